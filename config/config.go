@@ -1,19 +1,71 @@
 package config
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// Environments accepted in ENV
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
 type Config struct {
-	Database DatabaseConfig
-	JWT      JWTConfig
-	Server   ServerConfig
-	OAuth    OAuthConfig
+	// Env is the deployment environment; production enables strict
+	// validation of secrets and URLs
+	Env string
+
+	Database   DatabaseConfig
+	JWT        JWTConfig
+	Server     ServerConfig
+	OAuth      OAuthConfig
+	Redis      RedisConfig
+	State      StateConfig
+	AI         AIConfig
+	Storage    StorageConfig
+	Share      ShareConfig
+	CORS       CORSConfig
+	Login      LoginConfig
+	Security   SecurityConfig
+	Mail       MailConfig
+	BodyLog    BodyLogConfig
+	API        APIConfig
+	MCP        MCPConfig
+	Messages   MessagesConfig
+	Schedule   ScheduleConfig
+	Memory     MemoryConfig
+	Guardrails GuardrailsConfig
+	SSE        SSEConfig
+	Compress   CompressConfig
+	Invite     InviteConfig
+	Stats      StatsConfig
+	Retention  RetentionConfig
+	Queue      QueueConfig
+	Import     ImportConfig
+
+	// invalid lists environment variables that failed to parse and fell
+	// back to their defaults, reported by Validate
+	invalid []string
 }
 
+// Database drivers
+const (
+	// DatabaseDriverPostgres connects to an external PostgreSQL server
+	DatabaseDriverPostgres = "postgres"
+
+	// DatabaseDriverEmbedded downloads and runs a throwaway PostgreSQL server
+	// in-process, for local development and tests without Docker
+	DatabaseDriverEmbedded = "embedded"
+)
+
 type DatabaseConfig struct {
+	Driver       string
 	Host         string
 	Port         int
 	User         string
@@ -23,25 +75,481 @@ type DatabaseConfig struct {
 	MaxOpenConns int
 	MaxIdleConns int
 	MaxLifetime  time.Duration
+
+	// MinConns is the number of connections kept open even when idle
+	MinConns int
+
+	// MaxIdleTime closes connections idle for longer than this
+	MaxIdleTime time.Duration
+
+	// HealthCheckPeriod is how often idle connections are checked
+	HealthCheckPeriod time.Duration
+
+	// StatsInterval is how often pool statistics are logged (0 disables it)
+	StatsInterval time.Duration
+
+	// SlowQueryThreshold logs queries taking longer than this (0 disables it)
+	SlowQueryThreshold time.Duration
+
+	// MigrationLockTimeout bounds how long startup waits for another
+	// instance to finish migrating (0 waits indefinitely)
+	MigrationLockTimeout time.Duration
+
+	// MigrationsDir reads migrations from disk instead of the copies
+	// embedded in the binary
+	MigrationsDir string
+
+	// EmbeddedDataDir holds the embedded server's data and binaries; data
+	// survives restarts
+	EmbeddedDataDir string
+
+	// PasswordFunc, when set, supplies the password for each new
+	// connection so a rotated password is picked up without a restart
+	PasswordFunc func(ctx context.Context) (string, error)
 }
 
+// Access token signing algorithms
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
 type JWTConfig struct {
 	AccessSecret      string
 	RefreshSecret     string
 	AccessExpiration  time.Duration
 	RefreshExpiration time.Duration
+
+	// Algorithm signs access tokens; HS256 uses AccessSecret, RS256 and
+	// EdDSA use the PEM private key in PrivateKeyFile
+	Algorithm      string
+	PrivateKeyFile string
+
+	// PreviousAccessSecret and PreviousKeyFiles hold retired keys that still
+	// verify tokens issued before a rotation
+	PreviousAccessSecret string
+	PreviousKeyFiles     []string
 }
 
 type ServerConfig struct {
 	Port string
 	Host string
+
+	// PublicURL is the externally reachable base URL of the API server,
+	// used to build links to server-hosted resources such as avatars
+	PublicURL string
+
+	// HealthCheckTimeout bounds each dependency check of /health/ready
+	HealthCheckTimeout time.Duration
+
+	// GRPCAddr is the listen address of the gRPC chat service; empty
+	// disables it. Requires a binary built with the grpc tag.
+	GRPCAddr string
+
+	// MaxBodyBytes bounds request bodies, and MaxAuthBodyBytes those of
+	// the unauthenticated auth endpoints. Uploads have their own limit.
+	MaxBodyBytes     int64
+	MaxAuthBodyBytes int64
 }
 
 type OAuthConfig struct {
-	GitHub       OAuthProviderConfig
-	Google       OAuthProviderConfig
-	StateSecret  string
-	FrontendURL  string
+	GitHub      OAuthProviderConfig
+	Google      OAuthProviderConfig
+	StateSecret string
+	FrontendURL string
+}
+
+// ShareConfig controls public conversation share links
+type ShareConfig struct {
+	// Secret signs share tokens
+	Secret string
+}
+
+// CORSConfig controls which browser origins may call the API
+type CORSConfig struct {
+	// AllowedOrigins are exact origins such as https://app.example.com,
+	// wildcard subdomains such as https://*.example.com, or "*" for any
+	// origin (only without credentials)
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+
+	// AllowCredentials lets browsers send cookies; requests from origins
+	// outside the allowlist are then rejected
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// LoginConfig controls lockout after repeated failed logins
+type LoginConfig struct {
+	// MaxAttempts failures for one email within AttemptWindow lock it out
+	MaxAttempts   int
+	AttemptWindow time.Duration
+
+	// IPMaxAttempts failures from one IP within AttemptWindow lock the IP
+	// out, catching attacks spread over many emails
+	IPMaxAttempts int
+
+	// Lockout is the first lockout duration; each further lockout doubles
+	// it up to MaxLockout
+	Lockout    time.Duration
+	MaxLockout time.Duration
+}
+
+// SecurityConfig controls the detection of suspicious account activity.
+// Accounts it locks stay locked until their owner follows the link emailed
+// to them.
+type SecurityConfig struct {
+	// RefreshFailureLimit refreshes with a used or expired token of one
+	// account within RefreshFailureWindow lock it, as the token may have
+	// been stolen; zero disables the check
+	RefreshFailureLimit  int
+	RefreshFailureWindow time.Duration
+
+	// GeoIPURL looks up the country of a client IP: "{ip}" is replaced and
+	// the response body must be an ISO country code. Empty disables the
+	// new country check.
+	GeoIPURL string
+
+	// LockNewCountry locks an account signed into from a country it wasn't
+	// used in before; otherwise the sign-in is only reported
+	LockNewCountry bool
+
+	// WebhookURL receives a JSON POST for every anomaly and lock
+	WebhookURL string
+
+	// AuditHashKey keys the hashes of emails in the audit log that belong
+	// to no account; empty uses a random key, so hashes only match within
+	// one run
+	AuditHashKey string
+}
+
+// MailConfig controls outgoing email. Without an SMTP host emails are
+// written to the log, which is enough for development.
+type MailConfig struct {
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+}
+
+// BodyLogConfig controls debug logging of request and response bodies
+type BodyLogConfig struct {
+	// Enabled logs bodies at debug level on the http module; off by default
+	// in production
+	Enabled bool
+
+	// MaxBytes of each body are logged; the rest is cut off
+	MaxBytes int
+
+	// RedactPaths are paths whose bodies are never logged; a trailing "*"
+	// matches any suffix
+	RedactPaths []string
+
+	// RedactFields are JSON and form fields masked wherever they appear
+	RedactFields []string
+}
+
+// APIConfig controls the lifecycle of API versions
+type APIConfig struct {
+	// V1DeprecatedAt and V1Sunset announce the deprecation and removal of
+	// /api/v1 in the Deprecation and Sunset headers; zero omits them
+	V1DeprecatedAt time.Time
+	V1Sunset       time.Time
+
+	// V1DeprecationLink points clients at migration docs
+	V1DeprecationLink string
+}
+
+type RedisConfig struct {
+	URL       string
+	KeyPrefix string
+}
+
+// StateConfig selects where shared runtime state (OAuth states, rate limits,
+// stream buffers, token revocations) is kept
+type StateConfig struct {
+	// Backend is "memory" for single-instance deployments or "redis" to
+	// share state across replicas
+	Backend string
+}
+
+// AIConfig controls retries and failover for AI provider calls
+type AIConfig struct {
+	// Provider restricts generations to one provider: openai, azure,
+	// gateway or mock, which answers with scripted replies and needs no
+	// API key. Empty uses every configured provider in priority order.
+	Provider string
+
+	MaxRetries        int
+	RetryBackoff      time.Duration
+	RetryMaxBackoff   time.Duration
+	GenerationTimeout time.Duration
+	Failover          bool
+
+	// AllowedModels restricts which models users may select in their
+	// settings; empty allows any model
+	AllowedModels []string
+
+	// MaxToolRounds bounds how many rounds of tool calls one answer may make
+	MaxToolRounds int
+
+	// Temperature, TopP, MaxTokens and Stop are the generation parameters
+	// used when neither the message nor the user's settings set them; zero
+	// values leave them to the provider
+	Temperature float64
+	TopP        float64
+	MaxTokens   int
+	Stop        []string
+
+	// ContextStrategy is how conversation history is sent to the model when
+	// the conversation doesn't pick a strategy: sliding_window,
+	// summary_window or full_history. ContextWindow is how many recent
+	// messages the window strategies send.
+	ContextStrategy string
+	ContextWindow   int
+
+	// PricingFile adds or overrides model prices used for cost accounting
+	PricingFile string
+
+	// MaxConcurrency bounds the generations running at once (0 is
+	// unlimited); QueueDepth more may wait up to QueueTimeout for a slot
+	// before requests are rejected with 503
+	MaxConcurrency int
+	QueueDepth     int
+	QueueTimeout   time.Duration
+
+	// CredentialsKey encrypts the provider API keys organizations store
+	// for their own generations
+	CredentialsKey string
+
+	// CredentialHosts are the hosts an organization's credentials may set
+	// as their base_url, over https; none allows no base_url at all
+	CredentialHosts []string
+
+	// BreakerThreshold consecutive transient failures open a provider's
+	// circuit (0 disables the breakers); it stays open for BreakerCooldown
+	// before a trial request is let through
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// ProbeInterval is how often provider endpoints are probed; unreachable
+	// providers have their circuit opened (0 disables probes)
+	ProbeInterval time.Duration
+}
+
+// MCPConfig connects the agent to MCP (Model Context Protocol) servers
+// whose tools the model may call
+type MCPConfig struct {
+	// ServersFile is a YAML or JSON file declaring the servers; empty
+	// disables MCP
+	ServersFile string
+
+	// Timeout bounds connecting to a server and each call to it
+	Timeout time.Duration
+}
+
+// MessagesConfig controls how long deleted messages are kept
+type MessagesConfig struct {
+	// PurgeAfter is how long a soft-deleted message is kept before the
+	// purge job removes it for good
+	PurgeAfter time.Duration
+
+	// PurgeInterval is how often the purge job runs; zero leaves it to
+	// admins to run on demand
+	PurgeInterval time.Duration
+
+	// LockWait is how long a message waits for the previous one in the
+	// same conversation to be answered before it is rejected; zero rejects
+	// it at once
+	LockWait time.Duration
+
+	// LockTTL is how long a conversation stays locked after the instance
+	// answering in it died
+	LockTTL time.Duration
+
+	// RetryInterval is how often failed writes of replies are retried;
+	// zero leaves it to admins to run the retry job on demand
+	RetryInterval time.Duration
+
+	// RetryBatchSize is how many failed writes one run retries
+	RetryBatchSize int
+
+	// RetryMaxAttempts is how many times a failed write is retried before
+	// it is given up on
+	RetryMaxAttempts int
+}
+
+// RetentionConfig controls how long conversations are kept
+type RetentionConfig struct {
+	// ConversationDays is how many days conversations are kept after their
+	// last activity unless their organization overrides it; zero keeps
+	// them forever
+	ConversationDays int
+
+	// Interval is how often expired conversations are deleted; zero leaves
+	// it to admins to run the job on demand
+	Interval time.Duration
+
+	// DryRun makes the retention and purge jobs report what they would
+	// delete without deleting anything
+	DryRun bool
+}
+
+// ImportConfig controls imports of conversations exported from other
+// assistants
+type ImportConfig struct {
+	// MaxBytes bounds an uploaded export
+	MaxBytes int64
+
+	// SyncBytes is the size up to which an export is imported during the
+	// request; larger ones are imported by a queued task
+	SyncBytes int64
+}
+
+// QueueConfig controls the background task queue, which titles new
+// conversations, learns memories and delivers webhooks
+type QueueConfig struct {
+	// Workers is how many tasks a process runs at once
+	Workers int
+
+	// MaxAttempts is how many times a task is run before it is kept as a
+	// dead letter
+	MaxAttempts int
+
+	// RetryBackoff is how long the first retry of a failed task waits,
+	// doubling per failed attempt
+	RetryBackoff time.Duration
+
+	// TaskTimeout bounds each run of a task
+	TaskTimeout time.Duration
+}
+
+// MemoryConfig controls the agent's long-term memory of users
+type MemoryConfig struct {
+	// Enabled turns extracting facts from messages and adding them to
+	// prompts on
+	Enabled bool
+
+	// MaxPerUser bounds how many facts are kept per user
+	MaxPerUser int
+}
+
+// GuardrailsConfig controls the prompt injection and jailbreak checks on
+// user messages and tool results
+type GuardrailsConfig struct {
+	// Enabled turns the checks on
+	Enabled bool
+
+	// RulesFile is a YAML or JSON file adding, replacing or disabling
+	// rules; empty keeps the built-in rules
+	RulesFile string
+
+	// Classifier also asks the model about text that passed the rules
+	Classifier bool
+
+	// ClassifierAction is block or warn
+	ClassifierAction string
+}
+
+// SSEConfig controls server-sent event streams and slow clients
+type SSEConfig struct {
+	// HeartbeatInterval is how often idle streams get a comment line
+	HeartbeatInterval time.Duration
+
+	// WriteTimeout bounds each write; a client that doesn't take an event
+	// in time is disconnected
+	WriteTimeout time.Duration
+
+	// BufferSize is how many events may wait for a slow client
+	BufferSize int
+
+	// DisconnectGrace is how long an answer keeps being generated after
+	// its client disconnected, so it can be resumed. The generation is
+	// cancelled when no client has resumed it within this time.
+	DisconnectGrace time.Duration
+}
+
+// CompressConfig controls Brotli and gzip compression of responses. Event
+// streams are never compressed.
+type CompressConfig struct {
+	Enabled bool
+
+	// Level is the gzip level from 1 (fastest) to 9 (smallest); -1 is the
+	// library default
+	Level int
+
+	// BrotliLevel is the Brotli level from 0 (fastest) to 11 (smallest)
+	BrotliLevel int
+
+	// MinSize is the smallest response body, in bytes, worth compressing
+	MinSize int
+}
+
+// InviteConfig controls invitations to organizations
+type InviteConfig struct {
+	// Secret signs invitation tokens
+	Secret string
+
+	// TTL is how long an invitation can be accepted
+	TTL time.Duration
+
+	// ExpireInterval is how often the job marking invitations past their
+	// TTL expired runs; zero leaves it to admins. Expired invitations are
+	// refused either way.
+	ExpireInterval time.Duration
+}
+
+// StatsConfig controls the activity statistics of users
+type StatsConfig struct {
+	// CacheTTL is how long a user's statistics are reused before they are
+	// computed again; zero computes them on every request
+	CacheTTL time.Duration
+}
+
+// ScheduleConfig controls how scheduled prompts are run
+type ScheduleConfig struct {
+	// PollInterval is how often due prompts are looked for; zero stops
+	// this instance from running them
+	PollInterval time.Duration
+
+	// BatchSize is how many due prompts one poll runs
+	BatchSize int
+
+	// Lease is how long a prompt being run is reserved for one instance
+	// before another may retry it
+	Lease time.Duration
+
+	// WebhookTimeout bounds each call to a prompt's webhook
+	WebhookTimeout time.Duration
+
+	// WebhookSecret signs webhook requests so receivers can verify them
+	WebhookSecret string
+}
+
+// StorageConfig controls where uploaded files are kept
+type StorageConfig struct {
+	// Backend is "local" or "s3" (any S3-compatible service such as MinIO)
+	Backend        string
+	MaxUploadBytes int64
+
+	// Local backend: files live under LocalPath and signed URLs point at
+	// PublicURL, the externally reachable /api/v1/files endpoint
+	LocalPath     string
+	PublicURL     string
+	SigningSecret string
+
+	// S3 backend
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
 }
 
 type OAuthProviderConfig struct {
@@ -49,30 +557,81 @@ type OAuthProviderConfig struct {
 	ClientSecret string
 	RedirectURL  string
 	Enabled      bool
+
+	// Scopes are requested at sign-in. OptionalScopes can be granted later
+	// for a linked account, one integration at a time.
+	Scopes         []string
+	OptionalScopes []string
 }
 
+// Insecure placeholder secrets used when the variables are unset
+const (
+	defaultJWTAccessSecret   = "your-secret-key"
+	defaultJWTRefreshSecret  = "your-refresh-secret-key"
+	defaultOAuthStateSecret  = "your-oauth-state-secret-32-bytes"
+	defaultShareSecret       = "your-share-link-secret"
+	defaultInviteSecret      = "your-org-invite-secret"
+	defaultWebhookSecret     = "your-schedule-webhook-secret"
+	defaultCredentialsKey    = "your-ai-credentials-key"
+	defaultStorageSigningKey = "your-storage-signing-secret"
+	defaultDatabasePassword  = "postgres"
+)
+
+// parseErrors collects malformed variables while Load runs
+var (
+	parseMu     sync.Mutex
+	parseErrors []string
+)
+
 func Load() *Config {
-	return &Config{
+	parseMu.Lock()
+	defer parseMu.Unlock()
+	parseErrors = nil
+
+	cfg := &Config{
+		Env: getEnv("ENV", EnvDevelopment),
 		Database: DatabaseConfig{
+			Driver:       getEnv("DB_DRIVER", DatabaseDriverPostgres),
 			Host:         getEnv("DB_HOST", "localhost"),
 			Port:         getEnvAsInt("DB_PORT", 5432),
 			User:         getEnv("DB_USER", "postgres"),
-			Password:     getEnv("DB_PASSWORD", "postgres"),
+			Password:     getEnv("DB_PASSWORD", defaultDatabasePassword),
 			Database:     getEnv("DB_NAME", "food_agent"),
 			SSLMode:      getEnv("DB_SSL_MODE", "disable"),
 			MaxOpenConns: getEnvAsInt("DB_MAX_OPEN_CONNS", 10),
 			MaxIdleConns: getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
 			MaxLifetime:  getEnvAsDuration("DB_MAX_LIFETIME", time.Hour),
+
+			MinConns:          getEnvAsInt("DB_MIN_CONNS", 0),
+			MaxIdleTime:       getEnvAsDuration("DB_MAX_IDLE_TIME", 30*time.Minute),
+			HealthCheckPeriod: getEnvAsDuration("DB_HEALTH_CHECK_PERIOD", time.Minute),
+			StatsInterval:     getEnvAsDuration("DB_POOL_STATS_INTERVAL", time.Minute),
+
+			SlowQueryThreshold:   getEnvAsDuration("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+			MigrationLockTimeout: getEnvAsDuration("DB_MIGRATION_LOCK_TIMEOUT", 5*time.Minute),
+			MigrationsDir:        getEnv("DB_MIGRATIONS_DIR", ""),
+
+			EmbeddedDataDir: getEnv("DB_EMBEDDED_DIR", ".data/postgres"),
 		},
 		JWT: JWTConfig{
-			AccessSecret:      getEnv("JWT_ACCESS_SECRET", "your-secret-key"),
-			RefreshSecret:     getEnv("JWT_REFRESH_SECRET", "your-refresh-secret-key"),
+			AccessSecret:      getEnv("JWT_ACCESS_SECRET", defaultJWTAccessSecret),
+			RefreshSecret:     getEnv("JWT_REFRESH_SECRET", defaultJWTRefreshSecret),
 			AccessExpiration:  getEnvAsDuration("JWT_ACCESS_EXPIRATION", 15*time.Minute),
 			RefreshExpiration: getEnvAsDuration("JWT_REFRESH_EXPIRATION", 7*24*time.Hour),
+
+			Algorithm:            getEnv("JWT_ALGORITHM", JWTAlgorithmHS256),
+			PrivateKeyFile:       getEnv("JWT_PRIVATE_KEY_FILE", ""),
+			PreviousAccessSecret: getEnv("JWT_PREVIOUS_ACCESS_SECRET", ""),
+			PreviousKeyFiles:     getEnvAsSlice("JWT_PREVIOUS_KEY_FILES"),
 		},
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "localhost"),
+			Port:               getEnv("SERVER_PORT", "8080"),
+			Host:               getEnv("SERVER_HOST", "localhost"),
+			PublicURL:          getEnv("SERVER_PUBLIC_URL", "http://localhost:8080"),
+			HealthCheckTimeout: getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			GRPCAddr:           getEnv("GRPC_ADDR", ""),
+			MaxBodyBytes:       int64(getEnvAsInt("SERVER_MAX_BODY_BYTES", 1<<20)),
+			MaxAuthBodyBytes:   int64(getEnvAsInt("SERVER_MAX_AUTH_BODY_BYTES", 16<<10)),
 		},
 		OAuth: OAuthConfig{
 			GitHub: OAuthProviderConfig{
@@ -80,17 +639,214 @@ func Load() *Config {
 				ClientSecret: getEnv("GITHUB_CLIENT_SECRET", ""),
 				RedirectURL:  getEnv("GITHUB_REDIRECT_URL", "http://localhost:8080/api/v1/auth/oauth/github/callback"),
 				Enabled:      getEnv("GITHUB_CLIENT_ID", "") != "" && getEnv("GITHUB_CLIENT_SECRET", "") != "",
+
+				Scopes:         getEnvAsSlice("GITHUB_SCOPES"),
+				OptionalScopes: getEnvAsSlice("GITHUB_OPTIONAL_SCOPES"),
 			},
 			Google: OAuthProviderConfig{
 				ClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
 				ClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
 				RedirectURL:  getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/api/v1/auth/oauth/google/callback"),
 				Enabled:      getEnv("GOOGLE_CLIENT_ID", "") != "" && getEnv("GOOGLE_CLIENT_SECRET", "") != "",
+
+				Scopes:         getEnvAsSlice("GOOGLE_SCOPES"),
+				OptionalScopes: getEnvAsSlice("GOOGLE_OPTIONAL_SCOPES"),
 			},
-			StateSecret: getEnv("OAUTH_STATE_SECRET", "your-oauth-state-secret-32-bytes"),
+			StateSecret: getEnv("OAUTH_STATE_SECRET", defaultOAuthStateSecret),
 			FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
 		},
+		Redis: RedisConfig{
+			URL:       getEnv("REDIS_URL", ""),
+			KeyPrefix: getEnv("REDIS_KEY_PREFIX", "eino:"),
+		},
+		State: StateConfig{
+			Backend: getEnv("STATE_BACKEND", defaultStateBackend()),
+		},
+		AI: AIConfig{
+			Provider:          getEnv("AI_PROVIDER", ""),
+			MaxRetries:        getEnvAsInt("AI_MAX_RETRIES", 2),
+			RetryBackoff:      getEnvAsDuration("AI_RETRY_BACKOFF", 500*time.Millisecond),
+			RetryMaxBackoff:   getEnvAsDuration("AI_RETRY_MAX_BACKOFF", 8*time.Second),
+			GenerationTimeout: getEnvAsDuration("AI_GENERATION_TIMEOUT", 2*time.Minute),
+			Failover:          getEnvAsBool("AI_FAILOVER", true),
+			AllowedModels:     getEnvAsSlice("AI_ALLOWED_MODELS"),
+			MaxToolRounds:     getEnvAsInt("AI_MAX_TOOL_ROUNDS", 5),
+			Temperature:       getEnvAsFloat("AI_TEMPERATURE", 0),
+			TopP:              getEnvAsFloat("AI_TOP_P", 0),
+			MaxTokens:         getEnvAsInt("AI_MAX_TOKENS", 0),
+			Stop:              getEnvAsSlice("AI_STOP"),
+			ContextStrategy:   getEnv("AI_CONTEXT_STRATEGY", "sliding_window"),
+			ContextWindow:     getEnvAsInt("AI_CONTEXT_WINDOW", 6),
+			PricingFile:       getEnv("AI_PRICING_FILE", ""),
+			MaxConcurrency:    getEnvAsInt("AI_MAX_CONCURRENCY", 32),
+			QueueDepth:        getEnvAsInt("AI_QUEUE_DEPTH", 64),
+			QueueTimeout:      getEnvAsDuration("AI_QUEUE_TIMEOUT", 30*time.Second),
+			CredentialsKey:    getEnv("AI_CREDENTIALS_KEY", defaultCredentialsKey),
+			CredentialHosts:   getEnvAsSlice("AI_CREDENTIAL_HOSTS"),
+			BreakerThreshold:  getEnvAsInt("AI_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:   getEnvAsDuration("AI_BREAKER_COOLDOWN", 30*time.Second),
+			ProbeInterval:     getEnvAsDuration("AI_PROBE_INTERVAL", time.Minute),
+		},
+		Share: ShareConfig{
+			Secret: getEnv("SHARE_LINK_SECRET", defaultShareSecret),
+		},
+		Storage: StorageConfig{
+			Backend:        getEnv("STORAGE_BACKEND", "local"),
+			MaxUploadBytes: int64(getEnvAsInt("UPLOAD_MAX_BYTES", 10<<20)),
+			LocalPath:      getEnv("STORAGE_LOCAL_PATH", "data/uploads"),
+			PublicURL:      getEnv("STORAGE_PUBLIC_URL", "http://localhost:8080/api/v1/files"),
+			SigningSecret:  getEnv("STORAGE_SIGNING_SECRET", defaultStorageSigningKey),
+			S3Endpoint:     getEnv("S3_ENDPOINT", ""),
+			S3Region:       getEnv("S3_REGION", "us-east-1"),
+			S3Bucket:       getEnv("S3_BUCKET", ""),
+			S3AccessKey:    getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey:    getEnv("S3_SECRET_KEY", ""),
+			S3UseSSL:       getEnvAsBool("S3_USE_SSL", true),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   getEnvAsSlice("CORS_ALLOWED_ORIGINS"),
+			AllowedMethods:   getEnvAsSlice("CORS_ALLOWED_METHODS"),
+			AllowedHeaders:   getEnvAsSlice("CORS_ALLOWED_HEADERS"),
+			AllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", true),
+			MaxAge:           getEnvAsDuration("CORS_MAX_AGE", 10*time.Minute),
+		},
+		Login: LoginConfig{
+			MaxAttempts:   getEnvAsInt("LOGIN_MAX_ATTEMPTS", 5),
+			AttemptWindow: getEnvAsDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+			IPMaxAttempts: getEnvAsInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+			Lockout:       getEnvAsDuration("LOGIN_LOCKOUT", time.Minute),
+			MaxLockout:    getEnvAsDuration("LOGIN_MAX_LOCKOUT", time.Hour),
+		},
+		BodyLog: BodyLogConfig{
+			MaxBytes:     getEnvAsInt("LOG_BODY_MAX_BYTES", 4096),
+			RedactPaths:  getEnvAsSlice("LOG_BODY_REDACT_PATHS"),
+			RedactFields: getEnvAsSlice("LOG_BODY_REDACT_FIELDS"),
+		},
+		API: APIConfig{
+			V1DeprecatedAt:    getEnvAsTime("API_V1_DEPRECATED_AT"),
+			V1Sunset:          getEnvAsTime("API_V1_SUNSET"),
+			V1DeprecationLink: getEnv("API_V1_DEPRECATION_LINK", ""),
+		},
+		MCP: MCPConfig{
+			ServersFile: getEnv("MCP_SERVERS_FILE", ""),
+			Timeout:     getEnvAsDuration("MCP_TIMEOUT", 30*time.Second),
+		},
+		Messages: MessagesConfig{
+			PurgeAfter:       getEnvAsDuration("MESSAGE_PURGE_AFTER", 30*24*time.Hour),
+			PurgeInterval:    getEnvAsDuration("MESSAGE_PURGE_INTERVAL", 24*time.Hour),
+			LockWait:         getEnvAsDuration("MESSAGE_LOCK_WAIT", 0),
+			LockTTL:          getEnvAsDuration("MESSAGE_LOCK_TTL", 30*time.Second),
+			RetryInterval:    getEnvAsDuration("MESSAGE_RETRY_INTERVAL", 30*time.Second),
+			RetryBatchSize:   getEnvAsInt("MESSAGE_RETRY_BATCH_SIZE", 50),
+			RetryMaxAttempts: getEnvAsInt("MESSAGE_RETRY_MAX_ATTEMPTS", 20),
+		},
+		Retention: RetentionConfig{
+			ConversationDays: getEnvAsInt("RETENTION_CONVERSATION_DAYS", 0),
+			Interval:         getEnvAsDuration("RETENTION_INTERVAL", 24*time.Hour),
+			DryRun:           getEnvAsBool("RETENTION_DRY_RUN", false),
+		},
+		Import: ImportConfig{
+			MaxBytes:  int64(getEnvAsInt("IMPORT_MAX_BYTES", 100<<20)),
+			SyncBytes: int64(getEnvAsInt("IMPORT_SYNC_BYTES", 1<<20)),
+		},
+		Queue: QueueConfig{
+			Workers:      getEnvAsInt("QUEUE_WORKERS", 4),
+			MaxAttempts:  getEnvAsInt("QUEUE_MAX_ATTEMPTS", 5),
+			RetryBackoff: getEnvAsDuration("QUEUE_RETRY_BACKOFF", 10*time.Second),
+			TaskTimeout:  getEnvAsDuration("QUEUE_TASK_TIMEOUT", 2*time.Minute),
+		},
+		Memory: MemoryConfig{
+			Enabled:    getEnvAsBool("MEMORY_ENABLED", true),
+			MaxPerUser: getEnvAsInt("MEMORY_MAX_PER_USER", 50),
+		},
+		Guardrails: GuardrailsConfig{
+			Enabled:          getEnvAsBool("GUARDRAILS_ENABLED", true),
+			RulesFile:        getEnv("GUARDRAILS_RULES_FILE", ""),
+			Classifier:       getEnvAsBool("GUARDRAILS_CLASSIFIER", false),
+			ClassifierAction: getEnv("GUARDRAILS_CLASSIFIER_ACTION", "warn"),
+		},
+		SSE: SSEConfig{
+			HeartbeatInterval: getEnvAsDuration("SSE_HEARTBEAT_INTERVAL", 15*time.Second),
+			WriteTimeout:      getEnvAsDuration("SSE_WRITE_TIMEOUT", 10*time.Second),
+			BufferSize:        getEnvAsInt("SSE_BUFFER_SIZE", 256),
+			DisconnectGrace:   getEnvAsDuration("SSE_DISCONNECT_GRACE", 30*time.Second),
+		},
+		Security: SecurityConfig{
+			RefreshFailureLimit:  getEnvAsInt("SECURITY_REFRESH_FAILURE_LIMIT", 5),
+			RefreshFailureWindow: getEnvAsDuration("SECURITY_REFRESH_FAILURE_WINDOW", 15*time.Minute),
+			GeoIPURL:             getEnv("GEOIP_URL", ""),
+			LockNewCountry:       getEnvAsBool("SECURITY_LOCK_NEW_COUNTRY", false),
+			WebhookURL:           getEnv("SECURITY_WEBHOOK_URL", ""),
+			AuditHashKey:         getEnv("AUDIT_HASH_KEY", ""),
+		},
+		Mail: MailConfig{
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			From:         getEnv("MAIL_FROM", "no-reply@localhost"),
+		},
+		Compress: CompressConfig{
+			Enabled:     getEnvAsBool("COMPRESS_ENABLED", true),
+			Level:       getEnvAsInt("COMPRESS_LEVEL", -1),
+			BrotliLevel: getEnvAsInt("COMPRESS_BROTLI_LEVEL", 4),
+			MinSize:     getEnvAsInt("COMPRESS_MIN_SIZE", 1024),
+		},
+		Invite: InviteConfig{
+			Secret:         getEnv("ORG_INVITE_SECRET", defaultInviteSecret),
+			TTL:            getEnvAsDuration("ORG_INVITE_TTL", 7*24*time.Hour),
+			ExpireInterval: getEnvAsDuration("ORG_INVITE_EXPIRE_INTERVAL", time.Hour),
+		},
+		Stats: StatsConfig{
+			CacheTTL: getEnvAsDuration("STATS_CACHE_TTL", 5*time.Minute),
+		},
+		Schedule: ScheduleConfig{
+			PollInterval:   getEnvAsDuration("SCHEDULE_POLL_INTERVAL", time.Minute),
+			BatchSize:      getEnvAsInt("SCHEDULE_BATCH_SIZE", 20),
+			Lease:          getEnvAsDuration("SCHEDULE_LEASE", 10*time.Minute),
+			WebhookTimeout: getEnvAsDuration("SCHEDULE_WEBHOOK_TIMEOUT", 10*time.Second),
+			WebhookSecret:  getEnv("SCHEDULE_WEBHOOK_SECRET", defaultWebhookSecret),
+		},
+	}
+	cfg.BodyLog.Enabled = getEnvAsBool("LOG_BODIES", !cfg.IsProduction())
+
+	// Sign-in only needs the user's profile and email
+	if len(cfg.OAuth.GitHub.Scopes) == 0 {
+		cfg.OAuth.GitHub.Scopes = []string{"user:email"}
+	}
+	if len(cfg.OAuth.Google.Scopes) == 0 {
+		cfg.OAuth.Google.Scopes = []string{
+			"https://www.googleapis.com/auth/userinfo.email",
+			"https://www.googleapis.com/auth/userinfo.profile",
+		}
 	}
+
+	// The frontend is the only origin allowed unless configured otherwise
+	if len(cfg.CORS.AllowedOrigins) == 0 {
+		cfg.CORS.AllowedOrigins = []string{cfg.OAuth.FrontendURL}
+	}
+	if len(cfg.CORS.AllowedMethods) == 0 {
+		cfg.CORS.AllowedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	}
+	if len(cfg.CORS.AllowedHeaders) == 0 {
+		cfg.CORS.AllowedHeaders = []string{"Content-Type", "Authorization", "X-Device-ID", "X-Org-ID", "X-Stream-Protocol"}
+	}
+
+	cfg.invalid = parseErrors
+	return cfg
+}
+
+// IsProduction reports whether the server runs in production mode
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+// defaultStateBackend uses Redis whenever it is configured
+func defaultStateBackend() string {
+	if getEnv("REDIS_URL", "") != "" {
+		return "redis"
+	}
+	return "memory"
 }
 
 func getEnv(key, defaultValue string) string {
@@ -105,13 +861,65 @@ func getEnvAsInt(name string, defaultVal int) int {
 	if value, err := strconv.Atoi(valueStr); err == nil {
 		return value
 	}
+	invalidEnv(name, valueStr, "integer")
 	return defaultVal
 }
 
+func getEnvAsFloat(name string, defaultVal float64) float64 {
+	valueStr := getEnv(name, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	invalidEnv(name, valueStr, "number")
+	return defaultVal
+}
+
+func getEnvAsBool(name string, defaultVal bool) bool {
+	valueStr := getEnv(name, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	invalidEnv(name, valueStr, "boolean")
+	return defaultVal
+}
+
+// getEnvAsSlice splits a comma-separated variable, dropping empty entries
+func getEnvAsSlice(name string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(name, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvAsDuration(name string, defaultVal time.Duration) time.Duration {
 	valueStr := getEnv(name, "")
 	if value, err := time.ParseDuration(valueStr); err == nil {
 		return value
 	}
+	invalidEnv(name, valueStr, "duration")
 	return defaultVal
-}
\ No newline at end of file
+}
+
+// getEnvAsTime parses an RFC 3339 timestamp or a 2006-01-02 date (UTC)
+func getEnvAsTime(name string) time.Time {
+	valueStr := getEnv(name, "")
+	if value, err := time.Parse(time.RFC3339, valueStr); err == nil {
+		return value
+	}
+	if value, err := time.Parse(time.DateOnly, valueStr); err == nil {
+		return value
+	}
+	invalidEnv(name, valueStr, "time")
+	return time.Time{}
+}
+
+// invalidEnv records a set variable that couldn't be parsed as kind
+func invalidEnv(name, value, kind string) {
+	if value == "" {
+		return
+	}
+	parseErrors = append(parseErrors, fmt.Sprintf("%s: invalid %s %q", name, kind, value))
+}
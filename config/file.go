@@ -0,0 +1,464 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFiles are tried in order when no config file is given
+var DefaultFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// fileKeys maps config file keys to the environment variables they set
+var fileKeys = map[string]string{
+	"env": "ENV",
+
+	"server.port":                 "SERVER_PORT",
+	"server.host":                 "SERVER_HOST",
+	"server.public_url":           "SERVER_PUBLIC_URL",
+	"server.health_check_timeout": "HEALTH_CHECK_TIMEOUT",
+	"server.grpc_addr":            "GRPC_ADDR",
+	"server.max_body_bytes":       "SERVER_MAX_BODY_BYTES",
+	"server.max_auth_body_bytes":  "SERVER_MAX_AUTH_BODY_BYTES",
+
+	"database.driver":                 "DB_DRIVER",
+	"database.host":                   "DB_HOST",
+	"database.port":                   "DB_PORT",
+	"database.user":                   "DB_USER",
+	"database.password":               "DB_PASSWORD",
+	"database.name":                   "DB_NAME",
+	"database.ssl_mode":               "DB_SSL_MODE",
+	"database.max_open_conns":         "DB_MAX_OPEN_CONNS",
+	"database.max_idle_conns":         "DB_MAX_IDLE_CONNS",
+	"database.max_lifetime":           "DB_MAX_LIFETIME",
+	"database.min_conns":              "DB_MIN_CONNS",
+	"database.max_idle_time":          "DB_MAX_IDLE_TIME",
+	"database.health_check_period":    "DB_HEALTH_CHECK_PERIOD",
+	"database.pool_stats_interval":    "DB_POOL_STATS_INTERVAL",
+	"database.slow_query_threshold":   "DB_SLOW_QUERY_THRESHOLD",
+	"database.migration_lock_timeout": "DB_MIGRATION_LOCK_TIMEOUT",
+	"database.migrations_dir":         "DB_MIGRATIONS_DIR",
+	"database.embedded_dir":           "DB_EMBEDDED_DIR",
+
+	"jwt.access_secret":      "JWT_ACCESS_SECRET",
+	"jwt.refresh_secret":     "JWT_REFRESH_SECRET",
+	"jwt.access_expiration":  "JWT_ACCESS_EXPIRATION",
+	"jwt.refresh_expiration": "JWT_REFRESH_EXPIRATION",
+	"jwt.algorithm":          "JWT_ALGORITHM",
+	"jwt.private_key_file":   "JWT_PRIVATE_KEY_FILE",
+	"jwt.previous_secret":    "JWT_PREVIOUS_ACCESS_SECRET",
+	"jwt.previous_key_files": "JWT_PREVIOUS_KEY_FILES",
+
+	"oauth.state_secret":           "OAUTH_STATE_SECRET",
+	"oauth.frontend_url":           "FRONTEND_URL",
+	"oauth.github.client_id":       "GITHUB_CLIENT_ID",
+	"oauth.github.client_secret":   "GITHUB_CLIENT_SECRET",
+	"oauth.github.redirect_url":    "GITHUB_REDIRECT_URL",
+	"oauth.github.scopes":          "GITHUB_SCOPES",
+	"oauth.github.optional_scopes": "GITHUB_OPTIONAL_SCOPES",
+	"oauth.google.client_id":       "GOOGLE_CLIENT_ID",
+	"oauth.google.client_secret":   "GOOGLE_CLIENT_SECRET",
+	"oauth.google.redirect_url":    "GOOGLE_REDIRECT_URL",
+	"oauth.google.scopes":          "GOOGLE_SCOPES",
+	"oauth.google.optional_scopes": "GOOGLE_OPTIONAL_SCOPES",
+
+	"cors.allowed_origins":   "CORS_ALLOWED_ORIGINS",
+	"cors.allowed_methods":   "CORS_ALLOWED_METHODS",
+	"cors.allowed_headers":   "CORS_ALLOWED_HEADERS",
+	"cors.allow_credentials": "CORS_ALLOW_CREDENTIALS",
+	"cors.max_age":           "CORS_MAX_AGE",
+
+	"redis.url":        "REDIS_URL",
+	"redis.key_prefix": "REDIS_KEY_PREFIX",
+	"state.backend":    "STATE_BACKEND",
+
+	"ai.provider":           "AI_PROVIDER",
+	"ai.max_retries":        "AI_MAX_RETRIES",
+	"ai.retry_backoff":      "AI_RETRY_BACKOFF",
+	"ai.retry_max_backoff":  "AI_RETRY_MAX_BACKOFF",
+	"ai.generation_timeout": "AI_GENERATION_TIMEOUT",
+	"ai.failover":           "AI_FAILOVER",
+	"ai.allowed_models":     "AI_ALLOWED_MODELS",
+	"ai.max_tool_rounds":    "AI_MAX_TOOL_ROUNDS",
+	"ai.temperature":        "AI_TEMPERATURE",
+	"ai.top_p":              "AI_TOP_P",
+	"ai.max_tokens":         "AI_MAX_TOKENS",
+	"ai.stop":               "AI_STOP",
+	"ai.context_strategy":   "AI_CONTEXT_STRATEGY",
+	"ai.context_window":     "AI_CONTEXT_WINDOW",
+	"ai.pricing_file":       "AI_PRICING_FILE",
+	"ai.max_concurrency":    "AI_MAX_CONCURRENCY",
+	"ai.queue_depth":        "AI_QUEUE_DEPTH",
+	"ai.queue_timeout":      "AI_QUEUE_TIMEOUT",
+	"ai.credentials_key":    "AI_CREDENTIALS_KEY",
+	"ai.credential_hosts":   "AI_CREDENTIAL_HOSTS",
+	"ai.breaker_threshold":  "AI_BREAKER_THRESHOLD",
+	"ai.breaker_cooldown":   "AI_BREAKER_COOLDOWN",
+	"ai.probe_interval":     "AI_PROBE_INTERVAL",
+	"ai.default_model":      "AI_DEFAULT_MODEL",
+	"ai.personas_file":      "PERSONAS_FILE",
+
+	"providers.openai.api_key":         "OPENAI_API_KEY",
+	"providers.openai.base_url":        "OPENAI_BASE_URL",
+	"providers.openai.model_name":      "OPENAI_MODEL_NAME",
+	"providers.openai.org_id":          "OPENAI_ORG_ID",
+	"providers.openai.supports_vision": "OPENAI_SUPPORTS_VISION",
+
+	"providers.azure.endpoint":        "AZURE_OPENAI_ENDPOINT",
+	"providers.azure.api_key":         "AZURE_OPENAI_API_KEY",
+	"providers.azure.deployment":      "AZURE_OPENAI_DEPLOYMENT",
+	"providers.azure.api_version":     "AZURE_OPENAI_API_VERSION",
+	"providers.azure.model_name":      "AZURE_OPENAI_MODEL_NAME",
+	"providers.azure.supports_vision": "AZURE_OPENAI_SUPPORTS_VISION",
+	"providers.azure.tenant_id":       "AZURE_TENANT_ID",
+	"providers.azure.client_id":       "AZURE_CLIENT_ID",
+	"providers.azure.client_secret":   "AZURE_CLIENT_SECRET",
+
+	"providers.gateway.base_url":        "GATEWAY_BASE_URL",
+	"providers.gateway.api_key":         "GATEWAY_API_KEY",
+	"providers.gateway.model_name":      "GATEWAY_MODEL_NAME",
+	"providers.gateway.headers":         "GATEWAY_HEADERS",
+	"providers.gateway.model_headers":   "GATEWAY_MODEL_HEADERS",
+	"providers.gateway.supports_vision": "GATEWAY_SUPPORTS_VISION",
+
+	"providers.mock.model_name":      "MOCK_AI_MODEL",
+	"providers.mock.reply":           "MOCK_AI_REPLY",
+	"providers.mock.script":          "MOCK_AI_SCRIPT",
+	"providers.mock.chunk_delay":     "MOCK_AI_CHUNK_DELAY",
+	"providers.mock.supports_vision": "MOCK_AI_SUPPORTS_VISION",
+
+	"logging.level":     "LOG_LEVEL",
+	"logging.format":    "LOG_FORMAT",
+	"logging.output":    "LOG_OUTPUT",
+	"logging.file_path": "LOG_FILE_PATH",
+
+	"logging.levels.ai":    "LOG_LEVEL_AI",
+	"logging.levels.db":    "LOG_LEVEL_DB",
+	"logging.levels.http":  "LOG_LEVEL_HTTP",
+	"logging.levels.auth":  "LOG_LEVEL_AUTH",
+	"logging.sample_debug": "LOG_SAMPLE_DEBUG",
+	"logging.sample_info":  "LOG_SAMPLE_INFO",
+	"logging.sample_burst": "LOG_SAMPLE_BURST",
+
+	"logging.redact_pii":         "LOG_REDACT_PII",
+	"logging.redact_key":         "LOG_REDACT_KEY",
+	"logging.redact_hash_fields": "LOG_REDACT_HASH_FIELDS",
+	"logging.redact_mask_fields": "LOG_REDACT_MASK_FIELDS",
+
+	"logging.service_name":        "LOG_SERVICE_NAME",
+	"logging.sink_buffer":         "LOG_SINK_BUFFER",
+	"logging.sink_batch_size":     "LOG_SINK_BATCH_SIZE",
+	"logging.sink_flush_interval": "LOG_SINK_FLUSH_INTERVAL",
+	"logging.loki_url":            "LOKI_URL",
+	"logging.loki_labels":         "LOKI_LABELS",
+	"logging.loki_tenant_id":      "LOKI_TENANT_ID",
+	"logging.loki_username":       "LOKI_USERNAME",
+	"logging.loki_password":       "LOKI_PASSWORD",
+	"logging.syslog_network":      "SYSLOG_NETWORK",
+	"logging.syslog_address":      "SYSLOG_ADDRESS",
+	"logging.otlp_endpoint":       "OTEL_EXPORTER_OTLP_ENDPOINT",
+	"logging.otlp_headers":        "OTEL_EXPORTER_OTLP_HEADERS",
+
+	"logging.bodies":             "LOG_BODIES",
+	"logging.body_max_bytes":     "LOG_BODY_MAX_BYTES",
+	"logging.body_redact_paths":  "LOG_BODY_REDACT_PATHS",
+	"logging.body_redact_fields": "LOG_BODY_REDACT_FIELDS",
+
+	"storage.backend":          "STORAGE_BACKEND",
+	"storage.max_upload_bytes": "UPLOAD_MAX_BYTES",
+	"storage.local_path":       "STORAGE_LOCAL_PATH",
+	"storage.public_url":       "STORAGE_PUBLIC_URL",
+	"storage.signing_secret":   "STORAGE_SIGNING_SECRET",
+	"storage.s3.endpoint":      "S3_ENDPOINT",
+	"storage.s3.region":        "S3_REGION",
+	"storage.s3.bucket":        "S3_BUCKET",
+	"storage.s3.access_key":    "S3_ACCESS_KEY",
+	"storage.s3.secret_key":    "S3_SECRET_KEY",
+	"storage.s3.use_ssl":       "S3_USE_SSL",
+
+	"share.secret": "SHARE_LINK_SECRET",
+
+	"rate_limits.auth":  "RATE_LIMIT_AUTH",
+	"rate_limits.share": "RATE_LIMIT_SHARE",
+	"rate_limits.embed": "RATE_LIMIT_EMBED",
+
+	"login.max_attempts":    "LOGIN_MAX_ATTEMPTS",
+	"login.ip_max_attempts": "LOGIN_IP_MAX_ATTEMPTS",
+	"login.attempt_window":  "LOGIN_ATTEMPT_WINDOW",
+	"login.lockout":         "LOGIN_LOCKOUT",
+	"login.max_lockout":     "LOGIN_MAX_LOCKOUT",
+
+	"security.refresh_failure_limit":  "SECURITY_REFRESH_FAILURE_LIMIT",
+	"security.refresh_failure_window": "SECURITY_REFRESH_FAILURE_WINDOW",
+	"security.geoip_url":              "GEOIP_URL",
+	"security.lock_new_country":       "SECURITY_LOCK_NEW_COUNTRY",
+	"security.webhook_url":            "SECURITY_WEBHOOK_URL",
+	"security.audit_hash_key":         "AUDIT_HASH_KEY",
+
+	"mail.smtp_host":     "SMTP_HOST",
+	"mail.smtp_port":     "SMTP_PORT",
+	"mail.smtp_username": "SMTP_USERNAME",
+	"mail.smtp_password": "SMTP_PASSWORD",
+	"mail.from":          "MAIL_FROM",
+
+	"api.v1_deprecated_at":    "API_V1_DEPRECATED_AT",
+	"api.v1_sunset":           "API_V1_SUNSET",
+	"api.v1_deprecation_link": "API_V1_DEPRECATION_LINK",
+
+	"mcp.servers_file": "MCP_SERVERS_FILE",
+	"mcp.timeout":      "MCP_TIMEOUT",
+
+	"messages.purge_after":        "MESSAGE_PURGE_AFTER",
+	"messages.purge_interval":     "MESSAGE_PURGE_INTERVAL",
+	"messages.lock_wait":          "MESSAGE_LOCK_WAIT",
+	"messages.lock_ttl":           "MESSAGE_LOCK_TTL",
+	"messages.retry_interval":     "MESSAGE_RETRY_INTERVAL",
+	"messages.retry_batch_size":   "MESSAGE_RETRY_BATCH_SIZE",
+	"messages.retry_max_attempts": "MESSAGE_RETRY_MAX_ATTEMPTS",
+
+	"retention.conversation_days": "RETENTION_CONVERSATION_DAYS",
+	"retention.interval":          "RETENTION_INTERVAL",
+	"retention.dry_run":           "RETENTION_DRY_RUN",
+
+	"import.max_bytes":  "IMPORT_MAX_BYTES",
+	"import.sync_bytes": "IMPORT_SYNC_BYTES",
+
+	"queue.workers":       "QUEUE_WORKERS",
+	"queue.max_attempts":  "QUEUE_MAX_ATTEMPTS",
+	"queue.retry_backoff": "QUEUE_RETRY_BACKOFF",
+	"queue.task_timeout":  "QUEUE_TASK_TIMEOUT",
+
+	"memory.enabled":      "MEMORY_ENABLED",
+	"memory.max_per_user": "MEMORY_MAX_PER_USER",
+
+	"guardrails.enabled":           "GUARDRAILS_ENABLED",
+	"guardrails.rules_file":        "GUARDRAILS_RULES_FILE",
+	"guardrails.classifier":        "GUARDRAILS_CLASSIFIER",
+	"guardrails.classifier_action": "GUARDRAILS_CLASSIFIER_ACTION",
+
+	"sse.heartbeat_interval": "SSE_HEARTBEAT_INTERVAL",
+	"sse.write_timeout":      "SSE_WRITE_TIMEOUT",
+	"sse.buffer_size":        "SSE_BUFFER_SIZE",
+	"sse.disconnect_grace":   "SSE_DISCONNECT_GRACE",
+
+	"compress.enabled":      "COMPRESS_ENABLED",
+	"compress.level":        "COMPRESS_LEVEL",
+	"compress.brotli_level": "COMPRESS_BROTLI_LEVEL",
+	"compress.min_size":     "COMPRESS_MIN_SIZE",
+
+	"invite.secret":          "ORG_INVITE_SECRET",
+	"invite.ttl":             "ORG_INVITE_TTL",
+	"invite.expire_interval": "ORG_INVITE_EXPIRE_INTERVAL",
+
+	"stats.cache_ttl": "STATS_CACHE_TTL",
+
+	"schedule.poll_interval":   "SCHEDULE_POLL_INTERVAL",
+	"schedule.batch_size":      "SCHEDULE_BATCH_SIZE",
+	"schedule.lease":           "SCHEDULE_LEASE",
+	"schedule.webhook_timeout": "SCHEDULE_WEBHOOK_TIMEOUT",
+	"schedule.webhook_secret":  "SCHEDULE_WEBHOOK_SECRET",
+
+	"secrets.provider":         "SECRETS_PROVIDER",
+	"secrets.path":             "SECRETS_PATH",
+	"secrets.refresh_interval": "SECRETS_REFRESH_INTERVAL",
+	"secrets.vault.addr":       "VAULT_ADDR",
+	"secrets.vault.namespace":  "VAULT_NAMESPACE",
+	"secrets.vault.mount":      "VAULT_MOUNT",
+	"secrets.aws.region":       "AWS_REGION",
+	"secrets.aws.endpoint":     "SECRETS_AWS_ENDPOINT",
+}
+
+// fileVars are the environment variables set from the config file, which a
+// reload may overwrite or clear
+var (
+	fileMu   sync.Mutex
+	fileVars = make(map[string]bool)
+)
+
+// LoadFile reads a YAML or TOML config file and exports its values as
+// environment variables for Load and the AI providers to pick up. Variables
+// that are already set win, so the environment overrides the file. An empty
+// path tries DefaultFiles and is not an error when none exist. It returns
+// the file that was read, if any.
+func LoadFile(path string) (string, error) {
+	if path == "" {
+		for _, candidate := range DefaultFiles {
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+		if path == "" {
+			return "", nil
+		}
+	}
+
+	values, err := readFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	if err := applyFile(values); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// fileReload is a config file that was re-read but not applied yet
+type fileReload struct {
+	// values are the variables the file sets
+	values map[string]string
+
+	// env is the environment once they replace those of the previous load
+	env map[string]string
+}
+
+// stageFile re-reads path and works out the environment it makes,
+// replacing the variables a previous load set while still leaving
+// variables from the real environment alone. The process environment is
+// left as it is until commit.
+func stageFile(path string) (*fileReload, error) {
+	values, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	reload := &fileReload{values: make(map[string]string), env: environ()}
+	for name := range fileVars {
+		delete(reload.env, name)
+	}
+	for name, value := range values {
+		if reload.env[name] != "" {
+			continue
+		}
+		reload.env[name] = value
+		reload.values[name] = value
+	}
+	return reload, nil
+}
+
+// commit switches the process environment to the reloaded file. Variables
+// are overwritten in place rather than cleared first, so concurrent readers
+// see the old value or the new one, never none.
+func (r *fileReload) commit() error {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	for name := range fileVars {
+		if _, ok := r.values[name]; !ok {
+			os.Unsetenv(name)
+			delete(fileVars, name)
+		}
+	}
+	for name, value := range r.values {
+		// Set since the file was staged, e.g. by a secret rotation
+		if current := os.Getenv(name); current != "" && !fileVars[name] {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+		fileVars[name] = true
+	}
+	return nil
+}
+
+// environ returns the process environment by variable name
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		env[name] = value
+	}
+	return env
+}
+
+// applyFile sets the variables from a config file that the environment
+// doesn't already set
+func applyFile(values map[string]string) error {
+	for name, value := range values {
+		// getEnv treats empty variables as unset, so they don't override
+		if current, ok := os.LookupEnv(name); ok && current != "" {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+		fileVars[name] = true
+	}
+	return nil
+}
+
+// readFile parses a config file into environment variable values
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unsupported config file format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	flat := make(map[string]string)
+	flatten("", values, flat)
+
+	var unknown []string
+	for key := range flat {
+		if _, ok := fileKeys[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown keys in config file %s: %s", path, strings.Join(unknown, ", "))
+	}
+
+	env := make(map[string]string, len(flat))
+	for key, value := range flat {
+		env[fileKeys[key]] = value
+	}
+	return env, nil
+}
+
+// flatten turns nested sections into dotted keys. Lists become
+// comma-separated values, matching getEnvAsSlice.
+func flatten(prefix string, value interface{}, out map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flatten(key, child, out)
+		}
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		out[prefix] = strings.Join(items, ",")
+	case nil:
+		// An empty key leaves the default in place
+	default:
+		out[prefix] = fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// RateLimit allows Limit requests per Window
+type RateLimit struct {
+	Limit  int           `json:"limit"`
+	Window time.Duration `json:"window"`
+}
+
+// Default rate limits per limiter name
+var defaultRateLimits = map[string]RateLimit{
+	"auth":  {Limit: 20, Window: time.Minute},
+	"share": {Limit: 60, Window: time.Minute},
+	"embed": {Limit: 120, Window: time.Minute},
+}
+
+// RuntimeConfig holds the settings that can change without a restart. A
+// snapshot is never modified once published; reloads replace it.
+type RuntimeConfig struct {
+	LogLevel string `json:"log_level"`
+
+	// LogModules overrides LogLevel per module, from LOG_LEVEL_<MODULE>
+	LogModules map[string]string `json:"log_modules"`
+
+	LogSampling LogSampling `json:"log_sampling"`
+
+	// RateLimits is keyed by limiter name (auth, share, embed)
+	RateLimits map[string]RateLimit `json:"rate_limits"`
+
+	// DefaultModel overrides the default provider's model; empty keeps
+	// the provider's own default
+	DefaultModel string `json:"default_model"`
+
+	// PersonasFile adds or overrides personas from a YAML or JSON file
+	PersonasFile string `json:"personas_file"`
+
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// LogSampling keeps 1 in N debug and info events once Burst events per
+// second have been logged; 0 or 1 disables sampling
+type LogSampling struct {
+	Debug int `json:"debug"`
+	Info  int `json:"info"`
+	Burst int `json:"burst"`
+}
+
+// RateLimit returns the limit for the named limiter
+func (r *RuntimeConfig) RateLimit(name string) RateLimit {
+	if limit, ok := r.RateLimits[name]; ok {
+		return limit
+	}
+	return defaultRateLimits[name]
+}
+
+// LoadRuntime reads the runtime settings from the environment
+func LoadRuntime(env string) (*RuntimeConfig, error) {
+	return loadRuntime(env, environ())
+}
+
+// loadRuntime reads the runtime settings from vars, an environment by
+// variable name
+func loadRuntime(env string, vars map[string]string) (*RuntimeConfig, error) {
+	defaultLevel := "debug"
+	if env == EnvProduction {
+		defaultLevel = "info"
+	}
+	// Empty variables count as unset, as with getEnv
+	getEnv := func(name, defaultValue string) string {
+		if value := vars[name]; value != "" {
+			return value
+		}
+		return defaultValue
+	}
+
+	rc := &RuntimeConfig{
+		LogLevel:     getEnv("LOG_LEVEL", defaultLevel),
+		LogModules:   make(map[string]string),
+		RateLimits:   make(map[string]RateLimit, len(defaultRateLimits)),
+		DefaultModel: getEnv("AI_DEFAULT_MODEL", ""),
+		PersonasFile: getEnv("PERSONAS_FILE", ""),
+		LoadedAt:     time.Now().UTC(),
+	}
+
+	for name, value := range vars {
+		if module, ok := strings.CutPrefix(name, "LOG_LEVEL_"); ok && module != "" && value != "" {
+			rc.LogModules[strings.ToLower(module)] = value
+		}
+	}
+
+	for variable, target := range map[string]*int{
+		"LOG_SAMPLE_DEBUG": &rc.LogSampling.Debug,
+		"LOG_SAMPLE_INFO":  &rc.LogSampling.Info,
+		"LOG_SAMPLE_BURST": &rc.LogSampling.Burst,
+	} {
+		value := getEnv(variable, "")
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s: must be a non-negative integer, got %q", variable, value)
+		}
+		*target = n
+	}
+
+	for name, limit := range defaultRateLimits {
+		variable := "RATE_LIMIT_" + strings.ToUpper(name)
+		value := getEnv(variable, "")
+		if value == "" {
+			rc.RateLimits[name] = limit
+			continue
+		}
+
+		parsed, err := parseRateLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", variable, err)
+		}
+		rc.RateLimits[name] = parsed
+	}
+
+	return rc, nil
+}
+
+// parseRateLimit parses "<limit>/<window>", e.g. "20/1m"
+func parseRateLimit(value string) (RateLimit, error) {
+	limitStr, windowStr, ok := strings.Cut(value, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, expected <limit>/<window> such as 20/1m", value)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+	if err != nil || limit <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit count %q", limitStr)
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowStr))
+	if err != nil || window <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit window %q", windowStr)
+	}
+
+	return RateLimit{Limit: limit, Window: window}, nil
+}
+
+// Watcher publishes RuntimeConfig snapshots and reloads them from the
+// config file on demand or on SIGHUP
+type Watcher struct {
+	configPath string
+	env        string
+	current    atomic.Pointer[RuntimeConfig]
+
+	// mu serializes reloads
+	mu        sync.Mutex
+	listeners []func(*RuntimeConfig) error
+}
+
+// NewWatcher loads the initial snapshot. configPath is the file returned
+// by LoadFile, empty when none is used.
+func NewWatcher(configPath, env string) (*Watcher, error) {
+	rc, err := LoadRuntime(env)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{configPath: configPath, env: env}
+	w.current.Store(rc)
+	return w, nil
+}
+
+// Current returns the active snapshot
+func (w *Watcher) Current() *RuntimeConfig {
+	return w.current.Load()
+}
+
+// OnReload registers fn to apply a new snapshot. Listeners run in
+// registration order and a failing listener aborts the reload, so register
+// the ones that can reject a snapshot first.
+func (w *Watcher) OnReload(fn func(*RuntimeConfig) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// Reload re-reads the config file and the environment and publishes the
+// new snapshot. On error the previous snapshot stays active, and so does
+// the environment: the file's variables are only exported once the
+// listeners accepted the snapshot.
+func (w *Watcher) Reload() (*RuntimeConfig, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	vars := environ()
+	var reload *fileReload
+	if w.configPath != "" {
+		var err error
+		if reload, err = stageFile(w.configPath); err != nil {
+			return nil, err
+		}
+		vars = reload.env
+	}
+
+	rc, err := loadRuntime(w.env, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, listener := range w.listeners {
+		if err := listener(rc); err != nil {
+			return nil, err
+		}
+	}
+
+	if reload != nil {
+		if err := reload.commit(); err != nil {
+			return nil, err
+		}
+	}
+	w.current.Store(rc)
+	return rc, nil
+}
+
+// WatchSignals reloads on SIGHUP until ctx is done. Failed reloads are
+// passed to onError and leave the active snapshot in place.
+func (w *Watcher) WatchSignals(ctx context.Context, onReload func(*RuntimeConfig), onError func(error)) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			rc, err := w.Reload()
+			if err != nil {
+				onError(err)
+				continue
+			}
+			onReload(rc)
+		}
+	}
+}
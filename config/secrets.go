@@ -0,0 +1,205 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Secrets providers accepted in SECRETS_PROVIDER
+const (
+	SecretsProviderVault = "vault"
+	SecretsProviderAWS   = "aws"
+)
+
+// secretVars are the variables a secrets manager may supply. Other keys in
+// the secret are ignored.
+var secretVars = []string{
+	"JWT_ACCESS_SECRET",
+	"JWT_REFRESH_SECRET",
+	"DB_PASSWORD",
+	"OPENAI_API_KEY",
+	"AZURE_OPENAI_API_KEY",
+	"AZURE_CLIENT_SECRET",
+	"GATEWAY_API_KEY",
+}
+
+// Secret is a version of a secret holding several values keyed by
+// environment variable name
+type Secret struct {
+	Values  map[string]string
+	Version string
+}
+
+// SecretsProvider reads secrets from an external secrets manager
+type SecretsProvider interface {
+	// Fetch returns the current version of the secret at path
+	Fetch(ctx context.Context, path string) (*Secret, error)
+}
+
+// Secrets caches a secret from a SecretsProvider and refreshes it when it
+// gets older than the TTL, notifying listeners when its version changes
+type Secrets struct {
+	provider SecretsProvider
+	path     string
+	ttl      time.Duration
+
+	mu        sync.Mutex
+	current   *Secret
+	fetchedAt time.Time
+	listeners []func(old, new *Secret)
+}
+
+// NewSecrets creates a cache for the secret at path
+func NewSecrets(provider SecretsProvider, path string, ttl time.Duration) *Secrets {
+	return &Secrets{provider: provider, path: path, ttl: ttl}
+}
+
+// LoadSecrets connects to the secrets manager selected by SECRETS_PROVIDER
+// and exports its values as environment variables, overriding any set
+// there, so Load and the AI providers see them. It returns nil when no
+// secrets manager is configured.
+func LoadSecrets(ctx context.Context) (*Secrets, error) {
+	name := getEnv("SECRETS_PROVIDER", "")
+	if name == "" {
+		return nil, nil
+	}
+
+	path := getEnv("SECRETS_PATH", "")
+	if path == "" {
+		return nil, fmt.Errorf("SECRETS_PATH is required when SECRETS_PROVIDER is set")
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	var provider SecretsProvider
+	switch name {
+	case SecretsProviderVault:
+		vault, err := NewVaultProvider(client)
+		if err != nil {
+			return nil, err
+		}
+		provider = vault
+	case SecretsProviderAWS:
+		aws, err := NewAWSProvider(client)
+		if err != nil {
+			return nil, err
+		}
+		provider = aws
+	default:
+		return nil, fmt.Errorf("unknown secrets provider: %s", name)
+	}
+
+	secrets := NewSecrets(provider, path, getEnvAsDuration("SECRETS_REFRESH_INTERVAL", 5*time.Minute))
+	if _, err := secrets.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// Get returns the value for key, refreshing the cache first if it expired.
+// When the refresh fails the cached value is returned, so an outage of the
+// secrets manager doesn't break callers holding a valid secret.
+func (s *Secrets) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	stale := s.current == nil || (s.ttl > 0 && time.Since(s.fetchedAt) > s.ttl)
+	s.mu.Unlock()
+
+	var refreshErr error
+	if stale {
+		_, refreshErr = s.Refresh(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return "", refreshErr
+	}
+	value, ok := s.current.Values[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", s.path, key)
+	}
+	return value, nil
+}
+
+// OnRotate registers fn to run after a refresh finds a new version
+func (s *Secrets) OnRotate(fn func(old, new *Secret)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Refresh fetches the secret and reports whether its version changed. A
+// new version is exported to the environment before listeners run.
+func (s *Secrets) Refresh(ctx context.Context) (bool, error) {
+	secret, err := s.provider.Fetch(ctx, s.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch secret %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	old := s.current
+	s.current = secret
+	s.fetchedAt = time.Now()
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	if old != nil && old.Version == secret.Version {
+		return false, nil
+	}
+
+	if err := exportSecret(secret); err != nil {
+		return false, err
+	}
+
+	if old != nil {
+		for _, listener := range listeners {
+			listener(old, secret)
+		}
+	}
+	return old != nil, nil
+}
+
+// exportSecret sets the known variables from secret. They are taken out of
+// the config file's variables so a config reload doesn't put the file's
+// value back.
+func exportSecret(secret *Secret) error {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	for _, key := range secretVars {
+		value, ok := secret.Values[key]
+		if !ok {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		delete(fileVars, key)
+	}
+	return nil
+}
+
+// Watch refreshes the secret every TTL until ctx is done so rotations are
+// picked up even when nothing reads the cache
+func (s *Secrets) Watch(ctx context.Context, onError func(error)) {
+	if s.ttl <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Refresh(ctx); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
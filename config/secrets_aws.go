@@ -0,0 +1,171 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AWSProvider reads secrets from AWS Secrets Manager. Requests are signed
+// with Signature Version 4 directly to avoid pulling in the AWS SDK.
+type AWSProvider struct {
+	client       *http.Client
+	endpoint     string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// NewAWSProvider configures Secrets Manager from AWS_REGION,
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and an
+// optional SECRETS_AWS_ENDPOINT (e.g. for LocalStack)
+func NewAWSProvider(client *http.Client) (*AWSProvider, error) {
+	p := &AWSProvider{
+		client:       client,
+		region:       getEnv("AWS_REGION", ""),
+		accessKey:    getEnv("AWS_ACCESS_KEY_ID", ""),
+		secretKey:    getEnv("AWS_SECRET_ACCESS_KEY", ""),
+		sessionToken: getEnv("AWS_SESSION_TOKEN", ""),
+	}
+	if p.region == "" || p.accessKey == "" || p.secretKey == "" {
+		return nil, fmt.Errorf("AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for the aws secrets provider")
+	}
+	p.endpoint = getEnv("SECRETS_AWS_ENDPOINT", fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", p.region))
+	return p, nil
+}
+
+// Fetch reads the current version of the secret named path. The secret
+// string must be a JSON object of key/value pairs.
+func (p *AWSProvider) Fetch(ctx context.Context, path string) (*Secret, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": path})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	p.sign(req, payload, time.Now().UTC())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("secrets manager returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var body struct {
+		SecretString string `json:"SecretString"`
+		VersionID    string `json:"VersionId"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets manager response: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(body.SecretString), &raw); err != nil {
+		return nil, fmt.Errorf("secret %s is not a JSON object: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		values[key] = fmt.Sprint(value)
+	}
+
+	return &Secret{Values: values, Version: body.VersionID}, nil
+}
+
+// sign adds Signature Version 4 headers to req
+func (p *AWSProvider) sign(req *http.Request, payload []byte, now time.Time) {
+	const service = "secretsmanager"
+
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if p.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.sessionToken)
+	}
+
+	u, _ := url.Parse(p.endpoint)
+	headers := map[string]string{
+		"content-type": req.Header.Get("Content-Type"),
+		"host":         u.Host,
+		"x-amz-date":   amzDate,
+		"x-amz-target": req.Header.Get("X-Amz-Target"),
+	}
+	if p.sessionToken != "" {
+		headers["x-amz-security-token"] = p.sessionToken
+	}
+	names := []string{"content-type", "host", "x-amz-date"}
+	if p.sessionToken != "" {
+		names = append(names, "x-amz-security-token")
+	}
+	names = append(names, "x-amz-target")
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalPath := u.EscapedPath()
+	if canonicalPath == "" {
+		canonicalPath = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath,
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(payload),
+	}, "\n")
+
+	scope := date + "/" + p.region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+p.secretKey), date)
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
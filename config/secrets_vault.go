@@ -0,0 +1,85 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// VaultProvider reads secrets from a HashiCorp Vault KV version 2 engine
+type VaultProvider struct {
+	client    *http.Client
+	addr      string
+	token     string
+	namespace string
+	mount     string
+}
+
+// NewVaultProvider configures Vault from VAULT_ADDR, VAULT_TOKEN,
+// VAULT_NAMESPACE and VAULT_MOUNT (default "secret")
+func NewVaultProvider(client *http.Client) (*VaultProvider, error) {
+	p := &VaultProvider{
+		client:    client,
+		addr:      strings.TrimSuffix(getEnv("VAULT_ADDR", ""), "/"),
+		token:     getEnv("VAULT_TOKEN", ""),
+		namespace: getEnv("VAULT_NAMESPACE", ""),
+		mount:     strings.Trim(getEnv("VAULT_MOUNT", "secret"), "/"),
+	}
+	if p.addr == "" || p.token == "" {
+		return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN are required for the vault secrets provider")
+	}
+	return p, nil
+}
+
+// vaultKVResponse is the body of a KV v2 read
+type vaultKVResponse struct {
+	Data struct {
+		Data     map[string]interface{} `json:"data"`
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	} `json:"data"`
+}
+
+// Fetch reads the latest version of the secret at path
+func (p *VaultProvider) Fetch(ctx context.Context, path string) (*Secret, error) {
+	url := fmt.Sprintf("%s/v1/%s/data/%s", p.addr, p.mount, strings.Trim(path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("vault returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var body vaultKVResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+
+	values := make(map[string]string, len(body.Data.Data))
+	for key, value := range body.Data.Data {
+		values[key] = fmt.Sprint(value)
+	}
+
+	return &Secret{
+		Values:  values,
+		Version: strconv.Itoa(body.Data.Metadata.Version),
+	}, nil
+}
@@ -0,0 +1,376 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// minSecretLength is the shortest signing secret accepted in production
+const minSecretLength = 32
+
+// maxStopSequences is the most stop sequences providers accept
+const maxStopSequences = 4
+
+// Validate checks the configuration and reports every problem at once.
+// Malformed values and inconsistent settings are always errors; insecure
+// defaults and development URLs are only rejected in production.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	for _, invalid := range c.invalid {
+		add("%s", invalid)
+	}
+
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		add("ENV: must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env)
+	}
+
+	switch c.Database.Driver {
+	case DatabaseDriverPostgres, DatabaseDriverEmbedded:
+	default:
+		add("DB_DRIVER: unsupported driver %q", c.Database.Driver)
+	}
+
+	switch c.JWT.Algorithm {
+	case JWTAlgorithmHS256:
+	case JWTAlgorithmRS256, JWTAlgorithmEdDSA:
+		if c.JWT.PrivateKeyFile == "" {
+			add("JWT_PRIVATE_KEY_FILE: required when JWT_ALGORITHM=%s", c.JWT.Algorithm)
+		}
+	default:
+		add("JWT_ALGORITHM: must be %s, %s or %s, got %q", JWTAlgorithmHS256, JWTAlgorithmRS256, JWTAlgorithmEdDSA, c.JWT.Algorithm)
+	}
+
+	switch c.State.Backend {
+	case "memory":
+	case "redis":
+		if c.Redis.URL == "" {
+			add("REDIS_URL: required when STATE_BACKEND=redis")
+		}
+	default:
+		add("STATE_BACKEND: must be memory or redis, got %q", c.State.Backend)
+	}
+
+	switch c.Storage.Backend {
+	case "local":
+	case "s3":
+		if c.Storage.S3Bucket == "" {
+			add("S3_BUCKET: required when STORAGE_BACKEND=s3")
+		}
+	default:
+		add("STORAGE_BACKEND: must be local or s3, got %q", c.Storage.Backend)
+	}
+
+	if c.OAuth.GitHub.Enabled {
+		if err := c.checkURL(c.OAuth.GitHub.RedirectURL); err != nil {
+			add("GITHUB_REDIRECT_URL: %v", err)
+		}
+	}
+	if c.OAuth.Google.Enabled {
+		if err := c.checkURL(c.OAuth.Google.RedirectURL); err != nil {
+			add("GOOGLE_REDIRECT_URL: %v", err)
+		}
+	}
+	scopeSettings := []struct {
+		name   string
+		scopes []string
+	}{
+		{"GITHUB_SCOPES", c.OAuth.GitHub.Scopes},
+		{"GITHUB_OPTIONAL_SCOPES", c.OAuth.GitHub.OptionalScopes},
+		{"GOOGLE_SCOPES", c.OAuth.Google.Scopes},
+		{"GOOGLE_OPTIONAL_SCOPES", c.OAuth.Google.OptionalScopes},
+	}
+	for _, setting := range scopeSettings {
+		for _, scope := range setting.scopes {
+			if strings.ContainsAny(scope, " \t") {
+				add("%s: scopes are comma-separated, got %q", setting.name, scope)
+			}
+		}
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				add("CORS_ALLOWED_ORIGINS: \"*\" can't be combined with CORS_ALLOW_CREDENTIALS=true")
+			}
+			continue
+		}
+		if err := checkOrigin(origin); err != nil {
+			add("CORS_ALLOWED_ORIGINS: %v", err)
+		}
+	}
+
+	if c.Server.MaxBodyBytes < 1 {
+		add("SERVER_MAX_BODY_BYTES: must be positive, got %d", c.Server.MaxBodyBytes)
+	}
+	if c.Server.MaxAuthBodyBytes < 1 {
+		add("SERVER_MAX_AUTH_BODY_BYTES: must be positive, got %d", c.Server.MaxAuthBodyBytes)
+	}
+
+	if c.Security.RefreshFailureLimit < 0 {
+		add("SECURITY_REFRESH_FAILURE_LIMIT: must not be negative, got %d", c.Security.RefreshFailureLimit)
+	}
+	if c.Security.RefreshFailureLimit > 0 && c.Security.RefreshFailureWindow <= 0 {
+		add("SECURITY_REFRESH_FAILURE_WINDOW: must be positive, got %s", c.Security.RefreshFailureWindow)
+	}
+	if c.Security.GeoIPURL != "" && !strings.Contains(c.Security.GeoIPURL, "{ip}") {
+		add("GEOIP_URL: must contain {ip}")
+	}
+	if c.Mail.SMTPHost != "" && (c.Mail.SMTPPort < 1 || c.Mail.SMTPPort > 65535) {
+		add("SMTP_PORT: must be a port number, got %d", c.Mail.SMTPPort)
+	}
+
+	switch c.AI.Provider {
+	case "", "openai", "azure", "gateway", "mock":
+	default:
+		add("AI_PROVIDER: must be openai, azure, gateway or mock, got %q", c.AI.Provider)
+	}
+	if c.AI.MaxToolRounds < 1 {
+		add("AI_MAX_TOOL_ROUNDS: must be at least 1, got %d", c.AI.MaxToolRounds)
+	}
+	if c.AI.Temperature < 0 || c.AI.Temperature > 2 {
+		add("AI_TEMPERATURE: must be between 0 and 2, got %g", c.AI.Temperature)
+	}
+	if c.AI.TopP < 0 || c.AI.TopP > 1 {
+		add("AI_TOP_P: must be between 0 and 1, got %g", c.AI.TopP)
+	}
+	if c.AI.MaxTokens < 0 {
+		add("AI_MAX_TOKENS: must not be negative, got %d", c.AI.MaxTokens)
+	}
+	for _, host := range c.AI.CredentialHosts {
+		if strings.ContainsAny(host, ":/ ") {
+			add("AI_CREDENTIAL_HOSTS: must be host names without scheme or port, got %q", host)
+		}
+	}
+	if len(c.AI.Stop) > maxStopSequences {
+		add("AI_STOP: at most %d stop sequences, got %d", maxStopSequences, len(c.AI.Stop))
+	}
+	switch c.AI.ContextStrategy {
+	case "sliding_window", "summary_window", "full_history":
+	default:
+		add("AI_CONTEXT_STRATEGY: must be sliding_window, summary_window or full_history, got %q", c.AI.ContextStrategy)
+	}
+	if c.AI.ContextWindow < 1 {
+		add("AI_CONTEXT_WINDOW: must be at least 1, got %d", c.AI.ContextWindow)
+	}
+	if c.AI.MaxConcurrency < 0 {
+		add("AI_MAX_CONCURRENCY: must not be negative, got %d", c.AI.MaxConcurrency)
+	}
+	if c.AI.QueueDepth < 0 {
+		add("AI_QUEUE_DEPTH: must not be negative, got %d", c.AI.QueueDepth)
+	}
+	if c.AI.QueueTimeout < 0 {
+		add("AI_QUEUE_TIMEOUT: must not be negative, got %s", c.AI.QueueTimeout)
+	}
+	if c.AI.BreakerThreshold < 0 {
+		add("AI_BREAKER_THRESHOLD: must not be negative, got %d", c.AI.BreakerThreshold)
+	}
+	if c.AI.BreakerThreshold > 0 && c.AI.BreakerCooldown <= 0 {
+		add("AI_BREAKER_COOLDOWN: must be positive, got %s", c.AI.BreakerCooldown)
+	}
+
+	if c.Messages.PurgeAfter <= 0 {
+		add("MESSAGE_PURGE_AFTER: must be positive, got %s", c.Messages.PurgeAfter)
+	}
+	if c.Messages.PurgeInterval < 0 {
+		add("MESSAGE_PURGE_INTERVAL: must not be negative, got %s", c.Messages.PurgeInterval)
+	}
+	if c.Messages.LockWait < 0 {
+		add("MESSAGE_LOCK_WAIT: must not be negative, got %s", c.Messages.LockWait)
+	}
+	if c.Messages.LockTTL < time.Second {
+		add("MESSAGE_LOCK_TTL: must be at least 1s, got %s", c.Messages.LockTTL)
+	}
+	if c.Messages.RetryInterval < 0 {
+		add("MESSAGE_RETRY_INTERVAL: must not be negative, got %s", c.Messages.RetryInterval)
+	}
+	if c.Messages.RetryBatchSize < 1 {
+		add("MESSAGE_RETRY_BATCH_SIZE: must be at least 1, got %d", c.Messages.RetryBatchSize)
+	}
+	if c.Messages.RetryMaxAttempts < 1 {
+		add("MESSAGE_RETRY_MAX_ATTEMPTS: must be at least 1, got %d", c.Messages.RetryMaxAttempts)
+	}
+
+	if c.Retention.ConversationDays < 0 {
+		add("RETENTION_CONVERSATION_DAYS: must not be negative, got %d", c.Retention.ConversationDays)
+	}
+	if c.Retention.Interval < 0 {
+		add("RETENTION_INTERVAL: must not be negative, got %s", c.Retention.Interval)
+	}
+
+	if c.Import.MaxBytes < 1 {
+		add("IMPORT_MAX_BYTES: must be positive, got %d", c.Import.MaxBytes)
+	}
+	if c.Import.SyncBytes < 0 || c.Import.SyncBytes > c.Import.MaxBytes {
+		add("IMPORT_SYNC_BYTES: must be between 0 and IMPORT_MAX_BYTES, got %d", c.Import.SyncBytes)
+	}
+
+	if c.Queue.Workers < 1 {
+		add("QUEUE_WORKERS: must be at least 1, got %d", c.Queue.Workers)
+	}
+	if c.Queue.MaxAttempts < 1 {
+		add("QUEUE_MAX_ATTEMPTS: must be at least 1, got %d", c.Queue.MaxAttempts)
+	}
+	if c.Queue.RetryBackoff <= 0 {
+		add("QUEUE_RETRY_BACKOFF: must be positive, got %s", c.Queue.RetryBackoff)
+	}
+	if c.Queue.TaskTimeout < time.Second {
+		add("QUEUE_TASK_TIMEOUT: must be at least 1s, got %s", c.Queue.TaskTimeout)
+	}
+	if c.Memory.MaxPerUser < 1 {
+		add("MEMORY_MAX_PER_USER: must be at least 1, got %d", c.Memory.MaxPerUser)
+	}
+
+	if c.Guardrails.ClassifierAction != "block" && c.Guardrails.ClassifierAction != "warn" {
+		add("GUARDRAILS_CLASSIFIER_ACTION: must be block or warn, got %q", c.Guardrails.ClassifierAction)
+	}
+
+	if c.SSE.HeartbeatInterval < 0 {
+		add("SSE_HEARTBEAT_INTERVAL: must not be negative, got %s", c.SSE.HeartbeatInterval)
+	}
+	if c.SSE.WriteTimeout < 0 {
+		add("SSE_WRITE_TIMEOUT: must not be negative, got %s", c.SSE.WriteTimeout)
+	}
+	if c.SSE.BufferSize < 0 {
+		add("SSE_BUFFER_SIZE: must not be negative, got %d", c.SSE.BufferSize)
+	}
+	if c.SSE.DisconnectGrace < 0 {
+		add("SSE_DISCONNECT_GRACE: must not be negative, got %s", c.SSE.DisconnectGrace)
+	}
+
+	if c.Compress.Level != -1 && (c.Compress.Level < 1 || c.Compress.Level > 9) {
+		add("COMPRESS_LEVEL: must be -1 or between 1 and 9, got %d", c.Compress.Level)
+	}
+	if c.Compress.BrotliLevel < 0 || c.Compress.BrotliLevel > 11 {
+		add("COMPRESS_BROTLI_LEVEL: must be between 0 and 11, got %d", c.Compress.BrotliLevel)
+	}
+	if c.Compress.MinSize < 0 {
+		add("COMPRESS_MIN_SIZE: must not be negative, got %d", c.Compress.MinSize)
+	}
+
+	if c.Invite.TTL <= 0 {
+		add("ORG_INVITE_TTL: must be positive, got %s", c.Invite.TTL)
+	}
+	if c.Invite.ExpireInterval < 0 {
+		add("ORG_INVITE_EXPIRE_INTERVAL: must not be negative, got %s", c.Invite.ExpireInterval)
+	}
+
+	if c.Stats.CacheTTL < 0 {
+		add("STATS_CACHE_TTL: must not be negative, got %s", c.Stats.CacheTTL)
+	}
+
+	if c.Schedule.PollInterval < 0 {
+		add("SCHEDULE_POLL_INTERVAL: must not be negative, got %s", c.Schedule.PollInterval)
+	}
+	if c.Schedule.BatchSize < 1 {
+		add("SCHEDULE_BATCH_SIZE: must be at least 1, got %d", c.Schedule.BatchSize)
+	}
+	if c.Schedule.Lease <= 0 {
+		add("SCHEDULE_LEASE: must be positive, got %s", c.Schedule.Lease)
+	}
+
+	if !c.API.V1DeprecatedAt.IsZero() && !c.API.V1Sunset.IsZero() && c.API.V1Sunset.Before(c.API.V1DeprecatedAt) {
+		add("API_V1_SUNSET: must not be before API_V1_DEPRECATED_AT")
+	}
+
+	if c.IsProduction() {
+		errs = append(errs, c.validateProduction()...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// secretSetting is a secret variable and its insecure placeholder
+type secretSetting struct {
+	name, value, placeholder string
+}
+
+// validateProduction rejects placeholder secrets, default credentials and
+// local URLs
+func (c *Config) validateProduction() []error {
+	var errs []error
+
+	secrets := []secretSetting{
+		{"JWT_REFRESH_SECRET", c.JWT.RefreshSecret, defaultJWTRefreshSecret},
+		{"OAUTH_STATE_SECRET", c.OAuth.StateSecret, defaultOAuthStateSecret},
+		{"SHARE_LINK_SECRET", c.Share.Secret, defaultShareSecret},
+		{"ORG_INVITE_SECRET", c.Invite.Secret, defaultInviteSecret},
+		{"SCHEDULE_WEBHOOK_SECRET", c.Schedule.WebhookSecret, defaultWebhookSecret},
+		{"AI_CREDENTIALS_KEY", c.AI.CredentialsKey, defaultCredentialsKey},
+	}
+	// The access secret is unused with asymmetric keys
+	if c.JWT.Algorithm == JWTAlgorithmHS256 {
+		secrets = append(secrets, secretSetting{"JWT_ACCESS_SECRET", c.JWT.AccessSecret, defaultJWTAccessSecret})
+	}
+	if c.Storage.Backend == "local" {
+		secrets = append(secrets, secretSetting{"STORAGE_SIGNING_SECRET", c.Storage.SigningSecret, defaultStorageSigningKey})
+	}
+
+	for _, secret := range secrets {
+		switch {
+		case secret.value == secret.placeholder:
+			errs = append(errs, fmt.Errorf("%s: must be set, the default is not secret", secret.name))
+		case len(secret.value) < minSecretLength:
+			errs = append(errs, fmt.Errorf("%s: must be at least %d characters", secret.name, minSecretLength))
+		}
+	}
+	if c.JWT.AccessSecret == c.JWT.RefreshSecret {
+		errs = append(errs, fmt.Errorf("JWT_REFRESH_SECRET: must differ from JWT_ACCESS_SECRET"))
+	}
+
+	if c.Database.Driver == DatabaseDriverEmbedded {
+		errs = append(errs, fmt.Errorf("DB_DRIVER: the embedded driver is for development only"))
+	} else if c.Database.Password == "" || c.Database.Password == defaultDatabasePassword {
+		errs = append(errs, fmt.Errorf("DB_PASSWORD: must be set to a non-default password"))
+	}
+
+	if err := c.checkURL(c.OAuth.FrontendURL); err != nil {
+		errs = append(errs, fmt.Errorf("FRONTEND_URL: %v", err))
+	}
+	if err := c.checkURL(c.Server.PublicURL); err != nil {
+		errs = append(errs, fmt.Errorf("SERVER_PUBLIC_URL: %v", err))
+	}
+
+	return errs
+}
+
+// checkURL requires an absolute http(s) URL, and in production one that
+// doesn't point at localhost
+func (c *Config) checkURL(value string) error {
+	if value == "" {
+		return errors.New("must be set")
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an absolute http(s) URL, got %q", value)
+	}
+
+	if c.IsProduction() {
+		host := u.Hostname()
+		if host == "localhost" || host == "127.0.0.1" || strings.HasSuffix(host, ".localhost") {
+			return fmt.Errorf("must not point at %s in production", host)
+		}
+	}
+
+	return nil
+}
+
+// checkOrigin requires scheme://host[:port] with nothing after it. The host
+// may start with "*." to allow any subdomain.
+func checkOrigin(origin string) error {
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) origin", origin)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("%q must be scheme://host[:port] without a path", origin)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package ai
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrProvidersUnavailable is returned when the circuit of every provider
+// that could serve a request is open
+var ErrProvidersUnavailable = errors.New("all AI providers are unavailable")
+
+// Circuit states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// BreakerConfig controls when provider circuits open and close
+type BreakerConfig struct {
+	// Threshold is how many consecutive transient failures open a circuit
+	// (0 disables the breakers)
+	Threshold int
+
+	// Cooldown is how long a circuit stays open before one trial request
+	// is let through
+	Cooldown time.Duration
+}
+
+// CircuitStatus is a snapshot of a provider's circuit
+type CircuitStatus struct {
+	State    string     `json:"state"`
+	Failures int        `json:"consecutive_failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	Opens    int64      `json:"opens"`
+	Rejected int64      `json:"rejected"`
+}
+
+type circuit struct {
+	state    string
+	failures int
+	openedAt time.Time
+	opens    int64
+	rejected int64
+
+	// trial is set while the one half-open request is in flight
+	trial bool
+}
+
+// Breakers holds a circuit breaker per provider. An open circuit turns
+// requests away from its provider until the cooldown passes or a health
+// probe succeeds; then a single trial request decides whether it closes
+// again.
+type Breakers struct {
+	config BreakerConfig
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// NewBreakers creates closed circuit breakers
+func NewBreakers(config BreakerConfig) *Breakers {
+	return &Breakers{
+		config:   config,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+func (b *Breakers) circuit(provider string) *circuit {
+	c, ok := b.circuits[provider]
+	if !ok {
+		c = &circuit{state: CircuitClosed}
+		b.circuits[provider] = c
+	}
+	return c
+}
+
+func (b *Breakers) enabled() bool {
+	return b != nil && b.config.Threshold > 0
+}
+
+// allow reports whether a request may go to provider, letting the trial
+// request through once an open circuit cooled down
+func (b *Breakers) allow(provider string) bool {
+	if !b.enabled() {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(provider)
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= b.config.Cooldown {
+		c.state = CircuitHalfOpen
+	}
+
+	switch {
+	case c.state == CircuitClosed:
+		return true
+	case c.state == CircuitHalfOpen && !c.trial:
+		c.trial = true
+		return true
+	}
+	c.rejected++
+	return false
+}
+
+// success closes provider's circuit
+func (b *Breakers) success(provider string) {
+	if !b.enabled() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(provider)
+	c.state = CircuitClosed
+	c.failures = 0
+	c.trial = false
+}
+
+// failure counts a transient failure, opening the circuit at the
+// threshold or when the trial request failed
+func (b *Breakers) failure(provider string) {
+	if !b.enabled() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(provider)
+	c.failures++
+	if c.state == CircuitHalfOpen || c.failures >= b.config.Threshold {
+		b.open(c)
+	}
+}
+
+// release ends a trial request that failed for reasons that say nothing
+// about the provider's health, so the next request becomes the trial
+func (b *Breakers) release(provider string) {
+	if !b.enabled() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.circuit(provider).trial = false
+}
+
+func (b *Breakers) open(c *circuit) {
+	if c.state != CircuitOpen {
+		c.opens++
+	}
+	c.state = CircuitOpen
+	c.openedAt = time.Now()
+	c.trial = false
+}
+
+// Report records the result of a health probe of provider. A failed probe
+// opens the circuit right away; a successful one lets the trial request
+// of an open circuit through without waiting for the cooldown.
+func (b *Breakers) Report(provider string, err error) {
+	if !b.enabled() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(provider)
+	switch {
+	case err != nil:
+		b.open(c)
+	case c.state == CircuitOpen:
+		c.state = CircuitHalfOpen
+	}
+}
+
+// Available reports whether provider's circuit isn't open
+func (b *Breakers) Available(provider string) bool {
+	if !b.enabled() {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[provider]
+	return !ok || c.state != CircuitOpen || time.Since(c.openedAt) >= b.config.Cooldown
+}
+
+// Snapshot returns the state of each provider's circuit
+func (b *Breakers) Snapshot() map[string]CircuitStatus {
+	snapshot := make(map[string]CircuitStatus)
+	if b == nil {
+		return snapshot
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for name, c := range b.circuits {
+		status := CircuitStatus{
+			State:    c.state,
+			Failures: c.failures,
+			Opens:    c.opens,
+			Rejected: c.rejected,
+		}
+		if c.state != CircuitClosed {
+			openedAt := c.openedAt
+			status.OpenedAt = &openedAt
+		}
+		snapshot[name] = status
+	}
+	return snapshot
+}
@@ -0,0 +1,47 @@
+package ai
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Credentials run a provider on another account than the configured one.
+// Empty BaseURL and Model keep the provider's.
+type Credentials struct {
+	APIKey  string
+	BaseURL string
+	Model   string
+}
+
+// CredentialedProvider is implemented by providers that can run with other
+// credentials, like an organization's own API key
+type CredentialedProvider interface {
+	Provider
+
+	// WithCredentials returns a copy of the provider using creds
+	WithCredentials(creds Credentials) Provider
+}
+
+// ModelResolver picks the models serving a generation in place of the
+// service's, like those of an organization's own API keys. No models and
+// no error keep the service's.
+type ModelResolver interface {
+	ModelsFor(ctx context.Context) ([]NamedModel, error)
+}
+
+type orgKey struct{}
+
+// WithOrg returns ctx generating for the organization a conversation
+// belongs to, whose own API keys are used if it stored any. A nil orgID is
+// a personal conversation.
+func WithOrg(ctx context.Context, orgID *uuid.UUID) context.Context {
+	return context.WithValue(ctx, orgKey{}, orgID)
+}
+
+// OrgFromContext returns the organization generations in ctx are for, or
+// nil
+func OrgFromContext(ctx context.Context) *uuid.UUID {
+	orgID, _ := ctx.Value(orgKey{}).(*uuid.UUID)
+	return orgID
+}
@@ -0,0 +1,194 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/shivaluma/eino-agent/internal/ai/templates"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+)
+
+// Context strategies with built-in history builders
+const (
+	ContextSlidingWindow = models.ContextSlidingWindow
+	ContextSummaryWindow = models.ContextSummaryWindow
+	ContextFullHistory   = models.ContextFullHistory
+)
+
+// HistoryBuilder picks the part of a conversation's history sent to the
+// model with a message. Builders are registered by context strategy name.
+type HistoryBuilder interface {
+	BuildHistory(ctx context.Context, req *ChatRequest) ([]*schema.Message, error)
+}
+
+// HistoryBuilderFunc adapts a function to a HistoryBuilder
+type HistoryBuilderFunc func(ctx context.Context, req *ChatRequest) ([]*schema.Message, error)
+
+// BuildHistory calls f
+func (f HistoryBuilderFunc) BuildHistory(ctx context.Context, req *ChatRequest) ([]*schema.Message, error) {
+	return f(ctx, req)
+}
+
+// DefaultContextWindow is how many recent messages the window strategies
+// send when the config doesn't say
+const DefaultContextWindow = 6
+
+// SlidingWindow sends the latest window messages of the history
+func SlidingWindow(window int) HistoryBuilder {
+	return HistoryBuilderFunc(func(ctx context.Context, req *ChatRequest) ([]*schema.Message, error) {
+		return latest(req.History, window), nil
+	})
+}
+
+// FullHistory sends the whole history the caller loaded
+func FullHistory() HistoryBuilder {
+	return HistoryBuilderFunc(func(ctx context.Context, req *ChatRequest) ([]*schema.Message, error) {
+		return req.History, nil
+	})
+}
+
+func latest(history []*schema.Message, window int) []*schema.Message {
+	if len(history) > window {
+		return history[len(history)-window:]
+	}
+	return history
+}
+
+// maxCachedSummaries bounds the conversation summaries kept in memory
+const maxCachedSummaries = 1000
+
+// summaryWindow sends the latest window messages after a summary of the
+// ones before them. Summaries are cached per conversation and extended with
+// the messages that left the window since, so each turn costs at most one
+// short summarization call.
+type summaryWindow struct {
+	window    int
+	summarize func(ctx context.Context, language, previous string, messages []*schema.Message) (string, error)
+
+	mu        sync.Mutex
+	summaries map[string]historySummary
+}
+
+type historySummary struct {
+	// last identifies the last message folded into the summary
+	last [sha256.Size]byte
+	text string
+}
+
+func newSummaryWindow(window int, summarize func(ctx context.Context, language, previous string, messages []*schema.Message) (string, error)) *summaryWindow {
+	return &summaryWindow{
+		window:    window,
+		summarize: summarize,
+		summaries: make(map[string]historySummary),
+	}
+}
+
+// BuildHistory falls back to the window alone when summarizing fails, so a
+// failing summary never fails the message
+func (b *summaryWindow) BuildHistory(ctx context.Context, req *ChatRequest) ([]*schema.Message, error) {
+	if len(req.History) <= b.window {
+		return req.History, nil
+	}
+
+	split := len(req.History) - b.window
+	older, recent := req.History[:split], req.History[split:]
+
+	summary, err := b.summary(ctx, req, older)
+	if err != nil {
+		logger.ModuleContext(ctx, "ai").Warn().Err(err).Msg("Failed to summarize conversation history, sending recent messages only")
+		return recent, nil
+	}
+
+	history := make([]*schema.Message, 0, len(recent)+1)
+	history = append(history, templates.HistorySummaryMessage(req.Language, summary))
+	return append(history, recent...), nil
+}
+
+// summary returns the summary of older, extending the cached one when it
+// covers a prefix of them
+func (b *summaryWindow) summary(ctx context.Context, req *ChatRequest, older []*schema.Message) (string, error) {
+	last := messageDigest(older[len(older)-1])
+
+	b.mu.Lock()
+	cached, ok := b.summaries[req.ConversationID]
+	b.mu.Unlock()
+
+	previous, pending := "", older
+	if ok {
+		if cached.last == last {
+			return cached.text, nil
+		}
+		// Messages summarized before may have dropped out of the loaded
+		// history, so the cached summary is matched by its last message
+		for i := len(older) - 2; i >= 0; i-- {
+			if messageDigest(older[i]) == cached.last {
+				previous, pending = cached.text, older[i+1:]
+				break
+			}
+		}
+	}
+
+	text, err := b.summarize(ctx, req.Language, previous, pending)
+	if err != nil {
+		return "", err
+	}
+
+	if req.ConversationID != "" {
+		b.mu.Lock()
+		if _, ok := b.summaries[req.ConversationID]; !ok && len(b.summaries) >= maxCachedSummaries {
+			for id := range b.summaries {
+				delete(b.summaries, id)
+				break
+			}
+		}
+		b.summaries[req.ConversationID] = historySummary{last: last, text: text}
+		b.mu.Unlock()
+	}
+	return text, nil
+}
+
+func messageDigest(msg *schema.Message) [sha256.Size]byte {
+	return sha256.Sum256([]byte(string(msg.Role) + "\x00" + msg.Content))
+}
+
+// summarizeHistory folds messages into the summary of the conversation so
+// far
+func (s *service) summarizeHistory(ctx context.Context, language, previous string, messages []*schema.Message) (string, error) {
+	prompt := templates.BuildHistorySummaryMessages(language, previous, messages)
+
+	models, err := s.resolveModels(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var response *schema.Message
+	err = s.execute(ctx, "summarize", models, func(ctx context.Context, m NamedModel) error {
+		result, err := m.Model.Generate(ctx, prompt)
+		response = result
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize history: %w", err)
+	}
+
+	return strings.TrimSpace(response.Content), nil
+}
+
+// history returns the history sent with req, built by the strategy the
+// request names or else the configured one
+func (s *service) history(ctx context.Context, req *ChatRequest) ([]*schema.Message, error) {
+	strategy := req.ContextStrategy
+	if strategy == "" {
+		strategy = s.config.ContextStrategy
+	}
+	builder, ok := s.historyBuilders[strategy]
+	if !ok {
+		builder = s.historyBuilders[ContextSlidingWindow]
+	}
+	return builder.BuildHistory(ctx, req)
+}
@@ -0,0 +1,218 @@
+package ai
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProviderStats holds call counters for a single provider
+type ProviderStats struct {
+	Requests  int64 `json:"requests"`
+	Successes int64 `json:"successes"`
+	Failures  int64 `json:"failures"`
+	Retries   int64 `json:"retries"`
+	Fallbacks int64 `json:"fallbacks"`
+
+	// ShortCircuits counts requests turned away by an open circuit
+	ShortCircuits int64 `json:"short_circuits"`
+}
+
+// latencyBuckets are the upper bounds of the latency histograms
+var latencyBuckets = []time.Duration{
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+	30 * time.Second, time.Minute, 2 * time.Minute,
+}
+
+// Bucket counts observations of at most LeMs milliseconds
+type Bucket struct {
+	LeMs  int64 `json:"le_ms"`
+	Count int64 `json:"count"`
+}
+
+// Histogram is a latency distribution. Buckets are cumulative; observations
+// above the last bound only show in Count.
+type Histogram struct {
+	Count   int64    `json:"count"`
+	SumMs   float64  `json:"sum_ms"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+func newHistogram() Histogram {
+	buckets := make([]Bucket, len(latencyBuckets))
+	for i, bound := range latencyBuckets {
+		buckets[i].LeMs = bound.Milliseconds()
+	}
+	return Histogram{Buckets: buckets}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	h.Count++
+	h.SumMs += float64(d) / float64(time.Millisecond)
+	for i, bound := range latencyBuckets {
+		if d <= bound {
+			h.Buckets[i].Count++
+		}
+	}
+}
+
+// ModelStats holds latency figures for one model of a provider, per
+// attempt
+type ModelStats struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Attempts int64  `json:"attempts"`
+
+	// Errors counts failed attempts by ErrorClass
+	Errors map[string]int64 `json:"errors"`
+
+	// Chunks is the number of stream chunks received
+	Chunks int64 `json:"chunks"`
+
+	// TimeToFirstToken covers streams that produced a chunk; Duration
+	// covers every attempt
+	TimeToFirstToken Histogram `json:"time_to_first_token"`
+	Duration         Histogram `json:"duration"`
+}
+
+type modelKey struct {
+	provider, model string
+}
+
+// Metrics collects per-provider call counters and per-model latencies
+type Metrics struct {
+	mu        sync.Mutex
+	providers map[string]*ProviderStats
+	models    map[modelKey]*ModelStats
+}
+
+// NewMetrics creates an empty metrics collector
+func NewMetrics() *Metrics {
+	return &Metrics{
+		providers: make(map[string]*ProviderStats),
+		models:    make(map[modelKey]*ModelStats),
+	}
+}
+
+func (m *Metrics) record(provider string, update func(*ProviderStats)) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.providers[provider]
+	if !ok {
+		stats = &ProviderStats{}
+		m.providers[provider] = stats
+	}
+	update(stats)
+}
+
+func (m *Metrics) request(provider string) {
+	m.record(provider, func(s *ProviderStats) { s.Requests++ })
+}
+
+func (m *Metrics) success(provider string) {
+	m.record(provider, func(s *ProviderStats) { s.Successes++ })
+}
+
+func (m *Metrics) failure(provider string) {
+	m.record(provider, func(s *ProviderStats) { s.Failures++ })
+}
+
+func (m *Metrics) retry(provider string) {
+	m.record(provider, func(s *ProviderStats) { s.Retries++ })
+}
+
+// fallback is counted against the provider that was abandoned
+func (m *Metrics) fallback(provider string) {
+	m.record(provider, func(s *ProviderStats) { s.Fallbacks++ })
+}
+
+func (m *Metrics) shortCircuit(provider string) {
+	m.record(provider, func(s *ProviderStats) { s.ShortCircuits++ })
+}
+
+// Snapshot returns a copy of the current counters keyed by provider name
+func (m *Metrics) Snapshot() map[string]ProviderStats {
+	snapshot := make(map[string]ProviderStats)
+	if m == nil {
+		return snapshot
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, stats := range m.providers {
+		snapshot[name] = *stats
+	}
+	return snapshot
+}
+
+// observe records one attempt on a model. A zero ttft means no chunk
+// arrived; errorClass is empty for successful attempts.
+func (m *Metrics) observe(provider, model string, ttft, duration time.Duration, chunks int, errorClass string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := modelKey{provider: provider, model: model}
+	stats, ok := m.models[key]
+	if !ok {
+		stats = &ModelStats{
+			Provider:         provider,
+			Model:            model,
+			Errors:           make(map[string]int64),
+			TimeToFirstToken: newHistogram(),
+			Duration:         newHistogram(),
+		}
+		m.models[key] = stats
+	}
+
+	stats.Attempts++
+	stats.Chunks += int64(chunks)
+	if errorClass != "" {
+		stats.Errors[errorClass]++
+	}
+	if ttft > 0 {
+		stats.TimeToFirstToken.observe(ttft)
+	}
+	stats.Duration.observe(duration)
+}
+
+// Models returns a copy of the per-model latencies sorted by provider and
+// model
+func (m *Metrics) Models() []ModelStats {
+	models := []ModelStats{}
+	if m == nil {
+		return models
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stats := range m.models {
+		s := *stats
+		s.Errors = make(map[string]int64, len(stats.Errors))
+		for class, count := range stats.Errors {
+			s.Errors[class] = count
+		}
+		s.TimeToFirstToken.Buckets = append([]Bucket(nil), stats.TimeToFirstToken.Buckets...)
+		s.Duration.Buckets = append([]Bucket(nil), stats.Duration.Buckets...)
+		models = append(models, s)
+	}
+
+	sort.Slice(models, func(i, j int) bool {
+		if models[i].Provider != models[j].Provider {
+			return models[i].Provider < models[j].Provider
+		}
+		return models[i].Model < models[j].Model
+	})
+	return models
+}
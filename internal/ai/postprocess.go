@@ -0,0 +1,359 @@
+package ai
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shivaluma/eino-agent/internal/ai/templates"
+)
+
+// PostProcessor is one step of the output pipeline. A streamed response
+// reaches it in segments; Hold says how many trailing bytes of the
+// buffered text must wait for more input because a match could still span
+// them. Steps may keep state, so each response gets new ones.
+type PostProcessor interface {
+	Process(segment string) string
+	Hold(buffered string) int
+}
+
+// maxHold bounds how much text a step holds back, so a long word or an
+// unclosed tag can't stall a stream
+const maxHold = 512
+
+// postPipeline runs a response through a chain of post-processors, in full
+// or chunk by chunk
+type postPipeline struct {
+	steps   []PostProcessor
+	pending []string
+}
+
+// newPostPipeline builds the steps described by specs, which have been
+// validated when the personas were loaded
+func newPostPipeline(specs []templates.PostProcessorSpec) *postPipeline {
+	p := &postPipeline{}
+	for _, spec := range specs {
+		switch spec.Type {
+		case templates.PostProcessorSanitizeMarkdown:
+			p.steps = append(p.steps, &markdownSanitizer{})
+		case templates.PostProcessorProfanity:
+			p.steps = append(p.steps, newProfanityFilter(spec.Words))
+		case templates.PostProcessorTruncate:
+			p.steps = append(p.steps, newTruncator(spec.MaxLength, spec.Marker))
+		case templates.PostProcessorRewriteLinks:
+			p.steps = append(p.steps, newLinkRewriter(spec.Template, spec.Hosts))
+		}
+	}
+	p.pending = make([]string, len(p.steps))
+	return p
+}
+
+// Write passes a chunk through the steps and returns the text that can be
+// sent on; the rest is held until the next chunk or Flush
+func (p *postPipeline) Write(chunk string) string {
+	text := chunk
+	for i, step := range p.steps {
+		buffered := p.pending[i] + text
+		hold := step.Hold(buffered)
+		p.pending[i] = buffered[len(buffered)-hold:]
+		text = step.Process(buffered[:len(buffered)-hold])
+	}
+	return text
+}
+
+// Flush returns what the steps still hold once the response is complete
+func (p *postPipeline) Flush() string {
+	var text string
+	for i, step := range p.steps {
+		buffered := p.pending[i] + text
+		p.pending[i] = ""
+		text = step.Process(buffered)
+	}
+	return text
+}
+
+// Process runs a complete response through the steps
+func (p *postPipeline) Process(text string) string {
+	return p.Write(text) + p.Flush()
+}
+
+// holdWhile returns the length of the trailing run of runes matching f, or
+// 0 when the run is longer than maxHold
+func holdWhile(buffered string, f func(rune) bool) int {
+	i := len(buffered)
+	for i > 0 {
+		r, size := utf8.DecodeLastRuneInString(buffered[:i])
+		if !f(r) {
+			break
+		}
+		i -= size
+	}
+	if hold := len(buffered) - i; hold <= maxHold {
+		return hold
+	}
+	return 0
+}
+
+func notSpace(r rune) bool { return !unicode.IsSpace(r) }
+
+// markdownText tracks fenced and inline code across segments so steps can
+// leave code untouched
+type markdownText struct {
+	inFence  bool
+	inInline bool
+}
+
+// apply runs fn over the parts of segment outside code
+func (m *markdownText) apply(segment string, fn func(string) string) string {
+	var b strings.Builder
+	for segment != "" {
+		i := strings.IndexAny(segment, "`\n")
+		if i < 0 {
+			i = len(segment)
+		}
+		if m.inFence || m.inInline {
+			b.WriteString(segment[:i])
+		} else {
+			b.WriteString(fn(segment[:i]))
+		}
+		segment = segment[i:]
+		if segment == "" {
+			break
+		}
+
+		if segment[0] == '\n' {
+			// Inline code doesn't span lines; a stray backtick must not
+			// hide the rest of the response
+			m.inInline = false
+			b.WriteByte('\n')
+			segment = segment[1:]
+			continue
+		}
+
+		ticks := len(segment) - len(strings.TrimLeft(segment, "`"))
+		switch {
+		case ticks >= 3 && !m.inInline:
+			m.inFence = !m.inFence
+		case ticks < 3 && !m.inFence:
+			m.inInline = !m.inInline
+		}
+		b.WriteString(segment[:ticks])
+		segment = segment[ticks:]
+	}
+	return b.String()
+}
+
+var (
+	htmlTagPattern    = regexp.MustCompile(`</?[a-zA-Z][a-zA-Z0-9-]*(\s[^<>]*)?/?>`)
+	unsafeLinkPattern = regexp.MustCompile(`(?i)\]\(\s*(javascript|vbscript|data):([^()]|\([^()]*\))*\)`)
+)
+
+// markdownSanitizer removes raw HTML tags and script links outside code
+type markdownSanitizer struct {
+	text markdownText
+}
+
+func (s *markdownSanitizer) Process(segment string) string {
+	return s.text.apply(segment, func(text string) string {
+		text = htmlTagPattern.ReplaceAllString(text, "")
+		return unsafeLinkPattern.ReplaceAllString(text, "](#)")
+	})
+}
+
+// Hold keeps back the last word, which may be a partial link target or
+// backtick run, and an unclosed tag
+func (s *markdownSanitizer) Hold(buffered string) int {
+	hold := holdWhile(buffered, notSpace)
+	if i := strings.LastIndexByte(buffered, '<'); i >= 0 && !strings.Contains(buffered[i:], ">") {
+		hold = max(hold, len(buffered)-i)
+	}
+	if hold > maxHold {
+		return 0
+	}
+	return hold
+}
+
+// defaultProfanity are masked by every profanity filter
+var defaultProfanity = []string{
+	"fuck", "fucking", "fucker", "shit", "bitch", "asshole", "bastard", "cunt", "dick",
+	"địt", "đéo", "đụ", "lồn", "cặc", "đm", "vcl", "vkl",
+}
+
+// profanityFilter masks listed words, keeping their first letter
+type profanityFilter struct {
+	words map[string]bool
+}
+
+func newProfanityFilter(extra []string) *profanityFilter {
+	words := make(map[string]bool, len(defaultProfanity)+len(extra))
+	for _, w := range append(defaultProfanity, extra...) {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			words[w] = true
+		}
+	}
+	return &profanityFilter{words: words}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+func (f *profanityFilter) Process(segment string) string {
+	var b strings.Builder
+	for segment != "" {
+		i := strings.IndexFunc(segment, isWordRune)
+		if i < 0 {
+			b.WriteString(segment)
+			break
+		}
+		b.WriteString(segment[:i])
+		segment = segment[i:]
+
+		end := strings.IndexFunc(segment, func(r rune) bool { return !isWordRune(r) })
+		if end < 0 {
+			end = len(segment)
+		}
+		word := segment[:end]
+		segment = segment[end:]
+
+		if !f.words[strings.ToLower(word)] {
+			b.WriteString(word)
+			continue
+		}
+		first, size := utf8.DecodeRuneInString(word)
+		b.WriteRune(first)
+		b.WriteString(strings.Repeat("*", utf8.RuneCountInString(word[size:])))
+	}
+	return b.String()
+}
+
+// Hold keeps back a word that may continue in the next chunk
+func (f *profanityFilter) Hold(buffered string) int {
+	return holdWhile(buffered, isWordRune)
+}
+
+// defaultTruncationMarker ends a truncated response
+const defaultTruncationMarker = "…"
+
+// truncator cuts the response after maxLength characters, preferring the
+// last space before the limit, and drops the rest
+type truncator struct {
+	maxLength int
+	marker    string
+	written   int
+	done      bool
+}
+
+func newTruncator(maxLength int, marker string) *truncator {
+	if marker == "" {
+		marker = defaultTruncationMarker
+	}
+	return &truncator{maxLength: maxLength, marker: marker}
+}
+
+func (t *truncator) Process(segment string) string {
+	if t.done || segment == "" {
+		return ""
+	}
+
+	length := utf8.RuneCountInString(segment)
+	if t.written+length <= t.maxLength {
+		t.written += length
+		return segment
+	}
+
+	// Cut at the last space unless the limit already falls between
+	// words. Segments end with a word, so the cut never splits one however
+	// the response was chunked.
+	t.done = true
+	runes := []rune(segment)
+	kept := string(runes[:t.maxLength-t.written])
+	if !unicode.IsSpace(runes[t.maxLength-t.written]) {
+		if i := strings.LastIndexFunc(kept, unicode.IsSpace); i >= 0 {
+			kept = kept[:i]
+		} else if t.written > 0 {
+			kept = ""
+		}
+	}
+	return strings.TrimRightFunc(kept, unicode.IsSpace) + t.marker
+}
+
+// Hold keeps back the last word and the space before it
+func (t *truncator) Hold(buffered string) int {
+	if t.done {
+		return 0
+	}
+	word := holdWhile(buffered, notSpace)
+	space := holdWhile(buffered[:len(buffered)-word], unicode.IsSpace)
+	if word+space > maxHold {
+		return 0
+	}
+	return word + space
+}
+
+var linkPattern = regexp.MustCompile(`https?://[^\s<>()\[\]"'` + "`" + `]+`)
+
+// linkRewriter replaces links outside code with a template, e.g. to send
+// them through a redirect service
+type linkRewriter struct {
+	template string
+	prefix   string
+	hosts    []string
+	text     markdownText
+}
+
+func newLinkRewriter(template string, hosts []string) *linkRewriter {
+	normalized := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			normalized = append(normalized, h)
+		}
+	}
+	return &linkRewriter{
+		template: template,
+		prefix:   template[:strings.Index(template, templates.URLPlaceholder)],
+		hosts:    normalized,
+	}
+}
+
+func (l *linkRewriter) Process(segment string) string {
+	return l.text.apply(segment, func(text string) string {
+		return linkPattern.ReplaceAllStringFunc(text, l.rewrite)
+	})
+}
+
+// rewrite applies the template to link, leaving trailing punctuation,
+// already rewritten links and links to other hosts alone
+func (l *linkRewriter) rewrite(link string) string {
+	trimmed := strings.TrimRight(link, ".,;:!?")
+	suffix := link[len(trimmed):]
+
+	if l.prefix != "" && strings.HasPrefix(trimmed, l.prefix) {
+		return link
+	}
+	u, err := url.Parse(trimmed)
+	if err != nil || !l.matchesHost(u.Hostname()) {
+		return link
+	}
+	return strings.ReplaceAll(l.template, templates.URLPlaceholder, url.QueryEscape(trimmed)) + suffix
+}
+
+func (l *linkRewriter) matchesHost(host string) bool {
+	if len(l.hosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, h := range l.hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hold keeps back the last word, which may be a partial link
+func (l *linkRewriter) Hold(buffered string) int {
+	return holdWhile(buffered, notSpace)
+}
@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Price is what a model costs, in US dollars per million tokens. Model
+// matches the exact model name or, failing that, the longest prefix, so
+// "gpt-4o" also prices dated snapshots such as "gpt-4o-2024-08-06".
+type Price struct {
+	Provider         string  `json:"provider" yaml:"provider"`
+	Model            string  `json:"model" yaml:"model"`
+	InputPerMillion  float64 `json:"input_per_million" yaml:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million" yaml:"output_per_million"`
+}
+
+// builtinPrices are list prices at the time of writing; a pricing file
+// overrides them
+var builtinPrices = []Price{
+	{Provider: "openai", Model: "gpt-4o", InputPerMillion: 2.50, OutputPerMillion: 10.00},
+	{Provider: "openai", Model: "gpt-4o-mini", InputPerMillion: 0.15, OutputPerMillion: 0.60},
+	{Provider: "openai", Model: "gpt-4.1", InputPerMillion: 2.00, OutputPerMillion: 8.00},
+	{Provider: "openai", Model: "gpt-4.1-mini", InputPerMillion: 0.40, OutputPerMillion: 1.60},
+	{Provider: "openai", Model: "gpt-4.1-nano", InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	{Provider: "openai", Model: "o4-mini", InputPerMillion: 1.10, OutputPerMillion: 4.40},
+	{Provider: "openai", Model: "gpt-3.5-turbo", InputPerMillion: 0.50, OutputPerMillion: 1.50},
+}
+
+// Pricing looks up model prices
+type Pricing struct {
+	prices map[string]Price
+}
+
+func priceKey(provider, model string) string {
+	return strings.ToLower(provider) + "/" + strings.ToLower(model)
+}
+
+// NewPricing creates a pricing table; later prices replace earlier ones
+// for the same provider and model
+func NewPricing(prices []Price) *Pricing {
+	p := &Pricing{prices: make(map[string]Price, len(prices))}
+	for _, price := range prices {
+		p.prices[priceKey(price.Provider, price.Model)] = price
+	}
+	return p
+}
+
+// pricingFile is the format of a pricing file
+type pricingFile struct {
+	Prices []Price `json:"prices" yaml:"prices"`
+}
+
+// LoadPricing returns the built-in prices plus those in a YAML or JSON
+// file, which may override built-ins. An empty path keeps the built-ins.
+func LoadPricing(path string) (*Pricing, error) {
+	prices := append([]Price(nil), builtinPrices...)
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pricing file: %w", err)
+		}
+
+		var file pricingFile
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			err = yaml.Unmarshal(data, &file)
+		case ".json":
+			err = json.Unmarshal(data, &file)
+		default:
+			return nil, fmt.Errorf("unsupported pricing file format: %s", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse pricing file %s: %w", path, err)
+		}
+
+		for _, price := range file.Prices {
+			if price.Provider == "" || price.Model == "" {
+				return nil, fmt.Errorf("price without a provider or model in %s", path)
+			}
+			if price.InputPerMillion < 0 || price.OutputPerMillion < 0 {
+				return nil, fmt.Errorf("negative price for %s/%s in %s", price.Provider, price.Model, path)
+			}
+			prices = append(prices, price)
+		}
+	}
+
+	return NewPricing(prices), nil
+}
+
+// Lookup returns the price of a model; a nil table has none
+func (p *Pricing) Lookup(provider, model string) (Price, bool) {
+	if p == nil || model == "" {
+		return Price{}, false
+	}
+	if price, ok := p.prices[priceKey(provider, model)]; ok {
+		return price, true
+	}
+
+	// Keys start with the provider, so a prefix match is the same provider
+	name := priceKey(provider, model)
+	var best Price
+	bestLen := 0
+	for key, price := range p.prices {
+		if strings.HasPrefix(name, key) && len(key) > bestLen {
+			best, bestLen = price, len(key)
+		}
+	}
+	return best, bestLen > 0
+}
+
+// Cost returns the price of usage in millionths of a US dollar, and false
+// when the model has no price or no usage was reported
+func (p *Pricing) Cost(provider, model string, usage *Usage) (int64, bool) {
+	price, ok := p.Lookup(provider, model)
+	if !ok || usage == nil {
+		return 0, false
+	}
+	// A price per million tokens is a price in micro-dollars per token
+	cost := float64(usage.PromptTokens)*price.InputPerMillion + float64(usage.CompletionTokens)*price.OutputPerMillion
+	return int64(math.Round(cost)), true
+}
+
+// Prices returns the table sorted by provider and model
+func (p *Pricing) Prices() []Price {
+	if p == nil {
+		return nil
+	}
+	list := make([]Price, 0, len(p.prices))
+	for _, price := range p.prices {
+		list = append(list, price)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return priceKey(list[i].Provider, list[i].Model) < priceKey(list[j].Provider, list[j].Model)
+	})
+	return list
+}
@@ -0,0 +1,55 @@
+package ai
+
+import "context"
+
+// Stages of a generation reported to a ProgressReporter
+const (
+	// StageQueued: the request was accepted and is waiting to run
+	StageQueued = "queued"
+
+	// StageRetrievingContext: the prompt and conversation history are
+	// being assembled
+	StageRetrievingContext = "retrieving_context"
+
+	// StageModelSelected: a provider and model were picked, again after a
+	// failover
+	StageModelSelected = "model_selected"
+
+	// StageGenerating: the model is producing output
+	StageGenerating = "generating"
+
+	// StageToolRunning: a tool called by the model is running
+	StageToolRunning = "tool_running"
+)
+
+// Status describes the current stage of a generation
+type Status struct {
+	Stage string
+
+	// Provider and Model are set in model_selected and generating
+	Provider string
+	Model    string
+
+	// Tool is the running tool in tool_running
+	Tool string
+}
+
+// ProgressReporter is told about the stages of a generation so clients can
+// show more than the text received so far
+type ProgressReporter interface {
+	Progress(ctx context.Context, status Status)
+}
+
+// ProgressFunc adapts a function to a ProgressReporter
+type ProgressFunc func(ctx context.Context, status Status)
+
+func (f ProgressFunc) Progress(ctx context.Context, status Status) {
+	f(ctx, status)
+}
+
+// report sends status to the request's reporter, if it has one
+func (req *ChatRequest) report(ctx context.Context, status Status) {
+	if req.Progress != nil {
+		req.Progress.Progress(ctx, status)
+	}
+}
@@ -0,0 +1,220 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// Provider implements the AI Provider interface for Azure OpenAI
+type Provider struct {
+	config *Config
+}
+
+// Config holds Azure OpenAI-specific configuration
+type Config struct {
+	// Endpoint is the resource endpoint, e.g.
+	// https://my-resource.openai.azure.com
+	Endpoint string
+
+	// Deployment is the deployment requests are sent to. Requested model
+	// names are used as deployment names as-is.
+	Deployment string
+	APIVersion string
+
+	// Model is the model behind the deployment, used for pricing and
+	// vision detection. It defaults to the deployment name.
+	Model string
+
+	// APIKey authenticates with a resource key. Without one, requests use
+	// Azure AD tokens for the service principal below.
+	APIKey       string
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+
+	// Vision overrides model-name based detection of image support
+	Vision *bool
+}
+
+// defaultAPIVersion is the Azure OpenAI API version used when
+// AZURE_OPENAI_API_VERSION is unset
+const defaultAPIVersion = "2024-10-21"
+
+// adScope is the Azure AD scope of Azure OpenAI tokens
+const adScope = "https://cognitiveservices.azure.com/.default"
+
+// NewProvider creates a new Azure OpenAI provider
+func NewProvider() ai.Provider {
+	return &Provider{
+		config: loadConfigFromEnv(),
+	}
+}
+
+// NewProviderWithConfig creates a new Azure OpenAI provider with custom
+// config
+func NewProviderWithConfig(config *Config) ai.Provider {
+	return &Provider{
+		config: config,
+	}
+}
+
+func loadConfigFromEnv() *Config {
+	deployment := os.Getenv("AZURE_OPENAI_DEPLOYMENT")
+	return &Config{
+		Endpoint:     strings.TrimSuffix(os.Getenv("AZURE_OPENAI_ENDPOINT"), "/"),
+		Deployment:   deployment,
+		APIVersion:   getEnvOrDefault("AZURE_OPENAI_API_VERSION", defaultAPIVersion),
+		Model:        getEnvOrDefault("AZURE_OPENAI_MODEL_NAME", deployment),
+		APIKey:       os.Getenv("AZURE_OPENAI_API_KEY"),
+		TenantID:     os.Getenv("AZURE_TENANT_ID"),
+		ClientID:     os.Getenv("AZURE_CLIENT_ID"),
+		ClientSecret: os.Getenv("AZURE_CLIENT_SECRET"),
+		Vision:       getEnvAsBoolPtr("AZURE_OPENAI_SUPPORTS_VISION"),
+	}
+}
+
+func getEnvAsBoolPtr(key string) *bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// CreateChatModel creates an Azure OpenAI chat model instance
+func (p *Provider) CreateChatModel(ctx context.Context) (model.ToolCallingChatModel, error) {
+	if !p.IsAvailable() {
+		return nil, fmt.Errorf("Azure OpenAI provider is not available: missing endpoint, deployment or credentials")
+	}
+
+	config := &openai.ChatModelConfig{
+		ByAzure:    true,
+		BaseURL:    p.config.Endpoint,
+		APIVersion: p.config.APIVersion,
+		Model:      p.config.Deployment,
+		APIKey:     p.config.APIKey,
+		// Model names are deployment names, which may contain dots
+		AzureModelMapperFunc: func(model string) string { return model },
+	}
+	if p.config.APIKey == "" {
+		config.HTTPClient = p.adClient()
+	}
+
+	chatModel, err := openai.NewChatModel(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure OpenAI chat model: %w", err)
+	}
+
+	return chatModel, nil
+}
+
+// adClient returns an HTTP client that authenticates with Azure AD tokens
+// for the configured service principal, refreshing them as they expire
+func (p *Provider) adClient() *http.Client {
+	credentials := &clientcredentials.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		TokenURL:     "https://login.microsoftonline.com/" + p.config.TenantID + "/oauth2/v2.0/token",
+		Scopes:       []string{adScope},
+	}
+	return &http.Client{
+		Transport: &oauth2.Transport{
+			Source: credentials.TokenSource(context.Background()),
+			Base:   stripKeyTransport{base: http.DefaultTransport},
+		},
+	}
+}
+
+// stripKeyTransport drops the empty api-key header the client sends
+// without a resource key, which Azure rejects next to a bearer token
+type stripKeyTransport struct {
+	base http.RoundTripper
+}
+
+func (t stripKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Del("api-key")
+	return t.base.RoundTrip(req)
+}
+
+// GetName returns the provider name
+func (p *Provider) GetName() string {
+	return "azure"
+}
+
+// IsAvailable checks if the provider is properly configured
+func (p *Provider) IsAvailable() bool {
+	if p.config.Endpoint == "" || p.config.Deployment == "" {
+		return false
+	}
+	return p.config.APIKey != "" || p.usesAD()
+}
+
+func (p *Provider) usesAD() bool {
+	return p.config.TenantID != "" && p.config.ClientID != "" && p.config.ClientSecret != ""
+}
+
+// visionModelPrefixes lists OpenAI model families that accept images
+var visionModelPrefixes = []string{"gpt-4o", "gpt-4.1", "gpt-4-turbo", "gpt-4.5", "gpt-5", "o1", "o3", "o4"}
+
+// SupportsVision reports whether the deployed model accepts images
+func (p *Provider) SupportsVision() bool {
+	if p.config.Vision != nil {
+		return *p.config.Vision
+	}
+
+	for _, prefix := range visionModelPrefixes {
+		if strings.HasPrefix(p.config.Model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetModel returns the model behind the deployment
+func (p *Provider) GetModel() string {
+	return p.config.Model
+}
+
+// GetEndpoint returns the configured resource endpoint
+func (p *Provider) GetEndpoint() string {
+	return p.config.Endpoint
+}
+
+// WithCredentials returns a provider on another Azure OpenAI resource key.
+// creds.BaseURL overrides the endpoint and creds.Model the deployment.
+func (p *Provider) WithCredentials(creds ai.Credentials) ai.Provider {
+	config := *p.config
+	config.APIKey = creds.APIKey
+	config.TenantID, config.ClientID, config.ClientSecret = "", "", ""
+	if creds.BaseURL != "" {
+		config.Endpoint = strings.TrimSuffix(creds.BaseURL, "/")
+	}
+	if creds.Model != "" {
+		config.Deployment = creds.Model
+		config.Model = creds.Model
+	}
+	return &Provider{config: &config}
+}
+
+// UpdateConfig updates the provider configuration
+func (p *Provider) UpdateConfig(config *Config) {
+	p.config = config
+}
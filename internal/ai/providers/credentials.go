@@ -0,0 +1,155 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/secretbox"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnsupportedProvider is returned for credentials of a provider that
+// isn't registered or can't run on other accounts
+var ErrUnsupportedProvider = errors.New("provider does not support organization credentials")
+
+// ErrBaseURLNotAllowed is returned for credentials whose base_url isn't an
+// https URL on one of the allowed hosts
+var ErrBaseURLNotAllowed = errors.New("base URL not allowed for organization credentials")
+
+// keyHintLength is how many trailing characters of a key are kept in the
+// clear to tell keys apart
+const keyHintLength = 4
+
+// OrgCredentials keeps the provider API keys organizations store, sealed at
+// rest
+type OrgCredentials struct {
+	factory *Factory
+	repo    *repository.OrganizationRepository
+	box     *secretbox.Box
+
+	// hosts are the hosts a base URL may point at. The server sends the
+	// organization's key and its conversations there, so anything else
+	// would let organization admins reach internal addresses.
+	hosts []string
+}
+
+// EnableOrgCredentials stores organizations' API keys in repo, sealed with
+// box, and makes ModelsFor resolve models running on them. Base URLs are
+// limited to https on hosts.
+func (f *Factory) EnableOrgCredentials(repo *repository.OrganizationRepository, box *secretbox.Box, hosts []string) *OrgCredentials {
+	f.credentials = &OrgCredentials{factory: f, repo: repo, box: box, hosts: hosts}
+	return f.credentials
+}
+
+// baseURLAllowed reports whether a base URL is https on an allowed host,
+// on the default port
+func (c *OrgCredentials) baseURLAllowed(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.User != nil || (u.Port() != "" && u.Port() != "443") {
+		return false
+	}
+	return slices.ContainsFunc(c.hosts, func(host string) bool {
+		return strings.EqualFold(host, u.Hostname())
+	})
+}
+
+// sealedFor binds a sealed key to its organization and provider, so it
+// can't be opened when copied to another row
+func sealedFor(orgID uuid.UUID, provider ProviderType) []byte {
+	return append(orgID[:], string(provider)...)
+}
+
+// Set stores an organization's credentials for a provider, replacing the
+// ones it had
+func (c *OrgCredentials) Set(ctx context.Context, orgID uuid.UUID, provider ProviderType, creds ai.Credentials, updatedBy uuid.UUID) (*models.ProviderCredential, error) {
+	if !c.factory.SupportsCredentials(provider) {
+		return nil, ErrUnsupportedProvider
+	}
+	if creds.BaseURL != "" && !c.baseURLAllowed(creds.BaseURL) {
+		return nil, ErrBaseURLNotAllowed
+	}
+
+	sealed, err := c.box.Seal(creds.APIKey, sealedFor(orgID, provider))
+	if err != nil {
+		return nil, err
+	}
+
+	cred := &models.ProviderCredential{
+		OrgID:           orgID,
+		Provider:        string(provider),
+		APIKeyEncrypted: sealed,
+		KeyHint:         keyHint(creds.APIKey),
+		UpdatedBy:       &updatedBy,
+	}
+	if creds.BaseURL != "" {
+		cred.BaseURL = &creds.BaseURL
+	}
+	if creds.Model != "" {
+		cred.Model = &creds.Model
+	}
+	if err := c.repo.SetCredential(ctx, cred); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// List returns an organization's stored credentials, without their keys
+func (c *OrgCredentials) List(ctx context.Context, orgID uuid.UUID) ([]models.ProviderCredential, error) {
+	return c.repo.ListCredentials(ctx, orgID)
+}
+
+// Delete removes an organization's credentials for a provider. It reports
+// false when there were none.
+func (c *OrgCredentials) Delete(ctx context.Context, orgID uuid.UUID, provider ProviderType) (bool, error) {
+	return c.repo.DeleteCredential(ctx, orgID, string(provider))
+}
+
+// open returns an organization's credentials by provider, with their keys
+func (c *OrgCredentials) open(ctx context.Context, orgID uuid.UUID) (map[ProviderType]ai.Credentials, error) {
+	stored, err := c.repo.ListCredentials(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := make(map[ProviderType]ai.Credentials, len(stored))
+	for _, cred := range stored {
+		provider := ProviderType(cred.Provider)
+		key, err := c.box.Open(cred.APIKeyEncrypted, sealedFor(orgID, provider))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s credentials: %w", provider, err)
+		}
+
+		opened := ai.Credentials{APIKey: key}
+		// Base URLs stored before the allowlist, or since removed from it,
+		// are checked again
+		if cred.BaseURL != nil && c.baseURLAllowed(*cred.BaseURL) {
+			opened.BaseURL = *cred.BaseURL
+		} else if cred.BaseURL != nil {
+			logger.ModuleContext(ctx, "ai").Warn().
+				Str("org_id", orgID.String()).
+				Str("provider", cred.Provider).
+				Msg("Ignoring organization base URL that is not allowed")
+		}
+		if cred.Model != nil {
+			opened.Model = *cred.Model
+		}
+		creds[provider] = opened
+	}
+	return creds, nil
+}
+
+func keyHint(key string) string {
+	if len(key) <= keyHintLength {
+		return ""
+	}
+	return key[len(key)-keyHintLength:]
+}
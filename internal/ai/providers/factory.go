@@ -1,10 +1,17 @@
 package providers
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 
+	"github.com/cloudwego/eino/components/model"
 	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/ai/providers/azure"
+	"github.com/shivaluma/eino-agent/internal/ai/providers/gateway"
+	"github.com/shivaluma/eino-agent/internal/ai/providers/mock"
 	"github.com/shivaluma/eino-agent/internal/ai/providers/openai"
+	"github.com/shivaluma/eino-agent/internal/health"
 )
 
 // ProviderType represents the type of AI provider
@@ -12,23 +19,40 @@ type ProviderType string
 
 const (
 	OpenAI    ProviderType = "openai"
+	Azure     ProviderType = "azure"
+	Gateway   ProviderType = "gateway"
 	Anthropic ProviderType = "anthropic"
 	Gemini    ProviderType = "gemini"
+
+	// Mock answers with scripted replies; it is only used when selected
+	Mock ProviderType = "mock"
 )
 
 // Factory creates AI providers based on type
 type Factory struct {
 	providers map[ProviderType]ai.Provider
+
+	// credentials are organizations' own API keys, when enabled
+	credentials *OrgCredentials
+
+	// breakers mark providers with an open circuit unavailable (optional)
+	breakers *ai.Breakers
+
+	// priority is the order in which providers are preferred
+	priority []ProviderType
 }
 
 // NewFactory creates a new provider factory
 func NewFactory() *Factory {
 	f := &Factory{
 		providers: make(map[ProviderType]ai.Provider),
+		priority:  defaultPriority,
 	}
 
 	// Register default providers
 	f.Register(OpenAI, openai.NewProvider())
+	f.Register(Azure, azure.NewProvider())
+	f.Register(Gateway, gateway.NewProvider())
 
 	// Future: Register other providers
 	// f.Register(Anthropic, anthropic.NewProvider())
@@ -42,6 +66,22 @@ func (f *Factory) Register(providerType ProviderType, provider ai.Provider) {
 	f.providers[providerType] = provider
 }
 
+// Select restricts the factory to one provider, so generations never
+// fail over to the others. The mock provider is only available this way.
+func (f *Factory) Select(providerType ProviderType) error {
+	provider, exists := f.providers[providerType]
+	if providerType == Mock {
+		provider, exists = mock.NewProvider(), true
+	}
+	if !exists {
+		return fmt.Errorf("unknown provider %s", providerType)
+	}
+
+	f.providers = map[ProviderType]ai.Provider{providerType: provider}
+	f.priority = []ProviderType{providerType}
+	return nil
+}
+
 // GetProvider returns a provider by type
 func (f *Factory) GetProvider(providerType ProviderType) (ai.Provider, error) {
 	provider, exists := f.providers[providerType]
@@ -53,26 +93,54 @@ func (f *Factory) GetProvider(providerType ProviderType) (ai.Provider, error) {
 		return nil, fmt.Errorf("provider %s is not available", providerType)
 	}
 
+	if !f.breakers.Available(string(providerType)) {
+		return nil, fmt.Errorf("provider %s is unhealthy", providerType)
+	}
+
 	return provider, nil
 }
 
-// GetAvailableProviders returns all available providers
+// SetBreakers makes providers whose circuit is open unavailable
+func (f *Factory) SetBreakers(breakers *ai.Breakers) {
+	f.breakers = breakers
+}
+
+// Probe checks that the endpoint of each configured provider answers and
+// reports the results to the breakers, returning the failures by provider
+func (f *Factory) Probe(ctx context.Context, client *http.Client) map[string]error {
+	failures := make(map[string]error)
+	for providerType, provider := range f.providers {
+		// Providers without an endpoint run in-process
+		if !provider.IsAvailable() || provider.GetEndpoint() == "" {
+			continue
+		}
+
+		err := health.Ping(ctx, client, provider.GetEndpoint())
+		f.breakers.Report(string(providerType), err)
+		if err != nil {
+			failures[string(providerType)] = err
+		}
+	}
+	return failures
+}
+
+// GetAvailableProviders returns all available and healthy providers
 func (f *Factory) GetAvailableProviders() []string {
 	var available []string
 	for providerType, provider := range f.providers {
-		if provider.IsAvailable() {
+		if provider.IsAvailable() && f.breakers.Available(string(providerType)) {
 			available = append(available, string(providerType))
 		}
 	}
 	return available
 }
 
+// defaultPriority is the order in which providers are preferred
+var defaultPriority = []ProviderType{OpenAI, Azure, Gateway, Anthropic, Gemini}
+
 // GetDefaultProvider returns the first available provider
 func (f *Factory) GetDefaultProvider() (ai.Provider, error) {
-	// Priority order
-	priority := []ProviderType{OpenAI, Anthropic, Gemini}
-
-	for _, providerType := range priority {
+	for _, providerType := range f.priority {
 		if provider, err := f.GetProvider(providerType); err == nil {
 			return provider, nil
 		}
@@ -80,3 +148,90 @@ func (f *Factory) GetDefaultProvider() (ai.Provider, error) {
 
 	return nil, fmt.Errorf("no available providers found")
 }
+
+// CreateChatModels creates a chat model for every available provider in
+// priority order, for use as a failover chain
+func (f *Factory) CreateChatModels(ctx context.Context) ([]ai.NamedModel, error) {
+	var models []ai.NamedModel
+	var lastErr error
+
+	for _, providerType := range f.priority {
+		provider, err := f.GetProvider(providerType)
+		if err != nil {
+			continue
+		}
+
+		chatModel, err := provider.CreateChatModel(ctx)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		models = append(models, namedModel(provider, chatModel))
+	}
+
+	if len(models) == 0 {
+		if lastErr != nil {
+			return nil, fmt.Errorf("no chat models could be created: %w", lastErr)
+		}
+		return nil, fmt.Errorf("no available providers found")
+	}
+
+	return models, nil
+}
+
+func namedModel(provider ai.Provider, chatModel model.ToolCallingChatModel) ai.NamedModel {
+	return ai.NamedModel{
+		Name:      provider.GetName(),
+		Model:     chatModel,
+		ModelName: provider.GetModel(),
+		Vision:    provider.SupportsVision(),
+		Endpoint:  provider.GetEndpoint(),
+	}
+}
+
+// SupportsCredentials reports whether a provider can run on an
+// organization's own API key
+func (f *Factory) SupportsCredentials(providerType ProviderType) bool {
+	_, ok := f.providers[providerType].(ai.CredentialedProvider)
+	return ok
+}
+
+// ModelsFor resolves the chat models of the organization generations in
+// ctx are for, running on its own API keys in priority order. It returns
+// none for personal conversations and organizations without keys, which
+// use the configured providers.
+func (f *Factory) ModelsFor(ctx context.Context) ([]ai.NamedModel, error) {
+	orgID := ai.OrgFromContext(ctx)
+	if orgID == nil || f.credentials == nil {
+		return nil, nil
+	}
+
+	creds, err := f.credentials.open(ctx, *orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	var models []ai.NamedModel
+	for _, providerType := range f.priority {
+		cred, ok := creds[providerType]
+		if !ok {
+			continue
+		}
+		provider, ok := f.providers[providerType].(ai.CredentialedProvider)
+		if !ok {
+			continue
+		}
+
+		own := provider.WithCredentials(cred)
+		chatModel, err := own.CreateChatModel(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		named := namedModel(own, chatModel)
+		named.OwnKey = true
+		models = append(models, named)
+	}
+	return models, nil
+}
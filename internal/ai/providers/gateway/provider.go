@@ -0,0 +1,196 @@
+// Package gateway serves models through any OpenAI-compatible endpoint, such
+// as OpenRouter, vLLM or LiteLLM
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/shivaluma/eino-agent/internal/ai"
+)
+
+// Provider implements the AI Provider interface for OpenAI-compatible
+// gateways
+type Provider struct {
+	config *Config
+}
+
+// Config holds gateway configuration
+type Config struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+
+	// Headers are sent with every request, e.g. OpenRouter's HTTP-Referer
+	// and X-Title
+	Headers map[string]string
+
+	// ModelHeaders are sent with requests for a model, on top of Headers,
+	// for gateways that route on headers
+	ModelHeaders map[string]map[string]string
+
+	// Vision reports whether the gateway's models accept images
+	Vision bool
+}
+
+// NewProvider creates a new gateway provider
+func NewProvider() ai.Provider {
+	return &Provider{
+		config: loadConfigFromEnv(),
+	}
+}
+
+// NewProviderWithConfig creates a new gateway provider with custom config
+func NewProviderWithConfig(config *Config) ai.Provider {
+	return &Provider{
+		config: config,
+	}
+}
+
+func loadConfigFromEnv() *Config {
+	vision, _ := strconv.ParseBool(os.Getenv("GATEWAY_SUPPORTS_VISION"))
+	return &Config{
+		BaseURL:      os.Getenv("GATEWAY_BASE_URL"),
+		APIKey:       os.Getenv("GATEWAY_API_KEY"),
+		Model:        os.Getenv("GATEWAY_MODEL_NAME"),
+		Headers:      parseHeaders(os.Getenv("GATEWAY_HEADERS")),
+		ModelHeaders: parseModelHeaders(os.Getenv("GATEWAY_MODEL_HEADERS")),
+		Vision:       vision,
+	}
+}
+
+// parseHeaders parses comma-separated Name=value pairs
+func parseHeaders(raw string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			continue
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+// parseModelHeaders parses a JSON object of headers keyed by model name.
+// Invalid JSON yields no model headers.
+func parseModelHeaders(raw string) map[string]map[string]string {
+	var headers map[string]map[string]string
+	if raw == "" || json.Unmarshal([]byte(raw), &headers) != nil {
+		return nil
+	}
+	return headers
+}
+
+// CreateChatModel creates a chat model on the gateway
+func (p *Provider) CreateChatModel(ctx context.Context) (model.ToolCallingChatModel, error) {
+	if !p.IsAvailable() {
+		return nil, fmt.Errorf("gateway provider is not available: missing base URL or model")
+	}
+
+	config := &openai.ChatModelConfig{
+		BaseURL: p.config.BaseURL,
+		Model:   p.config.Model,
+		APIKey:  p.config.APIKey,
+	}
+	if len(p.config.Headers) > 0 || len(p.config.ModelHeaders) > 0 {
+		config.HTTPClient = &http.Client{
+			Transport: &headerTransport{config: p.config, base: http.DefaultTransport},
+		}
+	}
+
+	chatModel, err := openai.NewChatModel(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gateway chat model: %w", err)
+	}
+
+	return chatModel, nil
+}
+
+// headerTransport adds the configured headers to requests, picking model
+// headers by the model named in the request body
+type headerTransport struct {
+	config *Config
+	base   http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	if len(t.config.ModelHeaders) > 0 && req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		var payload struct {
+			Model string `json:"model"`
+		}
+		if json.Unmarshal(body, &payload) == nil {
+			for name, value := range t.config.ModelHeaders[payload.Model] {
+				req.Header.Set(name, value)
+			}
+		}
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// GetName returns the provider name
+func (p *Provider) GetName() string {
+	return "gateway"
+}
+
+// IsAvailable checks if the provider is properly configured. Self-hosted
+// gateways often need no API key.
+func (p *Provider) IsAvailable() bool {
+	return p.config.BaseURL != "" && p.config.Model != ""
+}
+
+// SupportsVision reports whether the gateway's models accept images
+func (p *Provider) SupportsVision() bool {
+	return p.config.Vision
+}
+
+// GetModel returns the configured model name
+func (p *Provider) GetModel() string {
+	return p.config.Model
+}
+
+// GetEndpoint returns the gateway base URL
+func (p *Provider) GetEndpoint() string {
+	return p.config.BaseURL
+}
+
+// WithCredentials returns a provider on another gateway account, keeping
+// this one's endpoint, model and headers unless creds override them
+func (p *Provider) WithCredentials(creds ai.Credentials) ai.Provider {
+	config := *p.config
+	config.APIKey = creds.APIKey
+	if creds.BaseURL != "" {
+		config.BaseURL = creds.BaseURL
+	}
+	if creds.Model != "" {
+		config.Model = creds.Model
+	}
+	return &Provider{config: &config}
+}
+
+// UpdateConfig updates the provider configuration
+func (p *Provider) UpdateConfig(config *Config) {
+	p.config = config
+}
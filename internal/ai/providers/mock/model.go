@@ -0,0 +1,124 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// chatModel plays the script. Tools are accepted but never called.
+type chatModel struct {
+	config *Config
+
+	mu      sync.Mutex
+	rules   []Rule
+	retired []bool
+}
+
+func newChatModel(config *Config, rules []Rule) *chatModel {
+	return &chatModel{config: config, rules: rules, retired: make([]bool, len(rules))}
+}
+
+// answer picks the reply to the last user message of input, or the error
+// to fail with
+func (m *chatModel) answer(input []*schema.Message) (string, error) {
+	var message string
+	for i := len(input) - 1; i >= 0; i-- {
+		if input[i].Role == schema.User {
+			message = input[i].Content
+			break
+		}
+	}
+
+	reply := m.config.Reply
+	m.mu.Lock()
+	for i := range m.rules {
+		rule := &m.rules[i]
+		if m.retired[i] || !rule.matches(message) {
+			continue
+		}
+		m.retired[i] = rule.Once
+		if rule.Error != "" {
+			m.mu.Unlock()
+			return "", errors.New(rule.Error)
+		}
+		reply = rule.Reply
+		break
+	}
+	m.mu.Unlock()
+
+	return strings.ReplaceAll(reply, "{message}", message), nil
+}
+
+// meta reports a word count as token usage, which is enough for usage
+// accounting to have something to record
+func meta(input []*schema.Message, reply string) *schema.ResponseMeta {
+	prompt := 0
+	for _, msg := range input {
+		prompt += len(strings.Fields(msg.Content))
+	}
+	completion := len(strings.Fields(reply))
+	return &schema.ResponseMeta{
+		FinishReason: "stop",
+		Usage: &schema.TokenUsage{
+			PromptTokens:     prompt,
+			CompletionTokens: completion,
+			TotalTokens:      prompt + completion,
+		},
+	}
+}
+
+func (m *chatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	reply, err := m.answer(input)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := schema.AssistantMessage(reply, nil)
+	msg.ResponseMeta = meta(input, reply)
+	return msg, nil
+}
+
+// Stream sends the reply a word at a time, ChunkDelay apart, with the
+// usage on the last chunk
+func (m *chatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	reply, err := m.answer(input)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer writer.Close()
+
+		chunks := strings.SplitAfter(reply, " ")
+		for i, chunk := range chunks {
+			if m.config.ChunkDelay > 0 {
+				select {
+				case <-ctx.Done():
+					writer.Send(nil, ctx.Err())
+					return
+				case <-time.After(m.config.ChunkDelay):
+				}
+			}
+
+			msg := schema.AssistantMessage(chunk, nil)
+			if i == len(chunks)-1 {
+				msg.ResponseMeta = meta(input, reply)
+			}
+			if closed := writer.Send(msg, nil); closed {
+				return
+			}
+		}
+	}()
+	return reader, nil
+}
+
+func (m *chatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
@@ -0,0 +1,111 @@
+// Package mock answers without calling a model, with canned or scripted
+// replies, so the server runs without API keys in tests, CI and demos
+package mock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/shivaluma/eino-agent/internal/ai"
+)
+
+// Provider implements the AI Provider interface with scripted replies
+type Provider struct {
+	config *Config
+}
+
+// Config holds the mock's replies and pacing
+type Config struct {
+	// Model is the model name reported in responses and usage
+	Model string
+
+	// Reply answers messages no rule of the script matches; "{message}"
+	// is replaced by the user's message
+	Reply string
+
+	// ScriptFile is a YAML or JSON file of rules picking replies by
+	// message (optional)
+	ScriptFile string
+
+	// ChunkDelay is the pause before each streamed word
+	ChunkDelay time.Duration
+
+	// Vision makes the mock accept image attachments
+	Vision bool
+}
+
+// defaultReply answers when neither MOCK_AI_REPLY nor a script does
+const defaultReply = "This is a mock reply to: {message}"
+
+// NewProvider creates a new mock provider
+func NewProvider() ai.Provider {
+	return &Provider{
+		config: loadConfigFromEnv(),
+	}
+}
+
+// NewProviderWithConfig creates a new mock provider with custom config
+func NewProviderWithConfig(config *Config) ai.Provider {
+	return &Provider{
+		config: config,
+	}
+}
+
+func loadConfigFromEnv() *Config {
+	delay, err := time.ParseDuration(os.Getenv("MOCK_AI_CHUNK_DELAY"))
+	if err != nil {
+		delay = 30 * time.Millisecond
+	}
+	return &Config{
+		Model:      getEnvOrDefault("MOCK_AI_MODEL", "mock"),
+		Reply:      getEnvOrDefault("MOCK_AI_REPLY", defaultReply),
+		ScriptFile: os.Getenv("MOCK_AI_SCRIPT"),
+		ChunkDelay: delay,
+		Vision:     os.Getenv("MOCK_AI_SUPPORTS_VISION") == "true",
+	}
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// CreateChatModel loads the script and creates a chat model playing it
+func (p *Provider) CreateChatModel(ctx context.Context) (model.ToolCallingChatModel, error) {
+	script, err := loadScript(p.config.ScriptFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mock script: %w", err)
+	}
+	return newChatModel(p.config, script), nil
+}
+
+// GetName returns the provider name
+func (p *Provider) GetName() string {
+	return "mock"
+}
+
+// IsAvailable always reports true; the mock needs no credentials
+func (p *Provider) IsAvailable() bool {
+	return true
+}
+
+// SupportsVision reports whether the mock accepts images
+func (p *Provider) SupportsVision() bool {
+	return p.config.Vision
+}
+
+// GetModel returns the configured model name
+func (p *Provider) GetModel() string {
+	return p.config.Model
+}
+
+// GetEndpoint returns no endpoint; the mock runs in-process, so there is
+// nothing to probe
+func (p *Provider) GetEndpoint() string {
+	return ""
+}
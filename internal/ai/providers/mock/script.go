@@ -0,0 +1,83 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule picks the reply to messages matching it. Rules are tried in order
+// and the first match answers.
+type Rule struct {
+	// Match is a regular expression tried against the user's message;
+	// empty matches every message
+	Match string `json:"match" yaml:"match"`
+
+	// Reply is the answer; "{message}" is replaced by the user's message
+	Reply string `json:"reply" yaml:"reply"`
+
+	// Error, when set, fails the generation with it instead of replying,
+	// to try out error handling and failover
+	Error string `json:"error" yaml:"error"`
+
+	// Once retires the rule after it answered, so a list of rules can
+	// play a conversation in order
+	Once bool `json:"once" yaml:"once"`
+
+	pattern *regexp.Regexp
+}
+
+// scriptFile is the format of a script file
+type scriptFile struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// loadScript reads the rules of a YAML or JSON script file. An empty path
+// has no rules.
+func loadScript(path string) ([]Rule, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script file: %w", err)
+	}
+
+	var file scriptFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".json":
+		err = json.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("unsupported script file format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse script file %s: %w", path, err)
+	}
+
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+		if rule.Reply == "" && rule.Error == "" {
+			return nil, fmt.Errorf("rule %d in %s has neither a reply nor an error", i+1, path)
+		}
+		if rule.Match != "" {
+			rule.pattern, err = regexp.Compile(rule.Match)
+			if err != nil {
+				return nil, fmt.Errorf("invalid match of rule %d in %s: %w", i+1, path, err)
+			}
+		}
+	}
+	return file.Rules, nil
+}
+
+// matches reports whether the rule answers message
+func (r *Rule) matches(message string) bool {
+	return r.pattern == nil || r.pattern.MatchString(message)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/cloudwego/eino-ext/components/model/openai"
 	"github.com/cloudwego/eino/components/model"
@@ -23,6 +25,9 @@ type Config struct {
 	OrgID     string
 	Timeout   int
 	MaxTokens int
+
+	// Vision overrides model-name based detection of image support
+	Vision *bool
 }
 
 // NewProvider creates a new OpenAI provider
@@ -46,7 +51,16 @@ func loadConfigFromEnv() *Config {
 		Model:     getEnvOrDefault("OPENAI_MODEL_NAME", "gpt-4.1-mini"),
 		OrgID:     os.Getenv("OPENAI_ORG_ID"),
 		MaxTokens: 2000,
+		Vision:    getEnvAsBoolPtr("OPENAI_SUPPORTS_VISION"),
+	}
+}
+
+func getEnvAsBoolPtr(key string) *bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return nil
 	}
+	return &value
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -85,11 +99,54 @@ func (p *Provider) IsAvailable() bool {
 	return p.config.APIKey != ""
 }
 
+// visionModelPrefixes lists OpenAI model families that accept images
+var visionModelPrefixes = []string{"gpt-4o", "gpt-4.1", "gpt-4-turbo", "gpt-4.5", "gpt-5", "o1", "o3", "o4"}
+
+// SupportsVision reports whether the configured model accepts images
+func (p *Provider) SupportsVision() bool {
+	if p.config.Vision != nil {
+		return *p.config.Vision
+	}
+
+	for _, prefix := range visionModelPrefixes {
+		if strings.HasPrefix(p.config.Model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetModel returns the configured model name
 func (p *Provider) GetModel() string {
 	return p.config.Model
 }
 
+// defaultBaseURL is the OpenAI API used when OPENAI_BASE_URL is unset
+const defaultBaseURL = "https://api.openai.com/v1"
+
+// GetEndpoint returns the configured API base URL
+func (p *Provider) GetEndpoint() string {
+	if p.config.BaseURL != "" {
+		return p.config.BaseURL
+	}
+	return defaultBaseURL
+}
+
+// WithCredentials returns a provider on another OpenAI account, keeping
+// this one's endpoint and model unless creds override them
+func (p *Provider) WithCredentials(creds ai.Credentials) ai.Provider {
+	config := *p.config
+	config.APIKey = creds.APIKey
+	config.OrgID = ""
+	if creds.BaseURL != "" {
+		config.BaseURL = creds.BaseURL
+	}
+	if creds.Model != "" {
+		config.Model = creds.Model
+	}
+	return &Provider{config: &config}
+}
+
 // UpdateConfig updates the provider configuration
 func (p *Provider) UpdateConfig(config *Config) {
 	p.config = config
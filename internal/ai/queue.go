@@ -0,0 +1,209 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrOverloaded is matched by errors.Is when a generation was turned away
+// because the queue was full or the wait for a slot ran out
+var ErrOverloaded = errors.New("AI generation queue is full")
+
+// OverloadedError is returned by a saturated Queue with an estimate of
+// when a slot may be free
+type OverloadedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *OverloadedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrOverloaded, e.RetryAfter)
+}
+
+func (e *OverloadedError) Is(target error) bool {
+	return target == ErrOverloaded
+}
+
+// Bounds of the Retry-After estimate
+const (
+	minRetryAfter = time.Second
+	maxRetryAfter = time.Minute
+)
+
+// QueueConfig bounds concurrent generations
+type QueueConfig struct {
+	// Concurrency is how many generations run at once; zero is unlimited
+	Concurrency int
+
+	// Depth is how many more may wait for a slot before further ones are
+	// rejected
+	Depth int
+
+	// Timeout bounds the wait for a slot (0 waits as long as the request)
+	Timeout time.Duration
+}
+
+// QueueStats is a snapshot of the queue, for the admin metrics
+type QueueStats struct {
+	Concurrency int `json:"concurrency"`
+	Depth       int `json:"depth"`
+	Running     int `json:"running"`
+	Waiting     int `json:"waiting"`
+	PeakWaiting int `json:"peak_waiting"`
+
+	Admitted int64 `json:"admitted"`
+	Rejected int64 `json:"rejected"`
+	TimedOut int64 `json:"timed_out"`
+
+	// AvgWaitMs and AvgRunMs are moving averages over recent generations
+	AvgWaitMs int64 `json:"avg_wait_ms"`
+	AvgRunMs  int64 `json:"avg_run_ms"`
+}
+
+// averageWeight is the weight of the newest sample in the moving averages
+const averageWeight = 0.2
+
+// Queue runs a bounded number of generations at once, so a burst of
+// requests can't overwhelm the providers, and turns requests away with
+// ErrOverloaded once too many are waiting. A nil Queue is unlimited.
+type Queue struct {
+	config QueueConfig
+	slots  chan struct{}
+
+	mu          sync.Mutex
+	waiting     int
+	peakWaiting int
+	admitted    int64
+	rejected    int64
+	timedOut    int64
+	avgWait     time.Duration
+	avgRun      time.Duration
+}
+
+// NewQueue creates a queue; it is unlimited when concurrency is zero
+func NewQueue(config QueueConfig) *Queue {
+	q := &Queue{config: config}
+	if config.Concurrency > 0 {
+		q.slots = make(chan struct{}, config.Concurrency)
+	}
+	return q
+}
+
+// queueSlotKey marks a context that already holds a slot of a queue
+type queueSlotKey struct{}
+
+// Acquire waits for a slot and returns a context holding it and the
+// function releasing it. A context that already holds a slot of q is
+// returned as is, so a handler can reserve the slot up front, before
+// committing to a response, and the generation it makes doesn't queue
+// again.
+func (q *Queue) Acquire(ctx context.Context) (context.Context, func(), error) {
+	if q == nil || q.slots == nil || ctx.Value(queueSlotKey{}) == q {
+		return ctx, func() {}, nil
+	}
+
+	start := time.Now()
+	if err := q.wait(ctx); err != nil {
+		return ctx, func() {}, err
+	}
+
+	admitted := time.Now()
+	q.mu.Lock()
+	q.admitted++
+	q.avgWait = average(q.avgWait, admitted.Sub(start))
+	q.mu.Unlock()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			<-q.slots
+			q.mu.Lock()
+			q.avgRun = average(q.avgRun, time.Since(admitted))
+			q.mu.Unlock()
+		})
+	}
+	return context.WithValue(ctx, queueSlotKey{}, q), release, nil
+}
+
+func (q *Queue) wait(ctx context.Context) error {
+	select {
+	case q.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	q.mu.Lock()
+	if q.waiting >= q.config.Depth {
+		q.rejected++
+		err := q.overloaded()
+		q.mu.Unlock()
+		return err
+	}
+	q.waiting++
+	q.peakWaiting = max(q.peakWaiting, q.waiting)
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		q.waiting--
+		q.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if q.config.Timeout > 0 {
+		timer := time.NewTimer(q.config.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case q.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.timedOut++
+		return q.overloaded()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// overloaded estimates when a slot frees up from how long generations take
+// and how many are waiting. Must be called with q.mu held.
+func (q *Queue) overloaded() *OverloadedError {
+	retryAfter := q.avgRun * time.Duration(q.waiting+1) / time.Duration(q.config.Concurrency)
+	return &OverloadedError{RetryAfter: min(max(retryAfter, minRetryAfter), maxRetryAfter)}
+}
+
+func average(avg, sample time.Duration) time.Duration {
+	if avg == 0 {
+		return sample
+	}
+	return avg + time.Duration(averageWeight*float64(sample-avg))
+}
+
+// Stats returns the current state of the queue
+func (q *Queue) Stats() QueueStats {
+	if q == nil {
+		return QueueStats{}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return QueueStats{
+		Concurrency: q.config.Concurrency,
+		Depth:       q.config.Depth,
+		Running:     len(q.slots),
+		Waiting:     q.waiting,
+		PeakWaiting: q.peakWaiting,
+		Admitted:    q.admitted,
+		Rejected:    q.rejected,
+		TimedOut:    q.timedOut,
+		AvgWaitMs:   q.avgWait.Milliseconds(),
+		AvgRunMs:    q.avgRun.Milliseconds(),
+	}
+}
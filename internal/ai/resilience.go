@@ -0,0 +1,155 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+)
+
+// NamedModel is a chat model tagged with the provider that created it
+type NamedModel struct {
+	Name  string
+	Model model.ToolCallingChatModel
+
+	// ModelName is the model used when a request doesn't pick one
+	ModelName string
+	Vision    bool
+
+	// Endpoint is the base URL of the provider's API, used by health checks
+	Endpoint string
+
+	// OwnKey is set for models running on an organization's own API key
+	OwnKey bool
+}
+
+// RetryPolicy controls how failed generations are retried
+type RetryPolicy struct {
+	// MaxRetries is the number of retries per provider after the first attempt
+	MaxRetries int
+
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the exponential backoff delay
+	MaxBackoff time.Duration
+
+	// Timeout bounds a single generation attempt (0 disables it)
+	Timeout time.Duration
+
+	// Failover moves on to the next provider once retries are exhausted
+	Failover bool
+}
+
+// DefaultRetryPolicy returns the default retry policy
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries:     2,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     8 * time.Second,
+		Timeout:        2 * time.Minute,
+		Failover:       true,
+	}
+}
+
+// backoff returns the jittered delay before the given retry (1-based)
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.InitialBackoff << (retry - 1)
+	if delay <= 0 || delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	// Full jitter keeps replicas from retrying in lockstep
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// statusCodePattern matches the status code in errors returned by the
+// OpenAI-compatible clients, e.g. "error, status code: 429, status: ..."
+var statusCodePattern = regexp.MustCompile(`status code: (\d{3})`)
+
+// IsRetryable reports whether err is a transient provider failure: rate
+// limiting, server errors, timeouts or network errors
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	if match := statusCodePattern.FindStringSubmatch(err.Error()); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		return code == 429 || code >= 500
+	}
+
+	return false
+}
+
+// Error classes reported by ErrorClass
+const (
+	ErrorClassCanceled    = "canceled"
+	ErrorClassTimeout     = "timeout"
+	ErrorClassRateLimited = "rate_limited"
+	ErrorClassAuth        = "auth"
+	ErrorClassClient      = "client_error"
+	ErrorClassServer      = "server_error"
+	ErrorClassNetwork     = "network"
+	ErrorClassOther       = "other"
+)
+
+// ErrorClass buckets a provider error for metrics; it is empty for nil
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	}
+
+	if match := statusCodePattern.FindStringSubmatch(err.Error()); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		switch {
+		case code == 429:
+			return ErrorClassRateLimited
+		case code == 401 || code == 403:
+			return ErrorClassAuth
+		case code >= 500:
+			return ErrorClassServer
+		default:
+			return ErrorClassClient
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	}
+	return ErrorClassOther
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
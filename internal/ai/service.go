@@ -2,94 +2,785 @@ package ai
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 	"github.com/shivaluma/eino-agent/internal/ai/templates"
+	"github.com/shivaluma/eino-agent/internal/logger"
 )
 
 type service struct {
-	model     model.ToolCallingChatModel
+	models    []NamedModel
 	templates *templates.Manager
 	config    *Config
+
+	// defaultModel starts as config.DefaultModel and changes on reload
+	defaultModel atomic.Pointer[string]
+
+	// historyBuilders are keyed by context strategy
+	historyBuilders map[string]HistoryBuilder
 }
 
-// NewService creates a new AI service
-func NewService(model model.ToolCallingChatModel, config *Config) Service {
-	return &service{
-		model:     model,
+// NewService creates a new AI service. Models are tried in order: when one
+// keeps failing with transient errors the next one takes over.
+func NewService(models []NamedModel, config *Config) Service {
+	if config == nil {
+		config = &Config{}
+	}
+	if config.Retry == nil {
+		config.Retry = DefaultRetryPolicy()
+	}
+	if config.MaxToolRounds <= 0 {
+		config.MaxToolRounds = DefaultMaxToolRounds
+	}
+	if config.ContextWindow <= 0 {
+		config.ContextWindow = DefaultContextWindow
+	}
+
+	s := &service{
+		models:    models,
 		templates: templates.NewManager(),
 		config:    config,
 	}
+	s.historyBuilders = map[string]HistoryBuilder{
+		ContextSlidingWindow: SlidingWindow(config.ContextWindow),
+		ContextSummaryWindow: newSummaryWindow(config.ContextWindow, s.summarizeHistory),
+		ContextFullHistory:   FullHistory(),
+	}
+	for strategy, builder := range config.HistoryBuilders {
+		s.historyBuilders[strategy] = builder
+	}
+	s.SetDefaultModel(config.DefaultModel)
+	return s
 }
 
-func (s *service) Generate(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
-	// Build messages with template
-	messages, err := s.templates.BuildFoodRecommendMessages(req.Message, req.History)
+// SetDefaultModel changes the model used by the default provider when a
+// request doesn't pick one; empty falls back to the provider's model
+func (s *service) SetDefaultModel(model string) {
+	s.defaultModel.Store(&model)
+}
+
+// ErrVisionUnsupported is returned when a request has image attachments but
+// no configured provider accepts images
+var ErrVisionUnsupported = errors.New("no available AI provider supports image attachments")
+
+// permanentError stops retries and failover for errors that must not be
+// repeated, e.g. after part of a stream has already reached the client
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// execute runs attempt against each model in turn, retrying transient
+// failures with exponential backoff before failing over. Models whose
+// circuit is open are skipped.
+func (s *service) execute(ctx context.Context, operation string, models []NamedModel, attempt func(ctx context.Context, m NamedModel) error) error {
+	if len(models) == 0 {
+		return fmt.Errorf("no AI providers configured")
+	}
+
+	policy := s.config.Retry
+	log := logger.ModuleContext(ctx, "ai")
+
+	var lastErr error
+	var previous string
+	for _, named := range models {
+		breakers := s.breakers(named)
+		if !breakers.allow(named.Name) {
+			s.config.Metrics.shortCircuit(named.Name)
+			log.Warn().
+				Str("operation", operation).
+				Str("provider", named.Name).
+				Msg("Skipping AI provider with open circuit")
+			if !policy.Failover {
+				break
+			}
+			continue
+		}
+
+		if previous != "" {
+			if !policy.Failover {
+				break
+			}
+			s.config.Metrics.fallback(previous)
+			log.Warn().
+				Err(lastErr).
+				Str("operation", operation).
+				Str("from_provider", previous).
+				Str("to_provider", named.Name).
+				Msg("Failing over to next AI provider")
+		}
+		previous = named.Name
+
+		for try := 0; try <= policy.MaxRetries; try++ {
+			if try > 0 {
+				if !breakers.allow(named.Name) {
+					s.config.Metrics.shortCircuit(named.Name)
+					break
+				}
+
+				delay := policy.backoff(try)
+				s.config.Metrics.retry(named.Name)
+				log.Warn().
+					Err(lastErr).
+					Str("operation", operation).
+					Str("provider", named.Name).
+					Int("retry", try).
+					Dur("backoff", delay).
+					Msg("Retrying AI request")
+
+				if err := sleep(ctx, delay); err != nil {
+					breakers.release(named.Name)
+					return err
+				}
+			}
+
+			s.config.Metrics.request(named.Name)
+			err := s.attempt(ctx, operation, named, attempt)
+			if err == nil {
+				s.config.Metrics.success(named.Name)
+				breakers.success(named.Name)
+				return nil
+			}
+			s.config.Metrics.failure(named.Name)
+
+			var permanent *permanentError
+			if errors.As(err, &permanent) {
+				breakers.release(named.Name)
+				return permanent.err
+			}
+
+			lastErr = err
+			if ctx.Err() != nil {
+				breakers.release(named.Name)
+				return err
+			}
+			if !IsRetryable(err) {
+				breakers.release(named.Name)
+				break
+			}
+			breakers.failure(named.Name)
+		}
+	}
+
+	if lastErr == nil {
+		return ErrProvidersUnavailable
+	}
+	return lastErr
+}
+
+// breakers returns the circuit breakers guarding m. Models on an
+// organization's own key have none, so one organization's rate limits
+// don't shut everyone out of a provider.
+func (s *service) breakers(m NamedModel) *Breakers {
+	if m.OwnKey {
+		return nil
+	}
+	return s.config.Breakers
+}
+
+// attempt runs a single call bounded by the policy timeout, timing it in
+// a span
+func (s *service) attempt(ctx context.Context, operation string, m NamedModel, attempt func(ctx context.Context, m NamedModel) error) error {
+	if s.config.Retry.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Retry.Timeout)
+		defer cancel()
+	}
+
+	ctx, sp := startSpan(ctx, operation, m)
+	err := attempt(ctx, m)
+	s.endSpan(ctx, sp, err)
+	return err
+}
+
+// resolveModels returns the models serving generations in ctx: the
+// resolver's when it has any, the service's otherwise
+func (s *service) resolveModels(ctx context.Context) ([]NamedModel, error) {
+	if s.config.Resolver == nil {
+		return s.models, nil
+	}
+	models, err := s.config.Resolver.ModelsFor(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build messages: %w", err)
+		return nil, fmt.Errorf("failed to resolve AI providers: %w", err)
 	}
+	if len(models) == 0 {
+		return s.models, nil
+	}
+	return models, nil
+}
 
-	// Generate response
-	response, err := s.model.Generate(ctx, messages)
+// modelsFor returns the models able to serve req, in failover order
+func (s *service) modelsFor(ctx context.Context, req *ChatRequest) ([]NamedModel, error) {
+	resolved, err := s.resolveModels(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate response: %w", err)
+		return nil, err
+	}
+	if !req.hasImages() {
+		return resolved, nil
 	}
 
-	return &ChatResponse{
-		Content:        response.Content,
-		ConversationID: req.ConversationID,
-	}, nil
+	var models []NamedModel
+	for _, m := range resolved {
+		if m.Vision {
+			models = append(models, m)
+		}
+	}
+	if len(models) == 0 {
+		return nil, ErrVisionUnsupported
+	}
+	return models, nil
 }
 
-func (s *service) Stream(ctx context.Context, req *ChatRequest, callback StreamCallback) (*ChatResponse, error) {
-	// Build messages with template
-	messages, err := s.templates.BuildFoodRecommendMessages(req.Message, req.History)
+// buildMessages builds the prompt for req. A message with images is sent
+// as the parts of the final user message: its parts in order, then the
+// attachments.
+func (s *service) buildMessages(ctx context.Context, req *ChatRequest) ([]*schema.Message, error) {
+	req.report(ctx, Status{Stage: StageRetrievingContext})
+
+	history, err := s.history(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build history: %w", err)
+	}
+
+	messages, err := s.templates.BuildConversationMessages(req.SystemPrompt, req.Persona, req.Language, req.Message, history)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build messages: %w", err)
 	}
+	templates.AppendMemories(messages, req.Language, req.Memories)
+
+	if req.hasImages() {
+		last := messages[len(messages)-1]
+		var parts []schema.ChatMessagePart
+		if len(req.Parts) == 0 {
+			parts = append(parts, textPart(last.Content))
+		}
+		for _, part := range req.Parts {
+			if part.Image != nil {
+				parts = append(parts, imagePart(part.Image))
+			} else {
+				parts = append(parts, textPart(part.Text))
+			}
+		}
+		for i := range req.Attachments {
+			parts = append(parts, imagePart(&req.Attachments[i]))
+		}
+		last.MultiContent = parts
+	}
+
+	return messages, nil
+}
+
+func textPart(text string) schema.ChatMessagePart {
+	return schema.ChatMessagePart{Type: schema.ChatMessagePartTypeText, Text: text}
+}
+
+// imagePart inlines an image as a data URL
+func imagePart(attachment *Attachment) schema.ChatMessagePart {
+	return schema.ChatMessagePart{
+		Type: schema.ChatMessagePartTypeImageURL,
+		ImageURL: &schema.ChatMessageImageURL{
+			URL:      "data:" + attachment.ContentType + ";base64," + base64.StdEncoding.EncodeToString(attachment.Data),
+			MIMEType: attachment.ContentType,
+			Detail:   schema.ImageURLDetailAuto,
+		},
+	}
+}
+
+// modelName returns the model that serves req on m. A requested model name
+// only applies to the default provider since fallback providers don't share
+// model names. The model an organization picked for its own key beats the
+// default model.
+func (s *service) modelName(m NamedModel, req *ChatRequest) string {
+	if m.Name == s.config.DefaultProvider {
+		if req.Model != "" {
+			return req.Model
+		}
+		if model := *s.defaultModel.Load(); model != "" && !m.OwnKey {
+			return model
+		}
+	}
+	return m.ModelName
+}
+
+// modelOptions returns per-request model options, falling back to the
+// service defaults
+func (s *service) modelOptions(m NamedModel, req *ChatRequest) []model.Option {
+	var opts []model.Option
+
+	if req.Temperature != nil {
+		opts = append(opts, model.WithTemperature(float32(*req.Temperature)))
+	} else if s.config.Temperature > 0 {
+		opts = append(opts, model.WithTemperature(float32(s.config.Temperature)))
+	}
+
+	if req.TopP != nil {
+		opts = append(opts, model.WithTopP(float32(*req.TopP)))
+	} else if s.config.TopP > 0 {
+		opts = append(opts, model.WithTopP(float32(s.config.TopP)))
+	}
+
+	if req.MaxTokens != nil {
+		opts = append(opts, model.WithMaxTokens(*req.MaxTokens))
+	} else if s.config.MaxTokens > 0 {
+		opts = append(opts, model.WithMaxTokens(s.config.MaxTokens))
+	}
+
+	if req.Stop != nil {
+		opts = append(opts, model.WithStop(req.Stop))
+	} else if len(s.config.Stop) > 0 {
+		opts = append(opts, model.WithStop(s.config.Stop))
+	}
+
+	if name := s.modelName(m, req); name != "" && name != m.ModelName {
+		opts = append(opts, model.WithModel(name))
+	}
+
+	return opts
+}
+
+func (s *service) Generate(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	start := time.Now()
+	req.report(ctx, Status{Stage: StageQueued})
+	ctx, release, err := s.config.Queue.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if req.ResponseFormat.IsStructured() {
+		return s.generateStructured(ctx, req, start)
+	}
+
+	models, err := s.modelsFor(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 
-	// Start streaming
-	streamReader, err := s.model.Stream(ctx, messages)
+	messages, err := s.buildMessages(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start stream: %w", err)
+		return nil, err
 	}
 
-	var fullContent string
-	for {
-		chunk, err := streamReader.Recv()
+	// Generate the response, running the tools it calls until it answers
+	var usage *Usage
+	var selected string
+	for round := 0; ; round++ {
+		toolOpts := s.toolOptions(round)
+		var response *schema.Message
+		var used NamedModel
+		err = s.execute(ctx, "generate", models, func(ctx context.Context, m NamedModel) error {
+			s.generating(ctx, req, m, &selected)
+			result, err := m.Model.Generate(ctx, messages, append(s.modelOptions(m, req), toolOpts...)...)
+			response, used = result, m
+			return err
+		})
 		if err != nil {
-			if err == schema.ErrRecvAfterClosed {
-				break
+			return nil, fmt.Errorf("failed to generate response: %w", err)
+		}
+		usage = usage.add(usageFrom(response))
+
+		// Tool calls are only followed while tools are offered
+		if len(response.ToolCalls) == 0 || toolOpts == nil {
+			stopper := s.newStopper(req)
+			content := stopper.Write(response.Content)
+			content += stopper.Flush()
+			finishReason := finishReasonFrom(response)
+			if stopper.stopped() {
+				finishReason = stopper.reason
 			}
-			return nil, fmt.Errorf("stream error: %w", err)
+
+			return s.price(&ChatResponse{
+				Content:        newPostPipeline(templates.PostProcessorsFor(req.Persona)).Process(content),
+				ConversationID: req.ConversationID,
+				Provider:       used.Name,
+				Model:          s.modelName(used, req),
+				Usage:          usage,
+				FinishReason:   finishReason,
+				Latency:        time.Since(start),
+				OwnKey:         used.OwnKey,
+			}), nil
 		}
 
-		if chunk != nil && chunk.Content != "" {
-			fullContent += chunk.Content
-			if err := callback(chunk.Content); err != nil {
-				return nil, fmt.Errorf("callback error: %w", err)
+		messages = s.runTools(ctx, req, messages, response)
+	}
+}
+
+// toolOptions offers the registered tools to the model, except in the last
+// round, which has to produce an answer
+func (s *service) toolOptions(round int) []model.Option {
+	infos := s.config.Tools.Infos()
+	if len(infos) == 0 || round >= s.config.MaxToolRounds {
+		return nil
+	}
+	return []model.Option{model.WithTools(infos)}
+}
+
+// generating reports that an attempt on m starts, announcing the model
+// first when it differs from the one of the previous attempt
+func (s *service) generating(ctx context.Context, req *ChatRequest, m NamedModel, previous *string) {
+	status := Status{Provider: m.Name, Model: s.modelName(m, req)}
+	if sp := spanFrom(ctx); sp != nil {
+		sp.model = status.Model
+	}
+	if *previous != m.Name {
+		*previous = m.Name
+		status.Stage = StageModelSelected
+		req.report(ctx, status)
+	}
+	status.Stage = StageGenerating
+	req.report(ctx, status)
+}
+
+// runTools appends the model's tool calls and their results to messages
+func (s *service) runTools(ctx context.Context, req *ChatRequest, messages []*schema.Message, response *schema.Message) []*schema.Message {
+	messages = append(messages, response)
+	for _, call := range response.ToolCalls {
+		req.report(ctx, Status{Stage: StageToolRunning, Tool: call.Function.Name})
+		result := s.config.Tools.run(ctx, call)
+		if s.config.ContextFilter != nil {
+			content, err := s.config.ContextFilter(ctx, call.Function.Name, result.Content)
+			if err != nil {
+				logger.ModuleContext(ctx, "ai").Warn().Err(err).Str("tool", call.Function.Name).Msg("Tool result rejected")
+				content = "Error: " + err.Error()
 			}
+			result.Content = content
 		}
+		messages = append(messages, result)
 	}
+	return messages
+}
+
+// generateStructured asks for JSON output and validates it, feeding
+// validation errors back to the model until it produces valid output.
+// start is when the request was received.
+func (s *service) generateStructured(ctx context.Context, req *ChatRequest, start time.Time) (*ChatResponse, error) {
+	outputSchema, err := req.ResponseFormat.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	models, err := s.modelsFor(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.buildMessages(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	messages = append(messages, schema.SystemMessage(req.ResponseFormat.instruction()))
+
+	var lastErr error
+	var usage *Usage
+	var selected string
+	for attempt := 1; attempt <= structuredOutputAttempts; attempt++ {
+		var response *schema.Message
+		var used NamedModel
+		err := s.execute(ctx, "structured", models, func(ctx context.Context, m NamedModel) error {
+			s.generating(ctx, req, m, &selected)
+			result, err := m.Model.Generate(ctx, messages, s.modelOptions(m, req)...)
+			response, used = result, m
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate response: %w", err)
+		}
+		// Failed attempts still cost tokens
+		usage = usage.add(usageFrom(response))
+
+		structured, err := parseStructured(response.Content, outputSchema)
+		if err == nil {
+			return s.price(&ChatResponse{
+				Content:        string(structured),
+				ConversationID: req.ConversationID,
+				Structured:     structured,
+				Provider:       used.Name,
+				Model:          s.modelName(used, req),
+				Usage:          usage,
+				FinishReason:   finishReasonFrom(response),
+				Latency:        time.Since(start),
+				OwnKey:         used.OwnKey,
+			}), nil
+		}
+
+		lastErr = err
+		logger.ModuleContext(ctx, "ai").Warn().
+			Err(err).
+			Int("attempt", attempt).
+			Msg("Model returned invalid structured output")
+
+		messages = append(messages,
+			schema.AssistantMessage(response.Content, nil),
+			schema.UserMessage(fmt.Sprintf("Your previous answer was invalid: %v. Reply again with only the corrected JSON.", err)),
+		)
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrInvalidStructuredOutput, lastErr)
+}
+
+func (s *service) Stream(ctx context.Context, req *ChatRequest, callback StreamCallback) (*ChatResponse, error) {
+	start := time.Now()
+	req.report(ctx, Status{Stage: StageQueued})
+	ctx, release, err := s.config.Queue.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Partial JSON is useless to clients, so structured output is generated
+	// in full, validated and then delivered as a single chunk
+	if req.ResponseFormat.IsStructured() {
+		response, err := s.generateStructured(ctx, req, start)
+		if err != nil {
+			return nil, err
+		}
+		if err := callback(response.Content); err != nil {
+			return nil, fmt.Errorf("callback error: %w", err)
+		}
+		return response, nil
+	}
+
+	models, err := s.modelsFor(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.buildMessages(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The post-processors see the chunks of every round as one response,
+	// cut by the stop conditions first
+	pipeline := newPostPipeline(templates.PostProcessorsFor(req.Persona))
+	stopper := s.newStopper(req)
+
+	var fullContent, finishReason string
+	var usage *Usage
+	var used NamedModel
+	var selected string
+	for round := 0; ; round++ {
+		toolOpts := s.toolOptions(round)
+
+		// Tool calls arrive in pieces and are merged once the round ends
+		var chunks []*schema.Message
+		var roundUsage *Usage
+		err = s.execute(ctx, "stream", models, func(ctx context.Context, m NamedModel) error {
+			used = m
+			chunks, roundUsage = nil, nil
+			s.generating(ctx, req, m, &selected)
+			delivered := false
+
+			// Returning once a stop condition is met cancels the model call
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
 
-	return &ChatResponse{
+			// Start streaming
+			streamReader, err := m.Model.Stream(ctx, messages, append(s.modelOptions(m, req), toolOpts...)...)
+			if err != nil {
+				return fmt.Errorf("failed to start stream: %w", err)
+			}
+			defer streamReader.Close()
+
+			for {
+				chunk, err := streamReader.Recv()
+				if err != nil {
+					if errors.Is(err, io.EOF) || err == schema.ErrRecvAfterClosed {
+						return nil
+					}
+					err = fmt.Errorf("stream error: %w", err)
+					// Chunks already delivered can't be taken back, so a retry
+					// would duplicate content
+					if delivered {
+						return &permanentError{err: err}
+					}
+					return err
+				}
+				if chunk == nil {
+					continue
+				}
+				spanFrom(ctx).chunk()
+				chunks = append(chunks, chunk)
+
+				// Providers report usage and the finish reason on the final chunk
+				if chunkUsage := usageFrom(chunk); chunkUsage != nil {
+					roundUsage = chunkUsage
+				}
+				if reason := finishReasonFrom(chunk); reason != "" {
+					finishReason = reason
+				}
+
+				// Once a chunk is in the pipeline it counts as delivered
+				if chunk.Content != "" {
+					delivered = true
+					if out := pipeline.Write(stopper.Write(chunk.Content)); out != "" {
+						fullContent += out
+						if err := callback(out); err != nil {
+							return &permanentError{err: fmt.Errorf("callback error: %w", err)}
+						}
+					}
+					if stopper.stopped() {
+						return nil
+					}
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Returning at a stop condition cancels the call before the provider
+		// reports usage, which is then estimated
+		if roundUsage == nil && stopper.stopped() {
+			roundUsage = estimateUsage(messages, chunks)
+		}
+		usage = usage.add(roundUsage)
+
+		// A stopped response is over, whatever tools it was about to call
+		if stopper.stopped() || len(chunks) == 0 || toolOpts == nil {
+			break
+		}
+		response, err := schema.ConcatMessages(chunks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge stream: %w", err)
+		}
+		if len(response.ToolCalls) == 0 {
+			break
+		}
+		messages = s.runTools(ctx, req, messages, response)
+	}
+
+	if rest := pipeline.Write(stopper.Flush()) + pipeline.Flush(); rest != "" {
+		fullContent += rest
+		if err := callback(rest); err != nil {
+			return nil, fmt.Errorf("callback error: %w", err)
+		}
+	}
+	if stopper.stopped() {
+		finishReason = stopper.reason
+	}
+
+	return s.price(&ChatResponse{
 		Content:        fullContent,
 		ConversationID: req.ConversationID,
-	}, nil
+		Provider:       used.Name,
+		Model:          s.modelName(used, req),
+		Usage:          usage,
+		FinishReason:   finishReason,
+		Latency:        time.Since(start),
+		OwnKey:         used.OwnKey,
+	}), nil
 }
 
-func (s *service) GenerateTitle(ctx context.Context, firstMessage string) (string, error) {
-	messages, err := s.templates.BuildTitleMessages(firstMessage)
+// price sets the cost of a response from the pricing table
+func (s *service) price(response *ChatResponse) *ChatResponse {
+	response.CostMicros, response.Priced = s.config.Pricing.Cost(response.Provider, response.Model, response.Usage)
+	return response
+}
+
+func (s *service) GenerateTitle(ctx context.Context, firstMessage, language string, history []*schema.Message) (string, error) {
+	messages, err := s.templates.BuildTitleMessages(language, firstMessage, history)
 	if err != nil {
 		return "", fmt.Errorf("failed to build title messages: %w", err)
 	}
 
-	response, err := s.model.Generate(ctx, messages)
+	models, err := s.resolveModels(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var response *schema.Message
+	err = s.execute(ctx, "title", models, func(ctx context.Context, m NamedModel) error {
+		result, err := m.Model.Generate(ctx, messages)
+		response = result
+		return err
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to generate title: %w", err)
 	}
 
 	return response.Content, nil
 }
+
+// RoutePersona asks the model which persona should answer a conversation's
+// first message. A reply that doesn't name an active persona falls back to
+// the default persona.
+func (s *service) RoutePersona(ctx context.Context, message string) (string, error) {
+	messages := templates.BuildRouteMessages(message)
+
+	models, err := s.resolveModels(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var response *schema.Message
+	err = s.execute(ctx, "route", models, func(ctx context.Context, m NamedModel) error {
+		result, err := m.Model.Generate(ctx, messages)
+		response = result
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to route persona: %w", err)
+	}
+
+	persona, ok := templates.ParseRouteReply(response.Content)
+	if !ok {
+		logger.ModuleContext(ctx, "ai").Debug().Str("reply", response.Content).Msg("Router reply names no persona, using default")
+		return templates.DefaultPersona, nil
+	}
+	return persona, nil
+}
+
+// ExtractMemories asks the model for lasting facts about the user stated in
+// their message
+func (s *service) ExtractMemories(ctx context.Context, message string, known []string) ([]string, error) {
+	messages := templates.BuildMemoryExtractionMessages(message, known)
+
+	models, err := s.resolveModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var response *schema.Message
+	err = s.execute(ctx, "memory", models, func(ctx context.Context, m NamedModel) error {
+		result, err := m.Model.Generate(ctx, messages)
+		response = result
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract memories: %w", err)
+	}
+
+	return templates.ParseMemoryReply(response.Content)
+}
+
+// DetectInjection asks the model whether text is a prompt injection or
+// jailbreak attempt
+func (s *service) DetectInjection(ctx context.Context, text string) (bool, error) {
+	messages := templates.BuildInjectionCheckMessages(text)
+
+	models, err := s.resolveModels(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var response *schema.Message
+	err = s.execute(ctx, "guardrail", models, func(ctx context.Context, m NamedModel) error {
+		result, err := m.Model.Generate(ctx, messages)
+		response = result
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to classify text: %w", err)
+	}
+
+	return templates.ParseInjectionReply(response.Content), nil
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+// span times one attempt on a model. When it ends its latencies go to the
+// metrics and a record carrying its trace and span IDs is logged, which
+// the OTLP sink exports for the trace.
+type span struct {
+	id        string
+	parent    string
+	operation string
+	provider  string
+	model     string
+	ownKey    bool
+	start     time.Time
+
+	// firstChunk is the time to the first stream chunk, zero until one
+	// arrives
+	firstChunk time.Duration
+	chunks     int
+}
+
+type spanKey struct{}
+
+// startSpan starts the span of an attempt on m as a child of the span in
+// ctx, starting a trace if there is none
+func startSpan(ctx context.Context, operation string, m NamedModel) (context.Context, *span) {
+	if logger.GetTraceID(ctx) == "" {
+		ctx = logger.WithTraceID(ctx, logger.GenerateTraceID())
+	}
+
+	sp := &span{
+		id:        logger.GenerateSpanID(),
+		parent:    logger.GetSpanID(ctx),
+		operation: operation,
+		provider:  m.Name,
+		model:     m.ModelName,
+		ownKey:    m.OwnKey,
+		start:     time.Now(),
+	}
+	ctx = logger.WithSpanID(ctx, sp.id)
+	return context.WithValue(ctx, spanKey{}, sp), sp
+}
+
+// spanFrom returns the span of the attempt running in ctx, or nil
+func spanFrom(ctx context.Context) *span {
+	sp, _ := ctx.Value(spanKey{}).(*span)
+	return sp
+}
+
+// chunk counts a stream chunk
+func (sp *span) chunk() {
+	if sp == nil {
+		return
+	}
+	if sp.chunks == 0 {
+		sp.firstChunk = time.Since(sp.start)
+	}
+	sp.chunks++
+}
+
+// endSpan records the latencies of sp, which ended with err
+func (s *service) endSpan(ctx context.Context, sp *span, err error) {
+	duration := time.Since(sp.start)
+	class := ErrorClass(err)
+	s.config.Metrics.observe(sp.provider, sp.model, sp.firstChunk, duration, sp.chunks, class)
+
+	event := logger.ModuleContext(ctx, "ai").Info().
+		Str("span", "ai."+sp.operation).
+		Str("provider", sp.provider).
+		Str("model", sp.model).
+		Bool("own_key", sp.ownKey).
+		Dur("duration", duration)
+	if sp.parent != "" {
+		event = event.Str("parent_span_id", sp.parent)
+	}
+	if sp.chunks > 0 {
+		event = event.Dur("time_to_first_token", sp.firstChunk).Int("chunks", sp.chunks)
+	}
+	if err != nil {
+		event = event.Err(err).Str("error_class", class)
+	}
+	event.Msg("AI provider call finished")
+}
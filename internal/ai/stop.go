@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// Finish reasons of a response the service ended itself
+const (
+	FinishReasonStop   = "stop"
+	FinishReasonLength = "length"
+)
+
+// charsPerToken is the rough number of characters in a token, used to
+// estimate usage the provider didn't report
+const charsPerToken = 4
+
+// stopper ends a response at the first stop sequence or once it reaches the
+// maximum output length, whether or not the provider honoured them. Chunks
+// are written as they stream in; text that may be the start of a stop
+// sequence is held back until the next chunk tells.
+type stopper struct {
+	stop      []string
+	maxLength int
+
+	// written is how many characters were let through
+	written int
+	pending string
+
+	// reason is the finish reason once a condition was met
+	reason string
+}
+
+// newStopper enforces the stop sequences and output length of req, the
+// stop sequences defaulting to the service's like the model options do
+func (s *service) newStopper(req *ChatRequest) *stopper {
+	stop := req.Stop
+	if stop == nil {
+		stop = s.config.Stop
+	}
+	return &stopper{stop: stop, maxLength: req.MaxOutputLength}
+}
+
+// Write adds a chunk and returns the text to deliver, which may be empty.
+// Nothing more is let through once the response stopped.
+func (st *stopper) Write(chunk string) string {
+	if st.stopped() {
+		return ""
+	}
+	text := st.pending + chunk
+	st.pending = ""
+
+	cut := len(text)
+	for _, seq := range st.stop {
+		if i := strings.Index(text, seq); i >= 0 && i < cut {
+			cut = i
+			st.reason = FinishReasonStop
+		}
+	}
+	if !st.stopped() {
+		cut -= st.held(text)
+		st.pending = text[cut:]
+	}
+	return st.limit(text[:cut])
+}
+
+// Flush returns the text held back, at the end of the response
+func (st *stopper) Flush() string {
+	if st.stopped() {
+		return ""
+	}
+	text := st.pending
+	st.pending = ""
+	return st.limit(text)
+}
+
+// stopped reports whether a stop condition was met
+func (st *stopper) stopped() bool {
+	return st.reason != ""
+}
+
+// held returns the length of the longest end of text that starts a stop
+// sequence
+func (st *stopper) held(text string) int {
+	held := 0
+	for _, seq := range st.stop {
+		for n := min(len(seq)-1, len(text)); n > held; n-- {
+			if strings.HasSuffix(text, seq[:n]) {
+				held = n
+				break
+			}
+		}
+	}
+	return held
+}
+
+// limit cuts text at the maximum output length
+func (st *stopper) limit(text string) string {
+	if st.maxLength <= 0 {
+		return text
+	}
+	n := utf8.RuneCountInString(text)
+	if st.written+n <= st.maxLength {
+		st.written += n
+		return text
+	}
+
+	keep := st.maxLength - st.written
+	end := 0
+	for i := range text {
+		if keep == 0 {
+			end = i
+			break
+		}
+		keep--
+	}
+	st.written = st.maxLength
+	st.reason = FinishReasonLength
+	st.pending = ""
+	return text[:end]
+}
+
+// estimateUsage estimates the usage of a round cut short by a stop
+// condition, from the prompt and the chunks received
+func estimateUsage(prompt, chunks []*schema.Message) *Usage {
+	count := func(messages []*schema.Message) int {
+		chars := 0
+		for _, msg := range messages {
+			chars += utf8.RuneCountInString(msg.Content)
+		}
+		return (chars + charsPerToken - 1) / charsPerToken
+	}
+	usage := &Usage{PromptTokens: count(prompt), CompletionTokens: count(chunks)}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
@@ -0,0 +1,194 @@
+//go:build integration
+
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// scriptedModel streams its chunks, then holds the stream open until the
+// call is cancelled, closing cancelled when it is
+type scriptedModel struct {
+	chunks    []string
+	hold      bool
+	cancelled chan struct{}
+}
+
+func newScriptedModel(hold bool, chunks ...string) *scriptedModel {
+	return &scriptedModel{chunks: chunks, hold: hold, cancelled: make(chan struct{})}
+}
+
+func (m *scriptedModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return schema.AssistantMessage(strings.Join(m.chunks, ""), nil), nil
+}
+
+func (m *scriptedModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	reader, writer := schema.Pipe[*schema.Message](len(m.chunks))
+	go func() {
+		defer writer.Close()
+		for _, chunk := range m.chunks {
+			if closed := writer.Send(schema.AssistantMessage(chunk, nil), nil); closed {
+				return
+			}
+		}
+		if !m.hold {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			close(m.cancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}()
+	return reader, nil
+}
+
+func (m *scriptedModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func TestStopper_HoldsPartialSequence(t *testing.T) {
+	st := &stopper{stop: []string{"</end>"}}
+
+	// "</e" may start the stop sequence, so it waits for the next chunk
+	if got := st.Write("Hello </e"); got != "Hello " {
+		t.Fatalf("first chunk: got %q, want %q", got, "Hello ")
+	}
+	if got := st.Write("nd> and more"); got != "" {
+		t.Fatalf("second chunk: got %q, want nothing", got)
+	}
+	if st.reason != FinishReasonStop {
+		t.Fatalf("reason = %q, want %q", st.reason, FinishReasonStop)
+	}
+	if got := st.Write("after"); got != "" {
+		t.Fatalf("write after stop: got %q", got)
+	}
+	if got := st.Flush(); got != "" {
+		t.Fatalf("flush after stop: got %q", got)
+	}
+
+	// Held text that turns out not to be a stop sequence is let through
+	st = &stopper{stop: []string{"</end>"}}
+	if got := st.Write("a </"); got != "a " {
+		t.Fatalf("got %q, want %q", got, "a ")
+	}
+	if got := st.Write("b>"); got != "</b>" {
+		t.Fatalf("got %q, want %q", got, "</b>")
+	}
+	if got := st.Write("c </en"); got != "c " {
+		t.Fatalf("got %q, want %q", got, "c ")
+	}
+	if got := st.Flush(); got != "</en" {
+		t.Fatalf("flush: got %q, want %q", got, "</en")
+	}
+	if st.stopped() {
+		t.Fatalf("stopped with reason %q", st.reason)
+	}
+}
+
+func TestStopper_CutsAtRuneBoundary(t *testing.T) {
+	st := &stopper{maxLength: 4}
+
+	// The limit counts characters, not bytes, and never splits one
+	if got := st.Write("phở"); got != "phở" {
+		t.Fatalf("first chunk: got %q, want %q", got, "phở")
+	}
+	if got := st.Write("ngon"); got != "n" {
+		t.Fatalf("second chunk: got %q, want %q", got, "n")
+	}
+	if st.reason != FinishReasonLength {
+		t.Fatalf("reason = %q, want %q", st.reason, FinishReasonLength)
+	}
+
+	st = &stopper{maxLength: 2}
+	if got := st.Write("日本語"); got != "日本" {
+		t.Fatalf("got %q, want %q", got, "日本")
+	}
+}
+
+func TestStream_StopSequence(t *testing.T) {
+	m := newScriptedModel(true, "Pho is ", "a soup.</e", "nd> Ignored")
+	s := NewService([]NamedModel{{Name: "scripted", Model: m, ModelName: "scripted"}}, nil)
+
+	var got strings.Builder
+	response, err := s.Stream(context.Background(), &ChatRequest{
+		Message: "Describe pho",
+		Stop:    []string{"</end>"},
+	}, func(chunk string) error {
+		got.WriteString(chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+
+	if got.String() != "Pho is a soup." || response.Content != "Pho is a soup." {
+		t.Fatalf("streamed %q, content %q, want %q", got.String(), response.Content, "Pho is a soup.")
+	}
+	if response.FinishReason != FinishReasonStop {
+		t.Fatalf("finish reason = %q, want %q", response.FinishReason, FinishReasonStop)
+	}
+
+	// The model call is cancelled rather than left to run
+	select {
+	case <-m.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("model call was not cancelled")
+	}
+
+	// The usage the provider never sent is estimated
+	if response.Usage == nil || response.Usage.PromptTokens == 0 || response.Usage.CompletionTokens == 0 {
+		t.Fatalf("usage = %+v, want an estimate", response.Usage)
+	}
+}
+
+func TestStream_MaxOutputLength(t *testing.T) {
+	m := newScriptedModel(true, "Bún ", "chả ", "Hà Nội")
+	s := NewService([]NamedModel{{Name: "scripted", Model: m, ModelName: "scripted"}}, nil)
+
+	response, err := s.Stream(context.Background(), &ChatRequest{
+		Message:         "Name a dish",
+		MaxOutputLength: 6,
+	}, func(chunk string) error { return nil })
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+
+	if response.Content != "Bún ch" {
+		t.Fatalf("content = %q, want %q", response.Content, "Bún ch")
+	}
+	if response.FinishReason != FinishReasonLength {
+		t.Fatalf("finish reason = %q, want %q", response.FinishReason, FinishReasonLength)
+	}
+	select {
+	case <-m.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("model call was not cancelled")
+	}
+}
+
+func TestStream_NoStopCondition(t *testing.T) {
+	m := newScriptedModel(false, "Pho [do", "ne later")
+	s := NewService([]NamedModel{{Name: "scripted", Model: m, ModelName: "scripted"}}, nil)
+
+	response, err := s.Stream(context.Background(), &ChatRequest{
+		Message: "Describe pho",
+		Stop:    []string{"[done]"},
+	}, func(chunk string) error { return nil })
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+
+	if response.Content != "Pho [done later" {
+		t.Fatalf("content = %q, want %q", response.Content, "Pho [done later")
+	}
+	if response.FinishReason != "" {
+		t.Fatalf("finish reason = %q, want none", response.FinishReason)
+	}
+}
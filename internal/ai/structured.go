@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Response formats supported by structured output mode
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// structuredOutputAttempts is how many times the model may answer before
+// invalid structured output is reported as an error
+const structuredOutputAttempts = 3
+
+// ErrInvalidStructuredOutput is returned when the model keeps producing
+// output that doesn't match the requested format
+var ErrInvalidStructuredOutput = errors.New("model did not return valid structured output")
+
+// ResponseFormat asks the service for JSON output instead of free text
+type ResponseFormat struct {
+	// Type is one of ResponseFormatText, ResponseFormatJSONObject or
+	// ResponseFormatJSONSchema
+	Type string
+
+	// Name is an optional label for the schema, included in the prompt
+	Name string
+
+	// Schema is the JSON schema the output must satisfy (json_schema only)
+	Schema json.RawMessage
+}
+
+// IsStructured reports whether the format requires JSON output
+func (f *ResponseFormat) IsStructured() bool {
+	return f != nil && f.Type != "" && f.Type != ResponseFormatText
+}
+
+// Compile parses and checks the format's schema. It returns a nil schema
+// for formats that only require a JSON object.
+func (f *ResponseFormat) Compile() (*openapi3.Schema, error) {
+	switch f.Type {
+	case "", ResponseFormatText, ResponseFormatJSONObject:
+		return nil, nil
+	case ResponseFormatJSONSchema:
+	default:
+		return nil, fmt.Errorf("unsupported response format: %s", f.Type)
+	}
+
+	if len(f.Schema) == 0 {
+		return nil, fmt.Errorf("schema is required for %s response format", ResponseFormatJSONSchema)
+	}
+
+	schema := openapi3.NewSchema()
+	if err := json.Unmarshal(f.Schema, schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := schema.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	return schema, nil
+}
+
+// instruction tells the model how to format its answer
+func (f *ResponseFormat) instruction() string {
+	var b strings.Builder
+	b.WriteString("Respond only with a single valid JSON value. Do not wrap it in markdown code fences and do not add any text before or after it.")
+
+	if f.Type == ResponseFormatJSONSchema {
+		b.WriteString("\nThe JSON must conform to the following JSON schema")
+		if f.Name != "" {
+			fmt.Fprintf(&b, " (%s)", f.Name)
+		}
+		b.WriteString(":\n")
+		b.Write(f.Schema)
+	} else {
+		b.WriteString("\nThe JSON value must be an object.")
+	}
+
+	return b.String()
+}
+
+// parseStructured extracts the JSON value from content and validates it
+// against schema (nil for json_object)
+func parseStructured(content string, schema *openapi3.Schema) (json.RawMessage, error) {
+	content = stripCodeFence(strings.TrimSpace(content))
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return nil, fmt.Errorf("output is not valid JSON: %w", err)
+	}
+
+	if schema == nil {
+		if _, ok := value.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("output is not a JSON object")
+		}
+	} else if err := schema.VisitJSON(value, openapi3.MultiErrors()); err != nil {
+		return nil, fmt.Errorf("output does not match schema: %w", err)
+	}
+
+	return json.RawMessage(content), nil
+}
+
+// stripCodeFence removes a surrounding markdown code fence, which models
+// tend to add despite being told not to
+func stripCodeFence(content string) string {
+	if !strings.HasPrefix(content, "```") || !strings.HasSuffix(content, "```") {
+		return content
+	}
+
+	content = strings.TrimSuffix(strings.TrimPrefix(content, "```"), "```")
+	// Drop the language tag on the opening fence, e.g. ```json
+	if i := strings.IndexByte(content, '\n'); i >= 0 {
+		content = content[i+1:]
+	}
+	return strings.TrimSpace(content)
+}
@@ -0,0 +1,54 @@
+package templates
+
+import (
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// historySummaryHeaders introduce the summary of the earlier conversation,
+// keyed by language
+var historySummaryHeaders = map[string]string{
+	LanguageVietnamese: "Tóm tắt phần trước của cuộc trò chuyện:",
+	LanguageEnglish:    "Summary of the earlier conversation:",
+}
+
+// HistorySummaryMessage wraps a summary of the earlier conversation in a
+// system message placed before the recent messages
+func HistorySummaryMessage(language, summary string) *schema.Message {
+	header, ok := historySummaryHeaders[language]
+	if !ok {
+		header = historySummaryHeaders[DefaultLanguage]
+	}
+	return schema.SystemMessage(header + "\n" + summary)
+}
+
+// BuildHistorySummaryMessages builds messages asking the model to fold
+// messages into the summary of the conversation so far, which is empty for
+// the first summary. The transcript is passed as a separate user message
+// so it can't change the instructions.
+func BuildHistorySummaryMessages(language, previous string, messages []*schema.Message) []*schema.Message {
+	var b strings.Builder
+	b.WriteString("Summarize the conversation below for an assistant that will continue it. ")
+	b.WriteString("Keep the facts, decisions, preferences and open questions it needs; leave out greetings and small talk. ")
+	b.WriteString("Write at most 200 words in ")
+	if language == LanguageEnglish {
+		b.WriteString("English")
+	} else {
+		b.WriteString("Vietnamese")
+	}
+	b.WriteString(" and reply with the summary only.")
+
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("Summary so far:\n")
+		transcript.WriteString(previous)
+		transcript.WriteString("\n\nContinued conversation:\n")
+	}
+	transcript.WriteString(formatTranscript(messages))
+
+	return []*schema.Message{
+		schema.SystemMessage(b.String()),
+		schema.UserMessage(transcript.String()),
+	}
+}
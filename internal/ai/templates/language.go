@@ -0,0 +1,82 @@
+package templates
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Supported prompt languages (ISO 639-1 codes)
+const (
+	LanguageVietnamese = "vi"
+	LanguageEnglish    = "en"
+
+	// DefaultLanguage is used when neither the client nor the user asks
+	// for a specific language
+	DefaultLanguage = LanguageVietnamese
+)
+
+// languageDirectives are appended to custom system prompts so the model
+// answers in the selected language
+var languageDirectives = map[string]string{
+	LanguageVietnamese: "Luôn trả lời bằng tiếng Việt.",
+	LanguageEnglish:    "Always reply in English.",
+}
+
+// IsSupportedLanguage reports whether prompts exist for language
+func IsSupportedLanguage(language string) bool {
+	_, ok := languageDirectives[language]
+	return ok
+}
+
+// SupportedLanguages returns the supported language codes sorted
+// alphabetically
+func SupportedLanguages() []string {
+	languages := make([]string, 0, len(languageDirectives))
+	for language := range languageDirectives {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// NormalizeLanguage maps a language tag such as "en-US" to a supported
+// language code, or returns "" if it isn't supported
+func NormalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if IsSupportedLanguage(tag) {
+		return tag
+	}
+	return ""
+}
+
+// MatchAcceptLanguage returns the supported language with the highest
+// quality value in an Accept-Language header, or "" if none match
+func MatchAcceptLanguage(header string) string {
+	best := ""
+	bestQuality := 0.0
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+
+		language := NormalizeLanguage(tag)
+		if language != "" && quality > bestQuality {
+			best = language
+			bestQuality = quality
+		}
+	}
+
+	return best
+}
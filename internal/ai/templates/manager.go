@@ -3,6 +3,7 @@ package templates
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/cloudwego/eino/components/prompt"
 	"github.com/cloudwego/eino/schema"
@@ -11,7 +12,8 @@ import (
 // Manager manages AI message templates
 type Manager struct {
 	chatTemplate          prompt.ChatTemplate
-	titleTemplate         prompt.ChatTemplate
+	titleTemplates        map[string]prompt.ChatTemplate // keyed by language
+	titleHistoryTemplates map[string]prompt.ChatTemplate // keyed by language
 	foodRecommendTemplate prompt.ChatTemplate
 	config                *Config
 }
@@ -58,7 +60,8 @@ func NewFoodRecommendManager() *Manager {
 func NewManagerWithConfig(config *Config) *Manager {
 	return &Manager{
 		chatTemplate:          createChatTemplate(),
-		titleTemplate:         createTitleTemplate(),
+		titleTemplates:        createTitleTemplates(),
+		titleHistoryTemplates: createTitleHistoryTemplates(),
 		foodRecommendTemplate: createFoodRecommendTemplate(),
 		config:                config,
 	}
@@ -72,28 +75,60 @@ func createChatTemplate() prompt.ChatTemplate {
 	)
 }
 
-func createTitleTemplate() prompt.ChatTemplate {
-	return prompt.FromMessages(schema.FString,
-		schema.SystemMessage("Bạn giúp tôi đặt tên cho cuộc trò chuyện này dựa vào tin nhắn đầu tiên của người dùng nhé, tin nhắn là {message}, bạn chỉ cần đưa ra tên cho cuộc trò chuyện, không cần thêm từ ngữ gì khác, tên cuộc trò chuyện không được quá 20 ký tự"),
-	)
+func createTitleTemplates() map[string]prompt.ChatTemplate {
+	return map[string]prompt.ChatTemplate{
+		LanguageVietnamese: prompt.FromMessages(schema.FString,
+			schema.SystemMessage("Bạn giúp tôi đặt tên cho cuộc trò chuyện này dựa vào tin nhắn đầu tiên của người dùng nhé, tin nhắn là {message}, bạn chỉ cần đưa ra tên cho cuộc trò chuyện, không cần thêm từ ngữ gì khác, tên cuộc trò chuyện không được quá 20 ký tự"),
+		),
+		LanguageEnglish: prompt.FromMessages(schema.FString,
+			schema.SystemMessage("Give this conversation a name based on the user's first message, which is: {message}. Reply with the name only, without any other words. The name must not exceed 20 characters."),
+		),
+	}
 }
 
-func createFoodRecommendTemplate() prompt.ChatTemplate {
-	return prompt.FromMessages(schema.FString,
-		schema.SystemMessage(`Tính cách: Thân thiện, chuyên nghiệp, và có chút hài hước. Giao tiếp tự nhiên, gần gũi nhưng không quá "đời thường". Agent nên giống một người bạn sành ăn, luôn sẵn lòng gợi ý và tư vấn.
-
-Mục tiêu: Trả lời một cách linh hoạt, không chỉ giới hạn ở việc đề xuất món ăn mà còn mở rộng sang các tùy chọn khác như quán ăn, topping, hoặc món ăn kèm.
-
-Ngôn ngữ: Sử dụng ngôn từ trẻ trung, tích cực, ví dụ: "đỉnh của chóp", "chuẩn vị", "siêu ngon". Hạn chế sử dụng quá nhiều emoji để giữ sự chuyên nghiệp.
-
-Cấu trúc phản hồi:
-
-1. Phản ứng ban đầu: Xác nhận yêu cầu của người dùng một cách tích cực.
+// createTitleHistoryTemplates builds title prompts that summarize a whole
+// conversation instead of its first message
+func createTitleHistoryTemplates() map[string]prompt.ChatTemplate {
+	return map[string]prompt.ChatTemplate{
+		LanguageVietnamese: prompt.FromMessages(schema.FString,
+			schema.SystemMessage("Bạn giúp tôi đặt tên cho cuộc trò chuyện dưới đây dựa vào nội dung chính của nó nhé. Bạn chỉ cần đưa ra tên cho cuộc trò chuyện, không cần thêm từ ngữ gì khác, tên cuộc trò chuyện không được quá 20 ký tự.\n\n{transcript}"),
+		),
+		LanguageEnglish: prompt.FromMessages(schema.FString,
+			schema.SystemMessage("Give the conversation below a name that reflects its main topic. Reply with the name only, without any other words. The name must not exceed 20 characters.\n\n{transcript}"),
+		),
+	}
+}
 
-2. Gợi ý đa dạng: Đưa ra các tùy chọn không chỉ về món ăn mà còn về các khía cạnh liên quan, giúp người dùng có nhiều sự lựa chọn hơn.
+// titleTranscriptMessageLength caps each message in a title transcript so
+// long answers don't dominate the prompt
+const titleTranscriptMessageLength = 500
+
+// formatTranscript renders chat history as plain text for title prompts
+func formatTranscript(history []*schema.Message) string {
+	var b strings.Builder
+	for _, msg := range history {
+		content := []rune(strings.TrimSpace(msg.Content))
+		if len(content) > titleTranscriptMessageLength {
+			content = append(content[:titleTranscriptMessageLength], '…')
+		}
+
+		switch msg.Role {
+		case schema.User:
+			b.WriteString("User: ")
+		case schema.Assistant:
+			b.WriteString("Assistant: ")
+		default:
+			continue
+		}
+		b.WriteString(string(content))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
 
-3. Câu hỏi mở: Kết thúc bằng một câu hỏi mở để duy trì cuộc trò chuyện.
-`),
+func createFoodRecommendTemplate() prompt.ChatTemplate {
+	return prompt.FromMessages(schema.FString,
+		schema.SystemMessage(foodRecommendSystemPrompt),
 		schema.MessagesPlaceholder("chat_history", true),
 		schema.UserMessage("{food_request}"),
 	)
@@ -126,11 +161,21 @@ func (m *Manager) BuildChatMessages(message string, history []*schema.Message) (
 	return messages, nil
 }
 
-// BuildTitleMessages builds messages for title generation
-func (m *Manager) BuildTitleMessages(firstMessage string) ([]*schema.Message, error) {
-	messages, err := m.titleTemplate.Format(context.Background(), map[string]any{
-		"message": firstMessage,
-	})
+// BuildTitleMessages builds messages for title generation in the given
+// language, falling back to the default language. With history the title
+// summarizes the conversation; otherwise it is based on firstMessage.
+func (m *Manager) BuildTitleMessages(language, firstMessage string, history []*schema.Message) ([]*schema.Message, error) {
+	templates, params := m.titleTemplates, map[string]any{"message": firstMessage}
+	if len(history) > 0 {
+		templates, params = m.titleHistoryTemplates, map[string]any{"transcript": formatTranscript(history)}
+	}
+
+	titleTemplate, ok := templates[language]
+	if !ok {
+		titleTemplate = templates[DefaultLanguage]
+	}
+
+	messages, err := titleTemplate.Format(context.Background(), params)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to format title template: %w", err)
@@ -139,6 +184,21 @@ func (m *Manager) BuildTitleMessages(firstMessage string) ([]*schema.Message, er
 	return messages, nil
 }
 
+// placeholderTitleLength is how many characters of the first message a
+// placeholder title keeps
+const placeholderTitleLength = 50
+
+// PlaceholderTitle names a new conversation after the first line of its
+// first message until a title is generated
+func PlaceholderTitle(message string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	line = strings.TrimSpace(line)
+	if runes := []rune(line); len(runes) > placeholderTitleLength {
+		return strings.TrimSpace(string(runes[:placeholderTitleLength])) + "…"
+	}
+	return line
+}
+
 // BuildFoodRecommendMessages builds messages for food recommendation
 func (m *Manager) BuildFoodRecommendMessages(foodRequest string, history []*schema.Message) ([]*schema.Message, error) {
 	// Limit history to configured max
@@ -164,6 +224,41 @@ func (m *Manager) BuildFoodRecommendMessages(foodRequest string, history []*sche
 	return messages, nil
 }
 
+// BuildConversationMessages builds messages for a conversation using its
+// custom system prompt, or the named persona when no custom prompt is set.
+// The prompt is used verbatim rather than as a template, so user-supplied
+// text can't inject template variables. Custom prompts get a directive to
+// answer in the selected language; persona prompts are already localized.
+// History is sent as given; the AI service's history builders trim it.
+func (m *Manager) BuildConversationMessages(systemPrompt, persona, language, message string, history []*schema.Message) ([]*schema.Message, error) {
+	if language == "" {
+		language = DefaultLanguage
+	}
+	if !IsSupportedLanguage(language) {
+		return nil, fmt.Errorf("unsupported language: %s", language)
+	}
+
+	if systemPrompt == "" {
+		if persona == "" {
+			persona = DefaultPersona
+		}
+		p, ok := GetPersona(persona)
+		if !ok {
+			return nil, fmt.Errorf("unknown persona: %s", persona)
+		}
+		systemPrompt = p.SystemPrompt(language)
+	} else {
+		systemPrompt += "\n\n" + languageDirectives[language]
+	}
+
+	messages := make([]*schema.Message, 0, len(history)+2)
+	messages = append(messages, schema.SystemMessage(systemPrompt))
+	messages = append(messages, history...)
+	messages = append(messages, schema.UserMessage(message))
+
+	return messages, nil
+}
+
 // UpdateConfig updates the template configuration
 func (m *Manager) UpdateConfig(config *Config) {
 	m.config = config
@@ -0,0 +1,89 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// MaxMemoryLength is the maximum length of one stored memory
+const MaxMemoryLength = 200
+
+// memoryHeaders introduce the user's memories in the system prompt
+var memoryHeaders = map[string]string{
+	LanguageVietnamese: "Những điều bạn đã biết về người dùng (chỉ dùng khi liên quan):",
+	LanguageEnglish:    "Things you know about the user (use them only when relevant):",
+}
+
+// AppendMemories adds the user's memories to the system message of a built
+// prompt. Memories are listed verbatim after the instructions.
+func AppendMemories(messages []*schema.Message, language string, memories []string) {
+	if len(memories) == 0 || len(messages) == 0 || messages[0].Role != schema.System {
+		return
+	}
+
+	header, ok := memoryHeaders[language]
+	if !ok {
+		header = memoryHeaders[DefaultLanguage]
+	}
+
+	var b strings.Builder
+	b.WriteString(messages[0].Content)
+	b.WriteString("\n\n")
+	b.WriteString(header)
+	for _, memory := range memories {
+		b.WriteString("\n- ")
+		b.WriteString(memory)
+	}
+	messages[0].Content = b.String()
+}
+
+// BuildMemoryExtractionMessages builds messages asking the model for lasting
+// facts about the user stated in their message that aren't known yet. The
+// message is passed as a separate user message so it can't change the
+// instructions.
+func BuildMemoryExtractionMessages(message string, known []string) []*schema.Message {
+	var b strings.Builder
+	b.WriteString("Extract lasting facts about the user from their message below: preferences, dietary needs, allergies, location, profession and similar. ")
+	b.WriteString("Ignore one-off requests, questions and facts about other people. ")
+	fmt.Fprintf(&b, "Write each fact as a short phrase of at most %d characters in the language of the message. ", MaxMemoryLength)
+	b.WriteString("Reply with a JSON array of strings only, and [] if there is nothing new.")
+	if len(known) > 0 {
+		b.WriteString("\n\nAlready known, don't repeat:")
+		for _, memory := range known {
+			b.WriteString("\n- ")
+			b.WriteString(memory)
+		}
+	}
+
+	return []*schema.Message{
+		schema.SystemMessage(b.String()),
+		schema.UserMessage(message),
+	}
+}
+
+// ParseMemoryReply reads the facts from the extraction reply. Code fences
+// around the array are tolerated; empty and overly long facts are dropped.
+func ParseMemoryReply(reply string) ([]string, error) {
+	reply = strings.TrimSpace(reply)
+	reply = strings.TrimPrefix(reply, "```json")
+	reply = strings.TrimPrefix(reply, "```")
+	reply = strings.TrimSuffix(reply, "```")
+
+	var facts []string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(reply)), &facts); err != nil {
+		return nil, fmt.Errorf("memory extraction reply is not a JSON array of strings: %w", err)
+	}
+
+	memories := make([]string, 0, len(facts))
+	for _, fact := range facts {
+		fact = strings.TrimSpace(fact)
+		if fact == "" || len([]rune(fact)) > MaxMemoryLength {
+			continue
+		}
+		memories = append(memories, fact)
+	}
+	return memories, nil
+}
@@ -0,0 +1,243 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Persona is a built-in system prompt clients can select by name
+type Persona struct {
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	SystemPrompts map[string]string `json:"-"` // keyed by language
+
+	// PostProcessors are applied to the persona's responses in order
+	PostProcessors []PostProcessorSpec `json:"-"`
+}
+
+// SystemPrompt returns the persona's prompt in language, falling back to
+// the default language
+func (p Persona) SystemPrompt(language string) string {
+	if prompt, ok := p.SystemPrompts[language]; ok {
+		return prompt
+	}
+	return p.SystemPrompts[DefaultLanguage]
+}
+
+// DefaultPersona is used when a conversation has neither a persona nor a
+// custom system prompt
+const DefaultPersona = "food"
+
+const foodRecommendSystemPrompt = `Tính cách: Thân thiện, chuyên nghiệp, và có chút hài hước. Giao tiếp tự nhiên, gần gũi nhưng không quá "đời thường". Agent nên giống một người bạn sành ăn, luôn sẵn lòng gợi ý và tư vấn.
+
+Mục tiêu: Trả lời một cách linh hoạt, không chỉ giới hạn ở việc đề xuất món ăn mà còn mở rộng sang các tùy chọn khác như quán ăn, topping, hoặc món ăn kèm.
+
+Ngôn ngữ: Sử dụng ngôn từ trẻ trung, tích cực, ví dụ: "đỉnh của chóp", "chuẩn vị", "siêu ngon". Hạn chế sử dụng quá nhiều emoji để giữ sự chuyên nghiệp.
+
+Cấu trúc phản hồi:
+
+1. Phản ứng ban đầu: Xác nhận yêu cầu của người dùng một cách tích cực.
+
+2. Gợi ý đa dạng: Đưa ra các tùy chọn không chỉ về món ăn mà còn về các khía cạnh liên quan, giúp người dùng có nhiều sự lựa chọn hơn.
+
+3. Câu hỏi mở: Kết thúc bằng một câu hỏi mở để duy trì cuộc trò chuyện.
+`
+
+const foodRecommendSystemPromptEN = `Personality: Friendly, professional and a little humorous. Speak naturally and warmly without being overly casual. Act like a foodie friend who is always happy to suggest and advise.
+
+Goal: Answer flexibly. Don't stop at recommending dishes; also suggest restaurants, toppings and side dishes.
+
+Language: Use upbeat, positive wording such as "absolutely delicious" or "spot on". Keep emoji to a minimum to stay professional.
+
+Response structure:
+
+1. Opening: Acknowledge the user's request positively.
+
+2. Varied suggestions: Offer options covering the dish and related choices so the user has more to pick from.
+
+3. Open question: End with an open question to keep the conversation going.
+`
+
+const codingMentorSystemPrompt = `Bạn là một người hướng dẫn lập trình giàu kinh nghiệm và kiên nhẫn.
+
+Mục tiêu: Giúp người dùng hiểu vấn đề và tự tìm ra lời giải, không chỉ đưa đáp án.
+
+Cách trả lời:
+
+1. Làm rõ: Tóm tắt lại vấn đề; hỏi thêm về ngôn ngữ, phiên bản hoặc thông báo lỗi nếu còn thiếu.
+
+2. Giải thích: Trình bày nguyên nhân và ý tưởng trước, sau đó mới đưa ra đoạn mã ngắn gọn, có chú thích.
+
+3. Thực hành tốt: Nhắc đến cách kiểm thử, các trường hợp biên và lỗi bảo mật thường gặp khi liên quan.
+
+Luôn đặt mã trong khối code có ghi rõ ngôn ngữ.
+`
+
+const codingMentorSystemPromptEN = `You are an experienced and patient programming mentor.
+
+Goal: Help the user understand the problem and reach the solution themselves rather than only handing over an answer.
+
+How to answer:
+
+1. Clarify: Restate the problem; ask for the language, version or error message when they are missing.
+
+2. Explain: Describe the cause and the idea first, then give a short, commented code example.
+
+3. Good practice: Mention testing, edge cases and common security pitfalls when relevant.
+
+Always put code in fenced code blocks labelled with the language.
+`
+
+// builtinPersonas are the personas shipped with the server
+var builtinPersonas = map[string]Persona{
+	"food": {
+		Name:        "food",
+		Description: "Friendly food and restaurant recommendations",
+		SystemPrompts: map[string]string{
+			LanguageVietnamese: foodRecommendSystemPrompt,
+			LanguageEnglish:    foodRecommendSystemPromptEN,
+		},
+		PostProcessors: defaultPostProcessors,
+	},
+	"assistant": {
+		Name:        "assistant",
+		Description: "General-purpose helpful assistant",
+		SystemPrompts: map[string]string{
+			LanguageVietnamese: "Bạn là một trợ lý hữu ích, chính xác và lịch sự. Trả lời rõ ràng, có cấu trúc, và hỏi lại khi yêu cầu của người dùng chưa rõ ràng.",
+			LanguageEnglish:    "You are a helpful, accurate and polite assistant. Answer clearly and in a structured way, and ask follow-up questions when the user's request is unclear.",
+		},
+		PostProcessors: defaultPostProcessors,
+	},
+	"coding": {
+		Name:        "coding",
+		Description: "Programming mentor that explains code and guides you to solutions",
+		SystemPrompts: map[string]string{
+			LanguageVietnamese: codingMentorSystemPrompt,
+			LanguageEnglish:    codingMentorSystemPromptEN,
+		},
+		PostProcessors: defaultPostProcessors,
+	},
+	"nutritionist": {
+		Name:        "nutritionist",
+		Description: "Balanced-diet and nutrition advice",
+		SystemPrompts: map[string]string{
+			LanguageVietnamese: "Bạn là một chuyên gia dinh dưỡng. Đưa ra lời khuyên về chế độ ăn cân bằng, thành phần dinh dưỡng và lựa chọn món ăn lành mạnh. Không chẩn đoán bệnh; khuyên người dùng gặp bác sĩ khi cần thiết.",
+			LanguageEnglish:    "You are a nutritionist. Give advice on balanced diets, nutritional content and healthy food choices. Do not diagnose illnesses; recommend seeing a doctor when appropriate.",
+		},
+		PostProcessors: defaultPostProcessors,
+	},
+}
+
+// personas is the active allowlist: the built-in personas plus those loaded
+// by LoadPersonas. It is replaced as a whole so readers never see a
+// partially loaded set.
+var personas atomic.Pointer[map[string]Persona]
+
+func init() {
+	personas.Store(&builtinPersonas)
+}
+
+// personaFile is the format of a personas file
+type personaFile struct {
+	Personas []struct {
+		Name          string            `json:"name" yaml:"name"`
+		Description   string            `json:"description" yaml:"description"`
+		SystemPrompts map[string]string `json:"system_prompts" yaml:"system_prompts"`
+
+		// PostProcessors defaults to defaultPostProcessors when omitted;
+		// an empty list turns post-processing off
+		PostProcessors []PostProcessorSpec `json:"post_processors" yaml:"post_processors"`
+	} `json:"personas" yaml:"personas"`
+}
+
+// LoadPersonas replaces the active personas with the built-in ones plus
+// those defined in a YAML or JSON file, which may override built-ins by
+// name. An empty path restores the built-in set.
+func LoadPersonas(path string) error {
+	active := make(map[string]Persona, len(builtinPersonas))
+	for name, persona := range builtinPersonas {
+		active[name] = persona
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read personas file: %w", err)
+		}
+
+		var file personaFile
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			err = yaml.Unmarshal(data, &file)
+		case ".json":
+			err = json.Unmarshal(data, &file)
+		default:
+			return fmt.Errorf("unsupported personas file format: %s", path)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse personas file %s: %w", path, err)
+		}
+
+		for _, p := range file.Personas {
+			if p.Name == "" {
+				return fmt.Errorf("persona without a name in %s", path)
+			}
+			if p.Name == AutoPersona {
+				return fmt.Errorf("persona name %s is reserved", AutoPersona)
+			}
+			if p.SystemPrompts[DefaultLanguage] == "" {
+				return fmt.Errorf("persona %s has no %s system prompt", p.Name, DefaultLanguage)
+			}
+			postProcessors := p.PostProcessors
+			if postProcessors == nil {
+				postProcessors = defaultPostProcessors
+			}
+			for _, spec := range postProcessors {
+				if err := spec.Validate(); err != nil {
+					return fmt.Errorf("persona %s: %w", p.Name, err)
+				}
+			}
+			active[p.Name] = Persona{
+				Name:           p.Name,
+				Description:    p.Description,
+				SystemPrompts:  p.SystemPrompts,
+				PostProcessors: postProcessors,
+			}
+		}
+	}
+
+	personas.Store(&active)
+	return nil
+}
+
+// GetPersona returns the persona with the given name
+func GetPersona(name string) (Persona, bool) {
+	persona, ok := (*personas.Load())[name]
+	return persona, ok
+}
+
+// IsPersona reports whether name is an allowed persona
+func IsPersona(name string) bool {
+	_, ok := (*personas.Load())[name]
+	return ok
+}
+
+// ListPersonas returns all personas sorted by name
+func ListPersonas() []Persona {
+	active := *personas.Load()
+	list := make([]Persona, 0, len(active))
+	for _, persona := range active {
+		list = append(list, persona)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
@@ -0,0 +1,85 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Post-processor types, applied to a persona's responses in the order
+// they are listed
+const (
+	// PostProcessorSanitizeMarkdown removes raw HTML tags and script links
+	// outside code
+	PostProcessorSanitizeMarkdown = "sanitize_markdown"
+
+	// PostProcessorProfanity masks profane words
+	PostProcessorProfanity = "profanity_filter"
+
+	// PostProcessorTruncate cuts responses longer than MaxLength
+	// characters and appends Marker
+	PostProcessorTruncate = "truncate"
+
+	// PostProcessorRewriteLinks rewrites links outside code with Template
+	PostProcessorRewriteLinks = "rewrite_links"
+)
+
+// URLPlaceholder is replaced by the escaped original link in a
+// rewrite_links template
+const URLPlaceholder = "{url}"
+
+// PostProcessorSpec configures one step of a persona's output
+// post-processing
+type PostProcessorSpec struct {
+	Type string `json:"type" yaml:"type"`
+
+	// Words are masked by the profanity filter in addition to its
+	// built-in list
+	Words []string `json:"words,omitempty" yaml:"words"`
+
+	// MaxLength and Marker configure truncate; Marker defaults to an
+	// ellipsis
+	MaxLength int    `json:"max_length,omitempty" yaml:"max_length"`
+	Marker    string `json:"marker,omitempty" yaml:"marker"`
+
+	// Template and Hosts configure rewrite_links. Only links to Hosts and
+	// their subdomains are rewritten, or all links if Hosts is empty.
+	Template string   `json:"template,omitempty" yaml:"template"`
+	Hosts    []string `json:"hosts,omitempty" yaml:"hosts"`
+}
+
+// Validate checks that the step is complete
+func (s PostProcessorSpec) Validate() error {
+	switch s.Type {
+	case PostProcessorSanitizeMarkdown, PostProcessorProfanity:
+		return nil
+	case PostProcessorTruncate:
+		if s.MaxLength < 1 {
+			return fmt.Errorf("%s needs a positive max_length", s.Type)
+		}
+		return nil
+	case PostProcessorRewriteLinks:
+		if !strings.Contains(s.Template, URLPlaceholder) {
+			return fmt.Errorf("%s template must contain %s", s.Type, URLPlaceholder)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown post-processor type %q", s.Type)
+	}
+}
+
+// defaultPostProcessors apply to the built-in personas and to personas
+// from the personas file that don't list their own
+var defaultPostProcessors = []PostProcessorSpec{
+	{Type: PostProcessorSanitizeMarkdown},
+}
+
+// PostProcessorsFor returns the post-processing steps for responses of
+// persona, using the default persona's for an unknown or empty name, e.g.
+// with a custom system prompt
+func PostProcessorsFor(persona string) []PostProcessorSpec {
+	if p, ok := GetPersona(persona); ok {
+		return p.PostProcessors
+	}
+	p, _ := GetPersona(DefaultPersona)
+	return p.PostProcessors
+}
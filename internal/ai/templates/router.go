@@ -0,0 +1,54 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// AutoPersona asks for the persona to be chosen from the first message of
+// a conversation instead of naming one
+const AutoPersona = "auto"
+
+// IsSelectablePersona reports whether a client may ask for name: an active
+// persona or AutoPersona
+func IsSelectablePersona(name string) bool {
+	return name == AutoPersona || IsPersona(name)
+}
+
+// routeMessageLength caps the part of the message shown to the router
+const routeMessageLength = 1000
+
+// BuildRouteMessages builds messages asking the model which of the active
+// personas fits a conversation's first message best. The message is passed
+// as a separate user message so it can't change the instructions.
+func BuildRouteMessages(message string) []*schema.Message {
+	var b strings.Builder
+	b.WriteString("Choose the assistant best suited to answer the user's message. ")
+	b.WriteString("Reply with the assistant's name only, exactly as written below, without any other words.\n\n")
+	for _, p := range ListPersonas() {
+		fmt.Fprintf(&b, "- %s: %s\n", p.Name, p.Description)
+	}
+	fmt.Fprintf(&b, "\nIf none fits clearly, reply with %s.", DefaultPersona)
+
+	content := []rune(strings.TrimSpace(message))
+	if len(content) > routeMessageLength {
+		content = content[:routeMessageLength]
+	}
+
+	return []*schema.Message{
+		schema.SystemMessage(b.String()),
+		schema.UserMessage(string(content)),
+	}
+}
+
+// ParseRouteReply maps the router's reply to an active persona name. Models
+// sometimes add punctuation or quotes around the name, which are ignored.
+func ParseRouteReply(reply string) (string, bool) {
+	name := strings.ToLower(strings.Trim(strings.TrimSpace(reply), " \t\n\"'`.:*"))
+	if IsPersona(name) {
+		return name, true
+	}
+	return "", false
+}
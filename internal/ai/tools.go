@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+// DefaultMaxToolRounds is how many rounds of tool calls a generation may
+// make before the model has to answer without tools
+const DefaultMaxToolRounds = 5
+
+// ContextFilter screens content retrieved for the model, such as a tool's
+// output, returning the content to pass on. An error replaces the content
+// and is shown to the model instead.
+type ContextFilter func(ctx context.Context, source, content string) (string, error)
+
+// ToolRegistry holds the tools the model may call
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]registeredTool
+}
+
+type registeredTool struct {
+	tool tool.InvokableTool
+	info *schema.ToolInfo
+}
+
+// NewToolRegistry creates an empty registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]registeredTool)}
+}
+
+// Register adds tools, failing without adding any if a name is taken
+func (r *ToolRegistry) Register(ctx context.Context, tools ...tool.InvokableTool) error {
+	infos := make([]*schema.ToolInfo, len(tools))
+	for i, t := range tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to describe tool: %w", err)
+		}
+		infos[i] = info
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		if _, ok := r.tools[info.Name]; ok || seen[info.Name] {
+			return fmt.Errorf("tool %s is already registered", info.Name)
+		}
+		seen[info.Name] = true
+	}
+	for i, info := range infos {
+		r.tools[info.Name] = registeredTool{tool: tools[i], info: info}
+	}
+	return nil
+}
+
+// Infos describes the registered tools sorted by name; a nil registry has
+// none
+func (r *ToolRegistry) Infos() []*schema.ToolInfo {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]*schema.ToolInfo, 0, len(r.tools))
+	for _, t := range r.tools {
+		infos = append(infos, t.info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// run executes a tool call. Failures are returned to the model as the
+// tool's output so it can recover instead of failing the generation.
+func (r *ToolRegistry) run(ctx context.Context, call schema.ToolCall) *schema.Message {
+	log := logger.ModuleContext(ctx, "ai")
+
+	r.mu.RLock()
+	t, ok := r.tools[call.Function.Name]
+	r.mu.RUnlock()
+	if !ok {
+		log.Warn().Str("tool", call.Function.Name).Msg("Model called an unknown tool")
+		return schema.ToolMessage(fmt.Sprintf("Error: unknown tool %s", call.Function.Name), call.ID)
+	}
+
+	start := time.Now()
+	result, err := t.tool.InvokableRun(ctx, call.Function.Arguments)
+	if err != nil {
+		log.Warn().Err(err).Str("tool", call.Function.Name).Dur("duration", time.Since(start)).Msg("Tool call failed")
+		return schema.ToolMessage("Error: "+err.Error(), call.ID)
+	}
+
+	log.Debug().Str("tool", call.Function.Name).Dur("duration", time.Since(start)).Msg("Tool call completed")
+	return schema.ToolMessage(result, call.ID)
+}
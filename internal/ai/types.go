@@ -2,9 +2,13 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
+	"github.com/shivaluma/eino-agent/internal/ai/templates"
+	"github.com/shivaluma/eino-agent/internal/models"
 )
 
 // ChatRequest represents a request to the AI chat service
@@ -15,6 +19,73 @@ type ChatRequest struct {
 	Model          string
 	Stream         bool
 	History        []*schema.Message
+
+	// SystemPrompt overrides the persona's system prompt when set
+	SystemPrompt string
+
+	// Persona selects a built-in system prompt (defaults to the food persona)
+	Persona string
+
+	// Language selects the prompt language (defaults to Vietnamese)
+	Language string
+
+	// Temperature, TopP, MaxTokens and Stop override the service defaults
+	// when set
+	Temperature *float64
+	TopP        *float64
+	MaxTokens   *int
+	Stop        []string
+
+	// MaxOutputLength caps the response at this many characters (0 for no
+	// limit). The service enforces it and the stop sequences itself, so
+	// they hold with providers that ignore them; structured output, which
+	// a cut would leave invalid, is left to the provider.
+	MaxOutputLength int
+
+	// ResponseFormat requests structured JSON output (optional)
+	ResponseFormat *ResponseFormat
+
+	// Attachments are images sent along with Message; they require a
+	// provider with vision support
+	Attachments []Attachment
+
+	// Parts, when set, is Message as sent: text and images in order, for
+	// models with vision support. Message holds the text.
+	Parts []Part
+
+	// Progress is told about the stages of the generation (optional)
+	Progress ProgressReporter
+
+	// Memories are facts about the user added to the system prompt
+	Memories []string
+
+	// ContextStrategy picks the history builder; empty uses the service's
+	ContextStrategy string
+}
+
+// Attachment is an inline file sent to the model with a message
+type Attachment struct {
+	ContentType string
+	Data        []byte
+}
+
+// Part is one piece of a message sent as parts: text or an image
+type Part struct {
+	Text  string
+	Image *Attachment
+}
+
+// hasImages reports whether req needs a model with vision support
+func (req *ChatRequest) hasImages() bool {
+	if len(req.Attachments) > 0 {
+		return true
+	}
+	for _, part := range req.Parts {
+		if part.Image != nil {
+			return true
+		}
+	}
+	return false
 }
 
 // ChatResponse represents a response from the AI chat service
@@ -22,6 +93,110 @@ type ChatResponse struct {
 	Content        string
 	ConversationID string
 	MessageID      int64
+
+	// Structured is the validated JSON output when a structured response
+	// format was requested
+	Structured json.RawMessage
+
+	// Provider and Model identify what produced the response
+	Provider string
+	Model    string
+
+	// Usage is the token usage reported by the provider, if any
+	Usage *Usage
+
+	// FinishReason is why the model stopped, as reported by the provider,
+	// or FinishReasonStop or FinishReasonLength when the service cut the
+	// response
+	FinishReason string
+
+	// Latency is how long the whole generation took, including retries
+	// and tool calls
+	Latency time.Duration
+
+	// CostMicros is the price of Usage in millionths of a US dollar;
+	// Priced is false when the model has no price or no usage was reported
+	CostMicros int64
+	Priced     bool
+
+	// OwnKey is set when the response was made with an organization's own
+	// API key
+	OwnKey bool
+}
+
+// Metadata describes how the response to req was produced, for storing
+// with the assistant message
+func (r *ChatResponse) Metadata(req *ChatRequest) *models.GenerationMetadata {
+	metadata := &models.GenerationMetadata{
+		Provider:     r.Provider,
+		Model:        r.Model,
+		LatencyMs:    r.Latency.Milliseconds(),
+		FinishReason: r.FinishReason,
+		Language:     req.Language,
+	}
+	if r.Usage != nil {
+		metadata.PromptTokens = r.Usage.PromptTokens
+		metadata.CompletionTokens = r.Usage.CompletionTokens
+		metadata.TotalTokens = r.Usage.TotalTokens
+	}
+	if r.Priced {
+		metadata.CostUSD = float64(r.CostMicros) / 1e6
+	}
+
+	if req.SystemPrompt != "" {
+		metadata.CustomPrompt = true
+	} else {
+		metadata.Persona = req.Persona
+		if metadata.Persona == "" {
+			metadata.Persona = templates.DefaultPersona
+		}
+	}
+	if metadata.Language == "" {
+		metadata.Language = templates.DefaultLanguage
+	}
+	return metadata
+}
+
+// Usage holds token counts for a generation
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// finishReasonFrom extracts the finish reason from a model response
+func finishReasonFrom(msg *schema.Message) string {
+	if msg == nil || msg.ResponseMeta == nil {
+		return ""
+	}
+	return msg.ResponseMeta.FinishReason
+}
+
+// usageFrom extracts token usage from a model response
+func usageFrom(msg *schema.Message) *Usage {
+	if msg == nil || msg.ResponseMeta == nil || msg.ResponseMeta.Usage == nil {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     msg.ResponseMeta.Usage.PromptTokens,
+		CompletionTokens: msg.ResponseMeta.Usage.CompletionTokens,
+		TotalTokens:      msg.ResponseMeta.Usage.TotalTokens,
+	}
+}
+
+// add sums two usages; either may be nil
+func (u *Usage) add(other *Usage) *Usage {
+	if u == nil {
+		return other
+	}
+	if other == nil {
+		return u
+	}
+	return &Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
 }
 
 // StreamCallback is called for each chunk in streaming mode
@@ -35,8 +210,24 @@ type Service interface {
 	// Stream creates a streaming response
 	Stream(ctx context.Context, req *ChatRequest, callback StreamCallback) (*ChatResponse, error)
 	
-	// GenerateTitle generates a title for a conversation
-	GenerateTitle(ctx context.Context, firstMessage string) (string, error)
+	// GenerateTitle generates a title for a conversation from its first
+	// message, or from history when it is non-empty
+	GenerateTitle(ctx context.Context, firstMessage, language string, history []*schema.Message) (string, error)
+
+	// RoutePersona picks the persona best suited to a conversation's first
+	// message
+	RoutePersona(ctx context.Context, message string) (string, error)
+
+	// ExtractMemories returns lasting facts about the user stated in their
+	// message, leaving out the ones already known
+	ExtractMemories(ctx context.Context, message string, known []string) ([]string, error)
+
+	// DetectInjection reports whether text tries to override the
+	// assistant's instructions
+	DetectInjection(ctx context.Context, text string) (bool, error)
+
+	// SetDefaultModel changes the default provider's model at runtime
+	SetDefaultModel(model string)
 }
 
 // Provider defines the interface for AI model providers
@@ -44,6 +235,15 @@ type Provider interface {
 	CreateChatModel(ctx context.Context) (model.ToolCallingChatModel, error)
 	GetName() string
 	IsAvailable() bool
+
+	// GetModel returns the provider's default model name
+	GetModel() string
+
+	// SupportsVision reports whether the provider's model accepts images
+	SupportsVision() bool
+
+	// GetEndpoint returns the base URL of the provider's API
+	GetEndpoint() string
 }
 
 // Config holds AI service configuration
@@ -51,6 +251,48 @@ type Config struct {
 	DefaultModel    string
 	DefaultProvider string
 	SystemPrompt    string
-	Temperature     float64
-	MaxTokens       int
+
+	// Temperature, TopP, MaxTokens and Stop are the generation parameters
+	// of requests that don't set their own; zero values leave them to the
+	// provider
+	Temperature float64
+	TopP        float64
+	MaxTokens   int
+	Stop        []string
+
+	// Retry controls retries, timeouts and provider failover
+	Retry *RetryPolicy
+
+	// Metrics collects per-provider counters (optional)
+	Metrics *Metrics
+
+	// Breakers stop sending requests to failing providers (optional)
+	Breakers *Breakers
+
+	// ContextStrategy names the history builder of requests that don't
+	// pick one, defaulting to a sliding window of ContextWindow messages.
+	// HistoryBuilders add or replace strategies.
+	ContextStrategy string
+	ContextWindow   int
+	HistoryBuilders map[string]HistoryBuilder
+
+	// Tools are offered to the model in chat generations (optional).
+	// MaxToolRounds bounds how many rounds of tool calls one generation may
+	// make, defaulting to DefaultMaxToolRounds.
+	Tools         *ToolRegistry
+	MaxToolRounds int
+
+	// ContextFilter screens tool results before the model sees them
+	// (optional)
+	ContextFilter ContextFilter
+
+	// Pricing prices responses (optional)
+	Pricing *Pricing
+
+	// Queue bounds concurrent Generate and Stream calls (optional)
+	Queue *Queue
+
+	// Resolver swaps in other models per generation, e.g. organizations'
+	// own (optional)
+	Resolver ModelResolver
 }
\ No newline at end of file
@@ -0,0 +1,109 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes one invalid request field. Field is the JSON path
+// of the field, e.g. "response_format.type", and Message explains the
+// problem to people.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Validation converts an error from c.Validate into a 400 listing the
+// invalid fields. Errors the validator returns for anything but invalid
+// fields are internal; their text describes Go types, not the request.
+func Validation(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return Internal("Failed to validate request").Wrap(err)
+	}
+
+	fields := make([]FieldError, 0, len(fieldErrs))
+	names := make([]string, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		field := fieldPath(fe)
+		fields = append(fields, FieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fieldMessage(field, fe),
+		})
+		names = append(names, field)
+	}
+
+	message := "Invalid fields: " + strings.Join(names, ", ")
+	return New(http.StatusBadRequest, CodeValidation, message).WithDetails(fields)
+}
+
+// fieldPath is the field's namespace without the Go struct name it starts
+// with. The validator names fields by their JSON names.
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+	return path
+}
+
+// fieldMessage explains a failed validation rule in words
+func fieldMessage(field string, fe validator.FieldError) string {
+	param := fe.Param()
+
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "url":
+		return field + " must be a valid URL"
+	case "uuid", "uuid4":
+		return field + " must be a valid UUID"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.Join(strings.Fields(param), ", "))
+	case "min", "gte":
+		return fmt.Sprintf("%s must be at least %s", field, bound(fe.Kind(), param))
+	case "max", "lte":
+		return fmt.Sprintf("%s must be at most %s", field, bound(fe.Kind(), param))
+	case "len":
+		return fmt.Sprintf("%s must be exactly %s", field, bound(fe.Kind(), param))
+	case "gt":
+		return fmt.Sprintf("%s must be more than %s", field, bound(fe.Kind(), param))
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, bound(fe.Kind(), param))
+	}
+	return fmt.Sprintf("%s is invalid (%s)", field, fe.Tag())
+}
+
+// bound describes a size limit in the unit of the field's kind: characters
+// for strings, items for lists, the value itself for numbers
+func bound(kind reflect.Kind, param string) string {
+	unit := ""
+	switch kind {
+	case reflect.String:
+		unit = " character"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " item"
+	default:
+		return param
+	}
+	if param != "1" {
+		unit += "s"
+	}
+	return param + unit
+}
@@ -0,0 +1,141 @@
+// Package apiversion registers routes under /api/<version> and lets
+// handlers shape responses per version, so a new version can change its
+// schema while older ones stay compatible
+package apiversion
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// API versions, oldest first
+const (
+	V1 = "v1"
+	V2 = "v2"
+)
+
+// Latest is the version used when a request carries none
+const Latest = V2
+
+const contextKey = "api_version"
+
+// Deprecation is announced to clients with the Deprecation (RFC 9745),
+// Sunset (RFC 8594) and Link headers
+type Deprecation struct {
+	// At is when the endpoint was deprecated; zero sends "Deprecation: true"
+	At time.Time
+
+	// Sunset is when the endpoint stops working; zero omits the header
+	Sunset time.Time
+
+	// Link points at migration docs or the successor endpoint
+	Link string
+}
+
+// Middleware adds the deprecation headers to every response
+func (d Deprecation) Middleware() echo.MiddlewareFunc {
+	deprecation := "true"
+	if !d.At.IsZero() {
+		deprecation = fmt.Sprintf("@%d", d.At.Unix())
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			h := c.Response().Header()
+			h.Set("Deprecation", deprecation)
+			if !d.Sunset.IsZero() {
+				h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if d.Link != "" {
+				h.Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", d.Link))
+			}
+			return next(c)
+		}
+	}
+}
+
+// Version is an API version and its deprecation, if any
+type Version struct {
+	Name        string
+	Deprecation *Deprecation
+}
+
+// Router holds one route group per version
+type Router struct {
+	versions []Version
+	groups   map[string]*echo.Group
+}
+
+// NewRouter creates a group at prefix/<name> for every version. Requests
+// are tagged with their version, and deprecated versions send the
+// deprecation headers on every response.
+func NewRouter(e *echo.Echo, prefix string, versions ...Version) *Router {
+	r := &Router{versions: versions, groups: make(map[string]*echo.Group, len(versions))}
+	for _, v := range versions {
+		middleware := []echo.MiddlewareFunc{tag(v.Name)}
+		if v.Deprecation != nil {
+			middleware = append(middleware, v.Deprecation.Middleware())
+		}
+		r.groups[v.Name] = e.Group(strings.TrimSuffix(prefix, "/")+"/"+v.Name, middleware...)
+	}
+	return r
+}
+
+// Group returns the group of one version, for routes only it has
+func (r *Router) Group(version string) *echo.Group {
+	return r.groups[version]
+}
+
+// Each registers routes shared by every version
+func (r *Router) Each(register func(version string, g *echo.Group)) {
+	for _, v := range r.versions {
+		register(v.Name, r.groups[v.Name])
+	}
+}
+
+func tag(version string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(contextKey, version)
+			return next(c)
+		}
+	}
+}
+
+// FromContext returns the version of the request, or Latest outside a
+// versioned group
+func FromContext(c echo.Context) string {
+	if version, ok := c.Get(contextKey).(string); ok {
+		return version
+	}
+	return Latest
+}
+
+// Mapper converts a model into its representation for each version.
+// Versions without a mapping get the model itself, which is the v1 schema.
+type Mapper[T any] map[string]func(T) interface{}
+
+// Map converts v for the version of the request
+func (m Mapper[T]) Map(c echo.Context, v T) interface{} {
+	if convert, ok := m[FromContext(c)]; ok {
+		return convert(v)
+	}
+	return v
+}
+
+// MapSlice converts every element of vs
+func (m Mapper[T]) MapSlice(c echo.Context, vs []T) interface{} {
+	convert, ok := m[FromContext(c)]
+	if !ok {
+		return vs
+	}
+	out := make([]interface{}, len(vs))
+	for i, v := range vs {
+		out[i] = convert(v)
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+)
+
+// Auditor records security-relevant actions to the audit log
+type Auditor struct {
+	repo *repository.AuditRepository
+	key  []byte
+}
+
+// NewAuditor creates a new auditor. An empty key uses a random one, so
+// hashes only match for the life of the process.
+func NewAuditor(repo *repository.AuditRepository, key string) *Auditor {
+	a := &Auditor{repo: repo, key: []byte(key)}
+	if len(a.key) == 0 {
+		a.key = make([]byte, 32)
+		rand.Read(a.key)
+	}
+	return a
+}
+
+// Hash is a keyed hash of value, e.g. an email that belongs to no account,
+// so events about it can be matched up without recording it
+func (a *Auditor) Hash(value string) string {
+	if a == nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(value))
+	return "sha256:" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// Record persists an audit event. Failures are logged rather than returned so
+// that auditing never breaks the request being audited.
+func (a *Auditor) Record(ctx context.Context, event *models.AuditEvent) {
+	if a == nil || a.repo == nil {
+		return
+	}
+
+	if event.RequestID == nil {
+		if requestID := logger.GetRequestID(ctx); requestID != "" {
+			event.RequestID = &requestID
+		}
+	}
+
+	if err := a.repo.Create(ctx, event); err != nil {
+		logger.WithContext(ctx).Warn().
+			Err(err).
+			Str("action", event.Action).
+			Msg("Failed to record audit event")
+	}
+}
+
+// RecordRequest records an audit event enriched with the client IP,
+// user agent and request ID of the current request
+func (a *Auditor) RecordRequest(c echo.Context, action string, userID *uuid.UUID, success bool, metadata map[string]interface{}) {
+	if a == nil {
+		return
+	}
+
+	ip := c.RealIP()
+	userAgent := c.Request().UserAgent()
+
+	event := &models.AuditEvent{
+		UserID:    userID,
+		Action:    action,
+		Success:   success,
+		IPAddress: &ip,
+		UserAgent: &userAgent,
+	}
+
+	if len(metadata) > 0 {
+		if data, err := json.Marshal(metadata); err == nil {
+			event.Metadata = data
+		}
+	}
+
+	a.Record(c.Request().Context(), event)
+}
+
+// List returns audit events matching the filter
+func (a *Auditor) List(ctx context.Context, filter *models.AuditEventFilter) ([]models.AuditEvent, error) {
+	return a.repo.List(ctx, filter)
+}
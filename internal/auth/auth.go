@@ -4,24 +4,72 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/cache"
 	"github.com/shivaluma/eino-agent/internal/models"
 
 	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const revokedTokenKeyPrefix = "jwt:revoked:"
+
+// revokedUserKeyPrefix marks when all access tokens of a user were revoked
+const revokedUserKeyPrefix = "jwt:revoked_user:"
+
 type Service struct {
 	config *config.Config
+	cache  cache.Cache
+
+	// keys holds the access token keys, swapped on rotation
+	keys atomic.Pointer[keySet]
+}
+
+func NewService(cfg *config.Config, c cache.Cache) (*Service, error) {
+	keys, err := loadKeys(cfg.JWT)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Service{config: cfg, cache: c}
+	s.keys.Store(keys)
+	return s, nil
+}
+
+// RotateAccessSecret signs new access tokens with secret while still
+// accepting tokens signed with the previous one. It only applies to HS256.
+func (s *Service) RotateAccessSecret(secret string) error {
+	keys := s.keys.Load()
+	if keys.alg != jwa.HS256 {
+		return nil
+	}
+
+	current, err := jwk.FromRaw([]byte(secret))
+	if err != nil {
+		return fmt.Errorf("failed to create signing key: %w", err)
+	}
+	rotated, err := newKeySet(jwa.HS256, current, []jwk.Key{keys.signing})
+	if err != nil {
+		return err
+	}
+	s.keys.Store(rotated)
+	return nil
 }
 
-func NewService(cfg *config.Config) *Service {
-	return &Service{config: cfg}
+// JWKS returns the public keys that verify access tokens. It is empty for
+// HS256, whose keys can't be published.
+func (s *Service) JWKS() jwk.Set {
+	return s.keys.Load().public
 }
 
 func (s *Service) HashPassword(password string) (string, error) {
@@ -44,6 +92,7 @@ func (s *Service) GenerateAccessToken(userID uuid.UUID, username string) (string
 	token, err := jwt.NewBuilder().
 		Issuer("food-agent").
 		Subject(userID.String()).
+		JwtID(uuid.New().String()).
 		Audience([]string{"food-agent-api"}).
 		IssuedAt(now).
 		Expiration(now.Add(s.config.JWT.AccessExpiration)).
@@ -55,7 +104,8 @@ func (s *Service) GenerateAccessToken(userID uuid.UUID, username string) (string
 		return "", fmt.Errorf("failed to build access token: %w", err)
 	}
 
-	signed, err := jwt.Sign(token, jwt.WithKey(jwa.HS256, []byte(s.config.JWT.AccessSecret)))
+	keys := s.keys.Load()
+	signed, err := jwt.Sign(token, jwt.WithKey(keys.alg, keys.signing))
 	if err != nil {
 		return "", fmt.Errorf("failed to sign access token: %w", err)
 	}
@@ -71,16 +121,27 @@ func (s *Service) GenerateRefreshToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
 
-func (s *Service) CreateRefreshTokenRecord(userID uuid.UUID, token string) *models.RefreshToken {
-	return &models.RefreshToken{
+// CreateRefreshTokenRecord prepares a refresh token bound to device. A
+// token replacing one during a refresh continues its session.
+func (s *Service) CreateRefreshTokenRecord(userID uuid.UUID, token string, device models.Device, previous *models.RefreshToken) *models.RefreshToken {
+	record := &models.RefreshToken{
 		UserID:    userID,
 		TokenHash: token,
 		ExpiresAt: time.Now().Add(s.config.JWT.RefreshExpiration),
+		Device:    device,
+	}
+	if previous != nil {
+		record.SessionID = previous.SessionID
+		record.SignedInAt = previous.SignedInAt
 	}
+	return record
 }
 
 func (s *Service) ValidateAccessToken(tokenString string) (jwt.Token, error) {
-	token, err := jwt.Parse([]byte(tokenString), jwt.WithKey(jwa.HS256, []byte(s.config.JWT.AccessSecret)))
+	// Tokens issued before key IDs were added have no kid, so every key is
+	// tried rather than requiring a match
+	keys := s.keys.Load()
+	token, err := jwt.Parse([]byte(tokenString), jwt.WithKeySet(keys.verify, jws.WithRequireKid(false)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse access token: %w", err)
 	}
@@ -97,6 +158,64 @@ func (s *Service) ValidateAccessToken(tokenString string) (jwt.Token, error) {
 	return token, nil
 }
 
+// RevokeAccessToken adds the token to the revocation list until it expires
+func (s *Service) RevokeAccessToken(ctx context.Context, token jwt.Token) error {
+	if token.JwtID() == "" {
+		return fmt.Errorf("token has no ID")
+	}
+
+	ttl := time.Until(token.Expiration())
+	if ttl <= 0 {
+		return nil // Already expired
+	}
+
+	if err := s.cache.Set(ctx, revokedTokenKeyPrefix+token.JwtID(), []byte("1"), ttl); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeUserAccessTokens revokes every access token issued to the user so
+// far. The mark is kept until the last of them expires.
+func (s *Service) RevokeUserAccessTokens(ctx context.Context, userID uuid.UUID) error {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := s.cache.Set(ctx, revokedUserKeyPrefix+userID.String(), []byte(now), s.config.JWT.AccessExpiration); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+	return nil
+}
+
+// IsAccessTokenRevoked checks whether the token is on the revocation list
+// or was issued before all tokens of its user were revoked
+func (s *Service) IsAccessTokenRevoked(ctx context.Context, token jwt.Token) (bool, error) {
+	if token.JwtID() != "" {
+		revoked, err := s.cache.Exists(ctx, revokedTokenKeyPrefix+token.JwtID())
+		if err != nil {
+			return false, fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if revoked {
+			return true, nil
+		}
+	}
+
+	value, err := s.cache.Get(ctx, revokedUserKeyPrefix+token.Subject())
+	if errors.Is(err, cache.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	revokedAt, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return false, nil
+	}
+
+	// Issue times are in whole seconds, so tokens issued in the second of
+	// the revocation count as revoked
+	return token.IssuedAt().Unix() <= revokedAt, nil
+}
+
 func (s *Service) ExtractUserIDFromToken(token jwt.Token) (uuid.UUID, error) {
 	subject := token.Subject()
 	if subject == "" {
@@ -145,4 +264,13 @@ func (s *Service) GetUserClaimsFromContext(ctx context.Context) (*UserClaims, er
 		UserID:   userID,
 		Username: username,
 	}, nil
+}
+
+// GetAccessTokenFromContext returns the validated access token of the request
+func (s *Service) GetAccessTokenFromContext(ctx context.Context) (jwt.Token, error) {
+	token, ok := ctx.Value("access_token").(jwt.Token)
+	if !ok {
+		return nil, fmt.Errorf("access token not found in context")
+	}
+	return token, nil
 }
\ No newline at end of file
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/shivaluma/eino-agent/internal/models"
+)
+
+// DeviceIDHeader carries an optional ID a client keeps for its device, such
+// as one generated on first launch. Refresh tokens are bound to it along
+// with the user agent.
+const DeviceIDHeader = "X-Device-ID"
+
+// maxDeviceIDLength bounds the stored device ID
+const maxDeviceIDLength = 128
+
+// versionPattern matches version numbers in user agents
+var versionPattern = regexp.MustCompile(`[0-9][0-9._]*`)
+
+// NewDevice describes the device a request comes from. Its fingerprint is
+// a hash of the user agent without version numbers, so browser and OS
+// updates don't end sessions.
+func NewDevice(userAgent, deviceID, ip string) models.Device {
+	deviceID = strings.TrimSpace(deviceID)
+	if len(deviceID) > maxDeviceIDLength {
+		deviceID = deviceID[:maxDeviceIDLength]
+	}
+
+	agent := strings.Join(strings.Fields(versionPattern.ReplaceAllString(userAgent, "")), " ")
+	sum := sha256.Sum256([]byte(agent))
+
+	return models.Device{
+		Fingerprint: fmt.Sprintf("%x", sum),
+		UserAgent:   userAgent,
+		DeviceID:    deviceID,
+		IPAddress:   ip,
+	}
+}
+
+// DeviceMatches reports whether a refresh token may be used from device:
+// the fingerprints must match and so must the device IDs, unless the token
+// was issued without one, as it is on a browser redirect. Tokens issued
+// before they were bound to devices match any device.
+func DeviceMatches(token *models.RefreshToken, device models.Device) bool {
+	if token.Device.Fingerprint == "" {
+		return true
+	}
+	if token.Device.Fingerprint != device.Fingerprint {
+		return false
+	}
+	return token.Device.DeviceID == "" || token.Device.DeviceID == device.DeviceID
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/shivaluma/eino-agent/config"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// keySet holds the access token keys. Tokens are signed with signing and
+// verified against verify, which also holds the retired keys.
+type keySet struct {
+	alg     jwa.SignatureAlgorithm
+	signing jwk.Key
+	verify  jwk.Set
+
+	// public is served as the JWKS; it stays empty for HS256 since those
+	// keys are secret
+	public jwk.Set
+}
+
+// loadKeys builds the key set for the configured algorithm
+func loadKeys(cfg config.JWTConfig) (*keySet, error) {
+	if cfg.Algorithm == "" || cfg.Algorithm == config.JWTAlgorithmHS256 {
+		current, err := jwk.FromRaw([]byte(cfg.AccessSecret))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create signing key: %w", err)
+		}
+
+		var previous []jwk.Key
+		if cfg.PreviousAccessSecret != "" {
+			key, err := jwk.FromRaw([]byte(cfg.PreviousAccessSecret))
+			if err != nil {
+				return nil, fmt.Errorf("failed to create previous signing key: %w", err)
+			}
+			previous = append(previous, key)
+		}
+		return newKeySet(jwa.HS256, current, previous)
+	}
+
+	alg := jwa.SignatureAlgorithm(cfg.Algorithm)
+	current, err := readKeyFile(cfg.PrivateKeyFile, alg)
+	if err != nil {
+		return nil, err
+	}
+	if !isPrivateKey(current) {
+		return nil, fmt.Errorf("%s does not contain a private key", cfg.PrivateKeyFile)
+	}
+
+	var previous []jwk.Key
+	for _, path := range cfg.PreviousKeyFiles {
+		key, err := readKeyFile(path, alg)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+	return newKeySet(alg, current, previous)
+}
+
+// readKeyFile parses a PEM encoded key and checks it suits alg
+func readKeyFile(path string, alg jwa.SignatureAlgorithm) (jwk.Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	key, err := jwk.ParseKey(data, jwk.WithPEM(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key file %s: %w", path, err)
+	}
+
+	want := jwa.RSA
+	if alg == jwa.EdDSA {
+		want = jwa.OKP
+	}
+	if key.KeyType() != want {
+		return nil, fmt.Errorf("key in %s is %s, %s needs %s", path, key.KeyType(), alg, want)
+	}
+	return key, nil
+}
+
+// isPrivateKey reports whether key holds the private half of a pair
+func isPrivateKey(key jwk.Key) bool {
+	switch key.(type) {
+	case jwk.RSAPrivateKey, jwk.OKPPrivateKey:
+		return true
+	}
+	return false
+}
+
+// newKeySet assigns key IDs derived from each key's thumbprint, so tokens
+// name the key that signed them
+func newKeySet(alg jwa.SignatureAlgorithm, current jwk.Key, previous []jwk.Key) (*keySet, error) {
+	ks := &keySet{alg: alg, signing: current, verify: jwk.NewSet(), public: jwk.NewSet()}
+
+	for _, key := range append([]jwk.Key{current}, previous...) {
+		if err := jwk.AssignKeyID(key); err != nil {
+			return nil, fmt.Errorf("failed to assign key ID: %w", err)
+		}
+		if _, exists := ks.verify.LookupKeyID(key.KeyID()); exists {
+			continue
+		}
+		if err := key.Set(jwk.AlgorithmKey, alg); err != nil {
+			return nil, fmt.Errorf("failed to set key algorithm: %w", err)
+		}
+		if err := key.Set(jwk.KeyUsageKey, jwk.ForSignature); err != nil {
+			return nil, fmt.Errorf("failed to set key usage: %w", err)
+		}
+
+		if alg == jwa.HS256 {
+			if err := ks.verify.AddKey(key); err != nil {
+				return nil, fmt.Errorf("failed to add key: %w", err)
+			}
+			continue
+		}
+
+		public, err := key.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get public key: %w", err)
+		}
+		if err := ks.verify.AddKey(public); err != nil {
+			return nil, fmt.Errorf("failed to add key: %w", err)
+		}
+		if err := ks.public.AddKey(public); err != nil {
+			return nil, fmt.Errorf("failed to add key: %w", err)
+		}
+	}
+
+	return ks, nil
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/cache"
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+const loginKeyPrefix = "login:"
+
+// lockoutHistoryTTL is how long past lockouts count towards doubling the
+// next one
+const lockoutHistoryTTL = 24 * time.Hour
+
+// LoginStats counts login protection events since startup
+type LoginStats struct {
+	Failures int64 `json:"failures"`
+	Lockouts int64 `json:"lockouts"`
+	Blocked  int64 `json:"blocked"`
+}
+
+// LoginGuard tracks failed logins per email and per client IP in the cache
+// and locks either out for exponentially growing periods. Cache errors fail
+// open so an outage of the state backend doesn't block every login.
+type LoginGuard struct {
+	cache cache.Cache
+	cfg   config.LoginConfig
+
+	failures atomic.Int64
+	lockouts atomic.Int64
+	blocked  atomic.Int64
+}
+
+func NewLoginGuard(c cache.Cache, cfg config.LoginConfig) *LoginGuard {
+	return &LoginGuard{cache: c, cfg: cfg}
+}
+
+// Locked reports whether the email or the IP is locked out. Blocked
+// attempts are counted but don't extend the lockout.
+func (g *LoginGuard) Locked(ctx context.Context, email, ip string) bool {
+	for _, subject := range g.subjects(email, ip) {
+		locked, err := g.cache.Exists(ctx, loginKeyPrefix+"locked:"+subject.key)
+		if err != nil {
+			g.logError(ctx, err)
+			continue
+		}
+		if locked {
+			g.blocked.Add(1)
+			return true
+		}
+	}
+	return false
+}
+
+// Fail records a failed login and returns the subjects ("email", "ip") that
+// it locked out
+func (g *LoginGuard) Fail(ctx context.Context, email, ip string) []string {
+	g.failures.Add(1)
+
+	var lockedOut []string
+	for _, subject := range g.subjects(email, ip) {
+		if subject.max <= 0 {
+			continue
+		}
+
+		count, err := g.cache.Incr(ctx, loginKeyPrefix+"failures:"+subject.key, g.cfg.AttemptWindow)
+		if err != nil {
+			g.logError(ctx, err)
+			continue
+		}
+		if count < int64(subject.max) {
+			continue
+		}
+
+		if err := g.lock(ctx, subject.key); err != nil {
+			g.logError(ctx, err)
+			continue
+		}
+		lockedOut = append(lockedOut, subject.kind)
+	}
+	return lockedOut
+}
+
+// Succeed clears the failure count for email. The IP's count is kept so an
+// attacker can't reset it by logging into their own account.
+func (g *LoginGuard) Succeed(ctx context.Context, email string) {
+	key := loginKeyPrefix + "failures:email:" + email
+	if err := g.cache.Delete(ctx, key); err != nil {
+		g.logError(ctx, err)
+	}
+}
+
+// Stats returns the counters since startup
+func (g *LoginGuard) Stats() LoginStats {
+	return LoginStats{
+		Failures: g.failures.Load(),
+		Lockouts: g.lockouts.Load(),
+		Blocked:  g.blocked.Load(),
+	}
+}
+
+// lock starts a lockout for key lasting Lockout doubled for every earlier
+// lockout within lockoutHistoryTTL, capped at MaxLockout
+func (g *LoginGuard) lock(ctx context.Context, key string) error {
+	n, err := g.cache.Incr(ctx, loginKeyPrefix+"lockouts:"+key, lockoutHistoryTTL)
+	if err != nil {
+		return err
+	}
+
+	duration := g.cfg.Lockout
+	for i := int64(1); i < n && duration < g.cfg.MaxLockout; i++ {
+		duration *= 2
+	}
+	if g.cfg.MaxLockout > 0 && duration > g.cfg.MaxLockout {
+		duration = g.cfg.MaxLockout
+	}
+
+	value := []byte(strconv.FormatInt(time.Now().Add(duration).Unix(), 10))
+	if err := g.cache.Set(ctx, loginKeyPrefix+"locked:"+key, value, duration); err != nil {
+		return err
+	}
+	if err := g.cache.Delete(ctx, loginKeyPrefix+"failures:"+key); err != nil {
+		return err
+	}
+
+	g.lockouts.Add(1)
+	return nil
+}
+
+type loginSubject struct {
+	kind, key string
+	max       int
+}
+
+func (g *LoginGuard) subjects(email, ip string) []loginSubject {
+	return []loginSubject{
+		{kind: "email", key: "email:" + email, max: g.cfg.MaxAttempts},
+		{kind: "ip", key: "ip:" + ip, max: g.cfg.IPMaxAttempts},
+	}
+}
+
+func (g *LoginGuard) logError(ctx context.Context, err error) {
+	logger.ModuleContext(ctx, "auth").Warn().Err(err).Msg("Login lockout check failed")
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/shivaluma/eino-agent/config"
@@ -21,19 +22,24 @@ import (
 type OAuthService struct {
 	config    *config.Config
 	providers map[string]*oauth2.Config
+
+	// optionalScopes are the scopes users may grant later, per provider
+	optionalScopes map[string][]string
 }
 
 func NewOAuthService(cfg *config.Config) *OAuthService {
 	providers := make(map[string]*oauth2.Config)
+	optionalScopes := make(map[string][]string)
 
 	if cfg.OAuth.GitHub.Enabled {
 		providers["github"] = &oauth2.Config{
 			ClientID:     cfg.OAuth.GitHub.ClientID,
 			ClientSecret: cfg.OAuth.GitHub.ClientSecret,
 			RedirectURL:  cfg.OAuth.GitHub.RedirectURL,
-			Scopes:       []string{"user:email"},
+			Scopes:       cfg.OAuth.GitHub.Scopes,
 			Endpoint:     github.Endpoint,
 		}
+		optionalScopes["github"] = cfg.OAuth.GitHub.OptionalScopes
 	}
 
 	if cfg.OAuth.Google.Enabled {
@@ -41,17 +47,16 @@ func NewOAuthService(cfg *config.Config) *OAuthService {
 			ClientID:     cfg.OAuth.Google.ClientID,
 			ClientSecret: cfg.OAuth.Google.ClientSecret,
 			RedirectURL:  cfg.OAuth.Google.RedirectURL,
-			Scopes: []string{
-				"https://www.googleapis.com/auth/userinfo.email",
-				"https://www.googleapis.com/auth/userinfo.profile",
-			},
-			Endpoint: google.Endpoint,
+			Scopes:       cfg.OAuth.Google.Scopes,
+			Endpoint:     google.Endpoint,
 		}
+		optionalScopes["google"] = cfg.OAuth.Google.OptionalScopes
 	}
 
 	return &OAuthService{
-		config:    cfg,
-		providers: providers,
+		config:         cfg,
+		providers:      providers,
+		optionalScopes: optionalScopes,
 	}
 }
 
@@ -95,11 +100,48 @@ func (s *OAuthService) GetAuthURL(provider, state string, opts ...oauth2.AuthCod
 	if provider == "google" {
 		opts = append(opts, oauth2.SetAuthURLParam("prompt", "select_account"))
 		opts = append(opts, oauth2.AccessTypeOffline)
+		// Tokens keep the scopes granted before, as GitHub's do
+		opts = append(opts, oauth2.SetAuthURLParam("include_granted_scopes", "true"))
 	}
 
 	return cfg.AuthCodeURL(state, opts...), nil
 }
 
+// WithScopes requests scopes in place of the provider's sign-in scopes
+func WithScopes(scopes []string) oauth2.AuthCodeOption {
+	return oauth2.SetAuthURLParam("scope", strings.Join(scopes, " "))
+}
+
+// Scopes returns the scopes requested at sign-in with a provider
+func (s *OAuthService) Scopes(provider string) []string {
+	if cfg, exists := s.providers[provider]; exists {
+		return cfg.Scopes
+	}
+	return nil
+}
+
+// OptionalScopes returns the scopes users may grant a provider's linked
+// account later
+func (s *OAuthService) OptionalScopes(provider string) []string {
+	return s.optionalScopes[provider]
+}
+
+// GrantedScopes returns the scopes a token was granted, sorted. Providers
+// report them with the token, GitHub comma-separated and Google
+// space-separated; without them the requested scopes are assumed.
+func GrantedScopes(token *oauth2.Token, requested []string) []string {
+	granted := requested
+	if reported, _ := token.Extra("scope").(string); strings.TrimSpace(reported) != "" {
+		granted = strings.FieldsFunc(reported, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	}
+
+	scopes := slices.Clone(granted)
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
+
 // ExchangeCode exchanges the authorization code for tokens
 func (s *OAuthService) ExchangeCode(ctx context.Context, provider, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
 	cfg, exists := s.providers[provider]
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// OrgIDHeader selects the organization a request acts in. Without it
+// requests act in the user's personal space.
+const OrgIDHeader = "X-Org-ID"
+
+// OrgScope is the organization a request acts in and the user's role in it
+type OrgScope struct {
+	OrgID uuid.UUID
+	Role  string
+}
+
+type orgScopeKey struct{}
+
+// WithOrgScope returns ctx acting in the organization of scope
+func WithOrgScope(ctx context.Context, scope *OrgScope) context.Context {
+	return context.WithValue(ctx, orgScopeKey{}, scope)
+}
+
+// OrgScopeFromContext returns the organization the request acts in, or nil
+// in the user's personal space
+func OrgScopeFromContext(ctx context.Context) *OrgScope {
+	scope, _ := ctx.Value(orgScopeKey{}).(*OrgScope)
+	return scope
+}
+
+// OrgIDFromContext returns the ID of the organization the request acts in,
+// or nil in the user's personal space
+func OrgIDFromContext(ctx context.Context) *uuid.UUID {
+	if scope := OrgScopeFromContext(ctx); scope != nil {
+		return &scope.OrgID
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/cache"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const oauthStateKeyPrefix = "oauth:state:"
+
+// StateStore keeps short-lived OAuth states in the cache instead of the database
+type StateStore struct {
+	cache cache.Cache
+}
+
+// NewStateStore creates a new OAuth state store
+func NewStateStore(c cache.Cache) *StateStore {
+	return &StateStore{cache: c}
+}
+
+// Store saves an OAuth state until it expires
+func (s *StateStore) Store(ctx context.Context, state *models.OAuthState) error {
+	ttl := time.Until(state.ExpiresAt)
+	if ttl <= 0 {
+		return fmt.Errorf("OAuth state already expired")
+	}
+
+	if state.CreatedAt.IsZero() {
+		state.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(&oauthStatePayload{
+		State:        state.State,
+		Provider:     state.Provider,
+		CodeVerifier: state.CodeVerifier,
+		RedirectURI:  state.RedirectURI,
+		ExpiresAt:    state.ExpiresAt,
+		CreatedAt:    state.CreatedAt,
+		UserID:       state.UserID,
+		Scopes:       state.Scopes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode OAuth state: %w", err)
+	}
+
+	if err := s.cache.Set(ctx, oauthStateKeyPrefix+state.State, data, ttl); err != nil {
+		return fmt.Errorf("failed to store OAuth state: %w", err)
+	}
+
+	return nil
+}
+
+// Consume retrieves and deletes an OAuth state so it can only be used once.
+// Returns nil if the state is unknown or expired.
+func (s *StateStore) Consume(ctx context.Context, state string) (*models.OAuthState, error) {
+	data, err := s.cache.Take(ctx, oauthStateKeyPrefix+state)
+	if err == cache.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OAuth state: %w", err)
+	}
+
+	var oauthState oauthStatePayload
+	if err := json.Unmarshal(data, &oauthState); err != nil {
+		return nil, fmt.Errorf("failed to decode OAuth state: %w", err)
+	}
+
+	return oauthState.toModel(), nil
+}
+
+// oauthStatePayload mirrors models.OAuthState including the fields that are
+// hidden from JSON responses
+type oauthStatePayload struct {
+	State        string    `json:"state"`
+	Provider     string    `json:"provider"`
+	CodeVerifier *string   `json:"code_verifier,omitempty"`
+	RedirectURI  *string   `json:"redirect_uri,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	UserID *uuid.UUID `json:"user_id,omitempty"`
+	Scopes []string   `json:"scopes,omitempty"`
+}
+
+func (p *oauthStatePayload) toModel() *models.OAuthState {
+	return &models.OAuthState{
+		State:        p.State,
+		Provider:     p.Provider,
+		CodeVerifier: p.CodeVerifier,
+		RedirectURI:  p.RedirectURI,
+		ExpiresAt:    p.ExpiresAt,
+		CreatedAt:    p.CreatedAt,
+		UserID:       p.UserID,
+		Scopes:       p.Scopes,
+	}
+}
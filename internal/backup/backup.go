@@ -0,0 +1,348 @@
+// Package backup exports the application data to an archive and imports it
+// into another database, for moving an installation between environments.
+// An archive is a gzipped tar of a manifest followed by one JSON Lines file
+// per table, and is only restored into an empty database migrated to the
+// schema version it was taken at.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FormatVersion is the version of the archive layout. Archives of another
+// version are refused.
+const FormatVersion = 1
+
+// Tables holds the backed-up tables, each after the tables it references,
+// which is the order they are restored in. Organizations and participants
+// come along because conversations can't be restored or listed without
+// them.
+var Tables = []string{
+	"users",
+	"oauth_accounts",
+	"organizations",
+	"organization_members",
+	"conversations",
+	"conversation_participants",
+	"messages",
+}
+
+// serialColumns are the columns whose sequences are moved past the
+// restored rows
+var serialColumns = map[string]string{
+	"messages": "id",
+}
+
+const manifestName = "manifest.json"
+
+// restoreBatchSize is how many rows are inserted per statement
+const restoreBatchSize = 500
+
+// maxRowSize bounds a single row in an archive
+const maxRowSize = 16 << 20
+
+// Manifest describes an archive
+type Manifest struct {
+	FormatVersion int `json:"format_version"`
+
+	// SchemaVersion is the migration version of the database the archive
+	// was taken from
+	SchemaVersion int64     `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// Tables counts the rows of each table
+	Tables map[string]int64 `json:"tables"`
+}
+
+// Backup writes an archive of the database, which is at schemaVersion, to
+// w. All tables are read from one snapshot.
+func Backup(ctx context.Context, pool *pgxpool.Pool, schemaVersion int64, w io.Writer) (*Manifest, error) {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin snapshot: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		SchemaVersion: schemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		Tables:        make(map[string]int64, len(Tables)),
+	}
+	for _, table := range Tables {
+		var count int64
+		if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM "+pgx.Identifier{table}.Sanitize()).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", table, err)
+		}
+		manifest.Tables[table] = count
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, manifestName, int64(len(data)), bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	for _, table := range Tables {
+		if err := backupTable(ctx, tx, tw, table); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// backupTable writes the rows of table as JSON Lines. Tar entries need
+// their size up front, so rows are spooled to a temporary file first.
+func backupTable(ctx context.Context, tx pgx.Tx, tw *tar.Writer, table string) error {
+	spool, err := os.CreateTemp("", "backup-"+table+"-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	ident := pgx.Identifier{table}.Sanitize()
+	rows, err := tx.Query(ctx, "SELECT to_jsonb(t)::TEXT FROM "+ident+" t")
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	buf := bufio.NewWriter(spool)
+	var size int64
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		n, err := buf.WriteString(row + "\n")
+		if err != nil {
+			return fmt.Errorf("failed to spool %s: %w", table, err)
+		}
+		size += int64(n)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to spool %s: %w", table, err)
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to spool %s: %w", table, err)
+	}
+	return writeEntry(tw, table+".jsonl", size, spool)
+}
+
+func writeEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// ReadManifest returns the manifest of the archive in r without restoring
+// it
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	tr, err := openArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	return readManifest(tr)
+}
+
+// Restore imports the archive in r into the database, which must be at
+// schemaVersion and hold none of the backed-up data. Everything is
+// restored in one transaction, so a failed restore leaves nothing behind.
+func Restore(ctx context.Context, pool *pgxpool.Pool, schemaVersion int64, r io.Reader) (*Manifest, error) {
+	tr, err := openArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("archive format version %d is not supported, expected %d", manifest.FormatVersion, FormatVersion)
+	}
+	if manifest.SchemaVersion != schemaVersion {
+		return nil, fmt.Errorf("archive was taken at schema version %d but the database is at %d; migrate the database to the same version first", manifest.SchemaVersion, schemaVersion)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin restore: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range Tables {
+		var exists bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM "+pgx.Identifier{table}.Sanitize()+")").Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to check %s: %w", table, err)
+		}
+		if exists {
+			return nil, fmt.Errorf("table %s is not empty; restore into a freshly migrated database", table)
+		}
+	}
+
+	// Entries must come in the order of Tables, so references resolve
+	next := 0
+	restored := make(map[string]bool, len(Tables))
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		table := strings.TrimSuffix(header.Name, ".jsonl")
+		for next < len(Tables) && Tables[next] != table {
+			next++
+		}
+		if next == len(Tables) {
+			return nil, fmt.Errorf("unexpected archive entry %s", header.Name)
+		}
+
+		count, err := restoreTable(ctx, tx, table, tr)
+		if err != nil {
+			return nil, err
+		}
+		if count != manifest.Tables[table] {
+			return nil, fmt.Errorf("archive holds %d rows of %s but its manifest lists %d", count, table, manifest.Tables[table])
+		}
+		restored[table] = true
+		next++
+	}
+	for _, table := range Tables {
+		if manifest.Tables[table] > 0 && !restored[table] {
+			return nil, fmt.Errorf("archive is missing the rows of %s", table)
+		}
+	}
+
+	for table, column := range serialColumns {
+		query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+			table, column, pgx.Identifier{column}.Sanitize(), pgx.Identifier{table}.Sanitize())
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return nil, fmt.Errorf("failed to reset the sequence of %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return manifest, nil
+}
+
+func openArchive(r io.Reader) (*tar.Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	return tar.NewReader(gz), nil
+}
+
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if header.Name != manifestName {
+		return nil, fmt.Errorf("archive doesn't start with %s", manifestName)
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// restoreTable inserts the JSON Lines rows of table from r in batches and
+// returns how many it inserted
+func restoreTable(ctx context.Context, tx pgx.Tx, table string, r io.Reader) (int64, error) {
+	ident := pgx.Identifier{table}.Sanitize()
+	query := "INSERT INTO " + ident + " SELECT * FROM jsonb_populate_recordset(NULL::" + ident + ", $1::JSONB)"
+
+	var (
+		count int64
+		batch bytes.Buffer
+		rows  int
+	)
+	flush := func() error {
+		if rows == 0 {
+			return nil
+		}
+		batch.WriteByte(']')
+		if _, err := tx.Exec(ctx, query, batch.String()); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", table, err)
+		}
+		count += int64(rows)
+		batch.Reset()
+		rows = 0
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxRowSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if rows == 0 {
+			batch.WriteByte('[')
+		} else {
+			batch.WriteByte(',')
+		}
+		batch.Write(line)
+		rows++
+
+		if rows == restoreBatchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	return count, flush()
+}
@@ -0,0 +1,50 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// MonthStart returns the start of t's calendar month in UTC, when monthly
+// quotas reset
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ErrQuotaExceeded is returned for messages to the conversations of an
+// organization that used up this month's token quota
+var ErrQuotaExceeded = errors.New("organization exceeded its monthly token quota")
+
+// CheckOrgQuota returns ErrQuotaExceeded when the organization used up this
+// month's token quota. Personal conversations, with a nil orgID, have no
+// quota. Usage is recorded after a reply, so the last reply may overshoot
+// the quota.
+func (r *Recorder) CheckOrgQuota(ctx context.Context, orgID *uuid.UUID) error {
+	if orgID == nil {
+		return nil
+	}
+	exceeded, err := r.repo.OrgQuotaExceeded(ctx, *orgID, MonthStart(time.Now()))
+	if err != nil {
+		return err
+	}
+	if exceeded {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// OrgUsage returns the organization's usage this month against its quota
+func (r *Recorder) OrgUsage(ctx context.Context, org *models.Organization) (*models.OrgUsage, error) {
+	month := MonthStart(time.Now())
+	totals, err := r.repo.OrgTotals(ctx, org.ID, month, month.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	return &models.OrgUsage{Month: month, UsageTotals: totals, MonthlyTokenQuota: org.MonthlyTokenQuota}, nil
+}
@@ -0,0 +1,56 @@
+// Package billing records the token usage and cost of every AI reply so it
+// can be reported and billed per user.
+package billing
+
+import (
+	"context"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Recorder stores usage records
+type Recorder struct {
+	repo *repository.UsageRepository
+}
+
+func NewRecorder(repo *repository.UsageRepository) *Recorder {
+	return &Recorder{repo: repo}
+}
+
+// Record charges the usage of response to userID, whose message it
+// answers. messageID is the saved reply, or 0 if saving it failed: the
+// tokens were spent either way. Failures are logged rather than returned
+// since the reply has already been produced.
+func (r *Recorder) Record(ctx context.Context, userID, conversationID uuid.UUID, messageID int64, response *ai.ChatResponse) {
+	record := &models.UsageRecord{
+		UserID:         userID,
+		ConversationID: &conversationID,
+		Provider:       response.Provider,
+		Model:          response.Model,
+		OwnKey:         response.OwnKey,
+	}
+	if messageID != 0 {
+		record.MessageID = &messageID
+	}
+	if response.Usage != nil {
+		record.PromptTokens = response.Usage.PromptTokens
+		record.CompletionTokens = response.Usage.CompletionTokens
+		record.TotalTokens = response.Usage.TotalTokens
+	}
+	if response.Priced {
+		cost := response.CostMicros
+		record.CostMicros = &cost
+	}
+
+	if err := r.repo.Create(ctx, record); err != nil {
+		logger.ModuleContext(ctx, "billing").Error().Err(err).
+			Str("user_id", userID.String()).
+			Str("conversation_id", conversationID.String()).
+			Msg("Failed to record usage")
+	}
+}
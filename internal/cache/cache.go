@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+// ErrNotFound is returned when a key does not exist or has expired
+var ErrNotFound = errors.New("cache: key not found")
+
+// Cache defines a key-value store with expiration
+type Cache interface {
+	// Get returns the value stored at key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set stores value at key. A zero ttl means the key never expires.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Take returns the value stored at key and deletes it atomically
+	Take(ctx context.Context, key string) ([]byte, error)
+
+	// Delete removes key
+	Delete(ctx context.Context, key string) error
+
+	// Exists reports whether key is present
+	Exists(ctx context.Context, key string) (bool, error)
+
+	// Incr increments the counter at key, setting ttl when the key is created
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+
+	// Ping checks connectivity to the backing store
+	Ping(ctx context.Context) error
+
+	// Close releases resources held by the cache
+	Close() error
+}
+
+// New creates the cache selected by STATE_BACKEND. The in-memory cache only
+// works for a single instance; use Redis when running multiple replicas.
+func New(cfg *config.Config) (Cache, error) {
+	switch cfg.State.Backend {
+	case "memory":
+		logger.Logger.Info().Msg("Using in-memory cache")
+		return NewMemory(), nil
+	case "redis":
+		if cfg.Redis.URL == "" {
+			return nil, fmt.Errorf("STATE_BACKEND=redis requires REDIS_URL")
+		}
+
+		c, err := NewRedis(cfg.Redis.URL, cfg.Redis.KeyPrefix)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Logger.Info().Msg("Redis cache connected")
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown state backend: %s", cfg.State.Backend)
+	}
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type memoryItem struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (i memoryItem) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && now.After(i.expiresAt)
+}
+
+// Memory is an in-process Cache implementation. It is only suitable for
+// single-instance deployments and local development.
+type Memory struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+	stop  chan struct{}
+	once  sync.Once
+}
+
+// NewMemory creates an in-memory cache
+func NewMemory() *Memory {
+	m := &Memory{
+		items: make(map[string]memoryItem),
+		stop:  make(chan struct{}),
+	}
+	go m.janitor(time.Minute)
+	return m
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.items[key]
+	if !ok || item.expired(time.Now()) {
+		delete(m.items, key)
+		return nil, ErrNotFound
+	}
+
+	return item.value, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item := memoryItem{value: value}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+	m.items[key] = item
+
+	return nil
+}
+
+func (m *Memory) Take(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.items[key]
+	delete(m.items, key)
+	if !ok || item.expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+
+	return item.value, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.items, key)
+	return nil
+}
+
+func (m *Memory) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := m.Get(ctx, key)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (m *Memory) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	item, ok := m.items[key]
+	if !ok || item.expired(now) {
+		item = memoryItem{value: []byte("0")}
+		if ttl > 0 {
+			item.expiresAt = now.Add(ttl)
+		}
+	}
+
+	count, err := strconv.ParseInt(string(item.value), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	count++
+
+	item.value = []byte(strconv.FormatInt(count, 10))
+	m.items[key] = item
+
+	return count, nil
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	m.once.Do(func() { close(m.stop) })
+	return nil
+}
+
+// janitor periodically removes expired items
+func (m *Memory) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			m.mu.Lock()
+			for key, item := range m.items {
+				if item.expired(now) {
+					delete(m.items, key)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
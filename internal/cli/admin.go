@@ -0,0 +1,545 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/backup"
+	"github.com/shivaluma/eino-agent/internal/cache"
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/migrations"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+func adminCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Manage users, usage and data of the server without SQL",
+	}
+	cmd.AddCommand(
+		createAdminUserCmd(),
+		resetPasswordCmd(),
+		revokeTokensCmd(),
+		recomputeUsageCmd(),
+		backupCmd(),
+		restoreCmd(),
+		inspectCmd(),
+	)
+	return cmd
+}
+
+// admin holds the configuration and repositories the admin commands share
+type admin struct {
+	*env
+	db    *database.DB
+	users *repository.UserRepository
+	audit *repository.AuditRepository
+}
+
+// connect loads the configuration and connects to the database
+func connect(ctx context.Context) (*admin, error) {
+	env, err := setup(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.New(env.cfg)
+	if err != nil {
+		env.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return &admin{
+		env:   env,
+		db:    db,
+		users: repository.NewUserRepository(db),
+		audit: repository.NewAuditRepository(db),
+	}, nil
+}
+
+func (a *admin) Close() {
+	a.db.Close()
+	a.env.Close()
+}
+
+// user looks up a user by email
+func (a *admin) user(ctx context.Context, email string) (*models.User, error) {
+	user, err := a.users.GetByEmail(ctx, normalizeEmail(email))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("no user with email %s", email)
+	}
+	return user, nil
+}
+
+// record adds an action taken from the CLI to the audit log. Failing to
+// record doesn't undo the action, so it is only reported.
+func (a *admin) record(ctx context.Context, action string, userID uuid.UUID, metadata map[string]interface{}) {
+	metadata["source"] = "cli"
+	data, _ := json.Marshal(metadata)
+	event := &models.AuditEvent{UserID: &userID, Action: action, Success: true, Metadata: data}
+	if err := a.audit.Create(ctx, event); err != nil {
+		logger.Logger.Warn().Err(err).Str("action", action).Msg("Failed to record audit event")
+	}
+}
+
+// revokeTokens ends the sessions of the user and revokes the access tokens
+// already issued. Those are revoked in the cache the server checks, so the
+// revocation only reaches the server through a shared Redis.
+func (a *admin) revokeTokens(ctx context.Context, userID uuid.UUID) error {
+	if err := a.users.InvalidateUserRefreshTokens(ctx, userID); err != nil {
+		return fmt.Errorf("failed to end sessions: %w", err)
+	}
+
+	if a.cfg.State.Backend != "redis" {
+		fmt.Printf("Note: STATE_BACKEND=%s is local to each process, so issued access tokens stay valid until they expire (at most %s)\n",
+			a.cfg.State.Backend, a.cfg.JWT.AccessExpiration)
+		return nil
+	}
+
+	c, err := cache.New(a.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to cache: %w", err)
+	}
+	defer c.Close()
+
+	authSvc, err := auth.NewService(a.cfg, c)
+	if err != nil {
+		return err
+	}
+	return authSvc.RevokeUserAccessTokens(ctx, userID)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// newPassword returns the password given with --password, or a random one
+// that is printed once
+func newPassword(password string) (string, bool) {
+	if password != "" {
+		return password, false
+	}
+	return rand.Text(), true
+}
+
+func (a *admin) hashPassword(password string) (string, error) {
+	// Hashing doesn't touch the cache, so a local one does
+	authSvc, err := auth.NewService(a.cfg, cache.NewMemory())
+	if err != nil {
+		return "", err
+	}
+	return authSvc.HashPassword(password)
+}
+
+func createAdminUserCmd() *cobra.Command {
+	var email, username, password string
+
+	cmd := &cobra.Command{
+		Use:   "create-admin-user",
+		Short: "Create an admin user, or make an existing user an admin",
+		Long: `Create an admin user with a password. Without --password a random one is
+generated and printed. A user that already has the email is made an admin
+and keeps their password.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			password, generated := newPassword(password)
+
+			req := models.UserRegisterRequest{
+				Name:     strings.TrimSpace(username),
+				Email:    normalizeEmail(email),
+				Password: password,
+			}
+			if err := validator.New().Struct(&req); err != nil {
+				return fmt.Errorf("invalid user: %w", err)
+			}
+
+			a, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer a.Close()
+
+			existing, err := a.users.GetByEmail(ctx, req.Email)
+			if err != nil {
+				return fmt.Errorf("failed to look up user: %w", err)
+			}
+			if existing != nil {
+				if err := a.users.SetAdmin(ctx, existing.ID, true); err != nil {
+					return fmt.Errorf("failed to make user an admin: %w", err)
+				}
+				a.record(ctx, models.AuditActionAdminUserCreate, existing.ID, map[string]interface{}{"existing": true})
+				fmt.Printf("✓ %s already exists and is now an admin (ID %s)\n", req.Email, existing.ID)
+				return nil
+			}
+
+			hash, err := a.hashPassword(req.Password)
+			if err != nil {
+				return err
+			}
+			user := &models.User{
+				Username:     req.Name,
+				Email:        req.Email,
+				PasswordHash: &hash,
+			}
+			err = repository.NewTransactor(a.db).WithTx(ctx, func(ctx context.Context) error {
+				if err := a.users.Create(ctx, user); err != nil {
+					return fmt.Errorf("failed to create user: %w", err)
+				}
+				if err := a.users.SetAdmin(ctx, user.ID, true); err != nil {
+					return fmt.Errorf("failed to make user an admin: %w", err)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			a.record(ctx, models.AuditActionAdminUserCreate, user.ID, map[string]interface{}{"existing": false})
+
+			fmt.Printf("✓ Created admin %s (ID %s)\n", user.Email, user.ID)
+			if generated {
+				fmt.Printf("Password: %s\n", password)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&email, "email", "", "Email of the admin")
+	cmd.Flags().StringVar(&username, "username", "", "Display name of the admin")
+	cmd.Flags().StringVar(&password, "password", "", "Password of the admin (default: generated)")
+	cmd.MarkFlagRequired("email")
+	cmd.MarkFlagRequired("username")
+	return cmd
+}
+
+func resetPasswordCmd() *cobra.Command {
+	var password string
+
+	cmd := &cobra.Command{
+		Use:   "reset-password EMAIL",
+		Short: "Set a user's password and sign them out everywhere",
+		Long: `Set a user's password. Without --password a random one is generated and
+printed. The user's sessions end and their access tokens are revoked, as
+after revoke-tokens.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			password, generated := newPassword(password)
+			if len(password) < 8 {
+				return fmt.Errorf("password must be at least 8 characters")
+			}
+
+			a, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer a.Close()
+
+			user, err := a.user(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			hash, err := a.hashPassword(password)
+			if err != nil {
+				return err
+			}
+			if err := a.users.SetPassword(ctx, user.ID, hash); err != nil {
+				return fmt.Errorf("failed to set password: %w", err)
+			}
+			// The reset is an admin action, and a password change like any
+			// other on the user's account
+			a.record(ctx, models.AuditActionAdminPasswordReset, user.ID, map[string]interface{}{})
+			a.record(ctx, models.AuditActionPasswordChange, user.ID, map[string]interface{}{"reset": true})
+
+			if err := a.revokeTokens(ctx, user.ID); err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ Reset the password of %s and signed them out\n", user.Email)
+			if generated {
+				fmt.Printf("Password: %s\n", password)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&password, "password", "", "New password (default: generated)")
+	return cmd
+}
+
+func revokeTokensCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke-tokens EMAIL",
+		Short: "Sign a user out everywhere",
+		Long: `End every session of a user and revoke the access tokens already issued to
+them. Access tokens are revoked in Redis; with STATE_BACKEND=memory they
+stay valid until they expire.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			a, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer a.Close()
+
+			user, err := a.user(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if err := a.revokeTokens(ctx, user.ID); err != nil {
+				return err
+			}
+			a.record(ctx, models.AuditActionAdminTokenRevoke, user.ID, map[string]interface{}{})
+
+			fmt.Printf("✓ Signed %s out everywhere\n", user.Email)
+			return nil
+		},
+	}
+}
+
+func recomputeUsageCmd() *cobra.Command {
+	var from, to string
+
+	cmd := &cobra.Command{
+		Use:   "recompute-usage",
+		Short: "Recompute the cost of recorded usage from the current prices",
+		Long: `Recompute the cost of usage records from the built-in prices and
+AI_PRICING_FILE, after prices were corrected or added. Records of models
+that no longer have a price lose their cost. --from and --to (exclusive)
+take dates in UTC and default to all records.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			start, end := time.Time{}, time.Now().Add(24*time.Hour)
+			var err error
+			if from != "" {
+				if start, err = time.Parse(time.DateOnly, from); err != nil {
+					return fmt.Errorf("invalid --from, use YYYY-MM-DD: %w", err)
+				}
+			}
+			if to != "" {
+				if end, err = time.Parse(time.DateOnly, to); err != nil {
+					return fmt.Errorf("invalid --to, use YYYY-MM-DD: %w", err)
+				}
+			}
+
+			a, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer a.Close()
+
+			pricing, err := ai.LoadPricing(a.cfg.AI.PricingFile)
+			if err != nil {
+				return err
+			}
+
+			usage := repository.NewUsageRepository(a.db)
+			usageModels, err := usage.Models(ctx, start, end)
+			if err != nil {
+				return err
+			}
+
+			var total int64
+			for _, m := range usageModels {
+				var changed int64
+				price, ok := pricing.Lookup(m.Provider, m.Model)
+				if ok {
+					changed, err = usage.Reprice(ctx, m.Provider, m.Model, start, end, price.InputPerMillion, price.OutputPerMillion)
+				} else {
+					changed, err = usage.Unprice(ctx, m.Provider, m.Model, start, end)
+				}
+				if err != nil {
+					return err
+				}
+
+				status := "priced"
+				if !ok {
+					status = "no price"
+				}
+				fmt.Printf("  %-40s %-8s %d records changed\n", m.Provider+"/"+m.Model, status, changed)
+				total += changed
+			}
+
+			fmt.Printf("✓ Recomputed %d models, %d records changed\n", len(usageModels), total)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "First day to recompute (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "Day to stop before (YYYY-MM-DD)")
+	return cmd
+}
+
+// schemaVersion returns the migration version of the database. Archives
+// record it so they are only restored into a database with the same
+// tables.
+func (a *admin) schemaVersion(ctx context.Context) (int64, error) {
+	migrator := migrations.NewMigrator(a.db.Pool, migrations.Source(a.cfg.Database.MigrationsDir), a.cfg)
+	version, err := migrator.GetCurrentVersion(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, nil
+}
+
+func backupCmd() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Export users, conversations, messages and OAuth accounts to an archive",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			a, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer a.Close()
+
+			version, err := a.schemaVersion(ctx)
+			if err != nil {
+				return err
+			}
+
+			// Archives hold password hashes and OAuth tokens
+			f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if err != nil {
+				return fmt.Errorf("failed to create archive: %w", err)
+			}
+			manifest, err := backup.Backup(ctx, a.db.Pool, version, f)
+			if err == nil {
+				err = f.Close()
+			}
+			if err != nil {
+				f.Close()
+				os.Remove(file)
+				return fmt.Errorf("backup failed: %w", err)
+			}
+
+			fmt.Printf("✓ Backup written to %s\n", file)
+			printManifest(manifest)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&file, "file", "", "Archive to write")
+	cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+func restoreCmd() *cobra.Command {
+	var (
+		file    string
+		confirm bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Import an archive into a freshly migrated database",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if !confirm {
+				fmt.Println("⚠ WARNING: This will import the archive's users, conversations and messages into the database.")
+				fmt.Println("The database must be freshly migrated to the archive's schema version.")
+				fmt.Println("To confirm, add the --confirm flag:")
+				fmt.Printf("  eino-agent admin restore --file=%s --confirm\n", file)
+				return fmt.Errorf("restore not confirmed")
+			}
+
+			a, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer a.Close()
+
+			version, err := a.schemaVersion(ctx)
+			if err != nil {
+				return err
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				return fmt.Errorf("failed to open archive: %w", err)
+			}
+			defer f.Close()
+
+			manifest, err := backup.Restore(ctx, a.db.Pool, version, f)
+			if err != nil {
+				return fmt.Errorf("restore failed: %w", err)
+			}
+
+			fmt.Printf("✓ Restored %s\n", file)
+			printManifest(manifest)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&file, "file", "", "Archive to restore")
+	cmd.Flags().BoolVar(&confirm, "confirm", false, "Confirm restoring into the database")
+	cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+func inspectCmd() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Show an archive's versions and row counts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Inspect only reads the archive
+			f, err := os.Open(file)
+			if err != nil {
+				return fmt.Errorf("failed to open archive: %w", err)
+			}
+			defer f.Close()
+
+			manifest, err := backup.ReadManifest(f)
+			if err != nil {
+				return fmt.Errorf("failed to read archive: %w", err)
+			}
+			printManifest(manifest)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&file, "file", "", "Archive to read")
+	cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+func printManifest(manifest *backup.Manifest) {
+	fmt.Printf("Format version: %d\n", manifest.FormatVersion)
+	fmt.Printf("Schema version: %d\n", manifest.SchemaVersion)
+	fmt.Printf("Created at:     %s\n", manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+
+	tables := make([]string, 0, len(manifest.Tables))
+	for table := range manifest.Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Printf("  %-26s %d rows\n", table, manifest.Tables[table])
+	}
+}
@@ -0,0 +1,415 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/ai/providers"
+	"github.com/shivaluma/eino-agent/internal/ai/templates"
+	"github.com/shivaluma/eino-agent/internal/billing"
+	"github.com/shivaluma/eino-agent/internal/cache"
+	"github.com/shivaluma/eino-agent/internal/convlock"
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/events"
+	"github.com/shivaluma/eino-agent/internal/guardrails"
+	"github.com/shivaluma/eino-agent/internal/imports"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/mcp"
+	"github.com/shivaluma/eino-agent/internal/memory"
+	"github.com/shivaluma/eino-agent/internal/migrations"
+	"github.com/shivaluma/eino-agent/internal/outbox"
+	"github.com/shivaluma/eino-agent/internal/pubsub"
+	"github.com/shivaluma/eino-agent/internal/queue"
+	"github.com/shivaluma/eino-agent/internal/reminders"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/retention"
+	"github.com/shivaluma/eino-agent/internal/scheduler"
+	"github.com/shivaluma/eino-agent/internal/secretbox"
+	"github.com/shivaluma/eino-agent/internal/security"
+	"github.com/shivaluma/eino-agent/internal/storage"
+	"github.com/shivaluma/eino-agent/internal/titles"
+)
+
+// jobPurgeDeletedMessages is the scheduler job that hard-deletes messages
+// soft-deleted longer than MESSAGE_PURGE_AFTER ago
+const jobPurgeDeletedMessages = "purge_deleted_messages"
+
+// jobDeleteExpiredConversations is the scheduler job that deletes
+// conversations inactive for longer than their retention
+const jobDeleteExpiredConversations = "delete_expired_conversations"
+
+// jobRunScheduledPrompts is the scheduler job that runs due scheduled
+// prompts
+const jobRunScheduledPrompts = "run_scheduled_prompts"
+
+// jobExpireOrgInvitations is the scheduler job that marks organization
+// invitations past ORG_INVITE_TTL expired
+const jobExpireOrgInvitations = "expire_org_invitations"
+
+// jobRetryMessageWrites is the scheduler job that retries saving replies
+// and conversation updates that failed
+const jobRetryMessageWrites = "retry_message_writes"
+
+// jobProbeAIProviders is the scheduler job that probes AI provider
+// endpoints, opening the circuit of unreachable ones
+const jobProbeAIProviders = "probe_ai_providers"
+
+// app holds the services the API server and the worker share: storage, the
+// AI service and the background jobs
+type app struct {
+	*env
+
+	db       *database.DB
+	migrator *migrations.Migrator
+	cache    cache.Cache
+	files    storage.Store
+
+	userRepo        *repository.UserRepository
+	convRepo        *repository.ConversationRepository
+	auditRepo       *repository.AuditRepository
+	settingsRepo    *repository.SettingsRepository
+	uploadRepo      *repository.UploadRepository
+	shareRepo       *repository.ShareRepository
+	participantRepo *repository.ParticipantRepository
+	transactor      *repository.Transactor
+	feedbackRepo    *repository.FeedbackRepository
+	scheduleRepo    *repository.ScheduleRepository
+	memoryRepo      *repository.MemoryRepository
+	usageRepo       *repository.UsageRepository
+	orgRepo         *repository.OrganizationRepository
+	statsRepo       *repository.StatsRepository
+	importRepo      *repository.ImportRepository
+
+	factory        *providers.Factory
+	chatModels     []ai.NamedModel
+	orgCredentials *providers.OrgCredentials
+	guard          *guardrails.Guard
+	pricing        *ai.Pricing
+	aiMetrics      *ai.Metrics
+	aiBreakers     *ai.Breakers
+	aiQueue        *ai.Queue
+	aiService      ai.Service
+
+	pubsub            pubsub.PubSub
+	eventBus          events.Bus
+	conversationLocks convlock.Locker
+	tasks             queue.Queue
+	titles            *titles.Titler
+	usageRecorder     *billing.Recorder
+	messageOutbox     *outbox.Outbox
+	memories          *memory.Store
+	retention         *retention.Enforcer
+	importer          *imports.Importer
+
+	// jobs runs the background jobs once started, and tasks the queued
+	// tasks once consumed
+	jobs *scheduler.Scheduler
+
+	// closers release what the app holds, in reverse order
+	closers []func()
+}
+
+// newApp connects to the database, migrates it and sets up the shared
+// services. Background work, such as database stats, config reloads and
+// secret refreshes, runs until ctx is done.
+func newApp(ctx context.Context, env *env) (*app, error) {
+	cfg := env.cfg
+	a := &app{env: env}
+
+	db, err := database.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	a.db = db
+	a.closers = append(a.closers, db.Close)
+
+	go db.ReportStats(ctx, cfg.Database.StatsInterval)
+
+	// Migrations take a lock, so the server and workers can all run them
+	logger.Logger.Info().Msg("Running database migrations...")
+	a.migrator = migrations.NewMigrator(db.Pool, migrations.Source(cfg.Database.MigrationsDir), cfg)
+	if err := a.migrator.Migrate(ctx); err != nil {
+		a.Close()
+		return nil, fmt.Errorf("failed to run database migrations: %w", err)
+	}
+	logger.Logger.Info().Msg("Database migrations completed successfully")
+
+	appCache, err := cache.New(cfg)
+	if err != nil {
+		a.Close()
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+	a.cache = appCache
+	a.closers = append(a.closers, func() { appCache.Close() })
+
+	// Workers read stored imports, so they share the file storage
+	files, err := storage.New(ctx, cfg)
+	if err != nil {
+		a.Close()
+		return nil, fmt.Errorf("failed to initialize file storage: %w", err)
+	}
+	a.files = files
+
+	a.userRepo = repository.NewUserRepository(db)
+	a.convRepo = repository.NewConversationRepository(db)
+	a.auditRepo = repository.NewAuditRepository(db)
+	a.settingsRepo = repository.NewSettingsRepository(db)
+	a.uploadRepo = repository.NewUploadRepository(db)
+	a.shareRepo = repository.NewShareRepository(db)
+	a.participantRepo = repository.NewParticipantRepository(db)
+	a.transactor = repository.NewTransactor(db)
+	a.feedbackRepo = repository.NewFeedbackRepository(db)
+	a.scheduleRepo = repository.NewScheduleRepository(db)
+	a.memoryRepo = repository.NewMemoryRepository(db)
+	a.usageRepo = repository.NewUsageRepository(db)
+	a.orgRepo = repository.NewOrganizationRepository(db)
+	a.statsRepo = repository.NewStatsRepository(db)
+	a.importRepo = repository.NewImportRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	retentionRepo := repository.NewRetentionRepository(db)
+
+	if err := a.setupAI(ctx); err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	a.pubsub = pubsub.New(appCache, cfg.Redis.KeyPrefix)
+	a.closers = append(a.closers, func() { a.pubsub.Close() })
+	a.eventBus = events.NewBus(a.pubsub)
+	a.conversationLocks = convlock.New(appCache, cfg.Redis.KeyPrefix, convlock.Config{
+		Wait: cfg.Messages.LockWait,
+		TTL:  cfg.Messages.LockTTL,
+	})
+	a.tasks = queue.New(appCache, cfg.Redis.KeyPrefix, queue.Config{
+		Workers:     cfg.Queue.Workers,
+		MaxAttempts: cfg.Queue.MaxAttempts,
+		Backoff:     cfg.Queue.RetryBackoff,
+		Timeout:     cfg.Queue.TaskTimeout,
+	})
+	a.titles = titles.New(a.convRepo, a.participantRepo, a.aiService, appCache, a.eventBus, a.tasks)
+	a.usageRecorder = billing.NewRecorder(a.usageRepo)
+	a.messageOutbox = outbox.New(outboxRepo, a.convRepo, a.transactor, a.participantRepo, a.eventBus, outbox.Config{
+		BatchSize:   cfg.Messages.RetryBatchSize,
+		MaxAttempts: cfg.Messages.RetryMaxAttempts,
+	})
+	a.memories = memory.NewStore(a.memoryRepo, a.aiService, a.tasks, memory.Config{
+		Enabled:    cfg.Memory.Enabled,
+		MaxPerUser: cfg.Memory.MaxPerUser,
+	})
+	a.retention = retention.New(retentionRepo, a.convRepo, retention.Config{
+		ConversationDays: cfg.Retention.ConversationDays,
+		PurgeAfter:       cfg.Messages.PurgeAfter,
+		DryRun:           cfg.Retention.DryRun,
+	})
+	a.importer = imports.New(a.convRepo, a.transactor, a.importRepo, a.files, a.tasks)
+	a.addJobs()
+	a.addTasks()
+
+	a.watch(ctx)
+	return a, nil
+}
+
+// setupAI creates the chat models and the AI service
+func (a *app) setupAI(ctx context.Context) error {
+	cfg := a.cfg
+
+	a.factory = providers.NewFactory()
+	if cfg.AI.Provider != "" {
+		if err := a.factory.Select(providers.ProviderType(cfg.AI.Provider)); err != nil {
+			return err
+		}
+		if cfg.AI.Provider == string(providers.Mock) {
+			logger.Logger.Warn().Msg("AI_PROVIDER=mock: replies are scripted, no model is called")
+		}
+	}
+	chatModels, err := a.factory.CreateChatModels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create chat model: %w", err)
+	}
+	a.chatModels = chatModels
+
+	// Organizations can answer their conversations on their own API keys,
+	// kept encrypted
+	credentialsBox, err := secretbox.New(cfg.AI.CredentialsKey)
+	if err != nil {
+		return fmt.Errorf("failed to set up credentials encryption: %w", err)
+	}
+	a.orgCredentials = a.factory.EnableOrgCredentials(a.orgRepo, credentialsBox, cfg.AI.CredentialHosts)
+
+	if err := templates.LoadPersonas(a.runtime.Current().PersonasFile); err != nil {
+		return fmt.Errorf("failed to load personas: %w", err)
+	}
+
+	// Tools of the configured MCP servers are offered to the model
+	tools := ai.NewToolRegistry()
+	if cfg.MCP.ServersFile != "" {
+		servers, err := mcp.LoadServers(cfg.MCP.ServersFile)
+		if err != nil {
+			return fmt.Errorf("failed to load MCP servers: %w", err)
+		}
+		mcpServers := mcp.ConnectAll(ctx, servers, cfg.MCP.Timeout)
+		a.closers = append(a.closers, func() { mcpServers.Close() })
+		if err := tools.Register(ctx, mcpServers.Tools()...); err != nil {
+			return fmt.Errorf("failed to register MCP tools: %w", err)
+		}
+	}
+
+	// Guardrails screen user messages and tool results; the classifier,
+	// when enabled, is the AI service itself
+	a.guard, err = guardrails.New(guardrails.Config{
+		Enabled:          cfg.Guardrails.Enabled,
+		RulesFile:        cfg.Guardrails.RulesFile,
+		Classifier:       cfg.Guardrails.Classifier,
+		ClassifierAction: guardrails.Action(cfg.Guardrails.ClassifierAction),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load guardrail rules: %w", err)
+	}
+
+	// Prices turn token usage into costs for usage reports
+	a.pricing, err = ai.LoadPricing(cfg.AI.PricingFile)
+	if err != nil {
+		return fmt.Errorf("failed to load pricing: %w", err)
+	}
+
+	a.aiMetrics = ai.NewMetrics()
+	// Circuit breakers stop sending requests to a failing provider until
+	// it recovers
+	a.aiBreakers = ai.NewBreakers(ai.BreakerConfig{
+		Threshold: cfg.AI.BreakerThreshold,
+		Cooldown:  cfg.AI.BreakerCooldown,
+	})
+	a.factory.SetBreakers(a.aiBreakers)
+	a.aiQueue = ai.NewQueue(ai.QueueConfig{
+		Concurrency: cfg.AI.MaxConcurrency,
+		Depth:       cfg.AI.QueueDepth,
+		Timeout:     cfg.AI.QueueTimeout,
+	})
+	a.aiService = ai.NewService(chatModels, &ai.Config{
+		DefaultProvider: chatModels[0].Name,
+		DefaultModel:    a.runtime.Current().DefaultModel,
+		Temperature:     cfg.AI.Temperature,
+		TopP:            cfg.AI.TopP,
+		MaxTokens:       cfg.AI.MaxTokens,
+		Stop:            cfg.AI.Stop,
+		ContextStrategy: cfg.AI.ContextStrategy,
+		ContextWindow:   cfg.AI.ContextWindow,
+		Retry: &ai.RetryPolicy{
+			MaxRetries:     cfg.AI.MaxRetries,
+			InitialBackoff: cfg.AI.RetryBackoff,
+			MaxBackoff:     cfg.AI.RetryMaxBackoff,
+			Timeout:        cfg.AI.GenerationTimeout,
+			Failover:       cfg.AI.Failover,
+		},
+		Metrics:       a.aiMetrics,
+		Breakers:      a.aiBreakers,
+		Tools:         tools,
+		MaxToolRounds: cfg.AI.MaxToolRounds,
+		ContextFilter: a.guard.FilterContext,
+		Pricing:       a.pricing,
+		Queue:         a.aiQueue,
+		Resolver:      a.factory,
+	})
+	a.guard.SetClassifier(a.aiService)
+	return nil
+}
+
+// addJobs registers the background jobs. Soft-deleted messages are only
+// removed for good by the purge job, on its interval or when an admin runs
+// it.
+func (a *app) addJobs() {
+	cfg := a.cfg
+
+	a.jobs = scheduler.New()
+	a.jobs.Add(jobPurgeDeletedMessages, cfg.Messages.PurgeInterval, a.retention.PurgeDeletedMessages)
+	a.jobs.Add(jobDeleteExpiredConversations, cfg.Retention.Interval, a.retention.DeleteExpiredConversations)
+	promptRunner := reminders.NewRunner(a.scheduleRepo, a.convRepo, a.participantRepo, a.settingsRepo, a.aiService, a.eventBus, a.memories, a.guard, a.usageRecorder, a.conversationLocks, a.aiQueue, a.tasks, reminders.Config{
+		BatchSize:      cfg.Schedule.BatchSize,
+		Lease:          cfg.Schedule.Lease,
+		WebhookTimeout: cfg.Schedule.WebhookTimeout,
+		WebhookSecret:  cfg.Schedule.WebhookSecret,
+		RequireHTTPS:   cfg.IsProduction(),
+	})
+	a.jobs.Add(jobRetryMessageWrites, cfg.Messages.RetryInterval, a.messageOutbox.Retry)
+	a.jobs.Add(jobRunScheduledPrompts, cfg.Schedule.PollInterval, promptRunner.RunDue)
+	a.tasks.Handle(reminders.TaskWebhook, promptRunner.HandleWebhook)
+	a.jobs.Add(jobExpireOrgInvitations, cfg.Invite.ExpireInterval, func(ctx context.Context) (string, error) {
+		expired, err := a.orgRepo.ExpireInvitations(ctx)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("expired %d invitations", expired), nil
+	})
+	probeClient := &http.Client{Timeout: cfg.Server.HealthCheckTimeout}
+	a.jobs.Add(jobProbeAIProviders, cfg.AI.ProbeInterval, func(ctx context.Context) (string, error) {
+		failures := a.factory.Probe(ctx, probeClient)
+		for provider, err := range failures {
+			logger.Logger.Warn().Err(err).Str("provider", provider).Msg("AI provider probe failed")
+		}
+		return fmt.Sprintf("%d providers unreachable", len(failures)), nil
+	})
+}
+
+// addTasks registers the handlers of queued tasks. Whichever process
+// consumes the queue runs them, no matter which one queued them.
+func (a *app) addTasks() {
+	a.tasks.Handle(titles.TaskTitle, a.titles.Handle)
+	a.tasks.Handle(memory.TaskLearn, a.memories.HandleLearn)
+	a.tasks.Handle(security.TaskWebhook, security.NewWebhook(a.cfg.Security.WebhookURL).Deliver)
+	a.tasks.Handle(imports.TaskImport, a.importer.Handle)
+}
+
+// runBackground starts the background jobs and consumes queued tasks until
+// ctx is done
+func (a *app) runBackground(ctx context.Context) {
+	a.jobs.Start(ctx)
+	a.tasks.Consume(ctx)
+}
+
+// watch reloads the runtime configuration on SIGHUP and refreshes secrets
+// until ctx is done
+func (a *app) watch(ctx context.Context) {
+	// Listeners that can reject a snapshot go first so a bad reload
+	// changes nothing; rate limiters read the snapshot on every request
+	a.runtime.OnReload(func(rc *config.RuntimeConfig) error {
+		if err := logSettings(rc).Validate(); err != nil {
+			return err
+		}
+		return templates.LoadPersonas(rc.PersonasFile)
+	})
+	a.runtime.OnReload(func(rc *config.RuntimeConfig) error {
+		a.aiService.SetDefaultModel(rc.DefaultModel)
+		return logger.Apply(logSettings(rc))
+	})
+
+	go a.runtime.WatchSignals(ctx, func(rc *config.RuntimeConfig) {
+		logger.Logger.Info().Str("log_level", rc.LogLevel).Str("default_model", rc.DefaultModel).Msg("Runtime configuration reloaded")
+	}, func(err error) {
+		logger.Logger.Error().Err(err).Msg("Failed to reload runtime configuration, keeping current settings")
+	})
+
+	if a.secrets != nil {
+		a.secrets.OnRotate(func(old, new *config.Secret) {
+			logger.Logger.Info().Str("version", new.Version).Msg("Secrets rotated")
+			if new.Values["OPENAI_API_KEY"] != old.Values["OPENAI_API_KEY"] ||
+				new.Values["AZURE_OPENAI_API_KEY"] != old.Values["AZURE_OPENAI_API_KEY"] ||
+				new.Values["GATEWAY_API_KEY"] != old.Values["GATEWAY_API_KEY"] {
+				logger.Logger.Warn().Msg("AI provider API key rotated; restart to use the new key")
+			}
+		})
+		go a.secrets.Watch(ctx, func(err error) {
+			logger.Logger.Error().Err(err).Msg("Failed to refresh secrets, keeping cached values")
+		})
+	}
+}
+
+// Close releases the database, cache and MCP connections
+func (a *app) Close() {
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+	a.closers = nil
+}
@@ -0,0 +1,238 @@
+// Package cli is the command line of the eino-agent binary. The API server,
+// the background worker, migrations and admin tasks are subcommands that
+// load the configuration and set up logging the same way, so one binary
+// built without a Go toolchain serves every role.
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/logger"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+)
+
+// configPath is the config file named by the --config flag
+var configPath string
+
+// Execute runs the subcommand named on the command line. Errors are
+// printed before they are returned.
+func Execute() error {
+	root := &cobra.Command{
+		Use:   "eino-agent",
+		Short: "Eino Agent API server, background worker and operator tools",
+		// Usage only helps with flag mistakes, which cobra reports itself
+		SilenceUsage: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			if err := godotenv.Load(); err != nil {
+				log.Println("No .env file found, using environment variables")
+			}
+		},
+	}
+	root.PersistentFlags().StringVar(&configPath, "config", "", "Path to a YAML or TOML config file (default: config.yaml, config.yml or config.toml if present)")
+
+	root.AddCommand(
+		serveCmd(),
+		devCmd(),
+		workerCmd(),
+		migrateCmd(),
+		adminCmd(),
+		loadgenCmd(),
+	)
+
+	return root.Execute()
+}
+
+// env is the configuration every command starts from
+type env struct {
+	cfg        *config.Config
+	configFile string
+
+	// secrets is the secrets manager, nil when none is configured
+	secrets *config.Secrets
+
+	// runtime holds the settings that can be reloaded without a restart
+	runtime *config.Watcher
+}
+
+// setup loads and validates the configuration and sets up logging.
+// Environment variables, including .env, take precedence over the config
+// file, and a secrets manager over both. Long-running services log to
+// LOG_OUTPUT; the other commands default to stderr, keeping stdout for
+// their output.
+func setup(ctx context.Context, service bool) (*env, error) {
+	configFile, err := config.LoadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file: %w", err)
+	}
+
+	secrets, err := config.LoadSecrets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load secrets: %w", err)
+	}
+
+	cfg := config.Load()
+	if secrets != nil {
+		// New connections fetch the password so a rotation needs no restart
+		password := cfg.Database.Password
+		cfg.Database.PasswordFunc = func(ctx context.Context) (string, error) {
+			if value, err := secrets.Get(ctx, "DB_PASSWORD"); err == nil {
+				return value, nil
+			}
+			return password, nil
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	// Settings that can be reloaded at runtime via SIGHUP or the admin API
+	runtimeCfg, err := config.NewWatcher(configFile, cfg.Env)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	output := "stdout"
+	if !service {
+		output = "stderr"
+	}
+	if err := logger.Init(logConfig(cfg, runtimeCfg.Current(), output)); err != nil {
+		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	return &env{
+		cfg:        cfg,
+		configFile: configFile,
+		secrets:    secrets,
+		runtime:    runtimeCfg,
+	}, nil
+}
+
+// Close flushes the logs
+func (e *env) Close() {
+	logger.Close()
+}
+
+// logConfig configures the logger from the environment, writing to
+// LOG_OUTPUT or else defaultOutput
+func logConfig(cfg *config.Config, rc *config.RuntimeConfig, defaultOutput string) *logger.Config {
+	logConfig := &logger.Config{
+		Level:           rc.LogLevel,
+		ModuleLevels:    rc.LogModules,
+		Sampling:        logSettings(rc).Sampling,
+		Format:          getEnvOrDefault("LOG_FORMAT", "json"),
+		Output:          getEnvOrDefault("LOG_OUTPUT", defaultOutput),
+		FilePath:        getEnvOrDefault("LOG_FILE_PATH", "logs/app.log"),
+		AddTimestamp:    true,
+		AddCaller:       true,
+		PrettyPrint:     !cfg.IsProduction(),
+		ErrorStackTrace: true,
+
+		// PII is redacted in production unless LOG_REDACT_PII says otherwise
+		RedactPII:        getEnvAsBoolOrDefault("LOG_REDACT_PII", cfg.IsProduction()),
+		RedactKey:        os.Getenv("LOG_REDACT_KEY"),
+		RedactHashFields: getEnvAsSlice("LOG_REDACT_HASH_FIELDS"),
+		RedactMaskFields: getEnvAsSlice("LOG_REDACT_MASK_FIELDS"),
+
+		Sink: logger.SinkConfig{
+			ServiceName:   getEnvOrDefault("LOG_SERVICE_NAME", "eino-agent"),
+			BufferSize:    getEnvAsIntOrDefault("LOG_SINK_BUFFER", 10000),
+			BatchSize:     getEnvAsIntOrDefault("LOG_SINK_BATCH_SIZE", 500),
+			FlushInterval: getEnvAsDurationOrDefault("LOG_SINK_FLUSH_INTERVAL", 2*time.Second),
+			LokiURL:       os.Getenv("LOKI_URL"),
+			LokiLabels:    getEnvAsMap("LOKI_LABELS"),
+			LokiTenantID:  os.Getenv("LOKI_TENANT_ID"),
+			LokiUsername:  os.Getenv("LOKI_USERNAME"),
+			LokiPassword:  os.Getenv("LOKI_PASSWORD"),
+			SyslogNetwork: os.Getenv("SYSLOG_NETWORK"),
+			SyslogAddress: os.Getenv("SYSLOG_ADDRESS"),
+			OTLPEndpoint:  os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+			OTLPHeaders:   getEnvAsMap("OTEL_EXPORTER_OTLP_HEADERS"),
+		},
+	}
+	if logConfig.Sink.LokiLabels == nil {
+		logConfig.Sink.LokiLabels = map[string]string{"env": cfg.Env}
+	}
+
+	if !cfg.IsProduction() {
+		logConfig.Format = "console"
+		logConfig.PrettyPrint = true
+	}
+	return logConfig
+}
+
+// logSettings converts the reloadable logging controls for the logger
+func logSettings(rc *config.RuntimeConfig) logger.Settings {
+	return logger.Settings{
+		Level:   rc.LogLevel,
+		Modules: rc.LogModules,
+		Sampling: logger.Sampling{
+			Debug: uint32(rc.LogSampling.Debug),
+			Info:  uint32(rc.LogSampling.Info),
+			Burst: uint32(rc.LogSampling.Burst),
+		},
+	}
+}
+
+// getEnvOrDefault gets environment variable with a default value
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsBoolOrDefault(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsSlice(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvAsIntOrDefault(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsMap parses "key=value,key=value" pairs
+func getEnvAsMap(key string) map[string]string {
+	var values map[string]string
+	for _, pair := range getEnvAsSlice(key) {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if values == nil {
+			values = make(map[string]string)
+		}
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return values
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/shivaluma/eino-agent/config"
+
+	"github.com/spf13/cobra"
+)
+
+func devCmd() *cobra.Command {
+	var (
+		memory bool
+		mockAI bool
+		seed   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dev",
+		Short: "Run the API server for local development",
+		Long: `Run the API server with the background jobs and queued tasks, as serve
+does, set up for local development: ENV=development, so logs are written
+to the console, pending migrations applied on startup, a demo user with a
+few conversations, and a bare chat page at /dev/chat for trying out
+replies without the frontend. With --memory nothing else needs to run:
+the database is an embedded PostgreSQL server (DB_DRIVER=embedded) and the
+cache, queue, events and streams stay in the process even when REDIS_URL
+is set. With --mock-ai replies come from the mock provider (AI_PROVIDER=mock)
+instead of a model, so no API key is needed. make dev runs it with live
+reload.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Set before the configuration is loaded, so they win over .env
+			// and the config file
+			os.Setenv("ENV", config.EnvDevelopment)
+			if memory {
+				os.Setenv("DB_DRIVER", config.DatabaseDriverEmbedded)
+				os.Setenv("STATE_BACKEND", "memory")
+			}
+			if mockAI {
+				os.Setenv("AI_PROVIDER", "mock")
+			}
+
+			env, err := setup(cmd.Context(), true)
+			if err != nil {
+				return err
+			}
+			defer env.Close()
+
+			return serve(cmd.Context(), env, serveOptions{runJobs: true, dev: true, seed: seed})
+		},
+	}
+	cmd.Flags().BoolVar(&memory, "memory", false, "Use the embedded database and keep the cache, queue, events and streams in this process")
+	cmd.Flags().BoolVar(&mockAI, "mock-ai", false, "Answer with the mock provider's scripted replies instead of a model")
+	cmd.Flags().BoolVar(&seed, "seed", true, "Create the demo user and conversations unless the demo user exists")
+	return cmd
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/loadgen"
+
+	"github.com/spf13/cobra"
+)
+
+func loadgenCmd() *cobra.Command {
+	var (
+		cfg    loadgen.Config
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "loadgen",
+		Short: "Drive a running server with synthetic users and report latencies",
+		Long: `Drive a running API server with synthetic users: each registers a new
+account, signs in and sends messages one after another, streamed unless
+--stream=false. When the run is over, the latency percentiles of every
+step are reported, along with the time to the first streamed chunk.
+
+Run the server with serve --load-test so replies come from the mock
+provider and the auth rate limit doesn't turn the users away; the run then
+measures the HTTP and database path rather than a model. Each run creates
+--users accounts named loadgen-<run>-<n>@example.com.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return fmt.Errorf("unknown format: %s, use --format=text or --format=json", format)
+			}
+
+			// Interrupting the run still reports what was measured
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			report, err := loadgen.Run(ctx, cfg)
+			if err != nil {
+				return err
+			}
+
+			if format == "json" {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(report)
+			}
+			return report.Print(os.Stdout)
+		},
+	}
+	cmd.Flags().StringVar(&cfg.BaseURL, "url", "http://localhost:8888", "Base URL of the server")
+	cmd.Flags().IntVarP(&cfg.Users, "users", "u", 10, "Number of concurrent users")
+	cmd.Flags().DurationVarP(&cfg.Duration, "duration", "d", 30*time.Second, "How long to run (0 runs until every user sent --messages)")
+	cmd.Flags().IntVarP(&cfg.Messages, "messages", "n", 0, "Messages each user sends (0 sends until --duration is over)")
+	cmd.Flags().IntVar(&cfg.PerConversation, "per-conversation", 5, "Messages sent to a conversation before a user starts another")
+	cmd.Flags().BoolVar(&cfg.Stream, "stream", true, "Stream replies")
+	cmd.Flags().StringVar(&cfg.Message, "message", "Suggest a quick dinner recipe.", "Message text sent")
+	cmd.Flags().DurationVar(&cfg.RampUp, "ramp-up", 0, "Spread the users' starts over this long")
+	cmd.Flags().DurationVar(&cfg.Timeout, "timeout", time.Minute, "Timeout of each request")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text, json (latencies in nanoseconds)")
+	return cmd
+}
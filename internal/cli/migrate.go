@@ -0,0 +1,300 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/migrations"
+
+	"github.com/spf13/cobra"
+)
+
+func migrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Run pending migrations, or manage them with a subcommand",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withMigrator(cmd.Context(), false, func(ctx context.Context, migrator *migrations.Migrator, _ string) error {
+				if err := migrator.Migrate(ctx); err != nil {
+					return fmt.Errorf("migration failed: %w", err)
+				}
+				fmt.Println("✓ Migrations completed successfully")
+				return nil
+			})
+		},
+	}
+
+	var format string
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Show current migration status",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return fmt.Errorf("unknown format: %s, use --format=text or --format=json", format)
+			}
+			if format == "json" {
+				// Keep stdout for the JSON document
+				migrations.SetLogOutput(os.Stderr)
+			}
+
+			return withMigrator(cmd.Context(), false, func(ctx context.Context, migrator *migrations.Migrator, _ string) error {
+				if format == "text" {
+					if err := migrator.Status(ctx); err != nil {
+						return fmt.Errorf("failed to get migration status: %w", err)
+					}
+					return nil
+				}
+
+				report, err := migrator.Report(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to get migration status: %w", err)
+				}
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(report); err != nil {
+					return fmt.Errorf("failed to write migration status: %w", err)
+				}
+				return nil
+			})
+		},
+	}
+	status.Flags().StringVar(&format, "format", "text", "Output format: text, json")
+
+	rollback := &cobra.Command{
+		Use:   "rollback",
+		Short: "Rollback the last migration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withMigrator(cmd.Context(), false, func(ctx context.Context, migrator *migrations.Migrator, _ string) error {
+				if err := migrator.Rollback(ctx); err != nil {
+					return fmt.Errorf("rollback failed: %w", err)
+				}
+				return nil
+			})
+		},
+	}
+
+	rollbackTo := &cobra.Command{
+		Use:   "rollback-to VERSION",
+		Short: "Rollback to a specific migration version",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil || version <= 0 {
+				return fmt.Errorf("version must be a number greater than 0, got %q", args[0])
+			}
+			return withMigrator(cmd.Context(), false, func(ctx context.Context, migrator *migrations.Migrator, _ string) error {
+				if err := migrator.RollbackTo(ctx, version); err != nil {
+					return fmt.Errorf("rollback to version %d failed: %w", version, err)
+				}
+				return nil
+			})
+		},
+	}
+
+	validate := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate all migration checksums",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withMigrator(cmd.Context(), false, func(ctx context.Context, migrator *migrations.Migrator, _ string) error {
+				if err := migrator.Validate(ctx); err != nil {
+					return fmt.Errorf("migration validation failed: %w", err)
+				}
+				return nil
+			})
+		},
+	}
+
+	var resetConfirm bool
+	reset := &cobra.Command{
+		Use:   "reset",
+		Short: "DROP ALL TABLES and reapply migrations (DANGEROUS)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !resetConfirm {
+				fmt.Println("⚠ WARNING: This will DROP ALL TABLES and reapply all migrations!")
+				fmt.Println("To confirm, add the --confirm flag:")
+				fmt.Println("  eino-agent migrate reset --confirm")
+				return fmt.Errorf("reset not confirmed")
+			}
+			return withMigrator(cmd.Context(), false, func(ctx context.Context, migrator *migrations.Migrator, _ string) error {
+				if err := migrator.Reset(ctx, true); err != nil {
+					return fmt.Errorf("database reset failed: %w", err)
+				}
+				return nil
+			})
+		},
+	}
+	reset.Flags().BoolVar(&resetConfirm, "confirm", false, "Confirm dropping all tables")
+
+	var squashConfirm bool
+	squash := &cobra.Command{
+		Use:   "squash",
+		Short: "Replace applied migrations with a baseline of the current schema",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !squashConfirm {
+				fmt.Println("⚠ WARNING: This will replace all applied migration files with a single baseline")
+				fmt.Println("and rewrite schema_migrations to match. Commit or back up the migrations directory first.")
+				fmt.Println("To confirm, add the --confirm flag:")
+				fmt.Println("  eino-agent migrate squash --confirm")
+				return fmt.Errorf("squash not confirmed")
+			}
+			return withMigrator(cmd.Context(), true, func(ctx context.Context, migrator *migrations.Migrator, dir string) error {
+				filename, err := migrator.Squash(ctx, dir)
+				if err != nil {
+					return fmt.Errorf("squash failed: %w", err)
+				}
+				fmt.Printf("✓ Generated baseline migration: %s\n", filename)
+				fmt.Println("Other databases adopt the baseline the next time they migrate, provided they")
+				fmt.Println("have applied every squashed migration.")
+				return nil
+			})
+		},
+	}
+	squash.Flags().BoolVar(&squashConfirm, "confirm", false, "Confirm rewriting the migrations directory")
+
+	generate := &cobra.Command{
+		Use:   "generate NAME",
+		Short: "Generate a new migration file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Doesn't need a database connection
+			if err := generateMigration(args[0]); err != nil {
+				return fmt.Errorf("failed to generate migration: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.AddCommand(status, rollback, rollbackTo, validate, reset, squash, generate)
+	return cmd
+}
+
+// withMigrator connects to the database, starting the embedded server when
+// DB_DRIVER=embedded, and calls fn with a migrator. Squash rewrites the
+// migration files, so it works on the directory on disk rather than the
+// embedded copies; fn gets that directory.
+func withMigrator(ctx context.Context, onDisk bool, fn func(ctx context.Context, migrator *migrations.Migrator, dir string) error) error {
+	env, err := setup(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+	cfg := env.cfg
+
+	db, err := database.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	source := migrations.Source(cfg.Database.MigrationsDir)
+	dir := cfg.Database.MigrationsDir
+	if dir == "" {
+		dir = "migrations"
+	}
+	if onDisk {
+		source = migrations.Source(dir)
+	}
+
+	return fn(ctx, migrations.NewMigrator(db.Pool, source, cfg), dir)
+}
+
+// generateMigration creates a new migration file with proper naming convention
+func generateMigration(name string) error {
+	// Get current migrations to determine next version number
+	migrations, err := filepath.Glob("migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list existing migrations: %w", err)
+	}
+
+	// Find the highest version number
+	maxVersion := int64(0)
+	for _, migration := range migrations {
+		basename := filepath.Base(migration)
+		if strings.HasPrefix(basename, "000_") {
+			continue // Skip system migration
+		}
+
+		// Extract version number from filename (format: 001_timestamp_name.sql)
+		parts := strings.Split(basename, "_")
+		if len(parts) >= 1 {
+			if version, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
+				if version > maxVersion {
+					maxVersion = version
+				}
+			}
+		}
+	}
+
+	// Generate next version number
+	nextVersion := maxVersion + 1
+
+	// Generate timestamp
+	timestamp := time.Now().Format("20060102150405")
+
+	// Clean up migration name (replace spaces with underscores, lowercase)
+	cleanName := strings.ToLower(strings.ReplaceAll(name, " ", "_"))
+	cleanName = strings.ReplaceAll(cleanName, "-", "_")
+
+	// Generate filenames
+	basename := fmt.Sprintf("%03d_%s_%s", nextVersion, timestamp, cleanName)
+	upFilename := basename + ".up.sql"
+	downFilename := basename + ".down.sql"
+
+	header := `-- Migration: ` + name + `
+-- Created: ` + time.Now().Format("2006-01-02 15:04:05") + `
+-- Version: ` + fmt.Sprintf("%d", nextVersion) + `
+`
+
+	// Generate migration templates
+	upTemplate := header + `
+-- Add your SQL statements here
+-- Example:
+-- CREATE TABLE example (
+--     id SERIAL PRIMARY KEY,
+--     name VARCHAR(255) NOT NULL,
+--     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+-- );
+`
+	downTemplate := header + `
+-- Add statements that undo the up migration here. They are stored when the
+-- migration is applied and run by the rollback commands.
+-- Example:
+-- DROP TABLE IF EXISTS example;
+`
+
+	// Create migrations directory if it doesn't exist
+	if err := os.MkdirAll("migrations", 0755); err != nil {
+		return fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+
+	// Write the migration files
+	if err := os.WriteFile(filepath.Join("migrations", upFilename), []byte(upTemplate), 0644); err != nil {
+		return fmt.Errorf("failed to write migration file: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join("migrations", downFilename), []byte(downTemplate), 0644); err != nil {
+		return fmt.Errorf("failed to write rollback migration file: %w", err)
+	}
+
+	fmt.Printf("✓ Generated migration files: %s, %s\n", upFilename, downFilename)
+	fmt.Printf("✓ Migration version: %d\n", nextVersion)
+	fmt.Printf("✓ Directory: %s\n", "migrations")
+	fmt.Println("\nNext steps:")
+	fmt.Println("1. Edit the .up.sql file to add your SQL statements and the .down.sql file to undo them")
+	fmt.Println("2. Run 'make db-migrate' to apply the migration")
+	fmt.Println("3. Run 'make db-migrate-status' to verify the migration")
+
+	return nil
+}
@@ -0,0 +1,436 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/apiversion"
+	"github.com/shivaluma/eino-agent/internal/audit"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/devtools"
+	"github.com/shivaluma/eino-agent/internal/geoip"
+	"github.com/shivaluma/eino-agent/internal/grpcapi"
+	"github.com/shivaluma/eino-agent/internal/handlers"
+	"github.com/shivaluma/eino-agent/internal/health"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/mail"
+	"github.com/shivaluma/eino-agent/internal/middleware"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/security"
+	"github.com/shivaluma/eino-agent/internal/share"
+	"github.com/shivaluma/eino-agent/internal/storage"
+	"github.com/shivaluma/eino-agent/internal/streaming"
+
+	"github.com/labstack/echo/v4"
+	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/spf13/cobra"
+)
+
+func serveCmd() *cobra.Command {
+	var (
+		grpcAddr string
+		runJobs  bool
+		mockAI   bool
+		loadTest bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the API server",
+		Long: `Run the HTTP API server, and the gRPC chat service when GRPC_ADDR is set.
+Pending migrations are applied on startup. Background jobs and queued
+tasks run in the server unless --jobs=false, for deployments where
+workers run them.
+
+With --mock-ai replies come from the mock provider (AI_PROVIDER=mock)
+instead of a model. --load-test does the same and lifts the auth rate
+limit, so loadgen can sign up many users from one address and measures
+the HTTP and database path; never use it in production.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Set before the configuration is loaded, so they win over .env
+			// and the config file
+			if mockAI || loadTest {
+				os.Setenv("AI_PROVIDER", "mock")
+			}
+			if loadTest {
+				os.Setenv("RATE_LIMIT_AUTH", loadTestAuthLimit)
+			}
+
+			env, err := setup(cmd.Context(), true)
+			if err != nil {
+				return err
+			}
+			defer env.Close()
+
+			if loadTest {
+				logger.Logger.Warn().Msg("Load test mode: auth rate limit lifted and replies come from the mock provider")
+			}
+
+			if grpcAddr != "" {
+				env.cfg.Server.GRPCAddr = grpcAddr
+			}
+			return serve(cmd.Context(), env, serveOptions{runJobs: runJobs})
+		},
+	}
+	cmd.Flags().StringVar(&grpcAddr, "grpc-addr", "", "Listen address of the gRPC chat service, overriding GRPC_ADDR (needs a build with -tags grpc)")
+	cmd.Flags().BoolVar(&runJobs, "jobs", true, "Run background jobs and queued tasks in this process")
+	cmd.Flags().BoolVar(&mockAI, "mock-ai", false, "Answer with the mock provider's scripted replies instead of a model")
+	cmd.Flags().BoolVar(&loadTest, "load-test", false, "Set up for loadgen: the mock provider and no auth rate limit")
+	return cmd
+}
+
+// loadTestAuthLimit is the auth rate limit of --load-test, high enough that
+// loadgen never hits it
+const loadTestAuthLimit = "1000000000/1s"
+
+// serveOptions are what the serve and dev commands run differently
+type serveOptions struct {
+	// runJobs runs the background jobs and queued tasks in the server
+	runJobs bool
+
+	// dev serves the dev chat page
+	dev bool
+
+	// seed creates the demo user and conversations
+	seed bool
+}
+
+// serve runs the API server until SIGINT or SIGTERM
+func serve(ctx context.Context, env *env, opts serveOptions) error {
+	cfg := env.cfg
+
+	// From now on, use structured logging
+	logger.Logger.Info().Msg("Starting Eino Agent server")
+	logger.Logger.Info().Str("environment", cfg.Env).Str("config_file", env.configFile).Msg("Configuration loaded")
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a, err := newApp(ctx, env)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	oauthRepo := repository.NewOAuthRepository(a.db.Pool)
+	authSvc, err := auth.NewService(cfg, a.cache)
+	if err != nil {
+		return fmt.Errorf("failed to load JWT signing keys: %w", err)
+	}
+	oauthSvc := auth.NewOAuthService(cfg)
+	stateStore := auth.NewStateStore(a.cache)
+	auditor := audit.NewAuditor(a.auditRepo, cfg.Security.AuditHashKey)
+
+	if env.secrets != nil {
+		env.secrets.OnRotate(func(old, new *config.Secret) {
+			if secret := new.Values["JWT_ACCESS_SECRET"]; secret != "" && secret != old.Values["JWT_ACCESS_SECRET"] {
+				if err := authSvc.RotateAccessSecret(secret); err != nil {
+					logger.Logger.Error().Err(err).Msg("Failed to rotate JWT access secret")
+				}
+			}
+		})
+	}
+
+	loginGuard := auth.NewLoginGuard(a.cache, cfg.Login)
+	// Suspicious sign-ins and refreshes lock accounts until their owner
+	// confirms by email
+	mailer := mail.New(cfg.Mail)
+	securityMonitor := security.NewMonitor(a.userRepo, authSvc, a.cache, geoip.New(cfg.Security.GeoIPURL, a.cache),
+		mailer, auditor, a.tasks, cfg.Security, cfg.OAuth.FrontendURL)
+	authHandler := handlers.NewAuthHandler(a.userRepo, authSvc, auditor, loginGuard, securityMonitor)
+	oauthHandler := handlers.NewOAuthHandler(a.userRepo, oauthRepo, a.transactor, stateStore, authSvc, oauthSvc, auditor, securityMonitor, cfg.OAuth.FrontendURL)
+	streamStore := streaming.NewStore(a.cache, a.pubsub, cfg.Redis.KeyPrefix, 5*time.Minute, 2000)
+	convHandler := handlers.NewConversationHandler(a.convRepo, a.transactor, a.participantRepo, a.settingsRepo, a.uploadRepo, authSvc, a.aiService, streamStore, a.titles, a.files, a.eventBus, a.memories, a.guard, a.usageRecorder, a.conversationLocks, a.aiQueue, a.messageOutbox, cfg.SSE, cfg.AI.AllowedModels)
+	memoryHandler := handlers.NewMemoryHandler(a.memoryRepo, authSvc)
+	usageHandler := handlers.NewUsageHandler(a.usageRepo, a.pricing, authSvc)
+	statsHandler := handlers.NewStatsHandler(a.statsRepo, a.cache, authSvc, cfg.Stats)
+	orgHandler := handlers.NewOrganizationHandler(a.orgRepo, a.userRepo, a.transactor, a.usageRecorder, authSvc, auditor, mailer, cfg.Invite, cfg.OAuth.FrontendURL, a.orgCredentials)
+	eventsHandler := handlers.NewEventsHandler(a.eventBus, authSvc, cfg.SSE)
+	participantHandler := handlers.NewParticipantHandler(a.participantRepo, a.convRepo, a.userRepo, a.orgRepo, authSvc)
+	feedbackHandler := handlers.NewFeedbackHandler(a.feedbackRepo, a.convRepo, a.participantRepo, authSvc)
+	scheduleHandler := handlers.NewScheduleHandler(a.scheduleRepo, a.convRepo, a.participantRepo, authSvc, a.guard, cfg.IsProduction())
+	shareHandler := handlers.NewShareHandler(a.shareRepo, a.convRepo, authSvc, share.NewSigner(cfg.Share.Secret), cfg.OAuth.FrontendURL)
+	avatarHandler := handlers.NewAvatarHandler(a.userRepo, authSvc, a.files, cfg.Server.PublicURL, cfg.Storage.MaxUploadBytes)
+	uploadHandler := handlers.NewUploadHandler(a.uploadRepo, a.files, authSvc, cfg.Storage.MaxUploadBytes)
+	importHandler := handlers.NewImportHandler(a.importer, a.importRepo, authSvc, cfg.Import)
+	settingsHandler := handlers.NewSettingsHandler(a.settingsRepo, authSvc, cfg.AI.AllowedModels)
+
+	// Admins can still run jobs on demand when workers run them on their
+	// intervals
+	if opts.runJobs {
+		a.runBackground(ctx)
+	} else if cfg.State.Backend == "memory" {
+		logger.Logger.Warn().Msg("STATE_BACKEND=memory keeps queued tasks in this process, so with --jobs=false conversation titles, memories and webhooks never run")
+	}
+
+	adminHandler := handlers.NewAdminHandler(authSvc, auditor, a.aiMetrics, a.aiBreakers, a.db, env.runtime, loginGuard, a.jobs, a.tasks, a.guard, a.aiQueue)
+	retentionHandler := handlers.NewRetentionHandler(a.retention)
+
+	checker := health.NewChecker(cfg.Server.HealthCheckTimeout)
+	checker.Register("database", true, health.Database(a.db))
+	migrationCheck, err := health.Migrations(a.migrator)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations for health checks: %w", err)
+	}
+	checker.Register("migrations", true, migrationCheck)
+	if cfg.State.Backend == "redis" {
+		checker.Register("redis", true, health.Cache(a.cache))
+	}
+	checker.Register("ai_providers", false, health.AIProviders(a.chatModels, &http.Client{}))
+	healthHandler := handlers.NewHealthHandler(checker)
+
+	e := echo.New()
+
+	e.Validator = handlers.NewValidator()
+	e.HTTPErrorHandler = apierror.Handler
+
+	// Add request ID middleware first
+	e.Use(middleware.RequestIDMiddleware())
+	// Compression wraps body logging, so bodies are logged before encoding
+	e.Use(middleware.CompressMiddleware(cfg.Compress))
+	// Body logging wraps the request logger, which writes error responses
+	e.Use(middleware.BodyLoggingMiddleware(cfg.BodyLog))
+	// Replace Echo's logger with our structured logger
+	e.Use(middleware.LoggingMiddleware())
+	e.Use(middleware.ErrorHandlingMiddleware())
+	e.Use(echomiddleware.Recover())
+	e.Use(middleware.CORSMiddleware(cfg.CORS))
+	e.Use(middleware.BodyLimitMiddleware(cfg.Server.MaxBodyBytes))
+
+	authLimiter := middleware.RateLimitMiddleware(a.cache, "auth", func() config.RateLimit {
+		return env.runtime.Current().RateLimit("auth")
+	})
+	authBodyLimit := middleware.BodyLimitMiddleware(cfg.Server.MaxAuthBodyBytes)
+	shareLimiter := middleware.RateLimitMiddleware(a.cache, "share", func() config.RateLimit {
+		return env.runtime.Current().RateLimit("share")
+	})
+	embedLimiter := middleware.RateLimitMiddleware(a.cache, "embed", func() config.RateLimit {
+		return env.runtime.Current().RateLimit("embed")
+	})
+
+	// v2 renders messages with the v2 schema; everything else is shared. v1
+	// announces its deprecation once API_V1_DEPRECATED_AT or API_V1_SUNSET
+	// is set.
+	v1 := apiversion.Version{Name: apiversion.V1}
+	if !cfg.API.V1DeprecatedAt.IsZero() || !cfg.API.V1Sunset.IsZero() {
+		v1.Deprecation = &apiversion.Deprecation{
+			At:     cfg.API.V1DeprecatedAt,
+			Sunset: cfg.API.V1Sunset,
+			Link:   cfg.API.V1DeprecationLink,
+		}
+	}
+	routes := apiversion.NewRouter(e, "/api", v1, apiversion.Version{Name: apiversion.V2})
+
+	routes.Each(func(_ string, api *echo.Group) {
+		api.POST("/check-email", authHandler.CheckEmail, authLimiter, authBodyLimit)
+		api.POST("/register", authHandler.Register, authLimiter, authBodyLimit)
+		api.POST("/login", authHandler.Login, authLimiter, authBodyLimit)
+		api.POST("/token/refresh", authHandler.RefreshToken, authLimiter, authBodyLimit)
+		api.POST("/auth/unlock", authHandler.UnlockAccount, authLimiter, authBodyLimit)
+
+		// Organization invitations, accepted with the emailed token
+		api.GET("/invitations/:token", orgHandler.GetInvitation, authLimiter)
+		api.POST("/invitations/:token/accept", orgHandler.AcceptInvitation, authLimiter, authBodyLimit)
+
+		// OAuth routes
+		api.GET("/auth/oauth/providers", oauthHandler.GetOAuthProviders)
+		api.GET("/auth/oauth/:provider/authorize", oauthHandler.InitiateOAuth)
+		api.GET("/auth/oauth/:provider/callback", oauthHandler.HandleOAuthCallback)
+
+		protected := api.Group("")
+		protected.Use(middleware.AuthMiddleware(authSvc))
+		// X-Org-ID selects the organization requests act in
+		protected.Use(middleware.OrgMiddleware(authSvc, a.orgRepo))
+
+		// Protected auth/user routes
+		protected.GET("/auth/me", authHandler.Me)
+		protected.POST("/auth/logout", authHandler.Logout)
+		protected.GET("/auth/sessions", authHandler.ListSessions)
+		protected.DELETE("/auth/sessions/:id", authHandler.RevokeSession)
+		protected.POST("/auth/me/avatar", avatarHandler.UploadAvatar)
+		protected.DELETE("/auth/me/avatar", avatarHandler.DeleteAvatar)
+
+		// Protected OAuth routes
+		protected.GET("/auth/oauth/linked", oauthHandler.GetLinkedAccounts)
+		protected.POST("/auth/oauth/:provider/link", oauthHandler.LinkOAuthAccount)
+		protected.DELETE("/auth/oauth/:provider/unlink", oauthHandler.UnlinkOAuthAccount)
+		protected.POST("/auth/oauth/:provider/scopes", oauthHandler.RequestScopes)
+
+		protected.GET("/conversations", convHandler.GetConversations, middleware.ETagMiddleware())
+		protected.GET("/conversations/bootstrap", convHandler.GetBootstrap, middleware.ETagMiddleware())
+		protected.GET("/conversations/:id", convHandler.GetConversation)
+		protected.GET("/conversations/:id/messages", convHandler.GetMessages, middleware.ETagMiddleware())
+		protected.POST("/conversations/:id/pin", convHandler.TogglePin)
+		protected.PATCH("/conversations/:id/read", convHandler.MarkRead)
+		protected.POST("/conversations/:id/fork", convHandler.ForkConversation)
+		protected.PUT("/conversations/:id/persona", convHandler.UpdatePersona)
+		protected.PUT("/conversations/:id/context-strategy", convHandler.UpdateContextStrategy)
+		protected.PUT("/conversations/:id/model", convHandler.UpdateModel)
+		protected.POST("/conversations/:id/title/regenerate", convHandler.RegenerateTitle)
+		protected.DELETE("/conversations/:id/messages/:messageID", convHandler.DeleteMessage)
+		protected.POST("/conversations/:id/messages/:messageID/feedback", feedbackHandler.SubmitFeedback)
+		protected.GET("/conversations/:id/participants", participantHandler.ListParticipants)
+		protected.POST("/conversations/:id/participants", participantHandler.InviteParticipant)
+		protected.DELETE("/conversations/:id/participants/:userId", participantHandler.RemoveParticipant)
+		protected.POST("/conversations/:id/schedule", scheduleHandler.CreateSchedule)
+		protected.GET("/conversations/:id/schedule", scheduleHandler.ListSchedules)
+		protected.DELETE("/conversations/:id/schedule/:scheduleId", scheduleHandler.DeleteSchedule)
+		protected.POST("/conversations/:id/share", shareHandler.CreateShare)
+		protected.GET("/conversations/:id/shares", shareHandler.ListShares)
+		protected.DELETE("/conversations/:id/shares/:shareId", shareHandler.RevokeShare)
+
+		// New message endpoint - handles both new conversations and existing ones
+		protected.POST("/messages", convHandler.SendMessage)
+		protected.GET("/personas", convHandler.GetPersonas)
+		protected.GET("/agents", convHandler.GetAgents)
+		protected.GET("/memories", memoryHandler.ListMemories)
+		protected.DELETE("/memories", memoryHandler.DeleteAllMemories)
+		protected.DELETE("/memories/:memoryId", memoryHandler.DeleteMemory)
+		protected.GET("/usage/report", usageHandler.GetReport)
+		protected.GET("/stats", statsHandler.GetStats)
+		protected.GET("/streams/:id", convHandler.ResumeStream)
+		protected.POST("/streams/:id/cancel", convHandler.CancelStream)
+		protected.GET("/events", eventsHandler.Stream)
+
+		// Public read-only conversation snapshots
+		api.GET("/share/:token", shareHandler.GetSharedConversation, shareLimiter)
+		// Embeds of shared conversations, readable from any site
+		api.GET("/share/:token/embed", shareHandler.GetEmbed, embedLimiter, middleware.ETagMiddleware())
+		api.GET("/oembed", shareHandler.GetOEmbed, embedLimiter)
+
+		// Public avatar images
+		api.GET("/users/:id/avatar", avatarHandler.GetAvatar)
+
+		// Signed file URLs for the local storage backend; S3 serves its own
+		if localStore, ok := a.files.(*storage.Local); ok {
+			api.GET("/files/*", handlers.NewFileHandler(localStore).ServeSigned)
+		}
+
+		// File uploads for message attachments
+		protected.POST("/uploads", uploadHandler.Upload)
+		protected.GET("/uploads/:id", uploadHandler.GetUpload)
+
+		// Imports of conversations exported from ChatGPT
+		protected.POST("/import", importHandler.Import)
+		protected.GET("/import/:id", importHandler.GetImport)
+
+		// Organizations
+		protected.POST("/orgs", orgHandler.CreateOrganization)
+		protected.GET("/orgs", orgHandler.ListOrganizations)
+		protected.GET("/orgs/:id", orgHandler.GetOrganization)
+		protected.PATCH("/orgs/:id", orgHandler.UpdateOrganization)
+		protected.DELETE("/orgs/:id", orgHandler.DeleteOrganization)
+		protected.GET("/orgs/:id/members", orgHandler.ListMembers)
+		protected.POST("/orgs/:id/members", orgHandler.AddMember)
+		protected.PATCH("/orgs/:id/members/:userId", orgHandler.UpdateMember)
+		protected.DELETE("/orgs/:id/members/:userId", orgHandler.RemoveMember)
+		protected.GET("/orgs/:id/usage", orgHandler.GetUsage)
+		protected.POST("/orgs/:id/invitations", orgHandler.CreateInvitation)
+		protected.GET("/orgs/:id/invitations", orgHandler.ListInvitations)
+		protected.DELETE("/orgs/:id/invitations/:invitationId", orgHandler.RevokeInvitation)
+		protected.GET("/orgs/:id/credentials", orgHandler.ListCredentials)
+		protected.PUT("/orgs/:id/credentials/:provider", orgHandler.SetCredential)
+		protected.DELETE("/orgs/:id/credentials/:provider", orgHandler.DeleteCredential)
+
+		// Per-user AI settings
+		protected.GET("/settings", settingsHandler.GetSettings)
+		protected.PATCH("/settings", settingsHandler.UpdateSettings)
+
+		// Admin routes
+		admin := protected.Group("/admin")
+		admin.Use(middleware.AdminMiddleware(authSvc, a.userRepo))
+		admin.GET("/audit-events", adminHandler.GetAuditEvents)
+		admin.GET("/ai-metrics", adminHandler.GetAIMetrics)
+		admin.GET("/guardrails", adminHandler.GetGuardrails)
+		admin.GET("/usage", usageHandler.GetRollup)
+		admin.GET("/pricing", usageHandler.GetPricing)
+		admin.PUT("/orgs/:id/quota", orgHandler.SetQuota)
+		admin.PUT("/orgs/:id/retention", orgHandler.SetRetention)
+		admin.GET("/retention", retentionHandler.GetReport)
+		admin.GET("/db-stats", adminHandler.GetDBStats)
+		admin.GET("/login-stats", adminHandler.GetLoginStats)
+		admin.GET("/config", adminHandler.GetRuntimeConfig)
+		admin.POST("/config/reload", adminHandler.ReloadConfig)
+		admin.GET("/logging", adminHandler.GetLogging)
+		admin.PUT("/logging", adminHandler.UpdateLogging)
+		admin.GET("/feedback", feedbackHandler.GetFeedbackSummary)
+		admin.GET("/jobs", adminHandler.ListJobs)
+		admin.POST("/jobs/:name/run", adminHandler.RunJob)
+		admin.GET("/tasks/dead", adminHandler.ListDeadTasks)
+		admin.POST("/tasks/dead/retry", adminHandler.RetryDeadTasks)
+	})
+
+	// Replaced by POST /messages, which creates the conversation; only kept
+	// in v1 for old clients
+	routes.Group(apiversion.V1).POST("/conversations", convHandler.CreateConversation,
+		middleware.AuthMiddleware(authSvc), middleware.OrgMiddleware(authSvc, a.orgRepo), apiversion.Deprecation{Link: "/api/v2/messages"}.Middleware())
+
+	// Public keys for verifying access tokens
+	e.GET("/.well-known/jwks.json", authHandler.JWKS)
+
+	// Kubernetes probes
+	e.GET("/health/live", healthHandler.Live)
+	e.GET("/health/ready", healthHandler.Ready)
+
+	if opts.seed {
+		seeder := devtools.NewSeeder(a.userRepo, a.convRepo, a.transactor, authSvc.HashPassword)
+		seeded, err := seeder.Seed(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to seed demo data: %w", err)
+		}
+		if seeded {
+			logger.Logger.Info().Str("email", devtools.DemoEmail).Str("password", devtools.DemoPassword).Msg("Created demo user")
+		}
+	}
+	if opts.dev {
+		e.GET("/dev/chat", devtools.ChatPage)
+		logger.Logger.Info().Str("url", "http://localhost:"+cfg.Server.Port+"/dev/chat").Msg("Dev chat page available")
+	}
+
+	go func() {
+		if err := e.Start(":" + cfg.Server.Port); err != nil {
+			logger.Logger.Error().Err(err).Msg("Server failed to start")
+		}
+	}()
+
+	logger.Logger.Info().Str("port", cfg.Server.Port).Msg("Server started")
+
+	if cfg.Server.GRPCAddr != "" {
+		deps := grpcapi.Deps{
+			ConvRepo:     a.convRepo,
+			Tx:           a.transactor,
+			Participants: a.participantRepo,
+			SettingsRepo: a.settingsRepo,
+			AuthSvc:      authSvc,
+			AIService:    a.aiService,
+			Events:       a.eventBus,
+			Memories:     a.memories,
+			Guard:        a.guard,
+			Usage:        a.usageRecorder,
+			Locks:        a.conversationLocks,
+			Queue:        a.aiQueue,
+		}
+		go func() {
+			if err := grpcapi.Serve(ctx, cfg.Server.GRPCAddr, deps); err != nil {
+				logger.Logger.Error().Err(err).Msg("gRPC server failed")
+			}
+		}()
+	}
+
+	// The signal also stops the jobs and the gRPC service
+	<-ctx.Done()
+
+	logger.Logger.Info().Msg("Shutting down server...")
+	if err := e.Shutdown(context.TODO()); err != nil {
+		logger.Logger.Error().Err(err).Msg("Server forced to shutdown")
+	}
+	return nil
+}
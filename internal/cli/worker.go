@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"os/signal"
+	"syscall"
+
+	"github.com/shivaluma/eino-agent/internal/logger"
+
+	"github.com/spf13/cobra"
+)
+
+func workerCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "worker",
+		Short: "Run the background jobs and queued tasks without serving the API",
+		Long: `Run the background jobs, such as scheduled prompts, retried message writes
+and retention, and the queued tasks, such as conversation titles, memories
+and webhooks, without serving the API. Run API servers with --jobs=false
+to leave them to workers. Workers tell connected clients about their
+replies through Redis, so STATE_BACKEND=memory only suits a single process.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := setup(cmd.Context(), true)
+			if err != nil {
+				return err
+			}
+			defer env.Close()
+
+			logger.Logger.Info().Str("environment", env.cfg.Env).Str("config_file", env.configFile).Msg("Starting Eino Agent worker")
+			if env.cfg.State.Backend == "memory" {
+				logger.Logger.Warn().Msg("STATE_BACKEND=memory is local to this worker, so API clients won't see live updates of its replies and it only runs tasks it queued itself")
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			a, err := newApp(ctx, env)
+			if err != nil {
+				return err
+			}
+			defer a.Close()
+
+			a.runBackground(ctx)
+			<-ctx.Done()
+
+			logger.Logger.Info().Msg("Shutting down worker...")
+			return nil
+		},
+	}
+}
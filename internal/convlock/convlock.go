@@ -0,0 +1,49 @@
+// Package convlock lets one message at a time be answered in a
+// conversation, so concurrent requests can't interleave its history or both
+// create it
+package convlock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shivaluma/eino-agent/internal/cache"
+)
+
+// ErrBusy is returned when another message is still being answered in the
+// conversation
+var ErrBusy = errors.New("convlock: conversation is busy")
+
+// Unlock releases a conversation lock. Calling it more than once is safe.
+type Unlock func()
+
+// Locker hands out conversation locks
+type Locker interface {
+	// Lock takes the conversation's lock, waiting up to the configured time
+	// for the current holder to release it. It returns ErrBusy when the
+	// lock wasn't released in time.
+	Lock(ctx context.Context, conversationID uuid.UUID) (Unlock, error)
+}
+
+// Config controls how long requests queue for a lock
+type Config struct {
+	// Wait is how long a request waits for a busy conversation; zero
+	// rejects it at once
+	Wait time.Duration
+
+	// TTL bounds how long a lock outlives an instance that died holding
+	// it. Held locks are renewed, so it doesn't limit a generation.
+	TTL time.Duration
+}
+
+// New returns a Redis-backed locker when the shared cache is Redis, so
+// requests are serialized across instances, and an in-memory locker
+// otherwise
+func New(c cache.Cache, prefix string, config Config) Locker {
+	if rc, ok := c.(*cache.Redis); ok {
+		return NewRedisLocker(rc.Client(), prefix, config)
+	}
+	return NewMemoryLocker(config)
+}
@@ -0,0 +1,91 @@
+package convlock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryLock is one conversation's lock; refs counts holders and waiters so
+// unused locks can be dropped
+type memoryLock struct {
+	held chan struct{}
+	refs int
+}
+
+// MemoryLocker is an in-process Locker. It only serializes requests served
+// by the same instance.
+type MemoryLocker struct {
+	mu     sync.Mutex
+	locks  map[uuid.UUID]*memoryLock
+	config Config
+}
+
+// NewMemoryLocker creates an in-memory locker
+func NewMemoryLocker(config Config) *MemoryLocker {
+	return &MemoryLocker{
+		locks:  make(map[uuid.UUID]*memoryLock),
+		config: config,
+	}
+}
+
+func (m *MemoryLocker) Lock(ctx context.Context, conversationID uuid.UUID) (Unlock, error) {
+	m.mu.Lock()
+	lock, ok := m.locks[conversationID]
+	if !ok {
+		lock = &memoryLock{held: make(chan struct{}, 1)}
+		m.locks[conversationID] = lock
+	}
+	lock.refs++
+	m.mu.Unlock()
+
+	if err := m.acquire(ctx, lock); err != nil {
+		m.release(conversationID, lock, false)
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { m.release(conversationID, lock, true) })
+	}, nil
+}
+
+func (m *MemoryLocker) acquire(ctx context.Context, lock *memoryLock) error {
+	select {
+	case lock.held <- struct{}{}:
+		return nil
+	default:
+	}
+	if m.config.Wait <= 0 {
+		return ErrBusy
+	}
+
+	timer := time.NewTimer(m.config.Wait)
+	defer timer.Stop()
+
+	select {
+	case lock.held <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release drops a reference to the lock, unlocking it if it was held
+func (m *MemoryLocker) release(conversationID uuid.UUID, lock *memoryLock, held bool) {
+	if held {
+		<-lock.held
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock.refs--
+	if lock.refs == 0 {
+		delete(m.locks, conversationID)
+	}
+}
@@ -0,0 +1,107 @@
+package convlock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// pollInterval is how often a waiting request retries a busy lock
+const pollInterval = 100 * time.Millisecond
+
+// releaseScript deletes the lock only if it is still held by the caller's
+// token, so a lock that expired and was taken over isn't released by its
+// previous holder
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// renewScript extends the lock's expiry if it is still held by the caller
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// RedisLocker is a Locker backed by Redis keys with an expiry, so requests
+// are serialized across instances
+type RedisLocker struct {
+	client *redis.Client
+	prefix string
+	config Config
+}
+
+// NewRedisLocker creates a Redis-backed locker
+func NewRedisLocker(client *redis.Client, prefix string, config Config) *RedisLocker {
+	return &RedisLocker{
+		client: client,
+		prefix: prefix,
+		config: config,
+	}
+}
+
+func (r *RedisLocker) key(conversationID uuid.UUID) string {
+	return r.prefix + "conversation-lock:" + conversationID.String()
+}
+
+func (r *RedisLocker) Lock(ctx context.Context, conversationID uuid.UUID) (Unlock, error) {
+	key := r.key(conversationID)
+	token := uuid.New().String()
+	deadline := time.Now().Add(r.config.Wait)
+
+	for {
+		ok, err := r.client.SetNX(ctx, key, token, r.config.TTL).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			return nil, ErrBusy
+		}
+
+		select {
+		case <-time.After(min(pollInterval, time.Until(deadline))):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	stop := make(chan struct{})
+	go r.renew(key, token, stop)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			releaseScript.Run(ctx, r.client, []string{key}, token)
+		})
+	}, nil
+}
+
+// renew keeps extending a held lock until stop is closed
+func (r *RedisLocker) renew(key, token string, stop <-chan struct{}) {
+	ticker := time.NewTicker(r.config.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), r.config.TTL/3)
+			renewScript.Run(ctx, r.client, []string{key}, token, r.config.TTL.Milliseconds())
+			cancel()
+		}
+	}
+}
@@ -2,28 +2,95 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
 	"time"
 
 	"github.com/shivaluma/eino-agent/config"
 
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type DB struct {
 	Pool *pgxpool.Pool
+
+	// Queries holds per-query latency metrics recorded by the query tracer
+	Queries *QueryMetrics
+
+	// embedded is the in-process server when using the embedded driver
+	embedded *embeddedpostgres.EmbeddedPostgres
 }
 
 func New(cfg *config.Config) (*DB, error) {
+	dbCfg := cfg.Database
+
+	var embedded *embeddedpostgres.EmbeddedPostgres
+	switch dbCfg.Driver {
+	case "", config.DatabaseDriverPostgres:
+	case config.DatabaseDriverEmbedded:
+		var err error
+		embedded, err = startEmbedded(&dbCfg)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", dbCfg.Driver)
+	}
+
+	db, err := connect(&dbCfg)
+	if err != nil {
+		if embedded != nil {
+			embedded.Stop()
+		}
+		return nil, err
+	}
+	db.embedded = embedded
+
+	return db, nil
+}
+
+// startEmbedded runs a local PostgreSQL server for the embedded driver and
+// points dbCfg at it
+func startEmbedded(dbCfg *config.DatabaseConfig) (*embeddedpostgres.EmbeddedPostgres, error) {
+	dataDir, err := filepath.Abs(dbCfg.EmbeddedDataDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedded database directory: %w", err)
+	}
+
+	server := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Username(dbCfg.User).
+		Password(dbCfg.Password).
+		Database(dbCfg.Database).
+		Port(uint32(dbCfg.Port)).
+		DataPath(filepath.Join(dataDir, "data")).
+		RuntimePath(filepath.Join(dataDir, "runtime")).
+		BinariesPath(filepath.Join(dataDir, "bin")).
+		StartTimeout(time.Minute))
+
+	log.Printf("Starting embedded PostgreSQL on port %d", dbCfg.Port)
+	if err := server.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start embedded database: %w", err)
+	}
+
+	dbCfg.Host = "localhost"
+	dbCfg.SSLMode = "disable"
+
+	return server, nil
+}
+
+func connect(dbCfg *config.DatabaseConfig) (*DB, error) {
 	dsn := fmt.Sprintf(
 		"postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.Database,
-		cfg.Database.SSLMode,
+		dbCfg.User,
+		dbCfg.Password,
+		dbCfg.Host,
+		dbCfg.Port,
+		dbCfg.Database,
+		dbCfg.SSLMode,
 	)
 
 	poolConfig, err := pgxpool.ParseConfig(dsn)
@@ -31,8 +98,28 @@ func New(cfg *config.Config) (*DB, error) {
 		return nil, fmt.Errorf("failed to parse database config: %w", err)
 	}
 
-	poolConfig.MaxConns = int32(cfg.Database.MaxOpenConns)
-	poolConfig.MaxConnIdleTime = cfg.Database.MaxLifetime
+	poolConfig.MaxConns = int32(dbCfg.MaxOpenConns)
+	poolConfig.MinConns = int32(dbCfg.MinConns)
+	poolConfig.MaxConnLifetime = dbCfg.MaxLifetime
+	poolConfig.MaxConnIdleTime = dbCfg.MaxIdleTime
+	if dbCfg.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = dbCfg.HealthCheckPeriod
+	}
+
+	if dbCfg.PasswordFunc != nil {
+		passwordFunc := dbCfg.PasswordFunc
+		poolConfig.BeforeConnect = func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+			password, err := passwordFunc(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get database password: %w", err)
+			}
+			connConfig.Password = password
+			return nil
+		}
+	}
+
+	queries := NewQueryMetrics()
+	poolConfig.ConnConfig.Tracer = NewQueryTracer(dbCfg.SlowQueryThreshold, queries)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -48,7 +135,7 @@ func New(cfg *config.Config) (*DB, error) {
 
 	log.Println("Database connection established successfully")
 
-	return &DB{Pool: pool}, nil
+	return &DB{Pool: pool, Queries: queries}, nil
 }
 
 func (db *DB) Close() {
@@ -56,8 +143,21 @@ func (db *DB) Close() {
 		db.Pool.Close()
 		log.Println("Database connection closed")
 	}
+	if db.embedded != nil {
+		if err := db.embedded.Stop(); err != nil {
+			log.Printf("Failed to stop embedded database: %v", err)
+		}
+	}
 }
 
+// ErrPoolExhausted is returned by Health when every connection is in use
+var ErrPoolExhausted = errors.New("database connection pool exhausted")
+
+// Health pings the database. It reports ErrPoolExhausted without pinging
+// when no connection is free, since the ping would only wait for one.
 func (db *DB) Health(ctx context.Context) error {
+	if db.Stats().Exhausted() {
+		return ErrPoolExhausted
+	}
 	return db.Pool.Ping(ctx)
-}
\ No newline at end of file
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+// PoolStats is a snapshot of connection pool statistics
+type PoolStats struct {
+	MaxConns          int32         `json:"max_conns"`
+	TotalConns        int32         `json:"total_conns"`
+	AcquiredConns     int32         `json:"acquired_conns"`
+	IdleConns         int32         `json:"idle_conns"`
+	ConstructingConns int32         `json:"constructing_conns"`
+	AcquireCount      int64         `json:"acquire_count"`
+	AcquireDuration   time.Duration `json:"acquire_duration_ns"`
+
+	// EmptyAcquireCount counts acquires that had to wait for a connection
+	EmptyAcquireCount       int64 `json:"empty_acquire_count"`
+	CanceledAcquireCount    int64 `json:"canceled_acquire_count"`
+	NewConnsCount           int64 `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64 `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64 `json:"max_idle_destroy_count"`
+}
+
+// Exhausted reports whether every connection the pool may open is in use
+func (s PoolStats) Exhausted() bool {
+	return s.MaxConns > 0 && s.AcquiredConns >= s.MaxConns
+}
+
+// Stats returns current connection pool statistics
+func (db *DB) Stats() PoolStats {
+	stat := db.Pool.Stat()
+	return PoolStats{
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		AcquiredConns:           stat.AcquiredConns(),
+		IdleConns:               stat.IdleConns(),
+		ConstructingConns:       stat.ConstructingConns(),
+		AcquireCount:            stat.AcquireCount(),
+		AcquireDuration:         stat.AcquireDuration(),
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		NewConnsCount:           stat.NewConnsCount(),
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+	}
+}
+
+// ReportStats logs pool statistics every interval until ctx is done. An
+// exhausted pool is logged as a warning.
+func (db *DB) ReportStats(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastWaits int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stats := db.Stats()
+		event := logger.Module("db").Info()
+		if stats.Exhausted() {
+			event = logger.Module("db").Warn()
+		}
+
+		event.
+			Int32("max_conns", stats.MaxConns).
+			Int32("total_conns", stats.TotalConns).
+			Int32("acquired_conns", stats.AcquiredConns).
+			Int32("idle_conns", stats.IdleConns).
+			Int64("acquire_count", stats.AcquireCount).
+			Dur("acquire_duration", stats.AcquireDuration).
+			Int64("waits_since_last", stats.EmptyAcquireCount-lastWaits).
+			Bool("exhausted", stats.Exhausted()).
+			Msg("Database pool stats")
+
+		lastWaits = stats.EmptyAcquireCount
+	}
+}
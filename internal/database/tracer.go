@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/logger"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QueryStats holds latency counters for one query name
+type QueryStats struct {
+	Count   int64         `json:"count"`
+	Errors  int64         `json:"errors"`
+	Slow    int64         `json:"slow"`
+	Total   time.Duration `json:"total_ns"`
+	Max     time.Duration `json:"max_ns"`
+	Average time.Duration `json:"avg_ns"`
+}
+
+// QueryMetrics collects per-query latency counters
+type QueryMetrics struct {
+	mu      sync.Mutex
+	queries map[string]*QueryStats
+}
+
+// NewQueryMetrics creates an empty query metrics collector
+func NewQueryMetrics() *QueryMetrics {
+	return &QueryMetrics{
+		queries: make(map[string]*QueryStats),
+	}
+}
+
+func (m *QueryMetrics) record(name string, duration time.Duration, failed, slow bool) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.queries[name]
+	if !ok {
+		stats = &QueryStats{}
+		m.queries[name] = stats
+	}
+
+	stats.Count++
+	stats.Total += duration
+	if duration > stats.Max {
+		stats.Max = duration
+	}
+	if failed {
+		stats.Errors++
+	}
+	if slow {
+		stats.Slow++
+	}
+}
+
+// Snapshot returns a copy of the current counters keyed by query name
+func (m *QueryMetrics) Snapshot() map[string]QueryStats {
+	snapshot := make(map[string]QueryStats)
+	if m == nil {
+		return snapshot
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, stats := range m.queries {
+		s := *stats
+		if s.Count > 0 {
+			s.Average = s.Total / time.Duration(s.Count)
+		}
+		snapshot[name] = s
+	}
+	return snapshot
+}
+
+// QueryTracer is a pgx tracer that times every query, records per-query
+// metrics and logs queries slower than a threshold
+type QueryTracer struct {
+	slowThreshold time.Duration
+	metrics       *QueryMetrics
+}
+
+// NewQueryTracer creates a tracer. A zero slowThreshold disables slow query
+// logging; metrics are still recorded.
+func NewQueryTracer(slowThreshold time.Duration, metrics *QueryMetrics) *QueryTracer {
+	return &QueryTracer{
+		slowThreshold: slowThreshold,
+		metrics:       metrics,
+	}
+}
+
+type queryTraceKey struct{}
+
+type queryTrace struct {
+	name  string
+	sql   string
+	start time.Time
+}
+
+// TraceQueryStart implements pgx.QueryTracer
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryTraceKey{}, &queryTrace{
+		name:  queryName(data.SQL),
+		sql:   data.SQL,
+		start: time.Now(),
+	})
+}
+
+// TraceQueryEnd implements pgx.QueryTracer
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	trace, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+
+	duration := time.Since(trace.start)
+	failed := data.Err != nil && data.Err != pgx.ErrNoRows
+	slow := t.slowThreshold > 0 && duration >= t.slowThreshold
+	t.metrics.record(trace.name, duration, failed, slow)
+
+	if slow {
+		logger.ModuleContext(ctx, "db").Warn().
+			Str("query", trace.name).
+			Dur("duration", duration).
+			Str("sql", compactSQL(trace.sql)).
+			Str("command_tag", data.CommandTag.String()).
+			Err(data.Err).
+			Msg("Slow database query")
+	}
+}
+
+// repositoryPackage prefixes the function names of repository methods
+const repositoryPackage = "/internal/repository."
+
+// queryName names a query after the repository method that issued it, e.g.
+// "ConversationRepository.GetMessages", falling back to the SQL command
+func queryName(sql string) string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if i := strings.Index(frame.Function, repositoryPackage); i >= 0 {
+			name := frame.Function[i+len(repositoryPackage):]
+			name = strings.NewReplacer("(*", "", ")", "").Replace(name)
+			// Closures inside a method count towards the method
+			if j := strings.Index(name, ".func"); j >= 0 {
+				name = name[:j]
+			}
+			return name
+		}
+		if !more {
+			break
+		}
+	}
+
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+	return "unknown"
+}
+
+// compactSQL collapses whitespace so queries fit on one log line
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
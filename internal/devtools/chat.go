@@ -0,0 +1,26 @@
+package devtools
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+//go:embed chat.html
+var chatHTML string
+
+var chatPage = template.Must(template.New("chat").Parse(chatHTML))
+
+// ChatPage serves a bare chat client that signs in with the demo user and
+// talks to the v1 API of the same server, so replies can be tried out
+// without the frontend
+func ChatPage(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+	return chatPage.Execute(c.Response(), map[string]string{
+		"Email":    DemoEmail,
+		"Password": DemoPassword,
+	})
+}
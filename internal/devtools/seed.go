@@ -0,0 +1,122 @@
+// Package devtools supports running the server locally without a frontend:
+// demo data to start from and a bare chat page for trying out replies.
+// Nothing here is wired up outside the dev command.
+package devtools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Demo user credentials, also prefilled on the chat page
+const (
+	DemoEmail    = "demo@example.com"
+	DemoPassword = "demo-password"
+	demoName     = "Demo User"
+)
+
+// demoConversation is a seeded conversation and its messages, alternating
+// between the user and the assistant
+type demoConversation struct {
+	title    string
+	messages []string
+}
+
+var demoConversations = []demoConversation{
+	{
+		title: "Quick dinner ideas",
+		messages: []string{
+			"What can I cook in 20 minutes with eggs, spinach and feta?",
+			"A spinach and feta omelette or a quick shakshuka-style skillet both work. Sauté the spinach, add the eggs, crumble the feta on top and finish under the grill for a minute.",
+			"Which one is better with bread?",
+			"The skillet: the eggs stay runny, so there is sauce to mop up.",
+		},
+	},
+	{
+		title: "Weekly meal prep",
+		messages: []string{
+			"Plan three lunches I can prep on Sunday.",
+			"1. Chicken, rice and roasted vegetables\n2. Lentil salad with feta and herbs\n3. Pasta salad with tuna, olives and tomatoes\n\nAll three keep for four days in the fridge.",
+		},
+	},
+}
+
+// Seeder creates the demo data
+type Seeder struct {
+	users *repository.UserRepository
+	convs *repository.ConversationRepository
+	tx    *repository.Transactor
+	hash  func(password string) (string, error)
+}
+
+// NewSeeder creates a seeder. hash hashes the demo user's password the way
+// sign-ins check it.
+func NewSeeder(users *repository.UserRepository, convs *repository.ConversationRepository, tx *repository.Transactor, hash func(string) (string, error)) *Seeder {
+	return &Seeder{users: users, convs: convs, tx: tx, hash: hash}
+}
+
+// Seed creates the demo user, an admin, with a few conversations. It does
+// nothing when the demo user exists, so conversations deleted while
+// testing stay deleted. It reports whether anything was created.
+func (s *Seeder) Seed(ctx context.Context) (bool, error) {
+	existing, err := s.users.GetByEmail(ctx, DemoEmail)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up demo user: %w", err)
+	}
+	if existing != nil {
+		return false, nil
+	}
+
+	hash, err := s.hash(DemoPassword)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash demo password: %w", err)
+	}
+
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		user := &models.User{
+			Username:     demoName,
+			Email:        DemoEmail,
+			PasswordHash: &hash,
+		}
+		if err := s.users.Create(ctx, user); err != nil {
+			return fmt.Errorf("failed to create demo user: %w", err)
+		}
+		if err := s.users.SetAdmin(ctx, user.ID, true); err != nil {
+			return fmt.Errorf("failed to make demo user an admin: %w", err)
+		}
+
+		for _, demo := range demoConversations {
+			title := demo.title
+			conversation := &models.Conversation{UserID: user.ID, Title: &title}
+			if err := s.convs.Create(ctx, conversation); err != nil {
+				return fmt.Errorf("failed to create demo conversation: %w", err)
+			}
+
+			for i, content := range demo.messages {
+				message := &models.Message{
+					ConversationID: conversation.ID,
+					SenderID:       user.ID,
+					SenderType:     models.SenderTypeUser,
+					Content:        content,
+				}
+				if i%2 == 1 {
+					message.SenderType = models.SenderTypeAgent
+					message.SenderID = uuid.Nil
+				}
+				if err := s.convs.CreateMessage(ctx, message); err != nil {
+					return fmt.Errorf("failed to create demo message: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,172 @@
+// Package events delivers live updates about a user's conversations to all
+// of the user's connections, so open tabs and devices can update their
+// conversation list without polling
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/pubsub"
+)
+
+// Event types
+const (
+	// TypeMessageCompleted is sent when an assistant reply has been saved.
+	// A conversation the client doesn't know yet was just created.
+	TypeMessageCompleted = "message_completed"
+
+	// TypeConversationRenamed is sent when a conversation's title changes
+	TypeConversationRenamed = "conversation_renamed"
+
+	// TypeMessageDeleted is sent when a message is deleted
+	TypeMessageDeleted = "message_deleted"
+
+	// TypeConversationRead is sent to the user alone when their read marker
+	// in a conversation moves, so their other devices clear its unread count
+	TypeConversationRead = "conversation_read"
+
+	// TypeScheduledPromptRan is sent after a scheduled prompt ran. Failed
+	// runs carry the error instead of a message ID.
+	TypeScheduledPromptRan = "scheduled_prompt_ran"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further events are dropped for it
+const subscriberBuffer = 32
+
+// Event is a change to one of the user's conversations
+type Event struct {
+	Type           string     `json:"type"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	MessageID      int64      `json:"message_id,omitempty"`
+	Title          string     `json:"title,omitempty"`
+	ScheduleID     *uuid.UUID `json:"schedule_id,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	Time           time.Time  `json:"time"`
+}
+
+// NewMessageCompleted creates a message_completed event
+func NewMessageCompleted(conversationID uuid.UUID, messageID int64) Event {
+	return Event{
+		Type:           TypeMessageCompleted,
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		Time:           time.Now().UTC(),
+	}
+}
+
+// NewConversationRenamed creates a conversation_renamed event
+func NewConversationRenamed(conversationID uuid.UUID, title string) Event {
+	return Event{
+		Type:           TypeConversationRenamed,
+		ConversationID: conversationID,
+		Title:          title,
+		Time:           time.Now().UTC(),
+	}
+}
+
+// NewMessageDeleted creates a message_deleted event
+func NewMessageDeleted(conversationID uuid.UUID, messageID int64) Event {
+	return Event{
+		Type:           TypeMessageDeleted,
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		Time:           time.Now().UTC(),
+	}
+}
+
+// NewConversationRead creates a conversation_read event for the last
+// message read
+func NewConversationRead(conversationID uuid.UUID, messageID int64) Event {
+	return Event{
+		Type:           TypeConversationRead,
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		Time:           time.Now().UTC(),
+	}
+}
+
+// NewScheduledPromptRan creates a scheduled_prompt_ran event. runErr is
+// nil for successful runs.
+func NewScheduledPromptRan(conversationID, scheduleID uuid.UUID, messageID int64, runErr error) Event {
+	event := Event{
+		Type:           TypeScheduledPromptRan,
+		ConversationID: conversationID,
+		ScheduleID:     &scheduleID,
+		MessageID:      messageID,
+		Time:           time.Now().UTC(),
+	}
+	if runErr != nil {
+		event.Error = runErr.Error()
+	}
+	return event
+}
+
+// Bus publishes events to every subscriber of a user
+type Bus interface {
+	// Publish sends an event to the user's current subscribers. Events are
+	// not stored: subscribers that connect later don't receive them.
+	Publish(ctx context.Context, userID uuid.UUID, event Event) error
+
+	// Subscribe returns a channel of the user's events, which is closed
+	// once ctx is done
+	Subscribe(ctx context.Context, userID uuid.UUID) (<-chan Event, error)
+}
+
+// pubsubBus is a Bus over a pub/sub channel per user
+type pubsubBus struct {
+	pubsub pubsub.PubSub
+}
+
+// NewBus creates a bus over ps. With a Redis-backed ps events reach the
+// user's connections on every instance.
+func NewBus(ps pubsub.PubSub) Bus {
+	return &pubsubBus{pubsub: ps}
+}
+
+func channel(userID uuid.UUID) string {
+	return "events:" + userID.String()
+}
+
+func (b *pubsubBus) Publish(ctx context.Context, userID uuid.UUID, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if err := b.pubsub.Publish(ctx, channel(userID), data); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+func (b *pubsubBus) Subscribe(ctx context.Context, userID uuid.UUID) (<-chan Event, error) {
+	messages, err := b.pubsub.Subscribe(ctx, channel(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+
+	out := make(chan Event, subscriberBuffer)
+	go func() {
+		defer close(out)
+
+		for data := range messages {
+			var event Event
+			if err := json.Unmarshal(data, &event); err != nil {
+				logger.ModuleContext(ctx, "events").Warn().Err(err).Msg("Ignoring malformed event")
+				continue
+			}
+			select {
+			case out <- event:
+			default:
+				// The subscriber is too slow; it refetches on reconnect
+			}
+		}
+	}()
+
+	return out, nil
+}
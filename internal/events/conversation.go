@@ -0,0 +1,34 @@
+package events
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+)
+
+// PublishConversation sends an event to the owner and every participant of
+// a conversation. Failures only cost live updates, so they are logged
+// rather than returned.
+func PublishConversation(ctx context.Context, bus Bus, participants *repository.ParticipantRepository, conversation *models.Conversation, event Event) {
+	log := logger.ModuleContext(ctx, "events")
+
+	recipients := []uuid.UUID{conversation.UserID}
+	list, err := participants.ListByConversation(ctx, conversation.ID)
+	if err != nil {
+		log.Warn().Err(err).Str("conversation_id", conversation.ID.String()).Msg("Failed to list event recipients")
+	}
+	for _, p := range list {
+		if p.UserID != conversation.UserID {
+			recipients = append(recipients, p.UserID)
+		}
+	}
+
+	for _, userID := range recipients {
+		if err := bus.Publish(ctx, userID, event); err != nil {
+			log.Warn().Err(err).Str("type", event.Type).Msg("Failed to publish event")
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package geoip looks up the country of client IPs
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/cache"
+)
+
+// Locator looks up the ISO 3166 country code of an IP. It returns "" when
+// the country is unknown, e.g. for private addresses.
+type Locator interface {
+	Country(ctx context.Context, ip string) (string, error)
+}
+
+// New returns an HTTP locator for the URL template, or one that knows no
+// countries when the template is empty
+func New(urlTemplate string, c cache.Cache) Locator {
+	if urlTemplate == "" {
+		return None{}
+	}
+	return NewHTTPLocator(urlTemplate, c)
+}
+
+// None knows no countries, which turns country checks off
+type None struct{}
+
+func (None) Country(ctx context.Context, ip string) (string, error) {
+	return "", nil
+}
+
+// cacheTTL is how long looked up countries are kept
+const cacheTTL = 24 * time.Hour
+
+// HTTPLocator asks a lookup service such as ipapi.co. The URL template has
+// "{ip}" replaced with the address, and the response body must be the
+// country code. Results, unknown countries included, are cached.
+type HTTPLocator struct {
+	urlTemplate string
+	client      *http.Client
+	cache       cache.Cache
+}
+
+// NewHTTPLocator creates a locator for the lookup service at urlTemplate
+func NewHTTPLocator(urlTemplate string, c cache.Cache) *HTTPLocator {
+	return &HTTPLocator{
+		urlTemplate: urlTemplate,
+		client:      &http.Client{Timeout: 3 * time.Second},
+		cache:       c,
+	}
+}
+
+func (l *HTTPLocator) Country(ctx context.Context, ip string) (string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsLinkLocalUnicast() {
+		return "", nil
+	}
+
+	key := "geoip:" + addr.String()
+	if cached, err := l.cache.Get(ctx, key); err == nil {
+		return string(cached), nil
+	}
+
+	lookupURL := strings.ReplaceAll(l.urlTemplate, "{ip}", url.PathEscape(addr.String()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lookupURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("geoip lookup returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return "", err
+	}
+
+	country := strings.ToUpper(strings.TrimSpace(string(body)))
+	if len(country) != 2 {
+		country = ""
+	}
+	_ = l.cache.Set(ctx, key, []byte(country), cacheTTL)
+	return country, nil
+}
@@ -0,0 +1,19 @@
+//go:build !grpc
+
+package grpcapi
+
+import (
+	"context"
+	"errors"
+)
+
+// Enabled reports whether the binary was built with gRPC support
+const Enabled = false
+
+// ErrDisabled is returned by Serve in binaries built without the grpc tag
+var ErrDisabled = errors.New("gRPC support is not compiled in; rebuild with -tags grpc")
+
+// Serve always fails without the grpc build tag
+func Serve(ctx context.Context, addr string, deps Deps) error {
+	return ErrDisabled
+}
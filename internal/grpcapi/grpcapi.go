@@ -0,0 +1,32 @@
+// Package grpcapi exposes the chat service over gRPC. The service is
+// defined in proto/chat/v1/chat.proto; the server is only compiled with
+// the grpc build tag, after generating the stubs with "make proto".
+package grpcapi
+
+import (
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/billing"
+	"github.com/shivaluma/eino-agent/internal/convlock"
+	"github.com/shivaluma/eino-agent/internal/events"
+	"github.com/shivaluma/eino-agent/internal/guardrails"
+	"github.com/shivaluma/eino-agent/internal/memory"
+	"github.com/shivaluma/eino-agent/internal/repository"
+)
+
+// Deps are the services the gRPC server is built on, shared with the HTTP
+// handlers
+type Deps struct {
+	ConvRepo     *repository.ConversationRepository
+	Tx           *repository.Transactor
+	Participants *repository.ParticipantRepository
+	SettingsRepo *repository.SettingsRepository
+	AuthSvc      *auth.Service
+	AIService    ai.Service
+	Events       events.Bus
+	Memories     *memory.Store
+	Guard        *guardrails.Guard
+	Usage        *billing.Recorder
+	Locks        convlock.Locker
+	Queue        *ai.Queue
+}
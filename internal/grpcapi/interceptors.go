@@ -0,0 +1,125 @@
+//go:build grpc
+
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authenticate validates the bearer token in the "authorization" metadata
+// and stores the same context values as the HTTP AuthMiddleware, so
+// auth.Service.GetUserClaimsFromContext works for RPCs too
+func authenticate(ctx context.Context, authSvc *auth.Service) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || values[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	scheme, tokenString, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata format")
+	}
+
+	token, err := authSvc.ValidateAccessToken(tokenString)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+
+	revoked, err := authSvc.IsAccessTokenRevoked(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	if revoked {
+		return nil, status.Error(codes.Unauthenticated, "token has been revoked")
+	}
+
+	userID, err := authSvc.ExtractUserIDFromToken(token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token claims")
+	}
+
+	username, err := authSvc.ExtractUsernameFromToken(token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token claims")
+	}
+
+	ctx = context.WithValue(ctx, "user_id", userID)
+	ctx = context.WithValue(ctx, "username", username)
+	ctx = context.WithValue(ctx, "access_token", token)
+	return logger.WithUserID(ctx, userID.String()), nil
+}
+
+// withRequestID reuses the caller's x-request-id metadata or generates one,
+// and continues the caller's trace from its traceparent metadata
+func withRequestID(ctx context.Context) context.Context {
+	requestID, traceparent := "", ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-request-id"); len(values) > 0 {
+			requestID = values[0]
+		}
+		if values := md.Get("traceparent"); len(values) > 0 {
+			traceparent = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = logger.GenerateRequestID()
+	}
+	return logger.WithTraceparent(logger.WithRequestID(ctx, requestID), traceparent)
+}
+
+func unaryAuthInterceptor(authSvc *auth.Service) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = withRequestID(ctx)
+		ctx, err := authenticate(ctx, authSvc)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := handler(ctx, req)
+		logRPC(ctx, info.FullMethod, err)
+		return resp, err
+	}
+}
+
+func streamAuthInterceptor(authSvc *auth.Service) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := withRequestID(ss.Context())
+		ctx, err := authenticate(ctx, authSvc)
+		if err != nil {
+			return err
+		}
+
+		err = handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		logRPC(ctx, info.FullMethod, err)
+		return err
+	}
+}
+
+// contextStream replaces the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func logRPC(ctx context.Context, method string, err error) {
+	code := status.Code(err)
+	event := logger.ModuleContext(ctx, "grpc").Info()
+	if code == codes.Internal || code == codes.Unknown {
+		event = logger.ModuleContext(ctx, "grpc").Error().Err(err)
+	}
+	event.Str("method", method).Str("code", code.String()).Msg("RPC completed")
+}
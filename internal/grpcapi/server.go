@@ -0,0 +1,605 @@
+//go:build grpc
+
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/ai/templates"
+	"github.com/shivaluma/eino-agent/internal/billing"
+	"github.com/shivaluma/eino-agent/internal/convlock"
+	"github.com/shivaluma/eino-agent/internal/events"
+	"github.com/shivaluma/eino-agent/internal/grpcapi/chatv1"
+	"github.com/shivaluma/eino-agent/internal/guardrails"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Enabled reports whether the binary was built with gRPC support
+const Enabled = true
+
+// historyLimit is how many recent messages are loaded for the context
+// strategy, as in the HTTP API
+const historyLimit = 50
+
+// Server implements chatv1.ChatServiceServer
+type Server struct {
+	chatv1.UnimplementedChatServiceServer
+	deps Deps
+}
+
+// NewServer creates the chat service
+func NewServer(deps Deps) *Server {
+	return &Server{deps: deps}
+}
+
+// Serve listens on addr until ctx is cancelled, then stops gracefully
+func Serve(ctx context.Context, addr string, deps Deps) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryAuthInterceptor(deps.AuthSvc)),
+		grpc.ChainStreamInterceptor(streamAuthInterceptor(deps.AuthSvc)),
+	)
+	chatv1.RegisterChatServiceServer(srv, NewServer(deps))
+
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+
+	logger.Module("grpc").Info().Str("addr", addr).Msg("gRPC server listening")
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// turn is a user message saved to its conversation and the AI request
+// answering it
+type turn struct {
+	conversation *models.Conversation
+	userMessage  *models.Message
+	request      *ai.ChatRequest
+}
+
+// Chat answers a message in one response
+func (s *Server) Chat(ctx context.Context, req *chatv1.ChatRequest) (*chatv1.ChatResponse, error) {
+	ctx, release, err := s.deps.Queue.Acquire(ctx)
+	if err != nil {
+		return nil, queueError(ctx, err)
+	}
+	defer release()
+
+	unlock, err := s.lock(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	t, err := s.begin(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Organization conversations answer on the organization's own keys
+	response, err := s.deps.AIService.Generate(ai.WithOrg(ctx, t.conversation.OrgID), t.request)
+	if err != nil {
+		return nil, generationError(err)
+	}
+
+	reply, err := s.saveReply(ctx, t, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chatv1.ChatResponse{
+		ConversationId: t.conversation.ID.String(),
+		UserMessage:    toMessage(*t.userMessage),
+		Reply:          toMessage(*reply),
+		Usage:          toUsage(response),
+	}, nil
+}
+
+// ChatStream answers a message chunk by chunk. A reply cut short by an error
+// or by cancelling the call is saved as far as it got, marked partial.
+func (s *Server) ChatStream(req *chatv1.ChatRequest, stream chatv1.ChatService_ChatStreamServer) error {
+	ctx, release, err := s.deps.Queue.Acquire(stream.Context())
+	if err != nil {
+		return queueError(stream.Context(), err)
+	}
+	defer release()
+
+	unlock, err := s.lock(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t, err := s.begin(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	err = stream.Send(&chatv1.ChatEvent{Event: &chatv1.ChatEvent_Started{Started: &chatv1.ChatStarted{
+		ConversationId: t.conversation.ID.String(),
+		UserMessageId:  t.userMessage.ID,
+	}}})
+	if err != nil {
+		return err
+	}
+
+	t.request.Progress = ai.ProgressFunc(func(ctx context.Context, status ai.Status) {
+		stream.Send(&chatv1.ChatEvent{Event: &chatv1.ChatEvent_Status{Status: &chatv1.ChatStatus{
+			Stage:    status.Stage,
+			Provider: status.Provider,
+			Model:    status.Model,
+			Tool:     status.Tool,
+		}}})
+	})
+
+	var content strings.Builder
+	response, err := s.deps.AIService.Stream(ai.WithOrg(ctx, t.conversation.OrgID), t.request, func(chunk string) error {
+		content.WriteString(chunk)
+		return stream.Send(&chatv1.ChatEvent{Event: &chatv1.ChatEvent_Chunk{Chunk: chunk}})
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			s.savePartialReply(ctx, t, content.String(), models.InterruptionCancelled)
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		s.savePartialReply(ctx, t, content.String(), models.InterruptionError)
+		return generationError(err)
+	}
+
+	reply, err := s.saveReply(ctx, t, response)
+	if err != nil {
+		return err
+	}
+
+	return stream.Send(&chatv1.ChatEvent{Event: &chatv1.ChatEvent_Completed{Completed: &chatv1.ChatCompleted{
+		Reply: toMessage(*reply),
+		Usage: toUsage(response),
+	}}})
+}
+
+// ListConversations lists the personal conversations the user takes part
+// in; organizations can't be selected over gRPC
+func (s *Server) ListConversations(ctx context.Context, req *chatv1.ListConversationsRequest) (*chatv1.ListConversationsResponse, error) {
+	userClaims, err := s.deps.AuthSvc.GetUserClaimsFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	limit, offset := page(req.GetLimit(), req.GetOffset(), 20)
+	conversations, err := s.deps.ConvRepo.GetByUserID(ctx, userClaims.UserID, nil, limit, offset)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch conversations")
+	}
+
+	resp := &chatv1.ListConversationsResponse{
+		Conversations: make([]*chatv1.Conversation, 0, len(conversations)),
+	}
+	for _, c := range conversations {
+		conversation := &chatv1.Conversation{
+			Id:           c.ID.String(),
+			Role:         c.Role,
+			Pinned:       c.Pinned,
+			MessageCount: int32(c.MessageCount),
+			CreatedAt:    timestamppb.New(c.CreatedAt),
+			UpdatedAt:    timestamppb.New(c.UpdatedAt),
+		}
+		if c.Title != nil {
+			conversation.Title = *c.Title
+		}
+		resp.Conversations = append(resp.Conversations, conversation)
+	}
+	return resp, nil
+}
+
+// GetMessages returns the messages of a conversation the user can read
+func (s *Server) GetMessages(ctx context.Context, req *chatv1.GetMessagesRequest) (*chatv1.GetMessagesResponse, error) {
+	userClaims, err := s.deps.AuthSvc.GetUserClaimsFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(req.GetConversationId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid conversation ID")
+	}
+
+	conversation, err := s.deps.ConvRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch conversation")
+	}
+	if conversation == nil {
+		return nil, status.Error(codes.NotFound, "conversation not found")
+	}
+
+	role, err := s.role(ctx, conversation, userClaims.UserID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to check conversation access")
+	}
+	if !models.CanRead(role) {
+		return nil, status.Error(codes.PermissionDenied, "access denied")
+	}
+
+	limit, offset := page(req.GetLimit(), req.GetOffset(), 50)
+	messages, err := s.deps.ConvRepo.GetMessages(ctx, conversationID, limit, offset)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch messages")
+	}
+
+	resp := &chatv1.GetMessagesResponse{Messages: make([]*chatv1.Message, 0, len(messages))}
+	for _, m := range messages {
+		resp.Messages = append(resp.Messages, toMessage(m))
+	}
+	return resp, nil
+}
+
+// lock takes the lock of the request's conversation, as in the HTTP API, so
+// one message at a time is answered in it. A new conversation needs none.
+// Only users who may post to the conversation can take its lock; begin
+// checks access again once it is held.
+func (s *Server) lock(ctx context.Context, req *chatv1.ChatRequest) (convlock.Unlock, error) {
+	conversationID, err := uuid.Parse(req.GetConversationId())
+	if err != nil {
+		// begin rejects an invalid ID
+		return func() {}, nil
+	}
+
+	userClaims, err := s.deps.AuthSvc.GetUserClaimsFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	conversation, err := s.deps.ConvRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch conversation")
+	}
+	if conversation != nil {
+		role, err := s.role(ctx, conversation, userClaims.UserID)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to check conversation access")
+		}
+		if !models.CanWrite(role) {
+			return nil, status.Error(codes.PermissionDenied, "access denied")
+		}
+	}
+
+	unlock, err := s.deps.Locks.Lock(ctx, conversationID)
+	if errors.Is(err, convlock.ErrBusy) {
+		return nil, status.Error(codes.Aborted, "another message is still being answered in this conversation")
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return nil, status.Error(codes.Internal, "failed to lock conversation")
+	}
+	return unlock, nil
+}
+
+// queueError reports a message turned away by a full generation queue, as
+// the HTTP API's 503
+func queueError(ctx context.Context, err error) error {
+	if errors.Is(err, ai.ErrOverloaded) {
+		return status.Error(codes.Unavailable, "too many messages are being answered, try again later")
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return status.Error(codes.Internal, "failed to queue message")
+}
+
+// generationError reports a failed generation, as Unavailable when every
+// provider's circuit is open
+func generationError(err error) error {
+	if errors.Is(err, ai.ErrProvidersUnavailable) {
+		return status.Error(codes.Unavailable, "AI providers are unavailable, try again later")
+	}
+	return status.Error(codes.Internal, "failed to generate response")
+}
+
+// begin validates a chat request, saves the user message (creating the
+// conversation for a new or unknown ID) and builds the AI request with the
+// conversation history and the user's settings
+func (s *Server) begin(ctx context.Context, req *chatv1.ChatRequest) (*turn, error) {
+	userClaims, err := s.deps.AuthSvc.GetUserClaimsFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	if req.GetMessage() == "" {
+		return nil, status.Error(codes.InvalidArgument, "message is required")
+	}
+	if utf8.RuneCountInString(req.GetMessage()) > models.MaxMessageLength {
+		return nil, status.Errorf(codes.InvalidArgument, "message exceeds the %d character limit", models.MaxMessageLength)
+	}
+
+	check := s.deps.Guard.Check(ctx, guardrails.TargetMessage, req.GetMessage())
+	if check.Blocked {
+		return nil, status.Errorf(codes.InvalidArgument, "message was blocked by guardrail rule %s", check.Rule)
+	}
+	message := check.Text
+
+	settings, err := s.deps.SettingsRepo.GetByUserID(ctx, userClaims.UserID)
+	if err != nil {
+		logger.ModuleContext(ctx, "grpc").Warn().Err(err).Msg("Failed to load user settings")
+	}
+	if settings == nil {
+		settings = &models.UserSettings{UserID: userClaims.UserID}
+	}
+
+	persona := req.GetPersona()
+	systemPrompt := req.GetSystemPrompt()
+	if persona == "" && systemPrompt == "" && settings.Persona != nil {
+		persona = *settings.Persona
+	}
+	if persona != "" && !templates.IsSelectablePersona(persona) {
+		return nil, status.Error(codes.InvalidArgument, "unknown persona")
+	}
+	if len(systemPrompt) > models.MaxSystemPromptLength {
+		return nil, status.Error(codes.InvalidArgument, "system prompt is too long")
+	}
+
+	language, err := resolveLanguage(req.GetLanguage(), settings.Language)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var conversation *models.Conversation
+	var history []*schema.Message
+	var create func(ctx context.Context, conversation *models.Conversation) error
+
+	conversationID := uuid.Nil
+	if req.GetConversationId() != "" {
+		conversationID, err = uuid.Parse(req.GetConversationId())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid conversation ID")
+		}
+
+		conversation, err = s.deps.ConvRepo.GetByID(ctx, conversationID)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to fetch conversation")
+		}
+	}
+
+	if conversation != nil {
+		role, err := s.role(ctx, conversation, userClaims.UserID)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to check conversation access")
+		}
+		if !models.CanWrite(role) {
+			return nil, status.Error(codes.PermissionDenied, "access denied")
+		}
+		if err := s.deps.Usage.CheckOrgQuota(ctx, conversation.OrgID); err != nil {
+			if errors.Is(err, billing.ErrQuotaExceeded) {
+				return nil, status.Error(codes.ResourceExhausted, err.Error())
+			}
+			return nil, status.Error(codes.Internal, "failed to check organization quota")
+		}
+
+		messages, err := s.deps.ConvRepo.GetRecentMessages(ctx, conversation.ID, historyLimit)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to fetch messages")
+		}
+		for _, msg := range messages {
+			switch msg.SenderType {
+			case models.SenderTypeUser:
+				history = append(history, schema.UserMessage(msg.Content))
+			case models.SenderTypeAgent:
+				history = append(history, schema.AssistantMessage(msg.Content, nil))
+			}
+		}
+	} else {
+		title, err := s.deps.AIService.GenerateTitle(ctx, message, language, nil)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to generate title")
+		}
+
+		conversation = &models.Conversation{UserID: userClaims.UserID, Title: &title}
+		if persona == templates.AutoPersona {
+			persona = ""
+			if systemPrompt == "" {
+				persona, err = s.deps.AIService.RoutePersona(ctx, message)
+				if err != nil {
+					logger.ModuleContext(ctx, "grpc").Warn().Err(err).Msg("Failed to route persona, using default")
+					persona = templates.DefaultPersona
+				}
+			}
+		}
+		if persona != "" {
+			conversation.Persona = &persona
+		}
+		if systemPrompt != "" {
+			conversation.SystemPrompt = &systemPrompt
+		}
+
+		create = s.deps.ConvRepo.Create
+		if conversationID != uuid.Nil {
+			conversation.ID = conversationID
+			create = s.deps.ConvRepo.CreateWithID
+		}
+	}
+
+	userMessage := &models.Message{
+		SenderID:   userClaims.UserID,
+		SenderType: models.SenderTypeUser,
+		Content:    message,
+	}
+
+	err = s.deps.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if create != nil {
+			if err := create(ctx, conversation); err != nil {
+				return fmt.Errorf("failed to create conversation: %w", err)
+			}
+		}
+
+		userMessage.ConversationID = conversation.ID
+		if err := s.deps.ConvRepo.CreateMessage(ctx, userMessage); err != nil {
+			return fmt.Errorf("failed to save message: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.ModuleContext(ctx, "grpc").Error().Err(err).Msg("Failed to save message")
+		return nil, status.Error(codes.Internal, "failed to save message")
+	}
+
+	if err := s.deps.ConvRepo.UpdateTimestamp(ctx, conversation.ID); err != nil {
+		logger.ModuleContext(ctx, "grpc").Warn().Err(err).Msg("Failed to update conversation timestamp")
+	}
+
+	request := &ai.ChatRequest{
+		Message:        message,
+		ConversationID: conversation.ID.String(),
+		UserID:         userClaims.UserID.String(),
+		History:        history,
+		Language:       language,
+		Temperature:    settings.Temperature,
+		MaxTokens:      settings.MaxTokens,
+		Memories:       s.deps.Memories.Recall(ctx, userClaims.UserID),
+	}
+	if settings.Model != nil {
+		request.Model = *settings.Model
+	}
+	if conversation.Model != nil {
+		request.Model = *conversation.Model
+	}
+	if conversation.Persona != nil {
+		request.Persona = *conversation.Persona
+	}
+	if conversation.SystemPrompt != nil {
+		request.SystemPrompt = *conversation.SystemPrompt
+	}
+	if conversation.ContextStrategy != nil {
+		request.ContextStrategy = *conversation.ContextStrategy
+	}
+
+	return &turn{conversation: conversation, userMessage: userMessage, request: request}, nil
+}
+
+// saveReply stores the assistant's answer to a turn
+func (s *Server) saveReply(ctx context.Context, t *turn, response *ai.ChatResponse) (*models.Message, error) {
+	reply := &models.Message{
+		ConversationID: t.conversation.ID,
+		SenderID:       uuid.Nil,
+		SenderType:     models.SenderTypeAgent,
+		Content:        response.Content,
+		Metadata:       response.Metadata(t.request).JSON(),
+	}
+	err := s.deps.ConvRepo.CreateMessage(ctx, reply)
+	s.deps.Usage.Record(ctx, t.userMessage.SenderID, t.conversation.ID, reply.ID, response)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to save AI response")
+	}
+	events.PublishConversation(ctx, s.deps.Events, s.deps.Participants, t.conversation, events.NewMessageCompleted(t.conversation.ID, reply.ID))
+	s.deps.Memories.Learn(ctx, t.userMessage.SenderID, t.conversation.ID, t.userMessage.Content)
+	return reply, nil
+}
+
+// savePartialReply stores what was generated of an interrupted answer
+func (s *Server) savePartialReply(ctx context.Context, t *turn, content, interruption string) {
+	if content == "" {
+		return
+	}
+
+	metadata := (&ai.ChatResponse{}).Metadata(t.request)
+	metadata.Partial = true
+	metadata.Interruption = interruption
+
+	ctx = context.WithoutCancel(ctx)
+	reply := &models.Message{
+		ConversationID: t.conversation.ID,
+		SenderID:       uuid.Nil,
+		SenderType:     models.SenderTypeAgent,
+		Content:        content,
+		Metadata:       metadata.JSON(),
+	}
+	if err := s.deps.ConvRepo.CreateMessage(ctx, reply); err != nil {
+		logger.ModuleContext(ctx, "grpc").Error().Err(err).Msg("Failed to save partial reply")
+		return
+	}
+	events.PublishConversation(ctx, s.deps.Events, s.deps.Participants, t.conversation, events.NewMessageCompleted(t.conversation.ID, reply.ID))
+}
+
+// role is the user's participant role in a conversation, as in the HTTP API
+func (s *Server) role(ctx context.Context, conversation *models.Conversation, userID uuid.UUID) (string, error) {
+	if conversation.UserID == userID && conversation.OrgID == nil {
+		return models.ParticipantRoleOwner, nil
+	}
+	return s.deps.Participants.GetRole(ctx, conversation.ID, userID)
+}
+
+// resolveLanguage picks the requested language, then the user's preference,
+// then the default; gRPC has no Accept-Language to fall back on
+func resolveLanguage(requested string, preferred *string) (string, error) {
+	if requested != "" {
+		language := templates.NormalizeLanguage(requested)
+		if language == "" {
+			return "", fmt.Errorf("unsupported language: %s", requested)
+		}
+		return language, nil
+	}
+	if preferred != nil {
+		if language := templates.NormalizeLanguage(*preferred); language != "" {
+			return language, nil
+		}
+	}
+	return templates.DefaultLanguage, nil
+}
+
+// page applies the HTTP API's paging rules: limits between 1 and 100,
+// falling back to the default
+func page(limit, offset int32, defaultLimit int) (int, int) {
+	l := defaultLimit
+	if limit > 0 && limit <= 100 {
+		l = int(limit)
+	}
+	return l, max(int(offset), 0)
+}
+
+func toMessage(m models.Message) *chatv1.Message {
+	msg := &chatv1.Message{
+		Id:             m.ID,
+		ConversationId: m.ConversationID.String(),
+		Role:           chatv1.Role_ROLE_ASSISTANT,
+		Content:        m.Content,
+		CreatedAt:      timestamppb.New(m.CreatedAt),
+	}
+	if m.SenderType == models.SenderTypeUser {
+		msg.Role = chatv1.Role_ROLE_USER
+		msg.AuthorId = m.SenderID.String()
+	}
+	return msg
+}
+
+func toUsage(response *ai.ChatResponse) *chatv1.Usage {
+	if response.Usage == nil {
+		return nil
+	}
+	return &chatv1.Usage{
+		Provider:         response.Provider,
+		Model:            response.Model,
+		PromptTokens:     int32(response.Usage.PromptTokens),
+		CompletionTokens: int32(response.Usage.CompletionTokens),
+		TotalTokens:      int32(response.Usage.TotalTokens),
+	}
+}
@@ -0,0 +1,225 @@
+// Package guardrails screens text before it reaches the model for prompt
+// injection and jailbreak attempts: user messages, and content retrieved
+// for the model such as tool results. Heuristic rules match patterns and an
+// optional classifier asks the model itself; a match blocks the text,
+// strips the matching part or only logs a warning.
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+// Target is where checked text comes from
+type Target string
+
+const (
+	// TargetMessage is a message written by a user
+	TargetMessage Target = "message"
+
+	// TargetContext is content retrieved for the model, e.g. tool results
+	TargetContext Target = "context"
+)
+
+// Action is what happens to text matching a rule
+type Action string
+
+const (
+	// ActionBlock rejects the text
+	ActionBlock Action = "block"
+
+	// ActionStrip removes the matching parts and lets the rest through
+	ActionStrip Action = "strip"
+
+	// ActionWarn lets the text through unchanged and logs the match
+	ActionWarn Action = "warn"
+)
+
+func (a Action) valid() bool {
+	return a == ActionBlock || a == ActionStrip || a == ActionWarn
+}
+
+// ClassifierRule is the name the classifier's verdicts are counted under
+const ClassifierRule = "classifier"
+
+// Classifier decides whether text is an injection or jailbreak attempt.
+// ai.Service implements it by asking the model.
+type Classifier interface {
+	DetectInjection(ctx context.Context, text string) (bool, error)
+}
+
+// Config controls the guard
+type Config struct {
+	// Enabled turns checking on
+	Enabled bool
+
+	// RulesFile is a YAML or JSON file adding, replacing or disabling
+	// rules; empty keeps the built-in rules
+	RulesFile string
+
+	// Classifier also asks the model about text that passed the rules.
+	// It costs a model call per check.
+	Classifier bool
+
+	// ClassifierAction is what happens to text the classifier flags:
+	// block or warn, as there is no matching part to strip
+	ClassifierAction Action
+}
+
+// Result is the outcome of a check
+type Result struct {
+	// Text is the text to pass on, with stripped parts removed
+	Text string
+
+	// Blocked is set when the text must not reach the model; Rule names
+	// the rule that blocked it
+	Blocked bool
+	Rule    string
+
+	// Stripped and Warnings name the rules that stripped parts of the
+	// text or only warned
+	Stripped []string
+	Warnings []string
+}
+
+// BlockedError is returned for text a rule blocked
+type BlockedError struct {
+	Rule string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("content blocked by guardrail rule %s", e.Rule)
+}
+
+// Guard checks text against the rules and the classifier
+type Guard struct {
+	config     Config
+	rules      []Rule
+	classifier Classifier
+	metrics    *Metrics
+}
+
+// New loads the rules. A disabled guard passes all text through.
+func New(config Config) (*Guard, error) {
+	g := &Guard{config: config, metrics: NewMetrics()}
+	if !config.Enabled {
+		return g, nil
+	}
+
+	if config.Classifier && config.ClassifierAction != ActionBlock && config.ClassifierAction != ActionWarn {
+		return nil, fmt.Errorf("guardrail classifier action must be block or warn, got %q", config.ClassifierAction)
+	}
+
+	rules, err := loadRules(config.RulesFile)
+	if err != nil {
+		return nil, err
+	}
+	g.rules = rules
+	return g, nil
+}
+
+// SetClassifier sets the classifier used when Config.Classifier is on. The
+// AI service is created after the guard, which screens its tool results,
+// so it is set before the server starts handling requests.
+func (g *Guard) SetClassifier(c Classifier) {
+	g.classifier = c
+}
+
+// Enabled reports whether text is checked
+func (g *Guard) Enabled() bool {
+	return g.config.Enabled
+}
+
+// Rules returns the active rules
+func (g *Guard) Rules() []Rule {
+	return g.rules
+}
+
+// Metrics returns the per-rule counters
+func (g *Guard) Metrics() *Metrics {
+	return g.metrics
+}
+
+// Check runs the rules for target over text in order, then the
+// classifier. The first blocking match stops the check; text left empty by
+// stripping is blocked as well.
+func (g *Guard) Check(ctx context.Context, target Target, text string) Result {
+	return g.check(ctx, target, "", text)
+}
+
+// FilterContext checks content retrieved from source, returning the text to
+// pass on or a *BlockedError. It matches ai.ContextFilter.
+func (g *Guard) FilterContext(ctx context.Context, source, content string) (string, error) {
+	result := g.check(ctx, TargetContext, source, content)
+	if result.Blocked {
+		return "", &BlockedError{Rule: result.Rule}
+	}
+	return result.Text, nil
+}
+
+// check is Check with the source of the text added to the logs
+func (g *Guard) check(ctx context.Context, target Target, source, text string) Result {
+	result := Result{Text: text}
+	if !g.config.Enabled || strings.TrimSpace(text) == "" {
+		return result
+	}
+
+	logCtx := logger.ModuleContext(ctx, "guardrails").With().Str("target", string(target))
+	if source != "" {
+		logCtx = logCtx.Str("source", source)
+	}
+	log := logCtx.Logger()
+
+	for i := range g.rules {
+		rule := &g.rules[i]
+		if !rule.appliesTo(target) || !rule.pattern.MatchString(result.Text) {
+			continue
+		}
+
+		g.metrics.match(rule.Name, rule.Action)
+		log.Warn().Str("rule", rule.Name).Str("action", string(rule.Action)).Msg("Guardrail rule matched")
+
+		switch rule.Action {
+		case ActionBlock:
+			result.Blocked = true
+			result.Rule = rule.Name
+			return result
+		case ActionStrip:
+			result.Text = rule.pattern.ReplaceAllString(result.Text, "")
+			result.Stripped = append(result.Stripped, rule.Name)
+			if strings.TrimSpace(result.Text) == "" {
+				result.Blocked = true
+				result.Rule = rule.Name
+				return result
+			}
+		case ActionWarn:
+			result.Warnings = append(result.Warnings, rule.Name)
+		}
+	}
+
+	if g.config.Classifier && g.classifier != nil {
+		flagged, err := g.classifier.DetectInjection(ctx, result.Text)
+		if err != nil {
+			// The rules already ran, so a classifier outage lets text
+			// through rather than failing every request
+			log.Warn().Err(err).Msg("Guardrail classifier failed")
+			return result
+		}
+		if flagged {
+			g.metrics.match(ClassifierRule, g.config.ClassifierAction)
+			log.Warn().Str("rule", ClassifierRule).Str("action", string(g.config.ClassifierAction)).Msg("Guardrail classifier flagged text")
+
+			if g.config.ClassifierAction == ActionBlock {
+				result.Blocked = true
+				result.Rule = ClassifierRule
+				return result
+			}
+			result.Warnings = append(result.Warnings, ClassifierRule)
+		}
+	}
+
+	return result
+}
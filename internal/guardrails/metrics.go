@@ -0,0 +1,59 @@
+package guardrails
+
+import (
+	"sync"
+)
+
+// RuleStats holds counters for a single rule
+type RuleStats struct {
+	Matches  int64 `json:"matches"`
+	Blocked  int64 `json:"blocked"`
+	Stripped int64 `json:"stripped"`
+	Warned   int64 `json:"warned"`
+}
+
+// Metrics collects per-rule counters
+type Metrics struct {
+	mu    sync.Mutex
+	rules map[string]*RuleStats
+}
+
+// NewMetrics creates an empty metrics collector
+func NewMetrics() *Metrics {
+	return &Metrics{
+		rules: make(map[string]*RuleStats),
+	}
+}
+
+// match counts a rule matching, and the action that was taken
+func (m *Metrics) match(rule string, action Action) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.rules[rule]
+	if !ok {
+		stats = &RuleStats{}
+		m.rules[rule] = stats
+	}
+	stats.Matches++
+	switch action {
+	case ActionBlock:
+		stats.Blocked++
+	case ActionStrip:
+		stats.Stripped++
+	case ActionWarn:
+		stats.Warned++
+	}
+}
+
+// Snapshot returns a copy of the current counters keyed by rule name
+func (m *Metrics) Snapshot() map[string]RuleStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]RuleStats, len(m.rules))
+	for name, stats := range m.rules {
+		snapshot[name] = *stats
+	}
+	return snapshot
+}
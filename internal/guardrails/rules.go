@@ -0,0 +1,198 @@
+package guardrails
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is a heuristic check: text matching Pattern gets Action
+type Rule struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Pattern     string   `json:"pattern"`
+	Action      Action   `json:"action"`
+	Targets     []Target `json:"targets"`
+
+	pattern *regexp.Regexp
+}
+
+// appliesTo reports whether the rule checks text from target
+func (r *Rule) appliesTo(target Target) bool {
+	for _, t := range r.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleSpec is a rule as written in the built-in list and the rules file
+type ruleSpec struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description" yaml:"description"`
+	Pattern     string   `json:"pattern" yaml:"pattern"`
+	Action      Action   `json:"action" yaml:"action"`
+	Targets     []Target `json:"targets" yaml:"targets"`
+
+	// Disabled drops a built-in rule of the same name
+	Disabled bool `json:"disabled" yaml:"disabled"`
+}
+
+// rulesFile is the format of the rules file
+type rulesFile struct {
+	Rules []ruleSpec `json:"rules" yaml:"rules"`
+}
+
+// builtinRules catch the most common injection and jailbreak phrasings in
+// English and Vietnamese
+var builtinRules = []ruleSpec{
+	{
+		Name:        "ignore_instructions",
+		Description: "Asks the model to ignore or forget its instructions",
+		Pattern:     `(?i)\b(ignore|disregard|forget|override)\b.{0,40}\b(previous|prior|above|earlier|all|your|system)\b.{0,20}\b(instructions?|prompts?|rules|guidelines|directives)\b|(bỏ qua|quên|phớt lờ).{0,40}(hướng dẫn|chỉ dẫn|quy tắc|lệnh).{0,20}(trước|trên|hệ thống)`,
+		Action:      ActionBlock,
+		Targets:     []Target{TargetMessage, TargetContext},
+	},
+	{
+		Name:        "reveal_system_prompt",
+		Description: "Asks the model to print its system prompt",
+		Pattern:     `(?i)\b(reveal|show|print|repeat|output|display|leak)\b.{0,30}\b(system|hidden|initial|original)\s+(prompt|instructions?|message)|(tiết lộ|in ra|hiển thị|cho xem).{0,30}(system prompt|lời nhắc hệ thống|hướng dẫn hệ thống)`,
+		Action:      ActionBlock,
+		Targets:     []Target{TargetMessage, TargetContext},
+	},
+	{
+		Name:        "jailbreak_persona",
+		Description: "Well-known jailbreak personas and modes",
+		Pattern:     `\bDAN\b|(?i:\b(do anything now|developer mode|jailbreak(ed)?|god mode|unfiltered mode|no restrictions mode)\b)`,
+		Action:      ActionWarn,
+		Targets:     []Target{TargetMessage, TargetContext},
+	},
+	{
+		Name:        "role_override",
+		Description: "Tries to give the model a new identity or rules",
+		Pattern:     `(?i)\b(you are now|from now on,? you (are|will)|act as if you have no|pretend (that )?you (are|have) no)\b`,
+		Action:      ActionWarn,
+		Targets:     []Target{TargetMessage},
+	},
+	{
+		Name:        "fake_role_markers",
+		Description: "Chat template tokens and role headers that impersonate the system",
+		Pattern:     `(?i)<\|(im_start|im_end|system|endoftext)\|>|\[/?(INST|SYS)\]|<</?SYS>>|(^|\n)\s*#{2,}\s*(system|instructions?)\s*:?\s*(\n|$)`,
+		Action:      ActionStrip,
+		Targets:     []Target{TargetMessage, TargetContext},
+	},
+	{
+		Name:        "injected_instructions",
+		Description: "Retrieved content addressing the model instead of the user",
+		Pattern:     `(?i)\b(AI|assistant|language model|LLM|chatbot)s?\b.{0,20}\b(must|should|are instructed to|need to)\b.{0,20}\b(ignore|say|tell the user|respond|reply|output)\b`,
+		Action:      ActionBlock,
+		Targets:     []Target{TargetContext},
+	},
+	{
+		Name:        "invisible_characters",
+		Description: "Zero-width and bidi control characters that hide text",
+		Pattern:     `[\x{200B}-\x{200F}\x{202A}-\x{202E}\x{2060}-\x{2064}\x{2066}-\x{2069}\x{FEFF}\x{E0000}-\x{E007F}]`,
+		Action:      ActionStrip,
+		Targets:     []Target{TargetMessage, TargetContext},
+	},
+}
+
+// loadRules compiles the built-in rules, then adds, replaces or disables
+// them with the rules in path, a YAML or JSON file. An empty path keeps the
+// built-in rules.
+func loadRules(path string) ([]Rule, error) {
+	specs := append([]ruleSpec(nil), builtinRules...)
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read guardrail rules file: %w", err)
+		}
+
+		var file rulesFile
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			err = yaml.Unmarshal(data, &file)
+		case ".json":
+			err = json.Unmarshal(data, &file)
+		default:
+			return nil, fmt.Errorf("unsupported guardrail rules file format: %s", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse guardrail rules file %s: %w", path, err)
+		}
+
+		for _, spec := range file.Rules {
+			if spec.Name == "" {
+				return nil, fmt.Errorf("guardrail rule without a name in %s", path)
+			}
+			replaced := false
+			for i := range specs {
+				if specs[i].Name == spec.Name {
+					specs[i] = spec
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				specs = append(specs, spec)
+			}
+		}
+	}
+
+	rules := make([]Rule, 0, len(specs))
+	for _, spec := range specs {
+		if spec.Disabled {
+			continue
+		}
+		rule, err := compileRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// compileRule checks a rule spec, defaulting its targets to user messages
+func compileRule(spec ruleSpec) (Rule, error) {
+	if spec.Name == ClassifierRule {
+		return Rule{}, fmt.Errorf("guardrail rule name %s is reserved", ClassifierRule)
+	}
+	if !spec.Action.valid() {
+		return Rule{}, fmt.Errorf("guardrail rule %s has unknown action %q", spec.Name, spec.Action)
+	}
+
+	if spec.Pattern == "" {
+		return Rule{}, fmt.Errorf("guardrail rule %s has no pattern", spec.Name)
+	}
+	pattern, err := regexp.Compile(spec.Pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("guardrail rule %s has an invalid pattern: %w", spec.Name, err)
+	}
+
+	targets := spec.Targets
+	if len(targets) == 0 {
+		targets = []Target{TargetMessage}
+	}
+	for _, t := range targets {
+		if t != TargetMessage && t != TargetContext {
+			return Rule{}, fmt.Errorf("guardrail rule %s has unknown target %q", spec.Name, t)
+		}
+	}
+
+	return Rule{
+		Name:        spec.Name,
+		Description: spec.Description,
+		Pattern:     spec.Pattern,
+		Action:      spec.Action,
+		Targets:     targets,
+		pattern:     pattern,
+	}, nil
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/audit"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/guardrails"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/queue"
+	"github.com/shivaluma/eino-agent/internal/scheduler"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type AdminHandler struct {
+	authSvc   *auth.Service
+	auditor   *audit.Auditor
+	aiMetrics *ai.Metrics
+	breakers  *ai.Breakers
+	db        *database.DB
+	runtime   *config.Watcher
+	login     *auth.LoginGuard
+	jobs      *scheduler.Scheduler
+	tasks     queue.Queue
+	guard     *guardrails.Guard
+	aiQueue   *ai.Queue
+}
+
+func NewAdminHandler(authSvc *auth.Service, auditor *audit.Auditor, aiMetrics *ai.Metrics, breakers *ai.Breakers, db *database.DB, runtime *config.Watcher, login *auth.LoginGuard, jobs *scheduler.Scheduler, tasks queue.Queue, guard *guardrails.Guard, aiQueue *ai.Queue) *AdminHandler {
+	return &AdminHandler{
+		authSvc:   authSvc,
+		auditor:   auditor,
+		aiMetrics: aiMetrics,
+		breakers:  breakers,
+		db:        db,
+		runtime:   runtime,
+		login:     login,
+		jobs:      jobs,
+		tasks:     tasks,
+		guard:     guard,
+		aiQueue:   aiQueue,
+	}
+}
+
+// GetAuditEvents returns audit events filtered by user, action and time range.
+// Query params: user_id, action, from, to (RFC3339), limit, offset.
+func (h *AdminHandler) GetAuditEvents(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	filter := &models.AuditEventFilter{
+		Action: c.QueryParam("action"),
+		Limit:  50,
+		Offset: 0,
+	}
+
+	if userIDStr := c.QueryParam("user_id"); userIDStr != "" {
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			return apierror.BadRequest("Invalid user_id")
+		}
+		filter.UserID = &userID
+	}
+
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		from, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return apierror.BadRequest("Invalid from timestamp, expected RFC3339")
+		}
+		filter.From = &from
+	}
+
+	if toStr := c.QueryParam("to"); toStr != "" {
+		to, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return apierror.BadRequest("Invalid to timestamp, expected RFC3339")
+		}
+		filter.To = &to
+	}
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 200 {
+			filter.Limit = parsedLimit
+		}
+	}
+
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			filter.Offset = parsedOffset
+		}
+	}
+
+	events, err := h.auditor.List(c.Request().Context(), filter)
+	if err != nil {
+		return apierror.Internal("Failed to fetch audit events")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionAdminQuery, &userClaims.UserID, true, map[string]interface{}{
+		"filter_user_id": c.QueryParam("user_id"),
+		"filter_action":  filter.Action,
+		"filter_from":    c.QueryParam("from"),
+		"filter_to":      c.QueryParam("to"),
+	})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"events": events,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// GetAIMetrics returns per-provider request, retry and fallback counters,
+// per-model latencies, the providers' circuits and the state of the
+// generation queue
+func (h *AdminHandler) GetAIMetrics(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"providers": h.aiMetrics.Snapshot(),
+		"models":    h.aiMetrics.Models(),
+		"circuits":  h.breakers.Snapshot(),
+		"queue":     h.aiQueue.Stats(),
+	})
+}
+
+// GetGuardrails returns the active guardrail rules and how often each one
+// matched
+func (h *AdminHandler) GetGuardrails(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"enabled": h.guard.Enabled(),
+		"rules":   h.guard.Rules(),
+		"metrics": h.guard.Metrics().Snapshot(),
+	})
+}
+
+// GetDBStats returns database connection pool statistics and per-query
+// latency metrics
+func (h *AdminHandler) GetDBStats(c echo.Context) error {
+	stats := h.db.Stats()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"pool":      stats,
+		"exhausted": stats.Exhausted(),
+		"queries":   h.db.Queries.Snapshot(),
+	})
+}
+
+// GetLoginStats returns failed login, lockout and blocked attempt counters
+func (h *AdminHandler) GetLoginStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.login.Stats())
+}
+
+// GetRuntimeConfig returns the active runtime settings snapshot
+func (h *AdminHandler) GetRuntimeConfig(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.runtime.Current())
+}
+
+// ReloadConfig re-reads the reloadable settings (log level, rate limits,
+// default model, personas). An invalid config keeps the active settings.
+func (h *AdminHandler) ReloadConfig(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	rc, err := h.runtime.Reload()
+	if err != nil {
+		h.auditor.RecordRequest(c, models.AuditActionConfigReload, &userClaims.UserID, false, map[string]interface{}{
+			"error": err.Error(),
+		})
+		return apierror.Unprocessable("Failed to reload config: " + err.Error())
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionConfigReload, &userClaims.UserID, true, nil)
+	return c.JSON(http.StatusOK, rc)
+}
+
+// GetLogging returns the active log level, per-module levels and sampling,
+// and the delivery counters of the external log sink if one is in use
+func (h *AdminHandler) GetLogging(c echo.Context) error {
+	return c.JSON(http.StatusOK, struct {
+		logger.Settings
+		Sink *logger.SinkStats `json:"sink,omitempty"`
+	}{
+		Settings: logger.CurrentSettings(),
+		Sink:     logger.GetSinkStats(),
+	})
+}
+
+// UpdateLogging replaces the log level, per-module levels and sampling until
+// the next config reload
+func (h *AdminHandler) UpdateLogging(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	var settings logger.Settings
+	if err := c.Bind(&settings); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := logger.Apply(settings); err != nil {
+		h.auditor.RecordRequest(c, models.AuditActionLoggingUpdate, &userClaims.UserID, false, map[string]interface{}{
+			"error": err.Error(),
+		})
+		return apierror.BadRequest(err.Error())
+	}
+
+	current := logger.CurrentSettings()
+	h.auditor.RecordRequest(c, models.AuditActionLoggingUpdate, &userClaims.UserID, true, map[string]interface{}{
+		"level":    current.Level,
+		"modules":  current.Modules,
+		"sampling": current.Sampling,
+	})
+	return c.JSON(http.StatusOK, current)
+}
+
+// ListJobs returns the background jobs with their last and next runs
+func (h *AdminHandler) ListJobs(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"jobs": h.jobs.Status(),
+	})
+}
+
+// RunJob runs a background job now and returns its status once it finishes
+func (h *AdminHandler) RunJob(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	name := c.Param("name")
+	status, err := h.jobs.RunNow(c.Request().Context(), name)
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		return apierror.NotFound("Job not found")
+	case errors.Is(err, scheduler.ErrJobRunning):
+		return apierror.Conflict("Job is already running")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionJobRun, &userClaims.UserID, status.LastError == "", map[string]interface{}{
+		"job":     name,
+		"summary": status.LastSummary,
+		"error":   status.LastError,
+	})
+	return c.JSON(http.StatusOK, status)
+}
+
+// ListDeadTasks returns the queued tasks that ran out of attempts, most
+// recent first. Query params: limit (default 50, max 200).
+func (h *AdminHandler) ListDeadTasks(c echo.Context) error {
+	limit := 50
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 200 {
+			limit = parsedLimit
+		}
+	}
+
+	tasks, err := h.tasks.DeadLetters(c.Request().Context(), limit)
+	if err != nil {
+		return apierror.Internal("Failed to list dead tasks")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"tasks": tasks,
+	})
+}
+
+// RetryDeadTasks queues the dead tasks again with fresh attempts
+func (h *AdminHandler) RetryDeadTasks(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	queued, err := h.tasks.Redrive(c.Request().Context())
+	h.auditor.RecordRequest(c, models.AuditActionTaskRetry, &userClaims.UserID, err == nil, map[string]interface{}{
+		"queued": queued,
+	})
+	if err != nil {
+		return apierror.Internal("Failed to queue dead tasks")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"queued": queued,
+	})
+}
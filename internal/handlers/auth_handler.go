@@ -5,25 +5,49 @@ import (
 	"strings"
 	"time"
 
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/audit"
 	"github.com/shivaluma/eino-agent/internal/auth"
 	"github.com/shivaluma/eino-agent/internal/models"
 	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/security"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
 type AuthHandler struct {
-	userRepo *repository.UserRepository
-	authSvc  *auth.Service
+	userRepo   *repository.UserRepository
+	authSvc    *auth.Service
+	auditor    *audit.Auditor
+	loginGuard *auth.LoginGuard
+	monitor    *security.Monitor
 }
 
-func NewAuthHandler(userRepo *repository.UserRepository, authSvc *auth.Service) *AuthHandler {
+func NewAuthHandler(userRepo *repository.UserRepository, authSvc *auth.Service, auditor *audit.Auditor, loginGuard *auth.LoginGuard, monitor *security.Monitor) *AuthHandler {
 	return &AuthHandler{
-		userRepo: userRepo,
-		authSvc:  authSvc,
+		userRepo:   userRepo,
+		authSvc:    authSvc,
+		auditor:    auditor,
+		loginGuard: loginGuard,
+		monitor:    monitor,
 	}
 }
 
+// errAccountLocked is returned for an account locked after suspicious
+// activity. It is only returned once the caller proved who they are, so it
+// doesn't reveal which accounts exist.
+func errAccountLocked() *apierror.Error {
+	return apierror.New(http.StatusForbidden, apierror.CodeAccountLocked, "Account is locked, follow the link emailed to you to unlock it")
+}
+
+// requestDevice describes the device a request comes from, for binding
+// refresh tokens to it
+func requestDevice(c echo.Context) models.Device {
+	req := c.Request()
+	return auth.NewDevice(req.UserAgent(), req.Header.Get(auth.DeviceIDHeader), c.RealIP())
+}
+
 // setAuthCookies is a helper method to set authentication cookies
 func (h *AuthHandler) setAuthCookies(c echo.Context, accessToken, refreshToken string, refreshExpiresAt time.Time) {
 	// Access token cookie - no explicit expiration (session cookie)
@@ -51,24 +75,18 @@ func (h *AuthHandler) setAuthCookies(c echo.Context, accessToken, refreshToken s
 func (h *AuthHandler) CheckEmail(c echo.Context) error {
 	var req models.CheckEmailRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		return apierror.BadRequest("Invalid request body")
 	}
 
 	if err := c.Validate(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": err.Error(),
-		})
+		return apierror.Validation(err)
 	}
 
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
 
 	existingUser, err := h.userRepo.GetByEmail(c.Request().Context(), req.Email)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Internal server error",
-		})
+		return apierror.Internal("Internal server error")
 	}
 
 	return c.JSON(http.StatusOK, map[string]bool{
@@ -79,15 +97,11 @@ func (h *AuthHandler) CheckEmail(c echo.Context) error {
 func (h *AuthHandler) Register(c echo.Context) error {
 	var req models.UserRegisterRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		return apierror.BadRequest("Invalid request body")
 	}
 
 	if err := c.Validate(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": err.Error(),
-		})
+		return apierror.Validation(err)
 	}
 
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
@@ -95,21 +109,15 @@ func (h *AuthHandler) Register(c echo.Context) error {
 
 	existingUser, err := h.userRepo.GetByEmail(c.Request().Context(), req.Email)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Internal server error",
-		})
+		return apierror.Internal("Internal server error")
 	}
 	if existingUser != nil {
-		return c.JSON(http.StatusConflict, map[string]string{
-			"error": "Email already exists",
-		})
+		return apierror.Conflict("Email already exists")
 	}
 
 	hashedPassword, err := h.authSvc.HashPassword(req.Password)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to process password",
-		})
+		return apierror.Internal("Failed to process password")
 	}
 
 	user := &models.User{
@@ -119,11 +127,11 @@ func (h *AuthHandler) Register(c echo.Context) error {
 	}
 
 	if err := h.userRepo.Create(c.Request().Context(), user); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to create user",
-		})
+		return apierror.Internal("Failed to create user")
 	}
 
+	h.auditor.RecordRequest(c, models.AuditActionRegister, &user.ID, true, nil)
+
 	return c.JSON(http.StatusCreated, map[string]string{
 		"message": "User registered successfully",
 	})
@@ -132,171 +140,276 @@ func (h *AuthHandler) Register(c echo.Context) error {
 func (h *AuthHandler) Login(c echo.Context) error {
 	var req models.UserLoginRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		return apierror.BadRequest("Invalid request body")
 	}
 
 	if err := c.Validate(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": err.Error(),
-		})
+		return apierror.Validation(err)
 	}
 
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	ctx := c.Request().Context()
+	ip := c.RealIP()
+
+	// Locked out attempts get the same response as a wrong password so the
+	// lockout can't be used to probe accounts
+	if h.loginGuard.Locked(ctx, req.Email, ip) {
+		h.auditor.RecordRequest(c, models.AuditActionLogin, nil, false, map[string]interface{}{
+			"email_hash": h.auditor.Hash(req.Email),
+			"reason":     "locked_out",
+		})
+		return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid email or password")
+	}
 
-	user, err := h.userRepo.GetByEmail(c.Request().Context(), req.Email)
+	user, err := h.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Internal server error",
-		})
+		return apierror.Internal("Internal server error")
 	}
 	if user == nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Invalid email or password",
+		h.auditor.RecordRequest(c, models.AuditActionLogin, nil, false, map[string]interface{}{
+			"email_hash": h.auditor.Hash(req.Email),
+			"reason":     "unknown_email",
 		})
+		h.recordLoginFailure(c, req.Email, ip, nil)
+		return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid email or password")
 	}
 
 	if err := h.authSvc.VerifyPassword(user.PasswordHash, req.Password); err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Invalid email or password",
+		h.auditor.RecordRequest(c, models.AuditActionLogin, &user.ID, false, map[string]interface{}{
+			"reason": "invalid_password",
 		})
+		h.recordLoginFailure(c, req.Email, ip, &user.ID)
+		return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid email or password")
+	}
+
+	h.loginGuard.Succeed(ctx, req.Email)
+
+	if h.monitor.CheckLogin(c, user) {
+		h.auditor.RecordRequest(c, models.AuditActionLogin, &user.ID, false, map[string]interface{}{
+			"reason": "account_locked",
+		})
+		return errAccountLocked()
 	}
 
 	accessToken, err := h.authSvc.GenerateAccessToken(user.ID, user.Username)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate access token",
-		})
+		return apierror.Internal("Failed to generate access token")
 	}
 
 	refreshToken, err := h.authSvc.GenerateRefreshToken()
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate refresh token",
-		})
+		return apierror.Internal("Failed to generate refresh token")
 	}
 
-	refreshTokenRecord := h.authSvc.CreateRefreshTokenRecord(user.ID, refreshToken)
+	refreshTokenRecord := h.authSvc.CreateRefreshTokenRecord(user.ID, refreshToken, requestDevice(c), nil)
 	if err := h.userRepo.StoreRefreshToken(c.Request().Context(), refreshTokenRecord); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to store refresh token",
-		})
+		return apierror.Internal("Failed to store refresh token")
 	}
 
 	// Set authentication cookies
 	h.setAuthCookies(c, accessToken, refreshToken, refreshTokenRecord.ExpiresAt)
 
+	h.auditor.RecordRequest(c, models.AuditActionLogin, &user.ID, true, nil)
+
 	// Return only user data, not tokens
 	return c.JSON(http.StatusOK, models.UserResponse{
 		ID:        user.ID,
 		Username:  user.Username,
 		Email:     user.Email,
+		AvatarURL: user.AvatarURL,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	})
 }
 
+// recordLoginFailure counts a failed login and audits any lockout it starts
+func (h *AuthHandler) recordLoginFailure(c echo.Context, email, ip string, userID *uuid.UUID) {
+	for _, subject := range h.loginGuard.Fail(c.Request().Context(), email, ip) {
+		h.auditor.RecordRequest(c, models.AuditActionLoginLockout, userID, true, map[string]interface{}{
+			"email_hash": h.auditor.Hash(email),
+			"subject":    subject,
+		})
+	}
+}
+
 func (h *AuthHandler) RefreshToken(c echo.Context) error {
 	// Get refresh token from cookie instead of request body
 	cookie, err := c.Cookie("refresh_token")
 	if err != nil || cookie.Value == "" {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Refresh token not found",
-		})
+		return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Refresh token not found")
 	}
 
 	refreshTokenRecord, err := h.userRepo.GetRefreshToken(c.Request().Context(), cookie.Value)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Internal server error",
-		})
+		return apierror.Internal("Internal server error")
 	}
 	if refreshTokenRecord == nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Invalid or expired refresh token",
+		// A used or expired token of a real account may be a stolen one
+		if userID, err := h.userRepo.GetRefreshTokenOwner(c.Request().Context(), cookie.Value); err == nil && userID != nil {
+			h.monitor.RefreshFailed(c, *userID, "used_or_expired")
+		}
+		return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired refresh token")
+	}
+
+	// A token used from another device than it was issued to was likely
+	// copied off its device. It stays valid for its own device.
+	device := requestDevice(c)
+	if !auth.DeviceMatches(refreshTokenRecord, device) {
+		h.auditor.RecordRequest(c, models.AuditActionTokenRefresh, &refreshTokenRecord.UserID, false, map[string]interface{}{
+			"reason":     "device_mismatch",
+			"session_id": refreshTokenRecord.SessionID,
 		})
+		h.monitor.RefreshFailed(c, refreshTokenRecord.UserID, "device_mismatch")
+		return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Refresh token was issued to another device")
 	}
 
 	user, err := h.userRepo.GetByID(c.Request().Context(), refreshTokenRecord.UserID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Internal server error",
-		})
+		return apierror.Internal("Internal server error")
 	}
 	if user == nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "User not found",
-		})
+		return apierror.Unauthorized("User not found")
+	}
+	if user.LockedAt != nil {
+		return errAccountLocked()
 	}
 
 	if err := h.userRepo.InvalidateRefreshToken(c.Request().Context(), refreshTokenRecord.ID); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to invalidate refresh token",
-		})
+		return apierror.Internal("Failed to invalidate refresh token")
 	}
 
 	accessToken, err := h.authSvc.GenerateAccessToken(user.ID, user.Username)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate access token",
-		})
+		return apierror.Internal("Failed to generate access token")
 	}
 
 	newRefreshToken, err := h.authSvc.GenerateRefreshToken()
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate refresh token",
-		})
+		return apierror.Internal("Failed to generate refresh token")
 	}
 
-	newRefreshTokenRecord := h.authSvc.CreateRefreshTokenRecord(user.ID, newRefreshToken)
+	newRefreshTokenRecord := h.authSvc.CreateRefreshTokenRecord(user.ID, newRefreshToken, device, refreshTokenRecord)
 	if err := h.userRepo.StoreRefreshToken(c.Request().Context(), newRefreshTokenRecord); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to store refresh token",
-		})
+		return apierror.Internal("Failed to store refresh token")
 	}
 
 	// Update authentication cookies
 	h.setAuthCookies(c, accessToken, newRefreshToken, newRefreshTokenRecord.ExpiresAt)
 
+	h.auditor.RecordRequest(c, models.AuditActionTokenRefresh, &user.ID, true, nil)
+
 	// Return success without tokens
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "Token refreshed successfully",
 	})
 }
 
+// UnlockAccount unlocks an account locked after suspicious activity with
+// the token emailed to its owner. The owner signs in again afterwards.
+func (h *AuthHandler) UnlockAccount(c echo.Context) error {
+	var req models.UnlockAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	unlocked, err := h.monitor.Unlock(c, req.Token)
+	if err != nil {
+		return apierror.Internal("Failed to unlock account")
+	}
+	if !unlocked {
+		return apierror.New(http.StatusBadRequest, apierror.CodeInvalidToken, "Invalid or already used unlock token")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Account unlocked",
+	})
+}
+
 // Me returns the current authenticated user's profile.
 // Requires AuthMiddleware to set user context from a valid Bearer token.
 func (h *AuthHandler) Me(c echo.Context) error {
 	claims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Unauthorized",
-		})
+		return apierror.Unauthorized("Unauthorized")
 	}
 
 	user, err := h.userRepo.GetByID(c.Request().Context(), claims.UserID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Internal server error",
-		})
+		return apierror.Internal("Internal server error")
 	}
 	if user == nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "User not found",
-		})
+		return apierror.Unauthorized("User not found")
 	}
 
 	return c.JSON(http.StatusOK, models.UserResponse{
 		ID:        user.ID,
 		Username:  user.Username,
 		Email:     user.Email,
+		AvatarURL: user.AvatarURL,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	})
 }
 
+// ListSessions returns the devices the current user is signed in on. The
+// one making the request, known by its refresh token, is marked current.
+func (h *AuthHandler) ListSessions(c echo.Context) error {
+	claims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	sessions, err := h.userRepo.ListSessions(c.Request().Context(), claims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to list sessions")
+	}
+
+	if cookie, err := c.Cookie("refresh_token"); err == nil && cookie.Value != "" {
+		current, err := h.userRepo.GetRefreshToken(c.Request().Context(), cookie.Value)
+		if err == nil && current != nil && current.UserID == claims.UserID {
+			for _, session := range sessions {
+				session.Current = session.ID == current.SessionID
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+// RevokeSession signs the current user out on one of their devices. Access
+// tokens already issued to it stay valid until they expire.
+func (h *AuthHandler) RevokeSession(c echo.Context) error {
+	claims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid session ID")
+	}
+
+	revoked, err := h.userRepo.RevokeSession(c.Request().Context(), claims.UserID, sessionID)
+	if err != nil {
+		return apierror.Internal("Failed to revoke session")
+	}
+	if !revoked {
+		return apierror.NotFound("Session not found")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionSessionRevoke, &claims.UserID, true, map[string]interface{}{
+		"session_id": sessionID,
+	})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
 // Logout handles user logout by clearing authentication cookies and invalidating refresh token
 func (h *AuthHandler) Logout(c echo.Context) error {
 	// Get refresh token from cookie before clearing it
@@ -335,7 +448,25 @@ func (h *AuthHandler) Logout(c echo.Context) error {
 		MaxAge:   -1, // Delete the cookie
 	})
 
+	// Revoke the current access token so it can't be reused before it expires
+	if token, err := h.authSvc.GetAccessTokenFromContext(c.Request().Context()); err == nil {
+		if err := h.authSvc.RevokeAccessToken(c.Request().Context(), token); err != nil {
+			c.Logger().Error("Failed to revoke access token during logout: ", err)
+		}
+	}
+
+	if claims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context()); err == nil {
+		h.auditor.RecordRequest(c, models.AuditActionLogout, &claims.UserID, true, nil)
+	}
+
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "Successfully logged out",
 	})
 }
+
+// JWKS serves the public keys that verify access tokens so other services
+// can check them without sharing a secret. The set is empty with HS256.
+func (h *AuthHandler) JWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.authSvc.JWKS())
+}
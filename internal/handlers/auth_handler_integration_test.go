@@ -0,0 +1,166 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shivaluma/eino-agent/internal/middleware"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/security"
+	"github.com/shivaluma/eino-agent/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newAuthServer routes the auth endpoints as the server does
+func newAuthServer(t *testing.T) (*echo.Echo, *testutil.Env) {
+	env := testutil.NewEnv(t)
+	h := env.AuthHandler()
+
+	e := testutil.NewEcho()
+	e.POST("/register", h.Register)
+	e.POST("/login", h.Login)
+	e.POST("/token/refresh", h.RefreshToken)
+	protected := e.Group("", middleware.AuthMiddleware(env.Auth))
+	protected.GET("/auth/me", h.Me)
+	protected.POST("/auth/logout", h.Logout)
+	return e, env
+}
+
+func TestAuth_RegisterLoginLogout(t *testing.T) {
+	e, _ := newAuthServer(t)
+
+	rec := testutil.Request(t, e, http.MethodPost, "/register", models.UserRegisterRequest{
+		Name:     "Carol",
+		Email:    "carol@example.com",
+		Password: "password123",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	rec = testutil.Request(t, e, http.MethodPost, "/register", models.UserRegisterRequest{
+		Name:     "Carol",
+		Email:    "carol@example.com",
+		Password: "password123",
+	})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("register with a taken email: status %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	rec = testutil.Request(t, e, http.MethodPost, "/login", models.UserLoginRequest{
+		Email:    "carol@example.com",
+		Password: "password123",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+	access := testutil.Cookie(rec, "access_token")
+	refresh := testutil.Cookie(rec, "refresh_token")
+	if access == nil || refresh == nil {
+		t.Fatalf("login set cookies %v, want access_token and refresh_token", rec.Result().Cookies())
+	}
+
+	rec = testutil.Request(t, e, http.MethodGet, "/auth/me", nil, access)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("me: status %d, body %s", rec.Code, rec.Body)
+	}
+	var me models.UserResponse
+	testutil.DecodeJSON(t, rec, &me)
+	if me.Email != "carol@example.com" {
+		t.Fatalf("me: email %q, want carol@example.com", me.Email)
+	}
+
+	rec = testutil.Request(t, e, http.MethodPost, "/auth/logout", nil, access, refresh)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("logout: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	// Both tokens are revoked, not just their cookies cleared
+	rec = testutil.Request(t, e, http.MethodGet, "/auth/me", nil, access)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("me after logout: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	rec = testutil.Request(t, e, http.MethodPost, "/token/refresh", nil, refresh)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh after logout: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAuth_LoginWrongPassword(t *testing.T) {
+	e, env := newAuthServer(t)
+	env.CreateUser(t, "dave@example.com", "password123")
+
+	rec := testutil.Request(t, e, http.MethodPost, "/login", models.UserLoginRequest{
+		Email:    "dave@example.com",
+		Password: "wrong-password",
+	})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("login: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if testutil.Cookie(rec, "access_token") != nil {
+		t.Fatal("failed login set an access token")
+	}
+}
+
+func TestAuth_RefreshRotatesToken(t *testing.T) {
+	e, env := newAuthServer(t)
+	env.CreateUser(t, "erin@example.com", "password123")
+
+	rec := testutil.Request(t, e, http.MethodPost, "/login", models.UserLoginRequest{
+		Email:    "erin@example.com",
+		Password: "password123",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+	refresh := testutil.Cookie(rec, "refresh_token")
+
+	rec = testutil.Request(t, e, http.MethodPost, "/token/refresh", nil, refresh)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d, body %s", rec.Code, rec.Body)
+	}
+	rotated := testutil.Cookie(rec, "refresh_token")
+	if rotated == nil || rotated.Value == refresh.Value {
+		t.Fatal("refresh did not issue a new refresh token")
+	}
+
+	rec = testutil.Request(t, e, http.MethodGet, "/auth/me", nil, testutil.Cookie(rec, "access_token"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("me with the refreshed token: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	// A refresh token is only good once
+	rec = testutil.Request(t, e, http.MethodPost, "/token/refresh", nil, refresh)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAuth_LockRevokesAccessTokens(t *testing.T) {
+	e, env := newAuthServer(t)
+	user := env.CreateUser(t, "finn@example.com", "password123")
+
+	rec := testutil.Request(t, e, http.MethodPost, "/login", models.UserLoginRequest{
+		Email:    "finn@example.com",
+		Password: "password123",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+	access := testutil.Cookie(rec, "access_token")
+
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/token/refresh", nil), httptest.NewRecorder())
+	if !env.Monitor.Lock(c, user, security.ReasonRefreshFailures, nil) {
+		t.Fatal("Lock did not lock the account")
+	}
+
+	// The access token issued before the lock stops working at once
+	rec = testutil.Request(t, e, http.MethodGet, "/auth/me", nil, access)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("me after the lock: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
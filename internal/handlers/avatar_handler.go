@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/imaging"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// avatarSize is the width and height of stored avatars in pixels
+const avatarSize = 256
+
+type AvatarHandler struct {
+	userRepo  *repository.UserRepository
+	authSvc   *auth.Service
+	files     storage.Store
+	publicURL string
+	maxSize   int64
+}
+
+func NewAvatarHandler(userRepo *repository.UserRepository, authSvc *auth.Service, files storage.Store, publicURL string, maxSize int64) *AvatarHandler {
+	return &AvatarHandler{
+		userRepo:  userRepo,
+		authSvc:   authSvc,
+		files:     files,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		maxSize:   maxSize,
+	}
+}
+
+// avatarKey is the storage key of a user's avatar. Each user has a single
+// avatar object that is overwritten on upload.
+func avatarKey(userID uuid.UUID) string {
+	return fmt.Sprintf("avatars/%s.png", userID)
+}
+
+// UploadAvatar replaces the current user's avatar with an uploaded image
+// (form field "file"), cropped and resized to a square PNG
+func (h *AvatarHandler) UploadAvatar(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, h.maxSize+64*1024)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return apierror.BadRequest("File is required")
+	}
+	if fileHeader.Size > h.maxSize {
+		return apierror.PayloadTooLarge(fmt.Sprintf("File exceeds the %d byte limit", h.maxSize))
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return apierror.BadRequest("Failed to read file")
+	}
+	defer file.Close()
+
+	avatar, err := imaging.SquareThumbnail(file, avatarSize)
+	if errors.Is(err, imaging.ErrUnsupportedImage) {
+		return apierror.UnsupportedMediaType("Unsupported image format")
+	}
+	if err != nil {
+		return apierror.BadRequest(err.Error())
+	}
+
+	ctx := c.Request().Context()
+	if err := h.files.Put(ctx, avatarKey(userClaims.UserID), bytes.NewReader(avatar), "image/png"); err != nil {
+		return apierror.Internal("Failed to store avatar")
+	}
+
+	// The version parameter busts caches since the URL path never changes
+	avatarURL := fmt.Sprintf("%s/api/v1/users/%s/avatar?v=%d", h.publicURL, userClaims.UserID, time.Now().Unix())
+	if err := h.userRepo.UpdateAvatarURL(ctx, userClaims.UserID, &avatarURL); err != nil {
+		return apierror.Internal("Failed to update avatar")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"avatar_url": avatarURL,
+	})
+}
+
+// DeleteAvatar removes the current user's custom avatar
+func (h *AvatarHandler) DeleteAvatar(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	ctx := c.Request().Context()
+	if err := h.files.Delete(ctx, avatarKey(userClaims.UserID)); err != nil {
+		return apierror.Internal("Failed to delete avatar")
+	}
+
+	if err := h.userRepo.UpdateAvatarURL(ctx, userClaims.UserID, nil); err != nil {
+		return apierror.Internal("Failed to update avatar")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Avatar removed",
+	})
+}
+
+// GetAvatar serves a user's uploaded avatar. Avatars are public so they can
+// be embedded directly in <img> tags.
+func (h *AvatarHandler) GetAvatar(c echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid user ID")
+	}
+
+	reader, err := h.files.Get(c.Request().Context(), avatarKey(userID))
+	if err == storage.ErrNotFound {
+		return apierror.NotFound("Avatar not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to read avatar")
+	}
+	defer reader.Close()
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=86400")
+	return c.Stream(http.StatusOK, "image/png", reader)
+}
@@ -1,15 +1,36 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/shivaluma/eino-agent/config"
 	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/ai/templates"
+	"github.com/shivaluma/eino-agent/internal/apierror"
 	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/billing"
+	"github.com/shivaluma/eino-agent/internal/convlock"
+	"github.com/shivaluma/eino-agent/internal/events"
+	"github.com/shivaluma/eino-agent/internal/guardrails"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/memory"
 	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/outbox"
 	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/sse"
+	"github.com/shivaluma/eino-agent/internal/storage"
+	"github.com/shivaluma/eino-agent/internal/streaming"
+	"github.com/shivaluma/eino-agent/internal/titles"
 
 	"github.com/cloudwego/eino/schema"
 	"github.com/google/uuid"
@@ -17,25 +38,174 @@ import (
 )
 
 type ConversationHandler struct {
-	convRepo  *repository.ConversationRepository
-	authSvc   *auth.Service
-	aiService ai.Service
+	convRepo     *repository.ConversationRepository
+	tx           *repository.Transactor
+	participants *repository.ParticipantRepository
+	settingsRepo *repository.SettingsRepository
+	uploadRepo   *repository.UploadRepository
+	authSvc      *auth.Service
+	aiService    ai.Service
+	streams      streaming.Store
+	titles       *titles.Titler
+	files        storage.Store
+	events       events.Bus
+	memories     *memory.Store
+	guard        *guardrails.Guard
+	usage        *billing.Recorder
+	locks        convlock.Locker
+	queue        *ai.Queue
+	outbox       *outbox.Outbox
+	sse          config.SSEConfig
+
+	// allowedModels are the models clients may pick; empty allows any
+	allowedModels []string
 }
 
-func NewConversationHandler(convRepo *repository.ConversationRepository, authSvc *auth.Service, aiService ai.Service) *ConversationHandler {
+func NewConversationHandler(convRepo *repository.ConversationRepository, tx *repository.Transactor, participants *repository.ParticipantRepository, settingsRepo *repository.SettingsRepository, uploadRepo *repository.UploadRepository, authSvc *auth.Service, aiService ai.Service, streams streaming.Store, titler *titles.Titler, files storage.Store, bus events.Bus, memories *memory.Store, guard *guardrails.Guard, usage *billing.Recorder, locks convlock.Locker, queue *ai.Queue, messageOutbox *outbox.Outbox, sseConfig config.SSEConfig, allowedModels []string) *ConversationHandler {
 	return &ConversationHandler{
-		convRepo:  convRepo,
-		authSvc:   authSvc,
-		aiService: aiService,
+		convRepo:     convRepo,
+		tx:           tx,
+		participants: participants,
+		settingsRepo: settingsRepo,
+		uploadRepo:   uploadRepo,
+		authSvc:      authSvc,
+		aiService:    aiService,
+		streams:      streams,
+		titles:       titler,
+		files:        files,
+		events:       bus,
+		memories:     memories,
+		guard:        guard,
+		usage:        usage,
+		locks:        locks,
+		queue:        queue,
+		outbox:       messageOutbox,
+		sse:          sseConfig,
+
+		allowedModels: allowedModels,
+	}
+}
+
+// modelAllowed reports whether clients may pick model
+func (h *ConversationHandler) modelAllowed(model string) bool {
+	return len(h.allowedModels) == 0 || slices.Contains(h.allowedModels, model)
+}
+
+// errStreamCancelled aborts a generation whose stream was cancelled
+var errStreamCancelled = errors.New("stream cancelled")
+
+// errStreamAbandoned aborts a generation nobody is reading any more
+var errStreamAbandoned = errors.New("stream abandoned")
+
+// sseWriterConfig builds the configuration of an SSE writer. overflow
+// decides what a client loses when it can't keep up.
+func sseWriterConfig(cfg config.SSEConfig, overflow sse.Overflow) *sse.Config {
+	return &sse.Config{
+		HeartbeatInterval: cfg.HeartbeatInterval,
+		WriteTimeout:      cfg.WriteTimeout,
+		BufferSize:        cfg.BufferSize,
+		Overflow:          overflow,
+	}
+}
+
+// cancelCheckInterval limits how often a running generation polls the
+// stream store for cancellation requests
+const cancelCheckInterval = 500 * time.Millisecond
+
+// resumePollInterval is how long a resumed stream waits for new events
+// before re-checking the stream state
+const resumePollInterval = 5 * time.Second
+
+// checkOrgQuota turns away messages to the conversations of an
+// organization that used up this month's token quota, before any tokens
+// are spent on them
+func (h *ConversationHandler) checkOrgQuota(ctx context.Context, orgID *uuid.UUID) error {
+	err := h.usage.CheckOrgQuota(ctx, orgID)
+	if errors.Is(err, billing.ErrQuotaExceeded) {
+		return apierror.New(http.StatusTooManyRequests, apierror.CodeQuotaExceeded, "Organization exceeded its monthly token quota")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to check organization quota")
+	}
+	return nil
+}
+
+// checkWritable turns away messages to a conversation the user may not post
+// to. An ID no conversation has yet is free to start one with.
+func (h *ConversationHandler) checkWritable(ctx context.Context, conversationID, userID uuid.UUID) error {
+	conversation, role, err := h.convRepo.GetByIDForUser(ctx, conversationID, userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation != nil {
+		if !models.CanWrite(role) {
+			return apierror.Forbidden("Access denied")
+		}
+		return nil
+	}
+
+	existing, err := h.convRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if existing != nil {
+		return apierror.Forbidden("Access denied")
+	}
+	return nil
+}
+
+// queueFull answers a message turned away by a full generation queue with
+// 503 and when to retry
+func queueFull(c echo.Context, err error) error {
+	var overloaded *ai.OverloadedError
+	if !errors.As(err, &overloaded) {
+		return apierror.Internal("Failed to queue message")
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(overloaded.RetryAfter.Round(time.Second).Seconds())))
+	return apierror.Unavailable("Too many messages are being answered, try again later")
+}
+
+// titleConversation queues titling a new conversation. The title is sent
+// on the returned channel once it is saved and announced to the
+// conversation's participants; when titling fails the placeholder stays.
+func (h *ConversationHandler) titleConversation(ctx context.Context, conversation *models.Conversation, message, language string) <-chan string {
+	titles := make(chan string, 1)
+	log := logger.ModuleContext(ctx, "chat").With().Str("conversation_id", conversation.ID.String()).Logger()
+
+	// Subscribe before queueing so the rename isn't missed
+	ctx, cancel := context.WithCancel(ctx)
+	updates, err := h.events.Subscribe(ctx, conversation.UserID)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to subscribe to conversation events")
+	}
+
+	if err := h.titles.Request(ctx, conversation.ID, *conversation.Title, message, language); err != nil {
+		log.Warn().Err(err).Msg("Failed to queue conversation title, keeping the placeholder")
+		cancel()
+		return titles
+	}
+	if updates == nil {
+		cancel()
+		return titles
 	}
+
+	go func() {
+		defer cancel()
+		for event := range updates {
+			if event.Type == events.TypeConversationRenamed && event.ConversationID == conversation.ID {
+				titles <- event.Title
+				return
+			}
+		}
+	}()
+
+	return titles
 }
 
 func (h *ConversationHandler) GetConversations(c echo.Context) error {
 	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Unauthorized",
-		})
+		return apierror.Unauthorized("Unauthorized")
 	}
 
 	limit := 20
@@ -53,11 +223,9 @@ func (h *ConversationHandler) GetConversations(c echo.Context) error {
 		}
 	}
 
-	conversations, err := h.convRepo.GetByUserID(c.Request().Context(), userClaims.UserID, limit, offset)
+	conversations, err := h.convRepo.GetByUserID(c.Request().Context(), userClaims.UserID, auth.OrgIDFromContext(c.Request().Context()), limit, offset)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to fetch conversations",
-		})
+		return apierror.Internal("Failed to fetch conversations")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -67,55 +235,230 @@ func (h *ConversationHandler) GetConversations(c echo.Context) error {
 	})
 }
 
+// conversationHistoryResponse is a conversation with its latest messages,
+// rendered for the version of the request
+type conversationHistoryResponse struct {
+	models.ConversationSummary
+	Messages interface{} `json:"messages"`
+}
+
+// GetBootstrap returns the user's latest conversations with their latest
+// messages, so a client restoring a session needs one request instead of
+// one per conversation
+func (h *ConversationHandler) GetBootstrap(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	limit := 10
+	messageLimit := 20
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 50 {
+			limit = parsedLimit
+		}
+	}
+
+	if messagesStr := c.QueryParam("messages"); messagesStr != "" {
+		if parsedMessages, err := strconv.Atoi(messagesStr); err == nil && parsedMessages >= 0 && parsedMessages <= 100 {
+			messageLimit = parsedMessages
+		}
+	}
+
+	history, err := h.convRepo.GetRecentWithMessages(c.Request().Context(), userClaims.UserID, auth.OrgIDFromContext(c.Request().Context()), limit, messageLimit)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversations")
+	}
+
+	conversations := make([]conversationHistoryResponse, len(history))
+	for i, conv := range history {
+		conversations[i] = conversationHistoryResponse{
+			ConversationSummary: conv.ConversationSummary,
+			Messages:            messageMapper.MapSlice(c, conv.Messages),
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"conversations": conversations,
+		"limit":         limit,
+		"messages":      messageLimit,
+	})
+}
+
 func (h *ConversationHandler) SendMessage(c echo.Context) error {
 	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Unauthorized",
-		})
+		return apierror.Unauthorized("Unauthorized")
 	}
 
 	var req models.SendMessageRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		return apierror.BadRequest("Invalid request body")
 	}
 
 	if err := c.Validate(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": err.Error(),
-		})
+		return apierror.Validation(err)
+	}
+	if len(req.Content) > 0 {
+		if err := checkContent(&req); err != nil {
+			return err
+		}
+		req.Message = models.ContentText(req.Content)
+	}
+	if utf8.RuneCountInString(req.Message) > models.MaxMessageLength {
+		return apierror.Unprocessable(fmt.Sprintf("Message exceeds the %d character limit", models.MaxMessageLength)).
+			WithDetails(map[string]int{"max_length": models.MaxMessageLength})
+	}
+	req.Model = strings.TrimSpace(req.Model)
+	if req.Model != "" && !h.modelAllowed(req.Model) {
+		return apierror.BadRequest("Model is not allowed")
 	}
 
-	ctx := c.Request().Context()
+	protocol := sse.ProtocolV1
+	if req.Stream {
+		if protocol, err = streamProtocol(c); err != nil {
+			return err
+		}
+	}
+
+	// Screen the message for prompt injection before it is saved or sent
+	// to the model, each text of a message sent as parts on its own
+	if len(req.Content) > 0 {
+		for i := range req.Content {
+			part := &req.Content[i]
+			if part.Type == models.ContentPartImage {
+				continue
+			}
+			check := h.guard.Check(c.Request().Context(), guardrails.TargetMessage, part.Text)
+			if check.Blocked {
+				return apierror.Unprocessable("Message was blocked by content guardrails").WithDetails(map[string]string{"rule": check.Rule})
+			}
+			part.Text = check.Text
+		}
+		req.Message = models.ContentText(req.Content)
+	} else {
+		check := h.guard.Check(c.Request().Context(), guardrails.TargetMessage, req.Message)
+		if check.Blocked {
+			return apierror.Unprocessable("Message was blocked by content guardrails").WithDetails(map[string]string{"rule": check.Rule})
+		}
+		req.Message = check.Text
+	}
+
+	// User settings provide defaults for anything the request leaves unset
+	settings, err := h.settingsRepo.GetByUserID(c.Request().Context(), userClaims.UserID)
+	if err != nil {
+		logger.ModuleContext(c.Request().Context(), "chat").Warn().Err(err).Msg("Failed to load user settings, using defaults")
+	}
+	if settings == nil {
+		settings = &models.UserSettings{UserID: userClaims.UserID}
+	}
+
+	req.Persona = strings.TrimSpace(req.Persona)
+	req.SystemPrompt = strings.TrimSpace(req.SystemPrompt)
+	if req.Persona == "" && req.SystemPrompt == "" && settings.Persona != nil {
+		req.Persona = *settings.Persona
+	}
+	if req.Persona != "" && !templates.IsSelectablePersona(req.Persona) {
+		return apierror.BadRequest("Unknown persona")
+	}
+
+	preferredLanguage := ""
+	if settings.Language != nil {
+		preferredLanguage = *settings.Language
+	}
+
+	language, err := resolveLanguage(c, req.Language, preferredLanguage)
+	if err != nil {
+		return apierror.BadRequest(err.Error())
+	}
+
+	attachments, err := h.loadAttachments(c.Request().Context(), userClaims.UserID, req.Attachments)
+	if err != nil {
+		return apierror.BadRequest(err.Error())
+	}
+	parts, err := h.contentParts(c.Request().Context(), userClaims.UserID, req.Content)
+	if err != nil {
+		return apierror.BadRequest(err.Error())
+	}
+
+	var responseFormat *ai.ResponseFormat
+	if req.ResponseFormat != nil {
+		responseFormat = &ai.ResponseFormat{
+			Type:   req.ResponseFormat.Type,
+			Name:   req.ResponseFormat.Name,
+			Schema: req.ResponseFormat.Schema,
+		}
+		if _, err := responseFormat.Compile(); err != nil {
+			return apierror.BadRequest(err.Error())
+		}
+	}
+
+	// New conversations answer on the keys of the organization they are
+	// started in, existing ones on those of the organization they belong to
+	ctx := ai.WithOrg(c.Request().Context(), auth.OrgIDFromContext(c.Request().Context()))
 	var conversation *models.Conversation
 	var chatHistory []*schema.Message
 
-	// Check if conversation exists or create new one
+	// createConversation is set when the message starts a new conversation,
+	// which is then created together with the message
+	var createConversation func(ctx context.Context, conversation *models.Conversation) error
+
+	// Only users who may post can hold a conversation's lock; access is
+	// checked again once it is held
 	if req.ConversationID != nil {
-		// Try to find existing conversation
-		conversation, err = h.convRepo.GetByID(ctx, *req.ConversationID)
+		if err := h.checkWritable(ctx, *req.ConversationID, userClaims.UserID); err != nil {
+			return err
+		}
+	}
+
+	// Reserve a generation slot before anything is saved, so a busy server
+	// turns the message away rather than leave it unanswered. The title and
+	// the answer are generated in the slot.
+	ctx, release, err := h.queue.Acquire(ctx)
+	if err != nil {
+		return queueFull(c, err)
+	}
+	defer release()
+
+	// Answer one message per conversation at a time, so concurrent
+	// messages can't interleave the history or both create the conversation
+	if req.ConversationID != nil {
+		unlock, err := h.locks.Lock(ctx, *req.ConversationID)
+		if errors.Is(err, convlock.ErrBusy) {
+			return apierror.Conflict("Another message is still being answered in this conversation")
+		}
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "Failed to fetch conversation",
-			})
+			return apierror.Internal("Failed to lock conversation")
 		}
-		
+		defer unlock()
+	}
+
+	// Check if conversation exists or create new one, reading it again now
+	// that the lock is held
+	if req.ConversationID != nil {
+		// Try to find existing conversation the user can access
+		var role string
+		conversation, role, err = h.convRepo.GetByIDForUser(ctx, *req.ConversationID, userClaims.UserID)
+		if err != nil {
+			return apierror.Internal("Failed to fetch conversation")
+		}
+
 		if conversation != nil {
-			// Existing conversation found - verify ownership
-			if conversation.UserID != userClaims.UserID {
-				return c.JSON(http.StatusForbidden, map[string]string{
-					"error": "Access denied",
-				})
+			// Existing conversation found - only owners and contributors may post
+			if !models.CanWrite(role) {
+				return apierror.Forbidden("Access denied")
 			}
+			if err := h.checkOrgQuota(ctx, conversation.OrgID); err != nil {
+				return err
+			}
+			ctx = ai.WithOrg(ctx, conversation.OrgID)
 
-			// Load chat history
-			messages, err := h.convRepo.GetMessages(ctx, conversation.ID, 50, 0)
+			// Load the latest chat history; the context strategy picks
+			// what of it reaches the model
+			messages, err := h.convRepo.GetRecentMessages(ctx, conversation.ID, 50)
 			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{
-					"error": "Failed to fetch messages",
-				})
+				return apierror.Internal("Failed to fetch messages")
 			}
 
 			// Convert to schema messages for chat history
@@ -129,65 +472,90 @@ func (h *ConversationHandler) SendMessage(c echo.Context) error {
 			}
 		} else {
 			// Conversation not found - create new one with the provided ID
-			title, err := h.aiService.GenerateTitle(ctx, req.Message)
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{
-					"error": "Failed to generate title",
-				})
+			if err := h.checkOrgQuota(ctx, auth.OrgIDFromContext(ctx)); err != nil {
+				return err
 			}
-
+			title := templates.PlaceholderTitle(req.Message)
 			conversation = &models.Conversation{
 				ID:     *req.ConversationID, // Use the provided ID
 				UserID: userClaims.UserID,
 				Title:  &title,
+				OrgID:  auth.OrgIDFromContext(ctx),
 			}
-
-			if err := h.convRepo.CreateWithID(ctx, conversation); err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{
-					"error": "Failed to create conversation with provided ID",
-				})
-			}
+			h.routePersona(ctx, &req)
+			setConversationPrompt(conversation, &req)
+			createConversation = h.convRepo.CreateWithID
 		}
 	} else {
-		// New conversation - generate title from first message
-		title, err := h.aiService.GenerateTitle(ctx, req.Message)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "Failed to generate title",
-			})
+		// New conversation - its title is generated from the first message
+		// once it is saved
+		if err := h.checkOrgQuota(ctx, auth.OrgIDFromContext(ctx)); err != nil {
+			return err
 		}
-
+		title := templates.PlaceholderTitle(req.Message)
 		conversation = &models.Conversation{
 			UserID: userClaims.UserID,
 			Title:  &title,
+			OrgID:  auth.OrgIDFromContext(ctx),
 		}
-
-		if err := h.convRepo.Create(ctx, conversation); err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "Failed to create conversation",
-			})
-		}
+		h.routePersona(ctx, &req)
+		setConversationPrompt(conversation, &req)
+		createConversation = h.convRepo.Create
 	}
 
-	// Save user message
+	// Save user message, creating the conversation in the same transaction
+	// so a failure can't leave an empty conversation behind
 	userMessage := &models.Message{
-		ConversationID: conversation.ID,
-		SenderID:       userClaims.UserID,
-		SenderType:     models.SenderTypeUser,
-		Content:        req.Message,
-		Metadata:       req.Metadata,
+		SenderID:   userClaims.UserID,
+		SenderType: models.SenderTypeUser,
+		Content:    req.Message,
+		Metadata:   req.Metadata,
+		Parts:      req.Content,
 	}
 
-	if err := h.convRepo.CreateMessage(ctx, userMessage); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to save message",
-		})
+	err = h.tx.WithTx(ctx, func(ctx context.Context) error {
+		if createConversation != nil {
+			if err := createConversation(ctx, conversation); err != nil {
+				return fmt.Errorf("failed to create conversation: %w", err)
+			}
+		}
+
+		userMessage.ConversationID = conversation.ID
+		if err := h.convRepo.CreateMessage(ctx, userMessage); err != nil {
+			return fmt.Errorf("failed to save message: %w", err)
+		}
+
+		uploads := req.Attachments
+		if len(req.Content) > 0 {
+			uploads = models.ContentUploads(req.Content)
+		}
+		if len(uploads) > 0 {
+			if err := h.uploadRepo.AttachToMessage(ctx, uploads, userMessage.ID); err != nil {
+				return fmt.Errorf("failed to attach uploads: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if errors.Is(err, repository.ErrConversationExists) {
+		// The ID belongs to a conversation the user can't access
+		return apierror.Forbidden("Access denied")
+	}
+	if err != nil {
+		logger.ModuleContext(ctx, "chat").Error().Err(err).Str("conversation_id", conversation.ID.String()).Msg("Failed to save message")
+		return apierror.Internal("Failed to save message")
 	}
 
-	// Update conversation's updated_at
+	// Update conversation's updated_at, retrying later rather than
+	// failing the request
 	if err := h.convRepo.UpdateTimestamp(ctx, conversation.ID); err != nil {
-		// Log error but don't fail the request
-		fmt.Printf("Failed to update conversation timestamp: %v\n", err)
+		h.outbox.TouchConversation(ctx, conversation.ID, err)
+	}
+
+	// Title a new conversation without holding up the answer
+	var titles <-chan string
+	if createConversation != nil {
+		titles = h.titleConversation(ctx, conversation, req.Message, language)
 	}
 
 	// Prepare AI request
@@ -197,86 +565,212 @@ func (h *ConversationHandler) SendMessage(c echo.Context) error {
 		UserID:         userClaims.UserID.String(),
 		Stream:         req.Stream,
 		History:        chatHistory,
+		Language:       language,
+		Temperature:    settings.Temperature,
+		MaxTokens:      settings.MaxTokens,
+		ResponseFormat: responseFormat,
+		Attachments:    attachments,
+		Parts:          parts,
+		Memories:       h.memories.Recall(ctx, userClaims.UserID),
+	}
+	if settings.Model != nil {
+		aiRequest.Model = *settings.Model
+	}
+	// The conversation's model beats the user's; the message's beats both
+	if conversation.Model != nil {
+		aiRequest.Model = *conversation.Model
+	}
+	if req.Model != "" {
+		aiRequest.Model = req.Model
+	}
+	if req.Temperature != nil {
+		aiRequest.Temperature = req.Temperature
+	}
+	if req.TopP != nil {
+		aiRequest.TopP = req.TopP
+	}
+	if req.MaxTokens != nil {
+		aiRequest.MaxTokens = req.MaxTokens
+	}
+	aiRequest.Stop = req.Stop
+	if req.MaxOutputLength != nil {
+		aiRequest.MaxOutputLength = *req.MaxOutputLength
+	}
+	if conversation.Persona != nil {
+		aiRequest.Persona = *conversation.Persona
+	}
+	if conversation.SystemPrompt != nil {
+		aiRequest.SystemPrompt = *conversation.SystemPrompt
+	}
+	if conversation.ContextStrategy != nil {
+		aiRequest.ContextStrategy = *conversation.ContextStrategy
 	}
 
 	// Handle streaming or regular response
 	if req.Stream {
-		// Set headers for chunked streaming
-		c.Response().Header().Set("Content-Type", "text/event-stream")
-		c.Response().Header().Set("Cache-Control", "no-cache")
-		c.Response().Header().Set("Connection", "keep-alive")
-		c.Response().Header().Set("Transfer-Encoding", "chunked")
+		// Buffer events so a reconnecting client can resume via Last-Event-ID
+		stream, err := h.streams.Start(ctx, userClaims.UserID, conversation.ID)
+		if err != nil {
+			return apierror.Internal("Failed to start stream")
+		}
+
+		// Keep generating when the client disconnects so the rest of the
+		// answer is still available for resumption
+		genCtx := context.WithoutCancel(ctx)
+		defer h.streams.Finish(genCtx, stream.ID)
+
+		// A client too slow for the answer is disconnected and resumes from
+		// the last event it received
+		writer := sse.NewWriter(ctx, c.Response(), sseWriterConfig(h.sse, sse.OverflowAbort))
+		defer writer.Close()
+
+		publish := func(data interface{}) {
+			payload, _ := json.Marshal(data)
+			event, err := h.streams.Append(genCtx, stream.ID, payload)
+			if err != nil {
+				logger.ModuleContext(genCtx, "chat").Warn().Err(err).Str("stream_id", stream.ID).Msg("Failed to buffer stream event")
+				event = streaming.Event{Data: payload}
+			}
+			if !writer.Gone() {
+				writer.Send(sse.Encode(protocol, streaming.EventID(stream.ID, event), event.ID, event.Data))
+			}
+		}
 
 		// Write initial response with conversation and message info
-		initialData := map[string]interface{}{
-			"conversation_id": conversation.ID,
-			"message_id":      userMessage.ID,
-			"type":            "init",
+		publish(sse.NewInitEvent(conversation.ID, userMessage.ID, stream.ID))
+
+		// Send the generated title between events once it is ready
+		publishTitle := func() {
+			select {
+			case title := <-titles:
+				publish(sse.NewTitleEvent(conversation.ID, title))
+			default:
+			}
+		}
+
+		// Save the reply as it streams, so what was generated survives an
+		// error or a crash
+		reply := newPartialReply(h.convRepo, h.outbox, conversation.ID, aiRequest)
+		interrupted := func(reason string) {
+			if reply.interrupt(genCtx, reason) {
+				events.PublishConversation(genCtx, h.events, h.participants, conversation, events.NewMessageCompleted(conversation.ID, reply.message.ID))
+			}
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				interrupted(models.InterruptionError)
+				publish(sse.NewErrorEvent("Generation failed"))
+				panic(r)
+			}
+		}()
+
+		aiRequest.Progress = ai.ProgressFunc(func(ctx context.Context, status ai.Status) {
+			if status.Stage == ai.StageModelSelected {
+				reply.selected(status.Provider, status.Model)
+			}
+			publish(sse.NewStatusEvent(status.Stage, status.Provider, status.Model, status.Tool))
+		})
+
+		// Markdown chunking holds text back until it can't split a
+		// construct; the reply is saved as it comes
+		var coalescer *sse.Coalescer
+		if req.Chunking == models.ChunkingMarkdown {
+			coalescer = sse.NewCoalescer()
+		}
+		publishChunk := func(chunk string) {
+			if coalescer != nil {
+				chunk = coalescer.Write(chunk)
+			}
+			if chunk != "" {
+				publish(sse.NewChunkEvent(chunk))
+			}
 		}
-		initialJSON, _ := json.Marshal(initialData)
-		c.Response().Write([]byte(fmt.Sprintf("data: %s\n\n", string(initialJSON))))
-		c.Response().Flush()
 
 		// Stream callback
+		var lastCancelCheck time.Time
 		streamCallback := func(chunk string) error {
-			chunkData := map[string]interface{}{
-				"type":    "chunk",
-				"content": chunk,
-			}
-			chunkJSON, _ := json.Marshal(chunkData)
-			_, err := c.Response().Write([]byte(fmt.Sprintf("data: %s\n\n", string(chunkJSON))))
-			if err != nil {
-				return err // Client disconnected
+			if time.Since(lastCancelCheck) >= cancelCheckInterval {
+				lastCancelCheck = time.Now()
+				if cancelled, _ := h.streams.IsCancelled(genCtx, stream.ID); cancelled {
+					return errStreamCancelled
+				}
+				if h.abandoned(genCtx, writer, stream.ID) {
+					return errStreamAbandoned
+				}
 			}
-			c.Response().Flush()
+
+			publishTitle()
+			publishChunk(chunk)
+			reply.add(genCtx, chunk)
 			return nil
 		}
 
 		// Stream the response
-		response, err := h.aiService.Stream(ctx, aiRequest, streamCallback)
-		if err != nil {
-			errorData := map[string]interface{}{
-				"type":  "error",
-				"error": err.Error(),
+		response, err := h.aiService.Stream(genCtx, aiRequest, streamCallback)
+		if coalescer != nil {
+			if rest := coalescer.Flush(); rest != "" {
+				publish(sse.NewChunkEvent(rest))
 			}
-			errorJSON, _ := json.Marshal(errorData)
-			c.Response().Write([]byte(fmt.Sprintf("data: %s\n\n", string(errorJSON))))
-			c.Response().Flush()
+		}
+		publishTitle()
+		if errors.Is(err, errStreamCancelled) {
+			interrupted(models.InterruptionCancelled)
+			publish(sse.NewCancelledEvent())
+			return nil
+		}
+		if errors.Is(err, errStreamAbandoned) {
+			logger.ModuleContext(genCtx, "chat").Info().Str("stream_id", stream.ID).Msg("Cancelled generation of abandoned stream")
+			interrupted(models.InterruptionAbandoned)
+			publish(sse.NewCancelledEvent())
+			return nil
+		}
+		if err != nil {
+			interrupted(models.InterruptionError)
+			publish(sse.NewErrorEvent(err.Error()))
 			return nil
 		}
-
-		fullContent := response.Content
 
 		// Save AI response
-		aiMessage := &models.Message{
-			ConversationID: conversation.ID,
-			SenderID:       uuid.Nil, // System/AI doesn't have a user ID
-			SenderType:     models.SenderTypeAgent,
-			Content:        fullContent,
+		aiMessage := reply.message
+		if err := reply.finish(genCtx, response); err != nil {
+			// The reply is saved on retry; the stream still completes
+			logger.ModuleContext(genCtx, "chat").Error().Err(err).Str("conversation_id", conversation.ID.String()).Msg("Failed to save AI message, queued for retry")
+		} else {
+			events.PublishConversation(genCtx, h.events, h.participants, conversation, events.NewMessageCompleted(conversation.ID, aiMessage.ID))
+			h.memories.Learn(genCtx, userClaims.UserID, conversation.ID, req.Message)
 		}
+		h.usage.Record(genCtx, userClaims.UserID, conversation.ID, aiMessage.ID, response)
 
-		if err := h.convRepo.CreateMessage(ctx, aiMessage); err != nil {
-			// Log error but don't fail the streaming
-			fmt.Printf("Failed to save AI message: %v\n", err)
+		complete := sse.NewCompleteEvent(aiMessage.ID)
+		complete.FinishReason = response.FinishReason
+		if response.Usage != nil {
+			usage := sse.NewUsageEvent(response.Provider, response.Model,
+				response.Usage.PromptTokens, response.Usage.CompletionTokens, response.Usage.TotalTokens)
+			if response.Priced {
+				usage.CostUSD = float64(response.CostMicros) / 1e6
+			}
+			publish(usage)
+			complete.Usage = &usage.Usage
 		}
 
 		// Send completion signal
-		completeData := map[string]interface{}{
-			"type":       "complete",
-			"message_id": aiMessage.ID,
-		}
-		completeJSON, _ := json.Marshal(completeData)
-		c.Response().Write([]byte(fmt.Sprintf("data: %s\n\n", string(completeJSON))))
-		c.Response().Flush()
+		publish(complete)
 
 		return nil
 	} else {
 		// Non-streaming response
 		response, err := h.aiService.Generate(ctx, aiRequest)
+		if errors.Is(err, ai.ErrVisionUnsupported) {
+			return apierror.BadRequest("The selected model does not support image attachments")
+		}
+		if errors.Is(err, ai.ErrInvalidStructuredOutput) {
+			return apierror.Unprocessable("Model did not return output matching the requested format")
+		}
+		if errors.Is(err, ai.ErrProvidersUnavailable) {
+			return apierror.Unavailable("AI providers are unavailable, try again later")
+		}
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "Failed to generate response",
-			})
+			return apierror.Internal("Failed to generate response")
 		}
 
 		// Save AI response
@@ -285,19 +779,46 @@ func (h *ConversationHandler) SendMessage(c echo.Context) error {
 			SenderID:       uuid.Nil,
 			SenderType:     models.SenderTypeAgent,
 			Content:        response.Content,
+			Metadata:       response.Metadata(aiRequest).JSON(),
 		}
 
-		if err := h.convRepo.CreateMessage(ctx, aiMessage); err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "Failed to save AI response",
-			})
+		// A reply that fails to save is retried and still returned, with
+		// ID 0 until it is saved
+		err = h.convRepo.CreateMessage(ctx, aiMessage)
+		h.usage.Record(ctx, userClaims.UserID, conversation.ID, aiMessage.ID, response)
+		if err != nil {
+			h.outbox.SaveMessage(ctx, aiMessage, err)
+		} else {
+			events.PublishConversation(ctx, h.events, h.participants, conversation, events.NewMessageCompleted(conversation.ID, aiMessage.ID))
+			h.memories.Learn(ctx, userClaims.UserID, conversation.ID, req.Message)
 		}
 
-		return c.JSON(http.StatusOK, map[string]interface{}{
+		result := map[string]interface{}{
 			"conversation_id": conversation.ID,
-			"user_message":    userMessage,
-			"ai_message":      aiMessage,
-		})
+			"user_message":    messageMapper.Map(c, *userMessage),
+			"ai_message":      messageMapper.Map(c, *aiMessage),
+		}
+		if response.Structured != nil {
+			result["structured"] = response.Structured
+		}
+		if response.FinishReason != "" {
+			result["finish_reason"] = response.FinishReason
+		}
+		if response.Usage != nil {
+			usage := map[string]interface{}{
+				"provider":          response.Provider,
+				"model":             response.Model,
+				"prompt_tokens":     response.Usage.PromptTokens,
+				"completion_tokens": response.Usage.CompletionTokens,
+				"total_tokens":      response.Usage.TotalTokens,
+			}
+			if response.Priced {
+				usage["cost_usd"] = float64(response.CostMicros) / 1e6
+			}
+			result["usage"] = usage
+		}
+
+		return c.JSON(http.StatusOK, result)
 	}
 }
 
@@ -305,73 +826,161 @@ func (h *ConversationHandler) StreamMessage(c echo.Context) error {
 	return h.SendMessage(c)
 }
 
-func (h *ConversationHandler) GetConversation(c echo.Context) error {
+// ResumeStream replays a streamed response after the event given in the
+// Last-Event-ID header (or last_event_id query param) and follows it live
+// until the generation completes. The client negotiates the protocol
+// version again, as when it sent the message.
+func (h *ConversationHandler) ResumeStream(c echo.Context) error {
 	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Unauthorized",
-		})
+		return apierror.Unauthorized("Unauthorized")
 	}
 
-	conversationID, err := uuid.Parse(c.Param("id"))
+	protocol, err := streamProtocol(c)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid conversation ID",
-		})
+		return err
 	}
 
-	conversation, err := h.convRepo.GetByID(c.Request().Context(), conversationID)
+	stream, err := h.streams.Get(c.Request().Context(), c.Param("id"))
+	if err == streaming.ErrNotFound {
+		return apierror.NotFound("Stream not found or expired")
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to fetch conversation",
-		})
+		return apierror.Internal("Failed to fetch stream")
 	}
-	if conversation == nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "Conversation not found",
-		})
+
+	if stream.UserID != userClaims.UserID {
+		return apierror.Forbidden("Access denied")
 	}
 
-	if conversation.UserID != userClaims.UserID {
-		return c.JSON(http.StatusForbidden, map[string]string{
-			"error": "Access denied",
-		})
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("last_event_id")
 	}
+	lastID := streaming.ParseLastEventID(lastEventID)
 
-	return c.JSON(http.StatusOK, conversation)
-}
+	ctx := c.Request().Context()
+	writer := sse.NewWriter(ctx, c.Response(), sseWriterConfig(h.sse, sse.OverflowAbort))
+	defer writer.Close()
 
-func (h *ConversationHandler) GetMessages(c echo.Context) error {
-	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
-	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Unauthorized",
-		})
+	for {
+		// Keeps the generation going while this client reads it
+		if err := h.streams.MarkRead(ctx, stream.ID); err != nil {
+			logger.ModuleContext(ctx, "chat").Warn().Err(err).Str("stream_id", stream.ID).Msg("Failed to mark stream read")
+		}
+
+		events, done, err := h.streams.Read(ctx, stream.ID, lastID, resumePollInterval)
+		if err != nil {
+			return nil // Stream expired or client disconnected
+		}
+
+		for _, event := range events {
+			if err := writer.Send(sse.Encode(protocol, streaming.EventID(stream.ID, event), event.ID, event.Data)); err != nil {
+				return nil // Client disconnected
+			}
+			lastID = event.ID
+		}
+
+		if done || writer.Gone() {
+			return nil
+		}
 	}
+}
 
-	conversationID, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid conversation ID",
-		})
+// streamProtocol negotiates the chat streaming protocol version of a
+// request from the X-Stream-Protocol header or the stream_protocol query
+// param, and names it on the response
+func streamProtocol(c echo.Context) (int, error) {
+	accepted := c.Request().Header.Get(sse.ProtocolHeader)
+	if accepted == "" {
+		accepted = c.QueryParam(sse.ProtocolParam)
 	}
 
-	conversation, err := h.convRepo.GetByID(c.Request().Context(), conversationID)
+	version, err := sse.NegotiateProtocol(accepted)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to fetch conversation",
-		})
-	}
-	if conversation == nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "Conversation not found",
-		})
+		return 0, apierror.BadRequest(fmt.Sprintf("Unsupported stream protocol %q", accepted)).
+			WithDetails(map[string]int{"min_version": sse.ProtocolV1, "max_version": sse.LatestProtocol})
 	}
+	c.Response().Header().Set(sse.ProtocolHeader, strconv.Itoa(version))
+	return version, nil
+}
 
-	if conversation.UserID != userClaims.UserID {
-		return c.JSON(http.StatusForbidden, map[string]string{
-			"error": "Access denied",
-		})
+// abandoned reports whether the client of a stream disconnected longer
+// than the grace period ago and no client has resumed the stream since. A
+// resumed client marks the stream read every resumePollInterval or so.
+func (h *ConversationHandler) abandoned(ctx context.Context, writer *sse.Writer, streamID string) bool {
+	goneAt := writer.GoneAt()
+	if goneAt.IsZero() || time.Since(goneAt) < h.sse.DisconnectGrace {
+		return false
+	}
+
+	lastRead, err := h.streams.LastRead(ctx, streamID)
+	if err != nil {
+		return false
+	}
+	return time.Since(lastRead) >= max(h.sse.DisconnectGrace, 2*resumePollInterval)
+}
+
+// CancelStream stops an in-progress generation, which may be running on
+// another server instance
+func (h *ConversationHandler) CancelStream(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	stream, err := h.streams.Get(c.Request().Context(), c.Param("id"))
+	if err == streaming.ErrNotFound {
+		return apierror.NotFound("Stream not found or expired")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to fetch stream")
+	}
+
+	if stream.UserID != userClaims.UserID {
+		return apierror.Forbidden("Access denied")
+	}
+
+	if err := h.streams.Cancel(c.Request().Context(), stream.ID); err != nil {
+		return apierror.Internal("Failed to cancel stream")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Stream cancelled",
+	})
+}
+
+func (h *ConversationHandler) GetConversation(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid conversation ID")
+	}
+
+	conversation, _, err := h.convRepo.GetByIDForUser(c.Request().Context(), conversationID, userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return apierror.NotFound("Conversation not found")
+	}
+
+	return c.JSON(http.StatusOK, conversation)
+}
+
+func (h *ConversationHandler) GetMessages(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid conversation ID")
 	}
 
 	limit := 50
@@ -389,21 +998,625 @@ func (h *ConversationHandler) GetMessages(c echo.Context) error {
 		}
 	}
 
-	messages, err := h.convRepo.GetMessages(c.Request().Context(), conversationID, limit, offset)
+	// Owners may ask for deleted messages too, e.g. to review what was
+	// removed before it is purged
+	includeDeleted, _ := strconv.ParseBool(c.QueryParam("include_deleted"))
+
+	// Access is checked in the same query as the messages are loaded
+	messages, role, err := h.convRepo.GetMessagesForUser(c.Request().Context(), conversationID, userClaims.UserID, limit, offset, includeDeleted)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to fetch messages",
-		})
+		return apierror.Internal("Failed to fetch messages")
+	}
+	if role == "" {
+		return apierror.NotFound("Conversation not found")
+	}
+	if includeDeleted && role != models.ParticipantRoleOwner {
+		return apierror.Forbidden("Only the owner can view deleted messages")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"messages": messages,
+		"messages": messageMapper.MapSlice(c, messages),
 		"limit":    limit,
 		"offset":   offset,
 	})
 }
 
+// ForkConversation copies a conversation, optionally only up to a message,
+// into a new conversation owned by the caller so they can branch off from
+// that point
+func (h *ConversationHandler) ForkConversation(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid conversation ID")
+	}
+
+	var req models.ForkConversationRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	ctx := c.Request().Context()
+	conversation, _, err := h.convRepo.GetByIDForUser(ctx, conversationID, userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return apierror.NotFound("Conversation not found")
+	}
+
+	if req.MessageID != nil {
+		message, err := h.convRepo.GetMessageByID(ctx, conversation.ID, *req.MessageID)
+		if err != nil {
+			return apierror.Internal("Failed to fetch message")
+		}
+		if message == nil {
+			return apierror.NotFound("Message not found")
+		}
+	}
+
+	// A fork stays in the organization of its source
+	fork := &models.Conversation{UserID: userClaims.UserID, OrgID: conversation.OrgID}
+	if title := strings.TrimSpace(req.Title); title != "" {
+		fork.Title = &title
+	}
+
+	copied, err := h.convRepo.Fork(ctx, conversation.ID, req.MessageID, fork)
+	if err != nil {
+		return apierror.Internal("Failed to fork conversation")
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"conversation":  fork,
+		"forked_from":   conversation.ID,
+		"message_count": copied,
+	})
+}
+
+// DeleteMessage soft-deletes a message. The conversation owner may delete any
+// message; contributors may only delete their own.
+func (h *ConversationHandler) DeleteMessage(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid conversation ID")
+	}
+
+	messageID, err := strconv.ParseInt(c.Param("messageID"), 10, 64)
+	if err != nil {
+		return apierror.BadRequest("Invalid message ID")
+	}
+
+	ctx := c.Request().Context()
+	conversation, role, err := h.convRepo.GetByIDForUser(ctx, conversationID, userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return apierror.NotFound("Conversation not found")
+	}
+
+	if !models.CanWrite(role) {
+		return apierror.Forbidden("Access denied")
+	}
+
+	message, err := h.convRepo.GetMessageByID(ctx, conversation.ID, messageID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch message")
+	}
+	if message == nil {
+		return apierror.NotFound("Message not found")
+	}
+	ownMessage := message.SenderType == models.SenderTypeUser && message.SenderID == userClaims.UserID
+	if role != models.ParticipantRoleOwner && !ownMessage {
+		return apierror.Forbidden("You can only delete your own messages")
+	}
+
+	deleted, err := h.convRepo.SoftDeleteMessage(ctx, conversation.ID, message.ID)
+	if err != nil {
+		return apierror.Internal("Failed to delete message")
+	}
+	if !deleted {
+		return apierror.NotFound("Message not found")
+	}
+
+	events.PublishConversation(ctx, h.events, h.participants, conversation, events.NewMessageDeleted(conversation.ID, message.ID))
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Message deleted",
+	})
+}
+
+// TogglePin pins or unpins a conversation for the current user
+func (h *ConversationHandler) TogglePin(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid conversation ID")
+	}
+
+	ctx := c.Request().Context()
+	conversation, role, err := h.convRepo.GetByIDForUser(ctx, conversationID, userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return apierror.NotFound("Conversation not found")
+	}
+
+	pinned, err := h.convRepo.TogglePinned(ctx, conversation.ID, userClaims.UserID, role)
+	if err != nil {
+		return apierror.Internal("Failed to update pin")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"conversation_id": conversation.ID,
+		"pinned":          pinned,
+	})
+}
+
+// MarkRead advances the current user's read marker in a conversation, which
+// the conversation list's unread counts are based on on every device
+func (h *ConversationHandler) MarkRead(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid conversation ID")
+	}
+
+	var req models.MarkReadRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	ctx := c.Request().Context()
+	conversation, role, err := h.convRepo.GetByIDForUser(ctx, conversationID, userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return apierror.NotFound("Conversation not found")
+	}
+
+	message, err := h.convRepo.GetMessageByID(ctx, conversation.ID, req.MessageID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch message")
+	}
+	if message == nil {
+		return apierror.NotFound("Message not found")
+	}
+
+	lastRead, err := h.convRepo.MarkRead(ctx, conversation.ID, userClaims.UserID, role, message.ID)
+	if err != nil {
+		return apierror.Internal("Failed to update read marker")
+	}
+
+	if err := h.events.Publish(ctx, userClaims.UserID, events.NewConversationRead(conversation.ID, lastRead)); err != nil {
+		logger.ModuleContext(ctx, "chat").Warn().Err(err).Msg("Failed to publish conversation read event")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"conversation_id":      conversation.ID,
+		"last_read_message_id": lastRead,
+	})
+}
+
+// RegenerateTitle replaces the conversation title with one summarizing its
+// most recent messages
+func (h *ConversationHandler) RegenerateTitle(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid conversation ID")
+	}
+
+	var req models.RegenerateTitleRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+	if req.Messages == 0 {
+		req.Messages = models.DefaultTitleHistoryMessages
+	}
+
+	ctx := c.Request().Context()
+	conversation, role, err := h.convRepo.GetByIDForUser(ctx, conversationID, userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return apierror.NotFound("Conversation not found")
+	}
+
+	if !models.CanWrite(role) {
+		return apierror.Forbidden("Access denied")
+	}
+
+	preferredLanguage := ""
+	if settings, err := h.settingsRepo.GetByUserID(ctx, userClaims.UserID); err != nil {
+		logger.ModuleContext(ctx, "chat").Warn().Err(err).Msg("Failed to load user settings, using defaults")
+	} else if settings != nil && settings.Language != nil {
+		preferredLanguage = *settings.Language
+	}
+
+	language, err := resolveLanguage(c, req.Language, preferredLanguage)
+	if err != nil {
+		return apierror.BadRequest(err.Error())
+	}
+
+	// Summarize the most recent messages
+	count, err := h.convRepo.GetMessageCount(ctx, conversation.ID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch messages")
+	}
+	messages, err := h.convRepo.GetMessages(ctx, conversation.ID, req.Messages, max(count-req.Messages, 0))
+	if err != nil {
+		return apierror.Internal("Failed to fetch messages")
+	}
+	if len(messages) == 0 {
+		return apierror.BadRequest("Conversation has no messages")
+	}
+
+	var history []*schema.Message
+	for _, msg := range messages {
+		switch msg.SenderType {
+		case models.SenderTypeUser:
+			history = append(history, schema.UserMessage(msg.Content))
+		case models.SenderTypeAgent:
+			history = append(history, schema.AssistantMessage(msg.Content, nil))
+		}
+	}
+
+	title, err := h.aiService.GenerateTitle(ai.WithOrg(ctx, conversation.OrgID), "", language, history)
+	if err != nil {
+		return apierror.Internal("Failed to generate title")
+	}
+	title = strings.Trim(strings.TrimSpace(title), "\"'")
+
+	conversation.Title = &title
+	if err := h.convRepo.Update(ctx, conversation); err != nil {
+		return apierror.Internal("Failed to update conversation")
+	}
+	events.PublishConversation(ctx, h.events, h.participants, conversation, events.NewConversationRenamed(conversation.ID, title))
+
+	return c.JSON(http.StatusOK, conversation)
+}
+
 // Deprecated - use SendMessage instead
 func (h *ConversationHandler) CreateConversation(c echo.Context) error {
 	return h.SendMessage(c)
 }
+
+// routePersona replaces the auto persona of a new conversation with the
+// persona the router picks for its first message. Routing failures fall
+// back to the default persona rather than failing the message.
+func (h *ConversationHandler) routePersona(ctx context.Context, req *models.SendMessageRequest) {
+	if req.Persona != templates.AutoPersona {
+		return
+	}
+	if req.SystemPrompt != "" {
+		req.Persona = ""
+		return
+	}
+
+	persona, err := h.aiService.RoutePersona(ctx, req.Message)
+	if err != nil {
+		logger.ModuleContext(ctx, "chat").Warn().Err(err).Msg("Failed to route persona, using default")
+		persona = templates.DefaultPersona
+	}
+	req.Persona = persona
+}
+
+// setConversationPrompt copies the requested persona or custom system prompt
+// onto a new conversation
+func setConversationPrompt(conversation *models.Conversation, req *models.SendMessageRequest) {
+	if req.Persona != "" {
+		conversation.Persona = &req.Persona
+	}
+	if req.SystemPrompt != "" {
+		conversation.SystemPrompt = &req.SystemPrompt
+	}
+}
+
+// loadAttachments reads the user's uploads referenced by a message so they
+// can be sent to the model inline, in the order of ids
+func (h *ConversationHandler) loadAttachments(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) ([]ai.Attachment, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	uploads, err := h.uploadRepo.GetByIDsForUser(ctx, userID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attachments")
+	}
+
+	loaded := make(map[uuid.UUID]ai.Attachment, len(uploads))
+	for _, upload := range uploads {
+		reader, err := h.files.Get(ctx, upload.StorageKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment %s", upload.ID)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment %s", upload.ID)
+		}
+
+		loaded[upload.ID] = ai.Attachment{
+			ContentType: upload.ContentType,
+			Data:        data,
+		}
+	}
+
+	attachments := make([]ai.Attachment, 0, len(ids))
+	for _, id := range ids {
+		attachment, ok := loaded[id]
+		if !ok {
+			return nil, fmt.Errorf("attachment not found")
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
+
+// checkContent checks the parts of a message sent as parts, which can't
+// also have a message or attachments
+func checkContent(req *models.SendMessageRequest) error {
+	if req.Message != "" || len(req.Attachments) > 0 {
+		return apierror.BadRequest("Send either content or a message with attachments, not both")
+	}
+
+	text := false
+	for i, part := range req.Content {
+		if err := part.Check(); err != nil {
+			return apierror.BadRequest(fmt.Sprintf("Invalid content part %d: %s", i+1, err))
+		}
+		text = text || part.Type != models.ContentPartImage
+	}
+	if !text {
+		return apierror.BadRequest("Content needs a text or code part")
+	}
+	if images := len(models.ContentUploads(req.Content)); images > models.MaxMessageAttachments {
+		return apierror.BadRequest(fmt.Sprintf("Content has %d images, at most %d are allowed", images, models.MaxMessageAttachments))
+	}
+	return nil
+}
+
+// contentParts maps the parts of a message sent as parts for the model,
+// code as Markdown and images read from their uploads
+func (h *ConversationHandler) contentParts(ctx context.Context, userID uuid.UUID, content []models.ContentPart) ([]ai.Part, error) {
+	if len(content) == 0 {
+		return nil, nil
+	}
+
+	images, err := h.loadAttachments(ctx, userID, models.ContentUploads(content))
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]ai.Part, 0, len(content))
+	for _, part := range content {
+		if part.Type == models.ContentPartImage {
+			parts = append(parts, ai.Part{Image: &images[0]})
+			images = images[1:]
+			continue
+		}
+		parts = append(parts, ai.Part{Text: part.Markdown()})
+	}
+	return parts, nil
+}
+
+// resolveLanguage picks the reply language from the explicit request field,
+// then the user's saved preference, then the Accept-Language header, then
+// the default language
+func resolveLanguage(c echo.Context, requested, preferred string) (string, error) {
+	if requested != "" {
+		language := templates.NormalizeLanguage(requested)
+		if language == "" {
+			return "", fmt.Errorf("unsupported language: %s", requested)
+		}
+		return language, nil
+	}
+
+	if language := templates.NormalizeLanguage(preferred); language != "" {
+		return language, nil
+	}
+
+	if language := templates.MatchAcceptLanguage(c.Request().Header.Get("Accept-Language")); language != "" {
+		return language, nil
+	}
+
+	return templates.DefaultLanguage, nil
+}
+
+// GetAgents lists the agents a conversation can be answered by. Passing
+// "auto" as the persona of a new conversation lets the router pick one.
+func (h *ConversationHandler) GetAgents(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"agents":  templates.ListPersonas(),
+		"default": templates.DefaultPersona,
+		"auto":    templates.AutoPersona,
+	})
+}
+
+// UpdatePersona switches the agent answering a conversation. Only the owner
+// may switch it.
+func (h *ConversationHandler) UpdatePersona(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid conversation ID")
+	}
+
+	var req models.UpdatePersonaRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+	if !templates.IsPersona(req.Persona) {
+		return apierror.BadRequest("Unknown persona")
+	}
+
+	ctx := c.Request().Context()
+	conversation, _, err := h.convRepo.GetByIDForUser(ctx, conversationID, userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return apierror.NotFound("Conversation not found")
+	}
+	if conversation.UserID != userClaims.UserID {
+		return apierror.Forbidden("Access denied")
+	}
+
+	conversation.Persona = &req.Persona
+	if err := h.convRepo.UpdatePersona(ctx, conversation); err != nil {
+		return apierror.Internal("Failed to update persona")
+	}
+
+	return c.JSON(http.StatusOK, conversation)
+}
+
+// UpdateContextStrategy sets how much of a conversation's history is sent
+// to the model. An empty strategy goes back to the server default. Only the
+// owner may change it.
+func (h *ConversationHandler) UpdateContextStrategy(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid conversation ID")
+	}
+
+	var req models.UpdateContextStrategyRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	ctx := c.Request().Context()
+	conversation, _, err := h.convRepo.GetByIDForUser(ctx, conversationID, userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return apierror.NotFound("Conversation not found")
+	}
+	if conversation.UserID != userClaims.UserID {
+		return apierror.Forbidden("Access denied")
+	}
+
+	conversation.ContextStrategy = nil
+	if req.Strategy != "" {
+		conversation.ContextStrategy = &req.Strategy
+	}
+	if err := h.convRepo.UpdateContextStrategy(ctx, conversation); err != nil {
+		return apierror.Internal("Failed to update context strategy")
+	}
+
+	return c.JSON(http.StatusOK, conversation)
+}
+
+// UpdateModel pins the model answering a conversation, so long threads keep
+// one voice whatever the owner's settings say later. An empty model goes
+// back to the user's settings. Only the owner may change it.
+func (h *ConversationHandler) UpdateModel(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid conversation ID")
+	}
+
+	var req models.UpdateConversationModelRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+	model := strings.TrimSpace(req.Model)
+	if model != "" && !h.modelAllowed(model) {
+		return apierror.BadRequest("Model is not allowed")
+	}
+
+	ctx := c.Request().Context()
+	conversation, _, err := h.convRepo.GetByIDForUser(ctx, conversationID, userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return apierror.NotFound("Conversation not found")
+	}
+	if conversation.UserID != userClaims.UserID {
+		return apierror.Forbidden("Access denied")
+	}
+
+	conversation.Model = nil
+	if model != "" {
+		conversation.Model = &model
+	}
+	if err := h.convRepo.UpdateModel(ctx, conversation); err != nil {
+		return apierror.Internal("Failed to update model")
+	}
+
+	return c.JSON(http.StatusOK, conversation)
+}
+
+// GetPersonas lists the built-in personas a conversation can be started with
+func (h *ConversationHandler) GetPersonas(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"personas":  templates.ListPersonas(),
+		"default":   templates.DefaultPersona,
+		"languages": templates.SupportedLanguages(),
+	})
+}
@@ -0,0 +1,396 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/middleware"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/testutil"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const stubReply = "Try a mushroom risotto tonight."
+
+// newChatServer routes POST /messages answered by a stub model, and returns
+// a session cookie of a new user
+func newChatServer(t *testing.T) (*echo.Echo, *testutil.Env, *testutil.StubAI, *models.User, *http.Cookie) {
+	env := testutil.NewEnv(t)
+	stub := testutil.NewStubAI(stubReply)
+	h := env.ConversationHandler(t, stub)
+
+	e := testutil.NewEcho()
+	protected := e.Group("", middleware.AuthMiddleware(env.Auth))
+	protected.POST("/messages", h.SendMessage)
+
+	user := env.CreateUser(t, "grace@example.com", "password123")
+	token, err := env.Auth.GenerateAccessToken(user.ID, user.Username)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	return e, env, stub, user, &http.Cookie{Name: "access_token", Value: token}
+}
+
+func TestSendMessage(t *testing.T) {
+	ctx := context.Background()
+	e, env, stub, user, session := newChatServer(t)
+
+	rec := testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{Message: "What should I cook?"}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("send: status %d, body %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		ConversationID uuid.UUID      `json:"conversation_id"`
+		UserMessage    models.Message `json:"user_message"`
+		AIMessage      models.Message `json:"ai_message"`
+		Usage          struct {
+			Provider    string `json:"provider"`
+			TotalTokens int    `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	testutil.DecodeJSON(t, rec, &body)
+	if body.AIMessage.Content != stubReply || body.AIMessage.SenderType != models.SenderTypeAgent {
+		t.Fatalf("ai_message = %+v, want the stub's reply", body.AIMessage)
+	}
+	if body.UserMessage.Content != "What should I cook?" || body.UserMessage.SenderID != user.ID {
+		t.Fatalf("user_message = %+v", body.UserMessage)
+	}
+	if body.Usage.Provider != "stub" || body.Usage.TotalTokens == 0 {
+		t.Fatalf("usage = %+v, want the stub's usage", body.Usage)
+	}
+
+	// The message started a conversation owned by the user, with both
+	// messages saved
+	conversation, role, err := env.Conversations.GetByIDForUser(ctx, body.ConversationID, user.ID)
+	if err != nil {
+		t.Fatalf("GetByIDForUser: %v", err)
+	}
+	if conversation == nil || role != models.ParticipantRoleOwner {
+		t.Fatalf("conversation = %+v, %q, want one owned by the user", conversation, role)
+	}
+	messages, err := env.Conversations.GetMessages(ctx, body.ConversationID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 2 || messages[1].Content != stubReply {
+		t.Fatalf("saved messages = %+v, want the question and the reply", messages)
+	}
+
+	// A follow-up is answered with the conversation so far
+	rec = testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{
+		Message:        "Without cheese?",
+		ConversationID: &body.ConversationID,
+	}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("follow-up: status %d, body %s", rec.Code, rec.Body)
+	}
+	requests := stub.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("model got %d requests, want 2", len(requests))
+	}
+	if last := requests[1]; last.Message != "Without cheese?" || len(last.History) != 2 {
+		t.Fatalf("follow-up request = message %q with %d history messages, want 2", last.Message, len(last.History))
+	}
+}
+
+func TestSendMessage_ContentParts(t *testing.T) {
+	ctx := context.Background()
+	e, env, stub, _, session := newChatServer(t)
+
+	content := []models.ContentPart{
+		{Type: models.ContentPartText, Text: "Is this dough too wet?"},
+		{Type: models.ContentPartCode, Language: "yaml", Text: "flour: 500g\nwater: 400g\n"},
+	}
+	rec := testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{Content: content}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("send: status %d, body %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		ConversationID uuid.UUID      `json:"conversation_id"`
+		UserMessage    models.Message `json:"user_message"`
+	}
+	testutil.DecodeJSON(t, rec, &body)
+
+	// The parts are saved as sent, and their text as the content the model
+	// got
+	want := "Is this dough too wet?\n\n```yaml\nflour: 500g\nwater: 400g\n```"
+	saved, err := env.Conversations.GetMessageByID(ctx, body.ConversationID, body.UserMessage.ID)
+	if err != nil {
+		t.Fatalf("GetMessageByID: %v", err)
+	}
+	if saved == nil || saved.Content != want || len(saved.Parts) != 2 || saved.Parts[1].Language != "yaml" {
+		t.Fatalf("saved message = %+v, want the parts and their Markdown", saved)
+	}
+	if got := stub.Requests()[0].Message; got != want {
+		t.Fatalf("model got %q, want %q", got, want)
+	}
+
+	// Parts can't be mixed with a plain message, and need some text
+	for name, req := range map[string]models.SendMessageRequest{
+		"with message": {Message: "Hi", Content: content},
+		"images only":  {Content: []models.ContentPart{{Type: models.ContentPartImage, UploadID: &body.ConversationID}}},
+		"empty text":   {Content: []models.ContentPart{{Type: models.ContentPartText, Text: " "}}},
+	} {
+		rec := testutil.Request(t, e, http.MethodPost, "/messages", req, session)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status %d, want %d", name, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSendMessage_StopConditions(t *testing.T) {
+	e, _, stub, _, session := newChatServer(t)
+
+	limit := 200
+	rec := testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{
+		Message:         "List three dishes",
+		Stop:            []string{"4."},
+		MaxOutputLength: &limit,
+	}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("send: status %d, body %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		FinishReason string `json:"finish_reason"`
+	}
+	testutil.DecodeJSON(t, rec, &body)
+	if body.FinishReason != ai.FinishReasonStop {
+		t.Fatalf("finish_reason = %q, want %q", body.FinishReason, ai.FinishReasonStop)
+	}
+	if got := stub.Requests()[0]; got.MaxOutputLength != limit || len(got.Stop) != 1 {
+		t.Fatalf("model got max output length %d and stop %q", got.MaxOutputLength, got.Stop)
+	}
+
+	zero := 0
+	rec = testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{Message: "Hi", MaxOutputLength: &zero}, session)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("zero limit: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestSendMessage_ConversationModel(t *testing.T) {
+	ctx := context.Background()
+	e, env, stub, user, session := newChatServer(t)
+
+	rec := testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{Message: "Dinner idea?"}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("send: status %d, body %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		ConversationID uuid.UUID `json:"conversation_id"`
+	}
+	testutil.DecodeJSON(t, rec, &body)
+
+	conversation, _, err := env.Conversations.GetByIDForUser(ctx, body.ConversationID, user.ID)
+	if err != nil {
+		t.Fatalf("GetByIDForUser: %v", err)
+	}
+	pinned := "pinned-model"
+	conversation.Model = &pinned
+	if err := env.Conversations.UpdateModel(ctx, conversation); err != nil {
+		t.Fatalf("UpdateModel: %v", err)
+	}
+
+	// Later messages get the pinned model unless they name one
+	for _, model := range []string{"", "message-model"} {
+		rec := testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{
+			Message:        "Something lighter?",
+			ConversationID: &body.ConversationID,
+			Model:          model,
+		}, session)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("follow-up: status %d, body %s", rec.Code, rec.Body)
+		}
+	}
+	requests := stub.Requests()
+	if got := []string{requests[0].Model, requests[1].Model, requests[2].Model}; got[0] != "" || got[1] != pinned || got[2] != "message-model" {
+		t.Fatalf("models = %q, want none, the pinned one, then the message's", got)
+	}
+}
+
+func TestSendMessage_Stream(t *testing.T) {
+	ctx := context.Background()
+	e, env, _, _, session := newChatServer(t)
+
+	rec := testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{Message: "Dinner idea?", Stream: true}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("send: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	var (
+		types          []string
+		content        strings.Builder
+		conversationID uuid.UUID
+		messageID      int64
+	)
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Type           string    `json:"type"`
+			Content        string    `json:"content"`
+			ConversationID uuid.UUID `json:"conversation_id"`
+			MessageID      int64     `json:"message_id"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", data, err)
+		}
+		types = append(types, event.Type)
+		switch event.Type {
+		case "init":
+			conversationID = event.ConversationID
+		case "chunk":
+			content.WriteString(event.Content)
+		case "complete":
+			messageID = event.MessageID
+		}
+	}
+
+	if len(types) == 0 || types[0] != "init" || types[len(types)-1] != "complete" {
+		t.Fatalf("event types %v, want init first and complete last", types)
+	}
+	if content.String() != stubReply {
+		t.Fatalf("streamed %q, want %q", content.String(), stubReply)
+	}
+
+	// The streamed reply is saved under the ID the complete event gave
+	reply, err := env.Conversations.GetMessageByID(ctx, conversationID, messageID)
+	if err != nil {
+		t.Fatalf("GetMessageByID: %v", err)
+	}
+	if reply == nil || reply.Content != stubReply {
+		t.Fatalf("saved reply = %+v, want the streamed content", reply)
+	}
+}
+
+func TestSendMessage_StreamProtocolV2(t *testing.T) {
+	e, _, _, _, session := newChatServer(t)
+
+	rec := testutil.Request(t, e, http.MethodPost, "/messages?stream_protocol=2,1", models.SendMessageRequest{Message: "Dinner idea?", Stream: true}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("send: status %d, body %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("X-Stream-Protocol"); got != "2" {
+		t.Fatalf("X-Stream-Protocol = %q, want 2", got)
+	}
+
+	// Every event is typed and numbered from 1; init names the version and
+	// complete carries the usage
+	var eventType string
+	var seq int64
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event:"); ok {
+			eventType = strings.TrimSpace(name)
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Type    string          `json:"type"`
+			Seq     int64           `json:"seq"`
+			Version int             `json:"version"`
+			Usage   json.RawMessage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", data, err)
+		}
+		seq++
+		if event.Type != eventType || event.Seq != seq {
+			t.Fatalf("event %d: type %q in event field %q, seq %d", seq, event.Type, eventType, event.Seq)
+		}
+		switch event.Type {
+		case "init":
+			if event.Version != 2 {
+				t.Fatalf("init version = %d, want 2", event.Version)
+			}
+		case "complete":
+			if len(event.Usage) == 0 {
+				t.Fatal("complete event has no usage")
+			}
+		}
+	}
+	if seq == 0 || eventType != "complete" {
+		t.Fatalf("stream of %d events ended with %q, want complete", seq, eventType)
+	}
+
+	rec = testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{Message: "Hi", Stream: true}, session)
+	if strings.Contains(rec.Body.String(), "event:") || strings.Contains(rec.Body.String(), `"seq"`) {
+		t.Fatal("client without a protocol got v2 events")
+	}
+
+	rec = testutil.Request(t, e, http.MethodPost, "/messages?stream_protocol=9", models.SendMessageRequest{Message: "Hi", Stream: true}, session)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unsupported protocol: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestSendMessage_OthersConversation(t *testing.T) {
+	e, env, stub, _, session := newChatServer(t)
+
+	rec := testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{Message: "What should I cook?"}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("send: status %d, body %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		ConversationID uuid.UUID `json:"conversation_id"`
+	}
+	testutil.DecodeJSON(t, rec, &body)
+
+	stranger := env.CreateUser(t, "mallory@example.com", "password123")
+	token, err := env.Auth.GenerateAccessToken(stranger.ID, stranger.Username)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	strangerSession := &http.Cookie{Name: "access_token", Value: token}
+
+	// A user without access is turned away before the conversation is
+	// locked, so the owner's messages aren't held up
+	for i := 0; i < 3; i++ {
+		rec = testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{
+			Message:        "Let me in",
+			ConversationID: &body.ConversationID,
+		}, strangerSession)
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("stranger: status %d, want %d", rec.Code, http.StatusForbidden)
+		}
+	}
+	if len(stub.Requests()) != 1 {
+		t.Fatalf("model got %d requests, want only the owner's", len(stub.Requests()))
+	}
+
+	rec = testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{
+		Message:        "Without cheese?",
+		ConversationID: &body.ConversationID,
+	}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("owner follow-up: status %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSendMessage_Unauthenticated(t *testing.T) {
+	e, _, stub, _, _ := newChatServer(t)
+
+	rec := testutil.Request(t, e, http.MethodPost, "/messages", models.SendMessageRequest{Message: "Hi"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("send: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if len(stub.Requests()) != 0 {
+		t.Fatal("unauthenticated message reached the model")
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/apiversion"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// messageV2 is the v2 message schema: a chat role instead of the sender
+// type, the author only for user messages, and content as typed parts so
+// other kinds of content can be added without another version
+type messageV2 struct {
+	ID             int64           `json:"id"`
+	ConversationID uuid.UUID       `json:"conversation_id"`
+	Role           string          `json:"role"`
+	AuthorID       *uuid.UUID      `json:"author_id,omitempty"`
+	Content        []contentPartV2 `json:"content"`
+	Metadata       json.RawMessage `json:"metadata,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeletedAt      *time.Time      `json:"deleted_at,omitempty"`
+}
+
+type contentPartV2 struct {
+	Type     string     `json:"type"`
+	Text     string     `json:"text"`
+	Language string     `json:"language,omitempty"`
+	UploadID *uuid.UUID `json:"upload_id,omitempty"`
+}
+
+// contentV2 returns the parts of a message sent as parts, and the content
+// of other messages as one text part
+func contentV2(m models.Message) []contentPartV2 {
+	if len(m.Parts) == 0 {
+		return []contentPartV2{{Type: models.ContentPartText, Text: m.Content}}
+	}
+
+	parts := make([]contentPartV2, 0, len(m.Parts))
+	for _, part := range m.Parts {
+		parts = append(parts, contentPartV2{
+			Type:     part.Type,
+			Text:     part.Text,
+			Language: part.Language,
+			UploadID: part.UploadID,
+		})
+	}
+	return parts
+}
+
+// messageMapper renders messages for the version of the request
+var messageMapper = apiversion.Mapper[models.Message]{
+	apiversion.V2: func(m models.Message) interface{} {
+		dto := messageV2{
+			ID:             m.ID,
+			ConversationID: m.ConversationID,
+			Role:           "assistant",
+			Content:        contentV2(m),
+			Metadata:       m.Metadata,
+			CreatedAt:      m.CreatedAt,
+			DeletedAt:      m.DeletedAt,
+		}
+		if m.SenderType == models.SenderTypeUser {
+			dto.Role = "user"
+			authorID := m.SenderID
+			dto.AuthorID = &authorID
+		}
+		return dto
+	},
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/events"
+	"github.com/shivaluma/eino-agent/internal/sse"
+
+	"github.com/labstack/echo/v4"
+)
+
+// EventsHandler streams live updates about the user's conversations
+type EventsHandler struct {
+	bus     events.Bus
+	authSvc *auth.Service
+	sse     config.SSEConfig
+}
+
+func NewEventsHandler(bus events.Bus, authSvc *auth.Service, sseConfig config.SSEConfig) *EventsHandler {
+	return &EventsHandler{bus: bus, authSvc: authSvc, sse: sseConfig}
+}
+
+// Stream sends the user's events over SSE until the client disconnects.
+// Events published while the client is disconnected are lost, so clients
+// should refetch the conversation list when they reconnect.
+func (h *EventsHandler) Stream(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	ctx := c.Request().Context()
+	updates, err := h.bus.Subscribe(ctx, userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to subscribe to events")
+	}
+
+	// A client too slow for its updates misses the oldest ones, as when a
+	// subscriber falls behind the bus
+	writer := sse.NewWriter(ctx, c.Response(), sseWriterConfig(h.sse, sse.OverflowDropOldest))
+	defer writer.Close()
+
+	for event := range updates {
+		if err := writer.SendJSON("", event); err != nil {
+			return nil // Client disconnected
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/ai/templates"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type FeedbackHandler struct {
+	feedbackRepo *repository.FeedbackRepository
+	convRepo     *repository.ConversationRepository
+	participants *repository.ParticipantRepository
+	authSvc      *auth.Service
+}
+
+func NewFeedbackHandler(feedbackRepo *repository.FeedbackRepository, convRepo *repository.ConversationRepository, participants *repository.ParticipantRepository, authSvc *auth.Service) *FeedbackHandler {
+	return &FeedbackHandler{
+		feedbackRepo: feedbackRepo,
+		convRepo:     convRepo,
+		participants: participants,
+		authSvc:      authSvc,
+	}
+}
+
+// SubmitFeedback records a thumbs up/down on an AI reply. Submitting again
+// replaces the user's earlier feedback.
+func (h *FeedbackHandler) SubmitFeedback(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid conversation ID")
+	}
+
+	messageID, err := strconv.ParseInt(c.Param("messageID"), 10, 64)
+	if err != nil {
+		return apierror.BadRequest("Invalid message ID")
+	}
+
+	var req models.MessageFeedbackRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	ctx := c.Request().Context()
+	conversation, err := h.convRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return apierror.NotFound("Conversation not found")
+	}
+
+	role, err := conversationRole(ctx, h.participants, conversation, userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to check conversation access")
+	}
+	if !models.CanRead(role) {
+		return apierror.Forbidden("Access denied")
+	}
+
+	message, err := h.convRepo.GetMessageByID(ctx, conversation.ID, messageID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch message")
+	}
+	if message == nil {
+		return apierror.NotFound("Message not found")
+	}
+	if message.SenderType != models.SenderTypeAgent {
+		return apierror.BadRequest("Feedback can only be given on AI replies")
+	}
+
+	feedback := &models.MessageFeedback{
+		MessageID: message.ID,
+		UserID:    userClaims.UserID,
+		Rating:    req.Rating,
+	}
+	if comment := strings.TrimSpace(req.Comment); comment != "" {
+		feedback.Comment = &comment
+	}
+
+	if err := h.feedbackRepo.Upsert(ctx, feedback); err != nil {
+		return apierror.Internal("Failed to save feedback")
+	}
+
+	return c.JSON(http.StatusOK, feedback)
+}
+
+// GetFeedbackSummary aggregates feedback per persona for admins.
+// Query params: from, to (RFC3339).
+func (h *FeedbackHandler) GetFeedbackSummary(c echo.Context) error {
+	filter := &models.FeedbackFilter{}
+
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		from, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return apierror.BadRequest("Invalid from timestamp, expected RFC3339")
+		}
+		filter.From = &from
+	}
+
+	if toStr := c.QueryParam("to"); toStr != "" {
+		to, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return apierror.BadRequest("Invalid to timestamp, expected RFC3339")
+		}
+		filter.To = &to
+	}
+
+	summaries, err := h.feedbackRepo.Summarize(c.Request().Context(), filter)
+	if err != nil {
+		return apierror.Internal("Failed to fetch feedback summary")
+	}
+
+	for i := range summaries {
+		if summaries[i].Persona == "" {
+			summaries[i].Persona = templates.DefaultPersona
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"personas": summaries,
+	})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+// FileHandler serves objects from local storage through signed URLs
+type FileHandler struct {
+	store *storage.Local
+}
+
+func NewFileHandler(store *storage.Local) *FileHandler {
+	return &FileHandler{store: store}
+}
+
+// ServeSigned streams an object if the URL's signature is valid and
+// unexpired. No other authentication is required.
+func (h *FileHandler) ServeSigned(c echo.Context) error {
+	key := c.Param("*")
+
+	if err := h.store.Verify(key, c.QueryParam("expires"), c.QueryParam("signature")); err != nil {
+		return apierror.Forbidden("Invalid or expired link")
+	}
+
+	reader, err := h.store.Get(c.Request().Context(), key)
+	if err == storage.ErrNotFound {
+		return apierror.NotFound("File not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to read file")
+	}
+	defer reader.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+
+	c.Response().Header().Set("Cache-Control", "private, max-age=300")
+	return c.Stream(http.StatusOK, contentType, reader)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/shivaluma/eino-agent/internal/health"
+
+	"github.com/labstack/echo/v4"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+	}
+}
+
+// Live reports that the process is serving requests. It doesn't touch any
+// dependency so an outage elsewhere never gets the server restarted.
+func (h *HealthHandler) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": string(health.StatusUp)})
+}
+
+// Ready reports per-dependency status. It returns 503 when a critical
+// dependency is down so the instance is taken out of rotation; a degraded
+// instance keeps serving.
+func (h *HealthHandler) Ready(c echo.Context) error {
+	report := h.checker.Check(c.Request().Context())
+
+	status := http.StatusOK
+	if report.Status == health.StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	return c.JSON(status, report)
+}
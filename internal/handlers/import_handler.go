@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/imports"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type ImportHandler struct {
+	importer *imports.Importer
+	jobs     *repository.ImportRepository
+	authSvc  *auth.Service
+	config   config.ImportConfig
+}
+
+func NewImportHandler(importer *imports.Importer, jobs *repository.ImportRepository, authSvc *auth.Service, cfg config.ImportConfig) *ImportHandler {
+	return &ImportHandler{
+		importer: importer,
+		jobs:     jobs,
+		authSvc:  authSvc,
+		config:   cfg,
+	}
+}
+
+// Import imports the conversations of a ChatGPT export uploaded as a
+// multipart file (form field "file"): its conversations.json or the zip
+// archive as downloaded. Exports up to IMPORT_SYNC_BYTES are imported right
+// away and answered with the report; larger ones are answered 202 with a
+// job to poll.
+func (h *ImportHandler) Import(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	// Leave headroom for the multipart envelope around the file
+	maxSize := h.config.MaxBytes
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxSize+64*1024)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return apierror.BadRequest("File is required")
+	}
+	tooLarge := apierror.PayloadTooLarge(fmt.Sprintf("Export exceeds the %d byte limit", maxSize)).
+		WithDetails(map[string]int64{"max_bytes": maxSize})
+	if fileHeader.Size > maxSize {
+		return tooLarge
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return apierror.BadRequest("Failed to read file")
+	}
+	defer file.Close()
+
+	export, size, err := imports.OpenExport(file, fileHeader.Size, maxSize)
+	switch {
+	case errors.Is(err, imports.ErrExportTooLarge):
+		return tooLarge
+	case errors.Is(err, imports.ErrInvalidExport):
+		return apierror.Unprocessable("File is not a ChatGPT conversations export")
+	case err != nil:
+		return apierror.BadRequest("Failed to read file")
+	}
+
+	ctx := c.Request().Context()
+	if size > h.config.SyncBytes {
+		job, err := h.importer.Start(ctx, userClaims.UserID, export)
+		if err != nil {
+			return apierror.Internal("Failed to start import")
+		}
+		return c.JSON(http.StatusAccepted, job)
+	}
+
+	report, err := h.importer.Import(ctx, userClaims.UserID, export)
+	if errors.Is(err, imports.ErrInvalidExport) {
+		// Conversations before the broken part stay imported
+		return apierror.Unprocessable("File is not a ChatGPT conversations export").WithDetails(report)
+	}
+	if err != nil {
+		return apierror.Internal("Failed to import conversations")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": models.ImportCompleted,
+		"report": report,
+	})
+}
+
+// GetImport returns one of the current user's import jobs
+func (h *ImportHandler) GetImport(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid import ID")
+	}
+
+	job, err := h.jobs.GetByID(c.Request().Context(), jobID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch import")
+	}
+	if job == nil || job.UserID != userClaims.UserID {
+		return apierror.NotFound("Import not found")
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
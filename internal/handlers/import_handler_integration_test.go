@@ -0,0 +1,154 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/shivaluma/eino-agent/internal/imports"
+	"github.com/shivaluma/eino-agent/internal/middleware"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/testutil"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// chatgptExport is a conversations.json with one conversation: two
+// answers to the first question, the second one kept, and a tool call
+// left out
+const chatgptExport = `[{
+	"title": "Weeknight pasta",
+	"create_time": 1700000000.5,
+	"update_time": 1700000100,
+	"current_node": "d",
+	"mapping": {
+		"root": {"message": null, "parent": null},
+		"a": {"message": {"author": {"role": "user"}, "content": {"content_type": "text", "parts": ["How long do I boil penne?"]}, "create_time": 1700000010}, "parent": "root"},
+		"b1": {"message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["An answer that was regenerated"]}, "create_time": 1700000020}, "parent": "a"},
+		"b": {"message": {"author": {"role": "assistant"}, "recipient": "all", "metadata": {"model_slug": "gpt-4o"}, "content": {"content_type": "text", "parts": ["About 11 minutes."]}, "create_time": 1700000030}, "parent": "a"},
+		"t": {"message": {"author": {"role": "assistant"}, "recipient": "python", "content": {"content_type": "code", "text": "print(11)"}, "create_time": 1700000040}, "parent": "b"},
+		"d": {"message": {"author": {"role": "user"}, "content": {"content_type": "text", "parts": ["Thanks!"]}, "create_time": 1700000050}, "parent": "t"}
+	}
+}]`
+
+// newImportServer routes the import endpoints for a new user, importing
+// exports larger than syncBytes in the background
+func newImportServer(t *testing.T, syncBytes int64) (*echo.Echo, *testutil.Env, *imports.Importer, *models.User, *http.Cookie) {
+	env := testutil.NewEnv(t)
+	h, importer := env.ImportHandler(t, syncBytes)
+
+	e := testutil.NewEcho()
+	protected := e.Group("", middleware.AuthMiddleware(env.Auth))
+	protected.POST("/import", h.Import)
+	protected.GET("/import/:id", h.GetImport)
+
+	user := env.CreateUser(t, "ada@example.com", "password123")
+	token, err := env.Auth.GenerateAccessToken(user.ID, user.Username)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	return e, env, importer, user, &http.Cookie{Name: "access_token", Value: token}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	e, env, _, user, session := newImportServer(t, 1<<20)
+
+	rec := testutil.Upload(t, e, "/import", "file", "conversations.json", []byte(chatgptExport), session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import: status %d, body %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Status string              `json:"status"`
+		Report models.ImportReport `json:"report"`
+	}
+	testutil.DecodeJSON(t, rec, &body)
+	if body.Status != models.ImportCompleted || body.Report.Conversations != 1 || body.Report.Messages != 3 {
+		t.Fatalf("response = %+v, want one conversation with 3 messages", body)
+	}
+
+	// The branch shown last is imported under the user, tool calls left out
+	conversationID := body.Report.ConversationIDs[0]
+	conversation, role, err := env.Conversations.GetByIDForUser(ctx, conversationID, user.ID)
+	if err != nil {
+		t.Fatalf("GetByIDForUser: %v", err)
+	}
+	if conversation == nil || role != models.ParticipantRoleOwner || *conversation.Title != "Weeknight pasta" {
+		t.Fatalf("conversation = %+v, %q, want the export's, owned by the user", conversation, role)
+	}
+	messages, err := env.Conversations.GetMessages(ctx, conversationID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	want := []string{"How long do I boil penne?", "About 11 minutes.", "Thanks!"}
+	if len(messages) != len(want) {
+		t.Fatalf("imported %d messages, want %d", len(messages), len(want))
+	}
+	for i, message := range messages {
+		if message.Content != want[i] {
+			t.Fatalf("message %d = %q, want %q", i, message.Content, want[i])
+		}
+	}
+	if messages[0].SenderID != user.ID || messages[1].SenderType != models.SenderTypeAgent {
+		t.Fatalf("senders = %s, %s, want the user then the assistant", messages[0].SenderID, messages[1].SenderType)
+	}
+	if got := messages[0].CreatedAt.Unix(); got != 1700000010 {
+		t.Fatalf("first message created at %d, want the export's time", got)
+	}
+
+	// Anything else is turned away
+	rec = testutil.Upload(t, e, "/import", "file", "conversations.json", []byte(`{"not":"an export"}`), session)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid export: status %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestImport_Background(t *testing.T) {
+	ctx := context.Background()
+	e, _, importer, _, session := newImportServer(t, 0)
+
+	// The zip archive as downloaded is accepted too
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create("export/conversations.json")
+	if err != nil {
+		t.Fatalf("zip Create: %v", err)
+	}
+	w.Write([]byte(chatgptExport))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip Close: %v", err)
+	}
+
+	rec := testutil.Upload(t, e, "/import", "file", "export.zip", archive.Bytes(), session)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("import: status %d, body %s", rec.Code, rec.Body)
+	}
+	var job models.ImportJob
+	testutil.DecodeJSON(t, rec, &job)
+	if job.Status != models.ImportPending {
+		t.Fatalf("job status = %q, want %q", job.Status, models.ImportPending)
+	}
+
+	// Run the queued task, then again as if it were delivered twice
+	payload, _ := json.Marshal(map[string]uuid.UUID{"job_id": job.ID})
+	for range 2 {
+		if err := importer.Handle(ctx, payload); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+	}
+
+	rec = testutil.Request(t, e, http.MethodGet, "/import/"+job.ID.String(), nil, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get: status %d, body %s", rec.Code, rec.Body)
+	}
+	testutil.DecodeJSON(t, rec, &job)
+	if job.Status != models.ImportCompleted || job.Report == nil || job.Report.Conversations != 1 || job.FinishedAt == nil {
+		t.Fatalf("job = %+v, want one conversation imported once", job)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type MemoryHandler struct {
+	memoryRepo *repository.MemoryRepository
+	authSvc    *auth.Service
+}
+
+func NewMemoryHandler(memoryRepo *repository.MemoryRepository, authSvc *auth.Service) *MemoryHandler {
+	return &MemoryHandler{
+		memoryRepo: memoryRepo,
+		authSvc:    authSvc,
+	}
+}
+
+// ListMemories returns what the agent remembers about the current user
+func (h *MemoryHandler) ListMemories(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	memories, err := h.memoryRepo.ListByUser(c.Request().Context(), userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch memories")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"memories": memories,
+	})
+}
+
+// DeleteMemory makes the agent forget one memory
+func (h *MemoryHandler) DeleteMemory(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	memoryID, err := uuid.Parse(c.Param("memoryId"))
+	if err != nil {
+		return apierror.BadRequest("Invalid memory ID")
+	}
+
+	deleted, err := h.memoryRepo.Delete(c.Request().Context(), userClaims.UserID, memoryID)
+	if err != nil {
+		return apierror.Internal("Failed to delete memory")
+	}
+	if !deleted {
+		return apierror.NotFound("Memory not found")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Memory deleted",
+	})
+}
+
+// DeleteAllMemories makes the agent forget everything about the current user
+func (h *MemoryHandler) DeleteAllMemories(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	deleted, err := h.memoryRepo.DeleteAll(c.Request().Context(), userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to delete memories")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Memories deleted",
+		"deleted": deleted,
+	})
+}
@@ -1,41 +1,59 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/audit"
 	"github.com/shivaluma/eino-agent/internal/auth"
 	"github.com/shivaluma/eino-agent/internal/logger"
 	"github.com/shivaluma/eino-agent/internal/models"
 	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/security"
 	"golang.org/x/oauth2"
 )
 
 type OAuthHandler struct {
 	userRepo    *repository.UserRepository
 	oauthRepo   *repository.OAuthRepository
+	tx          *repository.Transactor
+	stateStore  *auth.StateStore
 	authSvc     *auth.Service
 	oauthSvc    *auth.OAuthService
+	auditor     *audit.Auditor
+	monitor     *security.Monitor
 	frontendURL string
 }
 
 func NewOAuthHandler(
 	userRepo *repository.UserRepository,
 	oauthRepo *repository.OAuthRepository,
+	tx *repository.Transactor,
+	stateStore *auth.StateStore,
 	authSvc *auth.Service,
 	oauthSvc *auth.OAuthService,
+	auditor *audit.Auditor,
+	monitor *security.Monitor,
 	frontendURL string,
 ) *OAuthHandler {
 	return &OAuthHandler{
 		userRepo:    userRepo,
 		oauthRepo:   oauthRepo,
+		tx:          tx,
+		stateStore:  stateStore,
 		authSvc:     authSvc,
 		oauthSvc:    oauthSvc,
+		auditor:     auditor,
+		monitor:     monitor,
 		frontendURL: frontendURL,
 	}
 }
@@ -45,17 +63,13 @@ func (h *OAuthHandler) InitiateOAuth(c echo.Context) error {
 	provider := c.Param("provider")
 
 	if !h.oauthSvc.IsProviderEnabled(provider) {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": fmt.Sprintf("Provider %s is not enabled", provider),
-		})
+		return apierror.BadRequest(fmt.Sprintf("Provider %s is not enabled", provider))
 	}
 
 	// Generate state for CSRF protection
 	state, err := h.oauthSvc.GenerateState()
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate state",
-		})
+		return apierror.Internal("Failed to generate state")
 	}
 
 	// Store state in database with expiration
@@ -69,9 +83,7 @@ func (h *OAuthHandler) InitiateOAuth(c echo.Context) error {
 	if c.QueryParam("pkce") == "true" {
 		verifier, challenge, err := h.oauthSvc.GeneratePKCE()
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "Failed to generate PKCE",
-			})
+			return apierror.Internal("Failed to generate PKCE")
 		}
 		oauthState.CodeVerifier = &verifier
 
@@ -83,15 +95,11 @@ func (h *OAuthHandler) InitiateOAuth(c echo.Context) error {
 			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
 		)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "Failed to generate authorization URL",
-			})
+			return apierror.Internal("Failed to generate authorization URL")
 		}
 
-		if err := h.oauthRepo.StoreState(c.Request().Context(), oauthState); err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "Failed to store OAuth state",
-			})
+		if err := h.stateStore.Store(c.Request().Context(), oauthState); err != nil {
+			return apierror.Internal("Failed to store OAuth state")
 		}
 
 		return c.JSON(http.StatusOK, map[string]string{
@@ -103,15 +111,11 @@ func (h *OAuthHandler) InitiateOAuth(c echo.Context) error {
 	// Regular OAuth flow without PKCE
 	authURL, err := h.oauthSvc.GetAuthURL(provider, state)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate authorization URL",
-		})
+		return apierror.Internal("Failed to generate authorization URL")
 	}
 
-	if err := h.oauthRepo.StoreState(c.Request().Context(), oauthState); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to store OAuth state",
-		})
+	if err := h.stateStore.Store(c.Request().Context(), oauthState); err != nil {
+		return apierror.Internal("Failed to store OAuth state")
 	}
 
 	// For web flow, redirect directly
@@ -129,6 +133,7 @@ func (h *OAuthHandler) InitiateOAuth(c echo.Context) error {
 // HandleOAuthCallback handles the OAuth callback from the provider
 func (h *OAuthHandler) HandleOAuthCallback(c echo.Context) error {
 	provider := c.Param("provider")
+	log := logger.ModuleContext(c.Request().Context(), "auth")
 
 	code := c.QueryParam("code")
 	state := c.QueryParam("state")
@@ -148,8 +153,11 @@ func (h *OAuthHandler) HandleOAuthCallback(c echo.Context) error {
 		return c.Redirect(http.StatusTemporaryRedirect, redirectURL)
 	}
 
-	// Retrieve and validate state from database
-	storedState, err := h.oauthRepo.GetState(c.Request().Context(), state)
+	// Retrieve and consume state (one-time use)
+	storedState, err := h.stateStore.Consume(c.Request().Context(), state)
+	if err != nil {
+		log.Error().Err(err).Str("provider", provider).Msg("Failed to load OAuth state")
+	}
 	if err != nil || storedState == nil {
 		redirectURL := fmt.Sprintf("%s/sign-in?error=invalid_state", h.frontendURL)
 		return c.Redirect(http.StatusTemporaryRedirect, redirectURL)
@@ -157,21 +165,16 @@ func (h *OAuthHandler) HandleOAuthCallback(c echo.Context) error {
 
 	// Check state expiration
 	if time.Now().After(storedState.ExpiresAt) {
-		h.oauthRepo.DeleteState(c.Request().Context(), state)
 		redirectURL := fmt.Sprintf("%s/sign-in?error=state_expired", h.frontendURL)
 		return c.Redirect(http.StatusTemporaryRedirect, redirectURL)
 	}
 
-	// Delete state after validation (one-time use)
-	defer h.oauthRepo.DeleteState(c.Request().Context(), state)
-
 	// Exchange code for tokens
 	var opts []oauth2.AuthCodeOption
 	if storedState.CodeVerifier != nil {
 		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", *storedState.CodeVerifier))
 	}
 
-	log := logger.WithContext(c.Request().Context())
 	log.Debug().Str("provider", provider).Msg("Exchanging code for tokens")
 	token, err := h.oauthSvc.ExchangeCode(c.Request().Context(), provider, code, opts...)
 	if err != nil {
@@ -185,6 +188,12 @@ func (h *OAuthHandler) HandleOAuthCallback(c echo.Context) error {
 		Bool("has_refresh_token", token.RefreshToken != "").
 		Msg("Token exchange successful")
 
+	requested := h.oauthSvc.Scopes(provider)
+	if storedState.Scopes != nil {
+		requested = storedState.Scopes
+	}
+	granted := auth.GrantedScopes(token, requested)
+
 	// Get user info from provider
 	log.Debug().Str("provider", provider).Msg("Getting user info from provider")
 	userInfo, err := h.oauthSvc.GetUserInfo(c.Request().Context(), provider, token)
@@ -245,9 +254,15 @@ func (h *OAuthHandler) HandleOAuthCallback(c echo.Context) error {
 			Str("provider", provider).
 			Str("provider_id", userInfo.ID).
 			Msg("Database error while checking OAuth account")
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Database error during authentication",
-		})
+		return apierror.Internal("Database error during authentication")
+	}
+
+	// Linking an account or granting it scopes doesn't sign anyone in
+	if storedState.UserID != nil {
+		if storedState.Scopes == nil {
+			return h.completeLink(c, storedState, oauthAccount, userInfo, token, granted)
+		}
+		return h.completeScopes(c, storedState, oauthAccount, userInfo, token, granted)
 	}
 
 	if oauthAccount != nil {
@@ -292,6 +307,7 @@ func (h *OAuthHandler) HandleOAuthCallback(c echo.Context) error {
 		// Update user data
 		userDataJSON, _ := json.Marshal(userInfo)
 		oauthAccount.RawUserData = userDataJSON
+		oauthAccount.Scopes = granted
 
 		if err := h.oauthRepo.UpdateAccount(c.Request().Context(), oauthAccount); err != nil {
 			// Non-critical error, log but continue
@@ -299,6 +315,7 @@ func (h *OAuthHandler) HandleOAuthCallback(c echo.Context) error {
 		}
 	} else {
 		// New OAuth account - check if user with email exists
+		createUser := false
 		log.Info().
 			Str("provider", provider).
 			Str("provider_id", userInfo.ID).
@@ -428,24 +445,12 @@ func (h *OAuthHandler) HandleOAuthCallback(c echo.Context) error {
 				Str("provider_id", userInfo.ID).
 				Msg("Starting atomic user and OAuth account creation")
 
-			log.Debug().
-				Str("username", user.Username).
-				Str("email", user.Email).
-				Str("provider", provider).
-				Str("provider_id", userInfo.ID).
-				Msg("Creating user")
-			if err := h.userRepo.Create(c.Request().Context(), user); err != nil {
-				log.Error().Err(err).Msg("Failed to create user")
-				redirectURL := fmt.Sprintf("%s/sign-in?error=user_creation_failed", h.frontendURL)
-				return c.Redirect(http.StatusTemporaryRedirect, redirectURL)
-			}
-			log.Debug().Interface("user_id", user.ID).Msg("User created successfully")
+			createUser = true
 		}
 
 		// Create OAuth account
 		userDataJSON, _ := json.Marshal(userInfo)
 		oauthAccount = &models.OAuthAccount{
-			UserID:            user.ID,
 			Provider:          provider,
 			ProviderAccountID: userInfo.ID,
 			ProviderEmail:     &userInfo.Email,
@@ -453,6 +458,7 @@ func (h *OAuthHandler) HandleOAuthCallback(c echo.Context) error {
 			ProviderAvatarURL: &userInfo.AvatarURL,
 			AccessToken:       &token.AccessToken,
 			RawUserData:       userDataJSON,
+			Scopes:            granted,
 		}
 
 		if token.RefreshToken != "" {
@@ -462,38 +468,68 @@ func (h *OAuthHandler) HandleOAuthCallback(c echo.Context) error {
 			oauthAccount.TokenExpiresAt = &token.Expiry
 		}
 
-		log.Debug().
-			Interface("user_id", user.ID).
-			Str("provider", provider).
-			Str("provider_id", userInfo.ID).
-			Msg("Creating OAuth account")
+		// Create the user and OAuth account together so a failure can't
+		// leave behind a user without a way to sign in
+		failure := "oauth_account_creation_failed"
+		err := h.tx.WithTx(c.Request().Context(), func(ctx context.Context) error {
+			if createUser {
+				log.Debug().
+					Str("username", user.Username).
+					Str("email", user.Email).
+					Str("provider", provider).
+					Str("provider_id", userInfo.ID).
+					Msg("Creating user")
+				if err := h.userRepo.Create(ctx, user); err != nil {
+					failure = "user_creation_failed"
+					return fmt.Errorf("failed to create user: %w", err)
+				}
+			}
 
-		if err := h.oauthRepo.CreateAccount(c.Request().Context(), oauthAccount); err != nil {
-			log.Error().Err(err).Msg("Failed to create OAuth account")
-			redirectURL := fmt.Sprintf("%s/sign-in?error=oauth_account_creation_failed", h.frontendURL)
+			oauthAccount.UserID = user.ID
+			log.Debug().
+				Interface("user_id", user.ID).
+				Str("provider", provider).
+				Str("provider_id", userInfo.ID).
+				Msg("Creating OAuth account")
+			return h.oauthRepo.CreateAccount(ctx, oauthAccount)
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to create OAuth sign-up")
+			redirectURL := fmt.Sprintf("%s/sign-in?error=%s", h.frontendURL, failure)
 			return c.Redirect(http.StatusTemporaryRedirect, redirectURL)
 		}
-		log.Debug().Msg("OAuth account created successfully")
+		log.Debug().Interface("user_id", user.ID).Msg("OAuth account created successfully")
+	}
+
+	if h.monitor.CheckLogin(c, user) {
+		h.auditor.RecordRequest(c, models.AuditActionOAuthLogin, &user.ID, false, map[string]interface{}{
+			"provider": provider,
+			"reason":   "account_locked",
+		})
+		redirectURL := fmt.Sprintf("%s/sign-in?error=account_locked", h.frontendURL)
+		return c.Redirect(http.StatusTemporaryRedirect, redirectURL)
 	}
 
 	// Generate JWT tokens
 	accessToken, err := h.authSvc.GenerateAccessToken(user.ID, user.Username)
 	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("Failed to generate access token")
 		redirectURL := fmt.Sprintf("%s/sign-in?error=token_generation_failed", h.frontendURL)
 		return c.Redirect(http.StatusTemporaryRedirect, redirectURL)
 	}
 
 	refreshToken, err := h.authSvc.GenerateRefreshToken()
 	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("Failed to generate refresh token")
 		redirectURL := fmt.Sprintf("%s/sign-in?error=token_generation_failed", h.frontendURL)
 		return c.Redirect(http.StatusTemporaryRedirect, redirectURL)
 	}
 
 	// Store refresh token
-	refreshTokenRecord := h.authSvc.CreateRefreshTokenRecord(user.ID, refreshToken)
+	refreshTokenRecord := h.authSvc.CreateRefreshTokenRecord(user.ID, refreshToken, requestDevice(c), nil)
 	if err := h.userRepo.StoreRefreshToken(c.Request().Context(), refreshTokenRecord); err != nil {
 		// Non-critical error
-		fmt.Printf("Failed to store refresh token: %v\n", err)
+		log.Warn().Err(err).Str("user_id", user.ID.String()).Msg("Failed to store refresh token")
 	}
 
 	// Set secure HTTP-only cookies for tokens (following world best practices)
@@ -519,11 +555,210 @@ func (h *OAuthHandler) HandleOAuthCallback(c echo.Context) error {
 		MaxAge:   7 * 24 * 60 * 60, // 7 days
 	})
 
+	h.auditor.RecordRequest(c, models.AuditActionOAuthLogin, &user.ID, true, map[string]interface{}{
+		"provider": provider,
+	})
+
 	// Redirect to frontend OAuth callback for client-side handling
 	redirectURL := fmt.Sprintf("%s/oauth/callback?success=true", h.frontendURL)
 	return c.Redirect(http.StatusTemporaryRedirect, redirectURL)
 }
 
+// linkedURL is where a link or scopes flow returns to on the frontend
+func (h *OAuthHandler) linkedURL(state *models.OAuthState) string {
+	if state.RedirectURI != nil {
+		return h.frontendURL + *state.RedirectURI
+	}
+	return h.frontendURL + "/account/linked?provider=" + url.QueryEscape(state.Provider)
+}
+
+// setToken stores the new token of account with the user data and scopes
+// that came with it
+func setToken(account *models.OAuthAccount, userInfo *models.OAuthUserInfo, token *oauth2.Token, granted []string) {
+	account.AccessToken = &token.AccessToken
+	if token.RefreshToken != "" {
+		account.RefreshToken = &token.RefreshToken
+	}
+	if !token.Expiry.IsZero() {
+		account.TokenExpiresAt = &token.Expiry
+	}
+	userDataJSON, _ := json.Marshal(userInfo)
+	account.RawUserData = userDataJSON
+	account.Scopes = granted
+}
+
+// completeLink finishes a LinkOAuthAccount flow, linking the provider
+// account to the user unless another user has it or the user already has
+// another account of the provider
+func (h *OAuthHandler) completeLink(c echo.Context, state *models.OAuthState, account *models.OAuthAccount, userInfo *models.OAuthUserInfo, token *oauth2.Token, granted []string) error {
+	ctx := c.Request().Context()
+	log := logger.ModuleContext(ctx, "auth")
+	redirectURL := h.linkedURL(state)
+	fail := func(reason string) error {
+		h.auditor.RecordRequest(c, models.AuditActionOAuthLink, state.UserID, false, map[string]interface{}{
+			"provider": state.Provider,
+			"reason":   reason,
+		})
+		return c.Redirect(http.StatusTemporaryRedirect, redirectURL+"&error="+reason)
+	}
+
+	if account != nil {
+		if account.UserID != *state.UserID {
+			log.Warn().
+				Str("provider", state.Provider).
+				Str("user_id", state.UserID.String()).
+				Msg("Provider account is linked to another user")
+			return fail("account_already_linked")
+		}
+		// Linked already; the new token is kept as on sign-in
+		setToken(account, userInfo, token, granted)
+		if err := h.oauthRepo.UpdateAccount(ctx, account); err != nil {
+			log.Warn().Err(err).Msg("Failed to update OAuth account")
+		}
+		return c.Redirect(http.StatusTemporaryRedirect, redirectURL+"&success=true")
+	}
+
+	accounts, err := h.oauthRepo.GetByUserID(ctx, *state.UserID)
+	if err != nil {
+		log.Error().Err(err).Str("provider", state.Provider).Msg("Failed to get OAuth accounts")
+		return fail("link_failed")
+	}
+	for _, linked := range accounts {
+		if linked.Provider == state.Provider {
+			return fail("provider_already_linked")
+		}
+	}
+
+	account = &models.OAuthAccount{
+		UserID:            *state.UserID,
+		Provider:          state.Provider,
+		ProviderAccountID: userInfo.ID,
+		ProviderEmail:     &userInfo.Email,
+		ProviderUsername:  &userInfo.Username,
+		ProviderAvatarURL: &userInfo.AvatarURL,
+	}
+	setToken(account, userInfo, token, granted)
+	if err := h.oauthRepo.CreateAccount(ctx, account); err != nil {
+		log.Error().Err(err).Str("provider", state.Provider).Msg("Failed to link OAuth account")
+		return fail("link_failed")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOAuthLink, state.UserID, true, map[string]interface{}{
+		"provider": state.Provider,
+	})
+
+	return c.Redirect(http.StatusTemporaryRedirect, redirectURL+"&success=true")
+}
+
+// completeScopes finishes a RequestScopes flow: the provider account must be
+// the one the user linked, which then keeps the new token and its scopes
+func (h *OAuthHandler) completeScopes(c echo.Context, state *models.OAuthState, account *models.OAuthAccount, userInfo *models.OAuthUserInfo, token *oauth2.Token, granted []string) error {
+	log := logger.ModuleContext(c.Request().Context(), "auth")
+	redirectURL := h.linkedURL(state)
+
+	if account == nil || account.UserID != *state.UserID {
+		log.Warn().
+			Str("provider", state.Provider).
+			Str("user_id", state.UserID.String()).
+			Msg("Scopes granted by a different provider account")
+		return c.Redirect(http.StatusTemporaryRedirect, redirectURL+"&error=account_mismatch")
+	}
+
+	setToken(account, userInfo, token, granted)
+	if err := h.oauthRepo.UpdateAccount(c.Request().Context(), account); err != nil {
+		log.Error().Err(err).Str("provider", state.Provider).Msg("Failed to store granted scopes")
+		return c.Redirect(http.StatusTemporaryRedirect, redirectURL+"&error=scope_update_failed")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOAuthScopes, &account.UserID, true, map[string]interface{}{
+		"provider": state.Provider,
+		"scopes":   granted,
+	})
+
+	return c.Redirect(http.StatusTemporaryRedirect, redirectURL+"&success=true")
+}
+
+// RequestScopes starts an authorization asking the user to grant more of
+// the provider's optional scopes to their linked account. The callback
+// stores what was granted and redirects to the account page; the user stays
+// signed in as before.
+func (h *OAuthHandler) RequestScopes(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	provider := c.Param("provider")
+	if !h.oauthSvc.IsProviderEnabled(provider) {
+		return apierror.BadRequest(fmt.Sprintf("Provider %s is not enabled", provider))
+	}
+
+	var req models.OAuthScopesRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	signInScopes := h.oauthSvc.Scopes(provider)
+	optionalScopes := h.oauthSvc.OptionalScopes(provider)
+	for _, scope := range req.Scopes {
+		if !slices.Contains(optionalScopes, scope) && !slices.Contains(signInScopes, scope) {
+			return apierror.BadRequest(fmt.Sprintf("Scope %s can't be requested", scope)).
+				WithDetails(map[string][]string{"optional_scopes": optionalScopes})
+		}
+	}
+
+	accounts, err := h.oauthRepo.GetByUserID(c.Request().Context(), userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to get OAuth accounts")
+	}
+	var account *models.OAuthAccount
+	for _, linked := range accounts {
+		if linked.Provider == provider {
+			account = linked
+			break
+		}
+	}
+	if account == nil {
+		return apierror.NotFound(fmt.Sprintf("No linked %s account", provider))
+	}
+
+	// Ask for everything the account needs, so the new token keeps what was
+	// granted before
+	scopes := slices.Concat(signInScopes, account.Scopes, req.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	state, err := h.oauthSvc.GenerateState()
+	if err != nil {
+		return apierror.Internal("Failed to generate state")
+	}
+	redirectURI := "/account/linked?provider=" + url.QueryEscape(provider)
+	oauthState := &models.OAuthState{
+		State:       state,
+		Provider:    provider,
+		RedirectURI: &redirectURI,
+		ExpiresAt:   time.Now().Add(10 * time.Minute),
+		UserID:      &userClaims.UserID,
+		Scopes:      scopes,
+	}
+	if err := h.stateStore.Store(c.Request().Context(), oauthState); err != nil {
+		return apierror.Internal("Failed to store OAuth state")
+	}
+
+	authURL, err := h.oauthSvc.GetAuthURL(provider, state, auth.WithScopes(scopes))
+	if err != nil {
+		return apierror.Internal("Failed to generate authorization URL")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"auth_url": authURL,
+		"state":    state,
+	})
+}
+
 // GetOAuthProviders returns the list of enabled OAuth providers
 func (h *OAuthHandler) GetOAuthProviders(c echo.Context) error {
 	providers := h.oauthSvc.GetEnabledProviders()
@@ -538,58 +773,39 @@ func (h *OAuthHandler) LinkOAuthAccount(c echo.Context) error {
 	// Get user from context (requires authentication)
 	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Unauthorized",
-		})
+		return apierror.Unauthorized("Unauthorized")
 	}
 
 	provider := c.Param("provider")
 
 	if !h.oauthSvc.IsProviderEnabled(provider) {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": fmt.Sprintf("Provider %s is not enabled", provider),
-		})
+		return apierror.BadRequest(fmt.Sprintf("Provider %s is not enabled", provider))
 	}
 
 	// Generate state with user ID embedded
 	state, err := h.oauthSvc.GenerateState()
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate state",
-		})
+		return apierror.Internal("Failed to generate state")
 	}
 
-	// Store state with user context
+	// The state carries the user, so the callback links the account to them
+	// instead of signing in; the link is audited there once it's made
 	redirectURI := fmt.Sprintf("/account/linked?provider=%s", provider)
 	oauthState := &models.OAuthState{
 		State:       state,
 		Provider:    provider,
 		RedirectURI: &redirectURI,
 		ExpiresAt:   time.Now().Add(10 * time.Minute),
+		UserID:      &userClaims.UserID,
 	}
 
-	if err := h.oauthRepo.StoreState(c.Request().Context(), oauthState); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to store OAuth state",
-		})
+	if err := h.stateStore.Store(c.Request().Context(), oauthState); err != nil {
+		return apierror.Internal("Failed to store OAuth state")
 	}
 
-	// Store user ID in session/cookie for linking after callback
-	c.SetCookie(&http.Cookie{
-		Name:     "oauth_link_user",
-		Value:    userClaims.UserID.String(),
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   600, // 10 minutes
-	})
-
 	authURL, err := h.oauthSvc.GetAuthURL(provider, state)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate authorization URL",
-		})
+		return apierror.Internal("Failed to generate authorization URL")
 	}
 
 	return c.Redirect(http.StatusTemporaryRedirect, authURL)
@@ -600,9 +816,7 @@ func (h *OAuthHandler) UnlinkOAuthAccount(c echo.Context) error {
 	// Get user from context (requires authentication)
 	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Unauthorized",
-		})
+		return apierror.Unauthorized("Unauthorized")
 	}
 
 	provider := c.Param("provider")
@@ -610,33 +824,29 @@ func (h *OAuthHandler) UnlinkOAuthAccount(c echo.Context) error {
 	// Check if user has other auth methods
 	user, err := h.userRepo.GetByID(c.Request().Context(), userClaims.UserID)
 	if err != nil || user == nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "User not found",
-		})
+		return apierror.NotFound("User not found")
 	}
 
 	// Count OAuth accounts
 	accounts, err := h.oauthRepo.GetByUserID(c.Request().Context(), userClaims.UserID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to get OAuth accounts",
-		})
+		return apierror.Internal("Failed to get OAuth accounts")
 	}
 
 	// Ensure user has another auth method
 	if len(accounts) <= 1 && user.PasswordHash == nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Cannot unlink the only authentication method",
-		})
+		return apierror.BadRequest("Cannot unlink the only authentication method")
 	}
 
 	// Delete OAuth account
 	if err := h.oauthRepo.DeleteByUserAndProvider(c.Request().Context(), userClaims.UserID, provider); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to unlink OAuth account",
-		})
+		return apierror.Internal("Failed to unlink OAuth account")
 	}
 
+	h.auditor.RecordRequest(c, models.AuditActionOAuthUnlink, &userClaims.UserID, true, map[string]interface{}{
+		"provider": provider,
+	})
+
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "OAuth account unlinked successfully",
 	})
@@ -647,16 +857,12 @@ func (h *OAuthHandler) GetLinkedAccounts(c echo.Context) error {
 	// Get user from context (requires authentication)
 	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Unauthorized",
-		})
+		return apierror.Unauthorized("Unauthorized")
 	}
 
 	accounts, err := h.oauthRepo.GetByUserID(c.Request().Context(), userClaims.UserID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to get linked accounts",
-		})
+		return apierror.Internal("Failed to get linked accounts")
 	}
 
 	// Filter sensitive data
@@ -667,7 +873,11 @@ func (h *OAuthHandler) GetLinkedAccounts(c echo.Context) error {
 			"username":   account.ProviderUsername,
 			"email":      account.ProviderEmail,
 			"avatar_url": account.ProviderAvatarURL,
+			"scopes":     account.Scopes,
 			"created_at": account.CreatedAt,
+
+			// Scopes the user may grant later for integrations
+			"optional_scopes": h.oauthSvc.OptionalScopes(account.Provider),
 		}
 		linkedAccounts = append(linkedAccounts, linkedAccount)
 	}
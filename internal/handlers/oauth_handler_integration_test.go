@@ -0,0 +1,357 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/shivaluma/eino-agent/internal/middleware"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+)
+
+// fakeGitHub answers the token exchange and the user API calls the GitHub
+// provider makes, in place of github.com
+type fakeGitHub struct {
+	user   map[string]interface{}
+	emails []map[string]interface{}
+
+	// scope is reported as granted with the token
+	scope string
+}
+
+func (f *fakeGitHub) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	switch {
+	case req.URL.Host == "github.com" && req.URL.Path == "/login/oauth/access_token":
+		rec.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rec).Encode(map[string]string{"access_token": "gh-token", "token_type": "bearer", "scope": f.scope})
+	case req.URL.Host == "api.github.com" && req.URL.Path == "/user":
+		json.NewEncoder(rec).Encode(f.user)
+	case req.URL.Host == "api.github.com" && req.URL.Path == "/user/emails":
+		json.NewEncoder(rec).Encode(f.emails)
+	default:
+		rec.WriteHeader(http.StatusNotFound)
+	}
+
+	resp := rec.Result()
+	resp.Request = req
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+	return resp, nil
+}
+
+// newOAuthServer routes the OAuth endpoints with GitHub enabled, sending
+// the provider's requests to github
+func newOAuthServer(t *testing.T, github *fakeGitHub) (*echo.Echo, *testutil.Env) {
+	env := testutil.NewEnv(t)
+	env.Config.OAuth.GitHub.Enabled = true
+	env.Config.OAuth.GitHub.ClientID = "client-id"
+	env.Config.OAuth.GitHub.ClientSecret = "client-secret"
+	env.Config.OAuth.GitHub.RedirectURL = "http://localhost/auth/oauth/github/callback"
+	env.Config.OAuth.GitHub.OptionalScopes = []string{"repo", "read:org"}
+	env.Config.OAuth.FrontendURL = "http://frontend.test"
+	h := env.OAuthHandler()
+
+	client := &http.Client{Transport: github}
+	e := testutil.NewEcho()
+	// oauth2 makes its requests with the client in the request context
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := context.WithValue(c.Request().Context(), oauth2.HTTPClient, client)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	})
+	e.GET("/auth/oauth/:provider/authorize", h.InitiateOAuth)
+	e.GET("/auth/oauth/:provider/callback", h.HandleOAuthCallback)
+	protected := e.Group("", middleware.AuthMiddleware(env.Auth))
+	protected.POST("/auth/oauth/:provider/link", h.LinkOAuthAccount)
+	protected.POST("/auth/oauth/:provider/scopes", h.RequestScopes)
+	return e, env
+}
+
+// authorize starts a sign-in and returns its state
+func authorize(t *testing.T, e *echo.Echo) string {
+	t.Helper()
+
+	rec := testutil.Request(t, e, http.MethodGet, "/auth/oauth/github/authorize?pkce=true", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("authorize: status %d, body %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		AuthURL string `json:"auth_url"`
+		State   string `json:"state"`
+	}
+	testutil.DecodeJSON(t, rec, &body)
+	if body.State == "" || !strings.HasPrefix(body.AuthURL, "https://github.com/login/oauth/authorize") {
+		t.Fatalf("authorize = %+v, want a GitHub URL and a state", body)
+	}
+	return body.State
+}
+
+func TestOAuth_GitHubSignInCreatesUser(t *testing.T) {
+	ctx := context.Background()
+	e, env := newOAuthServer(t, &fakeGitHub{
+		user: map[string]interface{}{"id": 4242, "login": "octo", "name": "Octo Cat", "email": ""},
+		emails: []map[string]interface{}{
+			{"email": "other@example.com", "primary": false, "verified": true},
+			{"email": "octo@example.com", "primary": true, "verified": true},
+		},
+	})
+
+	state := authorize(t, e)
+	rec := testutil.Request(t, e, http.MethodGet, "/auth/oauth/github/callback?code=abc&state="+url.QueryEscape(state), nil)
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("callback: status %d, body %s", rec.Code, rec.Body)
+	}
+	if location := rec.Header().Get("Location"); location != "http://frontend.test/oauth/callback?success=true" {
+		t.Fatalf("callback redirected to %q", location)
+	}
+	if testutil.Cookie(rec, "access_token") == nil || testutil.Cookie(rec, "refresh_token") == nil {
+		t.Fatalf("callback set cookies %v, want access_token and refresh_token", rec.Result().Cookies())
+	}
+
+	// The primary verified email is used when the profile has none
+	user, err := env.Users.GetByEmail(ctx, "octo@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	if user == nil || user.Username != "octo" {
+		t.Fatalf("signed-in user = %+v, want octo", user)
+	}
+	account, err := env.OAuth.GetByProviderID(ctx, "github", "4242")
+	if err != nil {
+		t.Fatalf("GetByProviderID: %v", err)
+	}
+	if account == nil || account.UserID != user.ID {
+		t.Fatalf("OAuth account = %+v, want one linked to %s", account, user.ID)
+	}
+
+	// The state is single use
+	rec = testutil.Request(t, e, http.MethodGet, "/auth/oauth/github/callback?code=abc&state="+url.QueryEscape(state), nil)
+	if location := rec.Header().Get("Location"); location != "http://frontend.test/sign-in?error=invalid_state" {
+		t.Fatalf("callback with a used state redirected to %q", location)
+	}
+}
+
+func TestOAuth_GitHubSignInLinksExistingUser(t *testing.T) {
+	ctx := context.Background()
+	e, env := newOAuthServer(t, &fakeGitHub{
+		user: map[string]interface{}{"id": 7, "login": "frank-gh", "email": "frank@example.com"},
+	})
+	existing := env.CreateUser(t, "frank@example.com", "password123")
+
+	state := authorize(t, e)
+	rec := testutil.Request(t, e, http.MethodGet, "/auth/oauth/github/callback?code=abc&state="+url.QueryEscape(state), nil)
+	if location := rec.Header().Get("Location"); location != "http://frontend.test/oauth/callback?success=true" {
+		t.Fatalf("callback redirected to %q", location)
+	}
+
+	account, err := env.OAuth.GetByProviderID(ctx, "github", "7")
+	if err != nil {
+		t.Fatalf("GetByProviderID: %v", err)
+	}
+	if account == nil || account.UserID != existing.ID {
+		t.Fatalf("OAuth account = %+v, want one linked to the existing user %s", account, existing.ID)
+	}
+}
+
+func TestOAuth_CallbackWithUnknownState(t *testing.T) {
+	e, _ := newOAuthServer(t, &fakeGitHub{})
+
+	rec := testutil.Request(t, e, http.MethodGet, "/auth/oauth/github/callback?code=abc&state=forged", nil)
+	if location := rec.Header().Get("Location"); location != "http://frontend.test/sign-in?error=invalid_state" {
+		t.Fatalf("callback redirected to %q", location)
+	}
+	if testutil.Cookie(rec, "access_token") != nil {
+		t.Fatal("callback with an unknown state signed in")
+	}
+}
+
+func TestOAuth_GitHubGrantsOptionalScopes(t *testing.T) {
+	ctx := context.Background()
+	github := &fakeGitHub{
+		user:  map[string]interface{}{"id": 99, "login": "grace-gh", "email": "grace@example.com"},
+		scope: "user:email",
+	}
+	e, env := newOAuthServer(t, github)
+
+	// Sign-in only asks for the sign-in scopes
+	state := authorize(t, e)
+	testutil.Request(t, e, http.MethodGet, "/auth/oauth/github/callback?code=abc&state="+url.QueryEscape(state), nil)
+	account, err := env.OAuth.GetByProviderID(ctx, "github", "99")
+	if err != nil || account == nil {
+		t.Fatalf("GetByProviderID = %+v, %v, want the new account", account, err)
+	}
+	if !slices.Equal(account.Scopes, []string{"user:email"}) {
+		t.Fatalf("scopes after sign-in = %v, want [user:email]", account.Scopes)
+	}
+
+	user, err := env.Users.GetByID(ctx, account.UserID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	token, err := env.Auth.GenerateAccessToken(user.ID, user.Username)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	session := &http.Cookie{Name: "access_token", Value: token}
+
+	// Only optional scopes can be asked for
+	rec := testutil.Request(t, e, http.MethodPost, "/auth/oauth/github/scopes", map[string]interface{}{"scopes": []string{"delete_repo"}}, session)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unlisted scope: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = testutil.Request(t, e, http.MethodPost, "/auth/oauth/github/scopes", map[string]interface{}{"scopes": []string{"repo"}}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("request scopes: status %d, body %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		AuthURL string `json:"auth_url"`
+		State   string `json:"state"`
+	}
+	testutil.DecodeJSON(t, rec, &body)
+	authURL, err := url.Parse(body.AuthURL)
+	if err != nil {
+		t.Fatalf("auth URL %q: %v", body.AuthURL, err)
+	}
+	if scope := authURL.Query().Get("scope"); scope != "repo user:email" {
+		t.Fatalf("requested scope = %q, want the granted ones and repo", scope)
+	}
+
+	// The callback stores the grant and leaves the session alone
+	github.scope = "repo,user:email"
+	rec = testutil.Request(t, e, http.MethodGet, "/auth/oauth/github/callback?code=abc&state="+url.QueryEscape(body.State), nil)
+	if location := rec.Header().Get("Location"); location != "http://frontend.test/account/linked?provider=github&success=true" {
+		t.Fatalf("callback redirected to %q", location)
+	}
+	if testutil.Cookie(rec, "access_token") != nil {
+		t.Fatal("granting scopes signed in again")
+	}
+	account, err = env.OAuth.GetByProviderID(ctx, "github", "99")
+	if err != nil {
+		t.Fatalf("GetByProviderID: %v", err)
+	}
+	if !slices.Equal(account.Scopes, []string{"repo", "user:email"}) {
+		t.Fatalf("scopes after the grant = %v, want [repo user:email]", account.Scopes)
+	}
+}
+
+func TestOAuth_GrantFromAnotherAccount(t *testing.T) {
+	ctx := context.Background()
+	github := &fakeGitHub{user: map[string]interface{}{"id": 11, "login": "hal-gh", "email": "hal@example.com"}}
+	e, env := newOAuthServer(t, github)
+
+	state := authorize(t, e)
+	testutil.Request(t, e, http.MethodGet, "/auth/oauth/github/callback?code=abc&state="+url.QueryEscape(state), nil)
+	user, err := env.Users.GetByEmail(ctx, "hal@example.com")
+	if err != nil || user == nil {
+		t.Fatalf("GetByEmail = %+v, %v, want the new user", user, err)
+	}
+	token, err := env.Auth.GenerateAccessToken(user.ID, user.Username)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	rec := testutil.Request(t, e, http.MethodPost, "/auth/oauth/github/scopes", map[string]interface{}{"scopes": []string{"repo"}},
+		&http.Cookie{Name: "access_token", Value: token})
+	var body struct {
+		State string `json:"state"`
+	}
+	testutil.DecodeJSON(t, rec, &body)
+
+	// The user authorizes with a GitHub account that isn't theirs here
+	github.user = map[string]interface{}{"id": 12, "login": "someone-else", "email": "else@example.com"}
+	github.scope = "repo,user:email"
+	rec = testutil.Request(t, e, http.MethodGet, "/auth/oauth/github/callback?code=abc&state="+url.QueryEscape(body.State), nil)
+	if location := rec.Header().Get("Location"); location != "http://frontend.test/account/linked?provider=github&error=account_mismatch" {
+		t.Fatalf("callback redirected to %q", location)
+	}
+	if account, _ := env.OAuth.GetByProviderID(ctx, "github", "12"); account != nil {
+		t.Fatalf("the other GitHub account was linked: %+v", account)
+	}
+	if user, _ := env.Users.GetByEmail(ctx, "else@example.com"); user != nil {
+		t.Fatalf("the other GitHub account signed up: %+v", user)
+	}
+}
+
+func TestOAuth_LinkAccount(t *testing.T) {
+	ctx := context.Background()
+	github := &fakeGitHub{user: map[string]interface{}{"id": 21, "login": "ivy-gh", "email": "ivy-gh@example.com"}}
+	e, env := newOAuthServer(t, github)
+
+	user := env.CreateUser(t, "ivy@example.com", "password123")
+	links := func() []models.AuditEvent {
+		t.Helper()
+		events, err := env.Auditor.List(ctx, &models.AuditEventFilter{UserID: &user.ID, Action: models.AuditActionOAuthLink, Limit: 10})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		return events
+	}
+	link := func(user *models.User) string {
+		t.Helper()
+		token, err := env.Auth.GenerateAccessToken(user.ID, user.Username)
+		if err != nil {
+			t.Fatalf("GenerateAccessToken: %v", err)
+		}
+		rec := testutil.Request(t, e, http.MethodPost, "/auth/oauth/github/link", nil, &http.Cookie{Name: "access_token", Value: token})
+		if rec.Code != http.StatusTemporaryRedirect {
+			t.Fatalf("link: status %d, body %s", rec.Code, rec.Body)
+		}
+		authURL, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("auth URL: %v", err)
+		}
+		rec = testutil.Request(t, e, http.MethodGet, "/auth/oauth/github/callback?code=abc&state="+url.QueryEscape(authURL.Query().Get("state")), nil)
+		if testutil.Cookie(rec, "access_token") != nil {
+			t.Fatal("linking signed in again")
+		}
+		return rec.Header().Get("Location")
+	}
+
+	// Starting the flow links nothing yet
+	token, err := env.Auth.GenerateAccessToken(user.ID, user.Username)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	testutil.Request(t, e, http.MethodPost, "/auth/oauth/github/link", nil, &http.Cookie{Name: "access_token", Value: token})
+	if events := links(); len(events) != 0 {
+		t.Fatalf("oauth.link audited before the callback: %+v", events)
+	}
+
+	if location := link(user); location != "http://frontend.test/account/linked?provider=github&success=true" {
+		t.Fatalf("callback redirected to %q", location)
+	}
+	account, err := env.OAuth.GetByProviderID(ctx, "github", "21")
+	if err != nil || account == nil || account.UserID != user.ID {
+		t.Fatalf("GetByProviderID = %+v, %v, want the account linked to the user", account, err)
+	}
+	if events := links(); len(events) != 1 || !events[0].Success {
+		t.Fatalf("oauth.link events = %+v, want one success", events)
+	}
+
+	// Another user can't take the GitHub account over
+	other := env.CreateUser(t, "jay@example.com", "password123")
+	if location := link(other); location != "http://frontend.test/account/linked?provider=github&error=account_already_linked" {
+		t.Fatalf("callback redirected to %q", location)
+	}
+	if account, _ := env.OAuth.GetByProviderID(ctx, "github", "21"); account.UserID != user.ID {
+		t.Fatalf("account moved to user %s", account.UserID)
+	}
+}
@@ -0,0 +1,863 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/ai/providers"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/audit"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/billing"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/mail"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/share"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type OrganizationHandler struct {
+	orgRepo      *repository.OrganizationRepository
+	userRepo     *repository.UserRepository
+	tx           *repository.Transactor
+	usage        *billing.Recorder
+	authSvc      *auth.Service
+	auditor      *audit.Auditor
+	mailer       mail.Mailer
+	inviteSigner *share.Signer
+	inviteTTL    time.Duration
+	frontendURL  string
+	credentials  *providers.OrgCredentials
+}
+
+// NewOrganizationHandler creates the organization handler. Invitation
+// tokens are signed like share tokens, with their own secret.
+func NewOrganizationHandler(orgRepo *repository.OrganizationRepository, userRepo *repository.UserRepository, tx *repository.Transactor, usage *billing.Recorder, authSvc *auth.Service, auditor *audit.Auditor, mailer mail.Mailer, inviteCfg config.InviteConfig, frontendURL string, credentials *providers.OrgCredentials) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgRepo:      orgRepo,
+		userRepo:     userRepo,
+		tx:           tx,
+		usage:        usage,
+		authSvc:      authSvc,
+		auditor:      auditor,
+		mailer:       mailer,
+		inviteSigner: share.NewSigner(inviteCfg.Secret),
+		inviteTTL:    inviteCfg.TTL,
+		frontendURL:  strings.TrimSuffix(frontendURL, "/"),
+		credentials:  credentials,
+	}
+}
+
+// loadOrganization fetches the organization in the :id path param along with
+// the current user's role in it. Organizations the user isn't a member of
+// are reported as not found. On failure it returns a nil organization and
+// the API error.
+func (h *OrganizationHandler) loadOrganization(c echo.Context) (*models.Organization, uuid.UUID, error) {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return nil, uuid.Nil, apierror.Unauthorized("Unauthorized")
+	}
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, uuid.Nil, apierror.BadRequest("Invalid organization ID")
+	}
+
+	ctx := c.Request().Context()
+	role, err := h.orgRepo.GetRole(ctx, orgID, userClaims.UserID)
+	if err != nil {
+		return nil, uuid.Nil, apierror.Internal("Failed to check organization membership")
+	}
+	if role == "" {
+		return nil, uuid.Nil, apierror.NotFound("Organization not found")
+	}
+
+	org, err := h.orgRepo.GetByID(ctx, orgID)
+	if err != nil {
+		return nil, uuid.Nil, apierror.Internal("Failed to fetch organization")
+	}
+	if org == nil {
+		return nil, uuid.Nil, apierror.NotFound("Organization not found")
+	}
+	org.Role = role
+
+	return org, userClaims.UserID, nil
+}
+
+// CreateOrganization creates an organization owned by the current user
+func (h *OrganizationHandler) CreateOrganization(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	var req models.CreateOrganizationRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	org := &models.Organization{Name: req.Name, CreatedBy: &userClaims.UserID}
+	if err := h.orgRepo.Create(c.Request().Context(), org); err != nil {
+		return apierror.Internal("Failed to create organization")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgCreate, &userClaims.UserID, true, map[string]interface{}{
+		"org_id": org.ID,
+	})
+
+	return c.JSON(http.StatusCreated, org)
+}
+
+// ListOrganizations returns the organizations the current user is a member
+// of, with their role
+func (h *OrganizationHandler) ListOrganizations(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	orgs, err := h.orgRepo.ListByUser(c.Request().Context(), userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch organizations")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"organizations": orgs,
+	})
+}
+
+// GetOrganization returns an organization the current user is a member of
+func (h *OrganizationHandler) GetOrganization(c echo.Context) error {
+	org, _, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, org)
+}
+
+// UpdateOrganization renames an organization. Owners and admins only.
+func (h *OrganizationHandler) UpdateOrganization(c echo.Context) error {
+	org, userID, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+	if !models.CanManageOrg(org.Role) {
+		return apierror.Forbidden("Only owners and admins can update the organization")
+	}
+
+	var req models.UpdateOrganizationRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	org.Name = req.Name
+	if err := h.orgRepo.Rename(c.Request().Context(), org); err != nil {
+		return apierror.Internal("Failed to update organization")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgUpdate, &userID, true, map[string]interface{}{
+		"org_id": org.ID,
+	})
+
+	return c.JSON(http.StatusOK, org)
+}
+
+// DeleteOrganization deletes an organization and its conversations. Owners
+// only.
+func (h *OrganizationHandler) DeleteOrganization(c echo.Context) error {
+	org, userID, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+	if org.Role != models.OrgRoleOwner {
+		return apierror.Forbidden("Only owners can delete the organization")
+	}
+
+	if err := h.orgRepo.Delete(c.Request().Context(), org.ID); err != nil {
+		return apierror.Internal("Failed to delete organization")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgDelete, &userID, true, map[string]interface{}{
+		"org_id": org.ID,
+		"name":   org.Name,
+	})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListMembers returns the members of an organization
+func (h *OrganizationHandler) ListMembers(c echo.Context) error {
+	org, _, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+
+	members, err := h.orgRepo.ListMembers(c.Request().Context(), org.ID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch members")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"members": members,
+	})
+}
+
+// AddMember adds an existing user to an organization. Owners and admins
+// may add members and admins; only owners may add owners.
+func (h *OrganizationHandler) AddMember(c echo.Context) error {
+	org, userID, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+	if !models.CanManageOrg(org.Role) {
+		return apierror.Forbidden("Only owners and admins can add members")
+	}
+
+	var req models.AddOrgMemberRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+	if req.Role == models.OrgRoleOwner && org.Role != models.OrgRoleOwner {
+		return apierror.Forbidden("Only owners can add owners")
+	}
+
+	ctx := c.Request().Context()
+	user, err := h.userRepo.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(req.Email)))
+	if err != nil {
+		return apierror.Internal("Failed to fetch user")
+	}
+	if user == nil {
+		return apierror.NotFound("User not found")
+	}
+
+	added, err := h.orgRepo.AddMember(ctx, org.ID, user.ID, req.Role)
+	if err != nil {
+		return apierror.Internal("Failed to add member")
+	}
+	if !added {
+		return apierror.Conflict("User is already a member")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgMemberAdd, &userID, true, map[string]interface{}{
+		"org_id":  org.ID,
+		"user_id": user.ID,
+		"role":    req.Role,
+	})
+
+	return c.JSON(http.StatusCreated, models.OrgMember{
+		OrgID:     org.ID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		AvatarURL: user.AvatarURL,
+		Role:      req.Role,
+	})
+}
+
+// UpdateMember changes a member's role. Owners and admins may change the
+// roles of members and admins; only owners may make or unmake owners. The
+// last owner stays one.
+func (h *OrganizationHandler) UpdateMember(c echo.Context) error {
+	org, userID, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+	if !models.CanManageOrg(org.Role) {
+		return apierror.Forbidden("Only owners and admins can change roles")
+	}
+
+	targetID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		return apierror.BadRequest("Invalid user ID")
+	}
+
+	var req models.UpdateOrgMemberRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	ctx := c.Request().Context()
+	targetRole, err := h.orgRepo.GetRole(ctx, org.ID, targetID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch member")
+	}
+	if targetRole == "" {
+		return apierror.NotFound("Member not found")
+	}
+	if (targetRole == models.OrgRoleOwner || req.Role == models.OrgRoleOwner) && org.Role != models.OrgRoleOwner {
+		return apierror.Forbidden("Only owners can change owners")
+	}
+
+	updated, err := h.orgRepo.UpdateMemberRole(ctx, org.ID, targetID, req.Role)
+	if err != nil {
+		return apierror.Internal("Failed to update member")
+	}
+	if !updated {
+		return apierror.Conflict("The last owner can't be demoted")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgMemberRole, &userID, true, map[string]interface{}{
+		"org_id":   org.ID,
+		"user_id":  targetID,
+		"role":     req.Role,
+		"previous": targetRole,
+	})
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Member updated",
+	})
+}
+
+// RemoveMember removes a member from an organization, ending their access to
+// its conversations. Owners and admins may remove members and admins, only
+// owners may remove owners, and anyone may leave. The last owner can't.
+func (h *OrganizationHandler) RemoveMember(c echo.Context) error {
+	org, userID, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+
+	targetID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		return apierror.BadRequest("Invalid user ID")
+	}
+
+	ctx := c.Request().Context()
+	if targetID != userID {
+		if !models.CanManageOrg(org.Role) {
+			return apierror.Forbidden("Only owners and admins can remove members")
+		}
+
+		targetRole, err := h.orgRepo.GetRole(ctx, org.ID, targetID)
+		if err != nil {
+			return apierror.Internal("Failed to fetch member")
+		}
+		if targetRole == "" {
+			return apierror.NotFound("Member not found")
+		}
+		if targetRole == models.OrgRoleOwner && org.Role != models.OrgRoleOwner {
+			return apierror.Forbidden("Only owners can remove owners")
+		}
+	}
+
+	removed, err := h.orgRepo.RemoveMember(ctx, org.ID, targetID)
+	if err != nil {
+		return apierror.Internal("Failed to remove member")
+	}
+	if !removed {
+		return apierror.Conflict("The last owner can't leave the organization")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgMemberRemove, &userID, true, map[string]interface{}{
+		"org_id":  org.ID,
+		"user_id": targetID,
+	})
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Member removed",
+	})
+}
+
+// GetUsage returns the organization's usage this month against its quota.
+// Owners and admins only.
+func (h *OrganizationHandler) GetUsage(c echo.Context) error {
+	org, _, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+	if !models.CanManageOrg(org.Role) {
+		return apierror.Forbidden("Only owners and admins can view usage")
+	}
+
+	usage, err := h.usage.OrgUsage(c.Request().Context(), org)
+	if err != nil {
+		return apierror.Internal("Failed to fetch usage")
+	}
+
+	return c.JSON(http.StatusOK, usage)
+}
+
+// SetQuota sets an organization's monthly token quota. For site admins:
+// organizations don't set their own.
+func (h *OrganizationHandler) SetQuota(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid organization ID")
+	}
+
+	var req models.SetOrgQuotaRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	found, err := h.orgRepo.SetQuota(c.Request().Context(), orgID, req.MonthlyTokenQuota)
+	if err != nil {
+		return apierror.Internal("Failed to set quota")
+	}
+	if !found {
+		return apierror.NotFound("Organization not found")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgQuota, &userClaims.UserID, true, map[string]interface{}{
+		"org_id":              orgID,
+		"monthly_token_quota": req.MonthlyTokenQuota,
+	})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"org_id":              orgID,
+		"monthly_token_quota": req.MonthlyTokenQuota,
+	})
+}
+
+// SetRetention overrides how long an organization's conversations are kept.
+// For site admins, like quotas.
+func (h *OrganizationHandler) SetRetention(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid organization ID")
+	}
+
+	var req models.SetOrgRetentionRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	found, err := h.orgRepo.SetRetention(c.Request().Context(), orgID, req.ConversationRetentionDays)
+	if err != nil {
+		return apierror.Internal("Failed to set retention")
+	}
+	if !found {
+		return apierror.NotFound("Organization not found")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgRetention, &userClaims.UserID, true, map[string]interface{}{
+		"org_id":                      orgID,
+		"conversation_retention_days": req.ConversationRetentionDays,
+	})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"org_id":                      orgID,
+		"conversation_retention_days": req.ConversationRetentionDays,
+	})
+}
+
+// inviteURL is the page where an invitation is accepted
+func (h *OrganizationHandler) inviteURL(inv *models.OrgInvitation) string {
+	return h.frontendURL + "/invite/" + h.inviteSigner.Token(inv.ID)
+}
+
+// CreateInvitation invites an email to join an organization and emails it
+// the link to accept. Owners and admins may invite members and admins; only
+// owners may invite owners.
+func (h *OrganizationHandler) CreateInvitation(c echo.Context) error {
+	org, userID, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+	if !models.CanManageOrg(org.Role) {
+		return apierror.Forbidden("Only owners and admins can invite members")
+	}
+
+	var req models.CreateOrgInvitationRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+	if req.Role == models.OrgRoleOwner && org.Role != models.OrgRoleOwner {
+		return apierror.Forbidden("Only owners can invite owners")
+	}
+
+	ctx := c.Request().Context()
+	user, err := h.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		return apierror.Internal("Failed to fetch user")
+	}
+	if user != nil {
+		role, err := h.orgRepo.GetRole(ctx, org.ID, user.ID)
+		if err != nil {
+			return apierror.Internal("Failed to check organization membership")
+		}
+		if role != "" {
+			return apierror.Conflict("User is already a member")
+		}
+	}
+
+	inv := &models.OrgInvitation{
+		OrgID:     org.ID,
+		Email:     req.Email,
+		Role:      req.Role,
+		InvitedBy: &userID,
+		ExpiresAt: time.Now().Add(h.inviteTTL),
+	}
+	created, err := h.orgRepo.CreateInvitation(ctx, inv)
+	if err != nil {
+		return apierror.Internal("Failed to create invitation")
+	}
+	if !created {
+		return apierror.Conflict("Email already has a pending invitation")
+	}
+	inv.OrgName = org.Name
+
+	h.sendInvitation(ctx, inv)
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgInvite, &userID, true, map[string]interface{}{
+		"org_id":        org.ID,
+		"invitation_id": inv.ID,
+		"role":          inv.Role,
+	})
+
+	return c.JSON(http.StatusCreated, inv)
+}
+
+// sendInvitation emails the link to accept an invitation. A failed email
+// is logged; the invitation stays pending and can be revoked and sent again.
+func (h *OrganizationHandler) sendInvitation(ctx context.Context, inv *models.OrgInvitation) {
+	text := fmt.Sprintf("You have been invited to join %s as %s.\n\n"+
+		"Accept the invitation here:\n%s\n\n"+
+		"The link expires on %s. If you weren't expecting it, ignore this email.",
+		inv.OrgName, inv.Role, h.inviteURL(inv), inv.ExpiresAt.UTC().Format(time.RFC1123))
+
+	err := h.mailer.Send(ctx, mail.Message{
+		To:      inv.Email,
+		Subject: "You're invited to join " + inv.OrgName,
+		Text:    text,
+	})
+	if err != nil {
+		logger.ModuleContext(ctx, "org").Error().Err(err).Str("invitation_id", inv.ID.String()).Msg("Failed to email invitation")
+	}
+}
+
+// ListInvitations returns an organization's pending invitations. Owners and
+// admins only.
+func (h *OrganizationHandler) ListInvitations(c echo.Context) error {
+	org, _, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+	if !models.CanManageOrg(org.Role) {
+		return apierror.Forbidden("Only owners and admins can view invitations")
+	}
+
+	invitations, err := h.orgRepo.ListPendingInvitations(c.Request().Context(), org.ID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch invitations")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"invitations": invitations,
+	})
+}
+
+// RevokeInvitation withdraws a pending invitation. Owners and admins only.
+func (h *OrganizationHandler) RevokeInvitation(c echo.Context) error {
+	org, userID, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+	if !models.CanManageOrg(org.Role) {
+		return apierror.Forbidden("Only owners and admins can revoke invitations")
+	}
+
+	invitationID, err := uuid.Parse(c.Param("invitationId"))
+	if err != nil {
+		return apierror.BadRequest("Invalid invitation ID")
+	}
+
+	revoked, err := h.orgRepo.RevokeInvitation(c.Request().Context(), org.ID, invitationID)
+	if err != nil {
+		return apierror.Internal("Failed to revoke invitation")
+	}
+	if !revoked {
+		return apierror.NotFound("Invitation not found")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgInviteRevoke, &userID, true, map[string]interface{}{
+		"org_id":        org.ID,
+		"invitation_id": invitationID,
+	})
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Invitation revoked",
+	})
+}
+
+// pendingInvitation fetches the invitation behind the :token path param.
+// Forged, accepted, revoked and expired invitations all look missing. On
+// failure it returns a nil invitation and the API error.
+func (h *OrganizationHandler) pendingInvitation(c echo.Context) (*models.OrgInvitation, error) {
+	invitationID, err := h.inviteSigner.Parse(c.Param("token"))
+	if err != nil {
+		return nil, apierror.NotFound("Invitation not found")
+	}
+
+	inv, err := h.orgRepo.GetInvitation(c.Request().Context(), invitationID)
+	if err != nil {
+		return nil, apierror.Internal("Failed to fetch invitation")
+	}
+	if inv == nil || !inv.IsPending() {
+		return nil, apierror.NotFound("Invitation not found")
+	}
+
+	return inv, nil
+}
+
+// GetInvitation describes the invitation behind a token for the acceptance
+// page, including whether the invited email has an account yet. No
+// authentication is required.
+func (h *OrganizationHandler) GetInvitation(c echo.Context) error {
+	inv, err := h.pendingInvitation(c)
+	if inv == nil {
+		return err
+	}
+
+	user, err := h.userRepo.GetByEmail(c.Request().Context(), inv.Email)
+	if err != nil {
+		return apierror.Internal("Failed to fetch user")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"org_id":         inv.OrgID,
+		"org_name":       inv.OrgName,
+		"email":          inv.Email,
+		"role":           inv.Role,
+		"expires_at":     inv.ExpiresAt,
+		"account_exists": user != nil,
+	})
+}
+
+// errInvitationTaken is returned inside the acceptance transaction when
+// the invitation stopped being pending after it was read
+var errInvitationTaken = errors.New("invitation no longer pending")
+
+// AcceptInvitation adds the invited email's account to the organization.
+// Without an account, one is created with the name and password given; the
+// token proves the email. The user signs in afterwards. No authentication
+// is required.
+func (h *OrganizationHandler) AcceptInvitation(c echo.Context) error {
+	inv, err := h.pendingInvitation(c)
+	if inv == nil {
+		return err
+	}
+
+	var req models.AcceptOrgInvitationRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	ctx := c.Request().Context()
+	user, err := h.userRepo.GetByEmail(ctx, inv.Email)
+	if err != nil {
+		return apierror.Internal("Failed to fetch user")
+	}
+
+	accountCreated := user == nil
+	if accountCreated {
+		if req.Name == "" || req.Password == "" {
+			return apierror.BadRequest("Name and password are required to create an account")
+		}
+		hashedPassword, err := h.authSvc.HashPassword(req.Password)
+		if err != nil {
+			return apierror.Internal("Failed to process password")
+		}
+		user = &models.User{
+			Username:     req.Name,
+			Email:        inv.Email,
+			PasswordHash: &hashedPassword,
+		}
+	}
+
+	err = h.tx.WithTx(ctx, func(ctx context.Context) error {
+		if accountCreated {
+			if err := h.userRepo.Create(ctx, user); err != nil {
+				return err
+			}
+		}
+		accepted, err := h.orgRepo.AcceptInvitation(ctx, inv.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		if !accepted {
+			return errInvitationTaken
+		}
+		// An existing member keeps their role
+		_, err = h.orgRepo.AddMember(ctx, inv.OrgID, user.ID, inv.Role)
+		return err
+	})
+	if errors.Is(err, errInvitationTaken) {
+		return apierror.NotFound("Invitation not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to accept invitation")
+	}
+
+	if accountCreated {
+		h.auditor.RecordRequest(c, models.AuditActionRegister, &user.ID, true, map[string]interface{}{
+			"invitation_id": inv.ID,
+		})
+	}
+	h.auditor.RecordRequest(c, models.AuditActionOrgInviteAccept, &user.ID, true, map[string]interface{}{
+		"org_id":        inv.OrgID,
+		"invitation_id": inv.ID,
+		"role":          inv.Role,
+	})
+
+	status := http.StatusOK
+	if accountCreated {
+		status = http.StatusCreated
+	}
+	return c.JSON(status, map[string]interface{}{
+		"org_id":          inv.OrgID,
+		"org_name":        inv.OrgName,
+		"user_id":         user.ID,
+		"role":            inv.Role,
+		"account_created": accountCreated,
+	})
+}
+
+// ListCredentials returns the provider API keys an organization stored,
+// without the keys. Owners and admins only.
+func (h *OrganizationHandler) ListCredentials(c echo.Context) error {
+	org, _, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+	if !models.CanManageOrg(org.Role) {
+		return apierror.Forbidden("Only owners and admins can view provider credentials")
+	}
+
+	creds, err := h.credentials.List(c.Request().Context(), org.ID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch provider credentials")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"credentials": creds,
+	})
+}
+
+// SetCredential stores the organization's own API key for a provider, used
+// for its conversations from then on. Owners and admins only.
+func (h *OrganizationHandler) SetCredential(c echo.Context) error {
+	org, userID, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+	if !models.CanManageOrg(org.Role) {
+		return apierror.Forbidden("Only owners and admins can change provider credentials")
+	}
+
+	var req models.SetProviderCredentialRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+	req.APIKey = strings.TrimSpace(req.APIKey)
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	creds := ai.Credentials{APIKey: req.APIKey}
+	if req.BaseURL != nil {
+		creds.BaseURL = *req.BaseURL
+	}
+	if req.Model != nil {
+		creds.Model = *req.Model
+	}
+
+	provider := providers.ProviderType(c.Param("provider"))
+	cred, err := h.credentials.Set(c.Request().Context(), org.ID, provider, creds, userID)
+	if errors.Is(err, providers.ErrUnsupportedProvider) {
+		return apierror.BadRequest("Provider does not support organization credentials")
+	}
+	if errors.Is(err, providers.ErrBaseURLNotAllowed) {
+		return apierror.BadRequest("base_url must be an https URL on an allowed host")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to store provider credentials")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgCredentialSet, &userID, true, map[string]interface{}{
+		"org_id":   org.ID,
+		"provider": provider,
+		"key_hint": cred.KeyHint,
+	})
+
+	return c.JSON(http.StatusOK, cred)
+}
+
+// DeleteCredential removes the organization's API key for a provider; its
+// conversations go back to the configured providers. Owners and admins
+// only.
+func (h *OrganizationHandler) DeleteCredential(c echo.Context) error {
+	org, userID, err := h.loadOrganization(c)
+	if org == nil {
+		return err
+	}
+	if !models.CanManageOrg(org.Role) {
+		return apierror.Forbidden("Only owners and admins can change provider credentials")
+	}
+
+	provider := providers.ProviderType(c.Param("provider"))
+	deleted, err := h.credentials.Delete(c.Request().Context(), org.ID, provider)
+	if err != nil {
+		return apierror.Internal("Failed to delete provider credentials")
+	}
+	if !deleted {
+		return apierror.NotFound("Provider credentials not found")
+	}
+
+	h.auditor.RecordRequest(c, models.AuditActionOrgCredentialDelete, &userID, true, map[string]interface{}{
+		"org_id":   org.ID,
+		"provider": provider,
+	})
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Provider credentials deleted",
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/outbox"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// partialSaveInterval is how often a reply being streamed is saved, so a
+// crash loses at most this much of it
+const partialSaveInterval = 2 * time.Second
+
+// partialReply saves a streamed reply while it is generated. The message
+// is created with the first save and marked partial until finish; an
+// interrupted reply keeps what was generated. Final saves that fail are
+// queued in the outbox and retried.
+type partialReply struct {
+	convRepo *repository.ConversationRepository
+	outbox   *outbox.Outbox
+	request  *ai.ChatRequest
+	message  *models.Message
+	content  strings.Builder
+	saved    int
+	lastSave time.Time
+	start    time.Time
+
+	// provider and model are the last ones selected, for the metadata of
+	// a partial reply
+	provider string
+	model    string
+}
+
+func newPartialReply(convRepo *repository.ConversationRepository, messageOutbox *outbox.Outbox, conversationID uuid.UUID, request *ai.ChatRequest) *partialReply {
+	now := time.Now()
+	return &partialReply{
+		convRepo: convRepo,
+		outbox:   messageOutbox,
+		request:  request,
+		message: &models.Message{
+			ConversationID: conversationID,
+			SenderID:       uuid.Nil, // System/AI doesn't have a user ID
+			SenderType:     models.SenderTypeAgent,
+		},
+		lastSave: now,
+		start:    now,
+	}
+}
+
+// selected records the model generating the reply
+func (p *partialReply) selected(provider, model string) {
+	p.provider, p.model = provider, model
+}
+
+// add appends a chunk and saves the reply when it is due
+func (p *partialReply) add(ctx context.Context, chunk string) {
+	p.content.WriteString(chunk)
+	if time.Since(p.lastSave) >= partialSaveInterval {
+		if err := p.save(ctx, ""); err != nil {
+			logger.ModuleContext(ctx, "chat").Warn().Err(err).Str("conversation_id", p.message.ConversationID.String()).Msg("Failed to save partial reply")
+		}
+	}
+}
+
+// interrupt saves what was generated, marked with why the reply stopped.
+// It reports whether the reply was saved; one that failed to save is
+// retried.
+func (p *partialReply) interrupt(ctx context.Context, reason string) bool {
+	if p.content.Len() == 0 {
+		return false
+	}
+	if err := p.save(ctx, reason); err != nil {
+		p.outbox.SaveMessage(ctx, p.message, err)
+		return false
+	}
+	return true
+}
+
+// finish saves the complete reply. A reply that failed to save is retried.
+func (p *partialReply) finish(ctx context.Context, response *ai.ChatResponse) error {
+	p.message.Content = response.Content
+	p.message.Metadata = response.Metadata(p.request).JSON()
+	if err := p.store(ctx); err != nil {
+		p.outbox.SaveMessage(ctx, p.message, err)
+		return err
+	}
+	return nil
+}
+
+func (p *partialReply) save(ctx context.Context, interruption string) error {
+	if p.content.Len() == p.saved && interruption == "" {
+		return nil
+	}
+
+	response := &ai.ChatResponse{Provider: p.provider, Model: p.model, Latency: time.Since(p.start)}
+	metadata := response.Metadata(p.request)
+	metadata.Partial = true
+	metadata.Interruption = interruption
+
+	p.message.Content = p.content.String()
+	p.message.Metadata = metadata.JSON()
+	p.saved = p.content.Len()
+	p.lastSave = time.Now()
+	return p.store(ctx)
+}
+
+func (p *partialReply) store(ctx context.Context) error {
+	if p.message.ID == 0 {
+		return p.convRepo.CreateMessage(ctx, p.message)
+	}
+	return p.convRepo.UpdateMessageContent(ctx, p.message)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type ParticipantHandler struct {
+	participants *repository.ParticipantRepository
+	convRepo     *repository.ConversationRepository
+	userRepo     *repository.UserRepository
+	orgRepo      *repository.OrganizationRepository
+	authSvc      *auth.Service
+}
+
+func NewParticipantHandler(participants *repository.ParticipantRepository, convRepo *repository.ConversationRepository, userRepo *repository.UserRepository, orgRepo *repository.OrganizationRepository, authSvc *auth.Service) *ParticipantHandler {
+	return &ParticipantHandler{
+		participants: participants,
+		convRepo:     convRepo,
+		userRepo:     userRepo,
+		orgRepo:      orgRepo,
+		authSvc:      authSvc,
+	}
+}
+
+// conversationRole returns the user's role in a conversation, or an empty
+// string if they have no access. The creator of a personal conversation is
+// always its owner, even without a participant row; in an organization's
+// conversation they must still be a member of the organization.
+func conversationRole(ctx context.Context, participants *repository.ParticipantRepository, conversation *models.Conversation, userID uuid.UUID) (string, error) {
+	if conversation.UserID == userID && conversation.OrgID == nil {
+		return models.ParticipantRoleOwner, nil
+	}
+	return participants.GetRole(ctx, conversation.ID, userID)
+}
+
+// loadConversation fetches the conversation in the :id path param along with
+// the current user's role in it. On failure it returns a nil conversation
+// and the API error.
+func (h *ParticipantHandler) loadConversation(c echo.Context) (*models.Conversation, uuid.UUID, string, error) {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return nil, uuid.Nil, "", apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, uuid.Nil, "", apierror.BadRequest("Invalid conversation ID")
+	}
+
+	ctx := c.Request().Context()
+	conversation, err := h.convRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, uuid.Nil, "", apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return nil, uuid.Nil, "", apierror.NotFound("Conversation not found")
+	}
+
+	role, err := conversationRole(ctx, h.participants, conversation, userClaims.UserID)
+	if err != nil {
+		return nil, uuid.Nil, "", apierror.Internal("Failed to check conversation access")
+	}
+	if !models.CanRead(role) {
+		return nil, uuid.Nil, "", apierror.Forbidden("Access denied")
+	}
+
+	return conversation, userClaims.UserID, role, nil
+}
+
+// ListParticipants returns everyone with access to a conversation
+func (h *ParticipantHandler) ListParticipants(c echo.Context) error {
+	conversation, _, _, err := h.loadConversation(c)
+	if conversation == nil {
+		return err
+	}
+
+	participants, err := h.participants.ListByConversation(c.Request().Context(), conversation.ID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch participants")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"participants": participants,
+	})
+}
+
+// InviteParticipant gives another user access to a conversation. Inviting an
+// existing participant changes their role. Only the owner can invite.
+func (h *ParticipantHandler) InviteParticipant(c echo.Context) error {
+	conversation, userID, role, err := h.loadConversation(c)
+	if conversation == nil {
+		return err
+	}
+	if role != models.ParticipantRoleOwner {
+		return apierror.Forbidden("Only the owner can invite participants")
+	}
+
+	var req models.InviteParticipantRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	ctx := c.Request().Context()
+	invitee, err := h.userRepo.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(req.Email)))
+	if err != nil {
+		return apierror.Internal("Failed to fetch user")
+	}
+	if invitee == nil {
+		return apierror.NotFound("User not found")
+	}
+	if invitee.ID == conversation.UserID {
+		return apierror.BadRequest("User already owns this conversation")
+	}
+
+	// An organization's conversations are only shared within it
+	if conversation.OrgID != nil {
+		orgRole, err := h.orgRepo.GetRole(ctx, *conversation.OrgID, invitee.ID)
+		if err != nil {
+			return apierror.Internal("Failed to check organization membership")
+		}
+		if orgRole == "" {
+			return apierror.BadRequest("User is not a member of the conversation's organization")
+		}
+	}
+
+	if err := h.participants.Add(ctx, conversation.ID, invitee.ID, req.Role, &userID); err != nil {
+		return apierror.Internal("Failed to add participant")
+	}
+
+	return c.JSON(http.StatusCreated, models.Participant{
+		ConversationID: conversation.ID,
+		UserID:         invitee.ID,
+		Username:       invitee.Username,
+		AvatarURL:      invitee.AvatarURL,
+		Role:           req.Role,
+		InvitedBy:      &userID,
+	})
+}
+
+// RemoveParticipant revokes a user's access. The owner can remove anyone
+// else; other participants can only remove themselves.
+func (h *ParticipantHandler) RemoveParticipant(c echo.Context) error {
+	conversation, userID, role, err := h.loadConversation(c)
+	if conversation == nil {
+		return err
+	}
+
+	targetID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		return apierror.BadRequest("Invalid user ID")
+	}
+
+	if targetID == conversation.UserID {
+		return apierror.BadRequest("The owner can't be removed")
+	}
+	if role != models.ParticipantRoleOwner && targetID != userID {
+		return apierror.Forbidden("Access denied")
+	}
+
+	removed, err := h.participants.Remove(c.Request().Context(), conversation.ID, targetID)
+	if err != nil {
+		return apierror.Internal("Failed to remove participant")
+	}
+	if !removed {
+		return apierror.NotFound("Participant not found")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Participant removed",
+	})
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/retention"
+
+	"github.com/labstack/echo/v4"
+)
+
+type RetentionHandler struct {
+	enforcer *retention.Enforcer
+}
+
+func NewRetentionHandler(enforcer *retention.Enforcer) *RetentionHandler {
+	return &RetentionHandler{enforcer: enforcer}
+}
+
+// GetReport lists what the retention jobs would delete if they ran now,
+// per organization
+func (h *RetentionHandler) GetReport(c echo.Context) error {
+	report, err := h.enforcer.Report(c.Request().Context())
+	if err != nil {
+		return apierror.Internal("Failed to compute retention report")
+	}
+	return c.JSON(http.StatusOK, report)
+}
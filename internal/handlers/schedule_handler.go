@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/guardrails"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/reminders"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/scheduler"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type ScheduleHandler struct {
+	scheduleRepo *repository.ScheduleRepository
+	convRepo     *repository.ConversationRepository
+	participants *repository.ParticipantRepository
+	authSvc      *auth.Service
+	guard        *guardrails.Guard
+	requireHTTPS bool
+}
+
+func NewScheduleHandler(scheduleRepo *repository.ScheduleRepository, convRepo *repository.ConversationRepository, participants *repository.ParticipantRepository, authSvc *auth.Service, guard *guardrails.Guard, requireHTTPS bool) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleRepo: scheduleRepo,
+		convRepo:     convRepo,
+		participants: participants,
+		authSvc:      authSvc,
+		guard:        guard,
+		requireHTTPS: requireHTTPS,
+	}
+}
+
+// loadConversation fetches the conversation in the :id path param along with
+// the current user's ID and role in it. On failure it returns a nil
+// conversation and the API error.
+func (h *ScheduleHandler) loadConversation(c echo.Context) (*models.Conversation, uuid.UUID, string, error) {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return nil, uuid.Nil, "", apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, uuid.Nil, "", apierror.BadRequest("Invalid conversation ID")
+	}
+
+	ctx := c.Request().Context()
+	conversation, err := h.convRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, uuid.Nil, "", apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return nil, uuid.Nil, "", apierror.NotFound("Conversation not found")
+	}
+
+	role, err := conversationRole(ctx, h.participants, conversation, userClaims.UserID)
+	if err != nil {
+		return nil, uuid.Nil, "", apierror.Internal("Failed to check conversation access")
+	}
+
+	return conversation, userClaims.UserID, role, nil
+}
+
+// CreateSchedule schedules a prompt to be sent to the conversation later,
+// once at run_at or repeatedly on a cron schedule
+func (h *ScheduleHandler) CreateSchedule(c echo.Context) error {
+	conversation, userID, role, err := h.loadConversation(c)
+	if conversation == nil {
+		return err
+	}
+	if !models.CanWrite(role) {
+		return apierror.Forbidden("Access denied")
+	}
+
+	var req models.CreateScheduledPromptRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	req.Prompt = strings.TrimSpace(req.Prompt)
+	req.Cron = strings.TrimSpace(req.Cron)
+	if req.Prompt == "" {
+		return apierror.BadRequest("Prompt is required")
+	}
+	check := h.guard.Check(c.Request().Context(), guardrails.TargetMessage, req.Prompt)
+	if check.Blocked {
+		return apierror.Unprocessable("Prompt was blocked by content guardrails").WithDetails(map[string]string{"rule": check.Rule})
+	}
+	req.Prompt = check.Text
+	if (req.Cron == "") == (req.RunAt == nil) {
+		return apierror.BadRequest("Exactly one of cron and run_at is required")
+	}
+
+	prompt := &models.ScheduledPrompt{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+		Prompt:         req.Prompt,
+		Timezone:       "UTC",
+	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return apierror.BadRequest("Unknown timezone")
+		}
+		prompt.Timezone = req.Timezone
+	}
+	if req.Cron != "" {
+		if _, err := scheduler.ParseCron(req.Cron); err != nil {
+			return apierror.BadRequest("Invalid cron expression: " + err.Error())
+		}
+		prompt.Cron = &req.Cron
+	}
+	if req.RunAt != nil {
+		if !req.RunAt.After(time.Now()) {
+			return apierror.BadRequest("run_at must be in the future")
+		}
+		runAt := req.RunAt.UTC()
+		prompt.RunAt = &runAt
+	}
+	if req.WebhookURL != "" {
+		switch err := reminders.CheckWebhookURL(req.WebhookURL, h.requireHTTPS); {
+		case errors.Is(err, reminders.ErrWebhookHTTPS):
+			return apierror.BadRequest("Webhook URL must be an https URL")
+		case errors.Is(err, reminders.ErrWebhookAddress):
+			return apierror.BadRequest("Webhook URL must not point to a private address")
+		case err != nil:
+			return apierror.BadRequest("Webhook URL must be an http or https URL")
+		}
+		prompt.WebhookURL = &req.WebhookURL
+	}
+
+	next, err := reminders.NextRun(prompt, time.Now())
+	if err != nil {
+		return apierror.BadRequest(err.Error())
+	}
+	if next == nil {
+		return apierror.BadRequest("Schedule never runs")
+	}
+	prompt.NextRunAt = next
+
+	if err := h.scheduleRepo.Create(c.Request().Context(), prompt); err != nil {
+		return apierror.Internal("Failed to create schedule")
+	}
+
+	return c.JSON(http.StatusCreated, prompt)
+}
+
+// ListSchedules returns the conversation's scheduled prompts
+func (h *ScheduleHandler) ListSchedules(c echo.Context) error {
+	conversation, _, role, err := h.loadConversation(c)
+	if conversation == nil {
+		return err
+	}
+	if !models.CanRead(role) {
+		return apierror.Forbidden("Access denied")
+	}
+
+	prompts, err := h.scheduleRepo.ListByConversation(c.Request().Context(), conversation.ID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch schedules")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"schedules": prompts,
+	})
+}
+
+// DeleteSchedule cancels a scheduled prompt. Its creator and the
+// conversation owner may cancel it.
+func (h *ScheduleHandler) DeleteSchedule(c echo.Context) error {
+	conversation, userID, role, err := h.loadConversation(c)
+	if conversation == nil {
+		return err
+	}
+
+	scheduleID, err := uuid.Parse(c.Param("scheduleId"))
+	if err != nil {
+		return apierror.BadRequest("Invalid schedule ID")
+	}
+
+	ctx := c.Request().Context()
+	prompt, err := h.scheduleRepo.GetByID(ctx, conversation.ID, scheduleID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch schedule")
+	}
+	if prompt == nil || !models.CanRead(role) {
+		return apierror.NotFound("Schedule not found")
+	}
+	if prompt.UserID != userID && role != models.ParticipantRoleOwner {
+		return apierror.Forbidden("Access denied")
+	}
+
+	if err := h.scheduleRepo.Delete(ctx, prompt.ID); err != nil {
+		return apierror.Internal("Failed to delete schedule")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Schedule deleted",
+	})
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/shivaluma/eino-agent/internal/ai/templates"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SettingsHandler struct {
+	settingsRepo  *repository.SettingsRepository
+	authSvc       *auth.Service
+	allowedModels []string
+}
+
+// NewSettingsHandler creates a settings handler. An empty allowedModels list
+// lets users pick any model name.
+func NewSettingsHandler(settingsRepo *repository.SettingsRepository, authSvc *auth.Service, allowedModels []string) *SettingsHandler {
+	return &SettingsHandler{
+		settingsRepo:  settingsRepo,
+		authSvc:       authSvc,
+		allowedModels: allowedModels,
+	}
+}
+
+// GetSettings returns the current user's AI settings
+func (h *SettingsHandler) GetSettings(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	settings, err := h.settingsRepo.GetByUserID(c.Request().Context(), userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch settings")
+	}
+	if settings == nil {
+		settings = &models.UserSettings{UserID: userClaims.UserID}
+	}
+
+	return c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings applies a partial update to the current user's AI settings
+func (h *SettingsHandler) UpdateSettings(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	var req models.UpdateUserSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	settings, err := h.settingsRepo.GetByUserID(c.Request().Context(), userClaims.UserID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch settings")
+	}
+	if settings == nil {
+		settings = &models.UserSettings{UserID: userClaims.UserID}
+	}
+
+	if req.Temperature != nil {
+		settings.Temperature = req.Temperature
+	}
+	if req.MaxTokens != nil {
+		settings.MaxTokens = req.MaxTokens
+	}
+
+	if req.Model != nil {
+		model := strings.TrimSpace(*req.Model)
+		if model != "" && len(h.allowedModels) > 0 && !slices.Contains(h.allowedModels, model) {
+			return apierror.BadRequest("Model is not allowed")
+		}
+		settings.Model = optionalString(model)
+	}
+
+	if req.Persona != nil {
+		persona := strings.TrimSpace(*req.Persona)
+		if persona != "" && !templates.IsSelectablePersona(persona) {
+			return apierror.BadRequest("Unknown persona")
+		}
+		settings.Persona = optionalString(persona)
+	}
+
+	if req.Language != nil {
+		language := ""
+		if strings.TrimSpace(*req.Language) != "" {
+			language = templates.NormalizeLanguage(*req.Language)
+			if language == "" {
+				return apierror.BadRequest("Unsupported language")
+			}
+		}
+		settings.Language = optionalString(language)
+	}
+
+	if err := h.settingsRepo.Upsert(c.Request().Context(), settings); err != nil {
+		return apierror.Internal("Failed to save settings")
+	}
+
+	return c.JSON(http.StatusOK, settings)
+}
+
+// optionalString maps an empty string to nil so it clears the stored value
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/share"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type ShareHandler struct {
+	shareRepo   *repository.ShareRepository
+	convRepo    *repository.ConversationRepository
+	authSvc     *auth.Service
+	signer      *share.Signer
+	frontendURL string
+}
+
+func NewShareHandler(shareRepo *repository.ShareRepository, convRepo *repository.ConversationRepository, authSvc *auth.Service, signer *share.Signer, frontendURL string) *ShareHandler {
+	return &ShareHandler{
+		shareRepo:   shareRepo,
+		convRepo:    convRepo,
+		authSvc:     authSvc,
+		signer:      signer,
+		frontendURL: strings.TrimSuffix(frontendURL, "/"),
+	}
+}
+
+// shareURL builds the public frontend URL for a share link
+func (h *ShareHandler) shareURL(link *models.SharedLink) string {
+	return h.frontendURL + "/share/" + h.signer.Token(link.ID)
+}
+
+// ownedConversation loads the conversation in the :id path param and checks
+// that the current user owns it, which for an organization conversation
+// needs them to still be a member. On failure it returns nil and the API
+// error.
+func (h *ShareHandler) ownedConversation(c echo.Context) (*models.Conversation, error) {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return nil, apierror.Unauthorized("Unauthorized")
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, apierror.BadRequest("Invalid conversation ID")
+	}
+
+	conversation, role, err := h.convRepo.GetByIDForUser(c.Request().Context(), conversationID, userClaims.UserID)
+	if err != nil {
+		return nil, apierror.Internal("Failed to fetch conversation")
+	}
+	if conversation == nil {
+		return nil, apierror.NotFound("Conversation not found")
+	}
+
+	if role != models.ParticipantRoleOwner {
+		return nil, apierror.Forbidden("Access denied")
+	}
+
+	return conversation, nil
+}
+
+// CreateShare creates a public link to a snapshot of the conversation's
+// current messages
+func (h *ShareHandler) CreateShare(c echo.Context) error {
+	conversation, err := h.ownedConversation(c)
+	if conversation == nil {
+		return err
+	}
+
+	var req models.CreateShareRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.BadRequest("Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return apierror.Validation(err)
+	}
+
+	link := &models.SharedLink{
+		ConversationID: conversation.ID,
+		UserID:         conversation.UserID,
+	}
+	if req.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		link.ExpiresAt = &expiresAt
+	}
+
+	if err := h.shareRepo.Create(c.Request().Context(), link); err != nil {
+		return apierror.Internal("Failed to create share link")
+	}
+	link.URL = h.shareURL(link)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"link":  link,
+		"token": h.signer.Token(link.ID),
+	})
+}
+
+// ListShares returns all share links of a conversation, including revoked
+// and expired ones
+func (h *ShareHandler) ListShares(c echo.Context) error {
+	conversation, err := h.ownedConversation(c)
+	if conversation == nil {
+		return err
+	}
+
+	links, err := h.shareRepo.ListByConversation(c.Request().Context(), conversation.ID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch share links")
+	}
+
+	for i := range links {
+		links[i].URL = h.shareURL(&links[i])
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"links": links,
+	})
+}
+
+// RevokeShare disables a share link
+func (h *ShareHandler) RevokeShare(c echo.Context) error {
+	conversation, err := h.ownedConversation(c)
+	if conversation == nil {
+		return err
+	}
+
+	linkID, err := uuid.Parse(c.Param("shareId"))
+	if err != nil {
+		return apierror.BadRequest("Invalid share link ID")
+	}
+
+	link, err := h.shareRepo.GetByID(c.Request().Context(), linkID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch share link")
+	}
+	if link == nil || link.ConversationID != conversation.ID {
+		return apierror.NotFound("Share link not found")
+	}
+
+	if err := h.shareRepo.Revoke(c.Request().Context(), link.ID); err != nil {
+		return apierror.Internal("Failed to revoke share link")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Share link revoked",
+	})
+}
+
+// sharedConversation loads the active share link behind a token and its
+// conversation. Forged, expired and revoked tokens look the same as missing
+// ones. On failure it returns nil and the API error.
+func (h *ShareHandler) sharedConversation(ctx context.Context, token string) (*models.SharedLink, *models.Conversation, error) {
+	notFound := apierror.NotFound("Shared conversation not found")
+	linkID, err := h.signer.Parse(token)
+	if err != nil {
+		return nil, nil, notFound
+	}
+
+	link, err := h.shareRepo.GetByID(ctx, linkID)
+	if err != nil {
+		return nil, nil, apierror.Internal("Failed to fetch shared conversation")
+	}
+	if link == nil || !link.IsActive() {
+		return nil, nil, notFound
+	}
+
+	conversation, err := h.convRepo.GetByID(ctx, link.ConversationID)
+	if err != nil || conversation == nil {
+		return nil, nil, notFound
+	}
+	return link, conversation, nil
+}
+
+// GetSharedConversation serves the read-only snapshot behind a share token.
+// No authentication is required.
+func (h *ShareHandler) GetSharedConversation(c echo.Context) error {
+	ctx := c.Request().Context()
+	link, conversation, err := h.sharedConversation(ctx, c.Param("token"))
+	if link == nil {
+		return err
+	}
+
+	messages, err := h.shareRepo.GetSnapshotMessages(ctx, link)
+	if err != nil {
+		return apierror.Internal("Failed to fetch shared conversation")
+	}
+
+	return c.JSON(http.StatusOK, models.SharedConversation{
+		Title:     conversation.Title,
+		SharedAt:  link.CreatedAt,
+		ExpiresAt: link.ExpiresAt,
+		Messages:  messages,
+	})
+}
+
+// GetEmbed serves the messages of a share link selected by the theme, from
+// and to query parameters, for the frontend's embed page and for sites
+// embedding the conversation themselves. No authentication is required.
+func (h *ShareHandler) GetEmbed(c echo.Context) error {
+	opts, err := share.ParseEmbedOptions(c.QueryParams())
+	if err != nil {
+		return apierror.BadRequest("Invalid embed options")
+	}
+
+	ctx := c.Request().Context()
+	link, conversation, err := h.sharedConversation(ctx, c.Param("token"))
+	if link == nil {
+		return err
+	}
+
+	messages, err := h.shareRepo.GetSnapshotMessages(ctx, link)
+	if err != nil {
+		return apierror.Internal("Failed to fetch shared conversation")
+	}
+	start, end := opts.Range(len(messages))
+
+	return c.JSON(http.StatusOK, models.SharedEmbed{
+		Title:    conversation.Title,
+		URL:      h.shareURL(link),
+		Theme:    opts.Theme,
+		SharedAt: link.CreatedAt,
+		From:     start + 1,
+		To:       end,
+		Total:    len(messages),
+		Messages: messages[start:end],
+	})
+}
+
+// Default size of the embed iframe, shrunk to the consumer's maxwidth and
+// maxheight
+const (
+	embedWidth  = 600
+	embedHeight = 400
+)
+
+// GetOEmbed answers oEmbed requests for share links with an iframe of the
+// frontend's embed page. The theme, from and to query parameters of the
+// shared URL carry over to the embed. No authentication is required.
+func (h *ShareHandler) GetOEmbed(c echo.Context) error {
+	if format := c.QueryParam("format"); format != "" && format != "json" {
+		return apierror.New(http.StatusNotImplemented, apierror.CodeBadRequest, "Only the json format is supported")
+	}
+
+	sharedURL, err := url.Parse(c.QueryParam("url"))
+	if err != nil {
+		return apierror.NotFound("Shared conversation not found")
+	}
+	token, ok := h.shareToken(sharedURL)
+	if !ok {
+		return apierror.NotFound("Shared conversation not found")
+	}
+	opts, err := share.ParseEmbedOptions(sharedURL.Query())
+	if err != nil {
+		return apierror.BadRequest("Invalid embed options")
+	}
+
+	link, conversation, err := h.sharedConversation(c.Request().Context(), token)
+	if link == nil {
+		return err
+	}
+
+	embedURL := h.frontendURL + "/embed/" + token
+	if query := opts.Query(); len(query) > 0 {
+		embedURL += "?" + query.Encode()
+	}
+	title := ""
+	if conversation.Title != nil {
+		title = *conversation.Title
+	}
+	width := embedSize(c.QueryParam("maxwidth"), embedWidth)
+	height := embedSize(c.QueryParam("maxheight"), embedHeight)
+
+	return c.JSON(http.StatusOK, models.OEmbed{
+		Version:     "1.0",
+		Type:        "rich",
+		Title:       title,
+		ProviderURL: h.frontendURL,
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" title="%s" style="border:0" loading="lazy"></iframe>`,
+			html.EscapeString(embedURL), width, height, html.EscapeString(title)),
+		Width:  width,
+		Height: height,
+	})
+}
+
+// shareToken returns the token of a share link URL built by shareURL
+func (h *ShareHandler) shareToken(u *url.URL) (string, bool) {
+	frontend, err := url.Parse(h.frontendURL)
+	if err != nil || !strings.EqualFold(u.Scheme, frontend.Scheme) || !strings.EqualFold(u.Host, frontend.Host) {
+		return "", false
+	}
+	token, ok := strings.CutPrefix(u.Path, frontend.Path+"/share/")
+	if !ok || token == "" || strings.Contains(token, "/") {
+		return "", false
+	}
+	return token, true
+}
+
+// embedSize returns the default size, or the consumer's maximum when it is
+// smaller
+func embedSize(maxStr string, size int) int {
+	if limit, err := strconv.Atoi(maxStr); err == nil && limit > 0 && limit < size {
+		return limit
+	}
+	return size
+}
@@ -0,0 +1,218 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/shivaluma/eino-agent/internal/middleware"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/share"
+	"github.com/shivaluma/eino-agent/internal/testutil"
+)
+
+func TestShare_EmbedAndOEmbed(t *testing.T) {
+	ctx := context.Background()
+	env := testutil.NewEnv(t)
+	env.Config.OAuth.FrontendURL = "http://frontend.test"
+	h := env.ShareHandler()
+
+	e := testutil.NewEcho()
+	e.GET("/share/:token/embed", h.GetEmbed)
+	e.GET("/oembed", h.GetOEmbed)
+	protected := e.Group("", middleware.AuthMiddleware(env.Auth))
+	protected.POST("/conversations/:id/share", h.CreateShare)
+	protected.DELETE("/conversations/:id/shares/:shareId", h.RevokeShare)
+
+	owner := env.CreateUser(t, "owner@example.com", "password123")
+	token, err := env.Auth.GenerateAccessToken(owner.ID, owner.Username)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	session := &http.Cookie{Name: "access_token", Value: token}
+
+	// More messages than an embed shows
+	title := "Street food tour"
+	conversation := &models.Conversation{UserID: owner.ID, Title: &title}
+	if err := env.Conversations.Create(ctx, conversation); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	total := share.MaxEmbedMessages + 5
+	for i := 1; i <= total; i++ {
+		message := &models.Message{ConversationID: conversation.ID, SenderID: owner.ID, SenderType: models.SenderTypeUser, Content: fmt.Sprintf("message %d", i)}
+		if err := env.Conversations.CreateMessage(ctx, message); err != nil {
+			t.Fatalf("CreateMessage: %v", err)
+		}
+	}
+
+	rec := testutil.Request(t, e, http.MethodPost, "/conversations/"+conversation.ID.String()+"/share", map[string]interface{}{}, session)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("share: status %d, body %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		Link  models.SharedLink `json:"link"`
+		Token string            `json:"token"`
+	}
+	testutil.DecodeJSON(t, rec, &created)
+
+	embed := func(query string) (int, models.SharedEmbed) {
+		t.Helper()
+		rec := testutil.Request(t, e, http.MethodGet, "/share/"+created.Token+"/embed"+query, nil)
+		var body models.SharedEmbed
+		if rec.Code == http.StatusOK {
+			testutil.DecodeJSON(t, rec, &body)
+		}
+		return rec.Code, body
+	}
+
+	for _, tc := range []struct {
+		query          string
+		from, to, size int
+	}{
+		// The whole snapshot is cut at MaxEmbedMessages
+		{"", 1, share.MaxEmbedMessages, share.MaxEmbedMessages},
+		{"?from=2&to=105", 2, share.MaxEmbedMessages + 1, share.MaxEmbedMessages},
+		// A range past the end is clamped to it
+		{"?from=100&to=500", 100, total, total - 99},
+		{"?from=3&to=4&theme=dark", 3, 4, 2},
+		// Starting past the end shows nothing
+		{"?from=500", total + 1, total, 0},
+	} {
+		code, body := embed(tc.query)
+		if code != http.StatusOK {
+			t.Fatalf("embed%s: status %d", tc.query, code)
+		}
+		if body.From != tc.from || body.To != tc.to || len(body.Messages) != tc.size || body.Total != total {
+			t.Fatalf("embed%s: from %d to %d, %d of %d messages, want from %d to %d, %d of %d",
+				tc.query, body.From, body.To, len(body.Messages), body.Total, tc.from, tc.to, tc.size, total)
+		}
+		if tc.size > 0 && body.Messages[0].Content != fmt.Sprintf("message %d", tc.from) {
+			t.Fatalf("embed%s: first message %q, want message %d", tc.query, body.Messages[0].Content, tc.from)
+		}
+	}
+
+	for _, query := range []string{"?theme=neon", "?from=0", "?from=5&to=2"} {
+		if code, _ := embed(query); code != http.StatusBadRequest {
+			t.Fatalf("embed%s: status %d, want %d", query, code, http.StatusBadRequest)
+		}
+	}
+
+	oembed := func(sharedURL string) *models.OEmbed {
+		t.Helper()
+		rec := testutil.Request(t, e, http.MethodGet, "/oembed?maxwidth=320&url="+url.QueryEscape(sharedURL), nil)
+		if rec.Code == http.StatusNotFound {
+			return nil
+		}
+		if rec.Code != http.StatusOK {
+			t.Fatalf("oembed %s: status %d, body %s", sharedURL, rec.Code, rec.Body)
+		}
+		var body models.OEmbed
+		testutil.DecodeJSON(t, rec, &body)
+		return &body
+	}
+
+	// The embed carries the shared URL's options over, at the consumer's
+	// maximum width
+	body := oembed("http://frontend.test/share/" + created.Token + "?theme=dark&from=2")
+	if body == nil {
+		t.Fatal("oembed of the share link: not found")
+	}
+	if !strings.Contains(body.HTML, `src="http://frontend.test/embed/`+created.Token+`?from=2&amp;theme=dark"`) || body.Width != 320 || body.Title != title {
+		t.Fatalf("oembed = %+v", body)
+	}
+
+	// Only share links of the frontend are embedded
+	for _, foreign := range []string{
+		"http://evil.test/share/" + created.Token,
+		"https://frontend.test/share/" + created.Token,
+		"http://frontend.test/other/" + created.Token,
+	} {
+		if body := oembed(foreign); body != nil {
+			t.Fatalf("oembed %s = %+v, want not found", foreign, body)
+		}
+	}
+
+	// A revoked link is gone from both
+	rec = testutil.Request(t, e, http.MethodDelete, "/conversations/"+conversation.ID.String()+"/shares/"+created.Link.ID.String(), nil, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revoke: status %d, body %s", rec.Code, rec.Body)
+	}
+	if code, _ := embed(""); code != http.StatusNotFound {
+		t.Fatalf("embed of a revoked link: status %d, want %d", code, http.StatusNotFound)
+	}
+	if body := oembed("http://frontend.test/share/" + created.Token); body != nil {
+		t.Fatalf("oembed of a revoked link = %+v, want not found", body)
+	}
+}
+
+func TestShare_LeftOrganization(t *testing.T) {
+	ctx := context.Background()
+	env := testutil.NewEnv(t)
+	h := env.ShareHandler()
+	orgs := repository.NewOrganizationRepository(env.DB)
+
+	e := testutil.NewEcho()
+	protected := e.Group("", middleware.AuthMiddleware(env.Auth))
+	protected.POST("/conversations/:id/share", h.CreateShare)
+	protected.GET("/conversations/:id/shares", h.ListShares)
+	protected.DELETE("/conversations/:id/shares/:shareId", h.RevokeShare)
+
+	admin := env.CreateUser(t, "admin@example.com", "password123")
+	member := env.CreateUser(t, "member@example.com", "password123")
+	token, err := env.Auth.GenerateAccessToken(member.ID, member.Username)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	session := &http.Cookie{Name: "access_token", Value: token}
+
+	org := &models.Organization{Name: "Acme", CreatedBy: &admin.ID}
+	if err := orgs.Create(ctx, org); err != nil {
+		t.Fatalf("Create organization: %v", err)
+	}
+	if _, err := orgs.AddMember(ctx, org.ID, member.ID, models.OrgRoleMember); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+
+	// The member starts a conversation in the organization and shares it
+	title := "Team lunch"
+	conversation := &models.Conversation{UserID: member.ID, Title: &title, OrgID: &org.ID}
+	if err := env.Conversations.Create(ctx, conversation); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	sharesPath := "/conversations/" + conversation.ID.String() + "/shares"
+	rec := testutil.Request(t, e, http.MethodPost, "/conversations/"+conversation.ID.String()+"/share", map[string]interface{}{}, session)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("share as a member: status %d, body %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		Link models.SharedLink `json:"link"`
+	}
+	testutil.DecodeJSON(t, rec, &created)
+
+	// Leaving the organization ends their hold on its share links
+	if removed, err := orgs.RemoveMember(ctx, org.ID, member.ID); err != nil || !removed {
+		t.Fatalf("RemoveMember = %v, %v", removed, err)
+	}
+	for _, tc := range []struct {
+		method, path string
+	}{
+		{http.MethodPost, "/conversations/" + conversation.ID.String() + "/share"},
+		{http.MethodGet, sharesPath},
+		{http.MethodDelete, sharesPath + "/" + created.Link.ID.String()},
+	} {
+		var body interface{}
+		if tc.method == http.MethodPost {
+			body = map[string]interface{}{}
+		}
+		rec := testutil.Request(t, e, tc.method, tc.path, body, session)
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s %s after leaving: status %d, want %d", tc.method, tc.path, rec.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/cache"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// activeHoursWindow is how far back the most active hours are counted
+const activeHoursWindow = 30 * 24 * time.Hour
+
+// activeHoursTop is how many of the most active hours are returned
+const activeHoursTop = 3
+
+type StatsHandler struct {
+	statsRepo *repository.StatsRepository
+	cache     cache.Cache
+	authSvc   *auth.Service
+	config    config.StatsConfig
+}
+
+func NewStatsHandler(statsRepo *repository.StatsRepository, c cache.Cache, authSvc *auth.Service, config config.StatsConfig) *StatsHandler {
+	return &StatsHandler{
+		statsRepo: statsRepo,
+		cache:     c,
+		authSvc:   authSvc,
+		config:    config,
+	}
+}
+
+// GetStats returns the current user's activity statistics. The tz query
+// parameter, an IANA time zone defaulting to UTC, decides where weeks and
+// hours start.
+func (h *StatsHandler) GetStats(c echo.Context) error {
+	ctx := c.Request().Context()
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(ctx)
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	timeZone := c.QueryParam("tz")
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	// Local would be the server's zone, which the database doesn't know
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil || timeZone == "Local" {
+		return apierror.BadRequest("Unknown timezone")
+	}
+
+	key := fmt.Sprintf("stats:%s:%s", userClaims.UserID, loc)
+	if h.config.CacheTTL > 0 {
+		if cached, err := h.cache.Get(ctx, key); err == nil {
+			return c.JSONBlob(http.StatusOK, cached)
+		}
+	}
+
+	now := time.Now().In(loc)
+	weekStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).
+		AddDate(0, 0, -(int(now.Weekday())+6)%7)
+
+	stats, err := h.statsRepo.ActivityStats(ctx, userClaims.UserID, weekStart, now.Add(-activeHoursWindow), loc.String(), activeHoursTop)
+	if err != nil {
+		return apierror.Internal("Failed to compute stats")
+	}
+	stats.GeneratedAt = now.UTC()
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return apierror.Internal("Failed to encode stats")
+	}
+	if h.config.CacheTTL > 0 {
+		if err := h.cache.Set(ctx, key, data, h.config.CacheTTL); err != nil {
+			logger.ModuleContext(ctx, "stats").Warn().Err(err).Msg("Failed to cache stats")
+		}
+	}
+
+	return c.JSONBlob(http.StatusOK, data)
+}
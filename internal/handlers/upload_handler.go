@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// allowedUploadTypes maps accepted content types to file extensions. Only
+// images are accepted since they are the only attachments models consume.
+var allowedUploadTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// uploadURLTTL is how long signed upload URLs stay valid
+const uploadURLTTL = time.Hour
+
+type UploadHandler struct {
+	uploadRepo *repository.UploadRepository
+	store      storage.Store
+	authSvc    *auth.Service
+	maxSize    int64
+}
+
+func NewUploadHandler(uploadRepo *repository.UploadRepository, store storage.Store, authSvc *auth.Service, maxSize int64) *UploadHandler {
+	return &UploadHandler{
+		uploadRepo: uploadRepo,
+		store:      store,
+		authSvc:    authSvc,
+		maxSize:    maxSize,
+	}
+}
+
+// Upload stores a multipart file (form field "file") for use as a message
+// attachment
+func (h *UploadHandler) Upload(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	// Leave headroom for the multipart envelope around the file
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, h.maxSize+64*1024)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return apierror.BadRequest("File is required")
+	}
+	if fileHeader.Size > h.maxSize {
+		return apierror.PayloadTooLarge(fmt.Sprintf("File exceeds the %d byte limit", h.maxSize))
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return apierror.BadRequest("Failed to read file")
+	}
+	defer file.Close()
+
+	// Sniff the content type instead of trusting the client
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return apierror.BadRequest("Failed to read file")
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	ext, ok := allowedUploadTypes[contentType]
+	if !ok {
+		return apierror.UnsupportedMediaType("Unsupported file type")
+	}
+
+	upload := &models.Upload{
+		UserID:      userClaims.UserID,
+		StorageKey:  fmt.Sprintf("uploads/%s/%s%s", userClaims.UserID, uuid.New(), ext),
+		Filename:    filepath.Base(fileHeader.Filename),
+		ContentType: contentType,
+		SizeBytes:   fileHeader.Size,
+	}
+
+	ctx := c.Request().Context()
+	if err := h.store.Put(ctx, upload.StorageKey, io.MultiReader(bytes.NewReader(head), file), contentType); err != nil {
+		return apierror.Internal("Failed to store file")
+	}
+
+	if err := h.uploadRepo.Create(ctx, upload); err != nil {
+		// Don't leave orphaned objects behind
+		h.store.Delete(ctx, upload.StorageKey)
+		return apierror.Internal("Failed to save upload")
+	}
+
+	if url, err := h.store.SignedURL(ctx, upload.StorageKey, uploadURLTTL); err == nil {
+		upload.URL = url
+	}
+
+	return c.JSON(http.StatusCreated, upload)
+}
+
+// GetUpload serves the content of one of the current user's uploads
+func (h *UploadHandler) GetUpload(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return apierror.BadRequest("Invalid upload ID")
+	}
+
+	upload, err := h.uploadRepo.GetByID(c.Request().Context(), uploadID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch upload")
+	}
+	if upload == nil || upload.UserID != userClaims.UserID {
+		return apierror.NotFound("Upload not found")
+	}
+
+	reader, err := h.store.Get(c.Request().Context(), upload.StorageKey)
+	if err == storage.ErrNotFound {
+		return apierror.NotFound("Upload not found")
+	}
+	if err != nil {
+		return apierror.Internal("Failed to read upload")
+	}
+	defer reader.Close()
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", upload.Filename))
+	return c.Stream(http.StatusOK, upload.ContentType, reader)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+type UsageHandler struct {
+	usageRepo *repository.UsageRepository
+	pricing   *ai.Pricing
+	authSvc   *auth.Service
+}
+
+func NewUsageHandler(usageRepo *repository.UsageRepository, pricing *ai.Pricing, authSvc *auth.Service) *UsageHandler {
+	return &UsageHandler{
+		usageRepo: usageRepo,
+		pricing:   pricing,
+		authSvc:   authSvc,
+	}
+}
+
+// usageDateLayout is accepted for from and to besides RFC3339
+const usageDateLayout = "2006-01-02"
+
+// parseUsagePeriod reads the from and to query parameters. Dates without a
+// time are whole UTC days, so to=2025-08-31 includes that day. The period
+// defaults to the current month so far.
+func parseUsagePeriod(c echo.Context) (time.Time, time.Time, error) {
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := now
+
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		t, _, err := parseUsageTime(fromStr)
+		if err != nil {
+			return from, to, apierror.BadRequest("Invalid from, expected RFC3339 or YYYY-MM-DD")
+		}
+		from = t
+	}
+
+	if toStr := c.QueryParam("to"); toStr != "" {
+		t, dateOnly, err := parseUsageTime(toStr)
+		if err != nil {
+			return from, to, apierror.BadRequest("Invalid to, expected RFC3339 or YYYY-MM-DD")
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = t
+	}
+
+	if !from.Before(to) {
+		return from, to, apierror.BadRequest("from must be before to")
+	}
+	return from, to, nil
+}
+
+func parseUsageTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(usageDateLayout, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// usageFormat reads the format query parameter, json by default
+func usageFormat(c echo.Context) (string, error) {
+	switch format := c.QueryParam("format"); format {
+	case "", "json":
+		return "json", nil
+	case "csv":
+		return format, nil
+	default:
+		return "", apierror.BadRequest("Invalid format, expected json or csv")
+	}
+}
+
+// writeUsageCSV sends rows as a CSV attachment named filename
+func writeUsageCSV(c echo.Context, filename string, header []string, rows [][]string) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	res.WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(res)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	return w.WriteAll(rows)
+}
+
+// usageTotalsHeader and usageTotalsFields are the CSV columns of
+// models.UsageTotals
+var usageTotalsHeader = []string{"replies", "prompt_tokens", "completion_tokens", "total_tokens", "cost_usd", "unpriced_replies"}
+
+func usageTotalsFields(t models.UsageTotals) []string {
+	return []string{
+		strconv.FormatInt(t.Replies, 10),
+		strconv.FormatInt(t.PromptTokens, 10),
+		strconv.FormatInt(t.CompletionTokens, 10),
+		strconv.FormatInt(t.TotalTokens, 10),
+		strconv.FormatFloat(t.CostUSD, 'f', 6, 64),
+		strconv.FormatInt(t.Unpriced, 10),
+	}
+}
+
+func usageFilename(prefix string, from, to time.Time) string {
+	return fmt.Sprintf("%s-%s-%s.csv", prefix, from.UTC().Format(usageDateLayout), to.UTC().Format(usageDateLayout))
+}
+
+// GetReport returns the current user's usage and cost per day and model
+func (h *UsageHandler) GetReport(c echo.Context) error {
+	userClaims, err := h.authSvc.GetUserClaimsFromContext(c.Request().Context())
+	if err != nil {
+		return apierror.Unauthorized("Unauthorized")
+	}
+
+	from, to, err := parseUsagePeriod(c)
+	if err != nil {
+		return err
+	}
+	format, err := usageFormat(c)
+	if err != nil {
+		return err
+	}
+
+	rows, err := h.usageRepo.Report(c.Request().Context(), userClaims.UserID, from, to)
+	if err != nil {
+		return apierror.Internal("Failed to fetch usage report")
+	}
+
+	var totals models.UsageTotals
+	for _, row := range rows {
+		totals.Add(row.UsageTotals)
+	}
+
+	if format == "csv" {
+		header := append([]string{"day", "provider", "model"}, usageTotalsHeader...)
+		records := make([][]string, 0, len(rows))
+		for _, row := range rows {
+			records = append(records, append([]string{row.Day.Format(usageDateLayout), row.Provider, row.Model}, usageTotalsFields(row.UsageTotals)...))
+		}
+		return writeUsageCSV(c, usageFilename("usage", from, to), header, records)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"from":   from,
+		"to":     to,
+		"rows":   rows,
+		"totals": totals,
+	})
+}
+
+// GetRollup returns every user's usage and cost, highest cost first
+func (h *UsageHandler) GetRollup(c echo.Context) error {
+	from, to, err := parseUsagePeriod(c)
+	if err != nil {
+		return err
+	}
+	format, err := usageFormat(c)
+	if err != nil {
+		return err
+	}
+
+	users, err := h.usageRepo.RollupByUser(c.Request().Context(), from, to)
+	if err != nil {
+		return apierror.Internal("Failed to fetch usage rollup")
+	}
+
+	var totals models.UsageTotals
+	for _, user := range users {
+		totals.Add(user.UsageTotals)
+	}
+
+	if format == "csv" {
+		header := append([]string{"user_id", "email"}, usageTotalsHeader...)
+		records := make([][]string, 0, len(users))
+		for _, user := range users {
+			records = append(records, append([]string{user.UserID.String(), user.Email}, usageTotalsFields(user.UsageTotals)...))
+		}
+		return writeUsageCSV(c, usageFilename("usage-rollup", from, to), header, records)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"from":   from,
+		"to":     to,
+		"users":  users,
+		"totals": totals,
+	})
+}
+
+// GetPricing returns the prices used to compute costs
+func (h *UsageHandler) GetPricing(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"prices": h.pricing.Prices(),
+	})
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/shivaluma/eino-agent/internal/apierror"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// CustomValidator validates request bodies for echo
+type CustomValidator struct {
+	validator *validator.Validate
+}
+
+// Validate returns invalid fields as an *apierror.Error with a message per
+// field, so no Go struct or field names reach clients
+func (cv *CustomValidator) Validate(i any) error {
+	if err := cv.validator.Struct(i); err != nil {
+		return apierror.Validation(err)
+	}
+	return nil
+}
+
+// NewValidator reports invalid fields by their JSON names, which is what
+// clients see in validation error details
+func NewValidator() *CustomValidator {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return &CustomValidator{validator: v}
+}
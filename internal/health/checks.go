@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/cache"
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/migrations"
+)
+
+// Database checks that a connection is available and the server answers
+func Database(db *database.DB) Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		stats := db.Stats()
+		details := map[string]interface{}{
+			"max_conns":      stats.MaxConns,
+			"total_conns":    stats.TotalConns,
+			"acquired_conns": stats.AcquiredConns,
+		}
+		return details, db.Health(ctx)
+	}
+}
+
+// Cache checks connectivity to the cache backend
+func Cache(c cache.Cache) Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		return nil, c.Ping(ctx)
+	}
+}
+
+// Migrations checks that the database schema is at least at the newest
+// migration this binary ships with. It fails when another instance rolled
+// the schema back or this binary is newer than the last migration run.
+func Migrations(m *migrations.Migrator) (Check, error) {
+	files, err := m.LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var expected int64
+	for _, file := range files {
+		expected = max(expected, file.Version)
+	}
+
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		current, err := m.GetCurrentVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		details := map[string]interface{}{
+			"current_version":  current,
+			"expected_version": expected,
+		}
+		if current < expected {
+			return details, fmt.Errorf("schema is at version %d, expected %d", current, expected)
+		}
+		return details, nil
+	}, nil
+}
+
+// AIProviders checks that the API endpoint of each provider answers. Any
+// HTTP response below 500 counts, since probes don't authenticate. The
+// check fails only when no provider is reachable, as requests fail over
+// between them.
+func AIProviders(models []ai.NamedModel, client *http.Client) Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		details := make(map[string]interface{}, len(models))
+		reachable := 0
+		for _, m := range models {
+			// Models without an endpoint run in-process
+			if m.Endpoint == "" {
+				details[m.Name] = StatusUp
+				reachable++
+				continue
+			}
+			if err := Ping(ctx, client, m.Endpoint); err != nil {
+				details[m.Name] = err.Error()
+				continue
+			}
+			details[m.Name] = StatusUp
+			reachable++
+		}
+
+		if reachable == 0 {
+			return details, fmt.Errorf("no AI provider is reachable")
+		}
+		return details, nil
+	}
+}
+
+// Ping sends a GET request to the models list of the OpenAI-compatible API
+// at url and fails on transport errors and server errors
+func Ping(ctx context.Context, client *http.Client, url string) error {
+	if url == "" {
+		return fmt.Errorf("no endpoint configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(url, "/")+"/models", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
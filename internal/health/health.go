@@ -0,0 +1,122 @@
+// Package health reports the state of the server and its dependencies for
+// liveness and readiness probes
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status is the state of a component or of the whole server
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+
+	// StatusDegraded means a non-critical component is down; the server
+	// still accepts traffic
+	StatusDegraded Status = "degraded"
+)
+
+// Check reports the state of a dependency. Details are included in the
+// report; a non-nil error marks the component down.
+type Check func(ctx context.Context) (map[string]interface{}, error)
+
+// ComponentReport is the result of a single check
+type ComponentReport struct {
+	Status    Status                 `json:"status"`
+	Critical  bool                   `json:"critical"`
+	LatencyMs int64                  `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Report is the result of running every registered check
+type Report struct {
+	Status     Status                     `json:"status"`
+	Components map[string]ComponentReport `json:"components"`
+	CheckedAt  time.Time                  `json:"checked_at"`
+}
+
+type component struct {
+	name     string
+	critical bool
+	check    Check
+}
+
+// Checker runs the registered dependency checks
+type Checker struct {
+	components []component
+	timeout    time.Duration
+}
+
+// NewChecker creates a checker that bounds each check by timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a check. When a critical component is down the server is
+// not ready; other components only degrade the report.
+func (c *Checker) Register(name string, critical bool, check Check) {
+	c.components = append(c.components, component{name: name, critical: critical, check: check})
+}
+
+// Check runs every registered check concurrently
+func (c *Checker) Check(ctx context.Context) *Report {
+	report := &Report{
+		Status:     StatusUp,
+		Components: make(map[string]ComponentReport, len(c.components)),
+		CheckedAt:  time.Now().UTC(),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, comp := range c.components {
+		wg.Add(1)
+		go func(comp component) {
+			defer wg.Done()
+			result := c.run(ctx, comp)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[comp.name] = result
+			if result.Status != StatusDown {
+				return
+			}
+			if comp.critical {
+				report.Status = StatusDown
+			} else if report.Status == StatusUp {
+				report.Status = StatusDegraded
+			}
+		}(comp)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run executes a single check bounded by the checker timeout
+func (c *Checker) run(ctx context.Context, comp component) ComponentReport {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	details, err := comp.check(ctx)
+
+	result := ComponentReport{
+		Status:    StatusUp,
+		Critical:  comp.critical,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Details:   details,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
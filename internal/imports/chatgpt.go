@@ -0,0 +1,193 @@
+package imports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxTitleLength is the longest title a conversation can have
+const maxTitleLength = 255
+
+// untitled is the title of conversations exported without one
+const untitled = "Imported conversation"
+
+// chatgptConversation is a conversation of a ChatGPT conversations.json.
+// Its messages form a tree, as edits and regenerations branch off; the
+// branch shown last ends at CurrentNode.
+type chatgptConversation struct {
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	UpdateTime  float64                `json:"update_time"`
+	Mapping     map[string]chatgptNode `json:"mapping"`
+	CurrentNode string                 `json:"current_node"`
+}
+
+type chatgptNode struct {
+	Message *chatgptMessage `json:"message"`
+	Parent  string          `json:"parent"`
+}
+
+type chatgptMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+
+	// Recipient is all for messages shown in the conversation, and a tool
+	// for the calls the assistant made
+	Recipient string `json:"recipient"`
+
+	Metadata struct {
+		ModelSlug string `json:"model_slug"`
+		Hidden    bool   `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// readChatGPT calls fn with each conversation of a conversations.json,
+// decoding one at a time so large exports aren't held in memory
+func readChatGPT(r io.Reader, fn func(Thread) error) error {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return fmt.Errorf("%w: expected a list of conversations", ErrInvalidExport)
+	}
+	for dec.More() {
+		var conversation chatgptConversation
+		if err := dec.Decode(&conversation); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		if err := fn(conversation.thread()); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	return nil
+}
+
+// thread returns the branch of the conversation shown last, with the
+// messages the user saw: their own and the assistant's text replies
+func (c *chatgptConversation) thread() Thread {
+	thread := Thread{Title: title(c.Title)}
+	thread.CreatedAt, _ = unixTime(c.CreateTime, time.Now().UTC())
+	thread.UpdatedAt = thread.CreatedAt
+	if t, ok := unixTime(c.UpdateTime, thread.CreatedAt); ok && t.After(thread.UpdatedAt) {
+		thread.UpdatedAt = t
+	}
+
+	// Walk up from the last node; the visited set guards against cycles
+	visited := make(map[string]bool)
+	var branch []*chatgptMessage
+	for id := c.lastNode(); id != "" && !visited[id]; id = c.Mapping[id].Parent {
+		visited[id] = true
+		node, ok := c.Mapping[id]
+		if !ok {
+			break
+		}
+		if node.Message != nil {
+			branch = append(branch, node.Message)
+		}
+	}
+
+	createdAt := thread.CreatedAt
+	for i := len(branch) - 1; i >= 0; i-- {
+		msg := branch[i]
+		role := msg.Author.Role
+		if (role != RoleUser && role != RoleAssistant) || msg.Metadata.Hidden || (msg.Recipient != "" && msg.Recipient != "all") {
+			continue
+		}
+		content := msg.text()
+		if content == "" {
+			continue
+		}
+		// Messages without a time, or out of order, follow the one before
+		if t, ok := unixTime(msg.CreateTime, createdAt); ok && t.After(createdAt) {
+			createdAt = t
+		}
+		thread.Messages = append(thread.Messages, Message{
+			Role:      role,
+			Content:   content,
+			Model:     msg.Metadata.ModelSlug,
+			CreatedAt: createdAt,
+		})
+	}
+	if n := len(thread.Messages); n > 0 && thread.UpdatedAt.Before(thread.Messages[n-1].CreatedAt) {
+		thread.UpdatedAt = thread.Messages[n-1].CreatedAt
+	}
+	return thread
+}
+
+// lastNode returns the node the shown branch ends at: CurrentNode, or for
+// exports without it the latest message that nothing follows
+func (c *chatgptConversation) lastNode() string {
+	if _, ok := c.Mapping[c.CurrentNode]; ok {
+		return c.CurrentNode
+	}
+
+	parents := make(map[string]bool, len(c.Mapping))
+	for _, node := range c.Mapping {
+		parents[node.Parent] = true
+	}
+	var last string
+	latest := math.Inf(-1)
+	for id, node := range c.Mapping {
+		if parents[id] || node.Message == nil {
+			continue
+		}
+		// Ties go to the smaller ID so the pick doesn't depend on map order
+		if t := node.Message.CreateTime; t > latest || (t == latest && id < last) {
+			last, latest = id, t
+		}
+	}
+	return last
+}
+
+// text returns the text of a message, leaving out images and other
+// attachments of multimodal messages
+func (m *chatgptMessage) text() string {
+	switch m.Content.ContentType {
+	case "text", "multimodal_text":
+	default:
+		return ""
+	}
+
+	var texts []string
+	for _, part := range m.Content.Parts {
+		var text string
+		if json.Unmarshal(part, &text) == nil && strings.TrimSpace(text) != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n\n"))
+}
+
+// title trims an exported title to fit a conversation
+func title(exported string) string {
+	exported = strings.TrimSpace(exported)
+	if exported == "" {
+		return untitled
+	}
+	if utf8.RuneCountInString(exported) > maxTitleLength {
+		exported = string([]rune(exported)[:maxTitleLength])
+	}
+	return exported
+}
+
+// unixTime converts a Unix time in seconds; missing times are fallback,
+// with ok false
+func unixTime(seconds float64, fallback time.Time) (time.Time, bool) {
+	if seconds <= 0 {
+		return fallback, false
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), true
+}
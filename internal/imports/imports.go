@@ -0,0 +1,282 @@
+// Package imports brings conversations exported from other assistants into
+// a user's conversations. Small exports are imported during the request;
+// larger ones are stored and imported by a queued task, whose job reports
+// the outcome.
+package imports
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/queue"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// TaskImport is the queued task that imports a large export
+const TaskImport = "import_conversations"
+
+// chatgptFile is the file of a ChatGPT export holding the conversations
+const chatgptFile = "conversations.json"
+
+// ErrInvalidExport is returned for files that are not a ChatGPT export
+var ErrInvalidExport = errors.New("imports: not a ChatGPT conversations export")
+
+// ErrExportTooLarge is returned for zip archives whose conversations are
+// larger than allowed once extracted
+var ErrExportTooLarge = errors.New("imports: export too large")
+
+// Message roles of a thread
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Thread is a conversation read from an export
+type Thread struct {
+	Title     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Messages  []Message
+}
+
+// Message is a message of a thread
+type Message struct {
+	Role      string
+	Content   string
+	CreatedAt time.Time
+
+	// Model is the model that wrote an assistant message, when known
+	Model string
+}
+
+// importTask is the payload of a TaskImport task
+type importTask struct {
+	JobID uuid.UUID `json:"job_id"`
+}
+
+// Importer imports exported conversations
+type Importer struct {
+	convRepo *repository.ConversationRepository
+	tx       *repository.Transactor
+	jobs     *repository.ImportRepository
+	store    storage.Store
+	tasks    queue.Queue
+}
+
+func New(convRepo *repository.ConversationRepository, tx *repository.Transactor, jobs *repository.ImportRepository, store storage.Store, tasks queue.Queue) *Importer {
+	return &Importer{
+		convRepo: convRepo,
+		tx:       tx,
+		jobs:     jobs,
+		store:    store,
+		tasks:    tasks,
+	}
+}
+
+// OpenExport returns the conversations.json of an upload, which is either
+// that file or the zip archive ChatGPT sends, with its size. Archives whose
+// conversations.json is larger than maxSize are rejected.
+func OpenExport(file io.ReaderAt, size, maxSize int64) (io.Reader, int64, error) {
+	head := make([]byte, 4)
+	if n, _ := file.ReadAt(head, 0); !bytes.Equal(head[:n], []byte("PK\x03\x04")) {
+		return io.NewSectionReader(file, 0, size), size, nil
+	}
+
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	for _, f := range archive.File {
+		if path.Base(f.Name) != chatgptFile {
+			continue
+		}
+		if f.UncompressedSize64 > uint64(maxSize) {
+			return nil, 0, ErrExportTooLarge
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		// The archive's sizes can't be trusted; a conversations.json cut
+		// off here fails to parse
+		return io.LimitReader(r, int64(f.UncompressedSize64)), int64(f.UncompressedSize64), nil
+	}
+	return nil, 0, fmt.Errorf("%w: no %s in the archive", ErrInvalidExport, chatgptFile)
+}
+
+// Import imports the conversations of a ChatGPT export for the user and
+// reports what it created. Each conversation is saved on its own, so one
+// that fails is counted in the report and the import goes on; an export
+// that turns out unreadable stops it, keeping what was imported so far.
+func (i *Importer) Import(ctx context.Context, userID uuid.UUID, export io.Reader) (*models.ImportReport, error) {
+	report := &models.ImportReport{ConversationIDs: []uuid.UUID{}}
+	err := readChatGPT(export, func(thread Thread) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(thread.Messages) == 0 {
+			report.Skipped++
+			return nil
+		}
+
+		conversation, messages := thread.conversation(userID)
+		err := i.tx.WithTx(ctx, func(ctx context.Context) error {
+			return i.convRepo.Import(ctx, conversation, messages)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.ModuleContext(ctx, "import").Error().Err(err).Str("user_id", userID.String()).Msg("Failed to import conversation")
+			report.Failed++
+			if len(report.Errors) < models.MaxImportErrors {
+				report.Errors = append(report.Errors, models.ImportError{Title: thread.Title, Error: "failed to save conversation"})
+			}
+			return nil
+		}
+
+		report.Conversations++
+		report.Messages += len(messages)
+		report.ConversationIDs = append(report.ConversationIDs, conversation.ID)
+		return nil
+	})
+	return report, err
+}
+
+// conversation maps the thread to a conversation of the user and its
+// messages
+func (t *Thread) conversation(userID uuid.UUID) (*models.Conversation, []models.Message) {
+	title := t.Title
+	conversation := &models.Conversation{
+		UserID:    userID,
+		Title:     &title,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+	}
+
+	messages := make([]models.Message, 0, len(t.Messages))
+	for _, m := range t.Messages {
+		message := models.Message{
+			Content:   m.Content,
+			CreatedAt: m.CreatedAt,
+		}
+		if m.Role == RoleUser {
+			message.SenderID = userID
+			message.SenderType = models.SenderTypeUser
+		} else {
+			message.SenderID = uuid.Nil
+			message.SenderType = models.SenderTypeAgent
+			message.Metadata = (&models.GenerationMetadata{
+				Provider: "openai",
+				Model:    m.Model,
+				Imported: models.ImportSourceChatGPT,
+			}).JSON()
+		}
+		messages = append(messages, message)
+	}
+	return conversation, messages
+}
+
+// Start stores an export and queues its import, returning the pending job
+func (i *Importer) Start(ctx context.Context, userID uuid.UUID, export io.Reader) (*models.ImportJob, error) {
+	job := &models.ImportJob{
+		UserID:     userID,
+		Source:     models.ImportSourceChatGPT,
+		StorageKey: fmt.Sprintf("imports/%s/%s.json", userID, uuid.New()),
+	}
+	if err := i.store.Put(ctx, job.StorageKey, export, "application/json"); err != nil {
+		return nil, fmt.Errorf("failed to store export: %w", err)
+	}
+
+	if err := i.jobs.Create(ctx, job); err != nil {
+		// Don't leave orphaned objects behind
+		i.store.Delete(ctx, job.StorageKey)
+		return nil, err
+	}
+	if err := i.tasks.Enqueue(ctx, TaskImport, importTask{JobID: job.ID}); err != nil {
+		i.fail(ctx, job, "failed to queue the import")
+		return nil, fmt.Errorf("failed to queue import: %w", err)
+	}
+	return job, nil
+}
+
+// Handle runs a TaskImport task. The task isn't retried once the import
+// started, since a second run would import the conversations again; a job
+// found running was interrupted and is failed instead.
+func (i *Importer) Handle(ctx context.Context, payload json.RawMessage) error {
+	var task importTask
+	if err := json.Unmarshal(payload, &task); err != nil {
+		return fmt.Errorf("invalid import task: %w", err)
+	}
+
+	job, err := i.jobs.GetByID(ctx, task.JobID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case job == nil:
+		return nil
+	case job.Status == models.ImportRunning:
+		i.fail(ctx, job, "the import was interrupted")
+		return nil
+	case job.Status != models.ImportPending:
+		return nil
+	}
+
+	export, err := i.store.Get(ctx, job.StorageKey)
+	if err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+	defer export.Close()
+
+	started, err := i.jobs.Start(ctx, job.ID)
+	if err != nil || !started {
+		return err
+	}
+
+	report, err := i.Import(ctx, job.UserID, export)
+	job.Report = report
+	switch {
+	case errors.Is(err, ErrInvalidExport):
+		i.fail(ctx, job, "the file is not a ChatGPT conversations export")
+	case err != nil:
+		logger.ModuleContext(ctx, "import").Error().Err(err).Str("job_id", job.ID.String()).Msg("Import failed")
+		i.fail(ctx, job, "the import did not finish")
+	default:
+		job.Status = models.ImportCompleted
+		i.finish(ctx, job)
+	}
+	return nil
+}
+
+// fail finishes a job as failed with a message for the user
+func (i *Importer) fail(ctx context.Context, job *models.ImportJob, message string) {
+	job.Status = models.ImportFailed
+	job.Error = &message
+	i.finish(ctx, job)
+}
+
+// finish records the outcome of a job, even once ctx timed out, and drops
+// its export
+func (i *Importer) finish(ctx context.Context, job *models.ImportJob) {
+	ctx = context.WithoutCancel(ctx)
+	log := logger.ModuleContext(ctx, "import")
+	if err := i.jobs.Finish(ctx, job); err != nil {
+		log.Error().Err(err).Str("job_id", job.ID.String()).Msg("Failed to record import outcome")
+	}
+	if err := i.store.Delete(ctx, job.StorageKey); err != nil {
+		log.Warn().Err(err).Str("job_id", job.ID.String()).Msg("Failed to delete imported export")
+	}
+}
@@ -0,0 +1,143 @@
+// Package loadgen drives the API with synthetic users for load tests. Each
+// user registers, signs in and sends messages, one at a time, recording
+// how long every request took. Run the server with serve --load-test so
+// replies come from the mock provider and the run measures the HTTP and
+// database path rather than a model.
+package loadgen
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations recorded in a report
+const (
+	OpRegister = "register"
+	OpLogin    = "login"
+	OpMessage  = "message"
+
+	// OpFirstChunk is the time from sending a streamed message to its
+	// first chunk
+	OpFirstChunk = "first_chunk"
+)
+
+// Config describes a run
+type Config struct {
+	// BaseURL is the server, e.g. http://localhost:8888
+	BaseURL string
+
+	// Users is how many synthetic users run at once
+	Users int
+
+	// Duration bounds the run; users stop sending once it is over
+	Duration time.Duration
+
+	// Messages is how many messages each user sends (0 sends until
+	// Duration is over)
+	Messages int
+
+	// PerConversation is how many messages go to one conversation before
+	// a user starts another
+	PerConversation int
+
+	// Stream sends messages with stream=true and reads the events
+	Stream bool
+
+	// Message is the text sent
+	Message string
+
+	// RampUp spreads the users' starts over this long
+	RampUp time.Duration
+
+	// Timeout bounds each request
+	Timeout time.Duration
+}
+
+// Run drives the API as cfg describes until the users are done, Duration
+// is over or ctx is cancelled, and reports the latencies
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.Users < 1 {
+		return nil, errors.New("at least one user is needed")
+	}
+	if cfg.Duration <= 0 && cfg.Messages <= 0 {
+		return nil, errors.New("a duration or a message count is needed")
+	}
+	if cfg.PerConversation < 1 {
+		cfg.PerConversation = 1
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	// Emails of this run don't collide with earlier runs' users
+	runID, err := randomHex(4)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder := newRecorder()
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Users; i++ {
+		delay := time.Duration(0)
+		if cfg.RampUp > 0 {
+			delay = cfg.RampUp * time.Duration(i) / time.Duration(cfg.Users)
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if !sleep(ctx, delay) {
+				return
+			}
+
+			jar, _ := cookiejar.New(nil)
+			u := &user{
+				cfg:      &cfg,
+				client:   &http.Client{Jar: jar, Timeout: cfg.Timeout},
+				recorder: recorder,
+				email:    fmt.Sprintf("loadgen-%s-%d@example.com", runID, i),
+			}
+			u.run(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	return recorder.report(time.Since(start)), nil
+}
+
+// sleep waits for d unless ctx is done first, reporting whether it slept
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
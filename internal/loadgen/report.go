@@ -0,0 +1,150 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// recorder collects the latencies and failures of a run's users
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	failures  map[string]map[failure]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		latencies: make(map[string][]time.Duration),
+		failures:  make(map[string]map[failure]int),
+	}
+}
+
+func (r *recorder) record(op string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies[op] = append(r.latencies[op], d)
+}
+
+func (r *recorder) fail(op string, f failure) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures[op] == nil {
+		r.failures[op] = make(map[failure]int)
+	}
+	r.failures[op][f]++
+}
+
+// report summarizes what was recorded over a run that took elapsed
+func (r *recorder) report(elapsed time.Duration) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{Elapsed: elapsed}
+	for _, op := range []string{OpRegister, OpLogin, OpMessage, OpFirstChunk} {
+		latencies := r.latencies[op]
+		if len(latencies) == 0 && len(r.failures[op]) == 0 {
+			continue
+		}
+
+		stats := OpStats{Op: op, Count: len(latencies), Failures: make(map[string]int)}
+		for f, n := range r.failures[op] {
+			stats.Failures[string(f)] = n
+			stats.Failed += n
+		}
+		if len(latencies) > 0 {
+			slices.Sort(latencies)
+			stats.Min = latencies[0]
+			stats.P50 = percentile(latencies, 50)
+			stats.P90 = percentile(latencies, 90)
+			stats.P95 = percentile(latencies, 95)
+			stats.P99 = percentile(latencies, 99)
+			stats.Max = latencies[len(latencies)-1]
+		}
+		report.Ops = append(report.Ops, stats)
+	}
+	return report
+}
+
+// percentile picks the p-th percentile of sorted by the nearest-rank method
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Report summarizes a run
+type Report struct {
+	Elapsed time.Duration `json:"elapsed"`
+	Ops     []OpStats     `json:"ops"`
+}
+
+// OpStats are the latencies of one operation. Failed requests are counted
+// by reason and left out of the latencies.
+type OpStats struct {
+	Op       string         `json:"op"`
+	Count    int            `json:"count"`
+	Failed   int            `json:"failed"`
+	Failures map[string]int `json:"failures,omitempty"`
+	Min      time.Duration  `json:"min"`
+	P50      time.Duration  `json:"p50"`
+	P90      time.Duration  `json:"p90"`
+	P95      time.Duration  `json:"p95"`
+	P99      time.Duration  `json:"p99"`
+	Max      time.Duration  `json:"max"`
+}
+
+// Rate is how many requests of the operation succeeded per second
+func (s OpStats) Rate(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Count) / elapsed.Seconds()
+}
+
+// Print writes the report as a table, followed by the failures
+func (r *Report) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "op\tok\tfailed\treq/s\tmin\tp50\tp90\tp95\tp99\tmax\t\n")
+	for _, s := range r.Ops {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			s.Op, s.Count, s.Failed, s.Rate(r.Elapsed),
+			round(s.Min), round(s.P50), round(s.P90), round(s.P95), round(s.P99), round(s.Max))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, s := range r.Ops {
+		if s.Failed == 0 {
+			continue
+		}
+		reasons := make([]string, 0, len(s.Failures))
+		for reason, n := range s.Failures {
+			reasons = append(reasons, fmt.Sprintf("%s: %d", reason, n))
+		}
+		sort.Strings(reasons)
+		fmt.Fprintf(w, "%s failures: %s\n", s.Op, strings.Join(reasons, ", "))
+	}
+	_, err := fmt.Fprintf(w, "elapsed: %s\n", round(r.Elapsed))
+	return err
+}
+
+// round trims a duration to a readable precision
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,214 @@
+package loadgen
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/sse"
+)
+
+// password of every synthetic user
+const password = "loadgen-password"
+
+// user is one synthetic user, with its own session
+type user struct {
+	cfg      *Config
+	client   *http.Client
+	recorder *recorder
+	email    string
+
+	conversationID string
+	inConversation int
+}
+
+// run signs the user up and in, then sends messages until it sent
+// Messages or ctx is done
+func (u *user) run(ctx context.Context) {
+	err := u.do(ctx, OpRegister, "/api/v1/register", map[string]any{
+		"name":     "Load Test",
+		"email":    u.email,
+		"password": password,
+	}, http.StatusCreated)
+	if err != nil {
+		return
+	}
+
+	err = u.do(ctx, OpLogin, "/api/v1/login", map[string]any{
+		"email":    u.email,
+		"password": password,
+	}, http.StatusOK)
+	if err != nil {
+		return
+	}
+
+	for sent := 0; u.cfg.Messages <= 0 || sent < u.cfg.Messages; sent++ {
+		if ctx.Err() != nil {
+			return
+		}
+		u.send(ctx)
+	}
+}
+
+// do posts body to path and records how long it took, failing unless the
+// server answered with want
+func (u *user) do(ctx context.Context, op, path string, body any, want int) error {
+	start := time.Now()
+	resp, err := u.post(ctx, path, body)
+	if err != nil {
+		u.recordError(ctx, op, err)
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != want {
+		u.recorder.fail(op, statusFailure(resp.StatusCode))
+		return statusFailure(resp.StatusCode)
+	}
+	u.recorder.record(op, time.Since(start))
+	return nil
+}
+
+// send sends one message, continuing the user's conversation until it
+// holds PerConversation messages
+func (u *user) send(ctx context.Context) {
+	if u.inConversation >= u.cfg.PerConversation {
+		u.conversationID = ""
+		u.inConversation = 0
+	}
+
+	body := map[string]any{
+		"message": u.cfg.Message,
+		"stream":  u.cfg.Stream,
+	}
+	if u.conversationID != "" {
+		body["conversation_id"] = u.conversationID
+	}
+
+	start := time.Now()
+	resp, err := u.post(ctx, "/api/v1/messages", body)
+	if err != nil {
+		u.recordError(ctx, OpMessage, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		u.recorder.fail(OpMessage, statusFailure(resp.StatusCode))
+		return
+	}
+
+	if u.cfg.Stream {
+		err = u.readStream(resp.Body, start)
+	} else {
+		err = u.readReply(resp.Body)
+	}
+	if err != nil {
+		u.recordError(ctx, OpMessage, err)
+		return
+	}
+	u.recorder.record(OpMessage, time.Since(start))
+	u.inConversation++
+}
+
+// readReply reads the conversation of a non-streamed reply
+func (u *user) readReply(body io.Reader) error {
+	var reply struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := json.NewDecoder(body).Decode(&reply); err != nil {
+		return failure("invalid reply")
+	}
+	u.conversationID = reply.ConversationID
+	return nil
+}
+
+// readStream reads the events of a streamed reply up to its end, recording
+// when the first chunk arrived
+func (u *user) readStream(body io.Reader, start time.Time) error {
+	firstChunk := true
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Type           string `json:"type"`
+			ConversationID string `json:"conversation_id"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return failure("invalid event")
+		}
+
+		switch event.Type {
+		case sse.EventTypeInit:
+			u.conversationID = event.ConversationID
+		case sse.EventTypeChunk:
+			if firstChunk {
+				u.recorder.record(OpFirstChunk, time.Since(start))
+				firstChunk = false
+			}
+		case sse.EventTypeComplete:
+			return nil
+		case sse.EventTypeError, sse.EventTypeCancelled:
+			return failure("stream " + event.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return failure("stream incomplete")
+}
+
+func (u *user) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.cfg.BaseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return u.client.Do(req)
+}
+
+// failure is a failed request as counted in a report
+type failure string
+
+func (f failure) Error() string { return string(f) }
+
+func statusFailure(status int) failure {
+	return failure(fmt.Sprintf("HTTP %d", status))
+}
+
+// recordError counts a failed request, except one cut short because the
+// run is over
+func (u *user) recordError(ctx context.Context, op string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	var f failure
+	var netErr net.Error
+	switch {
+	case errors.As(err, &f):
+		u.recorder.fail(op, f)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		u.recorder.fail(op, "timeout")
+	default:
+		u.recorder.fail(op, "connection error")
+	}
+}
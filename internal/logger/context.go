@@ -2,6 +2,9 @@ package logger
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -72,7 +75,25 @@ func GenerateRequestID() string {
 	return uuid.New().String()
 }
 
-// GenerateTraceID generates a new trace ID
+// GenerateTraceID generates a new W3C trace ID
 func GenerateTraceID() string {
-	return uuid.New().String()
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// GenerateSpanID generates a new W3C span ID
+func GenerateSpanID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// WithTraceparent continues the trace of a W3C traceparent header, taking
+// its trace ID and its parent ID as the current span. Without a valid
+// header a new trace starts.
+func WithTraceparent(ctx context.Context, header string) context.Context {
+	parts := strings.Split(header, "-")
+	if len(parts) == 4 && isHexID(parts[1], 32) && isHexID(parts[2], 16) {
+		return WithSpanID(WithTraceID(ctx, parts[1]), parts[2])
+	}
+	return WithTraceID(ctx, GenerateTraceID())
 }
\ No newline at end of file
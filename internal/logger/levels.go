@@ -0,0 +1,236 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Sampling thins out debug and info logs under load. Warn and above are
+// never sampled.
+type Sampling struct {
+	// Debug and Info keep 1 in N events once the burst is used up; 0 or 1
+	// keeps every event
+	Debug uint32 `json:"debug"`
+	Info  uint32 `json:"info"`
+
+	// Burst events per level per second are kept before sampling starts
+	Burst uint32 `json:"burst"`
+}
+
+// Settings are the logging controls that can change at runtime
+type Settings struct {
+	Level string `json:"level"`
+
+	// Modules overrides Level for loggers returned by Module, e.g.
+	// {"ai": "debug"}
+	Modules map[string]string `json:"modules"`
+
+	Sampling Sampling `json:"sampling"`
+}
+
+// Validate checks that every level is known
+func (s Settings) Validate() error {
+	var errs []error
+	if !IsLevel(s.Level) {
+		errs = append(errs, fmt.Errorf("unknown log level: %s", s.Level))
+	}
+	for module, level := range s.Modules {
+		if !IsLevel(level) {
+			errs = append(errs, fmt.Errorf("unknown log level for module %s: %s", module, level))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	levelMu      sync.RWMutex
+	baseLevel    = zerolog.InfoLevel
+	moduleLevels = make(map[string]zerolog.Level)
+	modules      = make(map[string]*zerolog.Logger)
+
+	// rootLogger is Logger without the level hook, which module loggers
+	// replace with their own
+	rootLogger zerolog.Logger
+
+	sampler = &levelSampler{}
+)
+
+// Apply replaces the level, module levels and sampling
+func Apply(s Settings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	levels := make(map[string]zerolog.Level, len(s.Modules))
+	for module, level := range s.Modules {
+		levels[strings.ToLower(module)] = logLevels[strings.ToLower(level)]
+	}
+
+	levelMu.Lock()
+	baseLevel = logLevels[strings.ToLower(s.Level)]
+	moduleLevels = levels
+	levelMu.Unlock()
+
+	sampler.set(s.Sampling)
+	applyGlobalLevel()
+	return nil
+}
+
+// CurrentSettings returns the active logging controls
+func CurrentSettings() Settings {
+	levelMu.RLock()
+	defer levelMu.RUnlock()
+
+	s := Settings{
+		Level:    baseLevel.String(),
+		Modules:  make(map[string]string, len(moduleLevels)),
+		Sampling: sampler.get(),
+	}
+	for module, level := range moduleLevels {
+		s.Modules[module] = level.String()
+	}
+	return s
+}
+
+// Module returns the logger for a subsystem, tagged with a module field and
+// filtered by its own level when one is set
+func Module(name string) *zerolog.Logger {
+	name = strings.ToLower(name)
+
+	levelMu.RLock()
+	l, ok := modules[name]
+	levelMu.RUnlock()
+	if ok {
+		return l
+	}
+
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	if l, ok := modules[name]; ok {
+		return l
+	}
+	created := rootLogger.With().Str("module", name).Logger().Hook(levelHook{module: name})
+	modules[name] = &created
+	return &created
+}
+
+// ModuleContext is Module with the request fields of WithContext
+func ModuleContext(ctx context.Context, name string) *zerolog.Logger {
+	l := withContextFields(ctx, *Module(name))
+	return &l
+}
+
+// Enabled reports whether events at level would be logged by the module's
+// logger, so callers can skip building expensive fields
+func Enabled(module string, level zerolog.Level) bool {
+	levelMu.RLock()
+	defer levelMu.RUnlock()
+
+	min, ok := moduleLevels[strings.ToLower(module)]
+	if !ok {
+		min = baseLevel
+	}
+	return level >= min
+}
+
+// applyGlobalLevel lowers zerolog's global level to the most verbose
+// module so its events reach levelHook, which filters the rest
+func applyGlobalLevel() {
+	levelMu.RLock()
+	defer levelMu.RUnlock()
+
+	lowest := baseLevel
+	for _, level := range moduleLevels {
+		if level < lowest {
+			lowest = level
+		}
+	}
+	zerolog.SetGlobalLevel(lowest)
+}
+
+// resetModules drops cached module loggers after the root logger changes
+func resetModules(root zerolog.Logger) {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	rootLogger = root
+	modules = make(map[string]*zerolog.Logger)
+}
+
+// levelHook discards events below the effective level of its module
+type levelHook struct {
+	module string
+}
+
+func (h levelHook) Run(e *zerolog.Event, level zerolog.Level, _ string) {
+	if level == zerolog.NoLevel {
+		return
+	}
+
+	levelMu.RLock()
+	min, ok := moduleLevels[h.module]
+	if !ok {
+		min = baseLevel
+	}
+	levelMu.RUnlock()
+
+	if level < min {
+		e.Discard()
+	}
+}
+
+// levelSampler implements Sampling. Settings are atomics so they can change
+// while loggers are in use.
+type levelSampler struct {
+	debugN, infoN, burst atomic.Uint32
+	debug, info          sampleCounter
+}
+
+type sampleCounter struct {
+	count   atomic.Uint64
+	second  atomic.Int64
+	inBurst atomic.Uint32
+}
+
+func (s *levelSampler) set(cfg Sampling) {
+	s.debugN.Store(cfg.Debug)
+	s.infoN.Store(cfg.Info)
+	s.burst.Store(cfg.Burst)
+}
+
+func (s *levelSampler) get() Sampling {
+	return Sampling{Debug: s.debugN.Load(), Info: s.infoN.Load(), Burst: s.burst.Load()}
+}
+
+func (s *levelSampler) Sample(level zerolog.Level) bool {
+	var n uint32
+	var counter *sampleCounter
+	switch level {
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		n, counter = s.debugN.Load(), &s.debug
+	case zerolog.InfoLevel:
+		n, counter = s.infoN.Load(), &s.info
+	default:
+		return true
+	}
+	if n <= 1 {
+		return true
+	}
+
+	if burst := s.burst.Load(); burst > 0 {
+		now := time.Now().Unix()
+		if counter.second.Swap(now) != now {
+			counter.inBurst.Store(0)
+		}
+		if counter.inBurst.Add(1) <= burst {
+			return true
+		}
+	}
+	return counter.count.Add(1)%uint64(n) == 0
+}
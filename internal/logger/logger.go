@@ -40,7 +40,7 @@ type Config struct {
 	// Format is the output format (json, console)
 	Format string `json:"format" env:"LOG_FORMAT" default:"json"`
 
-	// Output destinations (stdout, stderr, file)
+	// Output destinations (stdout, stderr, file, loki, syslog, otlp)
 	Output string `json:"output" env:"LOG_OUTPUT" default:"stdout"`
 
 	// FilePath for file output
@@ -57,6 +57,28 @@ type Config struct {
 
 	// ErrorStackTrace enables stack trace for errors
 	ErrorStackTrace bool `json:"error_stack_trace" env:"LOG_STACK_TRACE" default:"true"`
+
+	// RedactPII hashes or masks personal data and credentials in log fields
+	RedactPII bool `json:"redact_pii" env:"LOG_REDACT_PII"`
+
+	// RedactKey keys the hashes so they can't be reversed by guessing; set
+	// it to correlate hashes across restarts and instances
+	RedactKey string `json:"-" env:"LOG_REDACT_KEY"`
+
+	// RedactHashFields and RedactMaskFields override DefaultHashFields and
+	// DefaultMaskFields
+	RedactHashFields []string `json:"redact_hash_fields" env:"LOG_REDACT_HASH_FIELDS"`
+	RedactMaskFields []string `json:"redact_mask_fields" env:"LOG_REDACT_MASK_FIELDS"`
+
+	// ModuleLevels overrides Level per module, e.g. {"ai": "debug"} from
+	// LOG_LEVEL_AI=debug
+	ModuleLevels map[string]string `json:"module_levels"`
+
+	// Sampling thins out debug and info logs under load
+	Sampling Sampling `json:"sampling"`
+
+	// Sink configures the loki, syslog and otlp outputs
+	Sink SinkConfig `json:"sink"`
 }
 
 // DefaultConfig returns default logger configuration
@@ -83,15 +105,18 @@ func Init(cfg *Config) error {
 	zerolog.TimeFieldFormat = time.RFC3339Nano
 	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
 
-	// Set log level
-	level, ok := logLevels[strings.ToLower(cfg.Level)]
-	if !ok {
-		level = zerolog.InfoLevel
+	// Unknown levels fall back to info
+	level := strings.ToLower(cfg.Level)
+	if !IsLevel(level) {
+		level = "info"
+	}
+	if err := Apply(Settings{Level: level, Modules: cfg.ModuleLevels, Sampling: cfg.Sampling}); err != nil {
+		return err
 	}
-	zerolog.SetGlobalLevel(level)
 
 	// Configure output
 	var output io.Writer
+	var sink *asyncWriter
 	switch strings.ToLower(cfg.Output) {
 	case "stderr":
 		output = os.Stderr
@@ -104,14 +129,21 @@ func Init(cfg *Config) error {
 			return fmt.Errorf("failed to open log file: %w", err)
 		}
 		output = file
+	case OutputLoki, OutputSyslog, OutputOTLP:
+		w, err := newSinkWriter(strings.ToLower(cfg.Output), cfg.Sink)
+		if err != nil {
+			return fmt.Errorf("failed to create log sink: %w", err)
+		}
+		sink = w
+		output = w
 	case "stdout":
 		fallthrough
 	default:
 		output = os.Stdout
 	}
 
-	// Configure format
-	if cfg.Format == "console" {
+	// Configure format; sinks always receive JSON
+	if cfg.Format == "console" && sink == nil {
 		if cfg.PrettyPrint {
 			output = zerolog.ConsoleWriter{
 				Out:        output,
@@ -127,6 +159,11 @@ func Init(cfg *Config) error {
 		}
 	}
 
+	// Redact before formatting so console output is covered too
+	if cfg.RedactPII {
+		output = newRedactWriter(output, cfg.RedactKey, cfg.RedactHashFields, cfg.RedactMaskFields)
+	}
+
 	// Create logger context
 	logContext := zerolog.New(output)
 
@@ -146,13 +183,45 @@ func Init(cfg *Config) error {
 	}
 	logContext = logContext.With().Int("pid", os.Getpid()).Logger()
 
+	// Sampling applies to every logger; the level hook lets module loggers
+	// log below the base level
+	logContext = logContext.Sample(sampler)
+	resetModules(logContext)
+
 	// Set global logger
-	Logger = logContext
-	log.Logger = logContext
+	Logger = logContext.Hook(levelHook{})
+	log.Logger = Logger
+
+	// Flush the sink of a previous Init now that nothing writes to it
+	if previous := activeSink.Swap(sink); previous != nil {
+		previous.Close()
+	}
 
 	return nil
 }
 
+// SetLevel changes the minimum log level at runtime. Module levels set with
+// Apply still take precedence for their modules.
+func SetLevel(name string) error {
+	level, ok := logLevels[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown log level: %s", name)
+	}
+
+	levelMu.Lock()
+	baseLevel = level
+	levelMu.Unlock()
+
+	applyGlobalLevel()
+	return nil
+}
+
+// IsLevel reports whether name is a known log level
+func IsLevel(name string) bool {
+	_, ok := logLevels[strings.ToLower(name)]
+	return ok
+}
+
 // InitDevelopment initializes logger with development settings
 func InitDevelopment() {
 	Init(&Config{
@@ -190,7 +259,13 @@ func ensureLogDir(filePath string) error {
 
 // WithContext returns a logger with context
 func WithContext(ctx context.Context) *zerolog.Logger {
-	l := Logger.With().Logger()
+	l := withContextFields(ctx, Logger)
+	return &l
+}
+
+// withContextFields adds the request and user IDs from ctx to l
+func withContextFields(ctx context.Context, l zerolog.Logger) zerolog.Logger {
+	l = l.With().Logger()
 	
 	// Add request ID if present
 	if reqID := GetRequestID(ctx); reqID != "" {
@@ -201,8 +276,16 @@ func WithContext(ctx context.Context) *zerolog.Logger {
 	if userID := GetUserID(ctx); userID != "" {
 		l = l.With().Str("user_id", userID).Logger()
 	}
+
+	// Add trace and span IDs if present
+	if traceID := GetTraceID(ctx); traceID != "" {
+		l = l.With().Str("trace_id", traceID).Logger()
+	}
+	if spanID := GetSpanID(ctx); spanID != "" {
+		l = l.With().Str("span_id", spanID).Logger()
+	}
 	
-	return &l
+	return l
 }
 
 // WithFields returns a logger with additional fields
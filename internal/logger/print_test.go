@@ -0,0 +1,103 @@
+package logger_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// printAllowed are the parts of internal/ that may print, as in
+// .golangci.yml: the command line prints to the terminal and the database
+// setup still logs with the standard library
+var printAllowed = []string{
+	"cli/",
+	"database/database.go",
+}
+
+// allowed reports whether rel, relative to internal/, may print
+func allowed(rel string) bool {
+	for _, prefix := range printAllowed {
+		if strings.HasPrefix(rel, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// forbiddenPrint reports whether a function of package path is one that
+// bypasses internal/logger
+func forbiddenPrint(path, name string) bool {
+	switch path {
+	case "fmt":
+		return strings.HasPrefix(name, "Print")
+	case "log":
+		return strings.HasPrefix(name, "Print") || strings.HasPrefix(name, "Fatal") || strings.HasPrefix(name, "Panic")
+	}
+	return false
+}
+
+// TestNoPrint fails on server code that prints with fmt or log instead of
+// logging through internal/logger, so the check holds without golangci-lint
+func TestNoPrint(t *testing.T) {
+	root := ".."
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if allowed(rel + "/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(rel, ".go") || allowed(rel) {
+			return nil
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+
+		// Imports may be renamed, so calls are matched by the local name
+		imports := make(map[string]string)
+		for _, spec := range file.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			name := importPath[strings.LastIndex(importPath, "/")+1:]
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			imports[name] = importPath
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			pkg, ok := sel.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if importPath, ok := imports[pkg.Name]; ok && forbiddenPrint(importPath, sel.Sel.Name) {
+				t.Errorf("%s: %s.%s: log through internal/logger instead", fset.Position(sel.Pos()), pkg.Name, sel.Sel.Name)
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk internal/: %v", err)
+	}
+}
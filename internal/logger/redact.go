@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Fields redacted by default when PII redaction is enabled. Hashed fields
+// keep a stable pseudonym so events about the same person can still be
+// correlated; masked fields are replaced outright.
+var (
+	DefaultHashFields = []string{"email", "invalid_email", "username", "ip", "remote_ip", "client_ip"}
+	DefaultMaskFields = []string{
+		"password", "token", "access_token", "refresh_token", "id_token",
+		"authorization", "cookie", "api_key", "secret", "code", "state",
+	}
+)
+
+const redactedValue = "[REDACTED]"
+
+// redactWriter rewrites JSON log events, replacing PII fields before they
+// reach the output. Events that can't be parsed are written unchanged.
+type redactWriter struct {
+	out  io.Writer
+	key  []byte
+	hash map[string]bool
+	mask map[string]bool
+}
+
+// newRedactWriter wraps out. An empty key uses a random one, so hashes are
+// only stable for the life of the process.
+func newRedactWriter(out io.Writer, key string, hashFields, maskFields []string) *redactWriter {
+	if len(hashFields) == 0 {
+		hashFields = DefaultHashFields
+	}
+	if len(maskFields) == 0 {
+		maskFields = DefaultMaskFields
+	}
+
+	w := &redactWriter{
+		out:  out,
+		key:  []byte(key),
+		hash: fieldSet(hashFields),
+		mask: fieldSet(maskFields),
+	}
+	if len(w.key) == 0 {
+		w.key = make([]byte, 32)
+		rand.Read(w.key)
+	}
+	return w
+}
+
+func fieldSet(fields []string) map[string]bool {
+	set := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		set[strings.ToLower(strings.TrimSpace(field))] = true
+	}
+	return set
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+
+	var event map[string]interface{}
+	if err := decoder.Decode(&event); err != nil || !w.redactMap(event) {
+		return w.out.Write(p)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(event); err != nil {
+		return w.out.Write(p)
+	}
+
+	if _, err := w.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the wrapped output, so buffered sinks are flushed when
+// zerolog exits on Fatal
+func (w *redactWriter) Close() error {
+	if closer, ok := w.out.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// redactMap redacts fields in place, descending into nested objects, and
+// reports whether anything changed
+func (w *redactWriter) redactMap(fields map[string]interface{}) bool {
+	changed := false
+	for name, value := range fields {
+		lower := strings.ToLower(name)
+		switch {
+		case w.mask[lower]:
+			fields[name] = redactedValue
+			changed = true
+		case w.hash[lower]:
+			if s, ok := value.(string); ok && s != "" {
+				fields[name] = w.pseudonym(s)
+				changed = true
+			}
+		case lower == "query":
+			if s, ok := value.(string); ok {
+				if redacted := w.redactQuery(s); redacted != s {
+					fields[name] = redacted
+					changed = true
+				}
+			}
+		default:
+			if nested, ok := value.(map[string]interface{}); ok && w.redactMap(nested) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// redactQuery applies the field rules to query string parameters, e.g. the
+// code and state of an OAuth callback
+func (w *redactWriter) redactQuery(query string) string {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return redactedValue
+	}
+
+	changed := false
+	for name, params := range values {
+		lower := strings.ToLower(name)
+		for i, param := range params {
+			switch {
+			case w.mask[lower]:
+				params[i] = redactedValue
+				changed = true
+			case w.hash[lower]:
+				params[i] = w.pseudonym(param)
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return query
+	}
+	return values.Encode()
+}
+
+// pseudonym is a keyed hash of value, short enough to read in logs
+func (w *redactWriter) pseudonym(value string) string {
+	mac := hmac.New(sha256.New, w.key)
+	mac.Write([]byte(value))
+	return "sha256:" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
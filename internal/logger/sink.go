@@ -0,0 +1,316 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Outputs that ship logs to an external sink
+const (
+	OutputLoki   = "loki"
+	OutputSyslog = "syslog"
+	OutputOTLP   = "otlp"
+)
+
+// SinkConfig configures the external log sinks. Events are queued and sent
+// in batches by a background goroutine; when the queue is full new events
+// are dropped rather than blocking the caller.
+type SinkConfig struct {
+	// ServiceName identifies this service in Loki labels, syslog tags and
+	// OTLP resources
+	ServiceName string `json:"service_name" env:"LOG_SERVICE_NAME" default:"eino-agent"`
+
+	// BufferSize is the number of events queued before dropping
+	BufferSize int `json:"buffer_size" env:"LOG_SINK_BUFFER" default:"10000"`
+
+	// BatchSize and FlushInterval bound how long events wait in a batch
+	BatchSize     int           `json:"batch_size" env:"LOG_SINK_BATCH_SIZE" default:"500"`
+	FlushInterval time.Duration `json:"flush_interval" env:"LOG_SINK_FLUSH_INTERVAL" default:"2s"`
+
+	// Loki push API, e.g. http://loki:3100
+	LokiURL      string            `json:"loki_url" env:"LOKI_URL"`
+	LokiLabels   map[string]string `json:"loki_labels" env:"LOKI_LABELS"`
+	LokiTenantID string            `json:"loki_tenant_id" env:"LOKI_TENANT_ID"`
+	LokiUsername string            `json:"loki_username" env:"LOKI_USERNAME"`
+	LokiPassword string            `json:"-" env:"LOKI_PASSWORD"`
+
+	// Syslog server; an empty address uses the local syslog daemon
+	SyslogNetwork string `json:"syslog_network" env:"SYSLOG_NETWORK"`
+	SyslogAddress string `json:"syslog_address" env:"SYSLOG_ADDRESS"`
+
+	// OTLP/HTTP collector, e.g. http://otel-collector:4318
+	OTLPEndpoint string            `json:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTLPHeaders  map[string]string `json:"-" env:"OTEL_EXPORTER_OTLP_HEADERS"`
+}
+
+// SinkStats counts events handled by the external sink since startup
+type SinkStats struct {
+	Output  string `json:"output"`
+	Written int64  `json:"written"`
+	Dropped int64  `json:"dropped"`
+	Failed  int64  `json:"failed"`
+	Queued  int    `json:"queued"`
+}
+
+// sinkEvent is a parsed log line
+type sinkEvent struct {
+	time    time.Time
+	level   zerolog.Level
+	message string
+	fields  map[string]interface{}
+	line    []byte
+}
+
+// batchSink delivers batches of events to an external system
+type batchSink interface {
+	send(events []sinkEvent) error
+	close() error
+}
+
+// asyncWriter queues log lines for a batchSink
+type asyncWriter struct {
+	output        string
+	sink          batchSink
+	events        chan []byte
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	written, dropped, failed atomic.Int64
+	lastError                atomic.Int64
+}
+
+// sinkCloseTimeout bounds how long Close waits for queued events
+const sinkCloseTimeout = 5 * time.Second
+
+// activeSink is the writer installed by Init, if any
+var activeSink atomic.Pointer[asyncWriter]
+
+// newSinkWriter creates the writer for an external output
+func newSinkWriter(output string, cfg SinkConfig) (*asyncWriter, error) {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "eino-agent"
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 10000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 2 * time.Second
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var sink batchSink
+	var err error
+	switch output {
+	case OutputLoki:
+		sink, err = newLokiSink(client, cfg)
+	case OutputSyslog:
+		sink, err = newSyslogSink(cfg)
+	case OutputOTLP:
+		sink, err = newOTLPSink(client, cfg)
+	default:
+		err = fmt.Errorf("unknown log output: %s", output)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	w := &asyncWriter{
+		output:        output,
+		sink:          sink,
+		events:        make(chan []byte, cfg.BufferSize),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		done:          make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Write queues a copy of p, dropping it when the queue is full
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		w.dropped.Add(1)
+		return len(p), nil
+	}
+
+	line := make([]byte, len(p))
+	copy(line, p)
+	select {
+	case w.events <- line:
+	default:
+		w.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]sinkEvent, 0, w.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.sink.send(batch); err != nil {
+			w.failed.Add(int64(len(batch)))
+			w.reportError(err)
+		} else {
+			w.written.Add(int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case line, ok := <-w.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, parseSinkEvent(line))
+			if len(batch) >= w.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// reportError writes sink failures to stderr at most once a minute, since
+// they can't be logged through the failing sink
+func (w *asyncWriter) reportError(err error) {
+	now := time.Now().Unix()
+	last := w.lastError.Load()
+	if now-last < 60 || !w.lastError.CompareAndSwap(last, now) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "logger: failed to ship logs to %s: %v\n", w.output, err)
+}
+
+// Close flushes queued events. zerolog calls it before exiting on Fatal.
+func (w *asyncWriter) Close() error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.events)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+	case <-time.After(sinkCloseTimeout):
+		return fmt.Errorf("timed out flushing logs to %s", w.output)
+	}
+	return w.sink.close()
+}
+
+func (w *asyncWriter) stats() SinkStats {
+	return SinkStats{
+		Output:  w.output,
+		Written: w.written.Load(),
+		Dropped: w.dropped.Load(),
+		Failed:  w.failed.Load(),
+		Queued:  len(w.events),
+	}
+}
+
+// GetSinkStats returns the counters of the external sink, or nil when logs
+// are written locally
+func GetSinkStats() *SinkStats {
+	w := activeSink.Load()
+	if w == nil {
+		return nil
+	}
+	stats := w.stats()
+	return &stats
+}
+
+// Close flushes logs queued for an external sink. Call it before exiting.
+func Close() error {
+	w := activeSink.Swap(nil)
+	if w == nil {
+		return nil
+	}
+	return w.Close()
+}
+
+// parseSinkEvent extracts the time, level and message of a JSON log line.
+// Lines that aren't JSON are kept as the message.
+func parseSinkEvent(line []byte) sinkEvent {
+	line = bytes.TrimRight(line, "\n")
+	event := sinkEvent{time: time.Now(), level: zerolog.NoLevel, line: line}
+
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&event.fields); err != nil {
+		event.message = string(line)
+		return event
+	}
+
+	if s, ok := event.fields[zerolog.LevelFieldName].(string); ok {
+		if level, err := zerolog.ParseLevel(s); err == nil {
+			event.level = level
+		}
+	}
+	if s, ok := event.fields[zerolog.TimestampFieldName].(string); ok {
+		if t, err := time.Parse(zerolog.TimeFieldFormat, s); err == nil {
+			event.time = t
+		}
+	}
+	if s, ok := event.fields[zerolog.MessageFieldName].(string); ok {
+		event.message = s
+	}
+	return event
+}
+
+// postJSON sends body to url and treats any non-2xx status as an error
+func postJSON(client *http.Client, url string, body interface{}, setHeaders func(*http.Request)) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if setHeaders != nil {
+		setHeaders(req)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// lokiSink pushes events to the Loki push API, one stream per level
+type lokiSink struct {
+	client *http.Client
+	url    string
+	labels map[string]string
+	cfg    SinkConfig
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func newLokiSink(client *http.Client, cfg SinkConfig) (*lokiSink, error) {
+	if cfg.LokiURL == "" {
+		return nil, errors.New("LOKI_URL is required for loki output")
+	}
+
+	labels := map[string]string{"app": cfg.ServiceName}
+	for name, value := range cfg.LokiLabels {
+		labels[name] = value
+	}
+
+	return &lokiSink{
+		client: client,
+		url:    strings.TrimSuffix(cfg.LokiURL, "/") + "/loki/api/v1/push",
+		labels: labels,
+		cfg:    cfg,
+	}, nil
+}
+
+func (s *lokiSink) send(events []sinkEvent) error {
+	streams := make(map[string]*lokiStream)
+	var order []string
+	for _, event := range events {
+		level := event.level.String()
+		if level == "" {
+			level = "unknown"
+		}
+
+		stream, ok := streams[level]
+		if !ok {
+			labels := make(map[string]string, len(s.labels)+1)
+			for name, value := range s.labels {
+				labels[name] = value
+			}
+			labels["level"] = level
+			stream = &lokiStream{Stream: labels}
+			streams[level] = stream
+			order = append(order, level)
+		}
+		stream.Values = append(stream.Values, [2]string{
+			strconv.FormatInt(event.time.UnixNano(), 10),
+			string(event.line),
+		})
+	}
+
+	push := lokiPush{Streams: make([]lokiStream, 0, len(order))}
+	for _, level := range order {
+		push.Streams = append(push.Streams, *streams[level])
+	}
+
+	return postJSON(s.client, s.url, push, func(req *http.Request) {
+		if s.cfg.LokiTenantID != "" {
+			req.Header.Set("X-Scope-OrgID", s.cfg.LokiTenantID)
+		}
+		if s.cfg.LokiUsername != "" {
+			req.SetBasicAuth(s.cfg.LokiUsername, s.cfg.LokiPassword)
+		}
+	})
+}
+
+func (s *lokiSink) close() error {
+	return nil
+}
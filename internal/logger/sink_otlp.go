@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// otlpSink exports events to an OpenTelemetry collector using OTLP/HTTP
+// with the JSON encoding
+type otlpSink struct {
+	client   *http.Client
+	url      string
+	headers  map[string]string
+	resource otlpResource
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string          `json:"timeUnixNano"`
+	SeverityNumber int             `json:"severityNumber,omitempty"`
+	SeverityText   string          `json:"severityText,omitempty"`
+	Body           otlpValue       `json:"body"`
+	Attributes     []otlpAttribute `json:"attributes,omitempty"`
+	TraceID        string          `json:"traceId,omitempty"`
+	SpanID         string          `json:"spanId,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      map[string]string `json:"scope"`
+	LogRecords []otlpLogRecord   `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpExport struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+// otlpSeverity maps zerolog levels to OTLP severity numbers
+var otlpSeverity = map[zerolog.Level]int{
+	zerolog.TraceLevel: 1,
+	zerolog.DebugLevel: 5,
+	zerolog.InfoLevel:  9,
+	zerolog.WarnLevel:  13,
+	zerolog.ErrorLevel: 17,
+	zerolog.FatalLevel: 21,
+	zerolog.PanicLevel: 21,
+}
+
+func newOTLPSink(client *http.Client, cfg SinkConfig) (*otlpSink, error) {
+	if cfg.OTLPEndpoint == "" {
+		return nil, errors.New("OTEL_EXPORTER_OTLP_ENDPOINT is required for otlp output")
+	}
+
+	resource := otlpResource{Attributes: []otlpAttribute{
+		stringAttribute("service.name", cfg.ServiceName),
+	}}
+	if hostname, err := os.Hostname(); err == nil {
+		resource.Attributes = append(resource.Attributes, stringAttribute("host.name", hostname))
+	}
+
+	return &otlpSink{
+		client:   client,
+		url:      strings.TrimSuffix(cfg.OTLPEndpoint, "/") + "/v1/logs",
+		headers:  cfg.OTLPHeaders,
+		resource: resource,
+	}, nil
+}
+
+func (s *otlpSink) send(events []sinkEvent) error {
+	records := make([]otlpLogRecord, 0, len(events))
+	for _, event := range events {
+		message := event.message
+		record := otlpLogRecord{
+			TimeUnixNano:   strconv.FormatInt(event.time.UnixNano(), 10),
+			SeverityNumber: otlpSeverity[event.level],
+			SeverityText:   strings.ToUpper(event.level.String()),
+			Body:           otlpValue{StringValue: &message},
+		}
+
+		for key, value := range event.fields {
+			switch key {
+			case zerolog.LevelFieldName, zerolog.TimestampFieldName, zerolog.MessageFieldName:
+				continue
+			case "trace_id":
+				if id, ok := value.(string); ok && isHexID(id, 32) {
+					record.TraceID = id
+				}
+			case "span_id":
+				if id, ok := value.(string); ok && isHexID(id, 16) {
+					record.SpanID = id
+				}
+			}
+			record.Attributes = append(record.Attributes, otlpAttribute{Key: key, Value: toOTLPValue(value)})
+		}
+		records = append(records, record)
+	}
+
+	export := otlpExport{ResourceLogs: []otlpResourceLogs{{
+		Resource: s.resource,
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      map[string]string{"name": "github.com/shivaluma/eino-agent/internal/logger"},
+			LogRecords: records,
+		}},
+	}}}
+
+	return postJSON(s.client, s.url, export, func(req *http.Request) {
+		for name, value := range s.headers {
+			req.Header.Set(name, value)
+		}
+	})
+}
+
+func (s *otlpSink) close() error {
+	return nil
+}
+
+// isHexID reports whether id is a W3C trace or span ID, which collectors
+// require in those fields
+func isHexID(id string, length int) bool {
+	if len(id) != length {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+// toOTLPValue keeps strings, booleans and numbers typed and encodes
+// anything else as JSON
+func toOTLPValue(value interface{}) otlpValue {
+	switch v := value.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			s := v.String()
+			return otlpValue{IntValue: &s}
+		}
+		if f, err := v.Float64(); err == nil {
+			return otlpValue{DoubleValue: &f}
+		}
+	}
+	data, _ := json.Marshal(value)
+	s := string(data)
+	return otlpValue{StringValue: &s}
+}
@@ -0,0 +1,52 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"log/syslog"
+
+	"github.com/rs/zerolog"
+)
+
+// syslogSink writes each event as a JSON message with the severity of its
+// level
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func newSyslogSink(cfg SinkConfig) (*syslogSink, error) {
+	writer, err := syslog.Dial(cfg.SyslogNetwork, cfg.SyslogAddress, syslog.LOG_INFO|syslog.LOG_DAEMON, cfg.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{writer: writer}, nil
+}
+
+func (s *syslogSink) send(events []sinkEvent) error {
+	for _, event := range events {
+		var err error
+		line := string(event.line)
+		switch event.level {
+		case zerolog.TraceLevel, zerolog.DebugLevel:
+			err = s.writer.Debug(line)
+		case zerolog.WarnLevel:
+			err = s.writer.Warning(line)
+		case zerolog.ErrorLevel:
+			err = s.writer.Err(line)
+		case zerolog.FatalLevel:
+			err = s.writer.Crit(line)
+		case zerolog.PanicLevel:
+			err = s.writer.Emerg(line)
+		default:
+			err = s.writer.Info(line)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) close() error {
+	return s.writer.Close()
+}
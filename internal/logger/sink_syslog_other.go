@@ -0,0 +1,19 @@
+//go:build windows || plan9
+
+package logger
+
+import "errors"
+
+type syslogSink struct{}
+
+func newSyslogSink(cfg SinkConfig) (*syslogSink, error) {
+	return nil, errors.New("syslog output is not supported on this platform")
+}
+
+func (s *syslogSink) send(events []sinkEvent) error {
+	return nil
+}
+
+func (s *syslogSink) close() error {
+	return nil
+}
@@ -0,0 +1,187 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// protocolVersion is the MCP revision the client implements
+const protocolVersion = "2025-03-26"
+
+// clientName identifies the agent to servers
+const clientName = "eino-agent"
+
+// Client is a connection to one MCP server
+type Client struct {
+	name      string
+	transport transport
+	timeout   time.Duration
+	nextID    atomic.Int64
+
+	// ServerName and ServerVersion are reported by the server
+	ServerName    string
+	ServerVersion string
+}
+
+// Tool is a tool offered by a server
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// Connect starts or dials the server and performs the initialization
+// handshake. timeout bounds the handshake and every later call; zero means
+// no limit.
+func Connect(ctx context.Context, cfg ServerConfig, timeout time.Duration) (*Client, error) {
+	var t transport
+	if cfg.Command != "" {
+		stdio, err := startStdio(cfg)
+		if err != nil {
+			return nil, err
+		}
+		t = stdio
+	} else {
+		t = newHTTPTransport(cfg)
+	}
+
+	c := &Client{name: cfg.Name, transport: t, timeout: timeout}
+	if err := c.initialize(ctx); err != nil {
+		t.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	params := map[string]interface{}{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": clientName, "version": "1.0.0"},
+	}
+
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return err
+	}
+	c.ServerName = result.ServerInfo.Name
+	c.ServerVersion = result.ServerInfo.Version
+	c.transport.negotiated(result.ProtocolVersion)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	if err := c.transport.notify(ctx, request{JSONRPC: "2.0", Method: "notifications/initialized"}); err != nil {
+		return fmt.Errorf("mcp %s: failed to complete initialization: %w", c.name, err)
+	}
+	return nil
+}
+
+// Name is the configured name of the server
+func (c *Client) Name() string {
+	return c.name
+}
+
+// ListTools returns every tool the server offers
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+
+		var result struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool runs a tool and returns its output as text. A tool that reports
+// an error still returns its output, with isError set.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (string, bool, error) {
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	params := map[string]interface{}{"name": name, "arguments": arguments}
+
+	var result struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			Resource *struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"resource"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := c.call(ctx, "tools/call", params, &result); err != nil {
+		return "", false, err
+	}
+
+	// The model only reads text; other content is named so it knows it
+	// was there
+	parts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		switch {
+		case content.Type == "text":
+			parts = append(parts, content.Text)
+		case content.Type == "resource" && content.Resource != nil && content.Resource.Text != "":
+			parts = append(parts, content.Resource.Text)
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content omitted]", content.Type))
+		}
+	}
+	return strings.Join(parts, "\n"), result.IsError, nil
+}
+
+// Close disconnects from the server, stopping it if it is a local process
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	id := c.nextID.Add(1)
+	msg, err := c.transport.roundTrip(ctx, request{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("mcp %s: %s failed: %w", c.name, method, err)
+	}
+	if msg.Error != nil {
+		return fmt.Errorf("mcp %s: %s failed: %w", c.name, method, msg.Error)
+	}
+	if err := json.Unmarshal(msg.Result, result); err != nil {
+		return fmt.Errorf("mcp %s: invalid %s result: %w", c.name, method, err)
+	}
+	return nil
+}
+
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(ctx, c.timeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,82 @@
+// Package mcp connects to MCP (Model Context Protocol) servers and exposes
+// their tools to the AI service
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ServerConfig declares one MCP server. A server is either a local process
+// speaking over stdio (Command) or a remote streamable HTTP endpoint (URL).
+type ServerConfig struct {
+	// Name prefixes the server's tools, so it must be unique
+	Name string `json:"name" yaml:"name"`
+
+	Command string            `json:"command" yaml:"command"`
+	Args    []string          `json:"args" yaml:"args"`
+	Env     map[string]string `json:"env" yaml:"env"`
+
+	URL     string            `json:"url" yaml:"url"`
+	Headers map[string]string `json:"headers" yaml:"headers"`
+
+	// Tools limits which of the server's tools are offered to the model;
+	// empty offers all of them
+	Tools []string `json:"tools" yaml:"tools"`
+}
+
+// serversFile is the format of the servers file
+type serversFile struct {
+	Servers []ServerConfig `json:"servers" yaml:"servers"`
+}
+
+// LoadServers reads the servers declared in a YAML or JSON file. ${VAR}
+// references in env and header values are expanded from the environment so
+// the file doesn't have to contain secrets.
+func LoadServers(path string) ([]ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MCP servers file: %w", err)
+	}
+
+	var file serversFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".json":
+		err = json.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("unsupported MCP servers file format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse MCP servers file %s: %w", path, err)
+	}
+
+	names := make(map[string]bool, len(file.Servers))
+	for i := range file.Servers {
+		server := &file.Servers[i]
+		switch {
+		case server.Name == "":
+			return nil, fmt.Errorf("MCP server without a name in %s", path)
+		case names[server.Name]:
+			return nil, fmt.Errorf("duplicate MCP server %s in %s", server.Name, path)
+		case (server.Command == "") == (server.URL == ""):
+			return nil, fmt.Errorf("MCP server %s needs either a command or a url", server.Name)
+		}
+		names[server.Name] = true
+
+		for key, value := range server.Env {
+			server.Env[key] = os.ExpandEnv(value)
+		}
+		for key, value := range server.Headers {
+			server.Headers[key] = os.ExpandEnv(value)
+		}
+	}
+
+	return file.Servers, nil
+}
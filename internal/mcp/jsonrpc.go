@@ -0,0 +1,63 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// request is an outgoing JSON-RPC request, or a notification without ID
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      *int64      `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// message is any incoming JSON-RPC message: a response to one of our
+// requests, or a request or notification from the server
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// isResponse reports whether the message answers request id
+func (m *message) isResponse(id int64) bool {
+	if m.Method != "" {
+		return false
+	}
+	var got int64
+	return json.Unmarshal(m.ID, &got) == nil && got == id
+}
+
+// rpcError is a JSON-RPC error returned by the server
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// JSON-RPC error codes used in replies to server requests
+const codeMethodNotFound = -32601
+
+// transport carries JSON-RPC messages to a server
+type transport interface {
+	// roundTrip sends a request and waits for its response
+	roundTrip(ctx context.Context, req request) (*message, error)
+
+	// notify sends a notification, which has no response
+	notify(ctx context.Context, req request) error
+
+	// negotiated records the protocol version agreed during initialization
+	negotiated(version string)
+
+	close() error
+}
@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+// Manager holds the connections to the configured servers
+type Manager struct {
+	clients []*Client
+	tools   []tool.InvokableTool
+}
+
+// ConnectAll connects to every server and discovers its tools. A server
+// that can't be reached is logged and left out so the agent still starts
+// with the tools of the others.
+func ConnectAll(ctx context.Context, servers []ServerConfig, timeout time.Duration) *Manager {
+	log := logger.ModuleContext(ctx, "mcp")
+	m := &Manager{}
+
+	for _, server := range servers {
+		client, err := Connect(ctx, server, timeout)
+		if err != nil {
+			log.Error().Err(err).Str("server", server.Name).Msg("Failed to connect to MCP server")
+			continue
+		}
+
+		tools, err := client.ListTools(ctx)
+		if err != nil {
+			log.Error().Err(err).Str("server", server.Name).Msg("Failed to list MCP server tools")
+			client.Close()
+			continue
+		}
+
+		allowed := make(map[string]bool, len(server.Tools))
+		for _, name := range server.Tools {
+			allowed[name] = true
+		}
+
+		count := 0
+		for _, t := range tools {
+			if len(allowed) > 0 && !allowed[t.Name] {
+				continue
+			}
+			m.tools = append(m.tools, newServerTool(client, t))
+			count++
+		}
+		m.clients = append(m.clients, client)
+
+		log.Info().
+			Str("server", server.Name).
+			Str("server_name", client.ServerName).
+			Str("server_version", client.ServerVersion).
+			Int("tools", count).
+			Msg("Connected to MCP server")
+	}
+
+	return m
+}
+
+// Tools returns the tools of all connected servers
+func (m *Manager) Tools() []tool.InvokableTool {
+	return m.tools
+}
+
+// Close disconnects from every server
+func (m *Manager) Close() error {
+	var errs []error
+	for _, client := range m.clients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,204 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+// maxMessageSize bounds a single message from a stdio server
+const maxMessageSize = 16 << 20
+
+// stopTimeout is how long a stdio server gets to exit after its input is
+// closed before it is killed
+const stopTimeout = 5 * time.Second
+
+// stdioTransport runs the server as a child process exchanging
+// newline-delimited JSON-RPC messages over stdin and stdout
+type stdioTransport struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[int64]chan *message
+
+	// done is closed when the server's output ends, with err set
+	done chan struct{}
+	err  error
+}
+
+func startStdio(cfg ServerConfig) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range cfg.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", cfg.Command, err)
+	}
+
+	t := &stdioTransport{
+		name:    cfg.Name,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go t.read(stdout)
+	go t.logStderr(stderr)
+	return t, nil
+}
+
+// read dispatches the server's messages until its output ends
+func (t *stdioTransport) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			logger.Module("mcp").Warn().Err(err).Str("server", t.name).Msg("Ignoring malformed message from MCP server")
+			continue
+		}
+
+		if msg.Method != "" {
+			t.answer(&msg)
+			continue
+		}
+
+		var id int64
+		if err := json.Unmarshal(msg.ID, &id); err != nil {
+			continue
+		}
+		t.mu.Lock()
+		ch, ok := t.pending[id]
+		delete(t.pending, id)
+		t.mu.Unlock()
+		if ok {
+			ch <- &msg
+		}
+	}
+
+	t.err = scanner.Err()
+	if t.err == nil {
+		t.err = errors.New("server closed its output")
+	}
+	close(t.done)
+}
+
+// answer replies to requests from the server. Only ping is supported; the
+// client declares no capabilities that would make the server ask for more.
+func (t *stdioTransport) answer(msg *message) {
+	if len(msg.ID) == 0 {
+		return // notification
+	}
+
+	reply := map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID}
+	if msg.Method == "ping" {
+		reply["result"] = struct{}{}
+	} else {
+		reply["error"] = rpcError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+	if err := t.write(reply); err != nil {
+		logger.Module("mcp").Warn().Err(err).Str("server", t.name).Msg("Failed to answer MCP server request")
+	}
+}
+
+// logStderr forwards the server's diagnostics to the log
+func (t *stdioTransport) logStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		logger.Module("mcp").Debug().Str("server", t.name).Msg(scanner.Text())
+	}
+}
+
+func (t *stdioTransport) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, req request) (*message, error) {
+	ch := make(chan *message, 1)
+	t.mu.Lock()
+	t.pending[*req.ID] = ch
+	t.mu.Unlock()
+
+	forget := func() {
+		t.mu.Lock()
+		delete(t.pending, *req.ID)
+		t.mu.Unlock()
+	}
+
+	if err := t.write(req); err != nil {
+		forget()
+		return nil, err
+	}
+
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-t.done:
+		forget()
+		return nil, t.err
+	case <-ctx.Done():
+		forget()
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(ctx context.Context, req request) error {
+	return t.write(req)
+}
+
+func (t *stdioTransport) negotiated(version string) {}
+
+// close ends the server by closing its input, killing it if it doesn't exit
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+
+	// Wait must not run before the output has been read
+	exited := make(chan error, 1)
+	go func() {
+		<-t.done
+		exited <- t.cmd.Wait()
+	}()
+
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(stopTimeout):
+		return t.cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// maxToolNameLength is the longest tool name model APIs accept
+const maxToolNameLength = 64
+
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// serverTool exposes a server's tool as an eino tool
+type serverTool struct {
+	client *Client
+	tool   Tool
+	info   *schema.ToolInfo
+}
+
+var _ tool.InvokableTool = (*serverTool)(nil)
+
+func newServerTool(client *Client, t Tool) *serverTool {
+	params := &openapi3.Schema{Type: openapi3.TypeObject}
+	if len(t.InputSchema) > 0 {
+		var parsed openapi3.Schema
+		if err := json.Unmarshal(t.InputSchema, &parsed); err == nil && parsed.Type == openapi3.TypeObject {
+			params = &parsed
+		}
+	}
+
+	return &serverTool{
+		client: client,
+		tool:   t,
+		info: &schema.ToolInfo{
+			Name:        toolName(client.Name(), t.Name),
+			Desc:        t.Description,
+			ParamsOneOf: schema.NewParamsOneOfByOpenAPIV3(params),
+		},
+	}
+}
+
+// toolName prefixes a tool with its server so tools of different servers
+// can't clash, keeping to the characters model APIs allow
+func toolName(server, name string) string {
+	qualified := invalidToolNameChars.ReplaceAllString(server+"_"+name, "_")
+	if len(qualified) > maxToolNameLength {
+		qualified = qualified[:maxToolNameLength]
+	}
+	return qualified
+}
+
+func (t *serverTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
+}
+
+func (t *serverTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	output, isError, err := t.client.CallTool(ctx, t.tool.Name, json.RawMessage(argumentsInJSON))
+	if err != nil {
+		return "", err
+	}
+	if isError {
+		return "", errors.New(output)
+	}
+	return output, nil
+}
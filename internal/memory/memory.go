@@ -0,0 +1,130 @@
+// Package memory gives the agent long-term memory of its users: facts
+// stated in their messages are extracted after each reply and added to the
+// prompts of later conversations.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/queue"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// TaskLearn is the queued task that learns memories from a message
+const TaskLearn = "learn_memories"
+
+// learnTask is the payload of a TaskLearn task
+type learnTask struct {
+	UserID         uuid.UUID `json:"user_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	Message        string    `json:"message"`
+}
+
+// Config controls the memory store
+type Config struct {
+	// Enabled turns extraction and recall on
+	Enabled bool
+
+	// MaxPerUser bounds how many memories a user can have; extraction
+	// stops adding memories once the limit is reached
+	MaxPerUser int
+}
+
+// Store recalls and learns memories. Failures to recall or queue learning
+// are logged rather than returned: a reply is never held up or lost
+// because of memory. Failed learning is retried by the task queue.
+type Store struct {
+	repo      *repository.MemoryRepository
+	aiService ai.Service
+	tasks     queue.Queue
+	config    Config
+}
+
+func NewStore(repo *repository.MemoryRepository, aiService ai.Service, tasks queue.Queue, config Config) *Store {
+	return &Store{repo: repo, aiService: aiService, tasks: tasks, config: config}
+}
+
+// Recall returns the user's memories for a prompt
+func (s *Store) Recall(ctx context.Context, userID uuid.UUID) []string {
+	if !s.config.Enabled {
+		return nil
+	}
+
+	memories, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		logger.ModuleContext(ctx, "memory").Warn().Err(err).Str("user_id", userID.String()).Msg("Failed to load memories")
+		return nil
+	}
+
+	contents := make([]string, len(memories))
+	for i, m := range memories {
+		contents[i] = m.Content
+	}
+	return contents
+}
+
+// Learn queues extracting new facts from a user's message, which calls the
+// model, so it doesn't hold up the reply
+func (s *Store) Learn(ctx context.Context, userID, conversationID uuid.UUID, message string) {
+	if !s.config.Enabled {
+		return
+	}
+
+	err := s.tasks.Enqueue(ctx, TaskLearn, learnTask{UserID: userID, ConversationID: conversationID, Message: message})
+	if err != nil {
+		logger.ModuleContext(ctx, "memory").Warn().Err(err).Str("user_id", userID.String()).Msg("Failed to queue learning memories")
+	}
+}
+
+// HandleLearn runs a TaskLearn task: it extracts new facts from the
+// message and stores them
+func (s *Store) HandleLearn(ctx context.Context, payload json.RawMessage) error {
+	if !s.config.Enabled {
+		return nil
+	}
+
+	var task learnTask
+	if err := json.Unmarshal(payload, &task); err != nil {
+		return fmt.Errorf("invalid learn task: %w", err)
+	}
+
+	known, err := s.repo.ListByUser(ctx, task.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to load memories: %w", err)
+	}
+	room := s.config.MaxPerUser - len(known)
+	if room <= 0 {
+		return nil
+	}
+
+	contents := make([]string, len(known))
+	for i, m := range known {
+		contents[i] = m.Content
+	}
+	facts, err := s.aiService.ExtractMemories(ctx, task.Message, contents)
+	if err != nil {
+		return fmt.Errorf("failed to extract memories: %w", err)
+	}
+	if len(facts) > room {
+		facts = facts[:room]
+	}
+
+	added, err := s.repo.Add(ctx, task.UserID, &task.ConversationID, facts)
+	if err != nil {
+		return fmt.Errorf("failed to store memories: %w", err)
+	}
+	if added > 0 {
+		logger.ModuleContext(ctx, "memory").Debug().
+			Str("user_id", task.UserID.String()).
+			Str("conversation_id", task.ConversationID.String()).
+			Int("added", added).
+			Msg("Learned user memories")
+	}
+	return nil
+}
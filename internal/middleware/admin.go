@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminMiddleware restricts access to users flagged as administrators.
+// Must be registered after AuthMiddleware.
+func AdminMiddleware(authSvc *auth.Service, userRepo *repository.UserRepository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userClaims, err := authSvc.GetUserClaimsFromContext(c.Request().Context())
+			if err != nil {
+				return apierror.Unauthorized("Unauthorized")
+			}
+
+			user, err := userRepo.GetByID(c.Request().Context(), userClaims.UserID)
+			if err != nil {
+				return apierror.Internal("Internal server error")
+			}
+			if user == nil || !user.IsAdmin {
+				return apierror.Forbidden("Admin access required")
+			}
+
+			return next(c)
+		}
+	}
+}
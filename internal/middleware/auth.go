@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/shivaluma/eino-agent/internal/apierror"
 	"github.com/shivaluma/eino-agent/internal/auth"
 
 	"github.com/labstack/echo/v4"
@@ -20,71 +21,47 @@ func AuthMiddleware(authSvc *auth.Service) echo.MiddlewareFunc {
 			if authHeader != "" {
 				tokenParts := strings.SplitN(authHeader, " ", 2)
 				if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-					return c.JSON(http.StatusUnauthorized, map[string]string{
-						"error": "Invalid authorization header format",
-					})
+					return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid authorization header format")
 				}
 				tokenString = tokenParts[1]
 			} else {
 				// If no Authorization header, try to get token from cookie
 				cookie, err := c.Cookie("access_token")
 				if err != nil || cookie.Value == "" {
-					return c.JSON(http.StatusUnauthorized, map[string]string{
-						"error": "Authentication required",
-					})
+					return apierror.Unauthorized("Authentication required")
 				}
 				tokenString = cookie.Value
 			}
 
 			token, err := authSvc.ValidateAccessToken(tokenString)
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "Invalid or expired token",
-				})
+				return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired token")
+			}
+
+			revoked, err := authSvc.IsAccessTokenRevoked(c.Request().Context(), token)
+			if err != nil {
+				return apierror.Internal("Internal server error")
+			}
+			if revoked {
+				return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Token has been revoked")
 			}
 
 			userID, err := authSvc.ExtractUserIDFromToken(token)
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "Invalid token claims",
-				})
+				return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims")
 			}
 
 			username, err := authSvc.ExtractUsernameFromToken(token)
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "Invalid token claims",
-				})
+				return apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims")
 			}
 
 			ctx := context.WithValue(c.Request().Context(), "user_id", userID)
 			ctx = context.WithValue(ctx, "username", username)
+			ctx = context.WithValue(ctx, "access_token", token)
 			c.SetRequest(c.Request().WithContext(ctx))
 
 			return next(c)
 		}
 	}
 }
-
-func CORSMiddleware() echo.MiddlewareFunc {
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			// In production, consider restricting origin. For now, reflect request origin for cookies.
-			origin := c.Request().Header.Get("Origin")
-			if origin == "" {
-				origin = "*"
-			}
-			c.Response().Header().Set("Access-Control-Allow-Origin", origin)
-			c.Response().Header().Set("Vary", "Origin")
-			c.Response().Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			c.Response().Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			c.Response().Header().Set("Access-Control-Allow-Credentials", "true")
-
-			if c.Request().Method == "OPTIONS" {
-				return c.NoContent(http.StatusOK)
-			}
-
-			return next(c)
-		}
-	}
-}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/shivaluma/eino-agent/internal/apierror"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BodyLimitMiddleware rejects request bodies larger than limit bytes with
+// 413. A body announced as too large is rejected before it is read; one
+// that turns out too large fails when the handler reads past the limit,
+// whatever error the handler returns for it. It can be stacked, the
+// smallest limit wins. Uploads and imports enforce their own, larger limit.
+func BodyLimitMiddleware(limit int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Body == nil || req.Body == http.NoBody || uploadRequest(c) {
+				return next(c)
+			}
+
+			tooLarge := apierror.PayloadTooLarge(fmt.Sprintf("Request body exceeds the %d byte limit", limit)).
+				WithDetails(map[string]int64{"max_bytes": limit})
+			if req.ContentLength > limit {
+				return tooLarge
+			}
+
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(c.Response(), req.Body, limit), limit: limit}
+			req.Body = body
+
+			err := next(c)
+			if err != nil && body.exceeded {
+				return tooLarge
+			}
+			return err
+		}
+	}
+}
+
+// uploadRequest reports whether a route reads its body with its own limit
+func uploadRequest(c echo.Context) bool {
+	path := c.Path()
+	return c.Request().Method == http.MethodPost &&
+		(strings.HasSuffix(path, "/uploads") || strings.HasSuffix(path, "/avatar") || strings.HasSuffix(path, "/import"))
+}
+
+// limitedBody records whether a read went past its limit, rather than
+// that of another BodyLimitMiddleware
+type limitedBody struct {
+	io.ReadCloser
+	limit    int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) && maxErr.Limit == b.limit {
+		b.exceeded = true
+	}
+	return n, err
+}
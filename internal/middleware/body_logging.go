@@ -0,0 +1,244 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+const (
+	defaultBodyLogMaxBytes = 4096
+	redactedBody           = "[REDACTED]"
+)
+
+// bodyRedactor masks configured fields in captured bodies
+type bodyRedactor struct {
+	fields map[string]bool
+	paths  []string
+}
+
+func newBodyRedactor(cfg config.BodyLogConfig) *bodyRedactor {
+	fields := cfg.RedactFields
+	if len(fields) == 0 {
+		fields = logger.DefaultMaskFields
+	}
+
+	r := &bodyRedactor{fields: make(map[string]bool, len(fields)), paths: cfg.RedactPaths}
+	for _, field := range fields {
+		r.fields[strings.ToLower(strings.TrimSpace(field))] = true
+	}
+	return r
+}
+
+// pathRedacted reports whether bodies of path must not be logged
+func (r *bodyRedactor) pathRedacted(path string) bool {
+	for _, pattern := range r.paths {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns body with fields masked. JSON is returned as raw JSON so
+// it stays structured in the log event. Bodies that can't be parsed, such
+// as truncated ones, are only kept when no redacted field name appears in
+// them.
+func (r *bodyRedactor) redact(contentType string, body []byte) (value string, isJSON bool) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var v interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&v); err == nil {
+			if data, err := json.Marshal(r.redactValue(v)); err == nil {
+				return string(data), true
+			}
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			for name := range values {
+				if r.fields[strings.ToLower(name)] {
+					values[name] = []string{redactedBody}
+				}
+			}
+			return values.Encode(), false
+		}
+	}
+
+	lower := strings.ToLower(string(body))
+	for field := range r.fields {
+		if strings.Contains(lower, field) {
+			return redactedBody, false
+		}
+	}
+	return string(body), false
+}
+
+func (r *bodyRedactor) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for name, value := range v {
+			if r.fields[strings.ToLower(name)] {
+				v[name] = redactedBody
+			} else {
+				v[name] = r.redactValue(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = r.redactValue(value)
+		}
+	}
+	return v
+}
+
+// loggableBody reports whether a body of contentType is text worth logging;
+// uploads and other binary bodies are skipped
+func loggableBody(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" ||
+		strings.HasSuffix(mediaType, "+json") ||
+		mediaType == "application/x-www-form-urlencoded"
+}
+
+// captureWriter passes the response through and keeps its first bytes
+type captureWriter struct {
+	http.ResponseWriter
+	buf       bytes.Buffer
+	max       int
+	size      int
+	truncated bool
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	if room := w.max - w.buf.Len(); room > 0 {
+		if len(p) > room {
+			w.buf.Write(p[:room])
+			w.truncated = true
+		} else {
+			w.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		w.truncated = true
+	}
+	w.size += len(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the Flusher of the underlying
+// writer, which streaming responses need
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// BodyLoggingMiddleware logs request and response bodies at debug level on
+// the http module, up to MaxBytes each, with configured paths and fields
+// redacted. It does nothing unless enabled and http debug logs are on.
+func BodyLoggingMiddleware(cfg config.BodyLogConfig) echo.MiddlewareFunc {
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultBodyLogMaxBytes
+	}
+	redactor := newBodyRedactor(cfg)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !cfg.Enabled || !logger.Enabled("http", zerolog.DebugLevel) {
+				return next(c)
+			}
+
+			req := c.Request()
+			path := req.URL.Path
+			pathRedacted := redactor.pathRedacted(path)
+
+			// Read the start of the request body and put it back in front of
+			// the rest, so large bodies aren't buffered in full
+			var reqBody []byte
+			reqTruncated := false
+			reqType := req.Header.Get(echo.HeaderContentType)
+			if !pathRedacted && req.Body != nil && req.Body != http.NoBody && loggableBody(reqType) {
+				head, err := io.ReadAll(io.LimitReader(req.Body, int64(maxBytes)+1))
+				if err != nil {
+					return err
+				}
+				req.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(head), req.Body), req.Body}
+
+				if len(head) > maxBytes {
+					head = head[:maxBytes]
+					reqTruncated = true
+				}
+				reqBody = head
+			}
+
+			res := c.Response()
+			capture := &captureWriter{ResponseWriter: res.Writer, max: maxBytes}
+			if !pathRedacted {
+				res.Writer = capture
+			}
+
+			err := next(c)
+
+			if !pathRedacted {
+				res.Writer = capture.ResponseWriter
+			}
+
+			// Handlers may have added the user to the request context
+			event := logger.ModuleContext(c.Request().Context(), "http").Debug().
+				Str("method", req.Method).
+				Str("path", path).
+				Int("status", res.Status)
+
+			if pathRedacted {
+				event.Str("request_body", redactedBody).Str("response_body", redactedBody)
+				event.Msg("Request bodies")
+				return err
+			}
+
+			if len(reqBody) > 0 {
+				logBody(event, "request_body", redactor, reqType, reqBody)
+				event.Bool("request_truncated", reqTruncated)
+			}
+
+			resType := res.Header().Get(echo.HeaderContentType)
+			if capture.size > 0 && loggableBody(resType) {
+				logBody(event, "response_body", redactor, resType, capture.buf.Bytes())
+				event.Bool("response_truncated", capture.truncated)
+			}
+			event.Int("response_size", capture.size).Msg("Request bodies")
+
+			return err
+		}
+	}
+}
+
+func logBody(event *zerolog.Event, key string, redactor *bodyRedactor, contentType string, body []byte) {
+	value, isJSON := redactor.redact(contentType, body)
+	if isJSON {
+		event.RawJSON(key, []byte(value))
+	} else {
+		event.Str(key, value)
+	}
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shivaluma/eino-agent/config"
+
+	"github.com/andybalholm/brotli"
+	"github.com/labstack/echo/v4"
+)
+
+// Content encodings, Brotli preferred when a client accepts both
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// encoder is the part of gzip.Writer and brotli.Writer the middleware uses
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// CompressMiddleware compresses responses with Brotli or gzip, whichever the
+// client prefers. Event streams are left alone: compression would hold
+// events back until a block fills, and proxies buffer compressed streams.
+func CompressMiddleware(cfg config.CompressConfig) echo.MiddlewareFunc {
+	if !cfg.Enabled {
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+
+	pools := map[string]*sync.Pool{
+		encodingBrotli: {New: func() interface{} {
+			return brotli.NewWriterLevel(io.Discard, cfg.BrotliLevel)
+		}},
+		encodingGzip: {New: func() interface{} {
+			// The level is validated at startup
+			w, _ := gzip.NewWriterLevel(io.Discard, cfg.Level)
+			return w
+		}},
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if streamRequest(c) {
+				return next(c)
+			}
+
+			res := c.Response()
+			res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+			encoding := negotiateEncoding(c.Request().Header.Get(echo.HeaderAcceptEncoding))
+			if encoding == "" {
+				return next(c)
+			}
+
+			pool := pools[encoding]
+			enc := pool.Get().(encoder)
+			enc.Reset(res.Writer)
+			cw := &compressWriter{ResponseWriter: res.Writer, encoder: enc, encoding: encoding, minSize: cfg.MinSize}
+			res.Writer = cw
+			defer func() {
+				res.Writer = cw.ResponseWriter
+				cw.finish()
+				enc.Reset(io.Discard)
+				pool.Put(enc)
+			}()
+
+			return next(c)
+		}
+	}
+}
+
+// negotiateEncoding picks the encoding to answer an Accept-Encoding header
+// with, or "" to send the response as is
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != encodingBrotli && name != encodingGzip {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > bestQ || (q > 0 && q == bestQ && name == encodingBrotli) {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter holds the status and the start of the body back until the
+// body reaches minSize, so short responses are sent uncompressed
+type compressWriter struct {
+	http.ResponseWriter
+	encoder     encoder
+	encoding    string
+	minSize     int
+	buf         []byte
+	code        int
+	compressing bool
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	// The compressed length isn't known yet
+	w.Header().Del(echo.HeaderContentLength)
+	w.code = code
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.Header().Get(echo.HeaderContentType) == "" {
+		w.Header().Set(echo.HeaderContentType, http.DetectContentType(p))
+	}
+	if w.compressing {
+		return w.encoder.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) < w.minSize {
+		return len(p), nil
+	}
+	if err := w.start(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start sends the header with the encoding and the held back body
+func (w *compressWriter) start() error {
+	w.compressing = true
+	w.Header().Set(echo.HeaderContentEncoding, w.encoding)
+	w.writeHeader()
+	_, err := w.encoder.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+func (w *compressWriter) writeHeader() {
+	if w.code != 0 {
+		w.ResponseWriter.WriteHeader(w.code)
+	}
+}
+
+// Flush compresses whatever is held back, since no more may come
+func (w *compressWriter) Flush() {
+	if !w.compressing {
+		_ = w.start()
+	}
+	_ = w.encoder.Flush()
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// finish ends the compressed stream, or sends a response that stayed under
+// minSize as it is
+func (w *compressWriter) finish() {
+	if w.compressing {
+		_ = w.encoder.Close()
+		return
+	}
+	w.writeHeader()
+	if len(w.buf) > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf)
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// streamRequest reports whether a request may be answered with an event
+// stream. POST /messages streams depending on its body, which isn't read
+// yet, so it is never compressed.
+func streamRequest(c echo.Context) bool {
+	req := c.Request()
+	if strings.Contains(req.Header.Get(echo.HeaderAccept), "text/event-stream") {
+		return true
+	}
+
+	path := c.Path()
+	return strings.HasSuffix(path, "/events") ||
+		strings.HasSuffix(path, "/streams/:id") ||
+		(req.Method == http.MethodPost && strings.HasSuffix(path, "/messages"))
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+
+	"github.com/labstack/echo/v4"
+)
+
+// originMatcher checks origins against the configured allowlist
+type originMatcher struct {
+	any   bool
+	exact map[string]bool
+
+	// wildcards are "https://*.example.com" patterns split around the "*"
+	wildcards []wildcardOrigin
+}
+
+type wildcardOrigin struct {
+	prefix, suffix string
+}
+
+func newOriginMatcher(origins []string) *originMatcher {
+	m := &originMatcher{exact: make(map[string]bool)}
+	for _, origin := range origins {
+		origin = normalizeOrigin(origin)
+		switch {
+		case origin == "*":
+			m.any = true
+		case strings.Contains(origin, "://*."):
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			m.wildcards = append(m.wildcards, wildcardOrigin{prefix: prefix, suffix: suffix})
+		default:
+			m.exact[origin] = true
+		}
+	}
+	return m
+}
+
+// allowed reports whether origin is on the allowlist. Wildcards match one or
+// more subdomain labels but not the bare domain.
+func (m *originMatcher) allowed(origin string) bool {
+	if m.any {
+		return true
+	}
+
+	origin = normalizeOrigin(origin)
+	if m.exact[origin] {
+		return true
+	}
+	for _, w := range m.wildcards {
+		if !strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+			continue
+		}
+		if len(origin) <= len(w.prefix)+len(w.suffix) {
+			continue
+		}
+		labels := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+		if !strings.ContainsAny(labels, ":/@") && !strings.HasPrefix(labels, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+}
+
+// CORSMiddleware answers preflight requests and adds CORS headers for
+// allowlisted origins. With credentials enabled, cross-origin requests from
+// other origins are rejected outright so cookies can't be used by them.
+// Public embeds are open to every origin, without credentials.
+func CORSMiddleware(cfg config.CORSConfig) echo.MiddlewareFunc {
+	matcher := newOriginMatcher(cfg.AllowedOrigins)
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			res := c.Response()
+			res.Header().Add("Vary", "Origin")
+
+			origin := req.Header.Get("Origin")
+			if origin == "" || isSameOrigin(c, origin) {
+				return next(c)
+			}
+
+			preflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+
+			// Embeds are read from any site, never with cookies
+			if embedRequest(c) {
+				res.Header().Set("Access-Control-Allow-Origin", "*")
+				if preflight {
+					res.Header().Set("Access-Control-Allow-Methods", "GET")
+					return c.NoContent(http.StatusNoContent)
+				}
+				return next(c)
+			}
+
+			if !matcher.allowed(origin) {
+				if preflight || cfg.AllowCredentials {
+					return apierror.Forbidden("Origin not allowed")
+				}
+				// Without credentials the browser enforces the missing headers
+				return next(c)
+			}
+
+			if matcher.any && !cfg.AllowCredentials {
+				res.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				res.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				res.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if preflight {
+				res.Header().Add("Vary", "Access-Control-Request-Method")
+				res.Header().Add("Vary", "Access-Control-Request-Headers")
+				res.Header().Set("Access-Control-Allow-Methods", methods)
+				res.Header().Set("Access-Control-Allow-Headers", headers)
+				if cfg.MaxAge > 0 {
+					res.Header().Set("Access-Control-Max-Age", maxAge)
+				}
+				return c.NoContent(http.StatusNoContent)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// embedRequest reports whether a route serves public embeds of shared
+// conversations
+func embedRequest(c echo.Context) bool {
+	path := c.Path()
+	return strings.HasSuffix(path, "/share/:token/embed") || strings.HasSuffix(path, "/oembed")
+}
+
+// isSameOrigin reports whether origin is the server itself. Browsers send
+// Origin on same-origin POSTs too, and those need no CORS handling.
+func isSameOrigin(c echo.Context, origin string) bool {
+	return normalizeOrigin(origin) == strings.ToLower(c.Scheme()+"://"+c.Request().Host)
+}
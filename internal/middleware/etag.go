@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ETagMiddleware answers conditional GETs. The response is buffered and tagged with a
+// hash of its body, which covers the updated_at and message fields it
+// includes, and a request whose If-None-Match matches gets 304 Not Modified
+// without the body. A handler may set its own ETag header instead. Only use
+// it on routes with small JSON responses; streams would be held back.
+func ETagMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				return next(c)
+			}
+
+			res := c.Response()
+			original := res.Writer
+			buffer := &bufferedWriter{ResponseWriter: original, status: http.StatusOK}
+			res.Writer = buffer
+			err := next(c)
+			res.Writer = original
+
+			if err != nil || !buffer.wroteHeader || buffer.status != http.StatusOK {
+				return buffer.flush(err)
+			}
+
+			etag := res.Header().Get("ETag")
+			if etag == "" {
+				sum := sha256.Sum256(buffer.body.Bytes())
+				etag = `W/"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+				res.Header().Set("ETag", etag)
+			}
+			// Responses are per user: let clients keep them but revalidate
+			res.Header().Set("Cache-Control", "private, no-cache")
+
+			if etagMatches(req.Header.Get("If-None-Match"), etag) {
+				res.Header().Del(echo.HeaderContentType)
+				res.Header().Del(echo.HeaderContentLength)
+				original.WriteHeader(http.StatusNotModified)
+				return err
+			}
+			return buffer.flush(err)
+		}
+	}
+}
+
+// etagMatches compares If-None-Match with an ETag using the weak
+// comparison RFC 9110 prescribes for it
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter holds a response back until its ETag is known
+type bufferedWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.status = status
+	w.wroteHeader = true
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// flush writes the held response, if the handler wrote one
+func (w *bufferedWriter) flush(err error) error {
+	if w.wroteHeader {
+		w.ResponseWriter.WriteHeader(w.status)
+		if _, writeErr := w.ResponseWriter.Write(w.body.Bytes()); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	return err
+}
@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"errors"
+	"net/http"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/shivaluma/eino-agent/internal/apierror"
 	"github.com/shivaluma/eino-agent/internal/logger"
 )
 
@@ -17,8 +20,9 @@ func RequestIDMiddleware() echo.MiddlewareFunc {
 				requestID = logger.GenerateRequestID()
 			}
 
-			// Add to context
+			// Add to context, continuing the caller's trace if any
 			ctx := logger.WithRequestID(c.Request().Context(), requestID)
+			ctx = logger.WithTraceparent(ctx, c.Request().Header.Get("traceparent"))
 			c.SetRequest(c.Request().WithContext(ctx))
 
 			// Add to response header
@@ -51,7 +55,7 @@ func LoggingMiddleware() echo.MiddlewareFunc {
 			status := c.Response().Status
 
 			// Log the request
-			log := logger.WithContext(c.Request().Context())
+			log := logger.ModuleContext(c.Request().Context(), "http")
 			
 			fields := map[string]interface{}{
 				"method":     c.Request().Method,
@@ -105,7 +109,22 @@ func ErrorHandlingMiddleware() echo.MiddlewareFunc {
 			}
 
 			// Get logger with context
-			log := logger.WithContext(c.Request().Context())
+			log := logger.ModuleContext(c.Request().Context(), "http")
+
+			// API errors are expected; only server errors are worth a log
+			// line beyond the request log
+			var apiErr *apierror.Error
+			if errors.As(err, &apiErr) {
+				if apiErr.Status >= http.StatusInternalServerError {
+					log.Error().
+						Err(err).
+						Str("code", apiErr.Code).
+						Str("path", c.Request().URL.Path).
+						Str("method", c.Request().Method).
+						Msg("Server error")
+				}
+				return err
+			}
 
 			// Handle Echo HTTP errors
 			if he, ok := err.(*echo.HTTPError); ok {
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// OrgMiddleware makes requests with an X-Org-ID header act in that
+// organization, which the user must be a member of. Must be registered
+// after AuthMiddleware.
+func OrgMiddleware(authSvc *auth.Service, orgRepo *repository.OrganizationRepository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(auth.OrgIDHeader)
+			if header == "" {
+				return next(c)
+			}
+
+			orgID, err := uuid.Parse(header)
+			if err != nil {
+				return apierror.BadRequest("Invalid " + auth.OrgIDHeader + " header")
+			}
+
+			userClaims, err := authSvc.GetUserClaimsFromContext(c.Request().Context())
+			if err != nil {
+				return apierror.Unauthorized("Unauthorized")
+			}
+
+			role, err := orgRepo.GetRole(c.Request().Context(), orgID, userClaims.UserID)
+			if err != nil {
+				return apierror.Internal("Failed to check organization membership")
+			}
+			if role == "" {
+				return apierror.Forbidden("Not a member of this organization")
+			}
+
+			ctx := auth.WithOrgScope(c.Request().Context(), &auth.OrgScope{OrgID: orgID, Role: role})
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
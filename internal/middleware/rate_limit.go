@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/apierror"
+	"github.com/shivaluma/eino-agent/internal/cache"
+	"github.com/shivaluma/eino-agent/internal/logger"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RateLimitMiddleware limits each client IP using fixed-window counters
+// stored in the cache. The limit is read on every request so reloaded
+// settings apply immediately.
+func RateLimitMiddleware(c cache.Cache, name string, rateLimit func() config.RateLimit) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			current := rateLimit()
+			limit, window := current.Limit, current.Window
+			bucket := time.Now().UnixNano() / int64(window)
+			key := fmt.Sprintf("ratelimit:%s:%s:%d", name, ctx.RealIP(), bucket)
+
+			count, err := c.Incr(ctx.Request().Context(), key, window)
+			if err != nil {
+				// Fail open - rate limiting must not take the API down
+				logger.WithContext(ctx.Request().Context()).Warn().
+					Err(err).
+					Str("limiter", name).
+					Msg("Rate limit check failed")
+				return next(ctx)
+			}
+
+			remaining := int64(limit) - count
+			if remaining < 0 {
+				remaining = 0
+			}
+			ctx.Response().Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			ctx.Response().Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+
+			if count > int64(limit) {
+				resetAt := time.Unix(0, (bucket+1)*int64(window))
+				ctx.Response().Header().Set("Retry-After", strconv.Itoa(int(time.Until(resetAt).Seconds())+1))
+				return apierror.TooManyRequests("Too many requests")
+			}
+
+			return next(ctx)
+		}
+	}
+}
@@ -5,8 +5,9 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
@@ -16,6 +17,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 	"github.com/shivaluma/eino-agent/config"
+	sqlfiles "github.com/shivaluma/eino-agent/migrations"
 )
 
 var logger zerolog.Logger
@@ -26,6 +28,12 @@ func init() {
 	logger = zerolog.New(output).With().Timestamp().Logger()
 }
 
+// SetLogOutput redirects migration logs, e.g. to keep stdout free for
+// machine-readable output
+func SetLogOutput(w io.Writer) {
+	logger = logger.Output(zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339})
+}
+
 // Migration represents a single database migration
 type Migration struct {
 	Version     int64
@@ -33,6 +41,9 @@ type Migration struct {
 	Content     string
 	Checksum    string
 	RollbackSQL string
+
+	// Baseline marks a migration produced by Squash
+	Baseline bool
 }
 
 // MigrationStatus represents the status of a migration
@@ -48,22 +59,55 @@ type MigrationStatus struct {
 
 // Migrator manages database migrations
 type Migrator struct {
-	db            *pgxpool.Pool
-	migrationsDir string
-	config        *config.Config
+	db     *pgxpool.Pool
+	files  fs.FS
+	config *config.Config
 }
 
-// NewMigrator creates a new migrator instance
-func NewMigrator(db *pgxpool.Pool, migrationsDir string, cfg *config.Config) *Migrator {
+// NewMigrator creates a new migrator instance reading migration files from
+// files, typically the result of Source
+func NewMigrator(db *pgxpool.Pool, files fs.FS, cfg *config.Config) *Migrator {
 	return &Migrator{
-		db:            db,
-		migrationsDir: migrationsDir,
-		config:        cfg,
+		db:     db,
+		files:  files,
+		config: cfg,
+	}
+}
+
+// Source returns the migration files to run: the directory dir when set,
+// otherwise the migrations embedded in the binary
+func Source(dir string) fs.FS {
+	if dir != "" {
+		return os.DirFS(dir)
+	}
+	return sqlfiles.FS
+}
+
+// File suffixes for paired migrations. A plain .sql file is treated as an
+// up migration.
+const (
+	upSuffix   = ".up.sql"
+	downSuffix = ".down.sql"
+)
+
+// rollbackDelimiter separates up and rollback SQL in a single migration file
+const rollbackDelimiter = "-- +rollback"
+
+// splitRollback splits single-file migration content at the rollback
+// delimiter line into up and rollback SQL
+func splitRollback(content string) (string, string) {
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == rollbackDelimiter {
+			return strings.Join(lines[:i], ""), strings.TrimSpace(strings.Join(lines[i+1:], ""))
+		}
 	}
+	return content, ""
 }
 
 // parseMigrationFilename extracts version from migration filename
-// Expected format: 001_20250108000001_initial_schema.sql
+// Expected format: 001_20250108000001_initial_schema.sql, optionally paired
+// as 001_20250108000001_initial_schema.up.sql / .down.sql
 func parseMigrationFilename(filename string) (int64, error) {
 	re := regexp.MustCompile(`^(\d+)_.*\.sql$`)
 	matches := re.FindStringSubmatch(filename)
@@ -88,12 +132,14 @@ func calculateChecksum(content string) string {
 
 // LoadMigrations loads all migration files from the migrations directory
 func (m *Migrator) LoadMigrations() ([]*Migration, error) {
-	files, err := os.ReadDir(m.migrationsDir)
+	files, err := fs.ReadDir(m.files, ".")
 	if err != nil {
 		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
 	}
 
 	var migrations []*Migration
+	downFiles := make(map[int64]string)
+	seen := make(map[int64]string)
 	for _, file := range files {
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".sql") {
 			continue
@@ -105,21 +151,52 @@ func (m *Migrator) LoadMigrations() ([]*Migration, error) {
 			continue
 		}
 
-		content, err := os.ReadFile(filepath.Join(m.migrationsDir, file.Name()))
+		content, err := fs.ReadFile(m.files, file.Name())
 		if err != nil {
 			return nil, fmt.Errorf("failed to read migration file %s: %w", file.Name(), err)
 		}
 
+		if strings.HasSuffix(file.Name(), downSuffix) {
+			downFiles[version] = string(content)
+			continue
+		}
+
+		if other, exists := seen[version]; exists {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, file.Name())
+		}
+		seen[version] = file.Name()
+
+		// The checksum covers the whole file so that existing single-file
+		// migrations keep their recorded checksums
+		up, rollback := splitRollback(string(content))
 		migration := &Migration{
-			Version:  version,
-			Filename: file.Name(),
-			Content:  string(content),
-			Checksum: calculateChecksum(string(content)),
+			Version:     version,
+			Filename:    file.Name(),
+			Content:     up,
+			Checksum:    calculateChecksum(string(content)),
+			RollbackSQL: rollback,
+			Baseline:    isBaseline(string(content)),
 		}
 
 		migrations = append(migrations, migration)
 	}
 
+	for _, migration := range migrations {
+		down, ok := downFiles[migration.Version]
+		if !ok {
+			continue
+		}
+		if migration.RollbackSQL != "" {
+			return nil, fmt.Errorf("migration %d has both a %s section and a down file", migration.Version, rollbackDelimiter)
+		}
+		migration.RollbackSQL = strings.TrimSpace(down)
+	}
+	for version := range downFiles {
+		if _, ok := seen[version]; !ok {
+			return nil, fmt.Errorf("down migration %d has no matching up migration", version)
+		}
+	}
+
 	// Sort migrations by version
 	sort.Slice(migrations, func(i, j int) bool {
 		return migrations[i].Version < migrations[j].Version
@@ -146,8 +223,7 @@ func (m *Migrator) InitializeMigrationSystem(ctx context.Context) error {
 
 	if !exists {
 		// Run the migration system setup
-		systemMigrationPath := filepath.Join(m.migrationsDir, "000_migration_system.sql")
-		content, err := os.ReadFile(systemMigrationPath)
+		content, err := fs.ReadFile(m.files, "000_migration_system.sql")
 		if err != nil {
 			return fmt.Errorf("failed to read migration system file: %w", err)
 		}
@@ -293,22 +369,101 @@ func (m *Migrator) ApplyMigration(ctx context.Context, migration *Migration) err
 // recordMigrationExecution records migration execution in schema_migrations table
 func (m *Migrator) recordMigrationExecution(ctx context.Context, migration *Migration, executionTime int, success bool, errorMsg string) error {
 	_, err := m.db.Exec(ctx, `
-		INSERT INTO schema_migrations (version, filename, checksum, applied_at, execution_time_ms, success, error_message)
-		VALUES ($1, $2, $3, NOW(), $4, $5, $6)
+		INSERT INTO schema_migrations (version, filename, checksum, applied_at, execution_time_ms, success, error_message, rollback_sql)
+		VALUES ($1, $2, $3, NOW(), $4, $5, $6, $7)
 		ON CONFLICT (version) DO UPDATE SET
 			filename = EXCLUDED.filename,
 			checksum = EXCLUDED.checksum,
 			applied_at = EXCLUDED.applied_at,
 			execution_time_ms = EXCLUDED.execution_time_ms,
 			success = EXCLUDED.success,
-			error_message = EXCLUDED.error_message
-	`, migration.Version, migration.Filename, migration.Checksum, executionTime, success, nullString(errorMsg))
+			error_message = EXCLUDED.error_message,
+			rollback_sql = EXCLUDED.rollback_sql
+	`, migration.Version, migration.Filename, migration.Checksum, executionTime, success, nullString(errorMsg), nullString(migration.RollbackSQL))
 
 	return err
 }
 
-// Migrate runs all pending migrations
+// migrationLockID is the advisory lock key that serializes migration runs
+// across instances
+const migrationLockID int64 = 0x65696e6f6d6967 // "einomig"
+
+// lockPollInterval is how often a waiting instance retries the lock
+const lockPollInterval = time.Second
+
+// acquireLock takes the migration advisory lock, waiting up to the
+// configured timeout while another instance holds it. The lock belongs to
+// the returned connection's session, so it is released by calling release.
+func (m *Migrator) acquireLock(ctx context.Context) (func(), error) {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection for migration lock: %w", err)
+	}
+
+	var timeout time.Duration
+	if m.config != nil {
+		timeout = m.config.Database.MigrationLockTimeout
+	}
+
+	start := time.Now()
+	waiting := false
+	for {
+		var locked bool
+		if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, migrationLockID).Scan(&locked); err != nil {
+			conn.Release()
+			return nil, fmt.Errorf("failed to take migration lock: %w", err)
+		}
+
+		if locked {
+			if waiting {
+				logger.Info().
+					Dur("waited", time.Since(start)).
+					Msg("✓ Acquired migration lock")
+			}
+			return func() {
+				if _, err := conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+					logger.Warn().Err(err).Msg("Failed to release migration lock")
+				}
+				conn.Release()
+			}, nil
+		}
+
+		if !waiting {
+			logger.Info().
+				Dur("timeout", timeout).
+				Msg("Another instance is running migrations, waiting for it to finish")
+			waiting = true
+		}
+
+		if timeout > 0 && time.Since(start) >= timeout {
+			conn.Release()
+			return nil, fmt.Errorf("timed out after %s waiting for migration lock held by another instance", timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			conn.Release()
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// Migrate runs all pending migrations. Concurrent runs from other instances
+// are serialized with a Postgres advisory lock; an instance that waited
+// finds the migrations already applied.
 func (m *Migrator) Migrate(ctx context.Context) error {
+	release, err := m.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return m.migrate(ctx)
+}
+
+// migrate runs all pending migrations while holding the migration lock
+func (m *Migrator) migrate(ctx context.Context) error {
 	// Initialize migration system if needed
 	if err := m.InitializeMigrationSystem(ctx); err != nil {
 		return err
@@ -344,6 +499,15 @@ func (m *Migrator) Migrate(ctx context.Context) error {
 			continue
 		}
 
+		// Databases that applied the squashed migrations one by one record
+		// the baseline instead of running it
+		if migration.Baseline && replacedByBaseline(appliedMap, migration) {
+			if err := m.adoptBaseline(ctx, migration, appliedMap); err != nil {
+				return err
+			}
+			continue
+		}
+
 		// Check if migration is already applied
 		if applied, exists := appliedMap[migration.Version]; exists {
 			if applied.Success {
@@ -351,6 +515,10 @@ func (m *Migrator) Migrate(ctx context.Context) error {
 				if err := m.ValidateMigration(ctx, migration); err != nil {
 					return err
 				}
+				// Down files can be added after a migration was applied
+				if err := m.backfillRollback(ctx, migration); err != nil {
+					return err
+				}
 				continue // Skip already applied migrations
 			} else {
 				logger.Warn().
@@ -380,73 +548,160 @@ func (m *Migrator) Migrate(ctx context.Context) error {
 	return nil
 }
 
-// Status shows current migration status
-func (m *Migrator) Status(ctx context.Context) error {
+// backfillRollback stores rollback SQL for an applied migration that was
+// recorded without one
+func (m *Migrator) backfillRollback(ctx context.Context, migration *Migration) error {
+	if migration.RollbackSQL == "" {
+		return nil
+	}
+
+	_, err := m.db.Exec(ctx, `
+		UPDATE schema_migrations
+		SET rollback_sql = $2
+		WHERE version = $1 AND rollback_sql IS NULL
+	`, migration.Version, migration.RollbackSQL)
+	if err != nil {
+		return fmt.Errorf("failed to store rollback SQL for migration %d: %w", migration.Version, err)
+	}
+
+	return nil
+}
+
+// StatusReport is a machine-readable snapshot of the migration state
+type StatusReport struct {
+	CurrentVersion int64              `json:"current_version"`
+	UpToDate       bool               `json:"up_to_date"`
+	Applied        []*MigrationReport `json:"applied"`
+	Pending        []*MigrationReport `json:"pending"`
+	Failed         []*MigrationReport `json:"failed"`
+}
+
+// MigrationReport describes a single migration in a StatusReport
+type MigrationReport struct {
+	Version  int64  `json:"version"`
+	Filename string `json:"filename"`
+
+	// Checksum is the recorded checksum for applied or failed migrations
+	// and the file checksum for pending ones
+	Checksum string `json:"checksum"`
+
+	// FileChecksum is the checksum of the migration file, empty when the
+	// file is missing
+	FileChecksum    string     `json:"file_checksum,omitempty"`
+	Modified        bool       `json:"modified,omitempty"`
+	AppliedAt       *time.Time `json:"applied_at,omitempty"`
+	ExecutionTimeMs int        `json:"execution_time_ms,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// Report returns the current migration status
+func (m *Migrator) Report(ctx context.Context) (*StatusReport, error) {
 	// Initialize migration system if needed
 	if err := m.InitializeMigrationSystem(ctx); err != nil {
-		return err
+		return nil, err
 	}
 
-	// Get current version
 	currentVersion, err := m.GetCurrentVersion(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Load all migrations
 	migrations, err := m.LoadMigrations()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Get applied migrations
 	appliedMigrations, err := m.GetAppliedMigrations(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	logger.Info().
-		Int64("current_version", currentVersion).
-		Int("total_migrations", len(migrations)-1).
-		Msg("Migration status")
+	files := make(map[int64]*Migration)
+	for _, migration := range migrations {
+		files[migration.Version] = migration
+	}
 
-	if len(appliedMigrations) > 0 {
-		logger.Info().Msg("Applied migrations:")
-		for _, applied := range appliedMigrations {
-			status := "✓"
-			if !applied.Success {
-				status = "✗"
-			}
-			logger.Info().
-				Str("status", status).
-				Int64("version", applied.Version).
-				Str("filename", applied.Filename).
-				Str("applied_at", applied.AppliedAt.Format("2006-01-02 15:04:05")).
-				Msg("")
-		}
+	report := &StatusReport{
+		CurrentVersion: currentVersion,
+		Applied:        []*MigrationReport{},
+		Pending:        []*MigrationReport{},
+		Failed:         []*MigrationReport{},
 	}
 
-	// Show pending migrations
 	appliedMap := make(map[int64]bool)
 	for _, applied := range appliedMigrations {
+		appliedAt := applied.AppliedAt
+		entry := &MigrationReport{
+			Version:         applied.Version,
+			Filename:        applied.Filename,
+			Checksum:        applied.Checksum,
+			AppliedAt:       &appliedAt,
+			ExecutionTimeMs: applied.ExecutionTime,
+			Error:           applied.ErrorMessage,
+		}
+		if file, ok := files[applied.Version]; ok {
+			entry.FileChecksum = file.Checksum
+			entry.Modified = file.Checksum != applied.Checksum
+		}
+
 		if applied.Success {
 			appliedMap[applied.Version] = true
+			report.Applied = append(report.Applied, entry)
+		} else {
+			report.Failed = append(report.Failed, entry)
 		}
 	}
 
-	var pendingMigrations []*Migration
 	for _, migration := range migrations {
 		if migration.Version == 0 { // Skip system migration
 			continue
 		}
 		if !appliedMap[migration.Version] {
-			pendingMigrations = append(pendingMigrations, migration)
+			report.Pending = append(report.Pending, &MigrationReport{
+				Version:      migration.Version,
+				Filename:     migration.Filename,
+				Checksum:     migration.Checksum,
+				FileChecksum: migration.Checksum,
+			})
+		}
+	}
+
+	report.UpToDate = len(report.Pending) == 0
+	return report, nil
+}
+
+// Status shows current migration status
+func (m *Migrator) Status(ctx context.Context) error {
+	report, err := m.Report(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger.Info().
+		Int64("current_version", report.CurrentVersion).
+		Int("total_migrations", len(report.Applied)+len(report.Pending)).
+		Msg("Migration status")
+
+	if len(report.Applied)+len(report.Failed) > 0 {
+		logger.Info().Msg("Applied migrations:")
+		for _, applied := range append(report.Applied, report.Failed...) {
+			status := "✓"
+			if applied.Error != "" || applied.Modified {
+				status = "✗"
+			}
+			logger.Info().
+				Str("status", status).
+				Int64("version", applied.Version).
+				Str("filename", applied.Filename).
+				Str("applied_at", applied.AppliedAt.Format("2006-01-02 15:04:05")).
+				Msg("")
 		}
 	}
 
-	if len(pendingMigrations) > 0 {
+	// Show pending migrations
+	if len(report.Pending) > 0 {
 		logger.Info().Msg("Pending migrations:")
-		for _, migration := range pendingMigrations {
+		for _, migration := range report.Pending {
 			logger.Info().
 				Str("status", "○").
 				Int64("version", migration.Version).
@@ -458,8 +713,15 @@ func (m *Migrator) Status(ctx context.Context) error {
 	return nil
 }
 
-// Rollback rolls back the last migration
+// Rollback rolls back the last migration, holding the migration lock like
+// Migrate
 func (m *Migrator) Rollback(ctx context.Context) error {
+	release, err := m.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Get current version
 	currentVersion, err := m.GetCurrentVersion(ctx)
 	if err != nil {
@@ -525,8 +787,15 @@ func (m *Migrator) Rollback(ctx context.Context) error {
 	return nil
 }
 
-// RollbackTo rolls back to a specific migration version
+// RollbackTo rolls back to a specific migration version, holding the
+// migration lock like Migrate
 func (m *Migrator) RollbackTo(ctx context.Context, targetVersion int64) error {
+	release, err := m.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	currentVersion, err := m.GetCurrentVersion(ctx)
 	if err != nil {
 		return err
@@ -693,10 +962,16 @@ func (m *Migrator) Reset(ctx context.Context, confirmed bool) error {
 		return fmt.Errorf("reset operation requires explicit confirmation. This will DROP ALL TABLES")
 	}
 
+	release, err := m.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	logger.Warn().Msg("⚠ RESETTING DATABASE - This will drop all tables and data!")
 
 	// Drop all tables
-	_, err := m.db.Exec(ctx, `
+	_, err = m.db.Exec(ctx, `
 		DROP SCHEMA public CASCADE;
 		CREATE SCHEMA public;
 		GRANT ALL ON SCHEMA public TO public;
@@ -709,7 +984,7 @@ func (m *Migrator) Reset(ctx context.Context, confirmed bool) error {
 	logger.Info().Msg("Reapplying all migrations...")
 
 	// Reapply all migrations
-	return m.Migrate(ctx)
+	return m.migrate(ctx)
 }
 
 // nullString returns sql.NullString
@@ -0,0 +1,249 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// baselineMarker is the first line of a migration produced by Squash. A
+// baseline replaces every migration up to and including its version.
+const baselineMarker = "-- +baseline"
+
+// isBaseline reports whether migration content starts with the baseline
+// marker
+func isBaseline(content string) bool {
+	line, _, _ := strings.Cut(content, "\n")
+	return strings.TrimSpace(line) == baselineMarker
+}
+
+// Squash replaces every applied migration with a single baseline migration
+// holding the current schema. The baseline is written to dir, which must be
+// the on-disk directory the migrator reads from, the squashed files are
+// removed and schema_migrations is rewritten so the database records only
+// the baseline. Other databases adopt the baseline on their next migrate.
+func (m *Migrator) Squash(ctx context.Context, dir string) (string, error) {
+	release, err := m.acquireLock(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	if err := m.InitializeMigrationSystem(ctx); err != nil {
+		return "", err
+	}
+
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		return "", err
+	}
+
+	appliedMigrations, err := m.GetAppliedMigrations(ctx)
+	if err != nil {
+		return "", err
+	}
+	appliedMap := make(map[int64]*MigrationStatus)
+	for _, applied := range appliedMigrations {
+		appliedMap[applied.Version] = applied
+	}
+
+	// The dump only matches the files if every one of them is applied
+	// unmodified
+	var squashed []*Migration
+	for _, migration := range migrations {
+		if migration.Version == 0 {
+			continue
+		}
+		applied, ok := appliedMap[migration.Version]
+		if !ok || !applied.Success {
+			return "", fmt.Errorf("migration %d (%s) is not applied; run migrate before squashing", migration.Version, migration.Filename)
+		}
+		if applied.Checksum != migration.Checksum {
+			return "", fmt.Errorf("migration %d has been modified (checksum mismatch)", migration.Version)
+		}
+		squashed = append(squashed, migration)
+	}
+	if len(squashed) < 2 {
+		return "", fmt.Errorf("nothing to squash: need at least two applied migrations, found %d", len(squashed))
+	}
+
+	schema, err := m.dumpSchema(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	last := squashed[len(squashed)-1]
+	now := time.Now()
+	filename := fmt.Sprintf("%03d_%s_baseline.sql", last.Version, now.Format("20060102150405"))
+	content := baselineMarker + `
+-- Baseline: squashes migrations ` + strconv.FormatInt(squashed[0].Version, 10) + ` to ` + strconv.FormatInt(last.Version, 10) + `
+-- Created: ` + now.Format("2006-01-02 15:04:05") + `
+-- Version: ` + strconv.FormatInt(last.Version, 10) + `
+
+` + schema
+
+	path := filepath.Join(dir, filename)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write baseline migration: %w", err)
+	}
+
+	baseline := &Migration{
+		Version:  last.Version,
+		Filename: filename,
+		Content:  content,
+		Checksum: calculateChecksum(content),
+	}
+	if err := m.recordBaseline(ctx, baseline); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	for _, migration := range squashed {
+		if err := removeMigrationFiles(dir, migration.Filename); err != nil {
+			return "", err
+		}
+	}
+
+	logger.Info().
+		Int("squashed", len(squashed)).
+		Int64("version", baseline.Version).
+		Str("filename", filename).
+		Msg("✓ Squashed migrations into baseline")
+
+	return filename, nil
+}
+
+// adoptBaseline records a baseline on a database that applied the squashed
+// migrations one by one. Databases that stopped partway through them can't
+// be brought to the baseline safely.
+func (m *Migrator) adoptBaseline(ctx context.Context, baseline *Migration, appliedMap map[int64]*MigrationStatus) error {
+	if applied, ok := appliedMap[baseline.Version]; !ok || !applied.Success {
+		return fmt.Errorf("database is behind baseline migration %d (%s); apply the squashed migrations with an earlier release first", baseline.Version, baseline.Filename)
+	}
+
+	if err := m.recordBaseline(ctx, baseline); err != nil {
+		return err
+	}
+
+	logger.Info().
+		Int64("version", baseline.Version).
+		Str("filename", baseline.Filename).
+		Msg("✓ Adopted baseline migration")
+	return nil
+}
+
+// replacedByBaseline reports whether the database recorded migrations that
+// baseline squashed
+func replacedByBaseline(appliedMap map[int64]*MigrationStatus, baseline *Migration) bool {
+	for version, applied := range appliedMap {
+		if version <= baseline.Version && applied.Filename != baseline.Filename {
+			return true
+		}
+	}
+	return false
+}
+
+// recordBaseline replaces the schema_migrations rows up to the baseline
+// version with a single row for the baseline
+func (m *Migrator) recordBaseline(ctx context.Context, baseline *Migration) error {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM schema_migrations
+		WHERE version > 0 AND version <= $1
+	`, baseline.Version)
+	if err != nil {
+		return fmt.Errorf("failed to remove squashed migrations: %w", err)
+	}
+
+	// Migrations before the baseline can't be rolled back individually any
+	// more, so the baseline has no rollback SQL
+	_, err = tx.Exec(ctx, `
+		INSERT INTO schema_migrations (version, filename, checksum, applied_at, execution_time_ms, success)
+		VALUES ($1, $2, $3, NOW(), 0, true)
+	`, baseline.Version, baseline.Filename, baseline.Checksum)
+	if err != nil {
+		return fmt.Errorf("failed to record baseline migration: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit baseline transaction: %w", err)
+	}
+
+	return nil
+}
+
+// removeMigrationFiles deletes a migration file and its down file, if any
+func removeMigrationFiles(dir, filename string) error {
+	files := []string{filename}
+	if base, ok := strings.CutSuffix(filename, upSuffix); ok {
+		files = append(files, base+downSuffix)
+	}
+
+	for _, file := range files {
+		if err := os.Remove(filepath.Join(dir, file)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove squashed migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// dumpSchema captures the current schema with pg_dump, excluding the
+// migration bookkeeping table that 000_migration_system.sql creates
+func (m *Migrator) dumpSchema(ctx context.Context) (string, error) {
+	connConfig := m.db.Config().ConnConfig
+
+	cmd := exec.CommandContext(ctx, "pg_dump",
+		"--schema-only",
+		"--no-owner",
+		"--no-privileges",
+		"--exclude-table=public.schema_migrations",
+		"--host", connConfig.Host,
+		"--port", strconv.Itoa(int(connConfig.Port)),
+		"--username", connConfig.User,
+		"--dbname", connConfig.Database,
+	)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+connConfig.Password)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("pg_dump failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return cleanDump(string(out)), nil
+}
+
+// cleanDump makes pg_dump output safe to run as a migration. Migrations run
+// in a transaction on a pooled connection, so session settings are scoped
+// to the transaction; psql meta-commands are dropped; functions are created
+// with OR REPLACE since 000_migration_system.sql already defines the
+// migration helpers on fresh installs.
+func cleanDump(dump string) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(dump, "\n") {
+		switch {
+		case strings.HasPrefix(line, `\`):
+			continue
+		case strings.HasPrefix(line, "SET "):
+			line = "SET LOCAL " + strings.TrimPrefix(line, "SET ")
+		case strings.HasPrefix(line, "SELECT pg_catalog.set_config("):
+			line = strings.Replace(line, ", false)", ", true)", 1)
+		case strings.HasPrefix(line, "CREATE FUNCTION "):
+			line = "CREATE OR REPLACE FUNCTION " + strings.TrimPrefix(line, "CREATE FUNCTION ")
+		}
+		b.WriteString(line)
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditEvent struct {
+	ID        int64           `json:"id" db:"id"`
+	UserID    *uuid.UUID      `json:"user_id,omitempty" db:"user_id"`
+	Action    string          `json:"action" db:"action"`
+	Success   bool            `json:"success" db:"success"`
+	IPAddress *string         `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent *string         `json:"user_agent,omitempty" db:"user_agent"`
+	RequestID *string         `json:"request_id,omitempty" db:"request_id"`
+	Metadata  json.RawMessage `json:"metadata,omitempty" db:"metadata"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AuditEventFilter narrows down audit event queries
+type AuditEventFilter struct {
+	UserID *uuid.UUID
+	Action string
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Offset int
+}
+
+const (
+	AuditActionLogin               = "auth.login"
+	AuditActionLoginLockout        = "auth.lockout"
+	AuditActionAnomaly             = "auth.anomaly"
+	AuditActionAccountLock         = "auth.account_lock"
+	AuditActionAccountUnlock       = "auth.account_unlock"
+	AuditActionLogout              = "auth.logout"
+	AuditActionRegister            = "auth.register"
+	AuditActionTokenRefresh        = "auth.token_refresh"
+	AuditActionSessionRevoke       = "auth.session_revoke"
+	AuditActionPasswordChange      = "auth.password_change"
+	AuditActionOAuthLogin          = "oauth.login"
+	AuditActionOAuthLink           = "oauth.link"
+	AuditActionOAuthUnlink         = "oauth.unlink"
+	AuditActionOAuthScopes         = "oauth.scopes"
+	AuditActionAdminQuery          = "admin.audit_query"
+	AuditActionConfigReload        = "admin.config_reload"
+	AuditActionLoggingUpdate       = "admin.logging_update"
+	AuditActionJobRun              = "admin.job_run"
+	AuditActionTaskRetry           = "admin.task_retry"
+	AuditActionOrgQuota            = "admin.org_quota"
+	AuditActionOrgRetention        = "admin.org_retention"
+	AuditActionAdminUserCreate     = "admin.user_create"
+	AuditActionAdminPasswordReset  = "admin.password_reset"
+	AuditActionAdminTokenRevoke    = "admin.token_revoke"
+	AuditActionOrgCreate           = "org.create"
+	AuditActionOrgUpdate           = "org.update"
+	AuditActionOrgDelete           = "org.delete"
+	AuditActionOrgMemberAdd        = "org.member_add"
+	AuditActionOrgMemberRole       = "org.member_role"
+	AuditActionOrgMemberRemove     = "org.member_remove"
+	AuditActionOrgInvite           = "org.invite"
+	AuditActionOrgInviteRevoke     = "org.invite_revoke"
+	AuditActionOrgInviteAccept     = "org.invite_accept"
+	AuditActionOrgCredentialSet    = "org.credential_set"
+	AuditActionOrgCredentialDelete = "org.credential_delete"
+)
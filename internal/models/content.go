@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Content part types
+const (
+	ContentPartText  = "text"
+	ContentPartImage = "image"
+	ContentPartCode  = "code"
+)
+
+// MaxContentParts is the most parts a message may have
+const MaxContentParts = 16
+
+// ContentPart is one piece of a message sent as parts. Messages keep their
+// parts as sent, and the text of the text and code parts as their content.
+type ContentPart struct {
+	Type string `json:"type" validate:"required,oneof=text image code"`
+
+	// Text is the text of a text part and the code of a code part
+	Text string `json:"text,omitempty"`
+
+	// Language is the language of a code part's code, e.g. go (optional)
+	Language string `json:"language,omitempty" validate:"omitempty,max=30"`
+
+	// UploadID is the image, uploaded via POST /uploads, of an image part
+	UploadID *uuid.UUID `json:"upload_id,omitempty"`
+}
+
+// Check reports what is missing from the part or doesn't belong to its
+// type
+func (p ContentPart) Check() error {
+	switch p.Type {
+	case ContentPartText, ContentPartCode:
+		if strings.TrimSpace(p.Text) == "" {
+			return fmt.Errorf("%s part without text", p.Type)
+		}
+		if p.UploadID != nil {
+			return fmt.Errorf("%s part with an upload", p.Type)
+		}
+	case ContentPartImage:
+		if p.UploadID == nil {
+			return errors.New("image part without an upload_id")
+		}
+		if p.Text != "" {
+			return errors.New("image part with text")
+		}
+	}
+	if p.Language != "" && p.Type != ContentPartCode {
+		return fmt.Errorf("%s part with a language", p.Type)
+	}
+	// The language goes after the opening fence, so it must stay one word
+	if strings.ContainsFunc(p.Language, func(r rune) bool { return r == '`' || unicode.IsSpace(r) }) {
+		return errors.New("code part language with spaces or backticks")
+	}
+	return nil
+}
+
+// Markdown renders a text or code part as Markdown, the code fenced; image
+// parts have no text
+func (p ContentPart) Markdown() string {
+	switch p.Type {
+	case ContentPartText:
+		return p.Text
+	case ContentPartCode:
+		// A fence longer than any backtick run in the code can't be closed
+		// by it
+		fence := "```"
+		for strings.Contains(p.Text, fence) {
+			fence += "`"
+		}
+		return fence + p.Language + "\n" + strings.TrimSuffix(p.Text, "\n") + "\n" + fence
+	default:
+		return ""
+	}
+}
+
+// ContentText joins the text and code parts as Markdown, a paragraph each.
+// It is the content of a message sent as parts.
+func ContentText(parts []ContentPart) string {
+	var texts []string
+	for _, part := range parts {
+		if text := part.Markdown(); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// ContentUploads returns the uploads of the image parts, in order
+func ContentUploads(parts []ContentPart) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, part := range parts {
+		if part.Type == ContentPartImage && part.UploadID != nil {
+			ids = append(ids, *part.UploadID)
+		}
+	}
+	return ids
+}
@@ -8,11 +8,69 @@ import (
 )
 
 type Conversation struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	UserID    uuid.UUID `json:"user_id" db:"user_id"`
-	Title     *string   `json:"title" db:"title"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID           uuid.UUID `json:"id" db:"id"`
+	UserID       uuid.UUID `json:"user_id" db:"user_id"`
+	Title        *string   `json:"title" db:"title"`
+	Persona      *string   `json:"persona,omitempty" db:"persona"`
+	SystemPrompt *string   `json:"system_prompt,omitempty" db:"system_prompt"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+
+	// OrgID is the organization the conversation belongs to; nil for
+	// personal conversations
+	OrgID *uuid.UUID `json:"org_id,omitempty" db:"org_id"`
+
+	// ContextStrategy decides how much of the conversation is sent with
+	// each message; nil uses the server default
+	ContextStrategy *string `json:"context_strategy,omitempty" db:"context_strategy"`
+
+	// Model answers every message of the conversation unless the message
+	// names one; nil uses the user's settings
+	Model *string `json:"model,omitempty" db:"model"`
+}
+
+// MaxSystemPromptLength is the maximum length of a custom system prompt
+const MaxSystemPromptLength = 4000
+
+// Context strategies decide how much of a conversation is sent with each
+// message
+const (
+	// ContextSlidingWindow sends the latest messages only
+	ContextSlidingWindow = "sliding_window"
+
+	// ContextSummaryWindow sends the latest messages and a summary of the
+	// ones before them
+	ContextSummaryWindow = "summary_window"
+
+	// ContextFullHistory sends every message loaded for the conversation
+	ContextFullHistory = "full_history"
+)
+
+// ConversationSummary is a conversation enriched with data needed to render
+// conversation lists without fetching messages separately
+type ConversationSummary struct {
+	Conversation
+	LastMessagePreview *string    `json:"last_message_preview"`
+	LastMessageAt      *time.Time `json:"last_message_at"`
+	MessageCount       int        `json:"message_count"`
+
+	// Role is the requesting user's participant role
+	Role string `json:"role"`
+
+	// Pinned is the requesting user's pin, which sorts the conversation first
+	Pinned bool `json:"pinned"`
+
+	// LastReadMessageID is the last message the requesting user read on any
+	// device; UnreadCount counts the messages after it they didn't send
+	LastReadMessageID *int64 `json:"last_read_message_id"`
+	UnreadCount       int    `json:"unread_count"`
+}
+
+// ConversationHistory is a conversation summary with its latest messages,
+// oldest first, for restoring a session in one request
+type ConversationHistory struct {
+	ConversationSummary
+	Messages []Message `json:"messages"`
 }
 
 type Message struct {
@@ -23,14 +81,158 @@ type Message struct {
 	Content        string          `json:"content" db:"content"`
 	Metadata       json.RawMessage `json:"metadata,omitempty" db:"metadata"`
 	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	DeletedAt      *time.Time      `json:"deleted_at,omitempty" db:"deleted_at"`
+
+	// Parts are the content parts of a message sent as parts; Content
+	// then holds their text
+	Parts []ContentPart `json:"parts,omitempty" db:"content_parts"`
+}
+
+// GenerationMetadata records how an assistant reply was produced. It is
+// stored as the reply's metadata.
+type GenerationMetadata struct {
+	Provider         string `json:"provider"`
+	Model            string `json:"model"`
+	LatencyMs        int64  `json:"latency_ms"`
+	PromptTokens     int    `json:"prompt_tokens,omitempty"`
+	CompletionTokens int    `json:"completion_tokens,omitempty"`
+	TotalTokens      int    `json:"total_tokens,omitempty"`
+	FinishReason     string `json:"finish_reason,omitempty"`
+
+	// CostUSD is the price of the tokens, when the model has a price
+	CostUSD float64 `json:"cost_usd,omitempty"`
+
+	// Persona is the persona whose prompt was used; it is empty when the
+	// conversation has a custom system prompt
+	Persona      string `json:"persona,omitempty"`
+	CustomPrompt bool   `json:"custom_prompt,omitempty"`
+	Language     string `json:"language,omitempty"`
+
+	// Partial is set while a streamed reply is being generated and stays
+	// set when it didn't complete; Interruption then says why. A partial
+	// reply without an interruption was cut off by a server crash.
+	Partial      bool   `json:"partial,omitempty"`
+	Interruption string `json:"interruption,omitempty"`
+
+	// Imported names the assistant the reply was imported from, e.g.
+	// chatgpt
+	Imported string `json:"imported,omitempty"`
+}
+
+// Reasons a reply was interrupted
+const (
+	InterruptionError     = "error"
+	InterruptionCancelled = "cancelled"
+	InterruptionAbandoned = "abandoned"
+)
+
+// JSON encodes the metadata for Message.Metadata
+func (g *GenerationMetadata) JSON() json.RawMessage {
+	data, _ := json.Marshal(g) // only plain fields, can't fail
+	return data
 }
 
+// MaxMessageLength is the most characters a message may have; longer ones
+// are rejected before they are saved or sent to the model
+const MaxMessageLength = 32000
+
 type SendMessageRequest struct {
-	Message        string          `json:"message" validate:"required"`
+	Message        string          `json:"message" validate:"required_without=Content"`
 	ConversationID *uuid.UUID      `json:"conversation_id,omitempty"`
-	Model          string          `json:"model,omitempty"`
+	Model          string          `json:"model,omitempty" validate:"omitempty,max=100"`
 	Stream         bool            `json:"stream"`
 	Metadata       json.RawMessage `json:"metadata,omitempty"`
+
+	// Persona and SystemPrompt only apply when the message starts a new
+	// conversation; they are stored on the conversation for later turns
+	Persona      string `json:"persona,omitempty" validate:"omitempty,max=50"`
+	SystemPrompt string `json:"system_prompt,omitempty" validate:"omitempty,max=4000"`
+
+	// Language overrides the Accept-Language header for the reply language
+	Language string `json:"language,omitempty" validate:"omitempty,max=10"`
+
+	// ResponseFormat requests structured JSON output instead of free text
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+
+	// Attachments are IDs of images uploaded via POST /uploads
+	Attachments []uuid.UUID `json:"attachments,omitempty" validate:"omitempty,max=4"`
+
+	// Content sends the message as parts (text, images and code) instead
+	// of Message and Attachments
+	Content []ContentPart `json:"content,omitempty" validate:"omitempty,max=16,dive"`
+
+	// Chunking is how a streamed reply is split into chunk events: raw,
+	// the default, as the model sends it, or markdown, at boundaries that
+	// don't split Markdown constructs
+	Chunking string `json:"chunking,omitempty" validate:"omitempty,oneof=raw markdown"`
+
+	// Temperature, TopP, MaxTokens and Stop override the user's settings
+	// and the server defaults for this message
+	Temperature *float64 `json:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
+	TopP        *float64 `json:"top_p,omitempty" validate:"omitempty,min=0,max=1"`
+	MaxTokens   *int     `json:"max_tokens,omitempty" validate:"omitempty,min=1,max=32000"`
+	Stop        []string `json:"stop,omitempty" validate:"omitempty,max=4,dive,min=1,max=100"`
+
+	// MaxOutputLength caps the reply at this many characters; the reply
+	// ends there with finish reason length
+	MaxOutputLength *int `json:"max_output_length,omitempty" validate:"omitempty,min=1,max=100000"`
+}
+
+// Chunking modes of a streamed reply
+const (
+	ChunkingRaw      = "raw"
+	ChunkingMarkdown = "markdown"
+)
+
+// ResponseFormat describes the structured output a client expects
+type ResponseFormat struct {
+	Type   string          `json:"type" validate:"required,oneof=text json_object json_schema"`
+	Name   string          `json:"name,omitempty" validate:"omitempty,max=100"`
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// DefaultTitleHistoryMessages is how many recent messages a regenerated
+// title is based on when the request doesn't say
+const DefaultTitleHistoryMessages = 10
+
+type RegenerateTitleRequest struct {
+	// Messages is how many recent messages to summarize
+	Messages int    `json:"messages,omitempty" validate:"omitempty,min=1,max=50"`
+	Language string `json:"language,omitempty" validate:"omitempty,max=10"`
+}
+
+// UpdatePersonaRequest switches the agent answering a conversation
+type UpdatePersonaRequest struct {
+	Persona string `json:"persona" validate:"required,max=50"`
+}
+
+// UpdateContextStrategyRequest changes how much of a conversation is sent
+// with each message; an empty strategy goes back to the server default
+type UpdateContextStrategyRequest struct {
+	Strategy string `json:"strategy" validate:"omitempty,oneof=sliding_window summary_window full_history"`
+}
+
+// UpdateConversationModelRequest pins the model answering a conversation;
+// an empty model goes back to the user's settings
+type UpdateConversationModelRequest struct {
+	Model string `json:"model" validate:"omitempty,max=100"`
+}
+
+// ForkConversationRequest branches a conversation into a new one owned by
+// the caller
+type ForkConversationRequest struct {
+	// MessageID is the last message copied into the fork; all messages are
+	// copied when it is nil
+	MessageID *int64 `json:"message_id,omitempty"`
+
+	// Title of the fork; defaults to the source title
+	Title string `json:"title,omitempty" validate:"omitempty,max=255"`
+}
+
+// MarkReadRequest advances the caller's read marker in a conversation
+type MarkReadRequest struct {
+	// MessageID is the last message read; markers never move back
+	MessageID int64 `json:"message_id" validate:"required,min=1"`
 }
 
 type CreateMessageRequest struct {
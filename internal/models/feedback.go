@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Feedback ratings
+const (
+	FeedbackRatingUp   = "up"
+	FeedbackRatingDown = "down"
+)
+
+type MessageFeedback struct {
+	MessageID int64     `json:"message_id" db:"message_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Rating    string    `json:"rating" db:"rating"`
+	Comment   *string   `json:"comment,omitempty" db:"comment"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type MessageFeedbackRequest struct {
+	Rating  string `json:"rating" validate:"required,oneof=up down"`
+	Comment string `json:"comment,omitempty" validate:"omitempty,max=2000"`
+}
+
+// FeedbackSummary aggregates feedback for one persona. Conversations with a
+// custom system prompt are grouped under "custom".
+type FeedbackSummary struct {
+	Persona  string  `json:"persona"`
+	Up       int     `json:"up"`
+	Down     int     `json:"down"`
+	Total    int     `json:"total"`
+	Approval float64 `json:"approval"`
+	Comments int     `json:"comments"`
+}
+
+// FeedbackFilter narrows down feedback aggregation
+type FeedbackFilter struct {
+	From *time.Time
+	To   *time.Time
+}
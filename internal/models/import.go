@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sources conversations can be imported from
+const (
+	ImportSourceChatGPT = "chatgpt"
+)
+
+// Import job statuses
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// ImportJob is an import run in the background because the export was too
+// large to import during the request
+type ImportJob struct {
+	ID         uuid.UUID     `json:"id" db:"id"`
+	UserID     uuid.UUID     `json:"user_id" db:"user_id"`
+	Source     string        `json:"source" db:"source"`
+	Status     string        `json:"status" db:"status"`
+	StorageKey string        `json:"-" db:"storage_key"`
+	Report     *ImportReport `json:"report,omitempty" db:"report"`
+	Error      *string       `json:"error,omitempty" db:"error"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// MaxImportErrors is how many failed conversations a report names; the
+// rest are only counted
+const MaxImportErrors = 20
+
+// ImportReport sums up what an import created and left out
+type ImportReport struct {
+	// Conversations and Messages are how many were created
+	Conversations int `json:"conversations"`
+	Messages      int `json:"messages"`
+
+	// Skipped counts conversations without any message to import
+	Skipped int `json:"skipped"`
+
+	// Failed counts conversations that could not be saved, the first
+	// MaxImportErrors of them listed in Errors
+	Failed int           `json:"failed"`
+	Errors []ImportError `json:"errors,omitempty"`
+
+	// ConversationIDs are the conversations created, in export order
+	ConversationIDs []uuid.UUID `json:"conversation_ids"`
+}
+
+// ImportError names a conversation that could not be imported
+type ImportError struct {
+	Title string `json:"title"`
+	Error string `json:"error"`
+}
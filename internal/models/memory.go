@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserMemory is a fact about a user the agent learned from their messages,
+// such as "vegetarian" or "lives in Hanoi". Memories are added to the
+// prompts of all the user's conversations.
+type UserMemory struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	Content        string     `json:"content" db:"content"`
+	ConversationID *uuid.UUID `json:"conversation_id,omitempty" db:"conversation_id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
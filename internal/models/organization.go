@@ -0,0 +1,160 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization member roles
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// CanManageOrg reports whether role may rename an organization and manage
+// its members
+func CanManageOrg(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin
+}
+
+type Organization struct {
+	ID   uuid.UUID `json:"id" db:"id"`
+	Name string    `json:"name" db:"name"`
+
+	// MonthlyTokenQuota is how many tokens the organization's
+	// conversations may use per calendar month (UTC); nil is unlimited
+	MonthlyTokenQuota *int64 `json:"monthly_token_quota" db:"monthly_token_quota"`
+
+	// ConversationRetentionDays is how many days the organization's
+	// conversations are kept after their last activity; nil uses the
+	// server default and 0 keeps them forever
+	ConversationRetentionDays *int `json:"conversation_retention_days" db:"conversation_retention_days"`
+
+	CreatedBy *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Role is the requesting user's role in the organization
+	Role string `json:"role,omitempty"`
+}
+
+// OrgMember is a user's membership of an organization
+type OrgMember struct {
+	OrgID     uuid.UUID `json:"org_id" db:"org_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Username  string    `json:"username" db:"username"`
+	Email     string    `json:"email" db:"email"`
+	AvatarURL *string   `json:"avatar_url,omitempty" db:"avatar_url"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type AddOrgMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+type UpdateOrgMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+// SetOrgQuotaRequest sets an organization's monthly token quota; a null
+// quota removes it
+type SetOrgQuotaRequest struct {
+	MonthlyTokenQuota *int64 `json:"monthly_token_quota" validate:"omitempty,min=0"`
+}
+
+// SetOrgRetentionRequest overrides how long an organization's conversations
+// are kept; null goes back to the server default and 0 keeps them forever
+type SetOrgRetentionRequest struct {
+	ConversationRetentionDays *int `json:"conversation_retention_days" validate:"omitempty,min=0"`
+}
+
+// OrgUsage is an organization's usage in the current month against its
+// quota
+type OrgUsage struct {
+	Month time.Time `json:"month"`
+	UsageTotals
+	MonthlyTokenQuota *int64 `json:"monthly_token_quota"`
+}
+
+// Organization invitation statuses
+const (
+	OrgInviteStatusPending  = "pending"
+	OrgInviteStatusAccepted = "accepted"
+	OrgInviteStatusRevoked  = "revoked"
+	OrgInviteStatusExpired  = "expired"
+)
+
+// OrgInvitation invites an email to join an organization
+type OrgInvitation struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	OrgID       uuid.UUID  `json:"org_id" db:"org_id"`
+	Email       string     `json:"email" db:"email"`
+	Role        string     `json:"role" db:"role"`
+	Status      string     `json:"status" db:"status"`
+	InvitedBy   *uuid.UUID `json:"invited_by,omitempty" db:"invited_by"`
+	AcceptedBy  *uuid.UUID `json:"accepted_by,omitempty" db:"accepted_by"`
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty" db:"responded_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+
+	// OrgName is the name of the organization, for the acceptance page
+	OrgName string `json:"org_name,omitempty" db:"-"`
+}
+
+// IsPending reports whether the invitation can still be accepted
+func (i *OrgInvitation) IsPending() bool {
+	return i.Status == OrgInviteStatusPending && time.Now().Before(i.ExpiresAt)
+}
+
+type CreateOrgInvitationRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	Role  string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+// AcceptOrgInvitationRequest accepts an invitation. Name and password
+// create the account when the invited email has none; they are ignored
+// otherwise.
+type AcceptOrgInvitationRequest struct {
+	Name     string `json:"name" validate:"omitempty,max=100"`
+	Password string `json:"password" validate:"omitempty,min=8"`
+}
+
+// ProviderCredential is an AI provider API key an organization stored so
+// its conversations are answered, and billed, on its own account. The key
+// itself never leaves the server.
+type ProviderCredential struct {
+	OrgID    uuid.UUID `json:"org_id" db:"org_id"`
+	Provider string    `json:"provider" db:"provider"`
+
+	// APIKeyEncrypted is the sealed API key
+	APIKeyEncrypted []byte `json:"-" db:"api_key_encrypted"`
+
+	// KeyHint is the end of the key, to tell keys apart
+	KeyHint   string     `json:"key_hint" db:"key_hint"`
+	BaseURL   *string    `json:"base_url,omitempty" db:"base_url"`
+	Model     *string    `json:"model,omitempty" db:"model"`
+	UpdatedBy *uuid.UUID `json:"updated_by,omitempty" db:"updated_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// SetProviderCredentialRequest stores an organization's API key for a
+// provider. BaseURL and Model default to the provider's; BaseURL must be
+// on a host in AI_CREDENTIAL_HOSTS.
+type SetProviderCredentialRequest struct {
+	APIKey  string  `json:"api_key" validate:"required,min=8,max=500"`
+	BaseURL *string `json:"base_url" validate:"omitempty,url,max=500"`
+	Model   *string `json:"model" validate:"omitempty,min=1,max=100"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEntry is a write that failed and is retried later. Payload is the
+// JSON its kind's handler applies.
+type OutboxEntry struct {
+	ID        int64           `json:"id" db:"id"`
+	Kind      string          `json:"kind" db:"kind"`
+	Payload   json.RawMessage `json:"payload" db:"payload"`
+	Attempts  int             `json:"attempts" db:"attempts"`
+	LastError *string         `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
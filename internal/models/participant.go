@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Conversation participant roles
+const (
+	ParticipantRoleOwner       = "owner"
+	ParticipantRoleContributor = "contributor"
+	ParticipantRoleViewer      = "viewer"
+)
+
+// CanRead reports whether role may read a conversation
+func CanRead(role string) bool {
+	return role == ParticipantRoleOwner || role == ParticipantRoleContributor || role == ParticipantRoleViewer
+}
+
+// CanWrite reports whether role may send messages to a conversation
+func CanWrite(role string) bool {
+	return role == ParticipantRoleOwner || role == ParticipantRoleContributor
+}
+
+type Participant struct {
+	ConversationID uuid.UUID  `json:"conversation_id" db:"conversation_id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	Username       string     `json:"username" db:"username"`
+	AvatarURL      *string    `json:"avatar_url,omitempty" db:"avatar_url"`
+	Role           string     `json:"role" db:"role"`
+	InvitedBy      *uuid.UUID `json:"invited_by,omitempty" db:"invited_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+type InviteParticipantRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=contributor viewer"`
+}
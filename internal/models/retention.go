@@ -0,0 +1,32 @@
+package models
+
+import "github.com/google/uuid"
+
+// RetentionReport lists what the retention jobs delete on their next run,
+// or would delete in dry-run mode
+type RetentionReport struct {
+	DryRun bool `json:"dry_run"`
+
+	// DefaultConversationDays is how long conversations without an
+	// organization override are kept; 0 keeps them forever
+	DefaultConversationDays int `json:"default_conversation_retention_days"`
+
+	// Scopes are the personal conversations and each organization with
+	// conversations past their retention
+	Scopes        []RetentionScope `json:"scopes"`
+	Conversations int64            `json:"conversations"`
+	Messages      int64            `json:"messages"`
+
+	// DeletedMessages counts soft-deleted messages due to be purged
+	DeletedMessages int64 `json:"deleted_messages"`
+}
+
+// RetentionScope counts the expired conversations of one organization, or
+// the personal ones when OrgID is nil
+type RetentionScope struct {
+	OrgID         *uuid.UUID `json:"org_id"`
+	OrgName       *string    `json:"org_name,omitempty"`
+	RetentionDays int        `json:"retention_days"`
+	Conversations int64      `json:"conversations"`
+	Messages      int64      `json:"messages"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScheduledPrompt is a prompt sent to a conversation later on the user's
+// behalf, once at RunAt or repeatedly on the Cron schedule. The reply is
+// appended to the conversation like any other.
+type ScheduledPrompt struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	ConversationID uuid.UUID  `json:"conversation_id" db:"conversation_id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	Prompt         string     `json:"prompt" db:"prompt"`
+	Cron           *string    `json:"cron,omitempty" db:"cron_expr"`
+	Timezone       string     `json:"timezone" db:"timezone"`
+	RunAt          *time.Time `json:"run_at,omitempty" db:"run_at"`
+	WebhookURL     *string    `json:"webhook_url,omitempty" db:"webhook_url"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty" db:"last_run_at"`
+	LastError      *string    `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+
+	// NextRunAt is nil once a one-off prompt has run or a schedule was
+	// stopped
+	NextRunAt *time.Time `json:"next_run_at,omitempty" db:"next_run_at"`
+}
+
+// CreateScheduledPromptRequest schedules a prompt. Exactly one of Cron and
+// RunAt must be set.
+type CreateScheduledPromptRequest struct {
+	Prompt string     `json:"prompt" validate:"required,max=4000"`
+	Cron   string     `json:"cron,omitempty" validate:"omitempty,max=100"`
+	RunAt  *time.Time `json:"run_at,omitempty"`
+
+	// Timezone is the IANA zone cron times are in; defaults to UTC
+	Timezone string `json:"timezone,omitempty" validate:"omitempty,max=64"`
+
+	// WebhookURL is POSTed the outcome of every run
+	WebhookURL string `json:"webhook_url,omitempty" validate:"omitempty,url,max=2048"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserSettings holds a user's AI preferences. Nil fields fall back to the
+// server defaults.
+type UserSettings struct {
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	Temperature *float64  `json:"temperature" db:"temperature"`
+	MaxTokens   *int      `json:"max_tokens" db:"max_tokens"`
+	Model       *string   `json:"model" db:"model"`
+	Persona     *string   `json:"persona" db:"persona"`
+	Language    *string   `json:"language" db:"language"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// UpdateUserSettingsRequest is a partial update: omitted fields are left
+// unchanged and empty strings clear the stored value
+type UpdateUserSettingsRequest struct {
+	Temperature *float64 `json:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
+	MaxTokens   *int     `json:"max_tokens,omitempty" validate:"omitempty,min=1,max=32000"`
+	Model       *string  `json:"model,omitempty" validate:"omitempty,max=100"`
+	Persona     *string  `json:"persona,omitempty" validate:"omitempty,max=50"`
+	Language    *string  `json:"language,omitempty" validate:"omitempty,max=10"`
+}
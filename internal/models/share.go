@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SharedLink grants public read-only access to a conversation snapshot
+type SharedLink struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	ConversationID uuid.UUID  `json:"conversation_id" db:"conversation_id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	LastMessageID  int64      `json:"-" db:"last_message_id"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+
+	// URL is the public link, filled in by handlers
+	URL string `json:"url,omitempty" db:"-"`
+}
+
+// IsActive reports whether the link can still be opened
+func (l *SharedLink) IsActive() bool {
+	if l.RevokedAt != nil {
+		return false
+	}
+	return l.ExpiresAt == nil || time.Now().Before(*l.ExpiresAt)
+}
+
+type CreateShareRequest struct {
+	// ExpiresInHours limits the link lifetime; 0 means it never expires
+	ExpiresInHours int `json:"expires_in_hours" validate:"omitempty,min=1,max=8760"`
+}
+
+// SharedMessage is a message as shown on a public share page, without
+// sender IDs
+type SharedMessage struct {
+	SenderType string    `json:"sender_type"`
+	Content    string    `json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SharedConversation is the public read-only snapshot behind a share link
+type SharedConversation struct {
+	Title     *string         `json:"title"`
+	SharedAt  time.Time       `json:"shared_at"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+	Messages  []SharedMessage `json:"messages"`
+}
+
+// SharedEmbed is a range of a shared conversation as shown in an embed.
+// From and To are the 1-based positions of its first and last message, out
+// of Total in the snapshot.
+type SharedEmbed struct {
+	Title    *string         `json:"title"`
+	URL      string          `json:"url"`
+	Theme    string          `json:"theme"`
+	SharedAt time.Time       `json:"shared_at"`
+	From     int             `json:"from"`
+	To       int             `json:"to"`
+	Total    int             `json:"total"`
+	Messages []SharedMessage `json:"messages"`
+}
+
+// OEmbed is an oEmbed rich response (https://oembed.com) for a share link
+type OEmbed struct {
+	Version     string `json:"version"`
+	Type        string `json:"type"`
+	Title       string `json:"title,omitempty"`
+	ProviderURL string `json:"provider_url"`
+	HTML        string `json:"html"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
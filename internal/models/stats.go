@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// ActivityStats summarizes a user's activity for their dashboard. Weeks
+// start on Monday and hours are counted in TimeZone.
+type ActivityStats struct {
+	// Conversations counts the conversations the user takes part in
+	Conversations int64 `json:"conversations"`
+
+	// MessagesThisWeek counts the messages the user sent since WeekStart
+	MessagesThisWeek int64 `json:"messages_this_week"`
+
+	// TokensUsed counts the tokens of all replies to the user, and
+	// TokensThisWeek those since WeekStart
+	TokensUsed     int64 `json:"tokens_used"`
+	TokensThisWeek int64 `json:"tokens_this_week"`
+
+	// ActiveHours are the hours of day the user sent the most messages in
+	// recently, busiest first
+	ActiveHours []HourActivity `json:"active_hours"`
+
+	TimeZone    string    `json:"time_zone"`
+	WeekStart   time.Time `json:"week_start"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// HourActivity counts the messages sent in one hour of the day, 0 to 23
+type HourActivity struct {
+	Hour     int   `json:"hour"`
+	Messages int64 `json:"messages"`
+}
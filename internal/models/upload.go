@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Upload is a file uploaded by a user, e.g. an image attached to a message
+type Upload struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	MessageID   *int64    `json:"message_id,omitempty" db:"message_id"`
+	StorageKey  string    `json:"-" db:"storage_key"`
+	Filename    string    `json:"filename" db:"filename"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	SizeBytes   int64     `json:"size_bytes" db:"size_bytes"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+
+	// URL is a short-lived signed download URL, filled in by handlers
+	URL string `json:"url,omitempty" db:"-"`
+}
+
+// MaxMessageAttachments is the maximum number of uploads per message
+const MaxMessageAttachments = 4
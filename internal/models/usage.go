@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageRecord is the token usage and cost of one AI reply, charged to the
+// user who sent the message it answers
+type UsageRecord struct {
+	ID               int64      `json:"id" db:"id"`
+	UserID           uuid.UUID  `json:"user_id" db:"user_id"`
+	ConversationID   *uuid.UUID `json:"conversation_id,omitempty" db:"conversation_id"`
+	OrgID            *uuid.UUID `json:"org_id,omitempty" db:"org_id"`
+	MessageID        *int64     `json:"message_id,omitempty" db:"message_id"`
+	Provider         string     `json:"provider" db:"provider"`
+	Model            string     `json:"model" db:"model"`
+	PromptTokens     int        `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens" db:"completion_tokens"`
+	TotalTokens      int        `json:"total_tokens" db:"total_tokens"`
+
+	// CostMicros is in millionths of a US dollar; nil when the model had
+	// no price
+	CostMicros *int64 `json:"cost_micros,omitempty" db:"cost_micros"`
+
+	// OwnKey is set when the reply used the organization's own provider
+	// API key; the provider bills it and the quota doesn't count it
+	OwnKey    bool      `json:"own_key,omitempty" db:"own_key"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// UsageTotals sums usage records. Unpriced counts replies whose model had
+// no price, which are missing from the cost.
+type UsageTotals struct {
+	Replies          int64   `json:"replies"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	CostMicros       int64   `json:"cost_micros"`
+	CostUSD          float64 `json:"cost_usd"`
+	Unpriced         int64   `json:"unpriced_replies"`
+}
+
+// Add adds other to t
+func (t *UsageTotals) Add(other UsageTotals) {
+	t.Replies += other.Replies
+	t.PromptTokens += other.PromptTokens
+	t.CompletionTokens += other.CompletionTokens
+	t.TotalTokens += other.TotalTokens
+	t.CostMicros += other.CostMicros
+	t.CostUSD = float64(t.CostMicros) / 1e6
+	t.Unpriced += other.Unpriced
+}
+
+// UsageReportRow is a user's usage on one day (UTC) with one model
+type UsageReportRow struct {
+	Day      time.Time `json:"day"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	UsageTotals
+}
+
+// UserUsage is one user's usage, for the admin rollup
+type UserUsage struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email"`
+	UsageTotals
+}
+
+// UsageModel is a provider and model that has usage records
+type UsageModel struct {
+	Provider string
+	Model    string
+}
@@ -15,6 +15,13 @@ type User struct {
 	OAuthProviderID  *string    `json:"-" db:"oauth_provider_id"`
 	AvatarURL        *string    `json:"avatar_url,omitempty" db:"avatar_url"`
 	OAuthEmail       *string    `json:"-" db:"oauth_email"`
+	IsAdmin          bool       `json:"is_admin" db:"is_admin"`
+
+	// LockedAt is set while the account is locked after suspicious
+	// activity, until its owner confirms by email; LockReason says why
+	LockedAt   *time.Time `json:"-" db:"locked_at"`
+	LockReason *string    `json:"-" db:"lock_reason"`
+
 	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
 }
@@ -25,6 +32,12 @@ type UserRegisterRequest struct {
 	Password string `json:"password" validate:"required,min=8"`
 }
 
+// UnlockAccountRequest redeems the unlock token emailed when an account was
+// locked
+type UnlockAccountRequest struct {
+	Token string `json:"token" validate:"required,max=100"`
+}
+
 type CheckEmailRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
@@ -38,6 +51,7 @@ type UserResponse struct {
 	ID        uuid.UUID `json:"id"`
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
+	AvatarURL *string   `json:"avatar_url,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -49,6 +63,39 @@ type RefreshToken struct {
 	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+
+	// SessionID and SignedInAt are carried over when the token is
+	// rotated, so they identify the sign-in rather than the token. They
+	// are assigned when a sign-in's first token is stored.
+	SessionID  uuid.UUID `json:"session_id" db:"session_id"`
+	SignedInAt time.Time `json:"signed_in_at" db:"signed_in_at"`
+
+	// Device is what the token was issued to; it can only be refreshed
+	// from a device with the same fingerprint
+	Device Device `json:"-"`
+}
+
+// Device identifies the client a session belongs to
+type Device struct {
+	// Fingerprint is a hash of the user agent without version numbers
+	Fingerprint string `json:"-"`
+	UserAgent   string `json:"user_agent"`
+	DeviceID    string `json:"device_id,omitempty"`
+	IPAddress   string `json:"ip_address"`
+}
+
+// Session is a signed-in device of a user, as listed by the sessions API
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	Device     Device    `json:"device"`
+	SignedInAt time.Time `json:"signed_in_at"`
+
+	// LastActiveAt is when the session's token was last refreshed
+	LastActiveAt time.Time `json:"last_active_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+
+	// Current marks the session of the request
+	Current bool `json:"current"`
 }
 
 type TokenResponse struct {
@@ -74,6 +121,7 @@ type OAuthAccount struct {
 	RefreshToken       *string    `json:"-" db:"refresh_token"`
 	TokenExpiresAt     *time.Time `json:"-" db:"token_expires_at"`
 	RawUserData        []byte     `json:"-" db:"raw_user_data"` // JSONB
+	Scopes             []string   `json:"scopes" db:"scopes"`   // granted by the user
 	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
 }
@@ -86,6 +134,16 @@ type OAuthState struct {
 	RedirectURI  *string   `json:"redirect_uri,omitempty" db:"redirect_uri"`
 	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+
+	// UserID is set when a signed-in user links an account, and Scopes too
+	// when they grant more scopes to their linked account
+	UserID *uuid.UUID `json:"-" db:"-"`
+	Scopes []string   `json:"-" db:"-"`
+}
+
+// OAuthScopesRequest asks for more scopes on a linked account
+type OAuthScopesRequest struct {
+	Scopes []string `json:"scopes" validate:"required,min=1,max=20,dive,required,max=200"`
 }
 
 type OAuthUserInfo struct {
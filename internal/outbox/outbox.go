@@ -0,0 +1,239 @@
+// Package outbox retries writes of generated messages that failed, so an
+// answer the user already received is never lost to a database hiccup.
+// Failed writes are queued in the message_outbox table, or in memory while
+// the table can't be written either, and applied again by a scheduler job.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/events"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of queued writes
+const (
+	// KindSaveMessage creates a message, or replaces the content of one
+	// that was saved before
+	KindSaveMessage = "save_message"
+
+	// KindTouchConversation bumps a conversation's updated_at
+	KindTouchConversation = "touch_conversation"
+)
+
+// Retry backoff: the first retry waits initialBackoff, doubling per failed
+// attempt up to maxBackoff
+const (
+	initialBackoff = 30 * time.Second
+	maxBackoff     = time.Hour
+)
+
+// lease is how long an entry being retried is reserved for one instance
+const lease = 5 * time.Minute
+
+// maxPending bounds the writes kept in memory while the outbox table can't
+// be written
+const maxPending = 1000
+
+// Config controls retries
+type Config struct {
+	// BatchSize is how many queued writes one run retries
+	BatchSize int
+
+	// MaxAttempts is how many times a write is retried before it is
+	// marked failed and left for inspection
+	MaxAttempts int
+}
+
+// Outbox queues failed writes and retries them. Its methods log failures
+// rather than return them.
+type Outbox struct {
+	repo         *repository.OutboxRepository
+	convRepo     *repository.ConversationRepository
+	tx           *repository.Transactor
+	participants *repository.ParticipantRepository
+	events       events.Bus
+	config       Config
+
+	mu      sync.Mutex
+	pending []models.OutboxEntry
+}
+
+func New(repo *repository.OutboxRepository, convRepo *repository.ConversationRepository, tx *repository.Transactor, participants *repository.ParticipantRepository, bus events.Bus, config Config) *Outbox {
+	return &Outbox{
+		repo:         repo,
+		convRepo:     convRepo,
+		tx:           tx,
+		participants: participants,
+		events:       bus,
+		config:       config,
+	}
+}
+
+// SaveMessage queues saving message, whose write failed with cause
+func (o *Outbox) SaveMessage(ctx context.Context, message *models.Message, cause error) {
+	o.enqueue(ctx, KindSaveMessage, message, cause)
+}
+
+// TouchConversation queues bumping the updated_at of a conversation, whose
+// update failed with cause
+func (o *Outbox) TouchConversation(ctx context.Context, conversationID uuid.UUID, cause error) {
+	o.enqueue(ctx, KindTouchConversation, conversationID, cause)
+}
+
+func (o *Outbox) enqueue(ctx context.Context, kind string, payload any, cause error) {
+	log := logger.ModuleContext(ctx, "outbox")
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Error().Err(err).Str("kind", kind).Msg("Failed to encode write for retry")
+		return
+	}
+
+	lastError := cause.Error()
+	entry := models.OutboxEntry{Kind: kind, Payload: data, LastError: &lastError}
+
+	// The write already failed once, so the entry is queued even when the
+	// request is gone
+	ctx = context.WithoutCancel(ctx)
+	err = o.repo.Add(ctx, &entry)
+	if err == nil {
+		log.Warn().AnErr("cause", cause).Str("kind", kind).Int64("entry_id", entry.ID).Msg("Write failed, queued for retry")
+		return
+	}
+	log.Warn().Err(err).Str("kind", kind).Msg("Failed to queue write, keeping it in memory")
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.pending) >= maxPending {
+		log.Error().AnErr("cause", cause).Str("kind", kind).RawJSON("payload", data).Msg("Too many writes pending, dropping write")
+		return
+	}
+	o.pending = append(o.pending, entry)
+}
+
+// Retry applies queued writes that are due, as a scheduler job
+func (o *Outbox) Retry(ctx context.Context) (string, error) {
+	log := logger.ModuleContext(ctx, "outbox")
+
+	var applied, failed int
+
+	// Writes kept in memory are applied directly, or moved to the table
+	// once it can be written again
+	o.mu.Lock()
+	pending := o.pending
+	o.pending = nil
+	o.mu.Unlock()
+
+	var kept []models.OutboxEntry
+	for _, entry := range pending {
+		saved, err := o.apply(ctx, entry)
+		if err == nil {
+			applied++
+			o.saved(ctx, saved)
+			continue
+		}
+
+		failed++
+		lastError := err.Error()
+		entry.Attempts++
+		entry.LastError = &lastError
+		if err := o.repo.Add(ctx, &entry); err != nil {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) > 0 {
+		o.mu.Lock()
+		o.pending = append(kept, o.pending...)
+		o.mu.Unlock()
+	}
+
+	entries, err := o.repo.ClaimDue(ctx, o.config.BatchSize, lease)
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		// The entry is removed in the same transaction as the write, so
+		// a write is never applied twice
+		var saved *models.Message
+		err := o.tx.WithTx(ctx, func(ctx context.Context) error {
+			var err error
+			if saved, err = o.apply(ctx, entry); err != nil {
+				return err
+			}
+			return o.repo.Delete(ctx, entry.ID)
+		})
+		if err == nil {
+			applied++
+			o.saved(ctx, saved)
+			continue
+		}
+
+		failed++
+		if entry.Attempts+1 >= o.config.MaxAttempts {
+			log.Error().Err(err).Str("kind", entry.Kind).Int64("entry_id", entry.ID).Msg("Write failed for the last time, giving up")
+			if err := o.repo.Fail(ctx, entry.ID, err.Error()); err != nil {
+				log.Error().Err(err).Int64("entry_id", entry.ID).Msg("Failed to mark write failed")
+			}
+			continue
+		}
+		if err := o.repo.Retry(ctx, entry.ID, time.Now().Add(backoff(entry.Attempts)), err.Error()); err != nil {
+			log.Error().Err(err).Int64("entry_id", entry.ID).Msg("Failed to reschedule write")
+		}
+	}
+
+	return fmt.Sprintf("applied %d writes, %d failed, %d pending in memory", applied, failed, len(kept)), nil
+}
+
+// apply performs a queued write, returning the message it saved if any
+func (o *Outbox) apply(ctx context.Context, entry models.OutboxEntry) (*models.Message, error) {
+	switch entry.Kind {
+	case KindSaveMessage:
+		var message models.Message
+		if err := json.Unmarshal(entry.Payload, &message); err != nil {
+			return nil, fmt.Errorf("invalid message: %w", err)
+		}
+		if message.ID == 0 {
+			return &message, o.convRepo.CreateMessage(ctx, &message)
+		}
+		return &message, o.convRepo.UpdateMessageContent(ctx, &message)
+	case KindTouchConversation:
+		var conversationID uuid.UUID
+		if err := json.Unmarshal(entry.Payload, &conversationID); err != nil {
+			return nil, fmt.Errorf("invalid conversation ID: %w", err)
+		}
+		return nil, o.convRepo.UpdateTimestamp(ctx, conversationID)
+	}
+	return nil, fmt.Errorf("unknown write kind %q", entry.Kind)
+}
+
+// saved tells the participants of a conversation about a reply saved on
+// retry, so open clients fetch it
+func (o *Outbox) saved(ctx context.Context, message *models.Message) {
+	if message == nil || message.SenderType != models.SenderTypeAgent {
+		return
+	}
+	conversation, err := o.convRepo.GetByID(ctx, message.ConversationID)
+	if err != nil || conversation == nil {
+		return
+	}
+	events.PublishConversation(ctx, o.events, o.participants, conversation, events.NewMessageCompleted(conversation.ID, message.ID))
+}
+
+// backoff is how long to wait after the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	wait := initialBackoff
+	for i := 0; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
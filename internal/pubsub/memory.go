@@ -0,0 +1,68 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPubSub is a PubSub for a single process
+type MemoryPubSub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan []byte]struct{}
+	closed      bool
+}
+
+// NewMemoryPubSub creates an in-memory pub/sub
+func NewMemoryPubSub() *MemoryPubSub {
+	return &MemoryPubSub{subscribers: make(map[string]map[chan []byte]struct{})}
+}
+
+func (m *MemoryPubSub) Publish(ctx context.Context, channel string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for ch := range m.subscribers[channel] {
+		select {
+		case ch <- data:
+		default:
+			// The subscriber is too slow
+		}
+	}
+	return nil
+}
+
+func (m *MemoryPubSub) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	ch := make(chan []byte, subscriberBuffer)
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if m.subscribers[channel] == nil {
+		m.subscribers[channel] = make(map[chan []byte]struct{})
+	}
+	m.subscribers[channel][ch] = struct{}{}
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subscribers[channel], ch)
+		if len(m.subscribers[channel]) == 0 {
+			delete(m.subscribers, channel)
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+func (m *MemoryPubSub) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
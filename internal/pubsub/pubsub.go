@@ -0,0 +1,47 @@
+// Package pubsub fans messages out to the subscribers of a channel. With
+// Redis, a message published on one instance reaches subscribers on all
+// of them, which live updates and resumed streams rely on when several
+// replicas run behind a load balancer.
+package pubsub
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shivaluma/eino-agent/internal/cache"
+)
+
+// ErrClosed is returned when subscribing after Close
+var ErrClosed = errors.New("pubsub: closed")
+
+// subscriberBuffer is how many messages a slow subscriber may fall behind
+// before further messages are dropped for it
+const subscriberBuffer = 32
+
+// PubSub publishes messages to the current subscribers of a channel.
+// Messages are not stored: subscribers that connect later don't receive
+// them, and a subscriber that falls behind misses some.
+type PubSub interface {
+	// Publish sends data to the channel's subscribers. Subscribers share
+	// data and must not modify it.
+	Publish(ctx context.Context, channel string, data []byte) error
+
+	// Subscribe returns the channel's messages. Messages published after
+	// Subscribe returns are received. The returned channel is closed once
+	// ctx is done.
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, error)
+
+	// Close releases what is held for subscribers. Subscribing afterwards
+	// fails.
+	Close() error
+}
+
+// New returns a Redis-backed pub/sub when the shared cache is Redis, so
+// messages reach subscribers on every instance, and an in-memory one
+// otherwise
+func New(c cache.Cache, prefix string) PubSub {
+	if rc, ok := c.(*cache.Redis); ok {
+		return NewRedisPubSub(rc.Client(), prefix)
+	}
+	return NewMemoryPubSub()
+}
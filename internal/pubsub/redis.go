@@ -0,0 +1,157 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+// RedisPubSub is a PubSub backed by Redis Pub/Sub channels. All of an
+// instance's subscribers share one Redis connection, which subscribes to a
+// channel while it has subscribers and is re-established by the client
+// when it drops. Messages published while it is down are lost.
+type RedisPubSub struct {
+	client *redis.Client
+	prefix string
+
+	mu          sync.Mutex
+	conn        *redis.PubSub
+	subscribers map[string]map[chan []byte]struct{}
+	// pending holds the channels Redis hasn't confirmed the subscription
+	// to yet
+	pending map[string]chan struct{}
+	closed  bool
+}
+
+// NewRedisPubSub creates a Redis-backed pub/sub. Channel names are
+// prefixed with prefix in Redis.
+func NewRedisPubSub(client *redis.Client, prefix string) *RedisPubSub {
+	return &RedisPubSub{
+		client:      client,
+		prefix:      prefix,
+		subscribers: make(map[string]map[chan []byte]struct{}),
+		pending:     make(map[string]chan struct{}),
+	}
+}
+
+func (r *RedisPubSub) Publish(ctx context.Context, channel string, data []byte) error {
+	if err := r.client.Publish(ctx, r.prefix+channel, data).Err(); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisPubSub) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	name := r.prefix + channel
+	ch := make(chan []byte, subscriberBuffer)
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if r.conn == nil {
+		// The connection outlives the request that opened it
+		r.conn = r.client.Subscribe(context.WithoutCancel(ctx))
+		go r.receive(r.conn.ChannelWithSubscriptions())
+	}
+	confirmed := r.pending[name]
+	if len(r.subscribers[name]) == 0 {
+		confirmed = make(chan struct{})
+		if err := r.conn.Subscribe(ctx, name); err != nil {
+			// Don't resubscribe to it on reconnect
+			r.conn.Unsubscribe(context.WithoutCancel(ctx), name)
+			r.mu.Unlock()
+			return nil, fmt.Errorf("failed to subscribe to %s: %w", channel, err)
+		}
+		r.pending[name] = confirmed
+		r.subscribers[name] = make(map[chan []byte]struct{})
+	}
+	r.subscribers[name][ch] = struct{}{}
+	r.mu.Unlock()
+
+	// Wait for the confirmation so no message published after Subscribe
+	// returns is missed
+	if confirmed != nil {
+		select {
+		case <-confirmed:
+		case <-ctx.Done():
+			r.unsubscribe(ctx, name, ch)
+			return nil, ctx.Err()
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		r.unsubscribe(ctx, name, ch)
+	}()
+
+	return ch, nil
+}
+
+// unsubscribe removes a subscriber, unsubscribing the connection from the
+// channel when it was the last one
+func (r *RedisPubSub) unsubscribe(ctx context.Context, name string, ch chan []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.subscribers[name], ch)
+	close(ch)
+	if len(r.subscribers[name]) > 0 {
+		return
+	}
+
+	delete(r.subscribers, name)
+	delete(r.pending, name)
+	if r.closed {
+		return
+	}
+	if err := r.conn.Unsubscribe(context.WithoutCancel(ctx), name); err != nil {
+		logger.ModuleContext(ctx, "pubsub").Warn().Err(err).Str("channel", name).Msg("Failed to unsubscribe")
+	}
+}
+
+// receive hands the connection's messages to the subscribers of their
+// channel until the connection is closed
+func (r *RedisPubSub) receive(messages <-chan interface{}) {
+	for msg := range messages {
+		r.mu.Lock()
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if confirmed, ok := r.pending[msg.Channel]; ok && msg.Kind == "subscribe" {
+				close(confirmed)
+				delete(r.pending, msg.Channel)
+			}
+		case *redis.Message:
+			data := []byte(msg.Payload)
+			for ch := range r.subscribers[msg.Channel] {
+				select {
+				case ch <- data:
+				default:
+					// The subscriber is too slow
+				}
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *RedisPubSub) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.conn == nil {
+		return nil
+	}
+	if err := r.conn.Close(); err != nil {
+		return fmt.Errorf("failed to close pub/sub connection: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+// maxPending bounds the tasks an in-memory queue holds
+const maxPending = 10000
+
+// MemoryQueue is an in-process Queue. Only the process that queued a task
+// runs it, and queued tasks are lost when the process exits.
+type MemoryQueue struct {
+	handlers
+	config Config
+	tasks  chan Task
+
+	mu   sync.Mutex
+	dead []Task
+}
+
+// NewMemoryQueue creates an in-memory queue
+func NewMemoryQueue(config Config) *MemoryQueue {
+	return &MemoryQueue{
+		config: config,
+		tasks:  make(chan Task, maxPending),
+	}
+}
+
+func (m *MemoryQueue) Enqueue(ctx context.Context, kind string, payload any) error {
+	task, err := newTask(kind, payload)
+	if err != nil {
+		return err
+	}
+	return m.push(task)
+}
+
+func (m *MemoryQueue) push(task Task) error {
+	select {
+	case m.tasks <- task:
+		return nil
+	default:
+		return ErrFull
+	}
+}
+
+func (m *MemoryQueue) Consume(ctx context.Context) {
+	for range m.config.Workers {
+		go m.work(ctx)
+	}
+}
+
+func (m *MemoryQueue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case task := <-m.tasks:
+			m.process(ctx, task)
+		}
+	}
+}
+
+func (m *MemoryQueue) process(ctx context.Context, task Task) {
+	err := m.run(ctx, task, m.config.Timeout)
+	if err == nil {
+		return
+	}
+
+	log := logger.ModuleContext(ctx, "queue").With().Str("kind", task.Kind).Str("task_id", task.ID.String()).Logger()
+	delay, retry := failed(&task, err, m.config)
+	if !retry {
+		log.Error().Err(err).Int("attempts", task.Attempts).Msg("Task failed, moved to dead letters")
+		m.bury(task)
+		return
+	}
+
+	log.Warn().Err(err).Int("attempts", task.Attempts).Dur("retry_in", delay).Msg("Task failed, retrying")
+	time.AfterFunc(delay, func() {
+		if err := m.push(task); err != nil {
+			log.Error().Err(err).Msg("Failed to queue task retry, moved to dead letters")
+			m.bury(task)
+		}
+	})
+}
+
+func (m *MemoryQueue) bury(task Task) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dead = append(m.dead, task)
+	if len(m.dead) > maxDeadLetters {
+		m.dead = m.dead[len(m.dead)-maxDeadLetters:]
+	}
+}
+
+func (m *MemoryQueue) DeadLetters(ctx context.Context, limit int) ([]Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := make([]Task, 0, min(limit, len(m.dead)))
+	for i := len(m.dead) - 1; i >= 0 && len(tasks) < limit; i-- {
+		tasks = append(tasks, m.dead[i])
+	}
+	return tasks, nil
+}
+
+func (m *MemoryQueue) Redrive(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	dead := m.dead
+	m.dead = nil
+	m.mu.Unlock()
+
+	for i, task := range dead {
+		task.Attempts = 0
+		task.LastError = ""
+		task.FailedAt = nil
+		if err := m.push(task); err != nil {
+			m.mu.Lock()
+			m.dead = append(dead[i:], m.dead...)
+			m.mu.Unlock()
+			return i, err
+		}
+	}
+	return len(dead), nil
+}
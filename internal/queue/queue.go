@@ -0,0 +1,157 @@
+// Package queue runs tasks outside the request that queued them, such as
+// titling a new conversation, learning memories from a message or
+// delivering a webhook. Failed tasks are retried with backoff and kept as
+// dead letters once they run out of attempts, for admins to inspect and
+// queue again.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shivaluma/eino-agent/internal/cache"
+)
+
+// ErrFull is returned when an in-memory queue has no room for a task
+var ErrFull = errors.New("queue: queue is full")
+
+// maxBackoff caps the delay between retries
+const maxBackoff = time.Hour
+
+// maxDeadLetters bounds how many dead letters are kept; older ones are
+// dropped
+const maxDeadLetters = 1000
+
+// Handler runs a task of one kind. A returned error retries the task.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// Task is a queued unit of work
+type Task struct {
+	ID        uuid.UUID       `json:"id"`
+	Kind      string          `json:"kind"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	FailedAt  *time.Time      `json:"failed_at,omitempty"`
+}
+
+// Queue hands tasks to the processes consuming them
+type Queue interface {
+	// Handle registers the handler of a kind of task. Handlers are
+	// registered before Consume is called.
+	Handle(kind string, handler Handler)
+
+	// Enqueue queues a task of the kind with the JSON encoding of payload
+	Enqueue(ctx context.Context, kind string, payload any) error
+
+	// Consume runs queued tasks in the background until ctx is done.
+	// Processes that only queue tasks don't call it.
+	Consume(ctx context.Context)
+
+	// DeadLetters returns up to limit tasks that ran out of attempts,
+	// most recent first
+	DeadLetters(ctx context.Context, limit int) ([]Task, error)
+
+	// Redrive queues the dead letters again with fresh attempts and
+	// returns how many were queued
+	Redrive(ctx context.Context) (int, error)
+}
+
+// Config controls how tasks are run and retried
+type Config struct {
+	// Workers is how many tasks a consuming process runs at once
+	Workers int
+
+	// MaxAttempts is how many times a task is run before it becomes a
+	// dead letter
+	MaxAttempts int
+
+	// Backoff is how long the first retry waits, doubling per failed
+	// attempt
+	Backoff time.Duration
+
+	// Timeout bounds each run of a task
+	Timeout time.Duration
+}
+
+// New returns a Redis-backed queue when the shared cache is Redis, so any
+// instance or worker can run the tasks, and an in-memory queue otherwise
+func New(c cache.Cache, prefix string, config Config) Queue {
+	if rc, ok := c.(*cache.Redis); ok {
+		return NewRedisQueue(rc.Client(), prefix, config)
+	}
+	return NewMemoryQueue(config)
+}
+
+// handlers are the registered handlers by kind
+type handlers struct {
+	mu sync.RWMutex
+	m  map[string]Handler
+}
+
+func (h *handlers) Handle(kind string, handler Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.m == nil {
+		h.m = make(map[string]Handler)
+	}
+	h.m[kind] = handler
+}
+
+// run runs a task with its handler, bounded by timeout
+func (h *handlers) run(ctx context.Context, task Task, timeout time.Duration) (err error) {
+	h.mu.RLock()
+	handler, ok := h.m[task.Kind]
+	h.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no handler for task kind %q", task.Kind)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// A panicking handler fails its task instead of the worker
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+	return handler(ctx, task.Payload)
+}
+
+func newTask(kind string, payload any) (Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Task{}, fmt.Errorf("failed to encode %s task: %w", kind, err)
+	}
+	return Task{
+		ID:        uuid.New(),
+		Kind:      kind,
+		Payload:   data,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// failed records a failed run of task and reports whether it has attempts
+// left, and if so how long its retry waits
+func failed(task *Task, err error, config Config) (time.Duration, bool) {
+	task.Attempts++
+	task.LastError = err.Error()
+	if task.Attempts >= config.MaxAttempts {
+		now := time.Now().UTC()
+		task.FailedAt = &now
+		return 0, false
+	}
+
+	delay := config.Backoff
+	for i := 1; i < task.Attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff), true
+}
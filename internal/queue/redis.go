@@ -0,0 +1,302 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+// consumerGroup is the stream consumer group every consuming process joins,
+// so each task is delivered to one of them
+const consumerGroup = "workers"
+
+// readBlock is how long a worker waits for a task before checking ctx
+const readBlock = 5 * time.Second
+
+// pollInterval is how often due retries are queued and tasks of consumers
+// that died are taken over
+const pollInterval = time.Second
+
+// errInterrupted fails a task whose consumer stopped before finishing it
+var errInterrupted = errors.New("task was interrupted before it finished")
+
+// promoteScript moves retries that are due from the delayed set to the
+// stream
+var promoteScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, 100)
+for _, task in ipairs(due) do
+	redis.call("XADD", KEYS[2], "*", "task", task)
+	redis.call("ZREM", KEYS[1], task)
+end
+return #due
+`)
+
+// RedisQueue is a Queue backed by a Redis stream, so a task queued on one
+// instance can run on any instance or worker. Tasks waiting for a retry
+// are kept in a sorted set and dead letters in a list. A task whose
+// consumer died is taken over by another once it has been idle for twice
+// the task timeout.
+type RedisQueue struct {
+	handlers
+	client   *redis.Client
+	prefix   string
+	config   Config
+	consumer string
+}
+
+// NewRedisQueue creates a Redis-backed queue
+func NewRedisQueue(client *redis.Client, prefix string, config Config) *RedisQueue {
+	return &RedisQueue{
+		client:   client,
+		prefix:   prefix,
+		config:   config,
+		consumer: uuid.New().String(),
+	}
+}
+
+func (r *RedisQueue) key(name string) string {
+	return r.prefix + "queue:" + name
+}
+
+func (r *RedisQueue) Enqueue(ctx context.Context, kind string, payload any) error {
+	task, err := newTask(kind, payload)
+	if err != nil {
+		return err
+	}
+	return r.add(ctx, task)
+}
+
+func (r *RedisQueue) add(ctx context.Context, task Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode task: %w", err)
+	}
+	err = r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.key("tasks"),
+		Values: map[string]interface{}{"task": data},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to queue task: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisQueue) Consume(ctx context.Context) {
+	r.createGroup(ctx)
+	for range r.config.Workers {
+		go r.work(ctx)
+	}
+	go r.maintain(ctx)
+}
+
+// createGroup creates the consumer group, and the stream with it. Joining
+// an existing group is not an error.
+func (r *RedisQueue) createGroup(ctx context.Context) {
+	err := r.client.XGroupCreateMkStream(ctx, r.key("tasks"), consumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		logger.ModuleContext(ctx, "queue").Error().Err(err).Msg("Failed to create task consumer group")
+	}
+}
+
+func (r *RedisQueue) work(ctx context.Context) {
+	log := logger.ModuleContext(ctx, "queue")
+
+	for ctx.Err() == nil {
+		streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    consumerGroup,
+			Consumer: r.consumer,
+			Streams:  []string{r.key("tasks"), ">"},
+			Count:    1,
+			Block:    readBlock,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				// The stream was deleted, e.g. by FLUSHDB
+				r.createGroup(ctx)
+				continue
+			}
+			log.Warn().Err(err).Msg("Failed to read tasks")
+			select {
+			case <-time.After(readBlock):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				r.process(ctx, msg)
+			}
+		}
+	}
+}
+
+func (r *RedisQueue) process(ctx context.Context, msg redis.XMessage) {
+	task, ok := r.decode(ctx, msg)
+	if !ok {
+		return
+	}
+
+	err := r.run(ctx, task, r.config.Timeout)
+	if err != nil && ctx.Err() != nil {
+		// Shutting down; the task is taken over by another consumer
+		return
+	}
+	r.settle(ctx, msg.ID, task, err)
+}
+
+// decode reads the task of a stream entry. Malformed entries are dropped.
+func (r *RedisQueue) decode(ctx context.Context, msg redis.XMessage) (Task, bool) {
+	var task Task
+	data, _ := msg.Values["task"].(string)
+	if err := json.Unmarshal([]byte(data), &task); err != nil {
+		logger.ModuleContext(ctx, "queue").Error().Err(err).Str("entry_id", msg.ID).Msg("Dropping malformed task")
+		r.client.XAck(context.WithoutCancel(ctx), r.key("tasks"), consumerGroup, msg.ID)
+		r.client.XDel(context.WithoutCancel(ctx), r.key("tasks"), msg.ID)
+		return Task{}, false
+	}
+	return task, true
+}
+
+// settle removes a task that ran from the stream, scheduling its retry or
+// burying it when it failed
+func (r *RedisQueue) settle(ctx context.Context, entryID string, task Task, runErr error) {
+	ctx = context.WithoutCancel(ctx)
+	log := logger.ModuleContext(ctx, "queue").With().Str("kind", task.Kind).Str("task_id", task.ID.String()).Logger()
+
+	var (
+		delay time.Duration
+		retry bool
+		data  []byte
+	)
+	if runErr != nil {
+		delay, retry = failed(&task, runErr, r.config)
+		data, _ = json.Marshal(task)
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		switch {
+		case runErr == nil:
+		case retry:
+			pipe.ZAdd(ctx, r.key("delayed"), redis.Z{Score: float64(time.Now().Add(delay).UnixMilli()), Member: data})
+		default:
+			pipe.LPush(ctx, r.key("dead"), data)
+			pipe.LTrim(ctx, r.key("dead"), 0, maxDeadLetters-1)
+		}
+		pipe.XAck(ctx, r.key("tasks"), consumerGroup, entryID)
+		pipe.XDel(ctx, r.key("tasks"), entryID)
+		return nil
+	})
+	if err != nil {
+		// The entry stays pending and is taken over later
+		log.Error().Err(err).AnErr("task_error", runErr).Msg("Failed to settle task")
+		return
+	}
+
+	switch {
+	case runErr == nil:
+	case retry:
+		log.Warn().Err(runErr).Int("attempts", task.Attempts).Dur("retry_in", delay).Msg("Task failed, retrying")
+	default:
+		log.Error().Err(runErr).Int("attempts", task.Attempts).Msg("Task failed, moved to dead letters")
+	}
+}
+
+// maintain queues due retries and takes over the tasks of consumers that
+// died, until ctx is done
+func (r *RedisQueue) maintain(ctx context.Context) {
+	log := logger.ModuleContext(ctx, "queue")
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		if err := promoteScript.Run(ctx, r.client, []string{r.key("delayed"), r.key("tasks")}, now).Err(); err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Msg("Failed to queue due task retries")
+		}
+
+		msgs, _, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   r.key("tasks"),
+			Group:    consumerGroup,
+			MinIdle:  2 * r.config.Timeout,
+			Start:    "0-0",
+			Count:    100,
+			Consumer: r.consumer,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil && !strings.HasPrefix(err.Error(), "NOGROUP") {
+				log.Warn().Err(err).Msg("Failed to take over interrupted tasks")
+			}
+			continue
+		}
+		for _, msg := range msgs {
+			if task, ok := r.decode(ctx, msg); ok {
+				r.settle(ctx, msg.ID, task, errInterrupted)
+			}
+		}
+	}
+}
+
+func (r *RedisQueue) DeadLetters(ctx context.Context, limit int) ([]Task, error) {
+	values, err := r.client.LRange(ctx, r.key("dead"), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	tasks := make([]Task, 0, len(values))
+	for _, value := range values {
+		var task Task
+		if err := json.Unmarshal([]byte(value), &task); err != nil {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+func (r *RedisQueue) Redrive(ctx context.Context) (int, error) {
+	var queued int
+	for {
+		// Oldest first, so they are queued in the order they failed
+		value, err := r.client.RPop(ctx, r.key("dead")).Result()
+		if errors.Is(err, redis.Nil) {
+			return queued, nil
+		}
+		if err != nil {
+			return queued, fmt.Errorf("failed to read dead letters: %w", err)
+		}
+
+		var task Task
+		if err := json.Unmarshal([]byte(value), &task); err != nil {
+			continue
+		}
+		task.Attempts = 0
+		task.LastError = ""
+		task.FailedAt = nil
+		if err := r.add(ctx, task); err != nil {
+			r.client.RPush(context.WithoutCancel(ctx), r.key("dead"), value)
+			return queued, err
+		}
+		queued++
+	}
+}
@@ -0,0 +1,336 @@
+// Package reminders runs scheduled prompts: when one is due, the prompt is
+// posted to its conversation on the user's behalf, the AI reply is
+// appended, and participants are notified over the event bus and the
+// prompt's webhook.
+package reminders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/ai/templates"
+	"github.com/shivaluma/eino-agent/internal/billing"
+	"github.com/shivaluma/eino-agent/internal/convlock"
+	"github.com/shivaluma/eino-agent/internal/events"
+	"github.com/shivaluma/eino-agent/internal/guardrails"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/memory"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/queue"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/scheduler"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+)
+
+// historyLimit matches the number of recent messages the chat endpoints
+// load for the context strategy
+const historyLimit = 50
+
+// errNoAccess stops a schedule whose user may no longer post to the
+// conversation
+var errNoAccess = errors.New("user can no longer post to this conversation")
+
+// Config controls how due prompts are picked up
+type Config struct {
+	// BatchSize is how many due prompts one poll runs
+	BatchSize int
+
+	// Lease is how long a claimed prompt is reserved for the instance
+	// running it before another instance may retry it
+	Lease time.Duration
+
+	// WebhookTimeout bounds each webhook call
+	WebhookTimeout time.Duration
+
+	// WebhookSecret signs webhook requests
+	WebhookSecret string
+
+	// RequireHTTPS refuses to call webhooks over plain http
+	RequireHTTPS bool
+}
+
+// Runner executes due scheduled prompts
+type Runner struct {
+	schedules    *repository.ScheduleRepository
+	convRepo     *repository.ConversationRepository
+	participants *repository.ParticipantRepository
+	settingsRepo *repository.SettingsRepository
+	aiService    ai.Service
+	events       events.Bus
+	memories     *memory.Store
+	guard        *guardrails.Guard
+	usage        *billing.Recorder
+	locks        convlock.Locker
+	queue        *ai.Queue
+	tasks        queue.Queue
+	webhooks     *http.Client
+	config       Config
+}
+
+func NewRunner(schedules *repository.ScheduleRepository, convRepo *repository.ConversationRepository, participants *repository.ParticipantRepository, settingsRepo *repository.SettingsRepository, aiService ai.Service, bus events.Bus, memories *memory.Store, guard *guardrails.Guard, usage *billing.Recorder, locks convlock.Locker, genQueue *ai.Queue, tasks queue.Queue, config Config) *Runner {
+	return &Runner{
+		schedules:    schedules,
+		convRepo:     convRepo,
+		participants: participants,
+		settingsRepo: settingsRepo,
+		aiService:    aiService,
+		events:       bus,
+		memories:     memories,
+		guard:        guard,
+		usage:        usage,
+		locks:        locks,
+		queue:        genQueue,
+		tasks:        tasks,
+		webhooks:     newWebhookClient(config.WebhookTimeout, config.RequireHTTPS),
+		config:       config,
+	}
+}
+
+// NextRun returns when a prompt should next run after t, or nil if it
+// shouldn't run again
+func NextRun(p *models.ScheduledPrompt, after time.Time) (*time.Time, error) {
+	if p.Cron == nil {
+		if p.RunAt != nil && p.RunAt.After(after) {
+			return p.RunAt, nil
+		}
+		return nil, nil
+	}
+
+	cron, err := scheduler.ParseCron(*p.Cron)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	next := cron.Next(after.In(loc))
+	if next.IsZero() {
+		return nil, nil
+	}
+	next = next.UTC()
+	return &next, nil
+}
+
+// RunDue runs every due prompt. It is registered as a scheduler job.
+func (r *Runner) RunDue(ctx context.Context) (string, error) {
+	due, err := r.schedules.ClaimDue(ctx, r.config.BatchSize, r.config.Lease)
+	if err != nil {
+		return "", err
+	}
+
+	failed := 0
+	for i := range due {
+		if !r.run(ctx, &due[i]) {
+			failed++
+		}
+	}
+
+	return fmt.Sprintf("ran %d scheduled prompts, %d failed", len(due), failed), nil
+}
+
+// run executes one claimed prompt, schedules its next run and sends the
+// notifications. It reports whether the prompt succeeded.
+func (r *Runner) run(ctx context.Context, p *models.ScheduledPrompt) bool {
+	log := logger.ModuleContext(ctx, "reminders").With().
+		Str("schedule_id", p.ID.String()).
+		Str("conversation_id", p.ConversationID.String()).
+		Logger()
+
+	conversation, reply, runErr := r.execute(ctx, p)
+	if errors.Is(runErr, convlock.ErrBusy) || errors.Is(runErr, ai.ErrOverloaded) {
+		// A message is being answered in the conversation or the server is
+		// saturated; try again on the next poll
+		log.Info().Err(runErr).Msg("Deferring scheduled prompt")
+		if err := r.schedules.Release(ctx, p.ID); err != nil {
+			log.Error().Err(err).Msg("Failed to release scheduled prompt")
+		}
+		return true
+	}
+
+	next, err := NextRun(p, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to compute next run, stopping schedule")
+		next = nil
+	}
+	// Neither access nor a blocked prompt comes back by retrying
+	var blocked *guardrails.BlockedError
+	if errors.Is(runErr, errNoAccess) || errors.As(runErr, &blocked) {
+		next = nil
+	}
+
+	var lastError *string
+	if runErr != nil {
+		msg := runErr.Error()
+		lastError = &msg
+		log.Warn().Err(runErr).Msg("Scheduled prompt failed")
+	}
+	if err := r.schedules.Complete(ctx, p.ID, next, lastError); err != nil {
+		log.Error().Err(err).Msg("Failed to record scheduled prompt run")
+	}
+
+	var messageID int64
+	if reply != nil {
+		messageID = reply.ID
+	}
+	if conversation != nil {
+		events.PublishConversation(ctx, r.events, r.participants, conversation, events.NewScheduledPromptRan(p.ConversationID, p.ID, messageID, runErr))
+	}
+	if p.WebhookURL != nil {
+		if err := r.notifyWebhook(ctx, *p.WebhookURL, p, reply, runErr); err != nil {
+			log.Warn().Err(err).Msg("Failed to queue scheduled prompt webhook")
+		}
+	}
+
+	return runErr == nil
+}
+
+// execute posts the prompt to its conversation and saves the AI reply
+func (r *Runner) execute(ctx context.Context, p *models.ScheduledPrompt) (*models.Conversation, *models.Message, error) {
+	conversation, err := r.convRepo.GetByID(ctx, p.ConversationID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch conversation: %w", err)
+	}
+	if conversation == nil {
+		return nil, nil, errNoAccess
+	}
+
+	unlock, err := r.locks.Lock(ctx, conversation.ID)
+	if err != nil {
+		return conversation, nil, err
+	}
+	defer unlock()
+
+	// Reserve the generation slot before the prompt is posted, so a
+	// deferred run doesn't leave it unanswered
+	ctx, release, err := r.queue.Acquire(ctx)
+	if err != nil {
+		return conversation, nil, err
+	}
+	defer release()
+
+	// Like in the API, leaving an organization ends access to its
+	// conversations
+	role := models.ParticipantRoleOwner
+	if conversation.UserID != p.UserID || conversation.OrgID != nil {
+		role, err = r.participants.GetRole(ctx, conversation.ID, p.UserID)
+		if err != nil {
+			return conversation, nil, fmt.Errorf("failed to check conversation access: %w", err)
+		}
+	}
+	if !models.CanWrite(role) {
+		return conversation, nil, errNoAccess
+	}
+
+	// The rules may have changed since the prompt was scheduled
+	check := r.guard.Check(ctx, guardrails.TargetMessage, p.Prompt)
+	if check.Blocked {
+		return conversation, nil, &guardrails.BlockedError{Rule: check.Rule}
+	}
+	prompt := check.Text
+
+	// An organization over its quota skips runs until the quota resets
+	if err := r.usage.CheckOrgQuota(ctx, conversation.OrgID); err != nil {
+		return conversation, nil, err
+	}
+	ctx = ai.WithOrg(ctx, conversation.OrgID)
+
+	settings, err := r.settingsRepo.GetByUserID(ctx, p.UserID)
+	if err != nil {
+		logger.ModuleContext(ctx, "reminders").Warn().Err(err).Msg("Failed to load user settings")
+	}
+	if settings == nil {
+		settings = &models.UserSettings{UserID: p.UserID}
+	}
+
+	messages, err := r.convRepo.GetRecentMessages(ctx, conversation.ID, historyLimit)
+	if err != nil {
+		return conversation, nil, fmt.Errorf("failed to fetch messages: %w", err)
+	}
+	var history []*schema.Message
+	for _, msg := range messages {
+		switch msg.SenderType {
+		case models.SenderTypeUser:
+			history = append(history, schema.UserMessage(msg.Content))
+		case models.SenderTypeAgent:
+			history = append(history, schema.AssistantMessage(msg.Content, nil))
+		}
+	}
+
+	metadata, _ := json.Marshal(map[string]string{"scheduled_prompt_id": p.ID.String()})
+	userMessage := &models.Message{
+		ConversationID: conversation.ID,
+		SenderID:       p.UserID,
+		SenderType:     models.SenderTypeUser,
+		Content:        prompt,
+		Metadata:       metadata,
+	}
+	if err := r.convRepo.CreateMessage(ctx, userMessage); err != nil {
+		return conversation, nil, fmt.Errorf("failed to save message: %w", err)
+	}
+
+	language := templates.DefaultLanguage
+	if settings.Language != nil {
+		if l := templates.NormalizeLanguage(*settings.Language); l != "" {
+			language = l
+		}
+	}
+
+	request := &ai.ChatRequest{
+		Message:        prompt,
+		ConversationID: conversation.ID.String(),
+		UserID:         p.UserID.String(),
+		History:        history,
+		Language:       language,
+		Temperature:    settings.Temperature,
+		MaxTokens:      settings.MaxTokens,
+		Memories:       r.memories.Recall(ctx, p.UserID),
+	}
+	if settings.Model != nil {
+		request.Model = *settings.Model
+	}
+	if conversation.Model != nil {
+		request.Model = *conversation.Model
+	}
+	if conversation.Persona != nil {
+		request.Persona = *conversation.Persona
+	}
+	if conversation.SystemPrompt != nil {
+		request.SystemPrompt = *conversation.SystemPrompt
+	}
+	if conversation.ContextStrategy != nil {
+		request.ContextStrategy = *conversation.ContextStrategy
+	}
+
+	response, err := r.aiService.Generate(ctx, request)
+	if err != nil {
+		return conversation, nil, fmt.Errorf("failed to generate response: %w", err)
+	}
+
+	reply := &models.Message{
+		ConversationID: conversation.ID,
+		SenderID:       uuid.Nil,
+		SenderType:     models.SenderTypeAgent,
+		Content:        response.Content,
+		Metadata:       response.Metadata(request).JSON(),
+	}
+	err = r.convRepo.CreateMessage(ctx, reply)
+	r.usage.Record(ctx, p.UserID, conversation.ID, reply.ID, response)
+	if err != nil {
+		return conversation, nil, fmt.Errorf("failed to save AI response: %w", err)
+	}
+
+	if err := r.convRepo.UpdateTimestamp(ctx, conversation.ID); err != nil {
+		logger.ModuleContext(ctx, "reminders").Warn().Err(err).Msg("Failed to update conversation timestamp")
+	}
+
+	return conversation, reply, nil
+}
@@ -0,0 +1,208 @@
+package reminders
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// webhookPayload is POSTed to a scheduled prompt's webhook after each run
+type webhookPayload struct {
+	ScheduleID     uuid.UUID `json:"schedule_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	Prompt         string    `json:"prompt"`
+	MessageID      int64     `json:"message_id,omitempty"`
+	Reply          string    `json:"reply,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	RanAt          time.Time `json:"ran_at"`
+}
+
+// Webhook requests carry the Unix time they were signed at and the
+// HMAC-SHA256 of "<timestamp>.<body>" under SCHEDULE_WEBHOOK_SECRET, hex
+// encoded after "sha256="
+const (
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// Reasons a webhook URL is refused
+var (
+	ErrWebhookScheme  = errors.New("webhook URL must be an http or https URL")
+	ErrWebhookHTTPS   = errors.New("webhook URL must be an https URL")
+	ErrWebhookAddress = errors.New("webhook address is not public")
+)
+
+// nonPublic are the ranges outside the private, loopback and link-local
+// ones netip knows that still don't reach the public internet
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// publicAddr reports whether addr is on the public internet
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublic {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckWebhookURL checks a webhook URL before it is saved or called. Host
+// names can only be checked once resolved, which the webhook client does
+// on every connection.
+func CheckWebhookURL(raw string, requireHTTPS bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ErrWebhookScheme
+	}
+	if requireHTTPS && u.Scheme != "https" {
+		return ErrWebhookHTTPS
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !publicAddr(addr) {
+		return ErrWebhookAddress
+	}
+	return nil
+}
+
+// newWebhookClient creates the client webhooks are called with. Users pick
+// the URLs, so it only connects to public addresses: the check runs on the
+// resolved address of every connection, redirects included, and a proxy
+// isn't used since it would be dialed instead.
+func newWebhookClient(timeout time.Duration, requireHTTPS bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addr) {
+				return fmt.Errorf("%w: %s", ErrWebhookAddress, addr)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return CheckWebhookURL(req.URL.String(), requireHTTPS)
+		},
+	}
+}
+
+// signWebhook returns the WebhookSignatureHeader of a body signed at
+// timestamp
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// TaskWebhook is the queued task that delivers the outcome of a run to a
+// scheduled prompt's webhook
+const TaskWebhook = "scheduled_prompt_webhook"
+
+// webhookTask is the payload of a TaskWebhook task
+type webhookTask struct {
+	URL     string         `json:"url"`
+	Payload webhookPayload `json:"payload"`
+}
+
+// notifyWebhook queues sending the outcome of a run to the prompt's webhook
+func (r *Runner) notifyWebhook(ctx context.Context, url string, p *models.ScheduledPrompt, reply *models.Message, runErr error) error {
+	payload := webhookPayload{
+		ScheduleID:     p.ID,
+		ConversationID: p.ConversationID,
+		Prompt:         p.Prompt,
+		RanAt:          time.Now().UTC(),
+	}
+	if reply != nil {
+		payload.MessageID = reply.ID
+		payload.Reply = reply.Content
+	}
+	if runErr != nil {
+		payload.Error = runErr.Error()
+	}
+
+	return r.tasks.Enqueue(ctx, TaskWebhook, webhookTask{URL: url, Payload: payload})
+}
+
+// HandleWebhook runs a TaskWebhook task. Any 2xx response counts as
+// delivered; other responses are retried. A URL that is no longer allowed,
+// such as an http one saved before https was required, is dropped.
+func (r *Runner) HandleWebhook(ctx context.Context, payload json.RawMessage) error {
+	var task webhookTask
+	if err := json.Unmarshal(payload, &task); err != nil {
+		return fmt.Errorf("invalid webhook task: %w", err)
+	}
+
+	if err := CheckWebhookURL(task.URL, r.config.RequireHTTPS); err != nil {
+		logger.ModuleContext(ctx, "reminders").Warn().Err(err).Str("schedule_id", task.Payload.ScheduleID.String()).Msg("Dropping scheduled prompt webhook")
+		return nil
+	}
+
+	body, err := json.Marshal(task.Payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, task.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, signWebhook(r.config.WebhookSecret, timestamp, body))
+
+	resp, err := r.webhooks.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
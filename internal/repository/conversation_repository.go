@@ -2,6 +2,9 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"time"
 
 	"github.com/shivaluma/eino-agent/internal/database"
 	"github.com/shivaluma/eino-agent/internal/models"
@@ -10,6 +13,10 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
+// ErrConversationExists is returned when creating a conversation with an ID
+// that is taken
+var ErrConversationExists = errors.New("conversation already exists")
+
 type ConversationRepository struct {
 	db *database.DB
 }
@@ -18,44 +25,184 @@ func NewConversationRepository(db *database.DB) *ConversationRepository {
 	return &ConversationRepository{db: db}
 }
 
+// Create inserts a conversation and registers its creator as the owner
+// participant
 func (r *ConversationRepository) Create(ctx context.Context, conversation *models.Conversation) error {
 	query := `
-		INSERT INTO conversations (user_id, title)
-		VALUES ($1, $2)
-		RETURNING id, created_at, updated_at`
+		WITH c AS (
+			INSERT INTO conversations (user_id, title, persona, system_prompt, org_id)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, user_id, created_at, updated_at
+		), p AS (
+			INSERT INTO conversation_participants (conversation_id, user_id, role)
+			SELECT id, user_id, 'owner' FROM c
+		)
+		SELECT id, created_at, updated_at FROM c`
 
-	return r.db.Pool.QueryRow(ctx, query, conversation.UserID, conversation.Title).
+	return conn(ctx, r.db.Pool).QueryRow(ctx, query, conversation.UserID, conversation.Title, conversation.Persona, conversation.SystemPrompt, conversation.OrgID).
 		Scan(&conversation.ID, &conversation.CreatedAt, &conversation.UpdatedAt)
 }
 
 func (r *ConversationRepository) CreateWithID(ctx context.Context, conversation *models.Conversation) error {
 	query := `
-		INSERT INTO conversations (id, user_id, title)
-		VALUES ($1, $2, $3)
-		RETURNING created_at, updated_at`
+		WITH c AS (
+			INSERT INTO conversations (id, user_id, title, persona, system_prompt, org_id)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (id) DO NOTHING
+			RETURNING id, user_id, created_at, updated_at
+		), p AS (
+			INSERT INTO conversation_participants (conversation_id, user_id, role)
+			SELECT id, user_id, 'owner' FROM c
+		)
+		SELECT created_at, updated_at FROM c`
 
-	return r.db.Pool.QueryRow(ctx, query, conversation.ID, conversation.UserID, conversation.Title).
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, conversation.ID, conversation.UserID, conversation.Title, conversation.Persona, conversation.SystemPrompt, conversation.OrgID).
 		Scan(&conversation.CreatedAt, &conversation.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return ErrConversationExists
+	}
+	return err
 }
 
-func (r *ConversationRepository) GetByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]models.Conversation, error) {
+// Import inserts a conversation brought over from another assistant with
+// its messages, keeping their timestamps, and registers the user as the
+// owner participant. Run it in a transaction so a failed message doesn't
+// leave half a conversation behind.
+func (r *ConversationRepository) Import(ctx context.Context, conversation *models.Conversation, messages []models.Message) error {
 	query := `
-		SELECT id, user_id, title, created_at, updated_at
-		FROM conversations
-		WHERE user_id = $1
-		ORDER BY updated_at DESC
+		WITH c AS (
+			INSERT INTO conversations (user_id, title, created_at, updated_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, user_id
+		), p AS (
+			INSERT INTO conversation_participants (conversation_id, user_id, role)
+			SELECT id, user_id, 'owner' FROM c
+		)
+		SELECT id FROM c`
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, conversation.UserID, conversation.Title, conversation.CreatedAt, conversation.UpdatedAt).
+		Scan(&conversation.ID)
+	if err != nil {
+		return err
+	}
+
+	messageQuery := `
+		INSERT INTO messages (conversation_id, sender_id, sender_type, content, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	for i := range messages {
+		message := &messages[i]
+		message.ConversationID = conversation.ID
+		err := conn(ctx, r.db.Pool).QueryRow(ctx, messageQuery,
+			message.ConversationID,
+			message.SenderID,
+			message.SenderType,
+			message.Content,
+			message.Metadata,
+			message.CreatedAt,
+		).Scan(&message.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fork copies a conversation into fork in one statement: the conversation
+// with fork's user as owner, its persona, system prompt, model and context
+// strategy, and its messages up to and including upToMessageID (all when
+// nil). Deleted messages are not copied. fork's title is used when set,
+// otherwise the source's; fork's organization is used. It returns how many
+// messages were copied.
+func (r *ConversationRepository) Fork(ctx context.Context, sourceID uuid.UUID, upToMessageID *int64, fork *models.Conversation) (int, error) {
+	query := `
+		WITH c AS (
+			INSERT INTO conversations (user_id, title, persona, system_prompt, org_id, model, context_strategy)
+			SELECT $2, COALESCE($3, title), persona, system_prompt, $5, model, context_strategy
+			FROM conversations
+			WHERE id = $1
+			RETURNING id, user_id, title, persona, system_prompt, model, context_strategy, created_at, updated_at
+		), p AS (
+			INSERT INTO conversation_participants (conversation_id, user_id, role)
+			SELECT id, user_id, 'owner' FROM c
+		), m AS (
+			INSERT INTO messages (conversation_id, sender_id, sender_type, content, metadata, content_parts, created_at)
+			SELECT c.id, src.sender_id, src.sender_type, src.content, src.metadata, src.content_parts, src.created_at
+			FROM c, messages src
+			WHERE src.conversation_id = $1
+				AND src.deleted_at IS NULL
+				AND ($4::BIGINT IS NULL OR src.id <= $4)
+			ORDER BY src.created_at ASC, src.id ASC
+			RETURNING 1
+		)
+		SELECT id, title, persona, system_prompt, model, context_strategy, created_at, updated_at, (SELECT COUNT(*) FROM m)
+		FROM c`
+
+	var copied int
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, sourceID, fork.UserID, fork.Title, upToMessageID, fork.OrgID).
+		Scan(&fork.ID, &fork.Title, &fork.Persona, &fork.SystemPrompt, &fork.Model, &fork.ContextStrategy, &fork.CreatedAt, &fork.UpdatedAt, &copied)
+	return copied, err
+}
+
+// lastMessagePreviewLength is the maximum number of characters returned as a
+// conversation's last message preview
+const lastMessagePreviewLength = 120
+
+// GetByUserID returns the conversations the user owns or participates in
+// within an organization, or their personal ones when orgID is nil, pinned
+// conversations first. Every conversation has an owner participant row, so
+// the list is driven from conversation_participants.
+func (r *ConversationRepository) GetByUserID(ctx context.Context, userID uuid.UUID, orgID *uuid.UUID, limit, offset int) ([]models.ConversationSummary, error) {
+	query := `
+		SELECT c.id, c.user_id, c.title, c.persona, c.system_prompt, c.created_at, c.updated_at, c.org_id,
+			LEFT(lm.content, $4), lm.created_at, COALESCE(mc.message_count, 0),
+			p.role, p.pinned, p.last_read_message_id, COALESCE(mc.unread_count, 0)
+		FROM conversation_participants p
+		JOIN conversations c ON c.id = p.conversation_id
+		LEFT JOIN LATERAL (
+			SELECT content, created_at
+			FROM messages
+			WHERE conversation_id = c.id AND deleted_at IS NULL
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		) lm ON true
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) AS message_count,
+				COUNT(*) FILTER (WHERE id > COALESCE(p.last_read_message_id, 0) AND sender_id <> $1) AS unread_count
+			FROM messages
+			WHERE conversation_id = c.id AND deleted_at IS NULL
+		) mc ON true
+		WHERE p.user_id = $1 AND c.org_id IS NOT DISTINCT FROM $5
+		ORDER BY p.pinned DESC, c.updated_at DESC
 		LIMIT $2 OFFSET $3`
 
-	rows, err := r.db.Pool.Query(ctx, query, userID, limit, offset)
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, userID, limit, offset, lastMessagePreviewLength, orgID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var conversations []models.Conversation
+	var conversations []models.ConversationSummary
 	for rows.Next() {
-		var conv models.Conversation
-		err := rows.Scan(&conv.ID, &conv.UserID, &conv.Title, &conv.CreatedAt, &conv.UpdatedAt)
+		var conv models.ConversationSummary
+		err := rows.Scan(
+			&conv.ID,
+			&conv.UserID,
+			&conv.Title,
+			&conv.Persona,
+			&conv.SystemPrompt,
+			&conv.CreatedAt,
+			&conv.UpdatedAt,
+			&conv.OrgID,
+			&conv.LastMessagePreview,
+			&conv.LastMessageAt,
+			&conv.MessageCount,
+			&conv.Role,
+			&conv.Pinned,
+			&conv.LastReadMessageID,
+			&conv.UnreadCount,
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -65,15 +212,128 @@ func (r *ConversationRepository) GetByUserID(ctx context.Context, userID uuid.UU
 	return conversations, rows.Err()
 }
 
+// GetRecentWithMessages returns the user's first conversations in the order
+// and scope of GetByUserID, each with up to messageLimit of its latest
+// messages, in a single query
+func (r *ConversationRepository) GetRecentWithMessages(ctx context.Context, userID uuid.UUID, orgID *uuid.UUID, limit, messageLimit int) ([]models.ConversationHistory, error) {
+	query := `
+		WITH recent AS (
+			SELECT c.id, c.user_id, c.title, c.persona, c.system_prompt, c.created_at, c.updated_at, c.org_id,
+				LEFT(lm.content, $4) AS last_message_preview, lm.created_at AS last_message_at,
+				COALESCE(mc.message_count, 0) AS message_count, p.role, p.pinned,
+				p.last_read_message_id, COALESCE(mc.unread_count, 0) AS unread_count
+			FROM conversation_participants p
+			JOIN conversations c ON c.id = p.conversation_id
+			LEFT JOIN LATERAL (
+				SELECT content, created_at
+				FROM messages
+				WHERE conversation_id = c.id AND deleted_at IS NULL
+				ORDER BY created_at DESC, id DESC
+				LIMIT 1
+			) lm ON true
+			LEFT JOIN LATERAL (
+				SELECT COUNT(*) AS message_count,
+					COUNT(*) FILTER (WHERE id > COALESCE(p.last_read_message_id, 0) AND sender_id <> $1) AS unread_count
+				FROM messages
+				WHERE conversation_id = c.id AND deleted_at IS NULL
+			) mc ON true
+			WHERE p.user_id = $1 AND c.org_id IS NOT DISTINCT FROM $5
+			ORDER BY p.pinned DESC, c.updated_at DESC
+			LIMIT $2
+		)
+		SELECT r.id, r.user_id, r.title, r.persona, r.system_prompt, r.created_at, r.updated_at, r.org_id,
+			r.last_message_preview, r.last_message_at, r.message_count, r.role, r.pinned,
+			r.last_read_message_id, r.unread_count,
+			m.id, m.sender_id, m.sender_type, m.content, m.metadata, m.content_parts, m.created_at
+		FROM recent r
+		LEFT JOIN LATERAL (
+			SELECT id, sender_id, sender_type, content, metadata, content_parts, created_at
+			FROM messages
+			WHERE conversation_id = r.id AND deleted_at IS NULL
+			ORDER BY created_at DESC, id DESC
+			LIMIT $3
+		) m ON true
+		ORDER BY r.pinned DESC, r.updated_at DESC, r.id, m.created_at ASC, m.id ASC`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, userID, limit, messageLimit, lastMessagePreviewLength, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Rows come one per message, grouped by conversation
+	var conversations []models.ConversationHistory
+	for rows.Next() {
+		var conv models.ConversationHistory
+		var (
+			messageID  *int64
+			senderID   *uuid.UUID
+			senderType *string
+			content    *string
+			metadata   []byte
+			parts      []models.ContentPart
+			createdAt  *time.Time
+		)
+		err := rows.Scan(
+			&conv.ID,
+			&conv.UserID,
+			&conv.Title,
+			&conv.Persona,
+			&conv.SystemPrompt,
+			&conv.CreatedAt,
+			&conv.UpdatedAt,
+			&conv.OrgID,
+			&conv.LastMessagePreview,
+			&conv.LastMessageAt,
+			&conv.MessageCount,
+			&conv.Role,
+			&conv.Pinned,
+			&conv.LastReadMessageID,
+			&conv.UnreadCount,
+			&messageID,
+			&senderID,
+			&senderType,
+			&content,
+			&metadata,
+			&parts,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if n := len(conversations); n == 0 || conversations[n-1].ID != conv.ID {
+			conv.Messages = []models.Message{}
+			conversations = append(conversations, conv)
+		}
+		if messageID == nil {
+			continue
+		}
+		last := &conversations[len(conversations)-1]
+		last.Messages = append(last.Messages, models.Message{
+			ID:             *messageID,
+			ConversationID: conv.ID,
+			SenderID:       *senderID,
+			SenderType:     *senderType,
+			Content:        *content,
+			Metadata:       metadata,
+			Parts:          parts,
+			CreatedAt:      *createdAt,
+		})
+	}
+
+	return conversations, rows.Err()
+}
+
 func (r *ConversationRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Conversation, error) {
 	query := `
-		SELECT id, user_id, title, created_at, updated_at
+		SELECT id, user_id, title, persona, system_prompt, created_at, updated_at, org_id, context_strategy, model
 		FROM conversations
 		WHERE id = $1`
 
 	conversation := &models.Conversation{}
-	err := r.db.Pool.QueryRow(ctx, query, id).
-		Scan(&conversation.ID, &conversation.UserID, &conversation.Title, &conversation.CreatedAt, &conversation.UpdatedAt)
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, id).
+		Scan(&conversation.ID, &conversation.UserID, &conversation.Title, &conversation.Persona, &conversation.SystemPrompt, &conversation.CreatedAt, &conversation.UpdatedAt, &conversation.OrgID, &conversation.ContextStrategy, &conversation.Model)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -85,6 +345,46 @@ func (r *ConversationRepository) GetByID(ctx context.Context, id uuid.UUID) (*mo
 	return conversation, nil
 }
 
+// accessibleConversation selects conversation $1 with the role of user $2
+// in it: owner of their personal conversations, else their participant role
+// while they belong to the conversation's organization. The role is NULL
+// for users without access.
+const accessibleConversation = `
+		SELECT c.*, CASE
+				WHEN c.user_id = $2 AND c.org_id IS NULL THEN 'owner'
+				ELSE p.role
+			END AS role
+		FROM conversations c
+		LEFT JOIN conversation_participants p ON p.conversation_id = c.id AND p.user_id = $2
+			AND (c.org_id IS NULL OR EXISTS (
+				SELECT 1 FROM organization_members m
+				WHERE m.org_id = c.org_id AND m.user_id = $2
+			))
+		WHERE c.id = $1`
+
+// GetByIDForUser returns a conversation with the user's role in it, or nil
+// if it doesn't exist or the user has no access to it
+func (r *ConversationRepository) GetByIDForUser(ctx context.Context, id, userID uuid.UUID) (*models.Conversation, string, error) {
+	query := `
+		SELECT id, user_id, title, persona, system_prompt, created_at, updated_at, org_id, context_strategy, model, role
+		FROM (` + accessibleConversation + `) a
+		WHERE role IS NOT NULL`
+
+	conversation := &models.Conversation{}
+	var role string
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, id, userID).
+		Scan(&conversation.ID, &conversation.UserID, &conversation.Title, &conversation.Persona, &conversation.SystemPrompt, &conversation.CreatedAt, &conversation.UpdatedAt, &conversation.OrgID, &conversation.ContextStrategy, &conversation.Model, &role)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	return conversation, role, nil
+}
+
 func (r *ConversationRepository) Update(ctx context.Context, conversation *models.Conversation) error {
 	query := `
 		UPDATE conversations
@@ -92,43 +392,245 @@ func (r *ConversationRepository) Update(ctx context.Context, conversation *model
 		WHERE id = $1
 		RETURNING updated_at`
 
-	return r.db.Pool.QueryRow(ctx, query, conversation.ID, conversation.Title).
+	return conn(ctx, r.db.Pool).QueryRow(ctx, query, conversation.ID, conversation.Title).
 		Scan(&conversation.UpdatedAt)
 }
 
+// ReplaceTitle sets a conversation's title unless it changed from
+// placeholder, so a generated title never overwrites a rename. It reports
+// whether the title was set.
+func (r *ConversationRepository) ReplaceTitle(ctx context.Context, conversationID uuid.UUID, placeholder, title string) (bool, error) {
+	query := `
+		UPDATE conversations
+		SET title = $3
+		WHERE id = $1 AND title = $2`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query, conversationID, placeholder, title)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// UpdatePersona switches a conversation to a persona. A custom system
+// prompt would take precedence over the persona, so it is cleared.
+func (r *ConversationRepository) UpdatePersona(ctx context.Context, conversation *models.Conversation) error {
+	query := `
+		UPDATE conversations
+		SET persona = $2, system_prompt = NULL, updated_at = NOW()
+		WHERE id = $1
+		RETURNING system_prompt, updated_at`
+
+	return conn(ctx, r.db.Pool).QueryRow(ctx, query, conversation.ID, conversation.Persona).
+		Scan(&conversation.SystemPrompt, &conversation.UpdatedAt)
+}
+
+// UpdateContextStrategy sets how much of a conversation is sent with each
+// message; nil goes back to the server default
+func (r *ConversationRepository) UpdateContextStrategy(ctx context.Context, conversation *models.Conversation) error {
+	query := `
+		UPDATE conversations
+		SET context_strategy = $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`
+
+	return conn(ctx, r.db.Pool).QueryRow(ctx, query, conversation.ID, conversation.ContextStrategy).
+		Scan(&conversation.UpdatedAt)
+}
+
+// UpdateModel pins the model answering a conversation; nil goes back to
+// the user's settings
+func (r *ConversationRepository) UpdateModel(ctx context.Context, conversation *models.Conversation) error {
+	query := `
+		UPDATE conversations
+		SET model = $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`
+
+	return conn(ctx, r.db.Pool).QueryRow(ctx, query, conversation.ID, conversation.Model).
+		Scan(&conversation.UpdatedAt)
+}
+
+// TogglePinned flips the user's pin on a conversation and returns the new
+// state. role is used if the user has no participant row yet.
+func (r *ConversationRepository) TogglePinned(ctx context.Context, conversationID, userID uuid.UUID, role string) (bool, error) {
+	query := `
+		INSERT INTO conversation_participants (conversation_id, user_id, role, pinned)
+		VALUES ($1, $2, $3, TRUE)
+		ON CONFLICT (conversation_id, user_id) DO UPDATE
+		SET pinned = NOT conversation_participants.pinned
+		RETURNING pinned`
+
+	var pinned bool
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, conversationID, userID, role).Scan(&pinned)
+	return pinned, err
+}
+
+// MarkRead moves the user's read marker in a conversation to messageID and
+// returns the marker, which never moves back. role is used if the user has
+// no participant row yet.
+func (r *ConversationRepository) MarkRead(ctx context.Context, conversationID, userID uuid.UUID, role string, messageID int64) (int64, error) {
+	query := `
+		INSERT INTO conversation_participants (conversation_id, user_id, role, last_read_message_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (conversation_id, user_id) DO UPDATE
+		SET last_read_message_id = GREATEST(conversation_participants.last_read_message_id, EXCLUDED.last_read_message_id)
+		RETURNING last_read_message_id`
+
+	var lastRead int64
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, conversationID, userID, role, messageID).Scan(&lastRead)
+	return lastRead, err
+}
+
 func (r *ConversationRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `DELETE FROM conversations WHERE id = $1`
-	_, err := r.db.Pool.Exec(ctx, query, id)
+	_, err := conn(ctx, r.db.Pool).Exec(ctx, query, id)
 	return err
 }
 
 func (r *ConversationRepository) CreateMessage(ctx context.Context, message *models.Message) error {
 	query := `
-		INSERT INTO messages (conversation_id, sender_id, sender_type, content, metadata)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO messages (conversation_id, sender_id, sender_type, content, metadata, content_parts)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at`
 
-	return r.db.Pool.QueryRow(ctx, query,
+	// Messages sent as plain text have NULL parts
+	var parts []byte
+	if len(message.Parts) > 0 {
+		parts, _ = json.Marshal(message.Parts) // only plain fields, can't fail
+	}
+
+	return conn(ctx, r.db.Pool).QueryRow(ctx, query,
 		message.ConversationID,
 		message.SenderID,
 		message.SenderType,
 		message.Content,
 		message.Metadata,
+		parts,
 	).Scan(&message.ID, &message.CreatedAt)
 }
 
+// UpdateMessageContent replaces the content and metadata of a message, as a
+// streamed reply is saved while it is generated
+func (r *ConversationRepository) UpdateMessageContent(ctx context.Context, message *models.Message) error {
+	query := `UPDATE messages SET content = $3, metadata = $4 WHERE conversation_id = $1 AND id = $2`
+
+	_, err := conn(ctx, r.db.Pool).Exec(ctx, query, message.ConversationID, message.ID, message.Content, message.Metadata)
+	return err
+}
+
+// GetMessages returns a page of the conversation's messages, oldest first.
+// Soft-deleted messages are left out.
 func (r *ConversationRepository) GetMessages(ctx context.Context, conversationID uuid.UUID, limit, offset int) ([]models.Message, error) {
+	return r.listMessages(ctx, conversationID, limit, offset, false)
+}
+
+// GetMessagesIncludingDeleted is GetMessages with soft-deleted messages
+// included. Deleted messages have DeletedAt set.
+func (r *ConversationRepository) GetMessagesIncludingDeleted(ctx context.Context, conversationID uuid.UUID, limit, offset int) ([]models.Message, error) {
+	return r.listMessages(ctx, conversationID, limit, offset, true)
+}
+
+// GetMessagesForUser returns a page of a conversation's messages, oldest
+// first, with the user's role in it. The role is empty when the
+// conversation doesn't exist or the user has no access to it. Deleted
+// messages are included on request for the owner only.
+func (r *ConversationRepository) GetMessagesForUser(ctx context.Context, conversationID, userID uuid.UUID, limit, offset int, includeDeleted bool) ([]models.Message, string, error) {
 	query := `
-		SELECT id, conversation_id, sender_id, sender_type, content, metadata, created_at
+		SELECT a.role, m.id, m.sender_id, m.sender_type, m.content, m.metadata, m.content_parts, m.created_at, m.deleted_at
+		FROM (` + accessibleConversation + `) a
+		LEFT JOIN LATERAL (
+			SELECT id, sender_id, sender_type, content, metadata, content_parts, created_at, deleted_at
+			FROM messages
+			WHERE conversation_id = a.id AND (deleted_at IS NULL OR ($5 AND a.role = 'owner'))
+			ORDER BY created_at ASC
+			LIMIT $3 OFFSET $4
+		) m ON TRUE
+		WHERE a.role IS NOT NULL
+		ORDER BY m.created_at ASC`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, conversationID, userID, limit, offset, includeDeleted)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var (
+		role     string
+		messages []models.Message
+	)
+	for rows.Next() {
+		// A conversation without messages in the page comes back as one
+		// row of NULL message columns
+		var (
+			messageID  *int64
+			senderID   *uuid.UUID
+			senderType *string
+			content    *string
+			metadata   []byte
+			parts      []models.ContentPart
+			createdAt  *time.Time
+			deletedAt  *time.Time
+		)
+		if err := rows.Scan(&role, &messageID, &senderID, &senderType, &content, &metadata, &parts, &createdAt, &deletedAt); err != nil {
+			return nil, "", err
+		}
+		if messageID == nil {
+			continue
+		}
+		messages = append(messages, models.Message{
+			ID:             *messageID,
+			ConversationID: conversationID,
+			SenderID:       *senderID,
+			SenderType:     *senderType,
+			Content:        *content,
+			Metadata:       metadata,
+			Parts:          parts,
+			CreatedAt:      *createdAt,
+			DeletedAt:      deletedAt,
+		})
+	}
+
+	return messages, role, rows.Err()
+}
+
+// GetRecentMessages returns the latest limit messages of a conversation,
+// oldest first, for building the model's context
+func (r *ConversationRepository) GetRecentMessages(ctx context.Context, conversationID uuid.UUID, limit int) ([]models.Message, error) {
+	query := `
+		SELECT id, conversation_id, sender_id, sender_type, content, metadata, content_parts, created_at, deleted_at
+		FROM (
+			SELECT id, conversation_id, sender_id, sender_type, content, metadata, content_parts, created_at, deleted_at
+			FROM messages
+			WHERE conversation_id = $1 AND deleted_at IS NULL
+			ORDER BY created_at DESC
+			LIMIT $2
+		) recent
+		ORDER BY created_at ASC`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, conversationID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+func (r *ConversationRepository) listMessages(ctx context.Context, conversationID uuid.UUID, limit, offset int, includeDeleted bool) ([]models.Message, error) {
+	query := `
+		SELECT id, conversation_id, sender_id, sender_type, content, metadata, content_parts, created_at, deleted_at
 		FROM messages
-		WHERE conversation_id = $1
+		WHERE conversation_id = $1 AND ($4 OR deleted_at IS NULL)
 		ORDER BY created_at ASC
 		LIMIT $2 OFFSET $3`
 
-	rows, err := r.db.Pool.Query(ctx, query, conversationID, limit, offset)
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, conversationID, limit, offset, includeDeleted)
 	if err != nil {
 		return nil, err
 	}
+	return scanMessages(rows)
+}
+
+func scanMessages(rows pgx.Rows) ([]models.Message, error) {
 	defer rows.Close()
 
 	var messages []models.Message
@@ -141,7 +643,9 @@ func (r *ConversationRepository) GetMessages(ctx context.Context, conversationID
 			&msg.SenderType,
 			&msg.Content,
 			&msg.Metadata,
+			&msg.Parts,
 			&msg.CreatedAt,
+			&msg.DeletedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -152,16 +656,75 @@ func (r *ConversationRepository) GetMessages(ctx context.Context, conversationID
 	return messages, rows.Err()
 }
 
+// GetMessageByID returns a message of the given conversation, or nil if there
+// is no such message or it has been deleted
+func (r *ConversationRepository) GetMessageByID(ctx context.Context, conversationID uuid.UUID, messageID int64) (*models.Message, error) {
+	query := `
+		SELECT id, conversation_id, sender_id, sender_type, content, metadata, content_parts, created_at
+		FROM messages
+		WHERE conversation_id = $1 AND id = $2 AND deleted_at IS NULL`
+
+	msg := &models.Message{}
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, conversationID, messageID).
+		Scan(&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.SenderType, &msg.Content, &msg.Metadata, &msg.Parts, &msg.CreatedAt)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return msg, nil
+}
+
 func (r *ConversationRepository) GetMessageCount(ctx context.Context, conversationID uuid.UUID) (int, error) {
-	query := `SELECT COUNT(*) FROM messages WHERE conversation_id = $1`
+	query := `SELECT COUNT(*) FROM messages WHERE conversation_id = $1 AND deleted_at IS NULL`
 
 	var count int
-	err := r.db.Pool.QueryRow(ctx, query, conversationID).Scan(&count)
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, conversationID).Scan(&count)
+	return count, err
+}
+
+// SoftDeleteMessage marks a message as deleted. It reports false if the
+// message does not exist or is already deleted.
+func (r *ConversationRepository) SoftDeleteMessage(ctx context.Context, conversationID uuid.UUID, messageID int64) (bool, error) {
+	query := `
+		UPDATE messages
+		SET deleted_at = NOW()
+		WHERE conversation_id = $1 AND id = $2 AND deleted_at IS NULL`
+
+	tag, err := conn(ctx, r.db.Pool).Exec(ctx, query, conversationID, messageID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// PurgeDeletedMessages permanently removes messages soft-deleted before the
+// given time and returns how many were removed
+func (r *ConversationRepository) PurgeDeletedMessages(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM messages WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+
+	tag, err := conn(ctx, r.db.Pool).Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// CountDeletedMessages counts messages soft-deleted before the given time,
+// which PurgeDeletedMessages would remove
+func (r *ConversationRepository) CountDeletedMessages(ctx context.Context, before time.Time) (int64, error) {
+	query := `SELECT COUNT(*) FROM messages WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+
+	var count int64
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, before).Scan(&count)
 	return count, err
 }
 
 func (r *ConversationRepository) UpdateTimestamp(ctx context.Context, conversationID uuid.UUID) error {
 	query := `UPDATE conversations SET updated_at = NOW() WHERE id = $1`
-	_, err := r.db.Pool.Exec(ctx, query, conversationID)
+	_, err := conn(ctx, r.db.Pool).Exec(ctx, query, conversationID)
 	return err
 }
@@ -0,0 +1,179 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+func TestConversationRepository_CreateWithMessages(t *testing.T) {
+	ctx := context.Background()
+	env := testutil.NewEnv(t)
+	owner := env.CreateUser(t, "owner@example.com", "password123")
+	other := env.CreateUser(t, "other@example.com", "password123")
+
+	title := "Dinner"
+	conversation := &models.Conversation{UserID: owner.ID, Title: &title}
+	if err := env.Conversations.Create(ctx, conversation); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	for _, m := range []struct {
+		sender     uuid.UUID
+		senderType string
+		content    string
+	}{
+		{owner.ID, models.SenderTypeUser, "What's for dinner?"},
+		{uuid.Nil, models.SenderTypeAgent, "Pasta."},
+	} {
+		message := &models.Message{ConversationID: conversation.ID, SenderID: m.sender, SenderType: m.senderType, Content: m.content}
+		if err := env.Conversations.CreateMessage(ctx, message); err != nil {
+			t.Fatalf("CreateMessage: %v", err)
+		}
+	}
+
+	messages, err := env.Conversations.GetMessages(ctx, conversation.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Content != "What's for dinner?" || messages[1].Content != "Pasta." {
+		t.Fatalf("GetMessages = %+v, want both messages oldest first", messages)
+	}
+
+	// The creator owns the conversation; others can't see it
+	got, role, err := env.Conversations.GetByIDForUser(ctx, conversation.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetByIDForUser: %v", err)
+	}
+	if got == nil || role != models.ParticipantRoleOwner {
+		t.Fatalf("GetByIDForUser(owner) = %+v, %q, want the conversation as owner", got, role)
+	}
+	got, _, err = env.Conversations.GetByIDForUser(ctx, conversation.ID, other.ID)
+	if err != nil {
+		t.Fatalf("GetByIDForUser: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("GetByIDForUser(other) = %+v, want nil", got)
+	}
+
+	summaries, err := env.Conversations.GetByUserID(ctx, owner.ID, nil, 10, 0)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].MessageCount != 2 || summaries[0].LastMessagePreview == nil || *summaries[0].LastMessagePreview != "Pasta." {
+		t.Fatalf("GetByUserID = %+v, want the conversation with 2 messages", summaries)
+	}
+}
+
+func TestConversationRepository_CreateWithIDTaken(t *testing.T) {
+	ctx := context.Background()
+	env := testutil.NewEnv(t)
+	owner := env.CreateUser(t, "owner@example.com", "password123")
+	other := env.CreateUser(t, "other@example.com", "password123")
+
+	id := uuid.New()
+	if err := env.Conversations.CreateWithID(ctx, &models.Conversation{ID: id, UserID: owner.ID}); err != nil {
+		t.Fatalf("CreateWithID: %v", err)
+	}
+	err := env.Conversations.CreateWithID(ctx, &models.Conversation{ID: id, UserID: other.ID})
+	if !errors.Is(err, repository.ErrConversationExists) {
+		t.Fatalf("CreateWithID with a taken ID = %v, want ErrConversationExists", err)
+	}
+}
+
+func TestConversationRepository_SoftDeleteMessage(t *testing.T) {
+	ctx := context.Background()
+	env := testutil.NewEnv(t)
+	owner := env.CreateUser(t, "owner@example.com", "password123")
+
+	conversation := &models.Conversation{UserID: owner.ID}
+	if err := env.Conversations.Create(ctx, conversation); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	message := &models.Message{ConversationID: conversation.ID, SenderID: owner.ID, SenderType: models.SenderTypeUser, Content: "oops"}
+	if err := env.Conversations.CreateMessage(ctx, message); err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+
+	deleted, err := env.Conversations.SoftDeleteMessage(ctx, conversation.ID, message.ID)
+	if err != nil || !deleted {
+		t.Fatalf("SoftDeleteMessage = %v, %v, want true", deleted, err)
+	}
+
+	messages, err := env.Conversations.GetMessages(ctx, conversation.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 0 {
+		t.Fatalf("GetMessages after deleting = %+v, want none", messages)
+	}
+
+	all, err := env.Conversations.GetMessagesIncludingDeleted(ctx, conversation.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetMessagesIncludingDeleted: %v", err)
+	}
+	if len(all) != 1 || all[0].DeletedAt == nil {
+		t.Fatalf("GetMessagesIncludingDeleted = %+v, want the deleted message", all)
+	}
+}
+
+func TestConversationRepository_Fork(t *testing.T) {
+	ctx := context.Background()
+	env := testutil.NewEnv(t)
+	owner := env.CreateUser(t, "owner@example.com", "password123")
+	other := env.CreateUser(t, "other@example.com", "password123")
+
+	conversation := &models.Conversation{UserID: owner.ID}
+	if err := env.Conversations.Create(ctx, conversation); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	strategy, model := models.ContextFullHistory, "pinned-model"
+	conversation.ContextStrategy, conversation.Model = &strategy, &model
+	if err := env.Conversations.UpdateContextStrategy(ctx, conversation); err != nil {
+		t.Fatalf("UpdateContextStrategy: %v", err)
+	}
+	if err := env.Conversations.UpdateModel(ctx, conversation); err != nil {
+		t.Fatalf("UpdateModel: %v", err)
+	}
+
+	var first *models.Message
+	for _, content := range []string{"What's for dinner?", "Pasta.", "Something lighter?"} {
+		message := &models.Message{ConversationID: conversation.ID, SenderID: owner.ID, SenderType: models.SenderTypeUser, Content: content}
+		if err := env.Conversations.CreateMessage(ctx, message); err != nil {
+			t.Fatalf("CreateMessage: %v", err)
+		}
+		if first == nil {
+			first = message
+		}
+	}
+
+	// The fork keeps the source's settings and the messages up to the one
+	// picked
+	fork := &models.Conversation{UserID: other.ID}
+	copied, err := env.Conversations.Fork(ctx, conversation.ID, &first.ID, fork)
+	if err != nil {
+		t.Fatalf("Fork: %v", err)
+	}
+	if copied != 1 {
+		t.Fatalf("Fork copied %d messages, want 1", copied)
+	}
+	if fork.ContextStrategy == nil || *fork.ContextStrategy != strategy || fork.Model == nil || *fork.Model != model {
+		t.Fatalf("Fork returned context strategy %v and model %v, want %q and %q", fork.ContextStrategy, fork.Model, strategy, model)
+	}
+
+	stored, err := env.Conversations.GetByID(ctx, fork.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if stored.UserID != other.ID || stored.ContextStrategy == nil || *stored.ContextStrategy != strategy {
+		t.Fatalf("stored fork = %+v, want it owned by the forking user with the source's context strategy", stored)
+	}
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+)
+
+type FeedbackRepository struct {
+	db *database.DB
+}
+
+func NewFeedbackRepository(db *database.DB) *FeedbackRepository {
+	return &FeedbackRepository{db: db}
+}
+
+// Upsert records a user's feedback on a message, replacing an earlier vote
+func (r *FeedbackRepository) Upsert(ctx context.Context, feedback *models.MessageFeedback) error {
+	query := `
+		INSERT INTO message_feedback (message_id, user_id, rating, comment)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (message_id, user_id) DO UPDATE
+		SET rating = EXCLUDED.rating, comment = EXCLUDED.comment, updated_at = NOW()
+		RETURNING created_at, updated_at`
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, feedback.MessageID, feedback.UserID, feedback.Rating, feedback.Comment).
+		Scan(&feedback.CreatedAt, &feedback.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save message feedback: %w", err)
+	}
+
+	return nil
+}
+
+// Summarize aggregates feedback per persona, most rated first. Conversations
+// without a persona are reported with an empty persona.
+func (r *FeedbackRepository) Summarize(ctx context.Context, filter *models.FeedbackFilter) ([]models.FeedbackSummary, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("f.created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("f.created_at < $%d", len(args)))
+	}
+
+	query := `
+		SELECT
+			CASE WHEN c.system_prompt IS NOT NULL THEN 'custom' ELSE COALESCE(c.persona, '') END AS persona,
+			COUNT(*) FILTER (WHERE f.rating = 'up'),
+			COUNT(*) FILTER (WHERE f.rating = 'down'),
+			COUNT(*) FILTER (WHERE f.comment IS NOT NULL)
+		FROM message_feedback f
+		JOIN messages m ON m.id = f.message_id
+		JOIN conversations c ON c.id = m.conversation_id`
+	if len(conditions) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conditions, " AND ")
+	}
+	query += "\n\t\tGROUP BY 1\n\t\tORDER BY COUNT(*) DESC"
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize feedback: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []models.FeedbackSummary{}
+	for rows.Next() {
+		var s models.FeedbackSummary
+		if err := rows.Scan(&s.Persona, &s.Up, &s.Down, &s.Comments); err != nil {
+			return nil, fmt.Errorf("failed to scan feedback summary: %w", err)
+		}
+		s.Total = s.Up + s.Down
+		if s.Total > 0 {
+			s.Approval = float64(s.Up) / float64(s.Total)
+		}
+		summaries = append(summaries, s)
+	}
+
+	return summaries, rows.Err()
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type ImportRepository struct {
+	db *database.DB
+}
+
+func NewImportRepository(db *database.DB) *ImportRepository {
+	return &ImportRepository{db: db}
+}
+
+const importJobColumns = `id, user_id, source, status, storage_key, report, error, created_at, finished_at`
+
+func scanImportJob(row pgx.Row, job *models.ImportJob) error {
+	var report []byte
+	err := row.Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Source,
+		&job.Status,
+		&job.StorageKey,
+		&report,
+		&job.Error,
+		&job.CreatedAt,
+		&job.FinishedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if report != nil {
+		job.Report = &models.ImportReport{}
+		if err := json.Unmarshal(report, job.Report); err != nil {
+			return fmt.Errorf("invalid import report: %w", err)
+		}
+	}
+	return nil
+}
+
+// Create stores a pending import job
+func (r *ImportRepository) Create(ctx context.Context, job *models.ImportJob) error {
+	query := `
+		INSERT INTO import_jobs (user_id, source, storage_key)
+		VALUES ($1, $2, $3)
+		RETURNING id, status, created_at`
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, job.UserID, job.Source, job.StorageKey).
+		Scan(&job.ID, &job.Status, &job.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create import job: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns an import job, or nil if there is no such job
+func (r *ImportRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.ImportJob, error) {
+	query := `
+		SELECT ` + importJobColumns + `
+		FROM import_jobs
+		WHERE id = $1`
+
+	job := &models.ImportJob{}
+	if err := scanImportJob(conn(ctx, r.db.Pool).QueryRow(ctx, query, id), job); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	return job, nil
+}
+
+// Start marks a pending job as running and reports whether it was pending,
+// so a task delivered twice runs the import once
+func (r *ImportRepository) Start(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE import_jobs
+		SET status = 'running'
+		WHERE id = $1 AND status = 'pending'`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to start import job: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// Finish records the outcome of a job: its status, report and error
+func (r *ImportRepository) Finish(ctx context.Context, job *models.ImportJob) error {
+	query := `
+		UPDATE import_jobs
+		SET status = $2, report = $3, error = $4, finished_at = NOW()
+		WHERE id = $1
+		RETURNING finished_at`
+
+	var report []byte
+	if job.Report != nil {
+		report, _ = json.Marshal(job.Report) // only plain fields, can't fail
+	}
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, job.ID, job.Status, report, job.Error).
+		Scan(&job.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to finish import job: %w", err)
+	}
+	return nil
+}
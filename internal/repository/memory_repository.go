@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type MemoryRepository struct {
+	db *database.DB
+}
+
+func NewMemoryRepository(db *database.DB) *MemoryRepository {
+	return &MemoryRepository{db: db}
+}
+
+// ListByUser returns the user's memories, oldest first
+func (r *MemoryRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.UserMemory, error) {
+	query := `
+		SELECT id, user_id, content, conversation_id, created_at
+		FROM user_memories
+		WHERE user_id = $1
+		ORDER BY created_at ASC, id ASC`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user memories: %w", err)
+	}
+	defer rows.Close()
+
+	memories := []models.UserMemory{}
+	for rows.Next() {
+		var m models.UserMemory
+		if err := rows.Scan(&m.ID, &m.UserID, &m.Content, &m.ConversationID, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user memory: %w", err)
+		}
+		memories = append(memories, m)
+	}
+
+	return memories, rows.Err()
+}
+
+// Add stores new memories for a user, skipping ones the user already has
+// (ignoring case), and reports how many were added. conversationID is where
+// they were learned.
+func (r *MemoryRepository) Add(ctx context.Context, userID uuid.UUID, conversationID *uuid.UUID, contents []string) (int, error) {
+	if len(contents) == 0 {
+		return 0, nil
+	}
+
+	query := `
+		INSERT INTO user_memories (user_id, conversation_id, content)
+		SELECT $1, $2, content FROM UNNEST($3::TEXT[]) AS content
+		ON CONFLICT (user_id, LOWER(content)) DO NOTHING`
+
+	tag, err := conn(ctx, r.db.Pool).Exec(ctx, query, userID, conversationID, contents)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add user memories: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Delete removes one of the user's memories and reports whether it existed
+func (r *MemoryRepository) Delete(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `DELETE FROM user_memories WHERE user_id = $1 AND id = $2`
+
+	tag, err := conn(ctx, r.db.Pool).Exec(ctx, query, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user memory: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteAll removes all of the user's memories and returns how many there
+// were
+func (r *MemoryRepository) DeleteAll(ctx context.Context, userID uuid.UUID) (int64, error) {
+	query := `DELETE FROM user_memories WHERE user_id = $1`
+
+	tag, err := conn(ctx, r.db.Pool).Exec(ctx, query, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete user memories: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -26,7 +26,7 @@ func (r *OAuthRepository) StoreState(ctx context.Context, state *models.OAuthSta
 		VALUES ($1, $2, $3, $4, $5)
 	`
 
-	_, err := r.db.Exec(ctx, query,
+	_, err := conn(ctx, r.db).Exec(ctx, query,
 		state.State,
 		state.Provider,
 		state.CodeVerifier,
@@ -51,7 +51,7 @@ func (r *OAuthRepository) GetState(ctx context.Context, state string) (*models.O
 	`
 
 	var oauthState models.OAuthState
-	err := r.db.QueryRow(ctx, query, state).Scan(
+	err := conn(ctx, r.db).QueryRow(ctx, query, state).Scan(
 		&oauthState.ID,
 		&oauthState.State,
 		&oauthState.Provider,
@@ -75,7 +75,7 @@ func (r *OAuthRepository) GetState(ctx context.Context, state string) (*models.O
 func (r *OAuthRepository) DeleteState(ctx context.Context, state string) error {
 	query := `DELETE FROM oauth_states WHERE state = $1`
 
-	_, err := r.db.Exec(ctx, query, state)
+	_, err := conn(ctx, r.db).Exec(ctx, query, state)
 	if err != nil {
 		return fmt.Errorf("failed to delete OAuth state: %w", err)
 	}
@@ -89,12 +89,12 @@ func (r *OAuthRepository) CreateAccount(ctx context.Context, account *models.OAu
 		INSERT INTO oauth_accounts (
 			user_id, provider, provider_account_id, provider_email, 
 			provider_username, provider_avatar_url, access_token, 
-			refresh_token, token_expires_at, raw_user_data
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			refresh_token, token_expires_at, raw_user_data, scopes
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id, created_at, updated_at
 	`
 
-	err := r.db.QueryRow(ctx, query,
+	err := conn(ctx, r.db).QueryRow(ctx, query,
 		account.UserID,
 		account.Provider,
 		account.ProviderAccountID,
@@ -105,6 +105,7 @@ func (r *OAuthRepository) CreateAccount(ctx context.Context, account *models.OAu
 		account.RefreshToken,
 		account.TokenExpiresAt,
 		account.RawUserData,
+		scopeArray(account.Scopes),
 	).Scan(&account.ID, &account.CreatedAt, &account.UpdatedAt)
 
 	if err != nil {
@@ -120,14 +121,14 @@ func (r *OAuthRepository) GetByProviderID(ctx context.Context, provider, provide
 		SELECT 
 			id, user_id, provider, provider_account_id, provider_email,
 			provider_username, provider_avatar_url, access_token,
-			refresh_token, token_expires_at, raw_user_data, created_at, updated_at
+			refresh_token, token_expires_at, raw_user_data, scopes, created_at, updated_at
 		FROM oauth_accounts
 		WHERE provider = $1 AND provider_account_id = $2
 		LIMIT 1
 	`
 
 	var account models.OAuthAccount
-	err := r.db.QueryRow(ctx, query, provider, providerAccountID).Scan(
+	err := conn(ctx, r.db).QueryRow(ctx, query, provider, providerAccountID).Scan(
 		&account.ID,
 		&account.UserID,
 		&account.Provider,
@@ -139,6 +140,7 @@ func (r *OAuthRepository) GetByProviderID(ctx context.Context, provider, provide
 		&account.RefreshToken,
 		&account.TokenExpiresAt,
 		&account.RawUserData,
+		&account.Scopes,
 		&account.CreatedAt,
 		&account.UpdatedAt,
 	)
@@ -159,13 +161,13 @@ func (r *OAuthRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]
 		SELECT 
 			id, user_id, provider, provider_account_id, provider_email,
 			provider_username, provider_avatar_url, access_token,
-			refresh_token, token_expires_at, raw_user_data, created_at, updated_at
+			refresh_token, token_expires_at, raw_user_data, scopes, created_at, updated_at
 		FROM oauth_accounts
 		WHERE user_id = $1
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(ctx, query, userID)
+	rows, err := conn(ctx, r.db).Query(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get OAuth accounts: %w", err)
 	}
@@ -186,6 +188,7 @@ func (r *OAuthRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]
 			&account.RefreshToken,
 			&account.TokenExpiresAt,
 			&account.RawUserData,
+			&account.Scopes,
 			&account.CreatedAt,
 			&account.UpdatedAt,
 		)
@@ -210,11 +213,12 @@ func (r *OAuthRepository) UpdateAccount(ctx context.Context, account *models.OAu
 			refresh_token = $6,
 			token_expires_at = $7,
 			raw_user_data = $8,
+			scopes = $9,
 			updated_at = NOW()
 		WHERE id = $1
 	`
 
-	_, err := r.db.Exec(ctx, query,
+	_, err := conn(ctx, r.db).Exec(ctx, query,
 		account.ID,
 		account.ProviderEmail,
 		account.ProviderUsername,
@@ -223,6 +227,7 @@ func (r *OAuthRepository) UpdateAccount(ctx context.Context, account *models.OAu
 		account.RefreshToken,
 		account.TokenExpiresAt,
 		account.RawUserData,
+		scopeArray(account.Scopes),
 	)
 
 	if err != nil {
@@ -232,11 +237,19 @@ func (r *OAuthRepository) UpdateAccount(ctx context.Context, account *models.OAu
 	return nil
 }
 
+// scopeArray stores no scopes as an empty array rather than NULL
+func scopeArray(granted []string) []string {
+	if granted == nil {
+		return []string{}
+	}
+	return granted
+}
+
 // DeleteByUserAndProvider deletes an OAuth account for a user and provider
 func (r *OAuthRepository) DeleteByUserAndProvider(ctx context.Context, userID uuid.UUID, provider string) error {
 	query := `DELETE FROM oauth_accounts WHERE user_id = $1 AND provider = $2`
 
-	_, err := r.db.Exec(ctx, query, userID, provider)
+	_, err := conn(ctx, r.db).Exec(ctx, query, userID, provider)
 	if err != nil {
 		return fmt.Errorf("failed to delete OAuth account: %w", err)
 	}
@@ -248,41 +261,10 @@ func (r *OAuthRepository) DeleteByUserAndProvider(ctx context.Context, userID uu
 func (r *OAuthRepository) CleanupExpiredStates(ctx context.Context) error {
 	query := `DELETE FROM oauth_states WHERE expires_at < NOW()`
 
-	_, err := r.db.Exec(ctx, query)
+	_, err := conn(ctx, r.db).Exec(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to cleanup expired states: %w", err)
 	}
 
 	return nil
 }
-
-// CreateAccountTx creates a new OAuth account within an existing transaction
-func (r *OAuthRepository) CreateAccountTx(ctx context.Context, tx pgx.Tx, account *models.OAuthAccount) error {
-	query := `
-		INSERT INTO oauth_accounts (
-			user_id, provider, provider_account_id, provider_email, 
-			provider_username, provider_avatar_url, access_token, 
-			refresh_token, token_expires_at, raw_user_data
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		RETURNING id, created_at, updated_at
-	`
-
-	err := tx.QueryRow(ctx, query,
-		account.UserID,
-		account.Provider,
-		account.ProviderAccountID,
-		account.ProviderEmail,
-		account.ProviderUsername,
-		account.ProviderAvatarURL,
-		account.AccessToken,
-		account.RefreshToken,
-		account.TokenExpiresAt,
-		account.RawUserData,
-	).Scan(&account.ID, &account.CreatedAt, &account.UpdatedAt)
-
-	if err != nil {
-		return fmt.Errorf("failed to create OAuth account: %w", err)
-	}
-
-	return nil
-}
@@ -0,0 +1,423 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type OrganizationRepository struct {
+	db *database.DB
+}
+
+func NewOrganizationRepository(db *database.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// Create inserts an organization and makes its creator the owner
+func (r *OrganizationRepository) Create(ctx context.Context, org *models.Organization) error {
+	query := `
+		WITH o AS (
+			INSERT INTO organizations (name, created_by)
+			VALUES ($1, $2)
+			RETURNING id, created_by, created_at, updated_at
+		), m AS (
+			INSERT INTO organization_members (org_id, user_id, role)
+			SELECT id, created_by, 'owner' FROM o
+		)
+		SELECT id, created_at, updated_at FROM o`
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, org.Name, org.CreatedBy).
+		Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+	org.Role = models.OrgRoleOwner
+	return nil
+}
+
+// GetByID returns an organization, or nil if it doesn't exist
+func (r *OrganizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Organization, error) {
+	query := `
+		SELECT id, name, monthly_token_quota, conversation_retention_days, created_by, created_at, updated_at
+		FROM organizations
+		WHERE id = $1`
+
+	org := &models.Organization{}
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, id).
+		Scan(&org.ID, &org.Name, &org.MonthlyTokenQuota, &org.ConversationRetentionDays, &org.CreatedBy, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org, nil
+}
+
+// ListByUser returns the organizations the user is a member of, with their
+// role, by name
+func (r *OrganizationRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Organization, error) {
+	query := `
+		SELECT o.id, o.name, o.monthly_token_quota, o.conversation_retention_days, o.created_by, o.created_at, o.updated_at, m.role
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.org_id
+		WHERE m.user_id = $1
+		ORDER BY o.name, o.created_at`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []models.Organization{}
+	for rows.Next() {
+		var org models.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.MonthlyTokenQuota, &org.ConversationRetentionDays, &org.CreatedBy, &org.CreatedAt, &org.UpdatedAt, &org.Role); err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+
+	return orgs, rows.Err()
+}
+
+// Rename changes an organization's name
+func (r *OrganizationRepository) Rename(ctx context.Context, org *models.Organization) error {
+	query := `
+		UPDATE organizations
+		SET name = $2
+		WHERE id = $1
+		RETURNING updated_at`
+
+	if err := conn(ctx, r.db.Pool).QueryRow(ctx, query, org.ID, org.Name).Scan(&org.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to rename organization: %w", err)
+	}
+	return nil
+}
+
+// SetQuota sets an organization's monthly token quota; nil removes it. It
+// reports false when the organization doesn't exist.
+func (r *OrganizationRepository) SetQuota(ctx context.Context, orgID uuid.UUID, quota *int64) (bool, error) {
+	query := `
+		UPDATE organizations
+		SET monthly_token_quota = $2
+		WHERE id = $1`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query, orgID, quota)
+	if err != nil {
+		return false, fmt.Errorf("failed to set organization quota: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// SetRetention overrides how many days an organization's conversations are
+// kept; nil goes back to the default. It reports false when the
+// organization doesn't exist.
+func (r *OrganizationRepository) SetRetention(ctx context.Context, orgID uuid.UUID, days *int) (bool, error) {
+	query := `
+		UPDATE organizations
+		SET conversation_retention_days = $2
+		WHERE id = $1`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query, orgID, days)
+	if err != nil {
+		return false, fmt.Errorf("failed to set organization retention: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// Delete removes an organization along with its conversations
+func (r *OrganizationRepository) Delete(ctx context.Context, orgID uuid.UUID) error {
+	if _, err := conn(ctx, r.db.Pool).Exec(ctx, `DELETE FROM organizations WHERE id = $1`, orgID); err != nil {
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	return nil
+}
+
+// GetRole returns the user's role in an organization, or an empty string if
+// they aren't a member
+func (r *OrganizationRepository) GetRole(ctx context.Context, orgID, userID uuid.UUID) (string, error) {
+	query := `
+		SELECT role
+		FROM organization_members
+		WHERE org_id = $1 AND user_id = $2`
+
+	var role string
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, orgID, userID).Scan(&role)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get organization role: %w", err)
+	}
+
+	return role, nil
+}
+
+// ListMembers returns the members of an organization, owners first
+func (r *OrganizationRepository) ListMembers(ctx context.Context, orgID uuid.UUID) ([]models.OrgMember, error) {
+	query := `
+		SELECT m.org_id, m.user_id, u.username, u.email, u.avatar_url, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = $1
+		ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, m.created_at ASC`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.OrgMember{}
+	for rows.Next() {
+		var m models.OrgMember
+		if err := rows.Scan(&m.OrgID, &m.UserID, &m.Username, &m.Email, &m.AvatarURL, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan organization member: %w", err)
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// AddMember adds a user to an organization. It reports false when they
+// already are a member.
+func (r *OrganizationRepository) AddMember(ctx context.Context, orgID, userID uuid.UUID, role string) (bool, error) {
+	query := `
+		INSERT INTO organization_members (org_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (org_id, user_id) DO NOTHING`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query, orgID, userID, role)
+	if err != nil {
+		return false, fmt.Errorf("failed to add organization member: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// UpdateMemberRole changes a member's role. The last owner can't stop
+// being one. It reports false when the user isn't a member or is the last
+// owner.
+func (r *OrganizationRepository) UpdateMemberRole(ctx context.Context, orgID, userID uuid.UUID, role string) (bool, error) {
+	query := `
+		UPDATE organization_members
+		SET role = $3
+		WHERE org_id = $1 AND user_id = $2
+			AND (role <> 'owner' OR $3 = 'owner' OR EXISTS (
+				SELECT 1 FROM organization_members o
+				WHERE o.org_id = $1 AND o.user_id <> $2 AND o.role = 'owner'
+			))`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query, orgID, userID, role)
+	if err != nil {
+		return false, fmt.Errorf("failed to update organization member: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// RemoveMember removes a user from an organization, which ends their
+// access to its conversations. The last owner can't be removed. It reports
+// false when the user isn't a member or is the last owner.
+func (r *OrganizationRepository) RemoveMember(ctx context.Context, orgID, userID uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM organization_members
+		WHERE org_id = $1 AND user_id = $2
+			AND (role <> 'owner' OR EXISTS (
+				SELECT 1 FROM organization_members o
+				WHERE o.org_id = $1 AND o.user_id <> $2 AND o.role = 'owner'
+			))`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query, orgID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove organization member: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+const invitationColumns = `i.id, i.org_id, i.email, i.role, i.status, i.invited_by, i.accepted_by, i.expires_at, i.responded_at, i.created_at`
+
+func scanInvitation(row pgx.Row, inv *models.OrgInvitation, extra ...any) error {
+	return row.Scan(append([]any{&inv.ID, &inv.OrgID, &inv.Email, &inv.Role, &inv.Status, &inv.InvitedBy,
+		&inv.AcceptedBy, &inv.ExpiresAt, &inv.RespondedAt, &inv.CreatedAt}, extra...)...)
+}
+
+// CreateInvitation inserts a pending invitation. It reports false when the
+// email already has a pending invitation to the organization.
+func (r *OrganizationRepository) CreateInvitation(ctx context.Context, inv *models.OrgInvitation) (bool, error) {
+	query := `
+		INSERT INTO organization_invitations (org_id, email, role, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (org_id, email) WHERE status = 'pending' DO NOTHING
+		RETURNING id, status, created_at`
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, inv.OrgID, inv.Email, inv.Role, inv.InvitedBy, inv.ExpiresAt).
+		Scan(&inv.ID, &inv.Status, &inv.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create invitation: %w", err)
+	}
+	return true, nil
+}
+
+// GetInvitation returns an invitation with its organization's name, or nil
+// if it doesn't exist
+func (r *OrganizationRepository) GetInvitation(ctx context.Context, id uuid.UUID) (*models.OrgInvitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `, o.name
+		FROM organization_invitations i
+		JOIN organizations o ON o.id = i.org_id
+		WHERE i.id = $1`
+
+	inv := &models.OrgInvitation{}
+	if err := scanInvitation(conn(ctx, r.db.Pool).QueryRow(ctx, query, id), inv, &inv.OrgName); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	return inv, nil
+}
+
+// ListPendingInvitations returns the invitations to an organization that
+// can still be accepted, newest first
+func (r *OrganizationRepository) ListPendingInvitations(ctx context.Context, orgID uuid.UUID) ([]models.OrgInvitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM organization_invitations i
+		WHERE i.org_id = $1 AND i.status = 'pending' AND i.expires_at > NOW()
+		ORDER BY i.created_at DESC`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []models.OrgInvitation{}
+	for rows.Next() {
+		var inv models.OrgInvitation
+		if err := scanInvitation(rows, &inv); err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, inv)
+	}
+
+	return invitations, rows.Err()
+}
+
+// RevokeInvitation withdraws a pending invitation. It reports false when
+// the organization has no such pending invitation.
+func (r *OrganizationRepository) RevokeInvitation(ctx context.Context, orgID, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE organization_invitations
+		SET status = 'revoked', responded_at = NOW()
+		WHERE id = $1 AND org_id = $2 AND status = 'pending'`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query, id, orgID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// AcceptInvitation marks a pending, unexpired invitation accepted by the
+// user. It reports false when the invitation can no longer be accepted.
+func (r *OrganizationRepository) AcceptInvitation(ctx context.Context, id, userID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE organization_invitations
+		SET status = 'accepted', accepted_by = $2, responded_at = NOW()
+		WHERE id = $1 AND status = 'pending' AND expires_at > NOW()`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// ExpireInvitations marks pending invitations past their expiry expired and
+// returns how many were
+func (r *OrganizationRepository) ExpireInvitations(ctx context.Context) (int64, error) {
+	query := `
+		UPDATE organization_invitations
+		SET status = 'expired'
+		WHERE status = 'pending' AND expires_at <= NOW()`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire invitations: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+const credentialColumns = `org_id, provider, api_key_encrypted, key_hint, base_url, model, updated_by, created_at, updated_at`
+
+// SetCredential stores an organization's API key for a provider, replacing
+// the one it had
+func (r *OrganizationRepository) SetCredential(ctx context.Context, cred *models.ProviderCredential) error {
+	query := `
+		INSERT INTO organization_provider_credentials (org_id, provider, api_key_encrypted, key_hint, base_url, model, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (org_id, provider) DO UPDATE
+		SET api_key_encrypted = EXCLUDED.api_key_encrypted, key_hint = EXCLUDED.key_hint,
+			base_url = EXCLUDED.base_url, model = EXCLUDED.model, updated_by = EXCLUDED.updated_by
+		RETURNING created_at, updated_at`
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, cred.OrgID, cred.Provider, cred.APIKeyEncrypted,
+		cred.KeyHint, cred.BaseURL, cred.Model, cred.UpdatedBy).Scan(&cred.CreatedAt, &cred.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store provider credential: %w", err)
+	}
+	return nil
+}
+
+// ListCredentials returns an organization's stored API keys by provider
+func (r *OrganizationRepository) ListCredentials(ctx context.Context, orgID uuid.UUID) ([]models.ProviderCredential, error) {
+	query := `
+		SELECT ` + credentialColumns + `
+		FROM organization_provider_credentials
+		WHERE org_id = $1
+		ORDER BY provider`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list provider credentials: %w", err)
+	}
+	defer rows.Close()
+
+	creds := []models.ProviderCredential{}
+	for rows.Next() {
+		var cred models.ProviderCredential
+		if err := rows.Scan(&cred.OrgID, &cred.Provider, &cred.APIKeyEncrypted, &cred.KeyHint, &cred.BaseURL,
+			&cred.Model, &cred.UpdatedBy, &cred.CreatedAt, &cred.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan provider credential: %w", err)
+		}
+		creds = append(creds, cred)
+	}
+
+	return creds, rows.Err()
+}
+
+// DeleteCredential removes an organization's API key for a provider. It
+// reports false when there was none.
+func (r *OrganizationRepository) DeleteCredential(ctx context.Context, orgID uuid.UUID, provider string) (bool, error) {
+	query := `DELETE FROM organization_provider_credentials WHERE org_id = $1 AND provider = $2`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query, orgID, provider)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete provider credential: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
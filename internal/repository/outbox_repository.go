@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+)
+
+type OutboxRepository struct {
+	db *database.DB
+}
+
+func NewOutboxRepository(db *database.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Add queues an entry for its first retry
+func (r *OutboxRepository) Add(ctx context.Context, entry *models.OutboxEntry) error {
+	query := `
+		INSERT INTO message_outbox (kind, payload, attempts, last_error)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	return conn(ctx, r.db.Pool).QueryRow(ctx, query, entry.Kind, entry.Payload, entry.Attempts, entry.LastError).
+		Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// ClaimDue reserves up to limit entries due for a retry, oldest first.
+// Entries claimed by an instance that died are claimed again once the
+// lease has passed.
+func (r *OutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEntry, error) {
+	query := `
+		UPDATE message_outbox
+		SET claimed_at = NOW()
+		WHERE id IN (
+			SELECT id
+			FROM message_outbox
+			WHERE failed_at IS NULL
+				AND next_attempt_at <= NOW()
+				AND (claimed_at IS NULL OR claimed_at < NOW() - make_interval(secs => $2))
+			ORDER BY id ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, payload, attempts, last_error, created_at`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.OutboxEntry
+	for rows.Next() {
+		var e models.OutboxEntry
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Payload, &e.Attempts, &e.LastError, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Delete removes an entry that was applied
+func (r *OutboxRepository) Delete(ctx context.Context, id int64) error {
+	if _, err := conn(ctx, r.db.Pool).Exec(ctx, `DELETE FROM message_outbox WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete outbox entry: %w", err)
+	}
+	return nil
+}
+
+// Retry records a failed attempt and releases the entry until nextAttemptAt
+func (r *OutboxRepository) Retry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	query := `
+		UPDATE message_outbox
+		SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3, claimed_at = NULL
+		WHERE id = $1`
+
+	if _, err := conn(ctx, r.db.Pool).Exec(ctx, query, id, nextAttemptAt, lastError); err != nil {
+		return fmt.Errorf("failed to reschedule outbox entry: %w", err)
+	}
+	return nil
+}
+
+// Fail records the last failed attempt of an entry that won't be retried.
+// Failed entries are kept for inspection.
+func (r *OutboxRepository) Fail(ctx context.Context, id int64, lastError string) error {
+	query := `
+		UPDATE message_outbox
+		SET attempts = attempts + 1, last_error = $2, failed_at = NOW(), claimed_at = NULL
+		WHERE id = $1`
+
+	if _, err := conn(ctx, r.db.Pool).Exec(ctx, query, id, lastError); err != nil {
+		return fmt.Errorf("failed to mark outbox entry failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type ParticipantRepository struct {
+	db *database.DB
+}
+
+func NewParticipantRepository(db *database.DB) *ParticipantRepository {
+	return &ParticipantRepository{db: db}
+}
+
+// Add grants a user access to a conversation, replacing any existing role.
+// The owner's role can't be changed this way.
+func (r *ParticipantRepository) Add(ctx context.Context, conversationID, userID uuid.UUID, role string, invitedBy *uuid.UUID) error {
+	query := `
+		INSERT INTO conversation_participants (conversation_id, user_id, role, invited_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (conversation_id, user_id) DO UPDATE
+		SET role = EXCLUDED.role, invited_by = EXCLUDED.invited_by
+		WHERE conversation_participants.role <> 'owner'`
+
+	if _, err := conn(ctx, r.db.Pool).Exec(ctx, query, conversationID, userID, role, invitedBy); err != nil {
+		return fmt.Errorf("failed to add participant: %w", err)
+	}
+
+	return nil
+}
+
+// GetRole returns the user's role in a conversation, or an empty string if
+// they aren't a participant. Participants of an organization's
+// conversation lose their role when they leave the organization.
+func (r *ParticipantRepository) GetRole(ctx context.Context, conversationID, userID uuid.UUID) (string, error) {
+	query := `
+		SELECT p.role
+		FROM conversation_participants p
+		JOIN conversations c ON c.id = p.conversation_id
+		WHERE p.conversation_id = $1 AND p.user_id = $2
+			AND (c.org_id IS NULL OR EXISTS (
+				SELECT 1 FROM organization_members m
+				WHERE m.org_id = c.org_id AND m.user_id = p.user_id
+			))`
+
+	var role string
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, conversationID, userID).Scan(&role)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get participant role: %w", err)
+	}
+
+	return role, nil
+}
+
+// ListByConversation returns the participants of a conversation, owner first
+func (r *ParticipantRepository) ListByConversation(ctx context.Context, conversationID uuid.UUID) ([]models.Participant, error) {
+	query := `
+		SELECT p.conversation_id, p.user_id, u.username, u.avatar_url, p.role, p.invited_by, p.created_at
+		FROM conversation_participants p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.conversation_id = $1
+		ORDER BY p.role = 'owner' DESC, p.created_at ASC`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list participants: %w", err)
+	}
+	defer rows.Close()
+
+	participants := []models.Participant{}
+	for rows.Next() {
+		var p models.Participant
+		err := rows.Scan(
+			&p.ConversationID,
+			&p.UserID,
+			&p.Username,
+			&p.AvatarURL,
+			&p.Role,
+			&p.InvitedBy,
+			&p.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan participant: %w", err)
+		}
+		participants = append(participants, p)
+	}
+
+	return participants, rows.Err()
+}
+
+// Remove revokes a user's access to a conversation. Owners can't be removed.
+func (r *ParticipantRepository) Remove(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM conversation_participants
+		WHERE conversation_id = $1 AND user_id = $2 AND role <> 'owner'`
+
+	result, err := conn(ctx, r.db.Pool).Exec(ctx, query, conversationID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove participant: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
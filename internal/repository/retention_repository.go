@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+)
+
+type RetentionRepository struct {
+	db *database.DB
+}
+
+func NewRetentionRepository(db *database.DB) *RetentionRepository {
+	return &RetentionRepository{db: db}
+}
+
+// expiredConversations selects conversations whose retention, their
+// organization's override or else $1 days, passed since their last
+// activity. A retention of 0 keeps them forever.
+const expiredConversations = `
+		SELECT c.id, c.org_id, o.name AS org_name, COALESCE(o.conversation_retention_days, $1) AS retention_days
+		FROM conversations c
+		LEFT JOIN organizations o ON o.id = c.org_id
+		WHERE COALESCE(o.conversation_retention_days, $1) > 0
+			AND c.updated_at < NOW() - make_interval(days => COALESCE(o.conversation_retention_days, $1))`
+
+// ExpiredConversations counts the conversations past their retention and
+// their messages, per organization with personal conversations first
+func (r *RetentionRepository) ExpiredConversations(ctx context.Context, defaultDays int) ([]models.RetentionScope, error) {
+	query := `
+		SELECT e.org_id, e.org_name, e.retention_days, COUNT(*),
+			COALESCE(SUM((SELECT COUNT(*) FROM messages m WHERE m.conversation_id = e.id)), 0)::BIGINT
+		FROM (` + expiredConversations + `) e
+		GROUP BY e.org_id, e.org_name, e.retention_days
+		ORDER BY e.org_id NULLS FIRST`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, defaultDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired conversations: %w", err)
+	}
+	defer rows.Close()
+
+	scopes := []models.RetentionScope{}
+	for rows.Next() {
+		var scope models.RetentionScope
+		if err := rows.Scan(&scope.OrgID, &scope.OrgName, &scope.RetentionDays, &scope.Conversations, &scope.Messages); err != nil {
+			return nil, fmt.Errorf("failed to scan expired conversations: %w", err)
+		}
+		scopes = append(scopes, scope)
+	}
+
+	return scopes, rows.Err()
+}
+
+// DeleteExpiredConversations deletes up to limit conversations past their
+// retention, with their messages, and returns how many it deleted
+func (r *RetentionRepository) DeleteExpiredConversations(ctx context.Context, defaultDays, limit int) (int64, error) {
+	query := `
+		DELETE FROM conversations
+		WHERE id IN (
+			SELECT id FROM (` + expiredConversations + `) e
+			LIMIT $2
+		)`
+
+	tag, err := conn(ctx, r.db.Pool).Exec(ctx, query, defaultDays, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired conversations: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type ScheduleRepository struct {
+	db *database.DB
+}
+
+func NewScheduleRepository(db *database.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+const scheduledPromptColumns = `id, conversation_id, user_id, prompt, cron_expr, timezone, run_at, webhook_url, next_run_at, last_run_at, last_error, created_at`
+
+func scanScheduledPrompt(row pgx.Row, p *models.ScheduledPrompt) error {
+	return row.Scan(
+		&p.ID,
+		&p.ConversationID,
+		&p.UserID,
+		&p.Prompt,
+		&p.Cron,
+		&p.Timezone,
+		&p.RunAt,
+		&p.WebhookURL,
+		&p.NextRunAt,
+		&p.LastRunAt,
+		&p.LastError,
+		&p.CreatedAt,
+	)
+}
+
+// Create stores a scheduled prompt. NextRunAt must be set.
+func (r *ScheduleRepository) Create(ctx context.Context, p *models.ScheduledPrompt) error {
+	query := `
+		INSERT INTO scheduled_prompts (conversation_id, user_id, prompt, cron_expr, timezone, run_at, webhook_url, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, p.ConversationID, p.UserID, p.Prompt, p.Cron, p.Timezone, p.RunAt, p.WebhookURL, p.NextRunAt).
+		Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduled prompt: %w", err)
+	}
+
+	return nil
+}
+
+// ListByConversation returns a conversation's scheduled prompts, soonest
+// first and finished ones last
+func (r *ScheduleRepository) ListByConversation(ctx context.Context, conversationID uuid.UUID) ([]models.ScheduledPrompt, error) {
+	query := `
+		SELECT ` + scheduledPromptColumns + `
+		FROM scheduled_prompts
+		WHERE conversation_id = $1
+		ORDER BY next_run_at ASC NULLS LAST, created_at DESC`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled prompts: %w", err)
+	}
+	defer rows.Close()
+
+	prompts := []models.ScheduledPrompt{}
+	for rows.Next() {
+		var p models.ScheduledPrompt
+		if err := scanScheduledPrompt(rows, &p); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled prompt: %w", err)
+		}
+		prompts = append(prompts, p)
+	}
+
+	return prompts, rows.Err()
+}
+
+// GetByID returns a scheduled prompt of the conversation, or nil if there
+// is no such prompt
+func (r *ScheduleRepository) GetByID(ctx context.Context, conversationID, id uuid.UUID) (*models.ScheduledPrompt, error) {
+	query := `
+		SELECT ` + scheduledPromptColumns + `
+		FROM scheduled_prompts
+		WHERE conversation_id = $1 AND id = $2`
+
+	p := &models.ScheduledPrompt{}
+	if err := scanScheduledPrompt(conn(ctx, r.db.Pool).QueryRow(ctx, query, conversationID, id), p); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get scheduled prompt: %w", err)
+	}
+
+	return p, nil
+}
+
+// Delete removes a scheduled prompt
+func (r *ScheduleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM scheduled_prompts WHERE id = $1`
+	if _, err := conn(ctx, r.db.Pool).Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to delete scheduled prompt: %w", err)
+	}
+	return nil
+}
+
+// ClaimDue marks up to limit due prompts as claimed and returns them. A
+// claim older than lease is assumed abandoned by a crashed instance and may
+// be taken over, so every instance can poll without running a prompt twice.
+func (r *ScheduleRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.ScheduledPrompt, error) {
+	query := `
+		UPDATE scheduled_prompts
+		SET claimed_at = NOW()
+		WHERE id IN (
+			SELECT id
+			FROM scheduled_prompts
+			WHERE next_run_at <= NOW()
+				AND (claimed_at IS NULL OR claimed_at < NOW() - make_interval(secs => $2))
+			ORDER BY next_run_at ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + scheduledPromptColumns
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim scheduled prompts: %w", err)
+	}
+	defer rows.Close()
+
+	var prompts []models.ScheduledPrompt
+	for rows.Next() {
+		var p models.ScheduledPrompt
+		if err := scanScheduledPrompt(rows, &p); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled prompt: %w", err)
+		}
+		prompts = append(prompts, p)
+	}
+
+	return prompts, rows.Err()
+}
+
+// Release gives up the claim on a prompt without running it, so it is
+// claimed again by the next poll
+func (r *ScheduleRepository) Release(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE scheduled_prompts SET claimed_at = NULL WHERE id = $1`
+	if _, err := conn(ctx, r.db.Pool).Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to release scheduled prompt: %w", err)
+	}
+	return nil
+}
+
+// Complete records a run of a claimed prompt and releases the claim.
+// nextRunAt is nil when the prompt should not run again.
+func (r *ScheduleRepository) Complete(ctx context.Context, id uuid.UUID, nextRunAt *time.Time, lastError *string) error {
+	query := `
+		UPDATE scheduled_prompts
+		SET next_run_at = $2, last_error = $3, last_run_at = NOW(), claimed_at = NULL
+		WHERE id = $1`
+
+	if _, err := conn(ctx, r.db.Pool).Exec(ctx, query, id, nextRunAt, lastError); err != nil {
+		return fmt.Errorf("failed to complete scheduled prompt: %w", err)
+	}
+	return nil
+}
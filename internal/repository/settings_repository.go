@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type SettingsRepository struct {
+	db *database.DB
+}
+
+func NewSettingsRepository(db *database.DB) *SettingsRepository {
+	return &SettingsRepository{db: db}
+}
+
+// GetByUserID returns the user's settings, or nil if none were saved yet
+func (r *SettingsRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*models.UserSettings, error) {
+	query := `
+		SELECT user_id, temperature, max_tokens, model, persona, language, created_at, updated_at
+		FROM user_settings
+		WHERE user_id = $1`
+
+	settings := &models.UserSettings{}
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, userID).Scan(
+		&settings.UserID,
+		&settings.Temperature,
+		&settings.MaxTokens,
+		&settings.Model,
+		&settings.Persona,
+		&settings.Language,
+		&settings.CreatedAt,
+		&settings.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// Upsert creates or replaces the user's settings
+func (r *SettingsRepository) Upsert(ctx context.Context, settings *models.UserSettings) error {
+	query := `
+		INSERT INTO user_settings (user_id, temperature, max_tokens, model, persona, language)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			temperature = EXCLUDED.temperature,
+			max_tokens = EXCLUDED.max_tokens,
+			model = EXCLUDED.model,
+			persona = EXCLUDED.persona,
+			language = EXCLUDED.language
+		RETURNING created_at, updated_at`
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query,
+		settings.UserID,
+		settings.Temperature,
+		settings.MaxTokens,
+		settings.Model,
+		settings.Persona,
+		settings.Language,
+	).Scan(&settings.CreatedAt, &settings.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to save user settings: %w", err)
+	}
+
+	return nil
+}
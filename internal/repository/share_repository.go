@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type ShareRepository struct {
+	db *database.DB
+}
+
+func NewShareRepository(db *database.DB) *ShareRepository {
+	return &ShareRepository{db: db}
+}
+
+// Create stores a share link covering every message currently in the
+// conversation
+func (r *ShareRepository) Create(ctx context.Context, link *models.SharedLink) error {
+	query := `
+		INSERT INTO shared_links (conversation_id, user_id, last_message_id, expires_at)
+		VALUES ($1, $2, (SELECT COALESCE(MAX(id), 0) FROM messages WHERE conversation_id = $1), $3)
+		RETURNING id, last_message_id, created_at`
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, link.ConversationID, link.UserID, link.ExpiresAt).
+		Scan(&link.ID, &link.LastMessageID, &link.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create shared link: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID returns a share link, or nil if it doesn't exist
+func (r *ShareRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.SharedLink, error) {
+	query := `
+		SELECT id, conversation_id, user_id, last_message_id, expires_at, revoked_at, created_at
+		FROM shared_links
+		WHERE id = $1`
+
+	link := &models.SharedLink{}
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, id).Scan(
+		&link.ID,
+		&link.ConversationID,
+		&link.UserID,
+		&link.LastMessageID,
+		&link.ExpiresAt,
+		&link.RevokedAt,
+		&link.CreatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get shared link: %w", err)
+	}
+
+	return link, nil
+}
+
+// ListByConversation returns all links for a conversation, newest first
+func (r *ShareRepository) ListByConversation(ctx context.Context, conversationID uuid.UUID) ([]models.SharedLink, error) {
+	query := `
+		SELECT id, conversation_id, user_id, last_message_id, expires_at, revoked_at, created_at
+		FROM shared_links
+		WHERE conversation_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shared links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []models.SharedLink
+	for rows.Next() {
+		var link models.SharedLink
+		err := rows.Scan(
+			&link.ID,
+			&link.ConversationID,
+			&link.UserID,
+			&link.LastMessageID,
+			&link.ExpiresAt,
+			&link.RevokedAt,
+			&link.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shared link: %w", err)
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+// Revoke disables a link; revoking twice keeps the original timestamp
+func (r *ShareRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE shared_links
+		SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1`
+
+	if _, err := conn(ctx, r.db.Pool).Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to revoke shared link: %w", err)
+	}
+
+	return nil
+}
+
+// GetSnapshotMessages returns the messages included in a share link
+func (r *ShareRepository) GetSnapshotMessages(ctx context.Context, link *models.SharedLink) ([]models.SharedMessage, error) {
+	query := `
+		SELECT sender_type, content, created_at
+		FROM messages
+		WHERE conversation_id = $1 AND id <= $2 AND deleted_at IS NULL
+		ORDER BY created_at ASC, id ASC`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, link.ConversationID, link.LastMessageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.SharedMessage{}
+	for rows.Next() {
+		var msg models.SharedMessage
+		if err := rows.Scan(&msg.SenderType, &msg.Content, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan shared message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
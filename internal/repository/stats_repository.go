@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type StatsRepository struct {
+	db *database.DB
+}
+
+func NewStatsRepository(db *database.DB) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+// ActivityStats computes a user's statistics for the week from weekStart.
+// Active hours are the topHours hours of day in timeZone, an IANA name, in
+// which the user sent the most messages since activeSince.
+func (r *StatsRepository) ActivityStats(ctx context.Context, userID uuid.UUID, weekStart, activeSince time.Time, timeZone string, topHours int) (*models.ActivityStats, error) {
+	// Each count reads one of the per-user indexes
+	query := `
+		SELECT c.conversations, m.messages, u.tokens, u.tokens_this_week
+		FROM (
+			SELECT COUNT(*) AS conversations
+			FROM conversation_participants
+			WHERE user_id = $1
+		) c, (
+			SELECT COUNT(*) AS messages
+			FROM messages
+			WHERE sender_id = $1 AND created_at >= $2 AND deleted_at IS NULL
+		) m, (
+			SELECT COALESCE(SUM(total_tokens), 0)::BIGINT AS tokens,
+				COALESCE(SUM(total_tokens) FILTER (WHERE created_at >= $2), 0)::BIGINT AS tokens_this_week
+			FROM usage_records
+			WHERE user_id = $1
+		) u`
+
+	stats := &models.ActivityStats{TimeZone: timeZone, WeekStart: weekStart}
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, userID, weekStart).
+		Scan(&stats.Conversations, &stats.MessagesThisWeek, &stats.TokensUsed, &stats.TokensThisWeek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activity stats: %w", err)
+	}
+
+	query = `
+		SELECT EXTRACT(HOUR FROM created_at AT TIME ZONE $3)::INT AS hour, COUNT(*)
+		FROM messages
+		WHERE sender_id = $1 AND created_at >= $2 AND deleted_at IS NULL
+		GROUP BY hour
+		ORDER BY 2 DESC, hour
+		LIMIT $4`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, userID, activeSince, timeZone, topHours)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active hours: %w", err)
+	}
+	defer rows.Close()
+
+	stats.ActiveHours = []models.HourActivity{}
+	for rows.Next() {
+		var hour models.HourActivity
+		if err := rows.Scan(&hour.Hour, &hour.Messages); err != nil {
+			return nil, fmt.Errorf("failed to scan active hour: %w", err)
+		}
+		stats.ActiveHours = append(stats.ActiveHours, hour)
+	}
+
+	return stats, rows.Err()
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type UploadRepository struct {
+	db *database.DB
+}
+
+func NewUploadRepository(db *database.DB) *UploadRepository {
+	return &UploadRepository{db: db}
+}
+
+// Create stores upload metadata; the file itself lives in object storage
+func (r *UploadRepository) Create(ctx context.Context, upload *models.Upload) error {
+	query := `
+		INSERT INTO uploads (user_id, storage_key, filename, content_type, size_bytes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query,
+		upload.UserID,
+		upload.StorageKey,
+		upload.Filename,
+		upload.ContentType,
+		upload.SizeBytes,
+	).Scan(&upload.ID, &upload.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID returns an upload, or nil if it doesn't exist
+func (r *UploadRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Upload, error) {
+	query := `
+		SELECT id, user_id, message_id, storage_key, filename, content_type, size_bytes, created_at
+		FROM uploads
+		WHERE id = $1`
+
+	upload := &models.Upload{}
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, id).Scan(
+		&upload.ID,
+		&upload.UserID,
+		&upload.MessageID,
+		&upload.StorageKey,
+		&upload.Filename,
+		&upload.ContentType,
+		&upload.SizeBytes,
+		&upload.CreatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get upload: %w", err)
+	}
+
+	return upload, nil
+}
+
+// GetByIDsForUser returns the user's uploads with the given IDs. Uploads
+// owned by other users are silently skipped.
+func (r *UploadRepository) GetByIDsForUser(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) ([]models.Upload, error) {
+	query := `
+		SELECT id, user_id, message_id, storage_key, filename, content_type, size_bytes, created_at
+		FROM uploads
+		WHERE user_id = $1 AND id = ANY($2)
+		ORDER BY created_at`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, userID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uploads: %w", err)
+	}
+	defer rows.Close()
+
+	var uploads []models.Upload
+	for rows.Next() {
+		var upload models.Upload
+		err := rows.Scan(
+			&upload.ID,
+			&upload.UserID,
+			&upload.MessageID,
+			&upload.StorageKey,
+			&upload.Filename,
+			&upload.ContentType,
+			&upload.SizeBytes,
+			&upload.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan upload: %w", err)
+		}
+		uploads = append(uploads, upload)
+	}
+
+	return uploads, rows.Err()
+}
+
+// AttachToMessage links uploads to the chat message they were sent with
+func (r *UploadRepository) AttachToMessage(ctx context.Context, ids []uuid.UUID, messageID int64) error {
+	query := `UPDATE uploads SET message_id = $2 WHERE id = ANY($1)`
+
+	if _, err := conn(ctx, r.db.Pool).Exec(ctx, query, ids, messageID); err != nil {
+		return fmt.Errorf("failed to attach uploads: %w", err)
+	}
+
+	return nil
+}
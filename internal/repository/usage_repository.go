@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type UsageRepository struct {
+	db *database.DB
+}
+
+func NewUsageRepository(db *database.DB) *UsageRepository {
+	return &UsageRepository{db: db}
+}
+
+// Create stores a usage record, charged to the organization of its
+// conversation if it has one
+func (r *UsageRepository) Create(ctx context.Context, record *models.UsageRecord) error {
+	query := `
+		INSERT INTO usage_records (user_id, conversation_id, org_id, message_id, provider, model,
+			prompt_tokens, completion_tokens, total_tokens, cost_micros, own_key)
+		VALUES ($1, $2, (SELECT org_id FROM conversations WHERE id = $2), $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, org_id, created_at`
+
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query,
+		record.UserID, record.ConversationID, record.MessageID, record.Provider, record.Model,
+		record.PromptTokens, record.CompletionTokens, record.TotalTokens, record.CostMicros, record.OwnKey,
+	).Scan(&record.ID, &record.OrgID, &record.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create usage record: %w", err)
+	}
+	return nil
+}
+
+// usageTotalsColumns sums usage_records into the fields of
+// models.UsageTotals, in order
+const usageTotalsColumns = `
+	COUNT(*),
+	COALESCE(SUM(prompt_tokens), 0)::BIGINT,
+	COALESCE(SUM(completion_tokens), 0)::BIGINT,
+	COALESCE(SUM(total_tokens), 0)::BIGINT,
+	COALESCE(SUM(cost_micros), 0)::BIGINT,
+	COUNT(*) FILTER (WHERE cost_micros IS NULL)`
+
+// Report sums a user's usage in [from, to) per day (UTC), provider and
+// model
+func (r *UsageRepository) Report(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]models.UsageReportRow, error) {
+	query := `
+		SELECT date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, provider, model,` + usageTotalsColumns + `
+		FROM usage_records
+		WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		GROUP BY day, provider, model
+		ORDER BY day, provider, model`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage report: %w", err)
+	}
+	defer rows.Close()
+
+	report := []models.UsageReportRow{}
+	for rows.Next() {
+		var row models.UsageReportRow
+		if err := rows.Scan(&row.Day, &row.Provider, &row.Model,
+			&row.Replies, &row.PromptTokens, &row.CompletionTokens, &row.TotalTokens, &row.CostMicros, &row.Unpriced,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan usage report row: %w", err)
+		}
+		row.CostUSD = float64(row.CostMicros) / 1e6
+		report = append(report, row)
+	}
+
+	return report, rows.Err()
+}
+
+// RollupByUser sums the usage of every user with usage in [from, to),
+// highest cost first
+func (r *UsageRepository) RollupByUser(ctx context.Context, from, to time.Time) ([]models.UserUsage, error) {
+	query := `
+		SELECT u.id, u.email,` + usageTotalsColumns + `
+		FROM usage_records ur
+		JOIN users u ON u.id = ur.user_id
+		WHERE ur.created_at >= $1 AND ur.created_at < $2
+		GROUP BY u.id, u.email
+		ORDER BY 7 DESC, 6 DESC, u.email`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage rollup: %w", err)
+	}
+	defer rows.Close()
+
+	rollup := []models.UserUsage{}
+	for rows.Next() {
+		var row models.UserUsage
+		if err := rows.Scan(&row.UserID, &row.Email,
+			&row.Replies, &row.PromptTokens, &row.CompletionTokens, &row.TotalTokens, &row.CostMicros, &row.Unpriced,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan usage rollup row: %w", err)
+		}
+		row.CostUSD = float64(row.CostMicros) / 1e6
+		rollup = append(rollup, row)
+	}
+
+	return rollup, rows.Err()
+}
+
+// OrgTotals sums an organization's usage in [from, to)
+func (r *UsageRepository) OrgTotals(ctx context.Context, orgID uuid.UUID, from, to time.Time) (models.UsageTotals, error) {
+	query := `
+		SELECT` + usageTotalsColumns + `
+		FROM usage_records
+		WHERE org_id = $1 AND created_at >= $2 AND created_at < $3`
+
+	var totals models.UsageTotals
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, orgID, from, to).Scan(
+		&totals.Replies, &totals.PromptTokens, &totals.CompletionTokens, &totals.TotalTokens, &totals.CostMicros, &totals.Unpriced,
+	)
+	if err != nil {
+		return totals, fmt.Errorf("failed to query organization usage: %w", err)
+	}
+	totals.CostUSD = float64(totals.CostMicros) / 1e6
+	return totals, nil
+}
+
+// OrgQuotaExceeded reports whether an organization used up its monthly
+// token quota since monthStart. Organizations without a quota never do, and
+// replies on the organization's own API keys don't count.
+func (r *UsageRepository) OrgQuotaExceeded(ctx context.Context, orgID uuid.UUID, monthStart time.Time) (bool, error) {
+	query := `
+		SELECT o.monthly_token_quota IS NOT NULL AND COALESCE((
+			SELECT SUM(total_tokens)
+			FROM usage_records
+			WHERE org_id = o.id AND created_at >= $2 AND NOT own_key
+		), 0) >= o.monthly_token_quota
+		FROM organizations o
+		WHERE o.id = $1`
+
+	var exceeded bool
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, orgID, monthStart).Scan(&exceeded)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check organization quota: %w", err)
+	}
+	return exceeded, nil
+}
+
+// Models returns the providers and models with usage in [from, to)
+func (r *UsageRepository) Models(ctx context.Context, from, to time.Time) ([]models.UsageModel, error) {
+	query := `
+		SELECT DISTINCT provider, model
+		FROM usage_records
+		WHERE created_at >= $1 AND created_at < $2
+		ORDER BY provider, model`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage models: %w", err)
+	}
+	defer rows.Close()
+
+	var list []models.UsageModel
+	for rows.Next() {
+		var m models.UsageModel
+		if err := rows.Scan(&m.Provider, &m.Model); err != nil {
+			return nil, fmt.Errorf("failed to scan usage model: %w", err)
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// Reprice recomputes the cost of a model's usage in [from, to) at the given
+// prices per million tokens, rounding like the recorder does, and returns
+// how many records changed
+func (r *UsageRepository) Reprice(ctx context.Context, provider, model string, from, to time.Time, inputPerMillion, outputPerMillion float64) (int64, error) {
+	query := `
+		UPDATE usage_records
+		SET cost_micros = ROUND(prompt_tokens * $5::NUMERIC + completion_tokens * $6::NUMERIC)::BIGINT
+		WHERE provider = $1 AND model = $2 AND created_at >= $3 AND created_at < $4
+			AND cost_micros IS DISTINCT FROM ROUND(prompt_tokens * $5::NUMERIC + completion_tokens * $6::NUMERIC)::BIGINT`
+
+	tag, err := conn(ctx, r.db.Pool).Exec(ctx, query, provider, model, from, to, inputPerMillion, outputPerMillion)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reprice usage: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Unprice clears the cost of a model's usage in [from, to), for models
+// that no longer have a price, and returns how many records changed
+func (r *UsageRepository) Unprice(ctx context.Context, provider, model string, from, to time.Time) (int64, error) {
+	query := `
+		UPDATE usage_records
+		SET cost_micros = NULL
+		WHERE provider = $1 AND model = $2 AND created_at >= $3 AND created_at < $4
+			AND cost_micros IS NOT NULL`
+
+	tag, err := conn(ctx, r.db.Pool).Exec(ctx, query, provider, model, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unprice usage: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
+	"time"
 
 	"github.com/shivaluma/eino-agent/internal/database"
 	"github.com/shivaluma/eino-agent/internal/models"
@@ -26,7 +27,7 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at, updated_at`
 
-	return r.db.Pool.QueryRow(ctx, query,
+	return conn(ctx, r.db.Pool).QueryRow(ctx, query,
 		user.Username,
 		user.Email,
 		user.PasswordHash,
@@ -39,15 +40,15 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, oauth_provider, oauth_provider_id, avatar_url, oauth_email, created_at, updated_at
+		SELECT id, username, email, password_hash, oauth_provider, oauth_provider_id, avatar_url, oauth_email, is_admin, locked_at, lock_reason, created_at, updated_at
 		FROM users
 		WHERE email = $1`
 
 	user := &models.User{}
-	err := r.db.Pool.QueryRow(ctx, query, email).
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, email).
 		Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash,
 			&user.OAuthProvider, &user.OAuthProviderID, &user.AvatarURL, &user.OAuthEmail,
-			&user.CreatedAt, &user.UpdatedAt)
+			&user.IsAdmin, &user.LockedAt, &user.LockReason, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -61,15 +62,15 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.
 
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, oauth_provider, oauth_provider_id, avatar_url, oauth_email, created_at, updated_at
+		SELECT id, username, email, password_hash, oauth_provider, oauth_provider_id, avatar_url, oauth_email, is_admin, locked_at, lock_reason, created_at, updated_at
 		FROM users
 		WHERE id = $1`
 
 	user := &models.User{}
-	err := r.db.Pool.QueryRow(ctx, query, id).
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, id).
 		Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash,
 			&user.OAuthProvider, &user.OAuthProviderID, &user.AvatarURL, &user.OAuthEmail,
-			&user.CreatedAt, &user.UpdatedAt)
+			&user.IsAdmin, &user.LockedAt, &user.LockReason, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -83,15 +84,15 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, oauth_provider, oauth_provider_id, avatar_url, oauth_email, created_at, updated_at
+		SELECT id, username, email, password_hash, oauth_provider, oauth_provider_id, avatar_url, oauth_email, is_admin, locked_at, lock_reason, created_at, updated_at
 		FROM users
 		WHERE username = $1`
 
 	user := &models.User{}
-	err := r.db.Pool.QueryRow(ctx, query, username).
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, username).
 		Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash,
 			&user.OAuthProvider, &user.OAuthProviderID, &user.AvatarURL, &user.OAuthEmail,
-			&user.CreatedAt, &user.UpdatedAt)
+			&user.IsAdmin, &user.LockedAt, &user.LockReason, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -103,17 +104,29 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*m
 	return user, nil
 }
 
+// StoreRefreshToken stores a token. One without a session starts a new
+// session; the session's ID and start are set on the token.
 func (r *UserRepository) StoreRefreshToken(ctx context.Context, token *models.RefreshToken) error {
 	tokenHash := sha256.Sum256([]byte(token.TokenHash))
 	token.TokenHash = fmt.Sprintf("%x", tokenHash)
 
-	query := `
-		INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at`
+	var sessionID *uuid.UUID
+	var signedInAt *time.Time
+	if token.SessionID != uuid.Nil {
+		sessionID, signedInAt = &token.SessionID, &token.SignedInAt
+	}
 
-	return r.db.Pool.QueryRow(ctx, query, token.UserID, token.TokenHash, token.ExpiresAt).
-		Scan(&token.ID, &token.CreatedAt)
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at, session_id, signed_in_at,
+			fingerprint, user_agent, device_id, ip_address)
+		VALUES ($1, $2, $3, COALESCE($4, gen_random_uuid()), COALESCE($5, NOW()),
+			NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''))
+		RETURNING id, created_at, session_id, signed_in_at`
+
+	device := token.Device
+	return conn(ctx, r.db.Pool).QueryRow(ctx, query, token.UserID, token.TokenHash, token.ExpiresAt, sessionID, signedInAt,
+		device.Fingerprint, device.UserAgent, device.DeviceID, device.IPAddress).
+		Scan(&token.ID, &token.CreatedAt, &token.SessionID, &token.SignedInAt)
 }
 
 func (r *UserRepository) GetRefreshToken(ctx context.Context, tokenString string) (*models.RefreshToken, error) {
@@ -121,13 +134,16 @@ func (r *UserRepository) GetRefreshToken(ctx context.Context, tokenString string
 	hashedToken := fmt.Sprintf("%x", tokenHash)
 
 	query := `
-		SELECT id, user_id, token_hash, expires_at, created_at, used_at
+		SELECT id, user_id, token_hash, expires_at, created_at, used_at, session_id, signed_in_at,
+			COALESCE(fingerprint, ''), COALESCE(user_agent, ''), COALESCE(device_id, ''), COALESCE(ip_address, '')
 		FROM refresh_tokens
 		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()`
 
 	token := &models.RefreshToken{}
-	err := r.db.Pool.QueryRow(ctx, query, hashedToken).
-		Scan(&token.ID, &token.UserID, &token.TokenHash, &token.ExpiresAt, &token.CreatedAt, &token.UsedAt)
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, hashedToken).
+		Scan(&token.ID, &token.UserID, &token.TokenHash, &token.ExpiresAt, &token.CreatedAt, &token.UsedAt,
+			&token.SessionID, &token.SignedInAt, &token.Device.Fingerprint, &token.Device.UserAgent,
+			&token.Device.DeviceID, &token.Device.IPAddress)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -145,38 +161,179 @@ func (r *UserRepository) InvalidateRefreshToken(ctx context.Context, tokenID uui
 		SET used_at = NOW()
 		WHERE id = $1`
 
-	_, err := r.db.Pool.Exec(ctx, query, tokenID)
+	_, err := conn(ctx, r.db.Pool).Exec(ctx, query, tokenID)
 	return err
 }
 
-func (r *UserRepository) CleanupExpiredTokens(ctx context.Context) error {
+// GetRefreshTokenOwner returns the user a refresh token was issued to,
+// whether or not it was used or expired, so failed refreshes can be
+// attributed. It returns nil for unknown tokens.
+func (r *UserRepository) GetRefreshTokenOwner(ctx context.Context, tokenString string) (*uuid.UUID, error) {
+	tokenHash := sha256.Sum256([]byte(tokenString))
+	hashedToken := fmt.Sprintf("%x", tokenHash)
+
+	query := `SELECT user_id FROM refresh_tokens WHERE token_hash = $1`
+
+	var userID uuid.UUID
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, hashedToken).Scan(&userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &userID, nil
+}
+
+// InvalidateUserRefreshTokens ends every session of the user
+func (r *UserRepository) InvalidateUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
 	query := `
-		DELETE FROM refresh_tokens
-		WHERE expires_at < NOW() OR used_at IS NOT NULL`
+		UPDATE refresh_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL`
 
-	_, err := r.db.Pool.Exec(ctx, query)
+	_, err := conn(ctx, r.db.Pool).Exec(ctx, query, userID)
 	return err
 }
 
-// BeginTx starts a new database transaction
-func (r *UserRepository) BeginTx(ctx context.Context) (pgx.Tx, error) {
-	return r.db.Pool.Begin(ctx)
+// ListSessions returns the signed-in devices of the user, most recently
+// active first. A session is signed in while its latest token is unused
+// and unexpired.
+func (r *UserRepository) ListSessions(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	query := `
+		SELECT session_id, signed_in_at, created_at, expires_at,
+			COALESCE(user_agent, ''), COALESCE(device_id, ''), COALESCE(ip_address, '')
+		FROM refresh_tokens
+		WHERE user_id = $1 AND used_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC`
+
+	rows, err := conn(ctx, r.db.Pool).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		session := &models.Session{}
+		if err := rows.Scan(&session.ID, &session.SignedInAt, &session.LastActiveAt, &session.ExpiresAt,
+			&session.Device.UserAgent, &session.Device.DeviceID, &session.Device.IPAddress); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
 }
 
-// CreateTx creates a user within an existing transaction
-func (r *UserRepository) CreateTx(ctx context.Context, tx pgx.Tx, user *models.User) error {
+// RevokeSession signs a session of the user out. It reports false when the
+// user has no such session signed in.
+func (r *UserRepository) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) (bool, error) {
 	query := `
-		INSERT INTO users (username, email, password_hash, oauth_provider, oauth_provider_id, avatar_url, oauth_email)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, created_at, updated_at`
+		UPDATE refresh_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND session_id = $2 AND used_at IS NULL AND expires_at > NOW()`
 
-	return tx.QueryRow(ctx, query,
-		user.Username,
-		user.Email,
-		user.PasswordHash,
-		user.OAuthProvider,
-		user.OAuthProviderID,
-		user.AvatarURL,
-		user.OAuthEmail,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	tag, err := conn(ctx, r.db.Pool).Exec(ctx, query, userID, sessionID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Lock locks the account until the unlock token whose hash is given is
+// redeemed. It reports false when the account was already locked.
+func (r *UserRepository) Lock(ctx context.Context, userID uuid.UUID, reason, unlockTokenHash string) (bool, error) {
+	query := `
+		UPDATE users
+		SET locked_at = NOW(), lock_reason = $2, unlock_token_hash = $3
+		WHERE id = $1 AND locked_at IS NULL`
+
+	tag, err := conn(ctx, r.db.Pool).Exec(ctx, query, userID, reason, unlockTokenHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Unlock unlocks the account the unlock token was issued for and returns
+// its ID, or nil when no locked account has the token
+func (r *UserRepository) Unlock(ctx context.Context, unlockTokenHash string) (*uuid.UUID, error) {
+	query := `
+		UPDATE users
+		SET locked_at = NULL, lock_reason = NULL, unlock_token_hash = NULL
+		WHERE unlock_token_hash = $1 AND locked_at IS NOT NULL
+		RETURNING id`
+
+	var userID uuid.UUID
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, unlockTokenHash).Scan(&userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &userID, nil
+}
+
+// RecordLoginCountry notes that the user signed in from country. It
+// reports whether the country is new for a user who had signed in from
+// elsewhere before; the first country a user is seen in isn't new.
+func (r *UserRepository) RecordLoginCountry(ctx context.Context, userID uuid.UUID, country string) (bool, error) {
+	query := `
+		WITH known AS (
+			SELECT COUNT(*) AS countries, COALESCE(BOOL_OR(country = $2), false) AS seen
+			FROM user_login_countries
+			WHERE user_id = $1
+		), upsert AS (
+			INSERT INTO user_login_countries (user_id, country)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id, country) DO UPDATE SET last_seen_at = NOW()
+		)
+		SELECT countries > 0 AND NOT seen FROM known`
+
+	var isNew bool
+	err := conn(ctx, r.db.Pool).QueryRow(ctx, query, userID, country).Scan(&isNew)
+	return isNew, err
+}
+
+// SetPassword replaces the user's password hash
+func (r *UserRepository) SetPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $2
+		WHERE id = $1`
+
+	_, err := conn(ctx, r.db.Pool).Exec(ctx, query, userID, passwordHash)
+	return err
+}
+
+// SetAdmin grants or takes away the user's admin rights
+func (r *UserRepository) SetAdmin(ctx context.Context, userID uuid.UUID, admin bool) error {
+	query := `
+		UPDATE users
+		SET is_admin = $2
+		WHERE id = $1`
+
+	_, err := conn(ctx, r.db.Pool).Exec(ctx, query, userID, admin)
+	return err
+}
+
+// UpdateAvatarURL sets or clears (nil) the user's avatar URL
+func (r *UserRepository) UpdateAvatarURL(ctx context.Context, userID uuid.UUID, avatarURL *string) error {
+	query := `
+		UPDATE users
+		SET avatar_url = $2
+		WHERE id = $1`
+
+	_, err := conn(ctx, r.db.Pool).Exec(ctx, query, userID, avatarURL)
+	return err
+}
+
+func (r *UserRepository) CleanupExpiredTokens(ctx context.Context) error {
+	query := `
+		DELETE FROM refresh_tokens
+		WHERE expires_at < NOW() OR used_at IS NOT NULL`
+
+	_, err := conn(ctx, r.db.Pool).Exec(ctx, query)
+	return err
 }
@@ -0,0 +1,95 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+func TestUserRepository_CreateAndGet(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewUserRepository(testutil.DB(t))
+
+	hash := "hash"
+	user := &models.User{Username: "alice", Email: "alice@example.com", PasswordHash: &hash}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if user.ID == uuid.Nil {
+		t.Fatal("Create did not set the user ID")
+	}
+
+	byEmail, err := users.GetByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	if byEmail == nil || byEmail.ID != user.ID {
+		t.Fatalf("GetByEmail = %+v, want user %s", byEmail, user.ID)
+	}
+
+	byID, err := users.GetByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if byID == nil || byID.Email != user.Email || byID.PasswordHash == nil || *byID.PasswordHash != hash {
+		t.Fatalf("GetByID = %+v, want %+v", byID, user)
+	}
+
+	missing, err := users.GetByEmail(ctx, "nobody@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail of unknown email: %v", err)
+	}
+	if missing != nil {
+		t.Fatalf("GetByEmail of unknown email = %+v, want nil", missing)
+	}
+
+	duplicate := &models.User{Username: "alice2", Email: "alice@example.com", PasswordHash: &hash}
+	if err := users.Create(ctx, duplicate); err == nil {
+		t.Fatal("Create with a taken email succeeded")
+	}
+}
+
+func TestUserRepository_RefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	env := testutil.NewEnv(t)
+	user := env.CreateUser(t, "bob@example.com", "password123")
+
+	token, err := env.Auth.GenerateRefreshToken()
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	record := env.Auth.CreateRefreshTokenRecord(user.ID, token, models.Device{}, nil)
+	if err := env.Users.StoreRefreshToken(ctx, record); err != nil {
+		t.Fatalf("StoreRefreshToken: %v", err)
+	}
+
+	stored, err := env.Users.GetRefreshToken(ctx, token)
+	if err != nil {
+		t.Fatalf("GetRefreshToken: %v", err)
+	}
+	if stored == nil || stored.UserID != user.ID {
+		t.Fatalf("GetRefreshToken = %+v, want a token of user %s", stored, user.ID)
+	}
+	if !stored.ExpiresAt.After(time.Now()) {
+		t.Fatalf("stored token expires at %s, already past", stored.ExpiresAt)
+	}
+
+	if err := env.Users.InvalidateRefreshToken(ctx, stored.ID); err != nil {
+		t.Fatalf("InvalidateRefreshToken: %v", err)
+	}
+	used, err := env.Users.GetRefreshToken(ctx, token)
+	if err != nil {
+		t.Fatalf("GetRefreshToken after invalidating: %v", err)
+	}
+	if used != nil {
+		t.Fatalf("GetRefreshToken after invalidating = %+v, want nil", used)
+	}
+}
@@ -0,0 +1,128 @@
+// Package retention deletes data past its retention: conversations without
+// activity for longer than their organization or the server allows, and
+// soft-deleted messages. In dry-run mode the jobs only report what they
+// would delete.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/repository"
+)
+
+// batchSize bounds the conversations deleted per statement, so a large
+// backlog doesn't hold locks for long
+const batchSize = 500
+
+// Config controls what is deleted
+type Config struct {
+	// ConversationDays is how many days conversations are kept after their
+	// last activity unless their organization overrides it; zero keeps
+	// them forever
+	ConversationDays int
+
+	// PurgeAfter is how long soft-deleted messages are kept
+	PurgeAfter time.Duration
+
+	// DryRun makes the jobs report what they would delete instead
+	DryRun bool
+}
+
+// Enforcer runs the retention jobs
+type Enforcer struct {
+	repo     *repository.RetentionRepository
+	convRepo *repository.ConversationRepository
+	config   Config
+}
+
+func New(repo *repository.RetentionRepository, convRepo *repository.ConversationRepository, config Config) *Enforcer {
+	return &Enforcer{repo: repo, convRepo: convRepo, config: config}
+}
+
+// Report lists what the jobs would delete if they ran now
+func (e *Enforcer) Report(ctx context.Context) (*models.RetentionReport, error) {
+	scopes, err := e.repo.ExpiredConversations(ctx, e.config.ConversationDays)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := e.convRepo.CountDeletedMessages(ctx, time.Now().Add(-e.config.PurgeAfter))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count deleted messages: %w", err)
+	}
+
+	report := &models.RetentionReport{
+		DryRun:                  e.config.DryRun,
+		DefaultConversationDays: e.config.ConversationDays,
+		Scopes:                  scopes,
+		DeletedMessages:         deleted,
+	}
+	for _, scope := range scopes {
+		report.Conversations += scope.Conversations
+		report.Messages += scope.Messages
+	}
+	return report, nil
+}
+
+// DeleteExpiredConversations deletes conversations past their retention,
+// as a scheduler job
+func (e *Enforcer) DeleteExpiredConversations(ctx context.Context) (string, error) {
+	log := logger.ModuleContext(ctx, "retention")
+
+	if e.config.DryRun {
+		scopes, err := e.repo.ExpiredConversations(ctx, e.config.ConversationDays)
+		if err != nil {
+			return "", err
+		}
+		var conversations, messages int64
+		for _, scope := range scopes {
+			event := log.Info().Int("retention_days", scope.RetentionDays).Int64("conversations", scope.Conversations).Int64("messages", scope.Messages)
+			if scope.OrgID != nil {
+				event = event.Str("org_id", scope.OrgID.String())
+			}
+			event.Msg("Dry run, would delete expired conversations")
+			conversations += scope.Conversations
+			messages += scope.Messages
+		}
+		return fmt.Sprintf("dry run: would delete %d conversations with %d messages", conversations, messages), nil
+	}
+
+	var total int64
+	for {
+		deleted, err := e.repo.DeleteExpiredConversations(ctx, e.config.ConversationDays, batchSize)
+		total += deleted
+		if err != nil {
+			return fmt.Sprintf("deleted %d conversations", total), err
+		}
+		if deleted < batchSize || ctx.Err() != nil {
+			break
+		}
+	}
+	if total > 0 {
+		log.Info().Int64("conversations", total).Msg("Deleted expired conversations")
+	}
+	return fmt.Sprintf("deleted %d conversations", total), nil
+}
+
+// PurgeDeletedMessages removes messages soft-deleted longer than
+// PurgeAfter ago, as a scheduler job
+func (e *Enforcer) PurgeDeletedMessages(ctx context.Context) (string, error) {
+	before := time.Now().Add(-e.config.PurgeAfter)
+
+	if e.config.DryRun {
+		count, err := e.convRepo.CountDeletedMessages(ctx, before)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("dry run: would purge %d messages", count), nil
+	}
+
+	purged, err := e.convRepo.PurgeDeletedMessages(ctx, before)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("purged %d messages", purged), nil
+}
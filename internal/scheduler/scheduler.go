@@ -0,0 +1,188 @@
+// Package scheduler runs named background jobs on a fixed interval and lets
+// admins trigger them on demand.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+var (
+	// ErrUnknownJob is returned when no job has the requested name
+	ErrUnknownJob = errors.New("unknown job")
+
+	// ErrJobRunning is returned when a job is triggered while it runs
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// JobFunc is the work of a job. The returned summary is reported in the
+// job's status, e.g. how many rows it removed.
+type JobFunc func(ctx context.Context) (summary string, err error)
+
+// JobStatus describes a job and its last run
+type JobStatus struct {
+	Name        string        `json:"name"`
+	Interval    time.Duration `json:"interval"`
+	Running     bool          `json:"running"`
+	LastRun     *time.Time    `json:"last_run,omitempty"`
+	LastSummary string        `json:"last_summary,omitempty"`
+	LastError   string        `json:"last_error,omitempty"`
+	NextRun     *time.Time    `json:"next_run,omitempty"`
+}
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      JobFunc
+
+	running     bool
+	lastRun     time.Time
+	lastSummary string
+	lastErr     error
+	nextRun     time.Time
+}
+
+// Scheduler runs registered jobs. A job never overlaps with itself.
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// New creates an empty scheduler
+func New() *Scheduler {
+	return &Scheduler{jobs: make(map[string]*job)}
+}
+
+// Add registers a job. A zero interval registers it for on-demand runs
+// only. Jobs must be added before Start.
+func (s *Scheduler) Add(name string, interval time.Duration, run JobFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[name] = &job{name: name, interval: interval, run: run}
+}
+
+// Start runs every job with an interval in the background until ctx is
+// done. The first run happens one interval after Start.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.interval <= 0 {
+			continue
+		}
+		j.nextRun = time.Now().Add(j.interval)
+		go s.loop(ctx, j)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		j.nextRun = time.Now().Add(j.interval)
+		s.mu.Unlock()
+
+		if err := s.execute(ctx, j); errors.Is(err, ErrJobRunning) {
+			logger.Module("scheduler").Warn().Str("job", j.name).Msg("Skipping run, previous run still in progress")
+		}
+	}
+}
+
+// RunNow runs a job immediately and waits for it to finish. A failed run is
+// reported in the returned status rather than as an error.
+func (s *Scheduler) RunNow(ctx context.Context, name string) (JobStatus, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return JobStatus{}, ErrUnknownJob
+	}
+
+	if err := s.execute(ctx, j); errors.Is(err, ErrJobRunning) {
+		return JobStatus{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return j.status(), nil
+}
+
+// execute runs a job once and records the outcome. It returns
+// ErrJobRunning if the job is already running, and the job's error
+// otherwise.
+func (s *Scheduler) execute(ctx context.Context, j *job) error {
+	s.mu.Lock()
+	if j.running {
+		s.mu.Unlock()
+		return ErrJobRunning
+	}
+	j.running = true
+	s.mu.Unlock()
+
+	log := logger.Module("scheduler")
+	start := time.Now()
+	summary, err := j.run(ctx)
+
+	s.mu.Lock()
+	j.running = false
+	j.lastRun = start
+	j.lastSummary = summary
+	j.lastErr = err
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Error().Err(err).Str("job", j.name).Dur("duration", time.Since(start)).Msg("Job failed")
+		return err
+	}
+	log.Info().Str("job", j.name).Str("summary", summary).Dur("duration", time.Since(start)).Msg("Job finished")
+	return nil
+}
+
+// Status returns every job's status, sorted by name
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status())
+	}
+	sort.Slice(statuses, func(i, k int) bool { return statuses[i].Name < statuses[k].Name })
+	return statuses
+}
+
+// status must be called with the scheduler lock held
+func (j *job) status() JobStatus {
+	st := JobStatus{
+		Name:        j.name,
+		Interval:    j.interval,
+		Running:     j.running,
+		LastSummary: j.lastSummary,
+	}
+	if !j.lastRun.IsZero() {
+		lastRun := j.lastRun
+		st.LastRun = &lastRun
+	}
+	if j.lastErr != nil {
+		st.LastError = j.lastErr.Error()
+	}
+	if !j.nextRun.IsZero() {
+		nextRun := j.nextRun
+		st.NextRun = &nextRun
+	}
+	return st
+}
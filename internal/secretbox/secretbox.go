@@ -0,0 +1,59 @@
+// Package secretbox encrypts small secrets, like API keys, before they are
+// stored
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrDecrypt is returned for sealed secrets that were tampered with, sealed
+// with another key or for another purpose
+var ErrDecrypt = errors.New("failed to decrypt secret")
+
+// Box seals secrets with AES-256-GCM under a key derived from a passphrase
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New creates a box whose key is the SHA-256 of passphrase
+func New(passphrase string) (*Box, error) {
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts secret. The same associated data, e.g. the ID of the row the
+// secret is stored in, must be passed to Open, so a sealed secret copied
+// elsewhere can't be opened.
+func (b *Box) Seal(secret string, associated []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return b.aead.Seal(nonce, nonce, []byte(secret), associated), nil
+}
+
+// Open decrypts a secret sealed with the same key and associated data
+func (b *Box) Open(sealed, associated []byte) (string, error) {
+	size := b.aead.NonceSize()
+	if len(sealed) < size {
+		return "", ErrDecrypt
+	}
+	secret, err := b.aead.Open(nil, sealed[:size], sealed[size:], associated)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(secret), nil
+}
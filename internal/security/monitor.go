@@ -0,0 +1,268 @@
+// Package security detects suspicious account activity. Accounts are
+// locked until their owner follows a link emailed to them, and every
+// anomaly is audited and posted to a webhook.
+package security
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/audit"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/cache"
+	"github.com/shivaluma/eino-agent/internal/geoip"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/mail"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/queue"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Reasons an account is locked or an anomaly is reported
+const (
+	ReasonRefreshFailures = "refresh_failures"
+	ReasonNewCountry      = "new_country"
+)
+
+// Webhook events
+const (
+	EventAnomaly       = "anomaly"
+	EventAccountLocked = "account_locked"
+)
+
+// refreshFailureKeyPrefix counts failed refreshes per user in the cache
+const refreshFailureKeyPrefix = "security:refresh-failures:"
+
+// Monitor watches sign-ins and token refreshes for signs of a compromised
+// account
+type Monitor struct {
+	userRepo    *repository.UserRepository
+	authSvc     *auth.Service
+	cache       cache.Cache
+	geo         geoip.Locator
+	mailer      mail.Mailer
+	auditor     *audit.Auditor
+	tasks       queue.Queue
+	cfg         config.SecurityConfig
+	frontendURL string
+}
+
+// NewMonitor creates a monitor. Unlock links point to frontendURL; webhooks
+// are delivered by tasks.
+func NewMonitor(userRepo *repository.UserRepository, authSvc *auth.Service, c cache.Cache, geo geoip.Locator, mailer mail.Mailer, auditor *audit.Auditor, tasks queue.Queue, cfg config.SecurityConfig, frontendURL string) *Monitor {
+	return &Monitor{
+		userRepo:    userRepo,
+		authSvc:     authSvc,
+		cache:       c,
+		geo:         geo,
+		mailer:      mailer,
+		auditor:     auditor,
+		tasks:       tasks,
+		cfg:         cfg,
+		frontendURL: frontendURL,
+	}
+}
+
+// CheckLogin is called once a user proved who they are, before tokens are
+// issued. It reports whether the account is locked, possibly by this
+// sign-in coming from a new country, in which case it must be refused.
+func (m *Monitor) CheckLogin(c echo.Context, user *models.User) bool {
+	if user.LockedAt != nil {
+		return true
+	}
+
+	ctx := c.Request().Context()
+	country, err := m.geo.Country(ctx, c.RealIP())
+	if err != nil {
+		logger.ModuleContext(ctx, "security").Warn().Err(err).Msg("GeoIP lookup failed")
+		return false
+	}
+	if country == "" {
+		return false
+	}
+
+	isNew, err := m.userRepo.RecordLoginCountry(ctx, user.ID, country)
+	if err != nil {
+		logger.ModuleContext(ctx, "security").Warn().Err(err).Msg("Failed to record sign-in country")
+		return false
+	}
+	if !isNew {
+		return false
+	}
+
+	details := map[string]interface{}{"country": country}
+	if !m.cfg.LockNewCountry {
+		m.report(c, user, ReasonNewCountry, details)
+		m.notify(ctx, user, "New sign-in to your account",
+			fmt.Sprintf("Your account was just signed into from a new country (%s). If this wasn't you, change your password.", country))
+		return false
+	}
+	return m.Lock(c, user, ReasonNewCountry, details)
+}
+
+// RefreshFailed counts a refresh with a used or expired token of the user
+// and locks the account once there were too many. Replaying used tokens is
+// how stolen ones show up.
+func (m *Monitor) RefreshFailed(c echo.Context, userID uuid.UUID, reason string) {
+	if m.cfg.RefreshFailureLimit <= 0 {
+		return
+	}
+
+	ctx := c.Request().Context()
+	count, err := m.cache.Incr(ctx, refreshFailureKeyPrefix+userID.String(), m.cfg.RefreshFailureWindow)
+	if err != nil {
+		logger.ModuleContext(ctx, "security").Warn().Err(err).Msg("Failed to count refresh failure")
+		return
+	}
+	if count < int64(m.cfg.RefreshFailureLimit) {
+		return
+	}
+
+	user, err := m.userRepo.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return
+	}
+	m.Lock(c, user, ReasonRefreshFailures, map[string]interface{}{
+		"failures":    count,
+		"last_reason": reason,
+	})
+}
+
+// Lock locks the account, ends its sessions, revokes its access tokens and
+// emails its owner a link to unlock it. It reports whether the account is locked, which it may
+// already have been.
+func (m *Monitor) Lock(c echo.Context, user *models.User, reason string, details map[string]interface{}) bool {
+	ctx := c.Request().Context()
+	log := logger.ModuleContext(ctx, "security")
+
+	token, err := newUnlockToken()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate unlock token")
+		return false
+	}
+
+	locked, err := m.userRepo.Lock(ctx, user.ID, reason, hashToken(token))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to lock account")
+		return false
+	}
+	if !locked {
+		return true
+	}
+
+	if err := m.userRepo.InvalidateUserRefreshTokens(ctx, user.ID); err != nil {
+		log.Error().Err(err).Msg("Failed to end sessions of locked account")
+	}
+	// Access tokens already issued would otherwise work until they expire
+	if err := m.authSvc.RevokeUserAccessTokens(ctx, user.ID); err != nil {
+		log.Error().Err(err).Msg("Failed to revoke access tokens of locked account")
+	}
+
+	metadata := map[string]interface{}{"reason": reason}
+	for k, v := range details {
+		metadata[k] = v
+	}
+	m.auditor.RecordRequest(c, models.AuditActionAccountLock, &user.ID, true, metadata)
+	m.sendWebhook(ctx, EventAccountLocked, c, user, reason, details)
+
+	link := m.frontendURL + "/unlock?token=" + url.QueryEscape(token)
+	m.notify(ctx, user, "Your account has been locked",
+		fmt.Sprintf("We noticed unusual activity on your account (%s) and locked it to protect you.\n\n"+
+			"If it was you, unlock your account here:\n%s\n\n"+
+			"If it wasn't, unlock it and change your password right away.", reason, link))
+
+	log.Warn().Str("user_id", user.ID.String()).Str("reason", reason).Msg("Account locked")
+	return true
+}
+
+// Unlock redeems an unlock token. It reports false for a token that doesn't
+// belong to a locked account.
+func (m *Monitor) Unlock(c echo.Context, token string) (bool, error) {
+	ctx := c.Request().Context()
+	userID, err := m.userRepo.Unlock(ctx, hashToken(token))
+	if err != nil {
+		return false, err
+	}
+	if userID == nil {
+		return false, nil
+	}
+
+	if err := m.cache.Delete(ctx, refreshFailureKeyPrefix+userID.String()); err != nil {
+		logger.ModuleContext(ctx, "security").Warn().Err(err).Msg("Failed to reset refresh failures")
+	}
+	m.auditor.RecordRequest(c, models.AuditActionAccountUnlock, userID, true, nil)
+	return true, nil
+}
+
+// report audits and posts an anomaly that didn't lock the account
+func (m *Monitor) report(c echo.Context, user *models.User, reason string, details map[string]interface{}) {
+	metadata := map[string]interface{}{"reason": reason}
+	for k, v := range details {
+		metadata[k] = v
+	}
+	m.auditor.RecordRequest(c, models.AuditActionAnomaly, &user.ID, true, metadata)
+	m.sendWebhook(c.Request().Context(), EventAnomaly, c, user, reason, details)
+}
+
+func (m *Monitor) notify(ctx context.Context, user *models.User, subject, text string) {
+	err := m.mailer.Send(ctx, mail.Message{To: user.Email, Subject: subject, Text: text})
+	if err != nil {
+		logger.ModuleContext(ctx, "security").Error().Err(err).Msg("Failed to email user")
+	}
+}
+
+// webhookPayload is POSTed to SECURITY_WEBHOOK_URL
+type webhookPayload struct {
+	Event      string                 `json:"event"`
+	UserID     uuid.UUID              `json:"user_id"`
+	Email      string                 `json:"email"`
+	Reason     string                 `json:"reason"`
+	IPAddress  string                 `json:"ip_address"`
+	UserAgent  string                 `json:"user_agent"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// sendWebhook queues delivering an event. Deliveries are retried by the
+// task queue; the audit log has every event.
+func (m *Monitor) sendWebhook(ctx context.Context, event string, c echo.Context, user *models.User, reason string, details map[string]interface{}) {
+	if m.cfg.WebhookURL == "" {
+		return
+	}
+
+	err := m.tasks.Enqueue(ctx, TaskWebhook, webhookPayload{
+		Event:      event,
+		UserID:     user.ID,
+		Email:      user.Email,
+		Reason:     reason,
+		IPAddress:  c.RealIP(),
+		UserAgent:  c.Request().UserAgent(),
+		Details:    details,
+		OccurredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		logger.ModuleContext(ctx, "security").Error().Err(err).Str("event", event).Msg("Failed to queue security webhook")
+	}
+}
+
+func newUnlockToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("%x", sum)
+}
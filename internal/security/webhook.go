@@ -0,0 +1,49 @@
+package security
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// TaskWebhook is the queued task that posts an event to
+// SECURITY_WEBHOOK_URL
+const TaskWebhook = "security_webhook"
+
+// Webhook delivers the events queued by monitors
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a webhook posting to url
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Deliver runs a TaskWebhook task. Any 2xx response counts as delivered;
+// other responses are retried.
+func (w *Webhook) Deliver(ctx context.Context, payload json.RawMessage) error {
+	if w.url == "" {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build security webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver security webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("security webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package share
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// Embed themes; auto follows the reader's color scheme
+const (
+	ThemeAuto  = "auto"
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// MaxEmbedMessages is the most messages an embed shows; longer ranges are
+// cut short
+const MaxEmbedMessages = 100
+
+// ErrInvalidEmbed is returned for embed options that can't be parsed
+var ErrInvalidEmbed = errors.New("invalid embed options")
+
+// EmbedOptions select how a shared conversation is embedded: its theme and
+// the messages shown, From and To being 1-based positions in the snapshot
+// (0 for its first and last message)
+type EmbedOptions struct {
+	Theme string
+	From  int
+	To    int
+}
+
+// ParseEmbedOptions reads the theme, from and to query parameters
+func ParseEmbedOptions(query url.Values) (EmbedOptions, error) {
+	opts := EmbedOptions{Theme: ThemeAuto}
+	switch theme := query.Get("theme"); theme {
+	case "":
+	case ThemeAuto, ThemeLight, ThemeDark:
+		opts.Theme = theme
+	default:
+		return opts, ErrInvalidEmbed
+	}
+
+	var err error
+	if opts.From, err = position(query.Get("from")); err != nil {
+		return opts, err
+	}
+	if opts.To, err = position(query.Get("to")); err != nil {
+		return opts, err
+	}
+	if opts.From > 0 && opts.To > 0 && opts.To < opts.From {
+		return opts, ErrInvalidEmbed
+	}
+	return opts, nil
+}
+
+func position(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, ErrInvalidEmbed
+	}
+	return n, nil
+}
+
+// Query returns the options as query parameters, leaving out defaults
+func (o EmbedOptions) Query() url.Values {
+	query := url.Values{}
+	if o.Theme != "" && o.Theme != ThemeAuto {
+		query.Set("theme", o.Theme)
+	}
+	if o.From > 0 {
+		query.Set("from", strconv.Itoa(o.From))
+	}
+	if o.To > 0 {
+		query.Set("to", strconv.Itoa(o.To))
+	}
+	return query
+}
+
+// Range returns the 0-based, half-open range of a snapshot of total
+// messages to show. It is empty when From is past the last message.
+func (o EmbedOptions) Range(total int) (start, end int) {
+	start, end = 0, total
+	if o.From > 0 {
+		start = min(o.From-1, total)
+	}
+	if o.To > 0 {
+		end = min(o.To, total)
+	}
+	end = min(end, start+MaxEmbedMessages)
+	return start, end
+}
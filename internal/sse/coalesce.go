@@ -0,0 +1,174 @@
+package sse
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCoalesced is how much text a Coalescer holds back at most; past it
+// everything is sent, so a runaway line can't stall the stream
+const maxCoalesced = 4096
+
+// Coalescer regroups streamed Markdown so chunks end at safe boundaries and
+// clients rendering each chunk don't flicker through half-built constructs.
+// Code blocks are sent a line at a time and fence lines whole; other text up
+// to the last space outside inline code, bold text and links. The text sent
+// adds up to the text written.
+type Coalescer struct {
+	pending string
+
+	// lineStart is set when pending starts a new line
+	lineStart bool
+
+	// fence is the fence of the open code block, empty outside one
+	fence string
+}
+
+// NewCoalescer creates a coalescer for a new reply
+func NewCoalescer() *Coalescer {
+	return &Coalescer{lineStart: true}
+}
+
+// Write adds a chunk and returns the text that is safe to send, which may
+// be empty
+func (c *Coalescer) Write(chunk string) string {
+	c.pending += chunk
+
+	// Complete lines are always safe
+	cut := 0
+	for {
+		end := strings.IndexByte(c.pending[cut:], '\n')
+		if end < 0 {
+			break
+		}
+		c.line(c.pending[cut:cut+end], cut > 0 || c.lineStart)
+		cut += end + 1
+	}
+	lineStart := cut > 0 || c.lineStart
+
+	// Then the part of the unfinished line that can't change meaning
+	rest := c.pending[cut:]
+	switch {
+	case c.fence != "":
+		// Code goes a line at a time
+	case lineStart && maybeFence(rest):
+		// Wait for the fence's info string
+	default:
+		cut += safePrefix(rest)
+	}
+
+	if len(c.pending)-cut > maxCoalesced {
+		cut = len(c.pending)
+		// Except for a character split across chunks
+		for i := len(c.pending) - 1; i >= 0 && i >= len(c.pending)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(c.pending[i]) {
+				if !utf8.FullRuneInString(c.pending[i:]) {
+					cut = i
+				}
+				break
+			}
+		}
+	}
+	return c.take(cut)
+}
+
+// Flush returns the text held back, at the end of the reply
+func (c *Coalescer) Flush() string {
+	return c.take(len(c.pending))
+}
+
+// take returns the first n bytes of pending
+func (c *Coalescer) take(n int) string {
+	if n == 0 {
+		return ""
+	}
+	out := c.pending[:n]
+	c.pending = c.pending[n:]
+	c.lineStart = strings.HasSuffix(out, "\n")
+	return out
+}
+
+// line tracks code blocks across a complete line; fences only count at the
+// start of a line
+func (c *Coalescer) line(line string, atStart bool) {
+	if !atStart {
+		return
+	}
+	marker := fenceMarker(line)
+	switch {
+	case marker == "":
+	case c.fence == "":
+		c.fence = marker
+	case marker[0] == c.fence[0] && len(marker) >= len(c.fence) && strings.TrimSpace(line) == marker:
+		// A closing fence has no info string
+		c.fence = ""
+	}
+}
+
+// fenceMarker returns the run of backticks or tildes opening a fence line,
+// or "" if line is no fence
+func fenceMarker(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return ""
+	}
+	n := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+	if n < 3 {
+		return ""
+	}
+	return trimmed[:n]
+}
+
+// maybeFence reports whether the start of a line may still turn out to be,
+// or is, a fence line
+func maybeFence(start string) bool {
+	trimmed := strings.TrimLeft(start, " ")
+	if len(start)-len(trimmed) > 3 {
+		return false
+	}
+	if trimmed == "" {
+		return true
+	}
+	if trimmed[0] != '`' && trimmed[0] != '~' {
+		return false
+	}
+	// A short run of fence characters could still grow into a fence
+	return fenceMarker(trimmed) != "" || strings.TrimLeft(trimmed, trimmed[:1]) == ""
+}
+
+// safePrefix returns how much of an unfinished line can be sent: up to its
+// last space outside inline code, bold text and link text or targets
+func safePrefix(text string) int {
+	var (
+		code     bool
+		bold     bool
+		brackets int
+		parens   int
+		safe     int
+	)
+	for i := 0; i < len(text); i++ {
+		switch ch := text[i]; {
+		case ch == '`':
+			code = !code
+		case code:
+		case ch == '*' && i+1 < len(text) && text[i+1] == '*':
+			bold = !bold
+			i++
+		case ch == '[':
+			brackets++
+		case ch == ']' && brackets > 0:
+			brackets--
+			if i+1 < len(text) && text[i+1] == '(' {
+				parens++
+				i++
+			}
+		case ch == ')' && parens > 0:
+			parens--
+		case ch == ' ' || ch == '\t':
+			if !bold && brackets == 0 && parens == 0 {
+				safe = i + 1
+			}
+		}
+	}
+	return safe
+}
@@ -0,0 +1,220 @@
+package sse
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// Versions of the chat streaming event schema, negotiated per request (see
+// NegotiateProtocol) and sent in the init event. A version is added on
+// incompatible changes; adding event types or optional fields does not
+// need one.
+//
+// In v1 every event's data is a JSON object with a "type" field:
+//
+//	init        {"type","version","conversation_id","message_id","generation_id"}
+//	status      {"type","stage","provider"?,"model"?,"tool"?}
+//	chunk       {"type","content"}
+//	tool_call   {"type","id","name","arguments"}
+//	tool_result {"type","id","name","result"?,"error"?}
+//	usage       {"type","provider","model","prompt_tokens","completion_tokens","total_tokens"}
+//	title       {"type","conversation_id","title"}
+//	complete    {"type","message_id","finish_reason"?}
+//	cancelled   {"type"}
+//	error       {"type","error"}
+//
+// A stream starts with init and ends with exactly one of complete, cancelled
+// or error. status events report the stage of the generation (queued,
+// retrieving_context, model_selected, generating, tool_running) and may come
+// anywhere in between. usage, when the provider reports it, is sent right
+// before complete. A new conversation starts with a placeholder title; title
+// carries the generated one if it is ready before the stream ends, otherwise
+// clients learn it from the conversation_renamed event. finish_reason says
+// why the reply ended, e.g. stop on a stop sequence or length at the
+// maximum output length. Clients must ignore event types they don't know.
+//
+// v2 sends the same events, typed: each names its type in the SSE event
+// field, so EventSource clients listen per type rather than on message, and
+// its data carries "seq", the event's position in the stream, counting from
+// 1 without gaps. complete also carries the generation's "usage", as in the
+// usage event, when the provider reported it.
+const (
+	ProtocolV1 = 1
+	ProtocolV2 = 2
+)
+
+// Chat streaming event types
+const (
+	EventTypeInit       = "init"
+	EventTypeStatus     = "status"
+	EventTypeChunk      = "chunk"
+	EventTypeToolCall   = "tool_call"
+	EventTypeToolResult = "tool_result"
+	EventTypeUsage      = "usage"
+	EventTypeTitle      = "title"
+	EventTypeComplete   = "complete"
+	EventTypeCancelled  = "cancelled"
+	EventTypeError      = "error"
+)
+
+// InitEvent opens a stream
+type InitEvent struct {
+	Type           string    `json:"type"`
+	Version        int       `json:"version"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	MessageID      int64     `json:"message_id"`
+	GenerationID   string    `json:"generation_id"`
+}
+
+// NewInitEvent creates an init event. Events are buffered as v1 and
+// Encode sets the version a client negotiated.
+func NewInitEvent(conversationID uuid.UUID, messageID int64, generationID string) InitEvent {
+	return InitEvent{
+		Type:           EventTypeInit,
+		Version:        ProtocolV1,
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		GenerationID:   generationID,
+	}
+}
+
+// StatusEvent reports the stage of the generation so clients can show
+// progress before and between chunks
+type StatusEvent struct {
+	Type     string `json:"type"`
+	Stage    string `json:"stage"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Tool     string `json:"tool,omitempty"`
+}
+
+// NewStatusEvent creates a status event
+func NewStatusEvent(stage, provider, model, tool string) StatusEvent {
+	return StatusEvent{Type: EventTypeStatus, Stage: stage, Provider: provider, Model: model, Tool: tool}
+}
+
+// ChunkEvent carries a piece of generated text
+type ChunkEvent struct {
+	Type    string `json:"type"`
+	Content string `json:"content"`
+}
+
+// NewChunkEvent creates a chunk event
+func NewChunkEvent(content string) ChunkEvent {
+	return ChunkEvent{Type: EventTypeChunk, Content: content}
+}
+
+// ToolCallEvent is sent when the model asks for a tool to be run
+type ToolCallEvent struct {
+	Type      string          `json:"type"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// NewToolCallEvent creates a tool_call event
+func NewToolCallEvent(id, name string, arguments json.RawMessage) ToolCallEvent {
+	return ToolCallEvent{Type: EventTypeToolCall, ID: id, Name: name, Arguments: arguments}
+}
+
+// ToolResultEvent is sent when a tool call finishes. ID matches the
+// corresponding tool_call event.
+type ToolResultEvent struct {
+	Type   string          `json:"type"`
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// NewToolResultEvent creates a tool_result event
+func NewToolResultEvent(id, name string, result json.RawMessage, err error) ToolResultEvent {
+	event := ToolResultEvent{Type: EventTypeToolResult, ID: id, Name: name, Result: result}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// UsageEvent reports token usage of the generation
+type UsageEvent struct {
+	Type string `json:"type"`
+	Usage
+}
+
+// Usage is the token usage of a generation
+type Usage struct {
+	Provider         string `json:"provider"`
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+
+	// CostUSD is the price of the tokens, when the model has a price
+	CostUSD float64 `json:"cost_usd,omitempty"`
+}
+
+// NewUsageEvent creates a usage event
+func NewUsageEvent(provider, model string, promptTokens, completionTokens, totalTokens int) UsageEvent {
+	return UsageEvent{
+		Type: EventTypeUsage,
+		Usage: Usage{
+			Provider:         provider,
+			Model:            model,
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      totalTokens,
+		},
+	}
+}
+
+// TitleEvent carries the generated title of a new conversation
+type TitleEvent struct {
+	Type           string    `json:"type"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	Title          string    `json:"title"`
+}
+
+// NewTitleEvent creates a title event
+func NewTitleEvent(conversationID uuid.UUID, title string) TitleEvent {
+	return TitleEvent{Type: EventTypeTitle, ConversationID: conversationID, Title: title}
+}
+
+// CompleteEvent closes a successful stream
+type CompleteEvent struct {
+	Type      string `json:"type"`
+	MessageID int64  `json:"message_id"`
+
+	// FinishReason is why the reply ended, when known
+	FinishReason string `json:"finish_reason,omitempty"`
+
+	// Usage repeats the usage event; only v2 clients get it
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// NewCompleteEvent creates a complete event
+func NewCompleteEvent(messageID int64) CompleteEvent {
+	return CompleteEvent{Type: EventTypeComplete, MessageID: messageID}
+}
+
+// CancelledEvent closes a stream stopped by the client
+type CancelledEvent struct {
+	Type string `json:"type"`
+}
+
+// NewCancelledEvent creates a cancelled event
+func NewCancelledEvent() CancelledEvent {
+	return CancelledEvent{Type: EventTypeCancelled}
+}
+
+// ErrorEvent closes a failed stream
+type ErrorEvent struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// NewErrorEvent creates an error event
+func NewErrorEvent(message string) ErrorEvent {
+	return ErrorEvent{Type: EventTypeError, Error: message}
+}
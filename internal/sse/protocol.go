@@ -0,0 +1,96 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ProtocolHeader names the chat streaming protocol versions a client
+// accepts on a request, and the negotiated one on the response.
+// ProtocolParam does the same as a query parameter, for EventSource, which
+// can't set headers.
+const (
+	ProtocolHeader = "X-Stream-Protocol"
+	ProtocolParam  = "stream_protocol"
+)
+
+// LatestProtocol is the newest protocol version the server speaks
+const LatestProtocol = ProtocolV2
+
+// ErrUnsupportedProtocol is returned when a client accepts none of the
+// versions the server speaks
+var ErrUnsupportedProtocol = errors.New("sse: unsupported stream protocol")
+
+// NegotiateProtocol picks the newest version the server speaks out of the
+// comma-separated versions a client accepts, such as "2, 1" or "v2".
+// Clients that name none get v1, which they were written against.
+func NegotiateProtocol(accepted string) (int, error) {
+	if strings.TrimSpace(accepted) == "" {
+		return ProtocolV1, nil
+	}
+
+	version := 0
+	for _, value := range strings.Split(accepted, ",") {
+		value = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "v")
+		v, err := strconv.Atoi(value)
+		if err != nil || v < ProtocolV1 || v > LatestProtocol {
+			continue
+		}
+		version = max(version, v)
+	}
+	if version == 0 {
+		return 0, ErrUnsupportedProtocol
+	}
+	return version, nil
+}
+
+// Encode renders a buffered event for a client of the given protocol
+// version. Events are buffered as v1 JSON; seq is the event's position in
+// the stream, 0 when it could not be buffered. Data that is not a JSON
+// object is sent as is.
+func Encode(version int, id string, seq int64, data []byte) Event {
+	if version < ProtocolV2 {
+		return Event{ID: id, Data: encodeV1(data)}
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Event{ID: id, Data: data}
+	}
+
+	var eventType string
+	_ = json.Unmarshal(fields["type"], &eventType)
+	if eventType == EventTypeInit {
+		fields["version"] = json.RawMessage(strconv.Itoa(version))
+	}
+	if seq > 0 {
+		fields["seq"] = json.RawMessage(strconv.FormatInt(seq, 10))
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return Event{ID: id, Data: data}
+	}
+	return Event{ID: id, Event: eventType, Data: encoded}
+}
+
+// encodeV1 drops what v1 clients don't know of: the usage of complete
+func encodeV1(data []byte) []byte {
+	if !bytes.Contains(data, []byte(`"usage"`)) {
+		return data
+	}
+
+	var complete CompleteEvent
+	if err := json.Unmarshal(data, &complete); err != nil || complete.Type != EventTypeComplete {
+		return data
+	}
+	complete.Usage = nil
+	encoded, err := json.Marshal(complete)
+	if err != nil {
+		return data
+	}
+	return encoded
+}
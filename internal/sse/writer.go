@@ -0,0 +1,333 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrClientGone is returned when writing to a client that has disconnected
+var ErrClientGone = errors.New("sse: client disconnected")
+
+// Event is a single server-sent event
+type Event struct {
+	ID    string
+	Event string
+	Data  []byte
+	Retry time.Duration
+}
+
+// Overflow decides what happens to a client too slow to keep up with its
+// buffered events
+type Overflow int
+
+const (
+	// OverflowAbort disconnects the client, which can resume from the last
+	// event it received
+	OverflowAbort Overflow = iota
+
+	// OverflowDropOldest discards the oldest buffered event
+	OverflowDropOldest
+)
+
+// Config holds SSE writer configuration
+type Config struct {
+	// HeartbeatInterval is how often a comment line is sent to keep
+	// proxies from closing idle connections. Zero disables heartbeats.
+	HeartbeatInterval time.Duration
+
+	// WriteTimeout bounds each write to the client. Zero disables deadlines.
+	WriteTimeout time.Duration
+
+	// BufferSize is how many events may wait for a slow client, so the
+	// sender never blocks on the connection. Zero writes synchronously.
+	BufferSize int
+
+	// Overflow applies when the buffer is full
+	Overflow Overflow
+}
+
+// DefaultConfig returns default SSE writer configuration
+func DefaultConfig() *Config {
+	return &Config{
+		HeartbeatInterval: 15 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		BufferSize:        256,
+		Overflow:          OverflowAbort,
+	}
+}
+
+// Writer writes server-sent events to an HTTP response
+type Writer struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	config *Config
+
+	// queue holds events for the pump when buffering; writeMu serializes
+	// writes to the response, which may block until the write deadline
+	queue   chan []byte
+	pumped  chan struct{}
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	gone    bool
+	goneAt  time.Time
+	closed  bool
+	dropped int
+	done    chan struct{}
+}
+
+// NewWriter prepares the response for streaming and starts sending
+// heartbeats until the writer is closed or ctx is cancelled
+func NewWriter(ctx context.Context, w http.ResponseWriter, config *Config) *Writer {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sw := &Writer{
+		w:      w,
+		rc:     http.NewResponseController(w),
+		config: config,
+		done:   make(chan struct{}),
+	}
+	sw.flush()
+
+	if config.BufferSize > 0 {
+		sw.queue = make(chan []byte, config.BufferSize)
+		sw.pumped = make(chan struct{})
+		go sw.pump()
+	}
+	go sw.watch(ctx)
+
+	return sw
+}
+
+// pump writes buffered events until the writer is closed, then delivers
+// what is left
+func (sw *Writer) pump() {
+	defer close(sw.pumped)
+
+	for {
+		select {
+		case p := <-sw.queue:
+			sw.writeNow(p)
+		case <-sw.done:
+			for {
+				select {
+				case p := <-sw.queue:
+					sw.writeNow(p)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// watch sends heartbeats and detects client disconnects
+func (sw *Writer) watch(ctx context.Context) {
+	var tick <-chan time.Time
+	if sw.config.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(sw.config.HeartbeatInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			sw.markGone()
+			return
+		case <-sw.done:
+			return
+		case <-tick:
+			if err := sw.Comment("heartbeat"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Send writes an event to the client
+func (sw *Writer) Send(event Event) error {
+	var buf bytes.Buffer
+
+	if event.ID != "" {
+		fmt.Fprintf(&buf, "id: %s\n", event.ID)
+	}
+	if event.Event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event.Event)
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(&buf, "retry: %d\n", event.Retry.Milliseconds())
+	}
+	for _, line := range strings.Split(string(event.Data), "\n") {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+
+	return sw.write(buf.Bytes())
+}
+
+// SendJSON marshals v and writes it as the data of an event
+func (sw *Writer) SendJSON(id string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+	return sw.Send(Event{ID: id, Data: data})
+}
+
+// Comment writes an SSE comment line, which clients ignore
+func (sw *Writer) Comment(text string) error {
+	return sw.write([]byte(": " + text + "\n\n"))
+}
+
+// Gone reports whether the client has disconnected
+func (sw *Writer) Gone() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.gone
+}
+
+// GoneAt returns when the client disconnected, or the zero time
+func (sw *Writer) GoneAt() time.Time {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.goneAt
+}
+
+// Dropped returns how many events were discarded for a slow client
+func (sw *Writer) Dropped() int {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.dropped
+}
+
+// Close stops heartbeats and waits until buffered events are written or
+// the client is found gone. The writer must not be used afterwards.
+func (sw *Writer) Close() {
+	sw.mu.Lock()
+	if !sw.closed {
+		sw.closed = true
+		close(sw.done)
+	}
+	sw.mu.Unlock()
+
+	if sw.pumped != nil {
+		<-sw.pumped
+	}
+	// Wait for a heartbeat being written
+	sw.writeMu.Lock()
+	sw.writeMu.Unlock()
+}
+
+func (sw *Writer) write(p []byte) error {
+	if sw.queue == nil {
+		sw.writeMu.Lock()
+		defer sw.writeMu.Unlock()
+		if sw.isGoneOrClosed() {
+			return ErrClientGone
+		}
+		return sw.writeLocked(p)
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if sw.gone || sw.closed {
+		return ErrClientGone
+	}
+
+	select {
+	case sw.queue <- p:
+		return nil
+	default:
+	}
+
+	if sw.config.Overflow == OverflowAbort {
+		sw.markGoneLocked()
+		return fmt.Errorf("%w: client too slow", ErrClientGone)
+	}
+
+	// The pump may take an event meanwhile, so the second send can only
+	// fail if it filled the buffer again
+	select {
+	case <-sw.queue:
+		sw.dropped++
+	default:
+	}
+	select {
+	case sw.queue <- p:
+	default:
+		sw.dropped++
+	}
+	return nil
+}
+
+// writeNow writes a buffered event unless the client is gone
+func (sw *Writer) writeNow(p []byte) {
+	sw.writeMu.Lock()
+	defer sw.writeMu.Unlock()
+
+	if !sw.Gone() {
+		sw.writeLocked(p)
+	}
+}
+
+// writeLocked writes p to the client within the write deadline. Must be
+// called with writeMu held.
+func (sw *Writer) writeLocked(p []byte) error {
+	if sw.config.WriteTimeout > 0 {
+		// Not all writers support deadlines; ignore http.ErrNotSupported
+		_ = sw.rc.SetWriteDeadline(time.Now().Add(sw.config.WriteTimeout))
+	}
+
+	if _, err := sw.w.Write(p); err != nil {
+		sw.markGone()
+		return fmt.Errorf("%w: %v", ErrClientGone, err)
+	}
+
+	sw.flush()
+	return nil
+}
+
+func (sw *Writer) isGoneOrClosed() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.gone || sw.closed
+}
+
+func (sw *Writer) flush() {
+	if err := sw.rc.Flush(); err != nil {
+		if f, ok := sw.w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+func (sw *Writer) markGone() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.markGoneLocked()
+}
+
+func (sw *Writer) markGoneLocked() {
+	if !sw.gone {
+		sw.gone = true
+		sw.goneAt = time.Now()
+	}
+}
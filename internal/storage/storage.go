@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/logger"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("storage: object not found")
+
+// Store persists binary objects such as uploaded files under string keys
+type Store interface {
+	// Put writes the object, replacing any existing object with the same key
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+
+	// Get opens the object for reading; callers must close the reader
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// SignedURL returns a URL granting read access to the object until ttl
+	// elapses, without further authentication
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+
+	// Delete removes the object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// New creates the store selected by STORAGE_BACKEND
+func New(ctx context.Context, cfg *config.Config) (Store, error) {
+	switch cfg.Storage.Backend {
+	case "local":
+		store, err := NewLocal(cfg.Storage.LocalPath, cfg.Storage.PublicURL, cfg.Storage.SigningSecret)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Logger.Info().Str("path", cfg.Storage.LocalPath).Msg("Using local file storage")
+		return store, nil
+	case "s3":
+		store, err := NewS3(ctx, S3Config{
+			Endpoint:  cfg.Storage.S3Endpoint,
+			Region:    cfg.Storage.S3Region,
+			Bucket:    cfg.Storage.S3Bucket,
+			AccessKey: cfg.Storage.S3AccessKey,
+			SecretKey: cfg.Storage.S3SecretKey,
+			UseSSL:    cfg.Storage.S3UseSSL,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Logger.Info().Str("bucket", cfg.Storage.S3Bucket).Msg("Using S3 file storage")
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.Storage.Backend)
+	}
+}
@@ -0,0 +1,221 @@
+package streaming
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryStream holds the buffered state of a single stream
+type memoryStream struct {
+	Stream
+
+	events     []Event
+	nextID     int64
+	done       bool
+	cancelled  bool
+	lastRead   time.Time
+	finishedAt time.Time
+	notify     chan struct{}
+}
+
+// broadcast wakes up waiting readers. Must be called with the store lock held.
+func (s *memoryStream) broadcast() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// MemoryStore is an in-process Store. It only supports resuming streams on
+// the instance that started them.
+type MemoryStore struct {
+	mu        sync.Mutex
+	streams   map[string]*memoryStream
+	ttl       time.Duration
+	maxEvents int
+}
+
+// NewMemoryStore creates an in-memory stream store. Finished streams are
+// kept for ttl, and each stream retains at most maxEvents events.
+func NewMemoryStore(ttl time.Duration, maxEvents int) *MemoryStore {
+	return &MemoryStore{
+		streams:   make(map[string]*memoryStream),
+		ttl:       ttl,
+		maxEvents: maxEvents,
+	}
+}
+
+func (m *MemoryStore) Start(ctx context.Context, userID, conversationID uuid.UUID) (*Stream, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cleanupLocked()
+
+	stream := &memoryStream{
+		Stream: Stream{
+			ID:             uuid.New().String(),
+			UserID:         userID,
+			ConversationID: conversationID,
+		},
+		notify: make(chan struct{}),
+	}
+	m.streams[stream.ID] = stream
+
+	info := stream.Stream
+	return &info, nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) (*Stream, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cleanupLocked()
+
+	stream, ok := m.streams[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	info := stream.Stream
+	return &info, nil
+}
+
+func (m *MemoryStore) Append(ctx context.Context, id string, data []byte) (Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, ok := m.streams[id]
+	if !ok {
+		return Event{}, ErrNotFound
+	}
+
+	stream.nextID++
+	event := Event{ID: stream.nextID, Data: data}
+	stream.events = append(stream.events, event)
+
+	// Drop the oldest events once the buffer is full
+	if m.maxEvents > 0 && len(stream.events) > m.maxEvents {
+		stream.events = stream.events[len(stream.events)-m.maxEvents:]
+	}
+
+	stream.broadcast()
+	return event, nil
+}
+
+func (m *MemoryStore) Finish(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, ok := m.streams[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if !stream.done {
+		stream.done = true
+		stream.finishedAt = time.Now()
+		stream.broadcast()
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) Read(ctx context.Context, id string, lastID int64, wait time.Duration) ([]Event, bool, error) {
+	events, done, notify, err := m.since(id, lastID)
+	if err != nil || len(events) > 0 || done || wait <= 0 {
+		return events, done, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-notify:
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	events, done, _, err = m.since(id, lastID)
+	return events, done, err
+}
+
+func (m *MemoryStore) since(id string, lastID int64) ([]Event, bool, <-chan struct{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, ok := m.streams[id]
+	if !ok {
+		return nil, false, nil, ErrNotFound
+	}
+
+	var events []Event
+	for _, event := range stream.events {
+		if event.ID > lastID {
+			events = append(events, event)
+		}
+	}
+
+	return events, stream.done, stream.notify, nil
+}
+
+func (m *MemoryStore) Cancel(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, ok := m.streams[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	stream.cancelled = true
+	return nil
+}
+
+func (m *MemoryStore) IsCancelled(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, ok := m.streams[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+
+	return stream.cancelled, nil
+}
+
+func (m *MemoryStore) MarkRead(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, ok := m.streams[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	stream.lastRead = time.Now()
+	return nil
+}
+
+func (m *MemoryStore) LastRead(ctx context.Context, id string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, ok := m.streams[id]
+	if !ok {
+		return time.Time{}, ErrNotFound
+	}
+
+	return stream.lastRead, nil
+}
+
+// cleanupLocked drops finished streams older than the TTL
+func (m *MemoryStore) cleanupLocked() {
+	now := time.Now()
+	for id, stream := range m.streams {
+		if stream.done && now.Sub(stream.finishedAt) > m.ttl {
+			delete(m.streams, id)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shivaluma/eino-agent/internal/pubsub"
+)
+
+// activeStreamTTL bounds how long an unfinished stream is kept in Redis, in
+// case the instance generating it dies before calling Finish
+const activeStreamTTL = time.Hour
+
+// RedisStore is a Store backed by Redis Streams, so any server instance can
+// serve a resumed stream. Appends are announced over pub/sub, which wakes
+// readers waiting on any instance.
+type RedisStore struct {
+	client    *redis.Client
+	pubsub    pubsub.PubSub
+	prefix    string
+	ttl       time.Duration
+	maxEvents int64
+}
+
+// NewRedisStore creates a Redis-backed stream store. Finished streams are
+// kept for ttl, and each stream retains roughly maxEvents events.
+func NewRedisStore(client *redis.Client, ps pubsub.PubSub, prefix string, ttl time.Duration, maxEvents int) *RedisStore {
+	return &RedisStore{
+		client:    client,
+		pubsub:    ps,
+		prefix:    prefix,
+		ttl:       ttl,
+		maxEvents: int64(maxEvents),
+	}
+}
+
+func (r *RedisStore) metaKey(id string) string {
+	return r.prefix + "stream:" + id + ":meta"
+}
+
+func (r *RedisStore) eventsKey(id string) string {
+	return r.prefix + "stream:" + id + ":events"
+}
+
+// channel is the pub/sub channel appends to a stream are announced on
+func (r *RedisStore) channel(id string) string {
+	return "stream:" + id
+}
+
+// notify wakes up the stream's readers. Readers that miss it catch up when
+// their wait times out.
+func (r *RedisStore) notify(ctx context.Context, id string) {
+	_ = r.pubsub.Publish(ctx, r.channel(id), nil)
+}
+
+func (r *RedisStore) Start(ctx context.Context, userID, conversationID uuid.UUID) (*Stream, error) {
+	stream := &Stream{
+		ID:             uuid.New().String(),
+		UserID:         userID,
+		ConversationID: conversationID,
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, r.metaKey(stream.ID), map[string]interface{}{
+		"user_id":         userID.String(),
+		"conversation_id": conversationID.String(),
+		"seq":             0,
+		"done":            0,
+		"cancelled":       0,
+	})
+	pipe.Expire(ctx, r.metaKey(stream.ID), activeStreamTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to start stream: %w", err)
+	}
+
+	return stream, nil
+}
+
+func (r *RedisStore) Get(ctx context.Context, id string) (*Stream, error) {
+	values, err := r.client.HMGet(ctx, r.metaKey(id), "user_id", "conversation_id").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream: %w", err)
+	}
+
+	userIDStr, _ := values[0].(string)
+	conversationIDStr, _ := values[1].(string)
+	if userIDStr == "" {
+		return nil, ErrNotFound
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stream user ID: %w", err)
+	}
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stream conversation ID: %w", err)
+	}
+
+	return &Stream{ID: id, UserID: userID, ConversationID: conversationID}, nil
+}
+
+func (r *RedisStore) Append(ctx context.Context, id string, data []byte) (Event, error) {
+	seq, err := r.client.HIncrBy(ctx, r.metaKey(id), "seq", 1).Result()
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to allocate event ID: %w", err)
+	}
+
+	err = r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.eventsKey(id),
+		MaxLen: r.maxEvents,
+		Approx: true,
+		ID:     "0-" + strconv.FormatInt(seq, 10),
+		Values: map[string]interface{}{"data": data},
+	}).Err()
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to append stream event: %w", err)
+	}
+
+	r.client.Expire(ctx, r.eventsKey(id), activeStreamTTL)
+	r.notify(ctx, id)
+
+	return Event{ID: seq, Data: data}, nil
+}
+
+func (r *RedisStore) Finish(ctx context.Context, id string) error {
+	seq, err := r.client.HIncrBy(ctx, r.metaKey(id), "seq", 1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to finish stream: %w", err)
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, r.metaKey(id), "done", 1)
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.eventsKey(id),
+		ID:     "0-" + strconv.FormatInt(seq, 10),
+		Values: map[string]interface{}{"done": 1},
+	})
+	pipe.Expire(ctx, r.metaKey(id), r.ttl)
+	pipe.Expire(ctx, r.eventsKey(id), r.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to finish stream: %w", err)
+	}
+	r.notify(ctx, id)
+
+	return nil
+}
+
+func (r *RedisStore) Read(ctx context.Context, id string, lastID int64, wait time.Duration) ([]Event, bool, error) {
+	events, done, err := r.since(ctx, id, lastID)
+	if err != nil || len(events) > 0 || done || wait <= 0 {
+		return events, done, err
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Subscribe before reading again, so an event appended in between
+	// isn't missed
+	notify, err := r.pubsub.Subscribe(subCtx, r.channel(id))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to wait for stream events: %w", err)
+	}
+	events, done, err = r.since(ctx, id, lastID)
+	if err != nil || len(events) > 0 || done {
+		return events, done, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-notify:
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	return r.since(ctx, id, lastID)
+}
+
+// since returns the events after lastID and whether the stream is done
+func (r *RedisStore) since(ctx context.Context, id string, lastID int64) ([]Event, bool, error) {
+	messages, err := r.client.XRange(ctx, r.eventsKey(id), "(0-"+strconv.FormatInt(lastID, 10), "+").Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read stream events: %w", err)
+	}
+
+	events, done := parseMessages(messages)
+	if len(events) > 0 || done {
+		return events, done, nil
+	}
+
+	finished, err := r.isDone(ctx, id)
+	return nil, finished, err
+}
+
+func (r *RedisStore) isDone(ctx context.Context, id string) (bool, error) {
+	values, err := r.client.HMGet(ctx, r.metaKey(id), "user_id", "done").Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to get stream state: %w", err)
+	}
+
+	if userID, _ := values[0].(string); userID == "" {
+		return false, ErrNotFound
+	}
+
+	done, _ := values[1].(string)
+	return done == "1", nil
+}
+
+func (r *RedisStore) Cancel(ctx context.Context, id string) error {
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+
+	if err := r.client.HSet(ctx, r.metaKey(id), "cancelled", 1).Err(); err != nil {
+		return fmt.Errorf("failed to cancel stream: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RedisStore) IsCancelled(ctx context.Context, id string) (bool, error) {
+	cancelled, err := r.client.HGet(ctx, r.metaKey(id), "cancelled").Result()
+	if err == redis.Nil {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check stream cancellation: %w", err)
+	}
+
+	return cancelled == "1", nil
+}
+
+func (r *RedisStore) MarkRead(ctx context.Context, id string) error {
+	// Don't recreate the metadata of an expired stream
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+
+	if err := r.client.HSet(ctx, r.metaKey(id), "read_at", time.Now().UnixMilli()).Err(); err != nil {
+		return fmt.Errorf("failed to mark stream read: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStore) LastRead(ctx context.Context, id string) (time.Time, error) {
+	values, err := r.client.HMGet(ctx, r.metaKey(id), "user_id", "read_at").Result()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get stream read time: %w", err)
+	}
+
+	if userID, _ := values[0].(string); userID == "" {
+		return time.Time{}, ErrNotFound
+	}
+
+	readAt, _ := values[1].(string)
+	if readAt == "" {
+		return time.Time{}, nil
+	}
+	ms, err := strconv.ParseInt(readAt, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid stream read time: %w", err)
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// parseMessages converts Redis stream entries into events, skipping the
+// completion marker
+func parseMessages(messages []redis.XMessage) ([]Event, bool) {
+	var events []Event
+	done := false
+
+	for _, message := range messages {
+		if _, ok := message.Values["done"]; ok {
+			done = true
+			continue
+		}
+
+		seq, err := strconv.ParseInt(message.ID[strings.Index(message.ID, "-")+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		data, _ := message.Values["data"].(string)
+		events = append(events, Event{ID: seq, Data: []byte(data)})
+	}
+
+	return events, done
+}
@@ -0,0 +1,91 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shivaluma/eino-agent/internal/cache"
+	"github.com/shivaluma/eino-agent/internal/pubsub"
+)
+
+// ErrNotFound is returned when a stream is unknown or has expired
+var ErrNotFound = errors.New("streaming: stream not found")
+
+// Event is a single buffered stream event
+type Event struct {
+	ID   int64
+	Data []byte
+}
+
+// Stream describes one streamed AI response
+type Stream struct {
+	ID             string
+	UserID         uuid.UUID
+	ConversationID uuid.UUID
+}
+
+// Store buffers stream events so that a reconnecting client can resume from
+// the last event it received, possibly on a different server instance
+type Store interface {
+	// Start registers a new stream
+	Start(ctx context.Context, userID, conversationID uuid.UUID) (*Stream, error)
+
+	// Get returns a stream by ID, or ErrNotFound
+	Get(ctx context.Context, id string) (*Stream, error)
+
+	// Append buffers a new event and wakes up waiting readers
+	Append(ctx context.Context, id string, data []byte) (Event, error)
+
+	// Finish marks the stream as complete
+	Finish(ctx context.Context, id string) error
+
+	// Read returns events after lastID and whether the stream has finished.
+	// When no events are buffered it waits up to wait for new ones.
+	Read(ctx context.Context, id string, lastID int64, wait time.Duration) ([]Event, bool, error)
+
+	// Cancel requests that the stream's generation stops
+	Cancel(ctx context.Context, id string) error
+
+	// IsCancelled reports whether cancellation was requested
+	IsCancelled(ctx context.Context, id string) (bool, error)
+
+	// MarkRead records that a resumed client is reading the stream
+	MarkRead(ctx context.Context, id string) error
+
+	// LastRead returns when a resumed client last read the stream, or the
+	// zero time if none has
+	LastRead(ctx context.Context, id string) (time.Time, error)
+}
+
+// EventID formats the SSE event ID for an event of a stream
+func EventID(streamID string, event Event) string {
+	return streamID + ":" + strconv.FormatInt(event.ID, 10)
+}
+
+// ParseLastEventID extracts the sequence number from a Last-Event-ID value.
+// Both "<stream_id>:<seq>" and a bare "<seq>" are accepted.
+func ParseLastEventID(value string) int64 {
+	if idx := strings.LastIndex(value, ":"); idx >= 0 {
+		value = value[idx+1:]
+	}
+
+	seq, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || seq < 0 {
+		return 0
+	}
+
+	return seq
+}
+
+// NewStore returns a Redis-backed store when the shared cache is Redis, so
+// streams can be resumed on any instance, and an in-memory store otherwise
+func NewStore(c cache.Cache, ps pubsub.PubSub, prefix string, ttl time.Duration, maxEvents int) Store {
+	if rc, ok := c.(*cache.Redis); ok {
+		return NewRedisStore(rc.Client(), ps, prefix, ttl, maxEvents)
+	}
+	return NewMemoryStore(ttl, maxEvents)
+}
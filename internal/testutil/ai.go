@@ -0,0 +1,109 @@
+//go:build integration
+
+package testutil
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// StubAI answers every message with Reply, streamed a word at a time, and
+// records the requests it was sent
+type StubAI struct {
+	Reply    string
+	Title    string
+	Provider string
+	Model    string
+
+	// Err, when set, fails generations instead
+	Err error
+
+	mu       sync.Mutex
+	requests []*ai.ChatRequest
+}
+
+// NewStubAI creates a stub answering with reply
+func NewStubAI(reply string) *StubAI {
+	return &StubAI{Reply: reply, Title: "Stub title", Provider: "stub", Model: "stub-model"}
+}
+
+// Requests returns the chat requests received so far
+func (s *StubAI) Requests() []*ai.ChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*ai.ChatRequest(nil), s.requests...)
+}
+
+func (s *StubAI) record(req *ai.ChatRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+}
+
+func (s *StubAI) response(req *ai.ChatRequest) *ai.ChatResponse {
+	prompt := len(strings.Fields(req.Message))
+	completion := len(strings.Fields(s.Reply))
+	return &ai.ChatResponse{
+		Content:        s.Reply,
+		ConversationID: req.ConversationID,
+		Provider:       s.Provider,
+		Model:          s.Model,
+		FinishReason:   "stop",
+		Usage: &ai.Usage{
+			PromptTokens:     prompt,
+			CompletionTokens: completion,
+			TotalTokens:      prompt + completion,
+		},
+	}
+}
+
+func (s *StubAI) Generate(ctx context.Context, req *ai.ChatRequest) (*ai.ChatResponse, error) {
+	s.record(req)
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return s.response(req), nil
+}
+
+func (s *StubAI) Stream(ctx context.Context, req *ai.ChatRequest, callback ai.StreamCallback) (*ai.ChatResponse, error) {
+	s.record(req)
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	// Split after each space so the chunks add up to the reply
+	for _, chunk := range strings.SplitAfter(s.Reply, " ") {
+		if chunk == "" {
+			continue
+		}
+		if err := callback(chunk); err != nil {
+			return nil, err
+		}
+	}
+	return s.response(req), nil
+}
+
+func (s *StubAI) GenerateTitle(ctx context.Context, firstMessage, language string, history []*schema.Message) (string, error) {
+	return s.Title, nil
+}
+
+func (s *StubAI) RoutePersona(ctx context.Context, message string) (string, error) {
+	return "", nil
+}
+
+func (s *StubAI) ExtractMemories(ctx context.Context, message string, known []string) ([]string, error) {
+	return nil, nil
+}
+
+func (s *StubAI) DetectInjection(ctx context.Context, text string) (bool, error) {
+	return false, nil
+}
+
+func (s *StubAI) SetDefaultModel(model string) {
+	s.Model = model
+}
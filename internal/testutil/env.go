@@ -0,0 +1,216 @@
+//go:build integration
+
+package testutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/ai/providers"
+	"github.com/shivaluma/eino-agent/internal/audit"
+	"github.com/shivaluma/eino-agent/internal/auth"
+	"github.com/shivaluma/eino-agent/internal/billing"
+	"github.com/shivaluma/eino-agent/internal/cache"
+	"github.com/shivaluma/eino-agent/internal/convlock"
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/events"
+	"github.com/shivaluma/eino-agent/internal/geoip"
+	"github.com/shivaluma/eino-agent/internal/guardrails"
+	"github.com/shivaluma/eino-agent/internal/handlers"
+	"github.com/shivaluma/eino-agent/internal/imports"
+	"github.com/shivaluma/eino-agent/internal/mail"
+	"github.com/shivaluma/eino-agent/internal/memory"
+	"github.com/shivaluma/eino-agent/internal/models"
+	"github.com/shivaluma/eino-agent/internal/outbox"
+	"github.com/shivaluma/eino-agent/internal/pubsub"
+	"github.com/shivaluma/eino-agent/internal/queue"
+	"github.com/shivaluma/eino-agent/internal/repository"
+	"github.com/shivaluma/eino-agent/internal/secretbox"
+	"github.com/shivaluma/eino-agent/internal/security"
+	"github.com/shivaluma/eino-agent/internal/share"
+	"github.com/shivaluma/eino-agent/internal/storage"
+	"github.com/shivaluma/eino-agent/internal/streaming"
+	"github.com/shivaluma/eino-agent/internal/titles"
+)
+
+// Env is a test's database with the repositories and services on it,
+// wired as the server wires them but with state kept in memory
+type Env struct {
+	Config *config.Config
+	DB     *database.DB
+	Cache  cache.Cache
+	Events events.Bus
+	Tasks  queue.Queue
+
+	Users         *repository.UserRepository
+	Conversations *repository.ConversationRepository
+	Participants  *repository.ParticipantRepository
+	OAuth         *repository.OAuthRepository
+	Tx            *repository.Transactor
+
+	Auth    *auth.Service
+	Auditor *audit.Auditor
+	Monitor *security.Monitor
+}
+
+// NewEnv creates an environment on a fresh database. Sign-in country
+// lookups and security webhooks are turned off and email is only logged.
+func NewEnv(t testing.TB) *Env {
+	t.Helper()
+
+	db := DB(t)
+	cfg := Config(t)
+	cfg.Security.GeoIPURL = ""
+	cfg.Security.WebhookURL = ""
+	cfg.Mail = config.MailConfig{}
+
+	c := cache.NewMemory()
+	ps := pubsub.New(c, "")
+	t.Cleanup(func() {
+		ps.Close()
+		c.Close()
+	})
+
+	authSvc, err := auth.NewService(cfg, c)
+	if err != nil {
+		t.Fatalf("failed to create auth service: %v", err)
+	}
+
+	env := &Env{
+		Config: cfg,
+		DB:     db,
+		Cache:  c,
+		Events: events.NewBus(ps),
+		Tasks: queue.New(c, "", queue.Config{
+			Workers:     1,
+			MaxAttempts: 1,
+			Backoff:     time.Second,
+			Timeout:     time.Minute,
+		}),
+
+		Users:         repository.NewUserRepository(db),
+		Conversations: repository.NewConversationRepository(db),
+		Participants:  repository.NewParticipantRepository(db),
+		OAuth:         repository.NewOAuthRepository(db.Pool),
+		Tx:            repository.NewTransactor(db),
+
+		Auth:    authSvc,
+		Auditor: audit.NewAuditor(repository.NewAuditRepository(db), cfg.Security.AuditHashKey),
+	}
+	env.Monitor = security.NewMonitor(env.Users, env.Auth, c, geoip.New("", c), mail.New(cfg.Mail),
+		env.Auditor, env.Tasks, cfg.Security, cfg.OAuth.FrontendURL)
+	return env
+}
+
+// CreateUser creates a user signing in with email and password
+func (e *Env) CreateUser(t testing.TB, email, password string) *models.User {
+	t.Helper()
+
+	hash, err := e.Auth.HashPassword(password)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	user := &models.User{Username: email, Email: email, PasswordHash: &hash}
+	if err := e.Users.Create(context.Background(), user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return user
+}
+
+// AuthHandler creates the handler for password sign-ins
+func (e *Env) AuthHandler() *handlers.AuthHandler {
+	return handlers.NewAuthHandler(e.Users, e.Auth, e.Auditor, auth.NewLoginGuard(e.Cache, e.Config.Login), e.Monitor)
+}
+
+// OAuthHandler creates the handler for OAuth sign-ins with the providers
+// enabled in e.Config
+func (e *Env) OAuthHandler() *handlers.OAuthHandler {
+	return handlers.NewOAuthHandler(e.Users, e.OAuth, e.Tx, auth.NewStateStore(e.Cache), e.Auth,
+		auth.NewOAuthService(e.Config), e.Auditor, e.Monitor, e.Config.OAuth.FrontendURL)
+}
+
+// OrganizationHandler creates the organization handler. Organizations'
+// keys are sealed with AI_CREDENTIALS_KEY and their base URLs limited to
+// AI_CREDENTIAL_HOSTS, as configured.
+func (e *Env) OrganizationHandler(t testing.TB) *handlers.OrganizationHandler {
+	t.Helper()
+
+	box, err := secretbox.New(e.Config.AI.CredentialsKey)
+	if err != nil {
+		t.Fatalf("failed to set up credentials encryption: %v", err)
+	}
+	orgRepo := repository.NewOrganizationRepository(e.DB)
+	credentials := providers.NewFactory().EnableOrgCredentials(orgRepo, box, e.Config.AI.CredentialHosts)
+	return handlers.NewOrganizationHandler(orgRepo, e.Users, e.Tx, billing.NewRecorder(repository.NewUsageRepository(e.DB)),
+		e.Auth, e.Auditor, mail.New(e.Config.Mail), e.Config.Invite, e.Config.OAuth.FrontendURL, credentials)
+}
+
+// ShareHandler creates the handler for share links and their embeds
+func (e *Env) ShareHandler() *handlers.ShareHandler {
+	return handlers.NewShareHandler(repository.NewShareRepository(e.DB), e.Conversations, e.Auth,
+		share.NewSigner(e.Config.Share.Secret), e.Config.OAuth.FrontendURL)
+}
+
+// ImportHandler creates the import handler and its importer, storing
+// exports in a temporary directory. Exports larger than syncBytes are left
+// to a queued task, which tests run with the importer's Handle.
+func (e *Env) ImportHandler(t testing.TB, syncBytes int64) (*handlers.ImportHandler, *imports.Importer) {
+	t.Helper()
+
+	files, err := storage.NewLocal(t.TempDir(), "http://localhost", "test-storage-secret")
+	if err != nil {
+		t.Fatalf("failed to create file storage: %v", err)
+	}
+	jobs := repository.NewImportRepository(e.DB)
+	importer := imports.New(e.Conversations, e.Tx, jobs, files, e.Tasks)
+
+	cfg := e.Config.Import
+	cfg.SyncBytes = syncBytes
+	return handlers.NewImportHandler(importer, jobs, e.Auth, cfg), importer
+}
+
+// ConversationHandler creates the chat handler answering with aiService.
+// Guardrails and memories are off and uploads go to a temporary
+// directory.
+func (e *Env) ConversationHandler(t testing.TB, aiService ai.Service) *handlers.ConversationHandler {
+	t.Helper()
+
+	files, err := storage.NewLocal(t.TempDir(), "http://localhost", "test-storage-secret")
+	if err != nil {
+		t.Fatalf("failed to create file storage: %v", err)
+	}
+	guard, err := guardrails.New(guardrails.Config{})
+	if err != nil {
+		t.Fatalf("failed to create guardrails: %v", err)
+	}
+
+	cfg := e.Config
+	outboxRepo := repository.NewOutboxRepository(e.DB)
+	return handlers.NewConversationHandler(
+		e.Conversations,
+		e.Tx,
+		e.Participants,
+		repository.NewSettingsRepository(e.DB),
+		repository.NewUploadRepository(e.DB),
+		e.Auth,
+		aiService,
+		streaming.NewMemoryStore(5*time.Minute, 2000),
+		titles.New(e.Conversations, e.Participants, aiService, e.Cache, e.Events, e.Tasks),
+		files,
+		e.Events,
+		memory.NewStore(repository.NewMemoryRepository(e.DB), aiService, e.Tasks, memory.Config{}),
+		guard,
+		billing.NewRecorder(repository.NewUsageRepository(e.DB)),
+		convlock.New(e.Cache, "", convlock.Config{Wait: cfg.Messages.LockWait, TTL: cfg.Messages.LockTTL}),
+		ai.NewQueue(ai.QueueConfig{Concurrency: 4, Depth: 16, Timeout: cfg.AI.QueueTimeout}),
+		outbox.New(outboxRepo, e.Conversations, e.Tx, e.Participants, e.Events, outbox.Config{
+			BatchSize:   cfg.Messages.RetryBatchSize,
+			MaxAttempts: cfg.Messages.RetryMaxAttempts,
+		}),
+		cfg.SSE,
+		cfg.AI.AllowedModels,
+	)
+}
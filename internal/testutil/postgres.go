@@ -0,0 +1,198 @@
+//go:build integration
+
+// Package testutil sets up integration tests: a migrated PostgreSQL
+// database per test, served by a container that testcontainers starts
+// once per test binary, the services and handlers built on it, and a
+// stub AI service. It is only compiled with -tags=integration and needs
+// Docker; without it the tests that need a database are skipped.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shivaluma/eino-agent/config"
+	"github.com/shivaluma/eino-agent/internal/database"
+	"github.com/shivaluma/eino-agent/internal/migrations"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// postgresImage is the server the tests run against; override it with
+// TEST_POSTGRES_IMAGE
+const postgresImage = "postgres:16-alpine"
+
+// templateDB is migrated once; each test gets a copy of it
+const templateDB = "eino_template"
+
+const (
+	dbUser     = "postgres"
+	dbPassword = "postgres"
+)
+
+var (
+	serverOnce sync.Once
+	serverErr  error
+
+	// dockerErr is why Docker can't be reached, if it can't, which skips
+	// the tests rather than fail them
+	dockerErr error
+
+	// serverHost and serverPort address the container once started
+	serverHost string
+	serverPort int
+
+	// databases numbers the test databases
+	databases atomic.Int64
+)
+
+// DB returns a database of the test's own with all migrations applied. It
+// is dropped when the test ends.
+func DB(t testing.TB) *database.DB {
+	t.Helper()
+	ctx := context.Background()
+
+	serverOnce.Do(func() {
+		if dockerErr = checkDocker(ctx); dockerErr == nil {
+			serverErr = startPostgres(ctx)
+		}
+	})
+	if dockerErr != nil {
+		t.Skipf("Docker is unavailable: %v", dockerErr)
+	}
+	if serverErr != nil {
+		t.Fatalf("failed to start PostgreSQL: %v", serverErr)
+	}
+
+	name := fmt.Sprintf("test_%d_%d", os.Getpid(), databases.Add(1))
+	if err := adminExec(ctx, fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", name, templateDB)); err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+
+	cfg := Config(t)
+	cfg.Database.Database = name
+	db, err := database.New(cfg)
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		if err := adminExec(ctx, fmt.Sprintf("DROP DATABASE %s WITH (FORCE)", name)); err != nil {
+			t.Logf("failed to drop test database: %v", err)
+		}
+	})
+	return db
+}
+
+// Config returns the configuration tests run with: the defaults, pointed
+// at the test server
+func Config(t testing.TB) *config.Config {
+	t.Helper()
+	return testConfig()
+}
+
+func testConfig() *config.Config {
+	cfg := config.Load()
+	cfg.Env = config.EnvDevelopment
+	cfg.State.Backend = "memory"
+	cfg.Database.Driver = config.DatabaseDriverPostgres
+	cfg.Database.Host = serverHost
+	cfg.Database.Port = serverPort
+	cfg.Database.User = dbUser
+	cfg.Database.Password = dbPassword
+	cfg.Database.Database = templateDB
+	cfg.Database.SSLMode = "disable"
+	cfg.Database.MigrationsDir = ""
+	cfg.Database.StatsInterval = 0
+	cfg.JWT.AccessSecret = "test-access-secret"
+	cfg.JWT.RefreshSecret = "test-refresh-secret"
+	return cfg
+}
+
+// checkDocker reports why testcontainers can't reach Docker. Looking up
+// the provider panics when no Docker host is found at all.
+func checkDocker(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+	return provider.Health(ctx)
+}
+
+// startPostgres starts the container and migrates the template database.
+// testcontainers removes the container when the test binary exits.
+func startPostgres(ctx context.Context) error {
+	image := os.Getenv("TEST_POSTGRES_IMAGE")
+	if image == "" {
+		image = postgresImage
+	}
+
+	container, err := postgres.Run(ctx, image,
+		postgres.WithDatabase(templateDB),
+		postgres.WithUsername(dbUser),
+		postgres.WithPassword(dbPassword),
+		testcontainers.WithWaitStrategy(
+			// The server restarts once after initializing the database
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(2*time.Minute),
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		return err
+	}
+	port, err := container.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		return err
+	}
+	serverHost = host
+	serverPort, err = strconv.Atoi(port.Port())
+	if err != nil {
+		return err
+	}
+
+	cfg := testConfig()
+	db, err := database.New(cfg)
+	if err != nil {
+		return err
+	}
+	// A template can't be copied while connected to
+	defer db.Close()
+
+	return migrations.NewMigrator(db.Pool, migrations.Source(""), cfg).Migrate(ctx)
+}
+
+// adminExec runs a statement on the server's maintenance database
+func adminExec(ctx context.Context, sql string) error {
+	conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%s@%s:%d/postgres?sslmode=disable",
+		dbUser, dbPassword, serverHost, serverPort))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
@@ -0,0 +1,130 @@
+// Package titles names new conversations after their first message. Titles
+// are generated by a queued task, so the first reply isn't held up, and
+// announced to the conversation's participants once saved.
+package titles
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shivaluma/eino-agent/internal/ai"
+	"github.com/shivaluma/eino-agent/internal/cache"
+	"github.com/shivaluma/eino-agent/internal/events"
+	"github.com/shivaluma/eino-agent/internal/logger"
+	"github.com/shivaluma/eino-agent/internal/queue"
+	"github.com/shivaluma/eino-agent/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// TaskTitle is the queued task that titles a new conversation
+const TaskTitle = "title_conversation"
+
+// cacheTTL is how long generated titles are reused for identical first
+// messages
+const cacheTTL = 24 * time.Hour
+
+// titleTask is the payload of a TaskTitle task
+type titleTask struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	Placeholder    string    `json:"placeholder"`
+	Message        string    `json:"message"`
+	Language       string    `json:"language"`
+}
+
+// Titler generates conversation titles
+type Titler struct {
+	convRepo     *repository.ConversationRepository
+	participants *repository.ParticipantRepository
+	aiService    ai.Service
+	cache        cache.Cache
+	events       events.Bus
+	tasks        queue.Queue
+}
+
+func New(convRepo *repository.ConversationRepository, participants *repository.ParticipantRepository, aiService ai.Service, c cache.Cache, bus events.Bus, tasks queue.Queue) *Titler {
+	return &Titler{
+		convRepo:     convRepo,
+		participants: participants,
+		aiService:    aiService,
+		cache:        c,
+		events:       bus,
+		tasks:        tasks,
+	}
+}
+
+// Request queues titling a new conversation from its first message. The
+// conversation keeps its placeholder title until the task replaced it, and
+// keeps a title it was renamed to meanwhile.
+func (t *Titler) Request(ctx context.Context, conversationID uuid.UUID, placeholder, message, language string) error {
+	return t.tasks.Enqueue(ctx, TaskTitle, titleTask{
+		ConversationID: conversationID,
+		Placeholder:    placeholder,
+		Message:        message,
+		Language:       language,
+	})
+}
+
+// Handle runs a TaskTitle task and publishes a conversation_renamed event
+// once the title is saved
+func (t *Titler) Handle(ctx context.Context, payload json.RawMessage) error {
+	var task titleTask
+	if err := json.Unmarshal(payload, &task); err != nil {
+		return fmt.Errorf("invalid title task: %w", err)
+	}
+
+	conversation, err := t.convRepo.GetByID(ctx, task.ConversationID)
+	if err != nil {
+		return fmt.Errorf("failed to load conversation: %w", err)
+	}
+	if conversation == nil {
+		// Deleted meanwhile
+		return nil
+	}
+
+	title, err := t.generate(ai.WithOrg(ctx, conversation.OrgID), task.Message, task.Language)
+	if err != nil {
+		return fmt.Errorf("failed to generate conversation title: %w", err)
+	}
+	title = strings.Trim(strings.TrimSpace(title), "\"'")
+	if title == "" {
+		return nil
+	}
+
+	replaced, err := t.convRepo.ReplaceTitle(ctx, conversation.ID, task.Placeholder, title)
+	if err != nil {
+		return fmt.Errorf("failed to save conversation title: %w", err)
+	}
+	if !replaced {
+		return nil
+	}
+
+	conversation.Title = &title
+	events.PublishConversation(ctx, t.events, t.participants, conversation, events.NewConversationRenamed(conversation.ID, title))
+	return nil
+}
+
+// generate returns a cached title for an identical first message, or asks
+// the AI service for a new one
+func (t *Titler) generate(ctx context.Context, message, language string) (string, error) {
+	key := fmt.Sprintf("title:%s:%x", language, sha256.Sum256([]byte(message)))
+
+	if cached, err := t.cache.Get(ctx, key); err == nil {
+		return string(cached), nil
+	}
+
+	title, err := t.aiService.GenerateTitle(ctx, message, language, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if err := t.cache.Set(ctx, key, []byte(title), cacheTTL); err != nil {
+		logger.ModuleContext(ctx, "chat").Warn().Err(err).Msg("Failed to cache conversation title")
+	}
+
+	return title, nil
+}
@@ -0,0 +1,7 @@
+-- Per-conversation system prompts
+
+-- A conversation either uses a built-in persona or a custom system prompt;
+-- when both are NULL the default persona applies
+ALTER TABLE conversations
+ADD COLUMN IF NOT EXISTS persona VARCHAR(50),
+ADD COLUMN IF NOT EXISTS system_prompt TEXT;
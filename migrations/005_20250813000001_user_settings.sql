@@ -0,0 +1,16 @@
+-- Per-user AI settings
+
+-- NULL columns fall back to the server defaults
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    temperature REAL CHECK (temperature >= 0 AND temperature <= 2),
+    max_tokens INTEGER CHECK (max_tokens > 0),
+    model VARCHAR(100),
+    persona VARCHAR(50),
+    language VARCHAR(10),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER update_user_settings_updated_at BEFORE UPDATE ON user_settings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
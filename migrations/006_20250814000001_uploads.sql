@@ -0,0 +1,16 @@
+-- File uploads for multimodal chat
+
+CREATE TABLE IF NOT EXISTS uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Set once the upload is attached to a chat message
+    message_id BIGINT REFERENCES messages(id) ON DELETE SET NULL,
+    storage_key VARCHAR(500) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_uploads_user_id ON uploads(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_uploads_message_id ON uploads(message_id);
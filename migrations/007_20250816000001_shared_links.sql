@@ -0,0 +1,15 @@
+-- Public read-only links to conversation snapshots
+
+CREATE TABLE IF NOT EXISTS shared_links (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Messages up to and including this ID are part of the snapshot
+    last_message_id BIGINT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_shared_links_conversation_id ON shared_links(conversation_id);
+CREATE INDEX IF NOT EXISTS idx_shared_links_user_id ON shared_links(user_id);
@@ -0,0 +1,17 @@
+-- Users with access to a conversation besides (and including) its owner
+
+CREATE TABLE IF NOT EXISTS conversation_participants (
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'contributor', 'viewer')),
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_participants_user_id ON conversation_participants(user_id);
+
+-- Existing conversations are owned by their creator
+INSERT INTO conversation_participants (conversation_id, user_id, role)
+SELECT id, user_id, 'owner' FROM conversations
+ON CONFLICT (conversation_id, user_id) DO NOTHING;
@@ -0,0 +1,10 @@
+-- Per-user pinned conversations. Pins live on the participant row so each
+-- collaborator keeps their own pins.
+
+ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Conversation lists are read per user with pinned conversations first
+CREATE INDEX IF NOT EXISTS idx_conversation_participants_user_pinned
+    ON conversation_participants(user_id, pinned DESC);
+
+CREATE INDEX IF NOT EXISTS idx_conversations_updated_at ON conversations(updated_at DESC);
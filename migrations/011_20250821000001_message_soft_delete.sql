@@ -0,0 +1,10 @@
+-- Soft delete for messages. Deleted messages are hidden from users and from
+-- model history until the purge job removes them.
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +rollback
+DROP INDEX IF EXISTS idx_messages_deleted_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,25 @@
+-- Prompts that run later in a conversation, once at run_at or repeatedly
+-- on a cron schedule
+
+CREATE TABLE IF NOT EXISTS scheduled_prompts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    prompt TEXT NOT NULL,
+    cron_expr VARCHAR(100),
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    run_at TIMESTAMPTZ,
+    webhook_url TEXT,
+    next_run_at TIMESTAMPTZ,
+    claimed_at TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((cron_expr IS NULL) <> (run_at IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_prompts_conversation_id ON scheduled_prompts(conversation_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_prompts_next_run_at ON scheduled_prompts(next_run_at) WHERE next_run_at IS NOT NULL;
+
+-- +rollback
+DROP TABLE IF EXISTS scheduled_prompts;
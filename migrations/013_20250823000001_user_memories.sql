@@ -0,0 +1,15 @@
+-- Long-term facts about users (preferences, dietary needs, location)
+-- extracted from their messages and added to future prompts
+
+CREATE TABLE IF NOT EXISTS user_memories (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    conversation_id UUID REFERENCES conversations(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_memories_user_content ON user_memories(user_id, LOWER(content));
+
+-- +rollback
+DROP TABLE IF EXISTS user_memories;
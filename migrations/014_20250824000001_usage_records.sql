@@ -0,0 +1,23 @@
+-- Token usage and cost of every AI reply, for usage reports and billing.
+-- Records outlive the conversation and message they were made for.
+
+CREATE TABLE IF NOT EXISTS usage_records (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    conversation_id UUID REFERENCES conversations(id) ON DELETE SET NULL,
+    message_id BIGINT,
+    provider VARCHAR(50) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    total_tokens INTEGER NOT NULL DEFAULT 0,
+    -- Millionths of a US dollar; NULL when the model had no price
+    cost_micros BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_records_user_created ON usage_records(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_records_created ON usage_records(created_at);
+
+-- +rollback
+DROP TABLE IF EXISTS usage_records;
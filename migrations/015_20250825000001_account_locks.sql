@@ -0,0 +1,23 @@
+-- Accounts locked after suspicious activity stay locked until their owner
+-- follows the unlock link emailed to them. Only the token's hash is kept.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS lock_reason VARCHAR(50);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS unlock_token_hash VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_unlock_token_hash ON users(unlock_token_hash) WHERE unlock_token_hash IS NOT NULL;
+
+-- Countries each user signed in from, to spot sign-ins from new ones
+CREATE TABLE IF NOT EXISTS user_login_countries (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    country CHAR(2) NOT NULL,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, country)
+);
+
+-- +rollback
+DROP TABLE IF EXISTS user_login_countries;
+DROP INDEX IF EXISTS idx_users_unlock_token_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS unlock_token_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS lock_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS locked_at;
@@ -0,0 +1,44 @@
+-- Organizations let teams share conversations and a usage quota. A
+-- conversation started with an organization selected belongs to it, and
+-- usage of its replies is charged to the organization.
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    -- Tokens the organization may use per calendar month (UTC); NULL is
+    -- unlimited
+    monthly_token_quota BIGINT CHECK (monthly_token_quota >= 0),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER update_organizations_updated_at BEFORE UPDATE ON organizations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);
+
+-- Deleting an organization deletes its conversations
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS org_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_conversations_org_id ON conversations(org_id) WHERE org_id IS NOT NULL;
+
+-- Usage records keep the organization they were charged to, even after the
+-- conversation is gone
+ALTER TABLE usage_records ADD COLUMN IF NOT EXISTS org_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_usage_records_org_created ON usage_records(org_id, created_at) WHERE org_id IS NOT NULL;
+
+-- +rollback
+DROP INDEX IF EXISTS idx_usage_records_org_created;
+ALTER TABLE usage_records DROP COLUMN IF EXISTS org_id;
+DROP INDEX IF EXISTS idx_conversations_org_id;
+ALTER TABLE conversations DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
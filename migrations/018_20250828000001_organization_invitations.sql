@@ -0,0 +1,27 @@
+-- Invitations to join an organization, emailed as signed links. Accepting
+-- one adds the invited email's account to the organization, creating the
+-- account if there is none.
+
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'revoked', 'expired')),
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    accepted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    responded_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One pending invitation per email and organization
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_invitations_pending_email
+    ON organization_invitations(org_id, email) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_pending_expires
+    ON organization_invitations(expires_at) WHERE status = 'pending';
+
+-- +rollback
+DROP INDEX IF EXISTS idx_organization_invitations_pending_expires;
+DROP INDEX IF EXISTS idx_organization_invitations_pending_email;
+DROP TABLE IF EXISTS organization_invitations;
@@ -0,0 +1,28 @@
+-- Organizations can store their own AI provider API keys; their
+-- conversations are then answered with them and the provider bills the
+-- organization directly. Keys are encrypted with AI_CREDENTIALS_KEY and
+-- only their last characters are kept in the clear.
+
+CREATE TABLE IF NOT EXISTS organization_provider_credentials (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    api_key_encrypted BYTEA NOT NULL,
+    key_hint VARCHAR(8) NOT NULL,
+    base_url TEXT,
+    model VARCHAR(100),
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (org_id, provider)
+);
+
+CREATE TRIGGER update_organization_provider_credentials_updated_at BEFORE UPDATE ON organization_provider_credentials
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Replies made with an organization's own key are billed by the provider,
+-- not against the organization's quota
+ALTER TABLE usage_records ADD COLUMN IF NOT EXISTS own_key BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +rollback
+ALTER TABLE usage_records DROP COLUMN IF EXISTS own_key;
+DROP TABLE IF EXISTS organization_provider_credentials;
@@ -0,0 +1,10 @@
+-- Per-conversation context window strategy
+
+-- How much of the conversation is sent with each message:
+-- sliding_window, summary_window or full_history. NULL uses
+-- AI_CONTEXT_STRATEGY.
+ALTER TABLE conversations
+ADD COLUMN IF NOT EXISTS context_strategy VARCHAR(50);
+
+-- +rollback
+ALTER TABLE conversations DROP COLUMN IF EXISTS context_strategy;
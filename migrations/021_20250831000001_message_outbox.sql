@@ -0,0 +1,19 @@
+-- Writes of generated messages that failed, retried by the
+-- retry_message_writes job until they succeed or run out of attempts
+
+CREATE TABLE IF NOT EXISTS message_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    claimed_at TIMESTAMPTZ,
+    last_error TEXT,
+    failed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_outbox_next_attempt_at ON message_outbox(next_attempt_at) WHERE failed_at IS NULL;
+
+-- +rollback
+DROP TABLE IF EXISTS message_outbox;
@@ -0,0 +1,8 @@
+-- Per-user read markers, so every device of a user shows the same unread
+-- counts. Markers live on the participant row like pins. Message IDs only
+-- grow, so a message is unread when its ID is past the marker.
+
+ALTER TABLE conversation_participants ADD COLUMN IF NOT EXISTS last_read_message_id BIGINT;
+
+-- +rollback
+ALTER TABLE conversation_participants DROP COLUMN IF EXISTS last_read_message_id;
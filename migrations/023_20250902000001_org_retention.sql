@@ -0,0 +1,9 @@
+-- Per-organization override of how long inactive conversations are kept,
+-- enforced by the enforce_retention job
+
+-- Days since a conversation's last activity before it is deleted; NULL uses
+-- RETENTION_CONVERSATION_DAYS and 0 keeps conversations forever
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS conversation_retention_days INT CHECK (conversation_retention_days >= 0);
+
+-- +rollback
+ALTER TABLE organizations DROP COLUMN IF EXISTS conversation_retention_days;
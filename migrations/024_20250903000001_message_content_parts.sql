@@ -0,0 +1,8 @@
+-- Content parts of messages sent as parts
+
+-- The parts as sent: text, image (by upload) and code parts, in order. The
+-- content column holds their text. NULL for messages sent as plain text.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_parts JSONB;
+
+-- +rollback
+ALTER TABLE messages DROP COLUMN IF EXISTS content_parts;
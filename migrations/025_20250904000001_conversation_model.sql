@@ -0,0 +1,10 @@
+-- Per-conversation model
+
+-- The model every message of the conversation is answered with unless the
+-- message names one. NULL uses the user's settings, then the server
+-- default.
+ALTER TABLE conversations
+ADD COLUMN IF NOT EXISTS model VARCHAR(100);
+
+-- +rollback
+ALTER TABLE conversations DROP COLUMN IF EXISTS model;
@@ -0,0 +1,9 @@
+-- Scopes granted on linked OAuth accounts
+
+-- What the user granted the app on the provider, as reported with the last
+-- token: the sign-in scopes plus any granted later for integrations
+ALTER TABLE oauth_accounts
+ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+
+-- +rollback
+ALTER TABLE oauth_accounts DROP COLUMN IF EXISTS scopes;
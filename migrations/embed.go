@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migration files so the binary can run
+// them regardless of its working directory
+package migrations
+
+import "embed"
+
+// FS holds the migration files
+//
+//go:embed *.sql
+var FS embed.FS